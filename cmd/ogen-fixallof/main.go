@@ -0,0 +1,169 @@
+// Command ogen-fixallof removes duplicate JSON keys from ogen-generated Encode
+// methods.
+//
+// When allOf composition produces several struct fields for the same JSON
+// property, the generated encodeFields method writes the key once per field,
+// producing an object with duplicate keys. Receivers disagree about which
+// value wins, and the generated Decode method only ever populates one of the
+// fields, so the value does not round-trip.
+//
+// Usage:
+//
+//	ogen-fixallof <oas_json_gen.go>
+//
+// Precedence: for each duplicated key the tool keeps the field that the
+// type's Decode method assigns for that key, so encoding and decoding agree.
+// If the Decode method cannot be matched, the first field in declaration
+// order (the first allOf member) wins. Every removed block is replaced with a
+// comment naming the field that was dropped.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixallof: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-fixallof <oas_json_gen.go>")
+	}
+
+	filename := args[0]
+
+	content, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	fixed, count := FixDuplicateEncodeKeys(content)
+
+	if count == 0 {
+		fmt.Printf("No duplicate JSON keys needed fixing in %s\n", filename)
+		return nil
+	}
+
+	if err := os.WriteFile(filename, fixed, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	fmt.Printf("Fixed %d duplicate JSON keys in %s\n", count, filename)
+	return nil
+}
+
+var (
+	// encodeFieldsPattern matches a generated encodeFields method.
+	encodeFieldsPattern = regexp.MustCompile(
+		`(?s)func \(s \*?(\w+)\) encodeFields\(e \*jx\.Encoder\) \{\n(.*?)\n\}\n`)
+
+	// fieldBlockPattern matches one top-level block of an encodeFields body.
+	fieldBlockPattern = regexp.MustCompile(`(?s)\t\{\n.*?\n\t\}\n?`)
+
+	// fieldStartPattern extracts the JSON key and struct field from a block.
+	fieldStartPattern = regexp.MustCompile(`e\.FieldStart\("([^"]+)"\)`)
+	fieldRefPattern   = regexp.MustCompile(`\bs\.(\w+)`)
+
+	// decodeCasePattern matches a key case in a generated Decode switch,
+	// capturing the first field it assigns.
+	decodeCasePattern = regexp.MustCompile(`(?s)\t\tcase "([^"]+)":\n.*?\bs\.(\w+)`)
+)
+
+// FixDuplicateEncodeKeys removes encodeFields blocks that write a JSON key
+// already written by another block of the same method.
+func FixDuplicateEncodeKeys(content []byte) ([]byte, int) {
+	count := 0
+	fixed := encodeFieldsPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		submatches := encodeFieldsPattern.FindSubmatch(match)
+		if len(submatches) < 3 {
+			return match
+		}
+		typeName := string(submatches[1])
+		body := submatches[2]
+
+		type block struct {
+			start, end int
+			key, field string
+		}
+		var blocks []block
+		byKey := make(map[string][]int)
+		for _, loc := range fieldBlockPattern.FindAllIndex(body, -1) {
+			text := body[loc[0]:loc[1]]
+			key := fieldStartPattern.FindSubmatch(text)
+			field := fieldRefPattern.FindSubmatch(text)
+			if key == nil || field == nil {
+				continue
+			}
+			byKey[string(key[1])] = append(byKey[string(key[1])], len(blocks))
+			blocks = append(blocks, block{loc[0], loc[1], string(key[1]), string(field[1])})
+		}
+
+		decoded := decodedFields(content, typeName)
+		drop := make(map[int]string) // block index -> kept field
+		for key, indexes := range byKey {
+			if len(indexes) < 2 {
+				continue
+			}
+			keep := indexes[0]
+			for _, i := range indexes {
+				if blocks[i].field == decoded[key] {
+					keep = i
+					break
+				}
+			}
+			for _, i := range indexes {
+				if i != keep {
+					drop[i] = blocks[keep].field
+				}
+			}
+		}
+		if len(drop) == 0 {
+			return match
+		}
+
+		var newBody bytes.Buffer
+		last := 0
+		for i, b := range blocks {
+			kept, ok := drop[i]
+			if !ok {
+				continue
+			}
+			count++
+			newBody.Write(body[last:b.start])
+			fmt.Fprintf(&newBody, "\t// Duplicate key %q from s.%s removed by ogen-fixallof; s.%s is encoded instead.\n",
+				b.key, b.field, kept)
+			last = b.end
+		}
+		newBody.Write(body[last:])
+
+		var result bytes.Buffer
+		result.Write(match[:len(match)-len(body)-len("\n}\n")])
+		result.Write(bytes.TrimRight(newBody.Bytes(), "\n"))
+		result.WriteString("\n}\n")
+		return result.Bytes()
+	})
+	return fixed, count
+}
+
+// decodedFields maps each JSON key of typeName's Decode method to the struct
+// field it assigns.
+func decodedFields(content []byte, typeName string) map[string]string {
+	fields := make(map[string]string)
+	decodePattern := regexp.MustCompile(
+		`(?s)func \(s \*` + regexp.QuoteMeta(typeName) + `\) Decode\(d \*jx\.Decoder\) error \{\n(.*?)\n\}\n`)
+	m := decodePattern.FindSubmatch(content)
+	if m == nil {
+		return fields
+	}
+	for _, c := range decodeCasePattern.FindAllSubmatch(m[1], -1) {
+		fields[string(c[1])] = string(c[2])
+	}
+	return fields
+}
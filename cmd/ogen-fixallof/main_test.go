@@ -0,0 +1,143 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const duplicateKeyInput = `package api
+
+// encodeFields encodes fields.
+func (s *Combined) encodeFields(e *jx.Encoder) {
+	{
+		if s.ID.Set {
+			e.FieldStart("id")
+			s.ID.Encode(e)
+		}
+	}
+	{
+		e.FieldStart("name")
+		e.Str(s.Name)
+	}
+	{
+		if s.ExtID.Set {
+			e.FieldStart("id")
+			s.ExtID.Encode(e)
+		}
+	}
+}
+
+// Decode decodes Combined from json.
+func (s *Combined) Decode(d *jx.Decoder) error {
+	if err := d.ObjBytes(func(d *jx.Decoder, k []byte) error {
+		switch string(k) {
+		case "id":
+			if err := func() error {
+				s.ExtID.Reset()
+				if err := s.ExtID.Decode(d); err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"id\"")
+			}
+		case "name":
+			requiredBitSet[0] |= 1 << 1
+			if err := func() error {
+				v, err := d.Str()
+				s.Name = string(v)
+				return err
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"name\"")
+			}
+		default:
+			return d.Skip()
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "decode Combined")
+	}
+	return nil
+}
+`
+
+func TestFixDuplicateEncodeKeys_KeepsDecodedField(t *testing.T) {
+	fixed, count := FixDuplicateEncodeKeys([]byte(duplicateKeyInput))
+
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+
+	out := string(fixed)
+	if strings.Contains(out, "s.ID.Encode(e)") {
+		t.Error("s.ID block should have been removed")
+	}
+	if !strings.Contains(out, "s.ExtID.Encode(e)") {
+		t.Error("s.ExtID block (the one Decode assigns) should be kept")
+	}
+	if !strings.Contains(out, `// Duplicate key "id" from s.ID removed by ogen-fixallof; s.ExtID is encoded instead.`) {
+		t.Errorf("missing explanatory comment:\n%s", out)
+	}
+	if strings.Count(out, `e.FieldStart("id")`) != 1 {
+		t.Errorf("id written %d times, want 1", strings.Count(out, `e.FieldStart("id")`))
+	}
+}
+
+func TestFixDuplicateEncodeKeys_FirstWinsWithoutDecode(t *testing.T) {
+	input := `func (s *Combined) encodeFields(e *jx.Encoder) {
+	{
+		e.FieldStart("id")
+		e.Str(s.ID)
+	}
+	{
+		e.FieldStart("id")
+		e.Str(s.ExtID)
+	}
+}
+`
+
+	fixed, count := FixDuplicateEncodeKeys([]byte(input))
+
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+	out := string(fixed)
+	if !strings.Contains(out, "e.Str(s.ID)") || strings.Contains(out, "e.Str(s.ExtID)") {
+		t.Errorf("first field should win:\n%s", out)
+	}
+}
+
+func TestFixDuplicateEncodeKeys_NoDuplicates(t *testing.T) {
+	input := `func (s *User) encodeFields(e *jx.Encoder) {
+	{
+		e.FieldStart("id")
+		e.Str(s.ID)
+	}
+	{
+		e.FieldStart("name")
+		e.Str(s.Name)
+	}
+}
+`
+
+	fixed, count := FixDuplicateEncodeKeys([]byte(input))
+
+	if count != 0 {
+		t.Errorf("count = %d, want 0", count)
+	}
+	if string(fixed) != input {
+		t.Error("content without duplicates was modified")
+	}
+}
+
+func TestFixDuplicateEncodeKeys_Idempotent(t *testing.T) {
+	once, _ := FixDuplicateEncodeKeys([]byte(duplicateKeyInput))
+	twice, count := FixDuplicateEncodeKeys(once)
+
+	if count != 0 {
+		t.Errorf("second run count = %d, want 0", count)
+	}
+	if string(once) != string(twice) {
+		t.Error("second run modified already fixed content")
+	}
+}
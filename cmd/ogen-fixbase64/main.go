@@ -0,0 +1,131 @@
+// Command ogen-fixbase64 makes ogen-generated JSON decoding of `format: byte`
+// strings accept any common base64 variant.
+//
+// ogen decodes byte-format strings with jx's Base64, which only accepts the
+// padded standard alphabet (RFC 4648 section 4). Services that send URL-safe
+// base64 or drop the padding produce otherwise-valid payloads that fail to
+// decode.
+//
+// Usage:
+//
+//	ogen-fixbase64 <oas_json_gen.go>
+//
+// The tool modifies the file in place, replacing every d.Base64() call with a
+// lenient decoder that accepts the standard and URL-safe alphabets, with or
+// without padding. Encoding is unchanged and still emits padded standard
+// base64.
+//
+// This tool is designed to be run as a post-processing step after ogen generation:
+//
+//	ogen --package api --target internal/api --clean openapi.json
+//	ogen-fixbase64 internal/api/oas_json_gen.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"regexp"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixbase64: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-fixbase64 <oas_json_gen.go>")
+	}
+
+	filename := args[0]
+
+	content, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	fixed, count := FixBase64Decode(content)
+
+	if count == 0 {
+		fmt.Printf("No base64 decoders needed fixing in %s\n", filename)
+		return nil
+	}
+
+	fixed, err = format.Source(fixed)
+	if err != nil {
+		return fmt.Errorf("format: %w", err)
+	}
+
+	if err := os.WriteFile(filename, fixed, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	fmt.Printf("Fixed %d base64 decoders in %s\n", count, filename)
+	return nil
+}
+
+// helperName is the function that replaces d.Base64() calls.
+const helperName = "decodeBase64Lenient"
+
+// helperFunc is appended to the file the first time it is fixed.
+const helperFunc = `
+// decodeBase64Lenient decodes a base64 string in either the standard or the
+// URL-safe alphabet, with or without padding.
+//
+// Added by ogen-fixbase64.
+func decodeBase64Lenient(d *jx.Decoder) ([]byte, error) {
+	if d.Next() == jx.Null {
+		if err := d.Null(); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	s, err := d.Str()
+	if err != nil {
+		return nil, err
+	}
+	s = strings.TrimRight(s, "=")
+	enc := base64.RawStdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	return enc.DecodeString(s)
+}
+`
+
+var base64CallPattern = regexp.MustCompile(`\bd\.Base64\(\)`)
+
+// FixBase64Decode replaces d.Base64() calls with decodeBase64Lenient(d) and
+// appends the helper and its imports if they are missing.
+//
+// The pattern it looks for:
+//
+//	v, err := d.Base64()
+//
+// And transforms it to:
+//
+//	v, err := decodeBase64Lenient(d)
+func FixBase64Decode(content []byte) ([]byte, int) {
+	count := 0
+	fixed := base64CallPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		count++
+		return []byte(helperName + "(d)")
+	})
+
+	if count == 0 {
+		return content, 0
+	}
+
+	if !bytes.Contains(fixed, []byte("func "+helperName+"(")) {
+		fixed = append(fixed, helperFunc...)
+	}
+	fixed = gopkg.AddImports(fixed, "encoding/base64", "strings")
+
+	return fixed, count
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const base64Input = `package api
+
+import (
+	"math/bits"
+
+	"github.com/go-faster/jx"
+)
+
+// Decode decodes User from json.
+func (s *User) Decode(d *jx.Decoder) error {
+	if err := d.ObjBytes(func(d *jx.Decoder, k []byte) error {
+		switch string(k) {
+		case "avatar":
+			if err := func() error {
+				v, err := d.Base64()
+				s.Avatar = []byte(v)
+				if err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"avatar\"")
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Decode decodes OptBase64 from json.
+func (o *OptBase64) Decode(d *jx.Decoder) error {
+	o.Set = true
+	v, err := d.Base64()
+	if err != nil {
+		return err
+	}
+	o.Value = v
+	return nil
+}
+`
+
+func TestFixBase64Decode(t *testing.T) {
+	fixed, count := FixBase64Decode([]byte(base64Input))
+
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	out := string(fixed)
+	if strings.Contains(out, "d.Base64()") {
+		t.Error("d.Base64() call left in output")
+	}
+	wants := []string{
+		"v, err := decodeBase64Lenient(d)",
+		"func decodeBase64Lenient(d *jx.Decoder) ([]byte, error) {",
+		`"encoding/base64"`,
+		`"strings"`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+}
+
+func TestFixBase64DecodeIdempotent(t *testing.T) {
+	fixed, _ := FixBase64Decode([]byte(base64Input))
+	again, count := FixBase64Decode(fixed)
+
+	if count != 0 {
+		t.Errorf("second run count = %d, want 0", count)
+	}
+	if string(again) != string(fixed) {
+		t.Error("second run changed the file")
+	}
+}
+
+func TestFixBase64DecodeNoMatch(t *testing.T) {
+	input := "package api\n\nimport (\n\t\"github.com/go-faster/jx\"\n)\n"
+	fixed, count := FixBase64Decode([]byte(input))
+
+	if count != 0 {
+		t.Errorf("count = %d, want 0", count)
+	}
+	if string(fixed) != input {
+		t.Error("file without base64 fields should be unchanged")
+	}
+}
@@ -0,0 +1,323 @@
+// Command ogen-fixbodylimit limits the size of request bodies read by
+// ogen-generated servers.
+//
+// The generated request decoders read the whole body with io.ReadAll before
+// decoding it, so one client posting a multi-gigabyte JSON body can exhaust
+// the server's memory. This tool wraps the body of every operation in
+// http.MaxBytesReader and answers bodies over the limit with 413 Request
+// Entity Too Large through the server's ErrorHandler.
+//
+// The limits are variables in the generated package:
+//
+//	api.DefaultMaxRequestBodySize = 4 << 20
+//	api.MaxRequestBodySizes[api.UploadFileOperation] = 512 << 20
+//
+// Usage:
+//
+//	ogen-fixbodylimit [-default 10MiB] [-limit Operation=size]... <generated-dir>
+//
+// The limits and helpers are written to oas_bodylimit_gen.go.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the limits are written to.
+const outputFile = "oas_bodylimit_gen.go"
+
+const handlersFile = "oas_handlers_gen.go"
+
+// defaultLimit is the limit used when -default is not given.
+const defaultLimit = 10 << 20
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixbodylimit: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixbodylimit", flag.ContinueOnError)
+	def := fs.String("default", FormatSize(defaultLimit), "request body limit of operations without -limit; 0 disables it")
+	limits := make(map[string]int64)
+	fs.Func("limit", "request body limit of one operation, as Operation=size (repeatable)", func(s string) error {
+		op, size, ok := strings.Cut(s, "=")
+		if !ok || op == "" {
+			return fmt.Errorf("want Operation=size, got %q", s)
+		}
+		n, err := ParseSize(size)
+		if err != nil {
+			return err
+		}
+		limits[op] = n
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: ogen-fixbodylimit [-default 10MiB] [-limit Operation=size]... <generated-dir>")
+	}
+	defaultSize, err := ParseSize(*def)
+	if err != nil {
+		return fmt.Errorf("-default: %w", err)
+	}
+
+	dir := fs.Arg(0)
+	handlers, err := os.ReadFile(filepath.Join(dir, handlersFile)) // #nosec G703 -- CLI tool, filename from trusted args
+	if os.IsNotExist(err) {
+		fmt.Printf("No server found in %s\n", dir)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	handlers, ops, count := LimitRequestBodies(handlers)
+	if len(ops) == 0 {
+		fmt.Printf("No operations with request bodies found in %s\n", dir)
+		return nil
+	}
+	for op := range limits {
+		if !contains(ops, op) {
+			return fmt.Errorf("-limit: %s is not an operation with a request body", op)
+		}
+	}
+
+	// The output file stays in: handlers limited by an earlier run call its
+	// helpers.
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"DefaultMaxRequestBodySize", "MaxRequestBodySizes", "RequestBodyTooLargeError", "limitRequestBody", "decodeRequestError"} {
+		obj := pkg.Types.Scope().Lookup(name)
+		if obj != nil && filepath.Base(pkg.Fset.Position(obj.Pos()).Filename) != outputFile {
+			return fmt.Errorf("%s is already declared", name)
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-fixbodylimit", pkg.Types)
+	writeLimits(gen, defaultSize, limits)
+	limitsFile, err := gen.Bytes()
+	if err != nil {
+		return err
+	}
+	outputPath := filepath.Join(pkg.Dir, outputFile)
+	old, _ := os.ReadFile(outputPath) // #nosec G703 -- CLI tool, filename from trusted args
+	if count == 0 && bytes.Equal(old, limitsFile) {
+		fmt.Printf("No request bodies needed limiting in %s\n", dir)
+		return nil
+	}
+
+	if count > 0 {
+		handlers, err = format.Source(handlers)
+		if err != nil {
+			return fmt.Errorf("format %s: %w", handlersFile, err)
+		}
+		// #nosec G703 -- CLI tool, filename from trusted args
+		if err := os.WriteFile(filepath.Join(pkg.Dir, handlersFile), handlers, 0600); err != nil {
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+	// #nosec G703 -- CLI tool, filename from trusted args
+	if err := os.WriteFile(outputPath, limitsFile, 0600); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	if count == 0 {
+		fmt.Printf("Changed the request body limits of %d operations to %s by default in %s\n", len(ops), FormatSize(defaultSize), dir)
+		return nil
+	}
+	fmt.Printf("Limited request bodies of %d operations to %s by default in %s\n", count, FormatSize(defaultSize), dir)
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// decodeRequestPattern matches the call of a request decoder in a
+	// handler and the start of its error handling.
+	decodeRequestPattern = regexp.MustCompile(
+		`\n(\t+)request, rawBody, close, err := s\.decode(\w+)Request\(r\)\n` +
+			`\t+if err != nil \{\n\t+err = &ogenerrors\.DecodeRequestError\{\n\t+OperationContext: +opErrContext,\n\t+Err: +err,\n\t+\}\n`)
+	// limitedPattern matches a request decoder call limited by an earlier
+	// run.
+	limitedPattern = regexp.MustCompile(`\n\t+r\.Body = limitRequestBody\(w, r\.Body, (\w+)Operation\)\n`)
+)
+
+// LimitRequestBodies makes every handler that decodes a request body limit
+// it with limitRequestBody, and report errors through decodeRequestError. It
+// returns the handlers, the names of the limited operations, including
+// those limited by an earlier run, sorted, and the number of handlers it
+// changed.
+func LimitRequestBodies(handlers []byte) ([]byte, []string, int) {
+	count := len(decodeRequestPattern.FindAllIndex(handlers, -1))
+	handlers = decodeRequestPattern.ReplaceAll(handlers, []byte(
+		"\n${1}r.Body = limitRequestBody(w, r.Body, ${2}Operation)\n"+
+			"${1}request, rawBody, close, err := s.decode${2}Request(r)\n"+
+			"${1}if err != nil {\n"+
+			"${1}\terr = decodeRequestError(opErrContext, err)\n"))
+	// Servers whose only ogenerrors use was the request decoding error.
+	if !bytes.Contains(handlers, []byte("ogenerrors.")) {
+		handlers = bytes.Replace(handlers, []byte("\t\"github.com/ogen-go/ogen/ogenerrors\"\n"), nil, 1)
+	}
+
+	var ops []string
+	for _, m := range limitedPattern.FindAllSubmatch(handlers, -1) {
+		ops = append(ops, string(m[1]))
+	}
+	sort.Strings(ops)
+	return handlers, ops, count
+}
+
+var sizeUnits = []struct {
+	suffix string
+	n      int64
+}{
+	{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a size in bytes with an optional unit: 512, 64KiB, 10MB.
+// KB, MB and GB are 1024-based, like KiB, MiB and GiB.
+func ParseSize(s string) (int64, error) {
+	num, mult := s, int64(1)
+	for _, u := range sizeUnits {
+		if n, ok := strings.CutSuffix(s, u.suffix); ok {
+			num, mult = strings.TrimSpace(n), u.n
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)/mult {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// FormatSize formats n in the largest unit that divides it.
+func FormatSize(n int64) string {
+	for _, u := range sizeUnits[:3] {
+		if n != 0 && n%u.n == 0 {
+			return fmt.Sprintf("%d%s", n/u.n, u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// writeLimits writes the limit variables, limitRequestBody,
+// RequestBodyTooLargeError and decodeRequestError.
+func writeLimits(gen *gopkg.Generated, defaultSize int64, limits map[string]int64) {
+	gen.Import("fmt", "fmt")
+	gen.Import("io", "io")
+	gen.Import("net/http", "http")
+	gen.Import("github.com/go-faster/errors", "errors")
+	gen.Import("github.com/ogen-go/ogen/ogenerrors", "ogenerrors")
+
+	gen.Printf(`// DefaultMaxRequestBodySize is the size in bytes of the largest request body
+// the server reads for operations without an entry in MaxRequestBodySizes.
+// Zero or less means no limit.
+var DefaultMaxRequestBodySize int64 = %d // %s
+
+// MaxRequestBodySizes overrides DefaultMaxRequestBodySize per operation.
+var MaxRequestBodySizes = map[OperationName]int64{
+`, defaultSize, FormatSize(defaultSize))
+	var ops []string
+	for op := range limits {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		gen.Printf("\t%sOperation: %d, // %s\n", op, limits[op], FormatSize(limits[op]))
+	}
+	gen.Printf(`}
+
+func limitRequestBody(w http.ResponseWriter, body io.ReadCloser, op OperationName) io.ReadCloser {
+	limit, ok := MaxRequestBodySizes[op]
+	if !ok {
+		limit = DefaultMaxRequestBodySize
+	}
+	if limit <= 0 || body == nil {
+		return body
+	}
+	return http.MaxBytesReader(w, body, limit)
+}
+
+// RequestBodyTooLargeError reports a request body over the limit of its
+// operation. The server responds with 413 Request Entity Too Large.
+type RequestBodyTooLargeError struct {
+	ogenerrors.OperationContext
+	// Limit is the limit in bytes.
+	Limit int64
+	Err   error
+}
+
+// Code returns http code to respond.
+func (e *RequestBodyTooLargeError) Code() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// Unwrap returns child error.
+func (e *RequestBodyTooLargeError) Unwrap() error {
+	return e.Err
+}
+
+// FormatError implements errors.Formatter.
+func (e *RequestBodyTooLargeError) FormatError(p errors.Printer) (next error) {
+	p.Printf("operation %%s: request body larger than %%d bytes", e.OperationName(), e.Limit)
+	return e.Err
+}
+
+// Format implements fmt.Formatter.
+func (e *RequestBodyTooLargeError) Format(s fmt.State, verb rune) {
+	errors.FormatError(e, s, verb)
+}
+
+// Error implements error.
+func (e *RequestBodyTooLargeError) Error() string {
+	return fmt.Sprintf("operation %%s: request body larger than %%d bytes", e.OperationName(), e.Limit)
+}
+
+var _ ogenerrors.Error = (*RequestBodyTooLargeError)(nil)
+
+// decodeRequestError wraps an error decoding the request body of an
+// operation, as a RequestBodyTooLargeError if the body was over the limit.
+func decodeRequestError(op ogenerrors.OperationContext, err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return &RequestBodyTooLargeError{
+			OperationContext: op,
+			Limit:            tooLarge.Limit,
+			Err:              err,
+		}
+	}
+	return &ogenerrors.DecodeRequestError{
+		OperationContext: op,
+		Err:              err,
+	}
+}
+`)
+}
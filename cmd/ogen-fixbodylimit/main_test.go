@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const handlersSource = `package api
+
+import (
+	"net/http"
+
+	"github.com/ogen-go/ogen/ogenerrors"
+)
+
+func (s *Server) handleCreatePaymentRequest(args [0]string, argsEscaped bool, w http.ResponseWriter, r *http.Request) {
+	var rawBody []byte
+	request, rawBody, close, err := s.decodeCreatePaymentRequest(r)
+	if err != nil {
+		err = &ogenerrors.DecodeRequestError{
+			OperationContext: opErrContext,
+			Err:              err,
+		}
+		defer recordError("DecodeRequest", err)
+		s.cfg.ErrorHandler(ctx, w, r, err)
+		return
+	}
+}
+
+func (s *Server) handleListAccountsRequest(args [0]string, argsEscaped bool, w http.ResponseWriter, r *http.Request) {
+}
+`
+
+func TestLimitRequestBodies(t *testing.T) {
+	got, ops, n := LimitRequestBodies([]byte(handlersSource))
+	if !reflect.DeepEqual(ops, []string{"CreatePayment"}) || n != 1 {
+		t.Errorf("LimitRequestBodies() operations = %v, %d changed, want [CreatePayment], 1", ops, n)
+	}
+	want := "\tr.Body = limitRequestBody(w, r.Body, CreatePaymentOperation)\n" +
+		"\trequest, rawBody, close, err := s.decodeCreatePaymentRequest(r)\n" +
+		"\tif err != nil {\n" +
+		"\t\terr = decodeRequestError(opErrContext, err)\n" +
+		"\t\tdefer recordError"
+	if !strings.Contains(string(got), want) {
+		t.Errorf("output missing %q:\n%s", want, got)
+	}
+	if strings.Contains(string(got), "ogenerrors") {
+		t.Errorf("output still imports ogenerrors:\n%s", got)
+	}
+
+	// Running again changes nothing, and still reports the operation.
+	again, ops, n := LimitRequestBodies(got)
+	if string(again) != string(got) || len(ops) != 1 || n != 0 {
+		t.Errorf("second LimitRequestBodies() = %v, %d changed:\n%s", ops, n, again)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{
+		"0":      0,
+		"512":    512,
+		"512B":   512,
+		"64KiB":  64 << 10,
+		"64KB":   64 << 10,
+		"10MiB":  10 << 20,
+		"10 MB":  10 << 20,
+		"1GiB":   1 << 30,
+		"100000": 100000,
+	} {
+		if got, err := ParseSize(s); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "MB", "-1", "1.5MB", "10TB", "9999999999GiB"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q) succeeded", s)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:        "0B",
+		1000:     "1000B",
+		1024:     "1KiB",
+		1536:     "1536B",
+		10 << 20: "10MiB",
+		3 << 30:  "3GiB",
+	} {
+		if got := FormatSize(n); got != want {
+			t.Errorf("FormatSize(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Command ogen-fixbool makes selected boolean fields in ogen-generated code
+// accept loosely typed values.
+//
+// Some APIs serialize booleans inconsistently: "true"/"false" strings, "1"/"0"
+// strings, or the numbers 1 and 0. ogen decodes booleans with d.Bool(), which
+// only accepts JSON true and false. Accepting the other forms everywhere would
+// hide real bugs, so this tool only touches the fields listed in its config.
+//
+// Usage:
+//
+//	ogen-fixbool -config bools.json <oas_json_gen.go>
+//
+// The config file maps generated type names to the JSON names of the fields to
+// relax. "*" selects every boolean field of the type:
+//
+//	{
+//	  "Metric": ["enabled", "visible"],
+//	  "LegacyFlags": ["*"]
+//	}
+//
+// Field types are read from oas_schemas_gen.go in the same directory. bool,
+// []bool, OptBool, OptNilBool and NilBool fields are supported.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/plexusone/ogen-tools/internal/fieldfix"
+)
+
+// Config maps type names to the JSON names of the fields to relax.
+type Config map[string][]string
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixbool: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixbool", flag.ContinueOnError)
+	configFile := fs.String("config", "", "JSON file mapping type names to field names")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *configFile == "" {
+		return fmt.Errorf("usage: ogen-fixbool -config bools.json <oas_json_gen.go>")
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	filename := fs.Arg(0)
+	content, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	fields, err := fieldfix.LoadFieldTypes(filepath.Join(filepath.Dir(filename), "oas_schemas_gen.go"))
+	if err != nil {
+		return err
+	}
+
+	fixed, count, err := FixBoolDecode(content, fields, cfg)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Printf("No boolean fields needed fixing in %s\n", filename)
+		return nil
+	}
+
+	if err := os.WriteFile(filename, fixed, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	fmt.Printf("Fixed %d boolean fields in %s\n", count, filename)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// boolRewriter relaxes the decoding of boolean fields.
+var boolRewriter = fieldfix.Rewriter{
+	Kind: "boolean",
+	Types: map[string]bool{
+		"bool":       true,
+		"[]bool":     true,
+		"OptBool":    true,
+		"OptNilBool": true,
+		"NilBool":    true,
+	},
+	Rewrite: func(block []byte, goField, _ string) ([]byte, bool) {
+		return relaxBlock(block, goField)
+	},
+}
+
+// FixBoolDecode rewrites the decoding of the configured boolean fields to use
+// decodeBoolLenient and appends the helpers if they are missing.
+//
+// Required fields and slice elements:
+//
+//	v, err := d.Bool()
+//
+// become:
+//
+//	v, err := decodeBoolLenient(d)
+//
+// Optional fields:
+//
+//	if err := s.Visible.Decode(d); err != nil {
+//
+// become:
+//
+//	if err := decodeOptBoolLenient(d, &s.Visible); err != nil {
+//
+// It returns an error if a configured type or field does not exist or is
+// not a boolean.
+func FixBoolDecode(content []byte, fields fieldfix.FieldTypes, cfg Config) ([]byte, int, error) {
+	fixed, count, err := boolRewriter.Apply(content, fields, fieldfix.Config(cfg))
+	if err != nil {
+		return nil, 0, err
+	}
+	if count > 0 && !bytes.Contains(fixed, []byte("func decodeBoolLenient(")) {
+		fixed = append(fixed, helperFuncs...)
+	}
+	return fixed, count, nil
+}
+
+// relaxBlock rewrites one field case to use the lenient decoders.
+func relaxBlock(block []byte, goField string) ([]byte, bool) {
+	orig := block
+	block = bytes.ReplaceAll(block, []byte("d.Bool()"), []byte("decodeBoolLenient(d)"))
+	block = bytes.ReplaceAll(block,
+		[]byte("s."+goField+".Decode(d)"),
+		[]byte("decodeOptBoolLenient(d, &s."+goField+")"))
+	return block, !bytes.Equal(orig, block)
+}
+
+// helperFuncs is appended to the file the first time it is fixed. It only
+// uses packages that oas_json_gen.go already imports.
+const helperFuncs = `
+// decodeBoolLenient decodes a boolean that may also be sent as "true",
+// "false", "1" or "0", or as the number 1 or 0.
+//
+// Added by ogen-fixbool.
+func decodeBoolLenient(d *jx.Decoder) (bool, error) {
+	switch d.Next() {
+	case jx.String:
+		v, err := d.Str()
+		if err != nil {
+			return false, err
+		}
+		switch v {
+		case "true", "1":
+			return true, nil
+		case "false", "0":
+			return false, nil
+		}
+		return false, errors.Errorf("invalid boolean %q", v)
+	case jx.Number:
+		v, err := d.Num()
+		if err != nil {
+			return false, err
+		}
+		switch v.String() {
+		case "1":
+			return true, nil
+		case "0":
+			return false, nil
+		}
+		return false, errors.Errorf("invalid boolean %s", v)
+	default:
+		return d.Bool()
+	}
+}
+
+// decodeOptBoolLenient decodes an optional boolean with decodeBoolLenient.
+// null leaves the value unset, or marks it null if the type supports it.
+//
+// Added by ogen-fixbool.
+func decodeOptBoolLenient(d *jx.Decoder, o interface{ SetTo(bool) }) error {
+	if d.Next() == jx.Null {
+		if err := d.Null(); err != nil {
+			return err
+		}
+		if n, ok := o.(interface{ SetToNull() }); ok {
+			n.SetToNull()
+		}
+		return nil
+	}
+	v, err := decodeBoolLenient(d)
+	if err != nil {
+		return err
+	}
+	o.SetTo(v)
+	return nil
+}
+`
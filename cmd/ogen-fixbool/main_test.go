@@ -0,0 +1,171 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/fieldfix"
+)
+
+const boolDecodeInput = `package api
+
+// Decode decodes Metric from json.
+func (s *Metric) Decode(d *jx.Decoder) error {
+	if s == nil {
+		return errors.New("invalid: unable to decode Metric to nil")
+	}
+
+	if err := d.ObjBytes(func(d *jx.Decoder, k []byte) error {
+		switch string(k) {
+		case "enabled":
+			if err := func() error {
+				v, err := d.Bool()
+				s.Enabled = bool(v)
+				if err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"enabled\"")
+			}
+		case "visible":
+			if err := func() error {
+				s.Visible.Reset()
+				if err := s.Visible.Decode(d); err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"visible\"")
+			}
+		case "strict":
+			if err := func() error {
+				v, err := d.Bool()
+				s.Strict = bool(v)
+				if err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"strict\"")
+			}
+		case "name":
+			if err := func() error {
+				v, err := d.Str()
+				s.Name = string(v)
+				if err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"name\"")
+			}
+		default:
+			return d.Skip()
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "decode Metric")
+	}
+
+	return nil
+}
+
+// Decode decodes bool from json.
+func (o *OptBool) Decode(d *jx.Decoder) error {
+	if o == nil {
+		return errors.New("invalid: unable to decode OptBool to nil")
+	}
+	o.Set = true
+	v, err := d.Bool()
+	if err != nil {
+		return err
+	}
+	o.Value = bool(v)
+	return nil
+}
+`
+
+var boolFieldTypes = fieldfix.FieldTypes{
+	"Metric": {
+		"Enabled": "bool",
+		"Visible": "OptBool",
+		"Strict":  "bool",
+		"Name":    "string",
+	},
+}
+
+func TestFixBoolDecode(t *testing.T) {
+	cfg := Config{"Metric": {"enabled", "visible"}}
+	fixed, count, err := FixBoolDecode([]byte(boolDecodeInput), boolFieldTypes, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	out := string(fixed)
+	wants := []string{
+		"v, err := decodeBoolLenient(d)\n\t\t\t\ts.Enabled = bool(v)",
+		"if err := decodeOptBoolLenient(d, &s.Visible); err != nil {",
+		"v, err := d.Bool()\n\t\t\t\ts.Strict = bool(v)",
+		"func decodeBoolLenient(d *jx.Decoder) (bool, error) {",
+		"func decodeOptBoolLenient(d *jx.Decoder, o interface{ SetTo(bool) }) error {",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+
+	// OptBool.Decode itself is shared by every optional boolean and must
+	// stay strict.
+	if !strings.Contains(out, "o.Set = true\n\tv, err := d.Bool()") {
+		t.Error("OptBool.Decode should not be changed")
+	}
+
+	again, count, err := FixBoolDecode(fixed, boolFieldTypes, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 || string(again) != out {
+		t.Errorf("second run changed %d fields", count)
+	}
+}
+
+func TestFixBoolDecodeAllFields(t *testing.T) {
+	fixed, count, err := FixBoolDecode([]byte(boolDecodeInput), boolFieldTypes, Config{"Metric": {"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+	if !strings.Contains(string(fixed), "v, err := d.Str()") {
+		t.Error("string field should be skipped")
+	}
+}
+
+func TestFixBoolDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"unknown type", Config{"Other": {"x"}}, "type Other has no generated Decode method"},
+		{"unknown field", Config{"Metric": {"missing"}}, `Metric has no field "missing"`},
+		{"not a boolean", Config{"Metric": {"name"}}, "Metric.name is string, not a boolean"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := FixBoolDecode([]byte(boolDecodeInput), boolFieldTypes, tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
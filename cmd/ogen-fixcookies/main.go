@@ -0,0 +1,407 @@
+// Command ogen-fixcookies fixes the Set-Cookie response headers of
+// ogen-generated code and adds typed cookie accessors to them.
+//
+// ogen already encodes and decodes in: cookie parameters, but a Set-Cookie
+// response header is a plain string header:
+//
+//   - The client reads only the first Set-Cookie line of a response and
+//     splits it on commas, so a second cookie is lost and an Expires date
+//     is cut in two.
+//   - The server writes the strings the handler returns as they are, with
+//     no way to enforce the Secure or HttpOnly attributes.
+//
+// This tool makes the client read every Set-Cookie line, makes the server
+// set the Secure, HttpOnly and SameSite attributes on every cookie it
+// writes, and gives the response types AddCookie and Cookies methods:
+//
+//	res := &api.LoginNoContent{}
+//	res.AddCookie(&http.Cookie{Name: "session", Value: token})
+//
+// Usage:
+//
+//	ogen-fixcookies [-secure=true] [-httponly=true] [-samesite lax] <generated-dir>
+//
+// The helpers are written to oas_cookies_gen.go.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/format"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the helpers are written to.
+const outputFile = "oas_cookies_gen.go"
+
+const (
+	decodersFile = "oas_response_decoders_gen.go"
+	encodersFile = "oas_response_encoders_gen.go"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixcookies: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixcookies", flag.ContinueOnError)
+	secure := fs.Bool("secure", true, "set the Secure attribute on server cookies")
+	httpOnly := fs.Bool("httponly", true, "set the HttpOnly attribute on server cookies")
+	sameSite := fs.String("samesite", "lax", "SameSite attribute of server cookies that have none: lax, strict, none, or empty to leave it unset")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: ogen-fixcookies [-secure=true] [-httponly=true] [-samesite lax] <generated-dir>")
+	}
+
+	attrs := Attributes{Secure: *secure, HttpOnly: *httpOnly}
+	switch *sameSite {
+	case "":
+	case "lax", "strict", "none":
+		attrs.SameSite = strings.ToUpper((*sameSite)[:1]) + (*sameSite)[1:]
+	default:
+		return fmt.Errorf("invalid -samesite %q: want lax, strict, none or empty", *sameSite)
+	}
+	if attrs.SameSite == "None" && !attrs.Secure {
+		return fmt.Errorf("-samesite none requires -secure")
+	}
+
+	dir := fs.Arg(0)
+	files := make(map[string][]byte)
+	for _, name := range []string{decodersFile, encodersFile} {
+		content, err := os.ReadFile(filepath.Join(dir, name)) // #nosec G703 -- CLI tool, filename from trusted args
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		files[name] = content
+	}
+
+	// The output file stays in: encoders fixed by an earlier run call its
+	// helpers.
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+	headers := FindHeaderTypes(pkg)
+	if len(headers) == 0 {
+		fmt.Printf("No Set-Cookie headers found in %s\n", dir)
+		return nil
+	}
+
+	var decoded, encoded int
+	if content, ok := files[decodersFile]; ok {
+		files[decodersFile], decoded = ReadAllCookies(content)
+	}
+	_, server := files[encodersFile]
+	if server {
+		files[encodersFile], encoded = SecureCookies(files[encodersFile])
+	}
+
+	gen, err := Generate(pkg, headers, attrs, server)
+	if err != nil {
+		return err
+	}
+
+	for name, content := range files {
+		content, err := format.Source(removeUnusedImport(content, "conv", "github.com/ogen-go/ogen/conv"))
+		if err != nil {
+			return fmt.Errorf("format %s: %w", name, err)
+		}
+		// #nosec G703 -- CLI tool, filename from trusted args
+		if err := os.WriteFile(filepath.Join(pkg.Dir, name), content, 0600); err != nil {
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+	if err := gen.WriteFile(filepath.Join(pkg.Dir, outputFile)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Fixed %d Set-Cookie decoders and %d encoders, added cookie methods to %d types in %s\n",
+		decoded, encoded, len(headers), dir)
+	return nil
+}
+
+// Attributes are the cookie attributes the server enforces.
+type Attributes struct {
+	Secure   bool
+	HttpOnly bool
+	// SameSite is Lax, Strict or None, set on cookies that have none, or
+	// empty.
+	SameSite string
+}
+
+var (
+	parsePattern  = regexp.MustCompile(`(?mi)^(\t+)// Parse "set-cookie" header\.\n`)
+	encodePattern = regexp.MustCompile(`(?mi)^(\t+)// Encode "set-cookie" header\.\n`)
+	arrayPattern  = regexp.MustCompile(
+		`(?s)^(\t+)return d\.DecodeArray\(func\(d uri\.Decoder\) error \{\n\t+var \w+ string\n.*?\n\t+(\S+) = append\(\S+, \w+\)\n`)
+)
+
+// blockEnd returns the end of the block that opens on the line after start
+// and closes at indent.
+func blockEnd(content []byte, start int, indent string) int {
+	end := strings.Index(string(content[start:]), "\n"+indent+"}\n")
+	if end < 0 {
+		return -1
+	}
+	return start + end + len(indent) + 3
+}
+
+// ReadAllCookies makes the client decode array Set-Cookie headers from every
+// header line, unsplit, instead of splitting the first one on commas. It
+// returns the number of headers changed.
+func ReadAllCookies(decoders []byte) ([]byte, int) {
+	var out []byte
+	count, last := 0, 0
+	for _, m := range parsePattern.FindAllSubmatchIndex(decoders, -1) {
+		indent := string(decoders[m[2]:m[3]])
+		end := blockEnd(decoders, m[1], indent)
+		if end < 0 {
+			continue
+		}
+		i := strings.Index(string(decoders[m[1]:end]), "return d.DecodeArray(")
+		if i < 0 {
+			continue
+		}
+		start := lineStart(decoders, m[1]+i)
+		a := arrayPattern.FindSubmatchIndex(decoders[start:end])
+		if a == nil {
+			continue
+		}
+		inner := string(decoders[start+a[2] : start+a[3]])
+		// The array closure closes at the indent of its return.
+		closeAt := strings.Index(string(decoders[start:end]), "\n"+inner+"})\n")
+		if closeAt < 0 {
+			continue
+		}
+		field := string(decoders[start+a[4] : start+a[5]])
+
+		out = append(out, decoders[last:start]...)
+		out = append(out, fmt.Sprintf("%s%s = append(%s, resp.Header.Values(cfg.Name)...)\n%sreturn nil\n",
+			inner, field, field, inner)...)
+		last = start + closeAt + len(inner) + 4
+		count++
+	}
+	return append(out, decoders[last:]...), count
+}
+
+// SecureCookies makes the server set the cookie attributes on the Set-Cookie
+// headers after encoding them. It returns the number of headers changed.
+func SecureCookies(encoders []byte) ([]byte, int) {
+	var out []byte
+	count, last := 0, 0
+	for _, m := range encodePattern.FindAllSubmatchIndex(encoders, -1) {
+		indent := string(encoders[m[2]:m[3]])
+		end := blockEnd(encoders, m[1], indent)
+		if end < 0 {
+			continue
+		}
+		call := indent + "secureSetCookies(w.Header())\n"
+		if strings.HasPrefix(string(encoders[end:]), call) {
+			continue
+		}
+		out = append(out, encoders[last:end]...)
+		out = append(out, call...)
+		last = end
+		count++
+	}
+	return append(out, encoders[last:]...), count
+}
+
+func lineStart(content []byte, i int) int {
+	for i > 0 && content[i-1] != '\n' {
+		i--
+	}
+	return i
+}
+
+// removeUnusedImport drops an import once the file no longer refers to it.
+func removeUnusedImport(content []byte, name, path string) []byte {
+	if regexp.MustCompile(`\b` + name + `\.`).Match(content) {
+		return content
+	}
+	return []byte(strings.Replace(string(content), "\t\""+path+"\"\n", "", 1))
+}
+
+// headerKind is the Go type of a SetCookie field.
+type headerKind int
+
+const (
+	stringHeader    headerKind = iota // string
+	optStringHeader                   // OptString
+	sliceHeader                       // []string
+)
+
+// HeaderType is a response type with a Set-Cookie header.
+type HeaderType struct {
+	Name string
+	kind headerKind
+}
+
+// FindHeaderTypes returns the response types holding a Set-Cookie header,
+// sorted by name. ogen names the header field SetCookie and, unlike schema
+// fields, leaves it untagged.
+func FindHeaderTypes(pkg *gopkg.Package) []HeaderType {
+	scope := pkg.Types.Scope()
+	var headers []HeaderType
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		st, ok := obj.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		for i := range st.NumFields() {
+			f := st.Field(i)
+			if f.Name() != "SetCookie" || st.Tag(i) != "" {
+				continue
+			}
+			if kind, ok := headerKindOf(f.Type()); ok {
+				headers = append(headers, HeaderType{Name: name, kind: kind})
+			}
+		}
+	}
+	return headers
+}
+
+func headerKindOf(t types.Type) (headerKind, bool) {
+	if s, ok := t.(*types.Slice); ok {
+		return sliceHeader, isString(s.Elem())
+	}
+	if w, v := gopkg.Unwrap(t); w == gopkg.Opt {
+		return optStringHeader, isString(v)
+	}
+	return stringHeader, isString(t)
+}
+
+func isString(t types.Type) bool {
+	b, ok := t.(*types.Basic)
+	return ok && b.Kind() == types.String
+}
+
+// Generate generates the cookie methods of the header types, the parse
+// helper they share and, for servers, secureSetCookies.
+func Generate(pkg *gopkg.Package, headers []HeaderType, attrs Attributes, server bool) (*gopkg.Generated, error) {
+	funcs := []string{"parseSetCookies"}
+	if server {
+		funcs = append(funcs, "secureSetCookies")
+	}
+	for _, name := range funcs {
+		if obj := pkg.Types.Scope().Lookup(name); obj != nil && declaredElsewhere(pkg, obj) {
+			return nil, fmt.Errorf("%s is already declared", name)
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-fixcookies", pkg.Types)
+	gen.Import("net/http", "http")
+	for _, h := range headers {
+		if err := checkMethods(pkg, h.Name, "AddCookie", "Cookies"); err != nil {
+			return nil, err
+		}
+		switch h.kind {
+		case sliceHeader:
+			gen.Printf("// AddCookie adds c as a Set-Cookie header.\n")
+			gen.Printf("func (s *%s) AddCookie(c *http.Cookie) {\n\ts.SetCookie = append(s.SetCookie, c.String())\n}\n\n", h.Name)
+			gen.Printf("// Cookies parses the Set-Cookie headers, skipping invalid ones.\n")
+			gen.Printf("func (s *%s) Cookies() []*http.Cookie {\n\treturn parseSetCookies(s.SetCookie...)\n}\n\n", h.Name)
+		case optStringHeader:
+			gen.Printf("// AddCookie sets c as the Set-Cookie header, which holds a single cookie.\n")
+			gen.Printf("func (s *%s) AddCookie(c *http.Cookie) {\n\ts.SetCookie.SetTo(c.String())\n}\n\n", h.Name)
+			gen.Printf("// Cookies parses the Set-Cookie header, if it is set and valid.\n")
+			gen.Printf("func (s *%s) Cookies() []*http.Cookie {\n", h.Name)
+			gen.Printf("\tif v, ok := s.SetCookie.Get(); ok {\n\t\treturn parseSetCookies(v)\n\t}\n\treturn nil\n}\n\n")
+		default:
+			gen.Printf("// AddCookie sets c as the Set-Cookie header, which holds a single cookie.\n")
+			gen.Printf("func (s *%s) AddCookie(c *http.Cookie) {\n\ts.SetCookie = c.String()\n}\n\n", h.Name)
+			gen.Printf("// Cookies parses the Set-Cookie header, if it is valid.\n")
+			gen.Printf("func (s *%s) Cookies() []*http.Cookie {\n\treturn parseSetCookies(s.SetCookie)\n}\n\n", h.Name)
+		}
+	}
+
+	gen.Printf(`// parseSetCookies parses Set-Cookie header values, skipping invalid ones.
+func parseSetCookies(values ...string) []*http.Cookie {
+	var cookies []*http.Cookie
+	for _, v := range values {
+		if c, err := http.ParseSetCookie(v); err == nil {
+			cookies = append(cookies, c)
+		}
+	}
+	return cookies
+}
+`)
+	if server {
+		gen.Printf("\n%s", secureSource(attrs))
+	}
+	return gen, nil
+}
+
+// secureSource returns the source of secureSetCookies for attrs.
+func secureSource(attrs Attributes) string {
+	var doc []string
+	var body strings.Builder
+	if attrs.Secure {
+		doc = append(doc, "Secure")
+		body.WriteString("\t\tc.Secure = true\n")
+	}
+	if attrs.HttpOnly {
+		doc = append(doc, "HttpOnly")
+		body.WriteString("\t\tc.HttpOnly = true\n")
+	}
+	if attrs.SameSite != "" {
+		doc = append(doc, "SameSite="+attrs.SameSite+" if unset")
+		fmt.Fprintf(&body, "\t\tif c.SameSite == 0 || c.SameSite == http.SameSiteDefaultMode {\n\t\t\tc.SameSite = http.SameSite%sMode\n\t\t}\n", attrs.SameSite)
+	}
+	what := "none"
+	if len(doc) > 0 {
+		what = strings.Join(doc, ", ")
+	}
+	return fmt.Sprintf(`// secureSetCookies sets the cookie attributes the server enforces on the
+// Set-Cookie headers of h, leaving invalid headers as they are:
+// %s.
+func secureSetCookies(h http.Header) {
+	values := h.Values("Set-Cookie")
+	for i, v := range values {
+		c, err := http.ParseSetCookie(v)
+		if err != nil {
+			continue
+		}
+%s		values[i] = c.String()
+	}
+}
+`, what, body.String())
+}
+
+// checkMethods reports an error if the named type already has one of the
+// methods outside the output file.
+func checkMethods(pkg *gopkg.Package, name string, methods ...string) error {
+	obj := pkg.Types.Scope().Lookup(name)
+	for _, method := range methods {
+		m, _, _ := types.LookupFieldOrMethod(types.NewPointer(obj.Type()), true, pkg.Types, method)
+		if m != nil && declaredElsewhere(pkg, m) {
+			return fmt.Errorf("%s.%s is already declared", name, method)
+		}
+	}
+	return nil
+}
+
+// declaredElsewhere reports whether obj is declared outside the output file,
+// which is rewritten.
+func declaredElsewhere(pkg *gopkg.Package, obj types.Object) bool {
+	return filepath.Base(pkg.Fset.Position(obj.Pos()).Filename) != outputFile
+}
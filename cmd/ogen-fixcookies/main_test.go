@@ -0,0 +1,219 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const schemasSource = `package api
+
+type OptString struct {
+	Value string
+	Set   bool
+}
+
+func (o *OptString) SetTo(v string) {
+	o.Set = true
+	o.Value = v
+}
+
+func (o OptString) Get() (v string, ok bool) {
+	return o.Value, o.Set
+}
+
+type LoginNoContent struct {
+	SetCookie []string
+}
+
+type MeOKHeaders struct {
+	SetCookie OptString
+	Response  Me
+}
+
+// Me is a schema with a setCookie property, not a header.
+type Me struct {
+	SetCookie string ` + "`json:\"setCookie\"`" + `
+}
+`
+
+const decodersSource = `package api
+
+import (
+	"net/http"
+
+	"github.com/go-faster/errors"
+	"github.com/ogen-go/ogen/uri"
+)
+
+func decodeLoginResponse(resp *http.Response) (res *LoginNoContent, _ error) {
+	var wrapper LoginNoContent
+	h := uri.NewHeaderDecoder(resp.Header)
+	// Parse "Set-Cookie" header.
+	{
+		cfg := uri.HeaderParameterDecodingConfig{
+			Name:    "Set-Cookie",
+			Explode: false,
+		}
+		if err := func() error {
+			if err := h.HasParam(cfg); err == nil {
+				if err := h.DecodeParam(cfg, func(d uri.Decoder) error {
+					return d.DecodeArray(func(d uri.Decoder) error {
+						var wrapperDotSetCookieVal string
+						if err := func() error {
+							val, err := d.DecodeValue()
+							if err != nil {
+								return err
+							}
+
+							wrapperDotSetCookieVal = val
+							return nil
+						}(); err != nil {
+							return err
+						}
+						wrapper.SetCookie = append(wrapper.SetCookie, wrapperDotSetCookieVal)
+						return nil
+					})
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}(); err != nil {
+			return res, errors.Wrap(err, "parse Set-Cookie header")
+		}
+	}
+	return &wrapper, nil
+}
+`
+
+const encodersSource = `package api
+
+import "net/http"
+
+func encodeMeResponse(response *MeOKHeaders, w http.ResponseWriter) error {
+	// Encoding response headers.
+	{
+		// Encode "Set-Cookie" header.
+		{
+			if val, ok := response.SetCookie.Get(); ok {
+				w.Header().Set("Set-Cookie", val)
+			}
+		}
+	}
+	w.WriteHeader(200)
+	return nil
+}
+`
+
+func TestReadAllCookies(t *testing.T) {
+	got, n := ReadAllCookies([]byte(decodersSource))
+	if n != 1 {
+		t.Fatalf("ReadAllCookies() changed %d headers, want 1", n)
+	}
+	want := "\t\t\t\tif err := h.DecodeParam(cfg, func(d uri.Decoder) error {\n" +
+		"\t\t\t\t\twrapper.SetCookie = append(wrapper.SetCookie, resp.Header.Values(cfg.Name)...)\n" +
+		"\t\t\t\t\treturn nil\n" +
+		"\t\t\t\t}); err != nil {\n"
+	if !strings.Contains(string(got), want) {
+		t.Errorf("ReadAllCookies() missing %q:\n%s", want, got)
+	}
+
+	// A second run finds nothing to change.
+	if _, n := ReadAllCookies(got); n != 0 {
+		t.Errorf("second ReadAllCookies() changed %d headers, want 0", n)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource)
+	writeFile(t, filepath.Join(dir, encodersFile), encodersSource)
+
+	if err := run([]string{"-samesite", "strict", dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	for file, wants := range map[string][]string{
+		encodersFile: {
+			"\t\t\t\tw.Header().Set(\"Set-Cookie\", val)\n\t\t\t}\n\t\t}\n\t\tsecureSetCookies(w.Header())\n\t}\n",
+		},
+		outputFile: {
+			"func (s *LoginNoContent) AddCookie(c *http.Cookie) {\n\ts.SetCookie = append(s.SetCookie, c.String())\n}",
+			"func (s *LoginNoContent) Cookies() []*http.Cookie {\n\treturn parseSetCookies(s.SetCookie...)\n}",
+			"func (s *MeOKHeaders) AddCookie(c *http.Cookie) {\n\ts.SetCookie.SetTo(c.String())\n}",
+			"\t\tc.Secure = true\n\t\tc.HttpOnly = true\n",
+			"\t\t\tc.SameSite = http.SameSiteStrictMode\n",
+		},
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(string(got), want) {
+				t.Errorf("%s missing %q:\n%s", file, want, got)
+			}
+		}
+	}
+
+	out, err := os.ReadFile(filepath.Join(dir, outputFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Schema fields named SetCookie are not headers.
+	if strings.Contains(string(out), "*Me)") {
+		t.Errorf("methods generated for schema Me:\n%s", out)
+	}
+
+	// A second run leaves the code as it is.
+	encoders, err := os.ReadFile(filepath.Join(dir, encodersFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-samesite", "strict", dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, encodersFile)); string(got) != string(encoders) {
+		t.Errorf("second run changed %s:\n%s", encodersFile, got)
+	}
+
+	// The fixed package must compile.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Errorf("fixed code does not type-check: %v", err)
+	}
+}
+
+func TestRun_AlreadyDeclared(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource+`
+func (s *LoginNoContent) Cookies() []string { return s.SetCookie }
+`)
+	writeFile(t, filepath.Join(dir, encodersFile), encodersSource)
+
+	err := run([]string{dir})
+	if err == nil || err.Error() != "LoginNoContent.Cookies is already declared" {
+		t.Errorf("run() error = %v, want LoginNoContent.Cookies is already declared", err)
+	}
+	// Nothing is written.
+	if got, _ := os.ReadFile(filepath.Join(dir, encodersFile)); string(got) != encodersSource {
+		t.Error("encoders were modified")
+	}
+}
+
+func TestRun_SameSiteNoneNeedsSecure(t *testing.T) {
+	err := run([]string{"-secure=false", "-samesite", "none", t.TempDir()})
+	if err == nil || err.Error() != "-samesite none requires -secure" {
+		t.Errorf("run() error = %v, want -samesite none requires -secure", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,334 @@
+// Command ogen-fixdeepobject flattens nested deepObject query parameters so
+// ogen can generate them.
+//
+// ogen encodes and decodes `style: deepObject` parameters whose properties are
+// all primitives, but rejects nested objects and arrays ("nested objects in
+// form parameters is not implemented yet"), so an API with filters such as
+// filter[price][min]=5 can't be generated at all. This tool rewrites the spec
+// before generation: nested properties become flat properties named
+// "price][min". ogen's encoder writes a property as name[property], so the
+// client sends filter[price][min]=5, and the generated server decodes the same
+// key back into the field, without patching the generated code.
+//
+// Usage:
+//
+//	ogen-fixdeepobject -o openapi.ogen.json openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// Arrays of primitives become strings sent as a comma-separated list
+// (filter[tags]=a,b). Nested maps and arrays of objects have no flat form and
+// are reported as errors. Only deepObject parameters are rewritten, and the
+// rest of the spec is written back unchanged, with its keys in their original
+// order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixdeepobject: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixdeepobject", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-fixdeepobject -o <output.json> <openapi.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	count, err := FlattenDeepObjects(spec)
+	if err != nil {
+		return err
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Flattened %d deepObject parameters in %s\n", count, *outputFile)
+	return nil
+}
+
+// FlattenDeepObjects rewrites the schema of every deepObject query parameter
+// with nested objects or arrays into a flat object, and returns the number of
+// parameters rewritten. Parameters referencing components/parameters are
+// rewritten once, in the component.
+func FlattenDeepObjects(spec *specdoc.Object) (int, error) {
+	f := &flattener{spec: spec}
+
+	var params []*specdoc.Object
+	if paths, ok := spec.Get("paths").(*specdoc.Object); ok {
+		for _, path := range paths.Keys() {
+			item, ok := paths.Get(path).(*specdoc.Object)
+			if !ok {
+				continue
+			}
+			params = appendParams(params, item)
+			for _, method := range item.Keys() {
+				if op, ok := item.Get(method).(*specdoc.Object); ok && specdoc.IsMethod(method) {
+					params = appendParams(params, op)
+				}
+			}
+		}
+	}
+	if components, ok := spec.Get("components").(*specdoc.Object); ok {
+		if defs, ok := components.Get("parameters").(*specdoc.Object); ok {
+			for _, name := range defs.Keys() {
+				if param, ok := defs.Get(name).(*specdoc.Object); ok {
+					params = append(params, param)
+				}
+			}
+		}
+	}
+
+	count := 0
+	for _, param := range params {
+		flattened, err := f.flattenParam(param)
+		if err != nil {
+			return 0, err
+		}
+		if flattened {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// appendParams appends the inline parameters of a path item or operation.
+func appendParams(params []*specdoc.Object, owner *specdoc.Object) []*specdoc.Object {
+	list, _ := owner.Get("parameters").([]any)
+	for _, v := range list {
+		if param, ok := v.(*specdoc.Object); ok && param.Get("$ref") == nil {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+type flattener struct {
+	spec *specdoc.Object
+}
+
+// flattenParam replaces the schema of a nested deepObject parameter with an
+// inline flat schema. The referenced component schema is left alone, since
+// request and response bodies may use it too.
+func (f *flattener) flattenParam(param *specdoc.Object) (bool, error) {
+	if param.Get("in") != "query" || param.Get("style") != "deepObject" {
+		return false, nil
+	}
+
+	name, _ := param.Get("name").(string)
+	schema, err := f.resolve(param.Get("schema"))
+	if err != nil {
+		return false, fmt.Errorf("parameter %s: %w", name, err)
+	}
+	if schema == nil || schema.Get("properties") == nil {
+		return false, nil
+	}
+
+	nested, err := f.isNested(schema)
+	if err != nil {
+		return false, fmt.Errorf("parameter %s: %w", name, err)
+	}
+	if !nested {
+		return false, nil
+	}
+
+	flat := specdoc.NewObject()
+	flat.Set("type", "object")
+	if schema.Has("description") {
+		flat.Set("description", schema.Get("description"))
+	}
+	props := specdoc.NewObject()
+	var required []any
+	if err := f.flatten("", schema, true, props, &required, nil); err != nil {
+		return false, fmt.Errorf("parameter %s: %w", name, err)
+	}
+	flat.Set("properties", props)
+	if len(required) > 0 {
+		flat.Set("required", required)
+	}
+	if schema.Has("additionalProperties") {
+		flat.Set("additionalProperties", schema.Get("additionalProperties"))
+	}
+
+	param.Set("schema", flat)
+	return true, nil
+}
+
+// isNested reports whether any property of schema is an object or an array.
+func (f *flattener) isNested(schema *specdoc.Object) (bool, error) {
+	props, _ := schema.Get("properties").(*specdoc.Object)
+	if props == nil {
+		return false, nil
+	}
+	for _, key := range props.Keys() {
+		prop, err := f.resolve(props.Get(key))
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", key, err)
+		}
+		if k := kind(prop); k == "object" || k == "array" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// flatten adds the properties of schema to props, prefixing nested names with
+// the names of their parents. A property is required only if it and all its
+// parents are. refs holds the component schemas being flattened, to reject
+// recursive schemas.
+func (f *flattener) flatten(prefix string, schema *specdoc.Object, required bool, props *specdoc.Object, req *[]any, refs []string) error {
+	list, _ := schema.Get("properties").(*specdoc.Object)
+	if list == nil {
+		return nil
+	}
+
+	for _, key := range list.Keys() {
+		name := prefix + key
+		value := list.Get(key)
+		isRequired := required && contains(schema.Get("required"), key)
+
+		prop, err := f.resolve(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		switch kind(prop) {
+		case "object":
+			if prop.Get("properties") == nil {
+				return fmt.Errorf("%s: nested maps have no deepObject form", name)
+			}
+			ref, _ := value.(*specdoc.Object).Get("$ref").(string)
+			if ref != "" {
+				for _, seen := range refs {
+					if seen == ref {
+						return fmt.Errorf("%s: recursive schema %s", name, ref)
+					}
+				}
+			}
+			if err := f.flatten(name+"][", prop, isRequired, props, req, append(refs, ref)); err != nil {
+				return err
+			}
+			continue
+
+		case "array":
+			items, err := f.resolve(prop.Get("items"))
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if k := kind(items); k == "object" || k == "array" {
+				return fmt.Errorf("%s: arrays of %ss have no deepObject form", name, k)
+			}
+			value = commaList(prop)
+		}
+
+		if props.Has(name) {
+			return fmt.Errorf("%s: flattened name collides with another property", name)
+		}
+		props.Set(name, value)
+		if isRequired {
+			*req = append(*req, name)
+		}
+	}
+	return nil
+}
+
+// commaList returns the string schema replacing an array of primitives.
+func commaList(array *specdoc.Object) *specdoc.Object {
+	s := specdoc.NewObject()
+	s.Set("type", "string")
+	desc, _ := array.Get("description").(string)
+	if desc != "" && !strings.HasSuffix(desc, ".") {
+		desc += "."
+	}
+	s.Set("description", strings.TrimSpace(desc+" Comma-separated list."))
+	return s
+}
+
+// resolve follows local $refs to component schemas. It returns nil for
+// values that aren't schema objects.
+func (f *flattener) resolve(v any) (*specdoc.Object, error) {
+	for range 32 {
+		schema, ok := v.(*specdoc.Object)
+		if !ok {
+			return nil, nil
+		}
+		ref, ok := schema.Get("$ref").(string)
+		if !ok {
+			return schema, nil
+		}
+		v, ok = f.lookup(ref)
+		if !ok {
+			return nil, fmt.Errorf("unresolved $ref %q", ref)
+		}
+	}
+	return nil, fmt.Errorf("$ref chain too long")
+}
+
+// lookup finds a local JSON pointer such as #/components/schemas/Filter.
+func (f *flattener) lookup(ref string) (any, bool) {
+	ptr, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, false
+	}
+	return specdoc.Lookup(f.spec, ptr)
+}
+
+// kind returns "object", "array" or "" for a schema.
+func kind(schema *specdoc.Object) string {
+	if schema == nil {
+		return ""
+	}
+	switch t := schema.Get("type").(type) {
+	case string:
+		if t == "object" || t == "array" {
+			return t
+		}
+		return ""
+	case []any:
+		// OpenAPI 3.1 type lists, e.g. ["object", "null"].
+		for _, v := range t {
+			if v == "object" || v == "array" {
+				return v.(string)
+			}
+		}
+		return ""
+	}
+	if schema.Get("properties") != nil || schema.Get("additionalProperties") != nil {
+		return "object"
+	}
+	if schema.Get("items") != nil {
+		return "array"
+	}
+	return ""
+}
+
+func contains(list any, s string) bool {
+	values, _ := list.([]any)
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
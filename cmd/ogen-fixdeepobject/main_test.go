@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/items": {
+      "parameters": [{"$ref": "#/components/parameters/Sort"}],
+      "get": {
+        "parameters": [
+          {"name": "filter", "in": "query", "style": "deepObject", "explode": true,
+           "schema": {"$ref": "#/components/schemas/Filter"}},
+          {"name": "meta", "in": "query", "style": "deepObject", "explode": true,
+           "schema": {"type": "object", "additionalProperties": {"type": "string"}}},
+          {"name": "page", "in": "query", "schema": {"type": "integer"}}
+        ]
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "Sort": {"name": "sort", "in": "query", "style": "deepObject", "explode": true,
+        "schema": {"type": "object", "properties": {"by": {"type": "object", "properties": {"field": {"type": "string"}}}}}}
+    },
+    "schemas": {
+      "Filter": {
+        "type": "object",
+        "required": ["price", "status"],
+        "properties": {
+          "status": {"type": "string"},
+          "price": {"$ref": "#/components/schemas/Range"},
+          "created": {"type": "object", "required": ["after"], "properties": {"after": {"type": "string"}}},
+          "tags": {"type": "array", "items": {"type": "string"}}
+        }
+      },
+      "Range": {
+        "type": "object",
+        "required": ["min"],
+        "properties": {"min": {"type": "integer"}, "max": {"type": "integer"}}
+      }
+    }
+  }
+}`
+
+func TestFlattenDeepObjects(t *testing.T) {
+	spec, err := specdoc.Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := FlattenDeepObjects(spec)
+	if err != nil {
+		t.Fatalf("FlattenDeepObjects: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	got, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+
+	for _, want := range []string{
+		// Nested properties keep their place, and required-ness only
+		// propagates through required parents.
+		`"schema":{"type":"object","properties":{"status":{"type":"string"},"price][min":{"type":"integer"},"price][max":{"type":"integer"},"created][after":{"type":"string"},"tags":{"type":"string","description":"Comma-separated list."}},"required":["status","price][min"]}`,
+		// Parameters without nesting are left alone.
+		`"schema":{"type":"object","additionalProperties":{"type":"string"}}`,
+		// Referenced parameters are rewritten in the component.
+		`"Sort":{"name":"sort","in":"query","style":"deepObject","explode":true,"schema":{"type":"object","properties":{"by][field":{"type":"string"}}}}`,
+		// Component schemas are left alone.
+		`"Range":{"type":"object","required":["min"],"properties":{"min":{"type":"integer"},"max":{"type":"integer"}}}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestFlattenDeepObjects_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{
+			name:    "nested map",
+			schema:  `{"type": "object", "properties": {"labels": {"type": "object", "additionalProperties": {"type": "string"}}}}`,
+			wantErr: "parameter filter: labels: nested maps have no deepObject form",
+		},
+		{
+			name:    "array of objects",
+			schema:  `{"type": "object", "properties": {"ranges": {"type": "array", "items": {"$ref": "#/components/schemas/Node"}}}}`,
+			wantErr: "parameter filter: ranges: arrays of objects have no deepObject form",
+		},
+		{
+			name:    "recursive",
+			schema:  `{"type": "object", "properties": {"node": {"$ref": "#/components/schemas/Node"}}}`,
+			wantErr: "parameter filter: node][child: recursive schema #/components/schemas/Node",
+		},
+		{
+			name:    "collision",
+			schema:  `{"type": "object", "properties": {"a][b": {"type": "string"}, "a": {"type": "object", "properties": {"b": {"type": "string"}}}}}`,
+			wantErr: "parameter filter: a][b: flattened name collides with another property",
+		},
+		{
+			name:    "unresolved ref",
+			schema:  `{"type": "object", "properties": {"a": {"$ref": "#/components/schemas/Missing"}}}`,
+			wantErr: `parameter filter: a: unresolved $ref "#/components/schemas/Missing"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := specdoc.Parse([]byte(`{
+  "paths": {"/items": {"get": {"parameters": [
+    {"name": "filter", "in": "query", "style": "deepObject", "explode": true, "schema": ` + tt.schema + `}
+  ]}}},
+  "components": {"schemas": {
+    "Node": {"type": "object", "properties": {"child": {"$ref": "#/components/schemas/Node"}}}
+  }}
+}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = FlattenDeepObjects(spec)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("FlattenDeepObjects() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `"by][field": {`) {
+		t.Errorf("output has no flattened property:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
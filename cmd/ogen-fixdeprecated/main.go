@@ -0,0 +1,326 @@
+// Command ogen-fixdeprecated completes the deprecation notices of
+// ogen-generated code, and optionally warns at run time when a deprecated
+// operation is called.
+//
+// ogen marks deprecated operations, parameters, properties and schemas with
+// "Deprecated:" comments, but not the GetX and SetX accessors of deprecated
+// properties, which is how most code reaches them, and it runs the notice
+// of a deprecated schema into its "Ref:" line. This tool marks the
+// accessors and splits the paragraphs, so staticcheck and gopls flag every
+// use.
+//
+// With -warn, the client methods of deprecated operations also report their
+// first call through DeprecationWarning, which logs with slog by default:
+//
+//	api.DeprecationWarning = func(op api.OperationName) {
+//		metrics.DeprecatedCalls.WithLabelValues(op).Inc()
+//	}
+//
+// Usage:
+//
+//	ogen-fixdeprecated [-warn] <generated-dir>
+//
+// The warning hook is written to oas_deprecated_gen.go.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the warning hook is written to.
+const outputFile = "oas_deprecated_gen.go"
+
+// accessorNotice is the notice ogen puts on deprecated properties.
+const accessorNotice = "Deprecated: schema marks this property as deprecated."
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixdeprecated: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixdeprecated", flag.ContinueOnError)
+	warn := fs.Bool("warn", false, "report the first call of each deprecated operation through DeprecationWarning")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: ogen-fixdeprecated [-warn] <generated-dir>")
+	}
+
+	dir := fs.Arg(0)
+	// The output file stays in: operations fixed by an earlier run call its
+	// hook.
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	d := FindDeprecations(pkg)
+	if len(d.Operations) == 0 && len(d.Accessors) == 0 && len(d.Types) == 0 {
+		fmt.Printf("No deprecations found in %s\n", dir)
+		return nil
+	}
+
+	edits := make(map[string][]gopkg.Edit)
+	for _, a := range d.Accessors {
+		edits[a.file] = append(edits[a.file], gopkg.Insert(a.offset, "\n//\n// "+accessorNotice))
+	}
+	for _, t := range d.Types {
+		edits[t.file] = append(edits[t.file], gopkg.Insert(t.offset, "//\n"))
+	}
+
+	var gen *gopkg.Generated
+	warned := 0
+	if *warn && len(d.Operations) > 0 {
+		if gen, err = generateWarning(pkg); err != nil {
+			return err
+		}
+		for _, op := range d.Operations {
+			if pkg.Types.Scope().Lookup(op.Name+"Operation") == nil {
+				return fmt.Errorf("%s: %sOperation not found", op.Name, op.Name)
+			}
+			if op.warned {
+				continue
+			}
+			edits[op.file] = append(edits[op.file], gopkg.Insert(op.offset, fmt.Sprintf("\n\twarnDeprecated(%sOperation)", op.Name)))
+			warned++
+		}
+	}
+
+	for path, fileEdits := range edits {
+		content, err := os.ReadFile(path) // #nosec G703 -- CLI tool, filename from trusted args
+		if err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		content, err = format.Source(gopkg.ApplyEdits(content, fileEdits))
+		if err != nil {
+			return fmt.Errorf("format %s: %w", filepath.Base(path), err)
+		}
+		if err := os.WriteFile(path, content, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+	if gen != nil {
+		if err := gen.WriteFile(filepath.Join(pkg.Dir, outputFile)); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Marked %d accessors and %d types deprecated, added warnings to %d operations in %s\n",
+		len(d.Accessors), len(d.Types), warned, dir)
+	return nil
+}
+
+// Deprecations are the deprecated declarations of a package that need
+// fixing.
+type Deprecations struct {
+	// Operations are the deprecated client methods.
+	Operations []Operation
+	// Accessors are the GetX and SetX methods of deprecated fields that
+	// lack a notice.
+	Accessors []Accessor
+	// Types are the deprecated types whose notice runs into the next line.
+	Types []Type
+}
+
+// Operation is a deprecated client method.
+type Operation struct {
+	Name string
+	// warned is set when the method already calls warnDeprecated.
+	warned bool
+
+	file   string
+	offset int // just after the opening brace of the body
+}
+
+// Accessor is a method of a deprecated field, e.g. Item.GetOldName.
+type Accessor struct {
+	Type, Method string
+
+	file   string
+	offset int // end of the doc comment
+}
+
+// Type is a deprecated type whose notice needs its own paragraph.
+type Type struct {
+	Name string
+
+	file   string
+	offset int // start of the comment line after the notice
+}
+
+// FindDeprecations finds the deprecated declarations of pkg.
+func FindDeprecations(pkg *gopkg.Package) Deprecations {
+	var d Deprecations
+	fields := make(map[string]map[string]bool) // type -> deprecated fields
+
+	paths := make([]string, 0, len(pkg.Files))
+	for path := range pkg.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		for _, decl := range pkg.Files[path].Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				if i := noticeLine(doc); i >= 0 && i+1 < len(doc.List) && doc.List[i+1].Text != "//" {
+					d.Types = append(d.Types, Type{Name: ts.Name.Name, file: path, offset: offset(pkg, doc.List[i+1].Pos())})
+				}
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				for _, f := range st.Fields.List {
+					if noticeLine(f.Doc) < 0 {
+						continue
+					}
+					for _, name := range f.Names {
+						if fields[ts.Name.Name] == nil {
+							fields[ts.Name.Name] = make(map[string]bool)
+						}
+						fields[ts.Name.Name][name.Name] = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, path := range paths {
+		for _, decl := range pkg.Files[path].Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Recv == nil || fd.Doc == nil || fd.Body == nil {
+				continue
+			}
+			recv := receiverName(fd)
+			name := fd.Name.Name
+			switch {
+			case recv == "Client" && noticeLine(fd.Doc) >= 0:
+				d.Operations = append(d.Operations, Operation{
+					Name:   name,
+					warned: callsWarning(fd.Body),
+					file:   path,
+					offset: offset(pkg, fd.Body.Lbrace) + 1,
+				})
+			case (strings.HasPrefix(name, "Get") || strings.HasPrefix(name, "Set")) &&
+				fields[recv][name[3:]] && noticeLine(fd.Doc) < 0:
+				d.Accessors = append(d.Accessors, Accessor{
+					Type:   recv,
+					Method: name,
+					file:   path,
+					offset: offset(pkg, fd.Doc.End()),
+				})
+			}
+		}
+	}
+	return d
+}
+
+// noticeLine returns the index of the "Deprecated:" line of doc, or -1.
+func noticeLine(doc *ast.CommentGroup) int {
+	if doc == nil {
+		return -1
+	}
+	for i, c := range doc.List {
+		if strings.HasPrefix(c.Text, "// Deprecated:") {
+			return i
+		}
+	}
+	return -1
+}
+
+func receiverName(fd *ast.FuncDecl) string {
+	t := fd.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// callsWarning reports whether body starts with a warnDeprecated call.
+func callsWarning(body *ast.BlockStmt) bool {
+	if len(body.List) == 0 {
+		return false
+	}
+	stmt, ok := body.List[0].(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := stmt.X.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	id, ok := call.Fun.(*ast.Ident)
+	return ok && id.Name == "warnDeprecated"
+}
+
+func offset(pkg *gopkg.Package, pos token.Pos) int {
+	return pkg.Fset.Position(pos).Offset
+}
+
+// generateWarning generates DeprecationWarning and warnDeprecated.
+func generateWarning(pkg *gopkg.Package) (*gopkg.Generated, error) {
+	for _, name := range []string{"DeprecationWarning", "warnDeprecated", "deprecationWarned"} {
+		obj := pkg.Types.Scope().Lookup(name)
+		if obj != nil && declaredElsewhere(pkg, obj) {
+			return nil, fmt.Errorf("%s is already declared", name)
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-fixdeprecated", pkg.Types)
+	gen.Import("log/slog", "slog")
+	gen.Import("sync", "sync")
+	gen.Printf("%s", warningSource)
+	return gen, nil
+}
+
+// declaredElsewhere reports whether obj is declared outside the output file,
+// which is rewritten.
+func declaredElsewhere(pkg *gopkg.Package, obj types.Object) bool {
+	return filepath.Base(pkg.Fset.Position(obj.Pos()).Filename) != outputFile
+}
+
+const warningSource = `// DeprecationWarning is called by the Client the first time each deprecated
+// operation is invoked. It logs a warning with slog by default; set it to
+// route the warnings elsewhere, or to nil to drop them. Set it before the
+// first call.
+var DeprecationWarning = func(op OperationName) {
+	slog.Warn("deprecated API operation called", "operation", op)
+}
+
+// deprecationWarned holds the operations DeprecationWarning was called for.
+var deprecationWarned sync.Map
+
+// warnDeprecated calls DeprecationWarning for op, once.
+func warnDeprecated(op OperationName) {
+	if _, warned := deprecationWarned.LoadOrStore(op, true); !warned && DeprecationWarning != nil {
+		DeprecationWarning(op)
+	}
+}
+`
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const schemasSource = `package api
+
+// Ref: #/components/schemas/Item
+type Item struct {
+	Name string
+	// Use name.
+	//
+	// Deprecated: schema marks this property as deprecated.
+	OldName string
+}
+
+// GetName returns the value of Name.
+func (s *Item) GetName() string {
+	return s.Name
+}
+
+// GetOldName returns the value of OldName.
+func (s *Item) GetOldName() string {
+	return s.OldName
+}
+
+// SetOldName sets the value of OldName.
+func (s *Item) SetOldName(val string) {
+	s.OldName = val
+}
+
+// Deprecated: schema marks this type as deprecated.
+// Ref: #/components/schemas/Legacy
+type Legacy struct {
+	A string
+}
+`
+
+const clientSource = `package api
+
+import "context"
+
+type Client struct{}
+
+// GetNew invokes getNew operation.
+//
+// GET /new
+func (c *Client) GetNew(ctx context.Context) (*Legacy, error) {
+	return nil, nil
+}
+
+// GetOld invokes getOld operation.
+//
+// Deprecated: schema marks this operation as deprecated.
+//
+// GET /old
+func (c *Client) GetOld(ctx context.Context) (*Item, error) {
+	return nil, nil
+}
+`
+
+const operationsSource = `package api
+
+// OperationName is the ogen operation name
+type OperationName = string
+
+const (
+	GetNewOperation OperationName = "GetNew"
+	GetOldOperation OperationName = "GetOld"
+)
+`
+
+func TestFindDeprecations(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource)
+	writeFile(t, filepath.Join(dir, "oas_client_gen.go"), clientSource)
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := FindDeprecations(pkg)
+	if len(d.Operations) != 1 || d.Operations[0].Name != "GetOld" {
+		t.Errorf("Operations = %+v, want GetOld", d.Operations)
+	}
+	var accessors []string
+	for _, a := range d.Accessors {
+		accessors = append(accessors, a.Type+"."+a.Method)
+	}
+	if got := strings.Join(accessors, ","); got != "Item.GetOldName,Item.SetOldName" {
+		t.Errorf("Accessors = %s, want Item.GetOldName,Item.SetOldName", got)
+	}
+	if len(d.Types) != 1 || d.Types[0].Name != "Legacy" {
+		t.Errorf("Types = %+v, want Legacy", d.Types)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource)
+	writeFile(t, filepath.Join(dir, "oas_client_gen.go"), clientSource)
+	writeFile(t, filepath.Join(dir, "oas_operations_gen.go"), operationsSource)
+
+	if err := run([]string{"-warn", dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	for file, wants := range map[string][]string{
+		"oas_schemas_gen.go": {
+			"// GetOldName returns the value of OldName.\n//\n// Deprecated: schema marks this property as deprecated.\nfunc (s *Item) GetOldName()",
+			"// SetOldName sets the value of OldName.\n//\n// Deprecated: schema marks this property as deprecated.\nfunc (s *Item) SetOldName(",
+			"// GetName returns the value of Name.\nfunc (s *Item) GetName()",
+			"// Deprecated: schema marks this type as deprecated.\n//\n// Ref: #/components/schemas/Legacy\n",
+		},
+		"oas_client_gen.go": {
+			"func (c *Client) GetOld(ctx context.Context) (*Item, error) {\n\twarnDeprecated(GetOldOperation)\n\treturn nil, nil\n}",
+			"func (c *Client) GetNew(ctx context.Context) (*Legacy, error) {\n\treturn nil, nil\n}",
+		},
+		outputFile: {
+			"var DeprecationWarning = func(op OperationName) {",
+			"func warnDeprecated(op OperationName) {",
+		},
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(string(got), want) {
+				t.Errorf("%s missing %q:\n%s", file, want, got)
+			}
+		}
+	}
+
+	// A second run leaves the code as it is.
+	before := readFiles(t, dir)
+	if err := run([]string{"-warn", dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	for name, content := range readFiles(t, dir) {
+		if content != before[name] {
+			t.Errorf("second run changed %s:\n%s", name, content)
+		}
+	}
+
+	// The fixed package must compile.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Errorf("fixed code does not type-check: %v", err)
+	}
+}
+
+func TestRun_NoWarn(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource)
+	writeFile(t, filepath.Join(dir, "oas_client_gen.go"), clientSource)
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "oas_client_gen.go")); string(got) != clientSource {
+		t.Errorf("client was modified:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, outputFile)); !os.IsNotExist(err) {
+		t.Errorf("%s written without -warn", outputFile)
+	}
+}
+
+func readFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, e := range entries {
+		content, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[e.Name()] = string(content)
+	}
+	return files
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,330 @@
+// Command ogen-fixenumnames renames the constants of ogen-generated enum
+// types.
+//
+// ogen names enum constants after their values: "active" gives StatusActive,
+// but 1 gives Priority1, and values ogen cannot turn into names give
+// numbered constants. Specs from other generators name the values with an
+// x-enum-varnames extension, which ogen ignores. This tool renames the
+// constants from x-enum-varnames in the spec, or from a config, across the
+// whole generated package, so every reference follows:
+//
+//	{
+//	  "enums": {
+//	    "TaskPriority": {"1": "Low", "2": "Medium", "3": "High"}
+//	  }
+//	}
+//
+// Usage:
+//
+//	ogen-fixenumnames -spec openapi.json -config enums.json <generated-dir>
+//
+// Names are variant names, which are prefixed with the enum type name as
+// ogen does: Low renames TaskPriority1 to TaskPriorityLow. Config names take
+// precedence over x-enum-varnames.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/constant"
+	"go/types"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// Config is the naming map read from the -config file.
+type Config struct {
+	// Enums maps enum type names to their values and the variant names to
+	// give them, e.g. "TaskPriority" -> "1" -> "Low".
+	Enums map[string]map[string]string `json:"enums"`
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixenumnames: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixenumnames", flag.ContinueOnError)
+	configFile := fs.String("config", "", "JSON file with the variant names of enum values")
+	specFile := fs.String("spec", "", "OpenAPI spec with x-enum-varnames extensions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || (*configFile == "" && *specFile == "") {
+		return fmt.Errorf("usage: ogen-fixenumnames [-spec openapi.json] [-config enums.json] <generated-dir>")
+	}
+
+	var cfg Config
+	if *configFile != "" {
+		var err error
+		if cfg, err = loadConfig(*configFile); err != nil {
+			return err
+		}
+	}
+	var varnames []Varnames
+	if *specFile != "" {
+		spec, err := specdoc.ReadFile(*specFile)
+		if err != nil {
+			return err
+		}
+		if varnames, err = SpecVarnames(spec); err != nil {
+			return fmt.Errorf("%s: %w", *specFile, err)
+		}
+	}
+
+	dir := fs.Arg(0)
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	renames, unmatched, err := Plan(FindEnums(pkg), cfg, varnames)
+	if err != nil {
+		return err
+	}
+	for _, ptr := range unmatched {
+		fmt.Fprintf(os.Stderr, "ogen-fixenumnames: #%s: no enum type has its values\n", ptr)
+	}
+
+	count, err := pkg.Rename(func(obj types.Object) string {
+		if c, ok := obj.(*types.Const); ok {
+			if name, ok := renames[c]; ok {
+				return name
+			}
+		}
+		return obj.Name()
+	})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Printf("No enum constants needed renaming in %s\n", dir)
+		return nil
+	}
+
+	written, err := pkg.Write()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Renamed %d enum constants in %d files in %s\n", count, len(written), dir)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Varnames is a schema of the spec with x-enum-varnames.
+type Varnames struct {
+	// Pointer is the JSON pointer of the schema.
+	Pointer string
+	// Values are the enum values, in the form valueKey gives the constants.
+	Values []string
+	// Names are the variant names of Values.
+	Names []string
+}
+
+// SpecVarnames returns the schemas of spec with both enum and
+// x-enum-varnames, in document order. A null value and its name are left
+// out, as ogen has no constant for it.
+func SpecVarnames(spec *specdoc.Object) ([]Varnames, error) {
+	var result []Varnames
+	err := specdoc.Walk(spec, func(o *specdoc.Object, ptr string) error {
+		if !o.Has("x-enum-varnames") {
+			return nil
+		}
+		values, _ := o.Get("enum").([]any)
+		names, _ := o.Get("x-enum-varnames").([]any)
+		if len(values) != len(names) {
+			return fmt.Errorf("#%s: %d x-enum-varnames for %d enum values", ptr, len(names), len(values))
+		}
+		v := Varnames{Pointer: ptr}
+		for i, value := range values {
+			name, ok := names[i].(string)
+			if !ok {
+				return fmt.Errorf("#%s: x-enum-varnames[%d] is not a string", ptr, i)
+			}
+			if value == nil {
+				continue
+			}
+			v.Values = append(v.Values, specValueKey(value))
+			v.Names = append(v.Names, name)
+		}
+		result = append(result, v)
+		return nil
+	})
+	return result, err
+}
+
+// Enum is a generated enum type with its constants in declaration order.
+type Enum struct {
+	Type   *types.TypeName
+	Consts []*types.Const
+}
+
+// FindEnums returns the enum types of pkg, sorted by name: the named basic
+// types with an AllValues method, and the constants of each.
+func FindEnums(pkg *gopkg.Package) []Enum {
+	byType := make(map[*types.TypeName][]*types.Const)
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		c, ok := scope.Lookup(name).(*types.Const)
+		if !ok {
+			continue
+		}
+		named, ok := c.Type().(*types.Named)
+		if !ok || named.Obj().Pkg() != pkg.Types {
+			continue
+		}
+		if _, ok := named.Underlying().(*types.Basic); !ok {
+			continue
+		}
+		if m, _, _ := types.LookupFieldOrMethod(named, false, pkg.Types, "AllValues"); m == nil {
+			continue
+		}
+		byType[named.Obj()] = append(byType[named.Obj()], c)
+	}
+
+	var enums []Enum
+	for typ, consts := range byType {
+		sort.Slice(consts, func(i, j int) bool { return consts[i].Pos() < consts[j].Pos() })
+		enums = append(enums, Enum{Type: typ, Consts: consts})
+	}
+	sort.Slice(enums, func(i, j int) bool { return enums[i].Type.Name() < enums[j].Type.Name() })
+	return enums
+}
+
+// Plan returns the new names of the enum constants, and the pointers of the
+// x-enum-varnames schemas that match no enum. A schema matches the enums
+// whose constants have its values, in the same order. Config names take
+// precedence; a config type or value without an enum constant is an error,
+// as are two schemas that name the values of one enum differently.
+func Plan(enums []Enum, cfg Config, varnames []Varnames) (map[*types.Const]string, []string, error) {
+	renames := make(map[*types.Const]string)
+	used := make([]bool, len(varnames))
+	for _, enum := range enums {
+		typeName := enum.Type.Name()
+		keys := make([]string, len(enum.Consts))
+		for i, c := range enum.Consts {
+			keys[i] = valueKey(c.Val())
+		}
+
+		var from string
+		for i, v := range varnames {
+			if !slices.Equal(v.Values, keys) {
+				continue
+			}
+			used[i] = true
+			if from != "" {
+				if !sameNames(renames, enum, typeName, v.Names) {
+					return nil, nil, fmt.Errorf("%s: #%s and #%s have different x-enum-varnames", typeName, from, v.Pointer)
+				}
+				continue
+			}
+			from = v.Pointer
+			for j, c := range enum.Consts {
+				renames[c] = typeName + v.Names[j]
+			}
+		}
+	}
+
+	for _, typeName := range sortedKeys(cfg.Enums) {
+		idx := slices.IndexFunc(enums, func(e Enum) bool { return e.Type.Name() == typeName })
+		if idx < 0 {
+			return nil, nil, fmt.Errorf("config: no enum type %s", typeName)
+		}
+		names := cfg.Enums[typeName]
+		for _, value := range sortedKeys(names) {
+			ci := slices.IndexFunc(enums[idx].Consts, func(c *types.Const) bool { return displayValue(c.Val()) == value })
+			if ci < 0 {
+				return nil, nil, fmt.Errorf("config: %s has no value %q", typeName, value)
+			}
+			renames[enums[idx].Consts[ci]] = typeName + names[value]
+		}
+	}
+
+	var unmatched []string
+	for i, v := range varnames {
+		if !used[i] {
+			unmatched = append(unmatched, v.Pointer)
+		}
+	}
+	return renames, unmatched, nil
+}
+
+// sameNames reports whether the constants of enum are already renamed to
+// the variant names.
+func sameNames(renames map[*types.Const]string, enum Enum, typeName string, names []string) bool {
+	for i, c := range enum.Consts {
+		if renames[c] != typeName+names[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// valueKey returns the JSON form of a constant value, which tells the string
+// "1" from the number 1.
+func valueKey(v constant.Value) string {
+	if v.Kind() == constant.String {
+		data, _ := json.Marshal(constant.StringVal(v))
+		return string(data)
+	}
+	return displayValue(v)
+}
+
+// specValueKey returns the valueKey of an enum value of the spec.
+func specValueKey(v any) string {
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return strconv.FormatInt(i, 10)
+		}
+		if f, err := n.Float64(); err == nil {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// displayValue returns a constant value as it is written in the config: a
+// string without quotes, a number in decimal.
+func displayValue(v constant.Value) string {
+	switch v.Kind() {
+	case constant.String:
+		return constant.StringVal(v)
+	case constant.Float:
+		f, _ := constant.Float64Val(v)
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return v.ExactString()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSource = `package api
+
+type Status string
+
+const (
+	StatusActive   Status = "active"
+	StatusInActive Status = "in-active"
+	Status1        Status = "1"
+)
+
+// AllValues returns all Status values.
+func (Status) AllValues() []Status {
+	return []Status{StatusActive, StatusInActive, Status1}
+}
+
+type Priority int
+
+const (
+	Priority1 Priority = 1
+	Priority2 Priority = 2
+)
+
+// AllValues returns all Priority values.
+func (Priority) AllValues() []Priority {
+	return []Priority{Priority1, Priority2}
+}
+
+type Code int
+
+const CodeMax Code = 9
+
+// Default is the Status of new tasks; Status1 is reserved.
+func Default() Status {
+	switch p := Priority(1); p {
+	case Priority1:
+		return Status1
+	}
+	return StatusActive
+}
+`
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "components": {
+    "schemas": {
+      "Status": {"type": "string", "enum": ["active", "in-active", "1"], "x-enum-varnames": ["Active", "Inactive", "One"]},
+      "Priority": {"type": "integer", "enum": [1, 2, null], "nullable": true, "x-enum-varnames": ["Low", "High", "None"]},
+      "Other": {"type": "integer", "enum": [1, 2, 3], "x-enum-varnames": ["A", "B", "C"]}
+    }
+  }
+}`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), testSource)
+	writeFile(t, filepath.Join(dir, "openapi.json"), testSpec)
+	writeFile(t, filepath.Join(dir, "enums.json"), `{"enums": {"Status": {"1": "Default"}}}`)
+
+	if err := run([]string{"-spec", filepath.Join(dir, "openapi.json"), "-config", filepath.Join(dir, "enums.json"), dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "oas_schemas_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+
+	wants := []string{
+		`StatusActive   Status = "active"`,
+		`StatusInactive Status = "in-active"`,
+		`StatusDefault  Status = "1"`, // the config wins over x-enum-varnames
+		"PriorityLow  Priority = 1",
+		"PriorityHigh Priority = 2",
+		"return []Priority{PriorityLow, PriorityHigh}",
+		"case PriorityLow:\n\t\treturn StatusDefault",
+		"// Default is the Status of new tasks; StatusDefault is reserved.",
+		"const CodeMax Code = 9",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// A second run finds nothing to rename.
+	if err := run([]string{"-spec", filepath.Join(dir, "openapi.json"), "-config", filepath.Join(dir, "enums.json"), dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if again, _ := os.ReadFile(filepath.Join(dir, "oas_schemas_gen.go")); string(again) != out {
+		t.Error("second run changed the output")
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		spec    string
+		wantErr string
+	}{
+		{
+			name:    "unknown type",
+			config:  `{"enums": {"Color": {"red": "Red"}}}`,
+			wantErr: "config: no enum type Color",
+		},
+		{
+			name:    "unknown value",
+			config:  `{"enums": {"Priority": {"3": "Max"}}}`,
+			wantErr: `config: Priority has no value "3"`,
+		},
+		{
+			name:    "varnames length",
+			spec:    `{"components": {"schemas": {"S": {"enum": ["a", "b"], "x-enum-varnames": ["A"]}}}}`,
+			wantErr: "openapi.json: #/components/schemas/S: 1 x-enum-varnames for 2 enum values",
+		},
+		{
+			name:    "different varnames",
+			spec:    `{"components": {"schemas": {"A": {"enum": [1, 2], "x-enum-varnames": ["Low", "High"]}, "B": {"enum": [1, 2], "x-enum-varnames": ["Min", "Max"]}}}}`,
+			wantErr: "Priority: #/components/schemas/A and #/components/schemas/B have different x-enum-varnames",
+		},
+		{
+			name:    "collision",
+			config:  `{"enums": {"Status": {"1": "Active"}}}`,
+			wantErr: "rename StatusActive: StatusActive is already declared by Status1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), testSource)
+			args := []string{dir}
+			if tt.config != "" {
+				writeFile(t, filepath.Join(dir, "enums.json"), tt.config)
+				args = append([]string{"-config", filepath.Join(dir, "enums.json")}, args...)
+			}
+			if tt.spec != "" {
+				writeFile(t, filepath.Join(dir, "openapi.json"), tt.spec)
+				args = append([]string{"-spec", filepath.Join(dir, "openapi.json")}, args...)
+			}
+
+			err := run(args)
+			if err == nil || !strings.HasSuffix(err.Error(), tt.wantErr) {
+				t.Errorf("run() error = %v, want %q", err, tt.wantErr)
+			}
+			if got, _ := os.ReadFile(filepath.Join(dir, "oas_schemas_gen.go")); string(got) != testSource {
+				t.Error("source was modified")
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,323 @@
+// Command ogen-fixfloat makes selected float fields in ogen-generated code
+// accept NaN, Infinity and numbers sent as strings.
+//
+// Several scientific and metrics APIs emit "NaN", "Infinity" or "-Infinity"
+// for values JSON numbers cannot represent, or quote every number as a
+// string. ogen decodes floats with d.Float64(), which only accepts JSON
+// numbers, and its validators reject NaN and infinities. Accepting these
+// everywhere would hide real bugs, so this tool only touches the fields listed
+// in its config.
+//
+// Usage:
+//
+//	ogen-fixfloat -config floats.json <oas_json_gen.go>
+//
+// The config file maps generated type names to the JSON names of the fields to
+// relax. "*" selects every float field of the type:
+//
+//	{
+//	  "Metric": ["value", "ratio"],
+//	  "Sample": ["*"]
+//	}
+//
+// Field types are read from oas_schemas_gen.go in the same directory, and the
+// NaN/infinity checks for the same fields are dropped from
+// oas_validators_gen.go if it exists.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/plexusone/ogen-tools/internal/fieldfix"
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// Config maps type names to the JSON names of the fields to relax.
+type Config map[string][]string
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixfloat: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixfloat", flag.ContinueOnError)
+	configFile := fs.String("config", "", "JSON file mapping type names to field names")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *configFile == "" {
+		return fmt.Errorf("usage: ogen-fixfloat -config floats.json <oas_json_gen.go>")
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	filename := fs.Arg(0)
+	content, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	dir := filepath.Dir(filename)
+	fields, err := fieldfix.LoadFieldTypes(filepath.Join(dir, "oas_schemas_gen.go"))
+	if err != nil {
+		return err
+	}
+
+	fixed, count, err := FixFloatDecode(content, fields, cfg)
+	if err != nil {
+		return err
+	}
+
+	validatorsFile := filepath.Join(dir, "oas_validators_gen.go")
+	validators, err := os.ReadFile(validatorsFile) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read file: %w", err)
+	}
+	fixedValidators, validateCount := FixFloatValidate(validators, cfg)
+
+	if count == 0 && validateCount == 0 {
+		fmt.Printf("No float fields needed fixing in %s\n", filename)
+		return nil
+	}
+
+	if count > 0 {
+		fixed, err = format.Source(fixed)
+		if err != nil {
+			return fmt.Errorf("format: %w", err)
+		}
+		if err := os.WriteFile(filename, fixed, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+	if validateCount > 0 {
+		if err := os.WriteFile(validatorsFile, fixedValidators, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+
+	fmt.Printf("Fixed %d float fields in %s\n", count, filename)
+	fmt.Printf("Fixed %d float validations in %s\n", validateCount, validatorsFile)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// floatTypes maps the supported field types to their element type.
+var floatTypes = map[string]string{
+	"float64":       "Float64",
+	"[]float64":     "Float64",
+	"OptFloat64":    "Float64",
+	"OptNilFloat64": "Float64",
+	"NilFloat64":    "Float64",
+	"float32":       "Float32",
+	"[]float32":     "Float32",
+	"OptFloat32":    "Float32",
+	"OptNilFloat32": "Float32",
+	"NilFloat32":    "Float32",
+}
+
+// floatRewriter relaxes the decoding of float fields.
+var floatRewriter = fieldfix.Rewriter{
+	Kind:    "float",
+	Types:   supportedTypes(),
+	Rewrite: relaxBlock,
+}
+
+func supportedTypes() map[string]bool {
+	m := make(map[string]bool, len(floatTypes))
+	for t := range floatTypes {
+		m[t] = true
+	}
+	return m
+}
+
+// FixFloatDecode rewrites the decoding of the configured float fields to use
+// the lenient helpers and appends the helpers if they are missing.
+//
+// Required fields and slice elements:
+//
+//	v, err := d.Float64()
+//
+// become:
+//
+//	v, err := decodeFloat64Lenient(d)
+//
+// Optional fields:
+//
+//	if err := s.Ratio.Decode(d); err != nil {
+//
+// become:
+//
+//	if err := decodeOptFloat64Lenient(d, &s.Ratio); err != nil {
+//
+// It returns an error if a configured type or field does not exist or is
+// not a float.
+func FixFloatDecode(content []byte, fields fieldfix.FieldTypes, cfg Config) ([]byte, int, error) {
+	fixed, count, err := floatRewriter.Apply(content, fields, fieldfix.Config(cfg))
+	if err != nil {
+		return nil, 0, err
+	}
+	if count > 0 && !bytes.Contains(fixed, []byte("func decodeFloat64Lenient(")) {
+		fixed = append(fixed, helperFuncs...)
+		fixed = gopkg.AddImports(fixed, "strconv")
+	}
+	return fixed, count, nil
+}
+
+// relaxBlock rewrites one field case to use the lenient decoders.
+func relaxBlock(block []byte, goField, fieldType string) ([]byte, bool) {
+	kind := floatTypes[fieldType]
+	orig := block
+	block = bytes.ReplaceAll(block,
+		[]byte("d."+kind+"()"),
+		[]byte("decode"+kind+"Lenient(d)"))
+	block = bytes.ReplaceAll(block,
+		[]byte("s."+goField+".Decode(d)"),
+		[]byte("decodeOpt"+kind+"Lenient(d, &s."+goField+")"))
+	return block, !bytes.Equal(orig, block)
+}
+
+var (
+	// validateMethodPattern matches a whole generated struct Validate method.
+	validateMethodPattern = regexp.MustCompile(
+		`(?s)func \(s \*(\w+)\) Validate\(\) error \{\n.*?\n\}\n`)
+
+	// validateBlockPattern matches the check of one field in a Validate
+	// method, which ends by naming the field in a FieldError.
+	validateBlockPattern = regexp.MustCompile(
+		`(?s)\n\tif err := func\(\) error \{\n.*?\n\t\t\tName:\s+"((?:[^"\\]|\\.)*)",\n`)
+)
+
+// FixFloatValidate makes the validators of the configured float fields
+// accept NaN and infinities by switching them from validate.Float.Validate
+// to ValidateStringified, which applies the same constraints without the
+// NaN/infinity checks.
+//
+// Only float checks call Validate with a float64 argument, so "*" can relax
+// every check in the method. Fields that don't exist are reported by
+// FixFloatDecode and simply skipped here.
+func FixFloatValidate(content []byte, cfg Config) ([]byte, int) {
+	count := 0
+	fixed := validateMethodPattern.ReplaceAllFunc(content, func(method []byte) []byte {
+		typeName := string(validateMethodPattern.FindSubmatch(method)[1])
+		names, ok := cfg[typeName]
+		if !ok {
+			return method
+		}
+		wanted := make(map[string]bool)
+		for _, name := range names {
+			wanted[name] = true
+		}
+
+		return validateBlockPattern.ReplaceAllFunc(method, func(block []byte) []byte {
+			name := string(validateBlockPattern.FindSubmatch(block)[1])
+			if !wanted[name] && !wanted[fieldfix.AllFields] {
+				return block
+			}
+			n := bytes.Count(block, []byte(").Validate(float64("))
+			count += n
+			return bytes.ReplaceAll(block,
+				[]byte(").Validate(float64("),
+				[]byte(").ValidateStringified(float64("))
+		})
+	})
+	return fixed, count
+}
+
+// helperFuncs is appended to the file the first time it is fixed.
+const helperFuncs = `
+// decodeFloat64Lenient decodes a number that may also be sent as a string,
+// including "NaN", "Infinity" and "-Infinity".
+//
+// Added by ogen-fixfloat.
+func decodeFloat64Lenient(d *jx.Decoder) (float64, error) {
+	if d.Next() != jx.String {
+		return d.Float64()
+	}
+	v, err := d.Str()
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid number %q", v)
+	}
+	return f, nil
+}
+
+// decodeFloat32Lenient is decodeFloat64Lenient for float32 fields.
+//
+// Added by ogen-fixfloat.
+func decodeFloat32Lenient(d *jx.Decoder) (float32, error) {
+	v, err := decodeFloat64Lenient(d)
+	return float32(v), err
+}
+
+// decodeOptFloat64Lenient decodes an optional number with
+// decodeFloat64Lenient. null leaves the value unset, or marks it null if the
+// type supports it.
+//
+// Added by ogen-fixfloat.
+func decodeOptFloat64Lenient(d *jx.Decoder, o interface{ SetTo(float64) }) error {
+	if d.Next() == jx.Null {
+		if err := d.Null(); err != nil {
+			return err
+		}
+		if n, ok := o.(interface{ SetToNull() }); ok {
+			n.SetToNull()
+		}
+		return nil
+	}
+	v, err := decodeFloat64Lenient(d)
+	if err != nil {
+		return err
+	}
+	o.SetTo(v)
+	return nil
+}
+
+// decodeOptFloat32Lenient is decodeOptFloat64Lenient for float32 fields.
+//
+// Added by ogen-fixfloat.
+func decodeOptFloat32Lenient(d *jx.Decoder, o interface{ SetTo(float32) }) error {
+	if d.Next() == jx.Null {
+		if err := d.Null(); err != nil {
+			return err
+		}
+		if n, ok := o.(interface{ SetToNull() }); ok {
+			n.SetToNull()
+		}
+		return nil
+	}
+	v, err := decodeFloat32Lenient(d)
+	if err != nil {
+		return err
+	}
+	o.SetTo(v)
+	return nil
+}
+`
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/fieldfix"
+)
+
+const floatDecodeInput = `package api
+
+import (
+	"math/bits"
+
+	"github.com/go-faster/jx"
+)
+
+// Decode decodes Metric from json.
+func (s *Metric) Decode(d *jx.Decoder) error {
+	if s == nil {
+		return errors.New("invalid: unable to decode Metric to nil")
+	}
+
+	if err := d.ObjBytes(func(d *jx.Decoder, k []byte) error {
+		switch string(k) {
+		case "value":
+			if err := func() error {
+				v, err := d.Float64()
+				s.Value = float64(v)
+				if err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"value\"")
+			}
+		case "weight":
+			if err := func() error {
+				s.Weight.Reset()
+				if err := s.Weight.Decode(d); err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"weight\"")
+			}
+		case "strict":
+			if err := func() error {
+				v, err := d.Float64()
+				s.Strict = float64(v)
+				if err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"strict\"")
+			}
+		case "count":
+			if err := func() error {
+				v, err := d.Int()
+				s.Count = int(v)
+				if err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"count\"")
+			}
+		default:
+			return d.Skip()
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "decode Metric")
+	}
+
+	return nil
+}
+`
+
+const floatValidatorsInput = `package api
+
+func (s *Metric) Validate() error {
+	if s == nil {
+		return validate.ErrNilPointer
+	}
+
+	var failures []validate.FieldError
+	if err := func() error {
+		if err := (validate.Float{}).Validate(float64(s.Value)); err != nil {
+			return errors.Wrap(err, "float")
+		}
+		return nil
+	}(); err != nil {
+		failures = append(failures, validate.FieldError{
+			Name:  "value",
+			Error: err,
+		})
+	}
+	if err := func() error {
+		if err := (validate.Float{}).Validate(float64(s.Strict)); err != nil {
+			return errors.Wrap(err, "float")
+		}
+		return nil
+	}(); err != nil {
+		failures = append(failures, validate.FieldError{
+			Name:  "strict",
+			Error: err,
+		})
+	}
+	if len(failures) > 0 {
+		return &validate.Error{Fields: failures}
+	}
+	return nil
+}
+`
+
+var floatFieldTypes = fieldfix.FieldTypes{
+	"Metric": {
+		"Value":  "float64",
+		"Weight": "OptNilFloat32",
+		"Strict": "float64",
+		"Count":  "int",
+	},
+}
+
+func TestFixFloatDecode(t *testing.T) {
+	cfg := Config{"Metric": {"value", "weight"}}
+	fixed, count, err := FixFloatDecode([]byte(floatDecodeInput), floatFieldTypes, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	out := string(fixed)
+	wants := []string{
+		"v, err := decodeFloat64Lenient(d)\n\t\t\t\ts.Value = float64(v)",
+		"if err := decodeOptFloat32Lenient(d, &s.Weight); err != nil {",
+		"v, err := d.Float64()\n\t\t\t\ts.Strict = float64(v)",
+		"func decodeFloat64Lenient(d *jx.Decoder) (float64, error) {",
+		"import (\n\t\"strconv\"\n\t\"math/bits\"",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+
+	again, count, err := FixFloatDecode(fixed, floatFieldTypes, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 || string(again) != out {
+		t.Errorf("second run changed %d fields", count)
+	}
+}
+
+func TestFixFloatDecodeAllFields(t *testing.T) {
+	_, count, err := FixFloatDecode([]byte(floatDecodeInput), floatFieldTypes, Config{"Metric": {"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+}
+
+func TestFixFloatDecodeNotFloat(t *testing.T) {
+	_, _, err := FixFloatDecode([]byte(floatDecodeInput), floatFieldTypes, Config{"Metric": {"count"}})
+
+	if err == nil || !strings.Contains(err.Error(), "Metric.count is int, not a float") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestFixFloatValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        Config
+		wantCount  int
+		wantStrict bool
+	}{
+		{"selected", Config{"Metric": {"value"}}, 1, true},
+		{"all", Config{"Metric": {"*"}}, 2, false},
+		{"other type", Config{"Other": {"value"}}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixed, count := FixFloatValidate([]byte(floatValidatorsInput), tt.cfg)
+			if count != tt.wantCount {
+				t.Errorf("count = %d, want %d", count, tt.wantCount)
+			}
+			out := string(fixed)
+			strict := strings.Contains(out, "(validate.Float{}).Validate(float64(s.Strict))")
+			if strict != tt.wantStrict {
+				t.Errorf("strict check kept = %v, want %v", strict, tt.wantStrict)
+			}
+		})
+	}
+}
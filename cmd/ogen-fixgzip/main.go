@@ -0,0 +1,164 @@
+// Command ogen-fixgzip makes ogen clients decompress gzip and deflate
+// response bodies.
+//
+// net/http only decompresses a response when the Transport asked for gzip
+// itself. With DisableCompression, a transport that is not an
+// *http.Transport, or an upstream that compresses regardless of
+// Accept-Encoding, the generated decoders get the compressed bytes and fail
+// with errors like "invalid character '\x1f' looking for beginning of value".
+//
+// This tool makes every response decoder start by replacing a body sent with
+// Content-Encoding gzip, x-gzip or deflate by its decompressed content:
+//
+//	func decodeGetUserResponse(resp *http.Response) (res *User, _ error) {
+//		decompressResponse(resp)
+//		switch resp.StatusCode {
+//
+// Usage:
+//
+//	ogen-fixgzip <generated-dir>
+//
+// The helper is written to oas_gzip_gen.go.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the helper is written to.
+const outputFile = "oas_gzip_gen.go"
+
+const decodersFile = "oas_response_decoders_gen.go"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixgzip: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-fixgzip <generated-dir>")
+	}
+
+	dir := args[0]
+	decoders, err := os.ReadFile(filepath.Join(dir, decodersFile)) // #nosec G703 -- CLI tool, filename from trusted args
+	if os.IsNotExist(err) {
+		fmt.Printf("No client found in %s\n", dir)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	decoders, count := DecompressResponses(decoders)
+	if count == 0 {
+		fmt.Printf("No response decoders needed fixing in %s\n", dir)
+		return nil
+	}
+
+	// The output file stays in: decoders fixed by an earlier run call its
+	// helper.
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"decompressResponse", "decompressedBody"} {
+		obj := pkg.Types.Scope().Lookup(name)
+		if obj != nil && filepath.Base(pkg.Fset.Position(obj.Pos()).Filename) != outputFile {
+			return fmt.Errorf("%s is already declared", name)
+		}
+	}
+
+	// #nosec G703 -- CLI tool, filename from trusted args
+	if err := os.WriteFile(filepath.Join(pkg.Dir, decodersFile), decoders, 0600); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	gen := gopkg.NewGenerated("ogen-fixgzip", pkg.Types)
+	writeHelper(gen)
+	if err := gen.WriteFile(filepath.Join(pkg.Dir, outputFile)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Fixed %d response decoders in %s\n", count, dir)
+	return nil
+}
+
+// decoderPattern matches the start of a response decoder, and the call of
+// decompressResponse if an earlier run added it.
+var decoderPattern = regexp.MustCompile(
+	`(?m)^func decode\w+Response\(resp \*http\.Response\) \([^\n]*\) \{\n(\tdecompressResponse\(resp\)\n)?`)
+
+// DecompressResponses makes every response decoder call decompressResponse
+// first. It returns the number of decoders it changed, leaving those fixed
+// by an earlier run as they are.
+func DecompressResponses(decoders []byte) ([]byte, int) {
+	count := 0
+	decoders = decoderPattern.ReplaceAllFunc(decoders, func(m []byte) []byte {
+		if decoderPattern.FindSubmatchIndex(m)[2] >= 0 {
+			return m
+		}
+		count++
+		return append(m[:len(m):len(m)], "\tdecompressResponse(resp)\n"...)
+	})
+	return decoders, count
+}
+
+func writeHelper(gen *gopkg.Generated) {
+	gen.Import("compress/gzip", "gzip")
+	gen.Import("compress/zlib", "zlib")
+	gen.Import("io", "io")
+	gen.Import("net/http", "http")
+	gen.Import("strings", "strings")
+	gen.Printf(`// decompressResponse replaces the body of a response sent with a gzip or
+// deflate Content-Encoding by its decompressed content. Responses the
+// Transport already decompressed have no Content-Encoding left.
+func decompressResponse(resp *http.Response) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "gzip", "x-gzip", "deflate":
+	default:
+		return
+	}
+	resp.Body = &decompressedBody{body: resp.Body, encoding: encoding}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decompressedBody decompresses body on the first Read, so an empty body,
+// such as the one of a 204, reads as empty instead of failing.
+type decompressedBody struct {
+	body     io.ReadCloser
+	encoding string
+	r        io.Reader
+	err      error
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if b.r == nil && b.err == nil {
+		if b.encoding == "deflate" {
+			// HTTP deflate is the zlib format.
+			b.r, b.err = zlib.NewReader(b.body)
+		} else {
+			b.r, b.err = gzip.NewReader(b.body)
+		}
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.r.Read(p)
+}
+
+func (b *decompressedBody) Close() error {
+	return b.body.Close()
+}
+`)
+}
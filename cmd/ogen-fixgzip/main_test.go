@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const decodersSource = `package api
+
+func decodeGetUserResponse(resp *http.Response) (res *User, _ error) {
+	switch resp.StatusCode {
+	}
+	return res, validate.UnexpectedStatusCodeWithResponse(resp)
+}
+
+func decodeDeleteUserResponse(resp *http.Response) (res *DeleteUserNoContent, _ error) {
+	switch resp.StatusCode {
+	}
+	return res, validate.UnexpectedStatusCodeWithResponse(resp)
+}
+`
+
+func TestDecompressResponses(t *testing.T) {
+	got, n := DecompressResponses([]byte(decodersSource))
+	if n != 2 {
+		t.Errorf("DecompressResponses() fixed %d decoders, want 2", n)
+	}
+	for _, want := range []string{
+		"(res *User, _ error) {\n\tdecompressResponse(resp)\n\tswitch resp.StatusCode {\n",
+		"(res *DeleteUserNoContent, _ error) {\n\tdecompressResponse(resp)\n\tswitch resp.StatusCode {\n",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+
+	// Running again changes nothing.
+	again, n := DecompressResponses(got)
+	if string(again) != string(got) || n != 0 {
+		t.Errorf("second DecompressResponses() = %d:\n%s", n, again)
+	}
+}
@@ -0,0 +1,236 @@
+// Command ogen-fixkeycase makes the Decode methods of selected ogen-generated
+// types match JSON object keys case-insensitively.
+//
+// encoding/json matches keys to struct fields case-insensitively; ogen only
+// accepts the exact spelling of the spec. Upstreams that send "userId" from
+// one endpoint and "UserID" from another lose the field silently, because
+// unknown keys are skipped. Matching loosely everywhere would hide real
+// mismatches, so this tool only touches the types listed in its config.
+//
+// Usage:
+//
+//	ogen-fixkeycase -config keycase.json <oas_json_gen.go>
+//
+// The config file maps generated type names to the JSON names of the fields
+// to match loosely. "*" selects every field of the type:
+//
+//	{
+//	  "User": ["userId"],
+//	  "LegacyAccount": ["*"]
+//	}
+//
+// As with encoding/json, a key with the exact spelling is preferred, and any
+// other casing of it is decoded into the same field.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// Config maps type names to the JSON names of the fields to match loosely.
+type Config map[string][]string
+
+// allFields selects every field of a type.
+const allFields = "*"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixkeycase: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixkeycase", flag.ContinueOnError)
+	configFile := fs.String("config", "", "JSON file mapping type names to field names")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *configFile == "" {
+		return fmt.Errorf("usage: ogen-fixkeycase -config keycase.json <oas_json_gen.go>")
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	filename := fs.Arg(0)
+	content, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	fixed, count, err := FixKeyCase(content, cfg)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Printf("No types configured in %s\n", *configFile)
+		return nil
+	}
+
+	fixed, err = format.Source(fixed)
+	if err != nil {
+		return fmt.Errorf("format: %w", err)
+	}
+
+	if err := os.WriteFile(filename, fixed, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	fmt.Printf("Fixed key matching of %d types in %s\n", count, filename)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// helperName is the function the key switch of a fixed Decode method calls.
+const helperName = "foldKey"
+
+// helperFunc is appended to the file the first time it is fixed.
+const helperFunc = `
+// foldKey returns the key of keys that k matches: k itself if it is spelled
+// exactly as one of them, otherwise the first one equal to k under Unicode
+// case folding, as encoding/json does. Other keys are returned unchanged.
+//
+// Added by ogen-fixkeycase.
+func foldKey(k []byte, keys ...string) string {
+	s := string(k)
+	for _, key := range keys {
+		if key == s {
+			return s
+		}
+	}
+	for _, key := range keys {
+		if strings.EqualFold(key, s) {
+			return key
+		}
+	}
+	return s
+}
+`
+
+var (
+	// decodeMethodPattern matches a whole generated struct Decode method.
+	decodeMethodPattern = regexp.MustCompile(
+		`(?s)func \(s \*(\w+)\) Decode\(d \*jx\.Decoder\) error \{\n.*?\n\}\n`)
+
+	// keySwitchPattern matches the switch on the object keys in a Decode
+	// method, as generated or as fixed by an earlier run.
+	keySwitchPattern = regexp.MustCompile(`\n\t\tswitch (?:string\(k\)|foldKey\(k(?:, "(?:[^"\\]|\\.)*")*\)) \{\n`)
+
+	// casePattern matches the JSON name of a field case in the key switch.
+	casePattern = regexp.MustCompile(`\n\t\tcase ("(?:[^"\\]|\\.)*"):\n`)
+)
+
+// FixKeyCase makes the key switch of the Decode method of every configured
+// type match the configured keys with foldKey, and appends the helper and its
+// import if they are missing. It returns the number of types fixed.
+//
+// The switch:
+//
+//	switch string(k) {
+//	case "userId":
+//
+// becomes:
+//
+//	switch foldKey(k, "userId") {
+//	case "userId":
+//
+// It returns an error if a configured type or field does not exist.
+func FixKeyCase(content []byte, cfg Config) ([]byte, int, error) {
+	seen := make(map[string]bool)
+	count := 0
+	var errs []string
+
+	fixed := decodeMethodPattern.ReplaceAllFunc(content, func(method []byte) []byte {
+		typeName := string(decodeMethodPattern.FindSubmatch(method)[1])
+		names, ok := cfg[typeName]
+		if !ok {
+			return method
+		}
+		seen[typeName] = true
+
+		loc := keySwitchPattern.FindIndex(method)
+		if loc == nil {
+			errs = append(errs, fmt.Sprintf("%s.Decode does not switch on object keys", typeName))
+			return method
+		}
+
+		wanted := make(map[string]bool)
+		for _, name := range names {
+			wanted[name] = true
+		}
+		all := wanted[allFields]
+		delete(wanted, allFields)
+
+		var keys []string
+		for _, m := range casePattern.FindAllSubmatch(method[loc[1]-1:], -1) {
+			name, err := strconv.Unquote(string(m[1]))
+			if err != nil {
+				continue
+			}
+			if all || wanted[name] {
+				keys = append(keys, string(m[1]))
+			}
+			delete(wanted, name)
+		}
+		for name := range wanted {
+			errs = append(errs, fmt.Sprintf("%s has no field %q", typeName, name))
+		}
+		if len(keys) == 0 {
+			return method
+		}
+
+		count++
+		var out bytes.Buffer
+		out.Write(method[:loc[0]])
+		fmt.Fprintf(&out, "\n\t\tswitch %s(k, %s) {\n", helperName, strings.Join(keys, ", "))
+		out.Write(method[loc[1]:])
+		return out.Bytes()
+	})
+
+	for typeName := range cfg {
+		if !seen[typeName] {
+			errs = append(errs, fmt.Sprintf("type %s has no generated Decode method", typeName))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, 0, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	if count == 0 {
+		return content, 0, nil
+	}
+
+	if !bytes.Contains(fixed, []byte("func "+helperName+"(")) {
+		fixed = append(fixed, helperFunc...)
+	}
+	fixed = gopkg.AddImports(fixed, "strings")
+
+	return fixed, count, nil
+}
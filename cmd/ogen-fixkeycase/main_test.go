@@ -0,0 +1,151 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const keyDecodeInput = `package api
+
+import (
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+)
+
+// Decode decodes User from json.
+func (s *User) Decode(d *jx.Decoder) error {
+	if s == nil {
+		return errors.New("invalid: unable to decode User to nil")
+	}
+
+	if err := d.ObjBytes(func(d *jx.Decoder, k []byte) error {
+		switch string(k) {
+		case "userId":
+			if err := func() error {
+				v, err := d.Str()
+				s.UserId = string(v)
+				if err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"userId\"")
+			}
+		case "display_name":
+			if err := func() error {
+				v, err := d.Str()
+				s.DisplayName = string(v)
+				if err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"display_name\"")
+			}
+		default:
+			return d.Skip()
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "decode User")
+	}
+
+	return nil
+}
+
+// Decode decodes Group from json.
+func (s *Group) Decode(d *jx.Decoder) error {
+	if s == nil {
+		return errors.New("invalid: unable to decode Group to nil")
+	}
+
+	if err := d.ObjBytes(func(d *jx.Decoder, k []byte) error {
+		switch string(k) {
+		case "name":
+			if err := func() error {
+				v, err := d.Str()
+				s.Name = string(v)
+				if err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"name\"")
+			}
+		default:
+			return d.Skip()
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "decode Group")
+	}
+
+	return nil
+}
+`
+
+func TestFixKeyCase(t *testing.T) {
+	cfg := Config{"User": {"userId"}}
+	fixed, count, err := FixKeyCase([]byte(keyDecodeInput), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+
+	out := string(fixed)
+	wants := []string{
+		"\t\tswitch foldKey(k, \"userId\") {\n\t\tcase \"userId\":",
+		// Group is not configured.
+		"\t\tswitch string(k) {\n\t\tcase \"name\":",
+		"func foldKey(k []byte, keys ...string) string {",
+		"import (\n\t\"strings\"\n",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+
+	again, _, err := FixKeyCase(fixed, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != out {
+		t.Errorf("second run changed the output:\n%s", again)
+	}
+
+	// Changing the config rewrites the keys of a fixed method.
+	all, _, err := FixKeyCase(fixed, Config{"User": {"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(all), `switch foldKey(k, "userId", "display_name") {`) {
+		t.Errorf("keys not updated:\n%s", all)
+	}
+	if n := strings.Count(string(all), "func foldKey("); n != 1 {
+		t.Errorf("foldKey declared %d times", n)
+	}
+}
+
+func TestFixKeyCaseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"unknown type", Config{"Other": {"*"}}, "type Other has no generated Decode method"},
+		{"unknown field", Config{"User": {"userID"}}, `User has no field "userID"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := FixKeyCase([]byte(keyDecodeInput), tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
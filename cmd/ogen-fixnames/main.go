@@ -0,0 +1,156 @@
+// Command ogen-fixnames applies a naming map to ogen-generated identifiers.
+//
+// ogen derives Go names from the spec, so SDK naming conventions (ID rather
+// than Id, API rather than Api, friendlier type names) are lost on every
+// regeneration. This tool renames identifiers across the whole generated
+// package using type information, so every reference follows its declaration
+// and identifiers belonging to other packages are never touched.
+//
+// Usage:
+//
+//	ogen-fixnames -config names.json <generated-dir>
+//
+// The config file maps camel-case words and whole identifiers:
+//
+//	{
+//	  "words": {"Id": "ID", "Api": "API", "Url": "URL"},
+//	  "names": {"GetUserOKApplicationJSON": "UserResponse"}
+//	}
+//
+// With the config above, UserId becomes UserID, GetApiKeyParams becomes
+// GetAPIKeyParams, and GetUserOKApplicationJSON becomes UserResponse. Whole
+// identifier mappings take precedence over word mappings.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/types"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// Config is the naming map read from the -config file.
+type Config struct {
+	// Words maps camel-case words to their replacement, e.g. "Id" -> "ID".
+	Words map[string]string `json:"words"`
+
+	// Names maps whole identifiers to their replacement.
+	Names map[string]string `json:"names"`
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixnames: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixnames", flag.ContinueOnError)
+	configFile := fs.String("config", "", "JSON file with word and name mappings")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *configFile == "" {
+		return fmt.Errorf("usage: ogen-fixnames -config names.json <generated-dir>")
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	dir := fs.Arg(0)
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	count, err := pkg.Rename(func(obj types.Object) string {
+		return MapName(obj.Name(), cfg)
+	})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Printf("No identifiers needed renaming in %s\n", dir)
+		return nil
+	}
+
+	written, err := pkg.Write()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Renamed %d identifiers in %d files in %s\n", count, len(written), dir)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// MapName returns the new name for an identifier under cfg, or name itself
+// if no mapping applies.
+func MapName(name string, cfg Config) string {
+	if newName, ok := cfg.Names[name]; ok {
+		return newName
+	}
+	if len(cfg.Words) == 0 {
+		return name
+	}
+
+	words := SplitWords(name)
+	for i, word := range words {
+		if replacement, ok := cfg.Words[word]; ok {
+			words[i] = replacement
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// SplitWords splits a camel-case identifier into its words. Runs of upper
+// case letters are kept together as an initialism, and digits stay attached
+// to the word before them:
+//
+//	UserId        -> User Id
+//	JSONDecoder   -> JSON Decoder
+//	getV2ApiKey   -> get V2 Api Key
+func SplitWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		switch {
+		case unicode.IsLower(prev) && unicode.IsUpper(cur),
+			unicode.IsDigit(prev) && unicode.IsUpper(cur):
+			// userId, v2Api: a new word starts at the upper case letter.
+		case unicode.IsUpper(prev) && unicode.IsUpper(cur) &&
+			i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+			// JSONDecoder: the last upper case letter of a run starts the next word.
+		case cur == '_' || prev == '_':
+			// snake_case pieces are words too; the underscore is its own word.
+		default:
+			continue
+		}
+		words = append(words, string(runes[start:i]))
+		start = i
+	}
+	return append(words, string(runes[start:]))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"UserId", []string{"User", "Id"}},
+		{"JSONDecoder", []string{"JSON", "Decoder"}},
+		{"getV2ApiKey", []string{"get", "V2", "Api", "Key"}},
+		{"ID", []string{"ID"}},
+		{"Identity", []string{"Identity"}},
+		{"GetUserOKApplicationJSON", []string{"Get", "User", "OK", "Application", "JSON"}},
+		{"x", []string{"x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitWords(tt.name); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitWords(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapName(t *testing.T) {
+	cfg := Config{
+		Words: map[string]string{"Id": "ID", "Api": "API"},
+		Names: map[string]string{"GetUserOKApplicationJSON": "UserResponse", "ApiId": "Key"},
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"UserId", "UserID"},
+		{"GetApiKeyParams", "GetAPIKeyParams"},
+		{"Identity", "Identity"},
+		{"GetUserOKApplicationJSON", "UserResponse"},
+		{"ApiId", "Key"}, // whole-name mapping wins over words
+		{"userId", "userID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MapName(tt.name, cfg); got != tt.want {
+				t.Errorf("MapName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+
+	src := `package api
+
+import "net/http"
+
+// UserId identifies a user.
+type UserId string
+
+type User struct {
+	Id   UserId
+	Body string
+}
+
+func decodeUser(resp *http.Response) (User, error) {
+	_ = resp.Body
+	return User{Id: UserId("1")}, nil
+}
+`
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), src)
+	writeFile(t, filepath.Join(dir, "names.json"), `{"words": {"Id": "ID"}, "names": {"Body": "Payload"}}`)
+
+	if err := run([]string{"-config", filepath.Join(dir, "names.json"), dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "oas_schemas_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+
+	wants := []string{
+		"// UserID identifies a user.",
+		"type UserID string",
+		"ID      UserID",
+		"Payload string",
+		"_ = resp.Body", // belongs to net/http and must not change
+		"User{ID: UserID(\"1\")}",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRun_Collision(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), "package api\n\ntype UserId string\n\ntype UserID int\n")
+	writeFile(t, filepath.Join(dir, "names.json"), `{"words": {"Id": "ID"}}`)
+
+	err := run([]string{"-config", filepath.Join(dir, "names.json"), dir})
+	if err == nil || !strings.Contains(err.Error(), "already declared") {
+		t.Errorf("err = %v, want collision error", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,163 @@
+// Command ogen-fixnullarray makes selected array fields in ogen-generated code
+// decode null as an empty slice.
+//
+// Some APIs send "items": null for an array the spec does not declare
+// nullable. ogen decodes the field with d.Arr, which fails on null with
+// `"[" expected`, and the whole response is lost. Accepting null for every
+// array would hide real bugs, so this tool only touches the fields listed in
+// its config.
+//
+// Usage:
+//
+//	ogen-fixnullarray -config nullarrays.json <oas_json_gen.go>
+//
+// The config file maps generated type names to the JSON names of the fields to
+// relax. "*" selects every array field of the type:
+//
+//	{
+//	  "SearchResult": ["items"],
+//	  "LegacyReport": ["*"]
+//	}
+//
+// Field types are read from oas_schemas_gen.go in the same directory. Fields of
+// any slice type but []byte are supported; nullable arrays (OptNil*Array)
+// already accept null.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/fieldfix"
+)
+
+// Config maps type names to the JSON names of the fields to relax.
+type Config map[string][]string
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixnullarray: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixnullarray", flag.ContinueOnError)
+	configFile := fs.String("config", "", "JSON file mapping type names to field names")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *configFile == "" {
+		return fmt.Errorf("usage: ogen-fixnullarray -config nullarrays.json <oas_json_gen.go>")
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	filename := fs.Arg(0)
+	content, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	fields, err := fieldfix.LoadFieldTypes(filepath.Join(filepath.Dir(filename), "oas_schemas_gen.go"))
+	if err != nil {
+		return err
+	}
+
+	fixed, count, err := FixNullArrayDecode(content, fields, cfg)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Printf("No array fields needed fixing in %s\n", filename)
+		return nil
+	}
+
+	if err := os.WriteFile(filename, fixed, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	fmt.Printf("Fixed %d array fields in %s\n", count, filename)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// FixNullArrayDecode makes the configured array fields decode null as an
+// empty slice.
+//
+// The field decoding:
+//
+//	s.Items = make([]Item, 0)
+//	if err := d.Arr(func(d *jx.Decoder) error {
+//
+// becomes:
+//
+//	s.Items = make([]Item, 0)
+//	if d.Next() == jx.Null {
+//		return d.Null()
+//	}
+//	if err := d.Arr(func(d *jx.Decoder) error {
+//
+// It returns an error if a configured type or field does not exist or is
+// not a slice.
+func FixNullArrayDecode(content []byte, fields fieldfix.FieldTypes, cfg Config) ([]byte, int, error) {
+	// The supported types are whatever slices the schemas declare, except
+	// base64 strings.
+	sliceTypes := make(map[string]bool)
+	for _, m := range fields {
+		for _, fieldType := range m {
+			if strings.HasPrefix(fieldType, "[]") && fieldType != "[]byte" {
+				sliceTypes[fieldType] = true
+			}
+		}
+	}
+
+	rewriter := fieldfix.Rewriter{
+		Kind:    "slice",
+		Types:   sliceTypes,
+		Rewrite: nullArrayBlock,
+	}
+	return rewriter.Apply(content, fields, fieldfix.Config(cfg))
+}
+
+// arrayStartPattern matches the start of the decoding of an array field. The
+// field is substituted for %s.
+const arrayStartPattern = `\n(\t+)s\.%s = make\([^\n]*, 0\)\n(\t+if err := d\.Arr\()`
+
+// nullArrayBlock rewrites one field case to accept null. A field fixed by an
+// earlier run no longer has the d.Arr call right after the make.
+func nullArrayBlock(block []byte, goField, _ string) ([]byte, bool) {
+	re := regexp.MustCompile(fmt.Sprintf(arrayStartPattern, regexp.QuoteMeta(goField)))
+	loc := re.FindSubmatchIndex(block)
+	if loc == nil {
+		return block, false
+	}
+	indent := string(block[loc[2]:loc[3]])
+	check := indent + "if d.Next() == jx.Null {\n" + indent + "\treturn d.Null()\n" + indent + "}\n"
+
+	fixed := append([]byte(nil), block[:loc[4]]...)
+	fixed = append(fixed, check...)
+	fixed = append(fixed, block[loc[4]:]...)
+	return fixed, true
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/fieldfix"
+)
+
+const arrayDecodeInput = `package api
+
+// Decode decodes SearchResult from json.
+func (s *SearchResult) Decode(d *jx.Decoder) error {
+	if s == nil {
+		return errors.New("invalid: unable to decode SearchResult to nil")
+	}
+	var requiredBitSet [1]uint8
+
+	if err := d.ObjBytes(func(d *jx.Decoder, k []byte) error {
+		switch string(k) {
+		case "items":
+			requiredBitSet[0] |= 1 << 0
+			if err := func() error {
+				s.Items = make([]Item, 0)
+				if err := d.Arr(func(d *jx.Decoder) error {
+					var elem Item
+					if err := elem.Decode(d); err != nil {
+						return err
+					}
+					s.Items = append(s.Items, elem)
+					return nil
+				}); err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"items\"")
+			}
+		case "tags":
+			if err := func() error {
+				s.Tags = make([]string, 0)
+				if err := d.Arr(func(d *jx.Decoder) error {
+					var elem string
+					v, err := d.Str()
+					elem = string(v)
+					if err != nil {
+						return err
+					}
+					s.Tags = append(s.Tags, elem)
+					return nil
+				}); err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"tags\"")
+			}
+		case "total":
+			if err := func() error {
+				v, err := d.Int()
+				s.Total = int(v)
+				if err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"total\"")
+			}
+		default:
+			return d.Skip()
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "decode SearchResult")
+	}
+
+	return nil
+}
+`
+
+var arrayFieldTypes = fieldfix.FieldTypes{
+	"SearchResult": {
+		"Items": "[]Item",
+		"Tags":  "[]string",
+		"Total": "int",
+	},
+}
+
+func TestFixNullArrayDecode(t *testing.T) {
+	cfg := Config{"SearchResult": {"items"}}
+	fixed, count, err := FixNullArrayDecode([]byte(arrayDecodeInput), arrayFieldTypes, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+
+	out := string(fixed)
+	want := "\t\t\t\ts.Items = make([]Item, 0)\n" +
+		"\t\t\t\tif d.Next() == jx.Null {\n\t\t\t\t\treturn d.Null()\n\t\t\t\t}\n" +
+		"\t\t\t\tif err := d.Arr(func(d *jx.Decoder) error {\n"
+	if !strings.Contains(out, want) {
+		t.Errorf("output missing %q", want)
+	}
+	if strings.Count(out, "jx.Null") != 1 {
+		t.Error("tags should not be changed")
+	}
+
+	again, count, err := FixNullArrayDecode(fixed, arrayFieldTypes, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 || string(again) != out {
+		t.Errorf("second run changed %d fields", count)
+	}
+}
+
+func TestFixNullArrayDecodeAllFields(t *testing.T) {
+	fixed, count, err := FixNullArrayDecode([]byte(arrayDecodeInput), arrayFieldTypes, Config{"SearchResult": {"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+	if !strings.Contains(string(fixed), "s.Tags = make([]string, 0)\n\t\t\t\tif d.Next() == jx.Null {") {
+		t.Error("tags should be changed")
+	}
+}
+
+func TestFixNullArrayDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"unknown type", Config{"Other": {"x"}}, "type Other has no generated Decode method"},
+		{"unknown field", Config{"SearchResult": {"missing"}}, `SearchResult has no field "missing"`},
+		{"not a slice", Config{"SearchResult": {"total"}}, "SearchResult.total is int, not a slice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := FixNullArrayDecode([]byte(arrayDecodeInput), arrayFieldTypes, tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
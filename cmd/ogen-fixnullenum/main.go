@@ -0,0 +1,251 @@
+// Command ogen-fixnullenum fixes ogen-generated code to accept null for
+// nullable enum fields.
+//
+// Nullable enums referenced via $ref hit the same problem as other nullable
+// $ref fields (https://github.com/ogen-go/ogen/issues/1358): ogen drops the
+// nullable flag, so optional fields become Opt* instead of OptNil* and required
+// fields use the bare enum type. Decoding null then fails in d.StrBytes(), and
+// even once null is accepted the zero value fails enum validation.
+//
+// Usage:
+//
+//	ogen-fixnullenum [-types A,B] <oas_json_gen.go> <oas_validators_gen.go>
+//
+// The tool modifies both files in place:
+//
+//   - Enum Decode methods accept null and leave the zero value.
+//   - Opt* Decode methods wrapping an enum accept null and stay unset.
+//   - Enum Validate methods accept the zero value, which is what null decodes to.
+//
+// By default every string enum is fixed. Use -types to limit the fix to the
+// enums that are actually nullable in the spec, so the others keep rejecting
+// null and the empty string.
+//
+// Run it before ogen-fixrecursion, which changes the shape of Decode and
+// Validate methods:
+//
+//	ogen --package api --target internal/api --clean openapi.json
+//	ogen-fixnullenum internal/api/oas_json_gen.go internal/api/oas_validators_gen.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixnullenum: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixnullenum", flag.ContinueOnError)
+	typesFlag := fs.String("types", "", "comma-separated enum types to fix (default: all string enums)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: ogen-fixnullenum [-types A,B] <oas_json_gen.go> <oas_validators_gen.go>")
+	}
+	jsonFile, validatorsFile := fs.Arg(0), fs.Arg(1)
+
+	jsonContent, err := os.ReadFile(jsonFile) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	validatorsContent, err := os.ReadFile(validatorsFile) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	enums := FindStringEnums(jsonContent)
+	if *typesFlag != "" {
+		selected := make(map[string]bool)
+		for _, name := range strings.Split(*typesFlag, ",") {
+			name = strings.TrimSpace(name)
+			if !enums[name] {
+				return fmt.Errorf("%s is not a string enum in %s", name, jsonFile)
+			}
+			selected[name] = true
+		}
+		enums = selected
+	}
+
+	fixedJSON, decodeCount := FixEnumDecode(jsonContent, enums)
+	fixedJSON, optCount := FixOptEnumDecode(fixedJSON, enums)
+	fixedValidators, validateCount := FixEnumValidate(validatorsContent, enums)
+
+	if decodeCount+optCount+validateCount == 0 {
+		fmt.Printf("No enum methods needed fixing in %s and %s\n", jsonFile, validatorsFile)
+		return nil
+	}
+
+	if decodeCount+optCount > 0 {
+		if err := os.WriteFile(jsonFile, fixedJSON, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+	if validateCount > 0 {
+		if err := os.WriteFile(validatorsFile, fixedValidators, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+
+	fmt.Printf("Fixed %d enum Decode and %d Opt* Decode methods in %s\n", decodeCount, optCount, jsonFile)
+	fmt.Printf("Fixed %d enum Validate methods in %s\n", validateCount, validatorsFile)
+	return nil
+}
+
+// nullCheck is inserted into Decode methods. It consumes the null and
+// returns, leaving the receiver at its zero value.
+const nullCheck = `if d.Next() == jx.Null {
+		if err := d.Null(); err != nil {
+			return err
+		}
+		return nil
+	}
+	`
+
+var (
+	// enumDecodePattern matches the start of a generated string enum Decode
+	// method up to the d.StrBytes() call. Enum decoders are the only ones
+	// followed by a "Try to use constant string" switch. The null check is
+	// optional so already-fixed decoders are still recognised as enums.
+	enumDecodePattern = regexp.MustCompile(
+		`(func \(s \*(\w+)\) Decode\(d \*jx\.Decoder\) error \{\s*` +
+			`if s == nil \{\s*` +
+			`return errors\.New\("invalid: unable to decode \w+ to nil"\)\s*\}\s*)` +
+			`(if d\.Next\(\) == jx\.Null \{\s*if err := d\.Null\(\); err != nil \{\s*return err\s*\}\s*return nil\s*\}\s*)?` +
+			`(v, err := d\.StrBytes\(\)\s*if err != nil \{\s*return err\s*\}\s*` +
+			`// Try to use constant string\.)`)
+
+	// optDecodePattern matches an Opt* Decode method without null handling,
+	// as in ogen-fixnull.
+	optDecodePattern = regexp.MustCompile(
+		`(func \(o \*Opt(\w+)\) Decode\(d \*jx\.Decoder\) error \{\s*` +
+			`if o == nil \{\s*` +
+			`return errors\.New\("invalid: unable to decode Opt\w+ to nil"\)\s*\}\s*)` +
+			`(o\.Set = true)`)
+
+	// enumValidatePattern matches the start of a generated enum Validate
+	// method: a value receiver switching on itself.
+	enumValidatePattern = regexp.MustCompile(
+		`(func \(s (\w+)\) Validate\(\) error \{\n\tswitch s \{\n)`)
+)
+
+// FindStringEnums returns the names of all string enum types with a
+// generated Decode method in content.
+func FindStringEnums(content []byte) map[string]bool {
+	enums := make(map[string]bool)
+	for _, m := range enumDecodePattern.FindAllSubmatch(content, -1) {
+		enums[string(m[2])] = true
+	}
+	return enums
+}
+
+// FixEnumDecode adds null handling to the Decode methods of the given enums.
+//
+// The pattern it looks for:
+//
+//	func (s *Status) Decode(d *jx.Decoder) error {
+//		if s == nil {
+//			return errors.New("invalid: unable to decode Status to nil")
+//		}
+//		v, err := d.StrBytes()
+//
+// And transforms it to:
+//
+//	func (s *Status) Decode(d *jx.Decoder) error {
+//		if s == nil {
+//			return errors.New("invalid: unable to decode Status to nil")
+//		}
+//		if d.Next() == jx.Null {
+//			if err := d.Null(); err != nil {
+//				return err
+//			}
+//			return nil
+//		}
+//		v, err := d.StrBytes()
+func FixEnumDecode(content []byte, enums map[string]bool) ([]byte, int) {
+	count := 0
+	fixed := enumDecodePattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		m := enumDecodePattern.FindSubmatch(match)
+		if !enums[string(m[2])] || len(m[3]) > 0 {
+			return match
+		}
+		count++
+
+		var result bytes.Buffer
+		result.Write(m[1])
+		result.WriteString(nullCheck)
+		result.Write(m[4])
+		return result.Bytes()
+	})
+	return fixed, count
+}
+
+// FixOptEnumDecode adds null handling to Opt* Decode methods that wrap one of
+// the given enums, so a null value leaves the option unset. Opt* types
+// wrapping other types are left for ogen-fixnull.
+func FixOptEnumDecode(content []byte, enums map[string]bool) ([]byte, int) {
+	count := 0
+	fixed := optDecodePattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		m := optDecodePattern.FindSubmatch(match)
+		if !enums[string(m[2])] {
+			return match
+		}
+		count++
+
+		var result bytes.Buffer
+		result.Write(m[1])
+		result.WriteString(nullCheck)
+		result.Write(m[3])
+		return result.Bytes()
+	})
+	return fixed, count
+}
+
+// FixEnumValidate makes the Validate methods of the given enums accept the
+// zero value, which is what a decoded null leaves behind.
+//
+// The pattern it looks for:
+//
+//	func (s Status) Validate() error {
+//		switch s {
+//
+// And transforms it to:
+//
+//	func (s Status) Validate() error {
+//		switch s {
+//		case "":
+//			// null, see ogen-fixnullenum.
+//			return nil
+//
+// Enums that already have an empty-string case are left untouched.
+func FixEnumValidate(content []byte, enums map[string]bool) ([]byte, int) {
+	count := 0
+	fixed := enumValidatePattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		m := enumValidatePattern.FindSubmatch(match)
+		name := string(m[2])
+		if !enums[name] {
+			return match
+		}
+		if bytes.Contains(content, []byte("func (s "+name+") Validate() error {\n\tswitch s {\n\tcase \"\":")) {
+			return match
+		}
+		count++
+
+		var result bytes.Buffer
+		result.Write(m[1])
+		result.WriteString("\tcase \"\":\n\t\t// null, see ogen-fixnullenum.\n\t\treturn nil\n")
+		return result.Bytes()
+	})
+	return fixed, count
+}
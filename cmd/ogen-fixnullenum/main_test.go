@@ -0,0 +1,173 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const enumJSONInput = `package api
+
+// Decode decodes Status from json.
+func (s *Status) Decode(d *jx.Decoder) error {
+	if s == nil {
+		return errors.New("invalid: unable to decode Status to nil")
+	}
+	v, err := d.StrBytes()
+	if err != nil {
+		return err
+	}
+	// Try to use constant string.
+	switch Status(v) {
+	case StatusActive:
+		*s = StatusActive
+	default:
+		*s = Status(v)
+	}
+
+	return nil
+}
+
+// Decode decodes Name from json.
+func (s *Name) Decode(d *jx.Decoder) error {
+	if s == nil {
+		return errors.New("invalid: unable to decode Name to nil")
+	}
+	v, err := d.StrBytes()
+	if err != nil {
+		return err
+	}
+	*s = Name(v)
+
+	return nil
+}
+
+// Decode decodes Status from json.
+func (o *OptStatus) Decode(d *jx.Decoder) error {
+	if o == nil {
+		return errors.New("invalid: unable to decode OptStatus to nil")
+	}
+	o.Set = true
+	if err := o.Value.Decode(d); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Decode decodes User from json.
+func (o *OptUser) Decode(d *jx.Decoder) error {
+	if o == nil {
+		return errors.New("invalid: unable to decode OptUser to nil")
+	}
+	o.Set = true
+	if err := o.Value.Decode(d); err != nil {
+		return err
+	}
+	return nil
+}
+`
+
+const enumValidatorsInput = `package api
+
+func (s Status) Validate() error {
+	switch s {
+	case "active":
+		return nil
+	default:
+		return errors.Errorf("invalid value: %v", s)
+	}
+}
+
+func (s *User) Validate() error {
+	if s == nil {
+		return validate.ErrNilPointer
+	}
+	return nil
+}
+`
+
+func TestFindStringEnums(t *testing.T) {
+	enums := FindStringEnums([]byte(enumJSONInput))
+
+	if len(enums) != 1 || !enums["Status"] {
+		t.Errorf("FindStringEnums() = %v, want only Status", enums)
+	}
+}
+
+func TestFixEnumDecode(t *testing.T) {
+	enums := map[string]bool{"Status": true}
+	fixed, count := FixEnumDecode([]byte(enumJSONInput), enums)
+
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+
+	out := string(fixed)
+	want := "return errors.New(\"invalid: unable to decode Status to nil\")\n\t}\n\tif d.Next() == jx.Null {"
+	if !strings.Contains(out, want) {
+		t.Errorf("Status.Decode missing null check:\n%s", out)
+	}
+	if strings.Count(out, "d.Next() == jx.Null") != 1 {
+		t.Error("only Status.Decode should be fixed")
+	}
+
+	// Running again must not add a second check.
+	again, count := FixEnumDecode(fixed, enums)
+	if count != 0 || string(again) != out {
+		t.Errorf("second run changed %d methods", count)
+	}
+	if !FindStringEnums(fixed)["Status"] {
+		t.Error("fixed Status.Decode no longer recognised as an enum")
+	}
+}
+
+func TestFixOptEnumDecode(t *testing.T) {
+	fixed, count := FixOptEnumDecode([]byte(enumJSONInput), map[string]bool{"Status": true})
+
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+
+	out := string(fixed)
+	want := "unable to decode OptStatus to nil\")\n\t}\n\tif d.Next() == jx.Null {"
+	if !strings.Contains(out, want) {
+		t.Errorf("OptStatus.Decode missing null check:\n%s", out)
+	}
+	if strings.Contains(out, "unable to decode OptUser to nil\")\n\t}\n\tif d.Next()") {
+		t.Error("OptUser is not an enum wrapper and should be left alone")
+	}
+}
+
+func TestFixEnumValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		enums     map[string]bool
+		wantCount int
+	}{
+		{"selected", map[string]bool{"Status": true}, 1},
+		{"not selected", map[string]bool{"Other": true}, 0},
+		{"non-enum type", map[string]bool{"User": true}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixed, count := FixEnumValidate([]byte(enumValidatorsInput), tt.enums)
+			if count != tt.wantCount {
+				t.Errorf("count = %d, want %d", count, tt.wantCount)
+			}
+			hasCase := strings.Contains(string(fixed), "switch s {\n\tcase \"\":\n\t\t// null, see ogen-fixnullenum.\n\t\treturn nil\n\tcase \"active\":")
+			if hasCase != (tt.wantCount > 0) {
+				t.Errorf("empty case present = %v:\n%s", hasCase, fixed)
+			}
+		})
+	}
+}
+
+func TestFixEnumValidateIdempotent(t *testing.T) {
+	enums := map[string]bool{"Status": true}
+	fixed, _ := FixEnumValidate([]byte(enumValidatorsInput), enums)
+	again, count := FixEnumValidate(fixed, enums)
+
+	if count != 0 || string(again) != string(fixed) {
+		t.Errorf("second run changed %d methods", count)
+	}
+}
@@ -0,0 +1,175 @@
+// Command ogen-fixnullobject makes selected object fields in ogen-generated
+// code decode null as the zero struct.
+//
+// Some APIs send "address": null for an object the spec does not declare
+// nullable. ogen decodes the field with the struct's Decode method, which
+// fails on null with `"{" expected`, and the whole response is lost.
+// Accepting null for every object would hide real bugs, so this tool only
+// touches the fields listed in its config.
+//
+// Usage:
+//
+//	ogen-fixnullobject -config nullobjects.json <oas_json_gen.go>
+//
+// The config file maps generated type names to the JSON names of the fields to
+// relax. "*" selects every object field of the type:
+//
+//	{
+//	  "User": ["address"],
+//	  "LegacyOrder": ["*"]
+//	}
+//
+// Field types are read from oas_schemas_gen.go in the same directory. Fields
+// whose type is a struct generated there are supported. Optional objects
+// (Opt*) are handled by ogen-fixnull instead.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/plexusone/ogen-tools/internal/fieldfix"
+)
+
+// Config maps type names to the JSON names of the fields to relax.
+type Config map[string][]string
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixnullobject: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixnullobject", flag.ContinueOnError)
+	configFile := fs.String("config", "", "JSON file mapping type names to field names")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *configFile == "" {
+		return fmt.Errorf("usage: ogen-fixnullobject -config nullobjects.json <oas_json_gen.go>")
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	filename := fs.Arg(0)
+	content, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	fields, err := fieldfix.LoadFieldTypes(filepath.Join(filepath.Dir(filename), "oas_schemas_gen.go"))
+	if err != nil {
+		return err
+	}
+
+	fixed, count, err := FixNullObjectDecode(content, fields, cfg)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Printf("No object fields needed fixing in %s\n", filename)
+		return nil
+	}
+
+	if err := os.WriteFile(filename, fixed, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	fmt.Printf("Fixed %d object fields in %s\n", count, filename)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// FixNullObjectDecode makes the configured object fields decode null as the
+// zero struct.
+//
+// The field decoding:
+//
+//	if err := s.Address.Decode(d); err != nil {
+//
+// becomes:
+//
+//	if d.Next() == jx.Null {
+//		s.Address = Address{}
+//		return d.Null()
+//	}
+//	if err := s.Address.Decode(d); err != nil {
+//
+// It returns an error if a configured type or field does not exist or is
+// not a struct.
+func FixNullObjectDecode(content []byte, fields fieldfix.FieldTypes, cfg Config) ([]byte, int, error) {
+	// The supported types are the structs of the schemas file, except ogen's
+	// Opt, Nil and OptNil wrappers.
+	structTypes := make(map[string]bool)
+	for name, m := range fields {
+		if !isWrapper(m) {
+			structTypes[name] = true
+		}
+	}
+
+	rewriter := fieldfix.Rewriter{
+		Kind:    "struct",
+		Types:   structTypes,
+		Rewrite: nullObjectBlock,
+	}
+	return rewriter.Apply(content, fields, fieldfix.Config(cfg))
+}
+
+// isWrapper reports whether a struct with the given fields is an Opt, Nil or
+// OptNil wrapper: a Value and Set and/or Null flags.
+func isWrapper(fields map[string]string) bool {
+	if _, ok := fields["Value"]; !ok {
+		return false
+	}
+	for name, fieldType := range fields {
+		if name != "Value" && !((name == "Set" || name == "Null") && fieldType == "bool") {
+			return false
+		}
+	}
+	return len(fields) > 1
+}
+
+// objectDecodePattern matches the decoding of an object field, and the null
+// check if an earlier run added it. The field is substituted for %s.
+const objectDecodePattern = `\n(\t+)(if d\.Next\(\) == jx\.Null \{\n[^\n]*\n[^\n]*\n\t+\}\n\t+)?if err := s\.%s\.Decode\(d\); err != nil \{\n`
+
+// nullObjectBlock rewrites one field case to accept null.
+func nullObjectBlock(block []byte, goField, fieldType string) ([]byte, bool) {
+	re := regexp.MustCompile(fmt.Sprintf(objectDecodePattern, regexp.QuoteMeta(goField)))
+	loc := re.FindSubmatchIndex(block)
+	if loc == nil || loc[4] >= 0 {
+		return block, false
+	}
+	indent := string(block[loc[2]:loc[3]])
+	check := "if d.Next() == jx.Null {\n" +
+		indent + "\ts." + goField + " = " + fieldType + "{}\n" +
+		indent + "\treturn d.Null()\n" +
+		indent + "}\n" + indent
+
+	fixed := append([]byte(nil), block[:loc[3]]...)
+	fixed = append(fixed, check...)
+	fixed = append(fixed, block[loc[3]:]...)
+	return fixed, true
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/fieldfix"
+)
+
+const objectDecodeInput = `package api
+
+// Decode decodes User from json.
+func (s *User) Decode(d *jx.Decoder) error {
+	if s == nil {
+		return errors.New("invalid: unable to decode User to nil")
+	}
+	var requiredBitSet [1]uint8
+
+	if err := d.ObjBytes(func(d *jx.Decoder, k []byte) error {
+		switch string(k) {
+		case "address":
+			requiredBitSet[0] |= 1 << 0
+			if err := func() error {
+				if err := s.Address.Decode(d); err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"address\"")
+			}
+		case "billing":
+			if err := func() error {
+				if err := s.Billing.Decode(d); err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"billing\"")
+			}
+		case "nickname":
+			if err := func() error {
+				s.Nickname.Reset()
+				if err := s.Nickname.Decode(d); err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"nickname\"")
+			}
+		default:
+			return d.Skip()
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "decode User")
+	}
+
+	return nil
+}
+`
+
+var objectFieldTypes = fieldfix.FieldTypes{
+	"User": {
+		"Address":  "Address",
+		"Billing":  "Address",
+		"Nickname": "OptString",
+	},
+	"Address": {
+		"Street": "string",
+	},
+	"OptString": {
+		"Value": "string",
+		"Set":   "bool",
+	},
+}
+
+func TestFixNullObjectDecode(t *testing.T) {
+	cfg := Config{"User": {"address"}}
+	fixed, count, err := FixNullObjectDecode([]byte(objectDecodeInput), objectFieldTypes, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+
+	out := string(fixed)
+	want := "\t\t\tif err := func() error {\n" +
+		"\t\t\t\tif d.Next() == jx.Null {\n\t\t\t\t\ts.Address = Address{}\n\t\t\t\t\treturn d.Null()\n\t\t\t\t}\n" +
+		"\t\t\t\tif err := s.Address.Decode(d); err != nil {\n"
+	if !strings.Contains(out, want) {
+		t.Errorf("output missing %q", want)
+	}
+	if strings.Count(out, "jx.Null") != 1 {
+		t.Error("billing should not be changed")
+	}
+
+	again, count, err := FixNullObjectDecode(fixed, objectFieldTypes, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 || string(again) != out {
+		t.Errorf("second run changed %d fields", count)
+	}
+}
+
+func TestFixNullObjectDecodeAllFields(t *testing.T) {
+	fixed, count, err := FixNullObjectDecode([]byte(objectDecodeInput), objectFieldTypes, Config{"User": {"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+	if strings.Contains(string(fixed), "s.Nickname = OptString{}") {
+		t.Error("optional field should be skipped")
+	}
+}
+
+func TestFixNullObjectDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"unknown type", Config{"Other": {"x"}}, "type Other has no generated Decode method"},
+		{"unknown field", Config{"User": {"missing"}}, `User has no field "missing"`},
+		{"optional", Config{"User": {"nickname"}}, "User.nickname is OptString, not a struct"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := FixNullObjectDecode([]byte(objectDecodeInput), objectFieldTypes, tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
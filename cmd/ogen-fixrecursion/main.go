@@ -0,0 +1,201 @@
+// Command ogen-fixrecursion guards ogen-generated Decode and Validate methods
+// against unbounded recursion.
+//
+// Specs with self-referential schemas (trees, linked lists, A -> B -> A cycles)
+// produce generated Decode and Validate methods that recurse once per level of
+// nesting. A crafted input with enough nesting can exhaust the stack; this tool
+// threads a depth counter through the generated calls and returns an error once
+// a configurable limit is exceeded.
+//
+// Usage:
+//
+//	ogen-fixrecursion [-max-depth N] <oas_json_gen.go|oas_validators_gen.go>...
+//
+// Each Decode method is split into a public entry point and a depth-aware
+// implementation:
+//
+//	func (s *Node) Decode(d *jx.Decoder) error {
+//		return s.decodeDepth(d, 0)
+//	}
+//
+//	func (s *Node) decodeDepth(d *jx.Decoder, depth int) error {
+//		if depth > maxDecodeDepth {
+//			return errors.Errorf("decode Node: maximum nesting depth %d exceeded", maxDecodeDepth)
+//		}
+//		...
+//		if err := elem.decodeDepth(d, depth+1); err != nil {
+//
+// Validate methods in oas_validators_gen.go are rewritten the same way using
+// validateDepth and maxValidateDepth.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// defaultMaxDepth is the nesting limit used when -max-depth is not given.
+// Every Opt* wrapper counts as a level, so this is roughly twice the number
+// of JSON object levels that can be decoded.
+const defaultMaxDepth = 256
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixrecursion: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixrecursion", flag.ContinueOnError)
+	maxDepth := fs.Int("max-depth", defaultMaxDepth, "maximum nesting depth before decoding/validation fails")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: ogen-fixrecursion [-max-depth N] <oas_json_gen.go|oas_validators_gen.go>...")
+	}
+	if *maxDepth < 1 {
+		return fmt.Errorf("-max-depth must be positive, got %d", *maxDepth)
+	}
+
+	for _, filename := range fs.Args() {
+		if err := fixFile(filename, *maxDepth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fixFile(filename string, maxDepth int) error {
+	content, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	fixed, decodeCount := FixDecodeRecursion(content, maxDepth)
+	fixed, validateCount := FixValidateRecursion(fixed, maxDepth)
+	count := decodeCount + validateCount
+
+	if count == 0 {
+		fmt.Printf("No Decode or Validate methods needed fixing in %s\n", filename)
+		return nil
+	}
+
+	if err := os.WriteFile(filename, fixed, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	fmt.Printf("Fixed %d Decode and %d Validate methods in %s\n", decodeCount, validateCount, filename)
+	return nil
+}
+
+var (
+	// decodeFuncPattern matches a whole generated Decode method. Generated
+	// methods always close with a "}" in column 0, which ends the body.
+	decodeFuncPattern = regexp.MustCompile(
+		`(?s)func \((\w+) (\*?)(\w+)\) Decode\(d \*jx\.Decoder\) error \{\n(.*?)\n\}\n`)
+
+	// validateFuncPattern matches a whole generated Validate method.
+	validateFuncPattern = regexp.MustCompile(
+		`(?s)func \((\w+) (\*?)(\w+)\) Validate\(\) error \{\n(.*?)\n\}\n`)
+
+	decodeCallPattern   = regexp.MustCompile(`\.Decode\(d\)`)
+	validateCallPattern = regexp.MustCompile(`\.Validate\(\)`)
+)
+
+// FixDecodeRecursion rewrites every generated Decode method so nested Decode
+// calls carry a depth counter, and adds the maxDecodeDepth constant.
+//
+// Files that already contain decodeDepth are left untouched.
+func FixDecodeRecursion(content []byte, maxDepth int) ([]byte, int) {
+	if bytes.Contains(content, []byte("decodeDepth(")) {
+		return content, 0
+	}
+	fixed, count := rewriteMethods(content, decodeFuncPattern, decodeCallPattern, depthRewrite{
+		entry:     "Decode(d *jx.Decoder) error",
+		entryCall: "decodeDepth(d, 0)",
+		impl:      "decodeDepth(d *jx.Decoder, depth int) error",
+		nested:    ".decodeDepth(d, depth+1)",
+		limit:     "maxDecodeDepth",
+		verb:      "decode",
+	})
+	if count > 0 {
+		fixed = appendLimit(fixed, "maxDecodeDepth", "Decode", maxDepth)
+	}
+	return fixed, count
+}
+
+// FixValidateRecursion rewrites every generated Validate method so nested
+// Validate calls carry a depth counter, and adds the maxValidateDepth constant.
+//
+// Files that already contain validateDepth are left untouched.
+func FixValidateRecursion(content []byte, maxDepth int) ([]byte, int) {
+	if bytes.Contains(content, []byte("validateDepth(")) {
+		return content, 0
+	}
+	fixed, count := rewriteMethods(content, validateFuncPattern, validateCallPattern, depthRewrite{
+		entry:     "Validate() error",
+		entryCall: "validateDepth(0)",
+		impl:      "validateDepth(depth int) error",
+		nested:    ".validateDepth(depth + 1)",
+		limit:     "maxValidateDepth",
+		verb:      "validate",
+	})
+	if count > 0 {
+		fixed = appendLimit(fixed, "maxValidateDepth", "Validate", maxDepth)
+	}
+	return fixed, count
+}
+
+// depthRewrite describes how one family of methods is split into an entry
+// point and a depth-aware implementation.
+type depthRewrite struct {
+	entry     string // signature of the public method
+	entryCall string // call from the public method into the implementation
+	impl      string // signature of the depth-aware implementation
+	nested    string // replacement for nested calls inside the implementation
+	limit     string // name of the limit constant
+	verb      string // used in the error message
+}
+
+func rewriteMethods(content []byte, funcPattern, callPattern *regexp.Regexp, rw depthRewrite) ([]byte, int) {
+	count := 0
+	fixed := funcPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		submatches := funcPattern.FindSubmatch(match)
+		if len(submatches) < 5 {
+			return match
+		}
+		count++
+
+		recv := string(submatches[1])
+		star := string(submatches[2])
+		typeName := string(submatches[3])
+		body := callPattern.ReplaceAll(submatches[4], []byte(rw.nested))
+
+		var result bytes.Buffer
+		fmt.Fprintf(&result, "func (%s %s%s) %s {\n", recv, star, typeName, rw.entry)
+		fmt.Fprintf(&result, "\treturn %s.%s\n}\n\n", recv, rw.entryCall)
+		fmt.Fprintf(&result, "func (%s %s%s) %s {\n", recv, star, typeName, rw.impl)
+		fmt.Fprintf(&result, "\tif depth > %s {\n", rw.limit)
+		fmt.Fprintf(&result, "\t\treturn errors.Errorf(\"%s %s: maximum nesting depth %%d exceeded\", %s)\n", rw.verb, typeName, rw.limit)
+		result.WriteString("\t}\n")
+		result.Write(body)
+		result.WriteString("\n}\n")
+		return result.Bytes()
+	})
+	return fixed, count
+}
+
+// appendLimit adds the limit constant to the end of the file.
+func appendLimit(content []byte, name, method string, maxDepth int) []byte {
+	var result bytes.Buffer
+	result.Write(bytes.TrimRight(content, "\n"))
+	fmt.Fprintf(&result, "\n\n// %s limits how deeply nested %s calls may recurse.\n", name, method)
+	fmt.Fprintf(&result, "// Added by ogen-fixrecursion.\nconst %s = %d\n", name, maxDepth)
+	return result.Bytes()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const recursiveDecodeInput = `package api
+
+// Decode decodes Node from json.
+func (s *Node) Decode(d *jx.Decoder) error {
+	if s == nil {
+		return errors.New("invalid: unable to decode Node to nil")
+	}
+
+	if err := d.ObjBytes(func(d *jx.Decoder, k []byte) error {
+		switch string(k) {
+		case "parent":
+			if err := func() error {
+				s.Parent.Reset()
+				if err := s.Parent.Decode(d); err != nil {
+					return err
+				}
+				return nil
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"parent\"")
+			}
+		default:
+			return d.Skip()
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "decode Node")
+	}
+
+	return nil
+}
+
+// UnmarshalJSON implements stdjson.Unmarshaler.
+func (s *Node) UnmarshalJSON(data []byte) error {
+	d := jx.DecodeBytes(data)
+	return s.Decode(d)
+}
+`
+
+func TestFixDecodeRecursion(t *testing.T) {
+	fixed, count := FixDecodeRecursion([]byte(recursiveDecodeInput), 10)
+
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+
+	out := string(fixed)
+	wants := []string{
+		"func (s *Node) Decode(d *jx.Decoder) error {\n\treturn s.decodeDepth(d, 0)\n}",
+		"func (s *Node) decodeDepth(d *jx.Decoder, depth int) error {\n\tif depth > maxDecodeDepth {",
+		`return errors.Errorf("decode Node: maximum nesting depth %d exceeded", maxDecodeDepth)`,
+		"s.Parent.decodeDepth(d, depth+1)",
+		"const maxDecodeDepth = 10",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Entry points outside Decode bodies keep calling Decode.
+	if !strings.Contains(out, "return s.Decode(d)\n}") {
+		t.Error("UnmarshalJSON should still call Decode")
+	}
+}
+
+func TestFixDecodeRecursion_Idempotent(t *testing.T) {
+	once, _ := FixDecodeRecursion([]byte(recursiveDecodeInput), 10)
+	twice, count := FixDecodeRecursion(once, 10)
+
+	if count != 0 {
+		t.Errorf("second run count = %d, want 0", count)
+	}
+	if string(once) != string(twice) {
+		t.Error("second run modified already fixed content")
+	}
+}
+
+func TestFixValidateRecursion(t *testing.T) {
+	input := `package api
+
+func (s *Node) Validate() error {
+	if s == nil {
+		return validate.ErrNilPointer
+	}
+
+	var failures []validate.FieldError
+	for i, elem := range s.Children {
+		if err := elem.Validate(); err != nil {
+			failures = append(failures, validate.FieldError{
+				Name:  fmt.Sprintf("[%d]", i),
+				Error: err,
+			})
+		}
+	}
+	if err := (validate.Int{}).Validate(int64(s.Value)); err != nil {
+		return errors.Wrap(err, "int")
+	}
+	if len(failures) > 0 {
+		return &validate.Error{Fields: failures}
+	}
+	return nil
+}
+
+func (s Status) Validate() error {
+	switch s {
+	case "active":
+		return nil
+	default:
+		return errors.Errorf("invalid value: %v", s)
+	}
+}
+`
+
+	fixed, count := FixValidateRecursion([]byte(input), 32)
+
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	out := string(fixed)
+	wants := []string{
+		"func (s *Node) Validate() error {\n\treturn s.validateDepth(0)\n}",
+		"func (s Status) Validate() error {\n\treturn s.validateDepth(0)\n}",
+		"elem.validateDepth(depth + 1)",
+		"const maxValidateDepth = 32",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Calls into the validate package take arguments and must not be touched.
+	if !strings.Contains(out, "(validate.Int{}).Validate(int64(s.Value))") {
+		t.Error("validate package call was rewritten")
+	}
+}
+
+func TestFixRecursion_NoMethods(t *testing.T) {
+	input := []byte("package api\n\nfunc foo() {}\n")
+
+	if _, count := FixDecodeRecursion(input, 10); count != 0 {
+		t.Errorf("decode count = %d, want 0", count)
+	}
+	if _, count := FixValidateRecursion(input, 10); count != 0 {
+		t.Errorf("validate count = %d, want 0", count)
+	}
+}
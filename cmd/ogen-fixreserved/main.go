@@ -0,0 +1,322 @@
+// Command ogen-fixreserved fixes how ogen clients encode reserved characters
+// in path parameters, and honors allowReserved on query parameters.
+//
+// ogen escapes path parameters with url.PathEscape, which leaves "." and ".."
+// as they are: an ID of ".." turns /files/{id}/content into /files/../content,
+// which proxies and servers normalize to /content. It also ignores
+// allowReserved, escaping the reserved characters of every query parameter.
+//
+// This tool makes the client encode dot segments as %2E, and, with -spec,
+// leave reserved characters unescaped in allowReserved query parameters:
+//
+//	GET /search?path=/docs/api:v2
+//
+// With -strict, path parameters also escape the reserved characters
+// url.PathEscape keeps, such as : and =, and the client fails on empty and dot
+// segments instead of sending them encoded.
+//
+// Usage:
+//
+//	ogen-fixreserved [-spec openapi.json] [-strict] <generated-dir>
+//
+// The helpers are written to oas_reserved_gen.go.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// outputFile is the file the helpers are written to.
+const outputFile = "oas_reserved_gen.go"
+
+const clientFile = "oas_client_gen.go"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixreserved: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixreserved", flag.ContinueOnError)
+	specFile := fs.String("spec", "", "OpenAPI spec with the allowReserved query parameters")
+	strict := fs.Bool("strict", false, "escape every reserved character in path parameters and reject empty and dot segments")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: ogen-fixreserved [-spec openapi.json] [-strict] <generated-dir>")
+	}
+
+	var allowReserved map[string][]string
+	if *specFile != "" {
+		spec, err := specdoc.ReadFileMap(*specFile)
+		if err != nil {
+			return err
+		}
+		allowReserved = AllowReserved(spec)
+	}
+
+	dir := fs.Arg(0)
+	client, err := os.ReadFile(filepath.Join(dir, clientFile)) // #nosec G703 -- CLI tool, filename from trusted args
+	if os.IsNotExist(err) {
+		fmt.Printf("No client found in %s\n", dir)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	// The output file stays in: parameters fixed by an earlier run call its
+	// helpers.
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"escapePathSegment", "encodeQueryAllowReserved"} {
+		obj := pkg.Types.Scope().Lookup(name)
+		if obj != nil && filepath.Base(pkg.Fset.Position(obj.Pos()).Filename) != outputFile {
+			return fmt.Errorf("%s is already declared", name)
+		}
+	}
+
+	client, paths := EscapePathParams(client)
+	client, queries := AllowReservedQueries(client, allowReserved)
+	if paths == 0 && queries == 0 && pkg.Types.Scope().Lookup("escapePathSegment") == nil {
+		fmt.Printf("No path or allowReserved query parameters found in %s\n", dir)
+		return nil
+	}
+
+	client, err = format.Source(client)
+	if err != nil {
+		return fmt.Errorf("format %s: %w", clientFile, err)
+	}
+	// #nosec G703 -- CLI tool, filename from trusted args
+	if err := os.WriteFile(filepath.Join(pkg.Dir, clientFile), client, 0600); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	gen := gopkg.NewGenerated("ogen-fixreserved", pkg.Types)
+	writeHelpers(gen, *strict)
+	if err := gen.WriteFile(filepath.Join(pkg.Dir, outputFile)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Fixed %d path parameters and %d allowReserved queries in %s\n", paths, queries, dir)
+	return nil
+}
+
+// AllowReserved maps "METHOD /path" to the names of the query parameters of
+// the operation that allow reserved characters.
+func AllowReserved(spec map[string]any) map[string][]string {
+	components, _ := spec["components"].(map[string]any)
+	shared, _ := components["parameters"].(map[string]any)
+	resolve := func(p any) map[string]any {
+		m, _ := p.(map[string]any)
+		if ref, ok := m["$ref"].(string); ok {
+			m, _ = shared[strings.TrimPrefix(ref, "#/components/parameters/")].(map[string]any)
+		}
+		return m
+	}
+
+	result := make(map[string][]string)
+	paths, _ := spec["paths"].(map[string]any)
+	for path, item := range paths {
+		item, _ := item.(map[string]any)
+		common, _ := item["parameters"].([]any)
+		for method, op := range item {
+			op, ok := op.(map[string]any)
+			if !ok || method == "parameters" {
+				continue
+			}
+			params, _ := op["parameters"].([]any)
+			// Operation parameters override path item parameters of the
+			// same name and location.
+			reserved := make(map[string]bool)
+			for _, p := range append(append([]any{}, common...), params...) {
+				p := resolve(p)
+				if p["in"] != "query" {
+					continue
+				}
+				name, _ := p["name"].(string)
+				allow, _ := p["allowReserved"].(bool)
+				reserved[name] = allow
+			}
+			var names []string
+			for name, allow := range reserved {
+				if allow {
+					names = append(names, name)
+				}
+			}
+			if len(names) > 0 {
+				sort.Strings(names)
+				result[strings.ToUpper(method)+" "+path] = names
+			}
+		}
+	}
+	return result
+}
+
+// pathParamPattern matches the encoding of a path parameter with the simple
+// style and a single value, up to the assignment of the encoded value.
+var pathParamPattern = regexp.MustCompile(
+	`(?s)\n(\t+)e := uri\.NewPathEncoder\(uri\.PathEncoderConfig\{\n\t+Param: +"[^"]*",\n\t+Style: +uri\.PathStyleSimple,\n` +
+		`[^{}]*?\}\)\n\t+if err := func\(\) error \{\n\t+return e\.EncodeValue\([^\n]*\)\n\t+\}\(\); err != nil \{\n` +
+		`.*?\n\t+encoded, err := e\.Result\(\)\n\t+if err != nil \{\n\t+return res, errors\.Wrap\(err, "encode path"\)\n\t+\}\n` +
+		`\t+(pathParts\[\d+\]) = encoded\n`)
+
+// EscapePathParams makes the client pass every single-valued simple path
+// parameter through escapePathSegment. It returns the number of parameters
+// changed.
+func EscapePathParams(client []byte) ([]byte, int) {
+	count := 0
+	client = pathParamPattern.ReplaceAllFunc(client, func(match []byte) []byte {
+		m := pathParamPattern.FindSubmatch(match)
+		indent, part := string(m[1]), string(m[2])
+		assign := fmt.Sprintf("%s%s = encoded\n", indent, part)
+		count++
+		return append(match[:len(match)-len(assign):len(match)-len(assign)], fmt.Sprintf(
+			"%s%s, err = escapePathSegment(encoded)\n%sif err != nil {\n%s\treturn res, errors.Wrap(err, \"encode path\")\n%s}\n",
+			indent, part, indent, indent, indent)...)
+	})
+	return client, count
+}
+
+var (
+	sendPattern       = regexp.MustCompile(`(?ms)^func \(c \*Client\) send\w+\(.*?^\}\n`)
+	operationPattern  = regexp.MustCompile(`semconv\.HTTPRequestMethodKey\.String\("(\w+)"\),\n\t+semconv\.URLTemplateKey\.String\(("(?:[^"\\]|\\.)*")\)`)
+	rawQueryStatement = "\tu.RawQuery = q.Values().Encode()\n"
+)
+
+// AllowReservedQueries makes the send methods of the operations in
+// allowReserved, keyed by "METHOD /path", encode their query with
+// encodeQueryAllowReserved. It returns the number of methods changed.
+func AllowReservedQueries(client []byte, allowReserved map[string][]string) ([]byte, int) {
+	count := 0
+	client = sendPattern.ReplaceAllFunc(client, func(send []byte) []byte {
+		m := operationPattern.FindSubmatch(send)
+		if m == nil {
+			return send
+		}
+		path, err := strconv.Unquote(string(m[2]))
+		if err != nil {
+			return send
+		}
+		names := allowReserved[string(m[1])+" "+path]
+		if len(names) == 0 || !strings.Contains(string(send), rawQueryStatement) {
+			return send
+		}
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = strconv.Quote(name)
+		}
+		count++
+		return []byte(strings.Replace(string(send), rawQueryStatement,
+			fmt.Sprintf("\tu.RawQuery = encodeQueryAllowReserved(q.Values(), %s)\n", strings.Join(quoted, ", ")), 1))
+	})
+	return client, count
+}
+
+// queryReserved are the reserved characters of RFC 3986 kept in allowReserved
+// query values. It leaves out & = + and #, which would change how the query
+// parses.
+const queryReserved = ":/?@!$'()*,;[]"
+
+// writeHelpers writes escapePathSegment and encodeQueryAllowReserved.
+func writeHelpers(gen *gopkg.Generated, strict bool) {
+	gen.Import("net/url", "url")
+	gen.Import("sort", "sort")
+	gen.Import("strings", "strings")
+
+	if strict {
+		gen.Import("github.com/go-faster/errors", "errors")
+		gen.Printf(`// escapePathSegment escapes the reserved characters url.PathEscape leaves in
+// an encoded path parameter, and rejects empty and dot segments, which no
+// encoding reliably keeps from being removed by path normalization.
+func escapePathSegment(encoded string) (string, error) {
+	switch encoded {
+	case "", ".", "..":
+		return "", errors.Errorf("path parameter %%q is not a valid segment", encoded)
+	}
+	return pathReservedEscaper.Replace(encoded), nil
+}
+
+var pathReservedEscaper = strings.NewReplacer(%s)
+
+`, replacerArgs("$&+:=@", false))
+	} else {
+		gen.Printf(`// escapePathSegment percent-encodes the dots of "." and ".." path
+// parameters, which would otherwise be removed by path normalization.
+func escapePathSegment(encoded string) (string, error) {
+	switch encoded {
+	case ".", "..":
+		return strings.ReplaceAll(encoded, ".", "%%2E"), nil
+	}
+	return encoded, nil
+}
+
+`)
+	}
+
+	gen.Printf(`// encodeQueryAllowReserved encodes v like url.Values.Encode, but leaves the
+// reserved characters %s unescaped in the values of
+// the allowReserved parameters.
+func encodeQueryAllowReserved(v url.Values, allowReserved ...string) string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		keep := false
+		for _, name := range allowReserved {
+			keep = keep || name == k
+		}
+		for _, val := range v[k] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(k))
+			b.WriteByte('=')
+			val = url.QueryEscape(val)
+			if keep {
+				val = queryReservedUnescaper.Replace(val)
+			}
+			b.WriteString(val)
+		}
+	}
+	return b.String()
+}
+
+var queryReservedUnescaper = strings.NewReplacer(%s)
+`, queryReserved, replacerArgs(queryReserved, true))
+}
+
+// replacerArgs returns the strings.NewReplacer arguments escaping each of
+// chars, or unescaping them.
+func replacerArgs(chars string, unescape bool) string {
+	var args []string
+	for _, c := range chars {
+		escaped := fmt.Sprintf("%%%02X", c)
+		if unescape {
+			args = append(args, strconv.Quote(escaped), strconv.Quote(string(c)))
+		} else {
+			args = append(args, strconv.Quote(string(c)), strconv.Quote(escaped))
+		}
+	}
+	return strings.Join(args, ", ")
+}
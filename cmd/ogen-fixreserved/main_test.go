@@ -0,0 +1,144 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const clientSource = `package api
+
+func (c *Client) sendGetThing(ctx context.Context, params GetThingParams) (res *GetThingOK, err error) {
+	otelAttrs := []attribute.KeyValue{
+		otelogen.OperationID("getThing"),
+		semconv.HTTPRequestMethodKey.String("GET"),
+		semconv.URLTemplateKey.String("/things/{id}"),
+	}
+
+	stage = "BuildURL"
+	u := uri.Clone(c.requestURL(ctx))
+	var pathParts [2]string
+	pathParts[0] = "/things/"
+	{
+		// Encode "id" parameter.
+		e := uri.NewPathEncoder(uri.PathEncoderConfig{
+			Param:   "id",
+			Style:   uri.PathStyleSimple,
+			Explode: false,
+		})
+		if err := func() error {
+			return e.EncodeValue(conv.StringToString(params.ID))
+		}(); err != nil {
+			return res, errors.Wrap(err, "encode path")
+		}
+		encoded, err := e.Result()
+		if err != nil {
+			return res, errors.Wrap(err, "encode path")
+		}
+		pathParts[1] = encoded
+	}
+	uri.AddPathParts(u, pathParts[:]...)
+
+	stage = "EncodeQueryParams"
+	q := uri.NewQueryEncoder()
+	u.RawQuery = q.Values().Encode()
+	return res, nil
+}
+
+func (c *Client) sendListThings(ctx context.Context, params ListThingsParams) (res *ListThingsOK, err error) {
+	otelAttrs := []attribute.KeyValue{
+		otelogen.OperationID("listThings"),
+		semconv.HTTPRequestMethodKey.String("GET"),
+		semconv.URLTemplateKey.String("/things"),
+	}
+
+	stage = "EncodeQueryParams"
+	q := uri.NewQueryEncoder()
+	u.RawQuery = q.Values().Encode()
+	return res, nil
+}
+`
+
+func TestAllowReserved(t *testing.T) {
+	spec := map[string]any{
+		"components": map[string]any{
+			"parameters": map[string]any{
+				"Filter": map[string]any{"name": "filter", "in": "query", "allowReserved": true},
+			},
+		},
+		"paths": map[string]any{
+			"/things": map[string]any{
+				"parameters": []any{
+					map[string]any{"name": "path", "in": "query", "allowReserved": true},
+					map[string]any{"name": "plain", "in": "query", "allowReserved": true},
+				},
+				"get": map[string]any{
+					"parameters": []any{
+						map[string]any{"$ref": "#/components/parameters/Filter"},
+						// Overrides the path item parameter.
+						map[string]any{"name": "plain", "in": "query"},
+						map[string]any{"name": "id", "in": "header", "allowReserved": true},
+					},
+				},
+			},
+			"/things/{id}": map[string]any{
+				"get": map[string]any{},
+			},
+		},
+	}
+	want := map[string][]string{"GET /things": {"filter", "path"}}
+	if got := AllowReserved(spec); !reflect.DeepEqual(got, want) {
+		t.Errorf("AllowReserved() = %v, want %v", got, want)
+	}
+}
+
+func TestEscapePathParams(t *testing.T) {
+	got, n := EscapePathParams([]byte(clientSource))
+	if n != 1 {
+		t.Errorf("EscapePathParams() changed %d parameters, want 1", n)
+	}
+	want := "\t\tpathParts[1], err = escapePathSegment(encoded)\n\t\tif err != nil {\n\t\t\treturn res, errors.Wrap(err, \"encode path\")\n\t\t}\n\t}\n"
+	if !strings.Contains(string(got), want) {
+		t.Errorf("output missing %q:\n%s", want, got)
+	}
+
+	// Running again changes nothing.
+	if again, n := EscapePathParams(got); n != 0 || string(again) != string(got) {
+		t.Errorf("second EscapePathParams() changed %d parameters", n)
+	}
+}
+
+func TestEscapePathParams_LabelStyle(t *testing.T) {
+	// Label and matrix parameters start with a reserved character; they are
+	// left alone.
+	source := strings.Replace(clientSource, "uri.PathStyleSimple", "uri.PathStyleLabel", 1)
+	if _, n := EscapePathParams([]byte(source)); n != 0 {
+		t.Errorf("EscapePathParams() changed %d label parameters", n)
+	}
+}
+
+func TestAllowReservedQueries(t *testing.T) {
+	got, n := AllowReservedQueries([]byte(clientSource), map[string][]string{
+		"GET /things":       {"filter", "path"},
+		"POST /things/{id}": {"q"},
+	})
+	if n != 1 {
+		t.Errorf("AllowReservedQueries() changed %d methods, want 1", n)
+	}
+	if c := strings.Count(string(got), rawQueryStatement); c != 1 {
+		t.Errorf("output has %d plain query encodings, want 1", c)
+	}
+	want := "\tu.RawQuery = encodeQueryAllowReserved(q.Values(), \"filter\", \"path\")\n\treturn res, nil\n}\n"
+	if !strings.HasSuffix(string(got), want) {
+		t.Errorf("output missing %q:\n%s", want, got)
+	}
+}
+
+func TestReplacerArgs(t *testing.T) {
+	if got, want := replacerArgs(":/", false), `":", "%3A", "/", "%2F"`; got != want {
+		t.Errorf("replacerArgs(escape) = %s, want %s", got, want)
+	}
+	if got, want := replacerArgs(":/", true), `"%3A", ":", "%2F", "/"`; got != want {
+		t.Errorf("replacerArgs(unescape) = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,314 @@
+// Command ogen-fixstream makes ogen clients stream binary downloads instead
+// of buffering them in memory.
+//
+// ogen decodes an application/octet-stream response by reading the whole
+// body into a bytes.Reader before returning it, so a multi-gigabyte download
+// needs as much memory. This tool passes the response body through instead,
+// and gives the response types a Close method, so they are io.ReadClosers:
+//
+//	res, err := client.DownloadFile(ctx, params)
+//	if err != nil {
+//		return err
+//	}
+//	defer res.Close()
+//	_, err = io.Copy(f, res)
+//
+// The caller must close streamed responses. Other responses of the same
+// operations, such as JSON errors, are still closed by the client.
+//
+// Usage:
+//
+//	ogen-fixstream [-content-types application/octet-stream,...] <generated-dir>
+//
+// The methods are written to oas_stream_gen.go.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/format"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the methods are written to.
+const outputFile = "oas_stream_gen.go"
+
+const (
+	decodersFile = "oas_response_decoders_gen.go"
+	clientFile   = "oas_client_gen.go"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixstream: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixstream", flag.ContinueOnError)
+	contentTypes := fs.String("content-types", "application/octet-stream", "comma-separated content types to stream")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: ogen-fixstream [-content-types application/octet-stream,...] <generated-dir>")
+	}
+
+	streamed := make(map[string]bool)
+	for _, ct := range strings.Split(*contentTypes, ",") {
+		if ct = strings.TrimSpace(ct); ct != "" {
+			streamed[ct] = true
+		}
+	}
+
+	dir := fs.Arg(0)
+	decoders, err := os.ReadFile(filepath.Join(dir, decodersFile)) // #nosec G703 -- CLI tool, filename from trusted args
+	if os.IsNotExist(err) {
+		fmt.Printf("No response decoders found in %s\n", dir)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	client, err := os.ReadFile(filepath.Join(dir, clientFile)) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	streams := FindStreams(decoders, streamed)
+	if len(streams) == 0 {
+		fmt.Printf("No buffered downloads found in %s\n", dir)
+		return nil
+	}
+
+	pkg, err := gopkg.Load(dir, outputFile)
+	if err != nil {
+		return err
+	}
+	gen, err := GenerateClose(pkg, streams)
+	if err != nil {
+		return err
+	}
+
+	ops := make(map[string]bool)
+	for _, s := range streams {
+		ops[s.Operation] = true
+	}
+	client, patched := DeferClose(client, ops)
+	if patched != len(ops) {
+		return fmt.Errorf("found %d of %d send methods in %s", patched, len(ops), clientFile)
+	}
+
+	for name, content := range map[string][]byte{
+		decodersFile: PassBody(decoders, streams),
+		clientFile:   client,
+	} {
+		content, err := format.Source(content)
+		if err != nil {
+			return fmt.Errorf("format %s: %w", name, err)
+		}
+		// #nosec G703 -- CLI tool, filename from trusted args
+		if err := os.WriteFile(filepath.Join(pkg.Dir, name), content, 0600); err != nil {
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+	if err := gen.WriteFile(filepath.Join(pkg.Dir, outputFile)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Streamed %d downloads of %d operations in %s\n", len(streams), len(ops), dir)
+	return nil
+}
+
+// Stream is a response decoded by buffering its body into an io.Reader.
+type Stream struct {
+	// Operation is the Go name of the operation, e.g. DownloadFile.
+	Operation string
+	// Type is the response type with the Data field.
+	Type string
+	// Wrapper is the type holding Type in its Response field when the
+	// response has headers, or empty.
+	Wrapper string
+
+	// start and end delimit the buffering code.
+	start, end int
+	indent     string
+}
+
+var (
+	decoderPattern     = regexp.MustCompile(`(?m)^func decode(\w+)Response\(`)
+	contentCasePattern = regexp.MustCompile(`(?m)^\t+case ct == "([^"]+)":\n`)
+	bufferPattern      = regexp.MustCompile(
+		`^(\t+)reader := resp\.Body\n\t+b, err := io\.ReadAll\(reader\)\n\t+if err != nil \{\n\t+return res, err\n\t+\}\n\n` +
+			`\t+response := (\w+)\{Data: bytes\.NewReader\(b\)\}\n(?:\t+var wrapper (\w+)\n)?`)
+)
+
+// FindStreams finds the buffered responses of the given content types in
+// the response decoders.
+func FindStreams(decoders []byte, contentTypes map[string]bool) []Stream {
+	funcs := decoderPattern.FindAllSubmatchIndex(decoders, -1)
+	var streams []Stream
+	for _, m := range contentCasePattern.FindAllSubmatchIndex(decoders, -1) {
+		if !contentTypes[string(decoders[m[2]:m[3]])] {
+			continue
+		}
+		b := bufferPattern.FindSubmatchIndex(decoders[m[1]:])
+		if b == nil {
+			continue
+		}
+
+		var op string
+		for _, f := range funcs {
+			if f[0] < m[0] {
+				op = string(decoders[f[2]:f[3]])
+			}
+		}
+		s := Stream{
+			Operation: op,
+			Type:      string(decoders[m[1]+b[4] : m[1]+b[5]]),
+			start:     m[1],
+			indent:    string(decoders[m[1]+b[2] : m[1]+b[3]]),
+		}
+		if b[6] >= 0 {
+			s.Wrapper = string(decoders[m[1]+b[6] : m[1]+b[7]])
+		}
+		// The wrapper declaration stays.
+		s.end = m[1] + b[1]
+		if b[6] >= 0 {
+			s.end = lineStart(decoders, m[1]+b[6])
+		}
+		streams = append(streams, s)
+	}
+	return streams
+}
+
+func lineStart(content []byte, i int) int {
+	for i > 0 && content[i-1] != '\n' {
+		i--
+	}
+	return i
+}
+
+// PassBody replaces the buffering of each stream with the response body.
+func PassBody(decoders []byte, streams []Stream) []byte {
+	var out []byte
+	last := 0
+	for _, s := range streams {
+		out = append(out, decoders[last:s.start]...)
+		out = append(out, fmt.Sprintf("%sresponse := %s{Data: resp.Body}\n", s.indent, s.Type)...)
+		last = s.end
+	}
+	out = append(out, decoders[last:]...)
+	return removeUnusedImport(out, "bytes")
+}
+
+// removeUnusedImport drops a standard library import once the file no
+// longer refers to it.
+func removeUnusedImport(content []byte, name string) []byte {
+	if regexp.MustCompile(`\b` + name + `\.`).Match(content) {
+		return content
+	}
+	return []byte(strings.Replace(string(content), "\t\""+name+"\"\n", "", 1))
+}
+
+// sendPattern matches a send method of the client up to its deferred close.
+var sendPattern = regexp.MustCompile(
+	`(?ms)^func \(c \*Client\) send(\w+)\(.*?^\tbody := resp\.Body\n\tdefer body\.Close\(\)\n`)
+
+// deferredClose leaves streamed responses open for the caller.
+const deferredClose = `	body := resp.Body
+	defer func() {
+		// Streamed responses are closed by the caller.
+		if _, ok := any(res).(io.Closer); !ok || err != nil {
+			body.Close()
+		}
+	}()
+`
+
+// DeferClose makes the send methods of the given operations keep the
+// response body open when they return a stream. It returns the number of
+// methods changed.
+func DeferClose(client []byte, ops map[string]bool) ([]byte, int) {
+	count := 0
+	client = sendPattern.ReplaceAllFunc(client, func(match []byte) []byte {
+		op := string(sendPattern.FindSubmatch(match)[1])
+		if !ops[op] {
+			return match
+		}
+		count++
+		tail := "\tbody := resp.Body\n\tdefer body.Close()\n"
+		return append(match[:len(match)-len(tail):len(match)-len(tail)], deferredClose...)
+	})
+	if count > 0 {
+		client = gopkg.AddImports(client, "io")
+	}
+	return client, count
+}
+
+// GenerateClose generates Close methods for the stream types, closing Data,
+// and Read and Close methods for their header wrappers, delegating to
+// Response.
+func GenerateClose(pkg *gopkg.Package, streams []Stream) (*gopkg.Generated, error) {
+	streamTypes := make(map[string]bool)
+	wrappers := make(map[string]bool)
+	for _, s := range streams {
+		streamTypes[s.Type] = true
+		if s.Wrapper != "" {
+			wrappers[s.Wrapper] = true
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-fixstream", pkg.Types)
+	gen.Import("io", "io")
+	for _, name := range sortedKeys(streamTypes) {
+		if err := checkClose(pkg, name, "Close"); err != nil {
+			return nil, err
+		}
+		gen.Printf("// Close closes the response body Data streams from, if it is a closer.\n")
+		gen.Printf("func (s %s) Close() error {\n", name)
+		gen.Printf("\tif c, ok := s.Data.(io.Closer); ok {\n\t\treturn c.Close()\n\t}\n\treturn nil\n}\n\n")
+	}
+	for _, name := range sortedKeys(wrappers) {
+		if err := checkClose(pkg, name, "Read", "Close"); err != nil {
+			return nil, err
+		}
+		gen.Printf("// Read reads from the response body Response streams from.\n")
+		gen.Printf("func (s *%s) Read(p []byte) (int, error) {\n\treturn s.Response.Read(p)\n}\n\n", name)
+		gen.Printf("// Close closes the response body Response streams from.\n")
+		gen.Printf("func (s *%s) Close() error {\n\treturn s.Response.Close()\n}\n\n", name)
+	}
+	return gen, nil
+}
+
+// checkClose reports an error if the named type is missing or already has
+// one of the methods.
+func checkClose(pkg *gopkg.Package, name string, methods ...string) error {
+	obj, ok := pkg.Types.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return fmt.Errorf("type %s not found", name)
+	}
+	for _, method := range methods {
+		if m, _, _ := types.LookupFieldOrMethod(types.NewPointer(obj.Type()), true, pkg.Types, method); m != nil {
+			return fmt.Errorf("%s.%s is already declared", name, method)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const schemasSource = `package api
+
+import "io"
+
+type DownloadOK struct {
+	Data io.Reader
+}
+
+func (s DownloadOK) Read(p []byte) (n int, err error) {
+	if s.Data == nil {
+		return 0, io.EOF
+	}
+	return s.Data.Read(p)
+}
+
+type DownloadOKHeaders struct {
+	ETag     string
+	Response DownloadOK
+}
+
+type DownloadNotFound struct{}
+
+type DownloadRes interface{ downloadRes() }
+
+func (*DownloadOKHeaders) downloadRes() {}
+func (*DownloadNotFound) downloadRes()  {}
+
+type GetNoteOK struct {
+	Data io.Reader
+}
+`
+
+const decodersSource = `package api
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/go-faster/errors"
+	"github.com/ogen-go/ogen/validate"
+)
+
+func decodeDownloadResponse(resp *http.Response) (res DownloadRes, _ error) {
+	switch resp.StatusCode {
+	case 200:
+		// Code 200.
+		ct, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err != nil {
+			return res, errors.Wrap(err, "parse media type")
+		}
+		switch {
+		case ct == "application/octet-stream":
+			reader := resp.Body
+			b, err := io.ReadAll(reader)
+			if err != nil {
+				return res, err
+			}
+
+			response := DownloadOK{Data: bytes.NewReader(b)}
+			var wrapper DownloadOKHeaders
+			wrapper.Response = response
+			wrapper.ETag = resp.Header.Get("ETag")
+			return &wrapper, nil
+		default:
+			return res, validate.InvalidContentType(ct)
+		}
+	case 404:
+		// Code 404.
+		return &DownloadNotFound{}, nil
+	}
+	return res, validate.UnexpectedStatusCodeWithResponse(resp)
+}
+
+func decodeGetNoteResponse(resp *http.Response) (res GetNoteOK, _ error) {
+	switch resp.StatusCode {
+	case 200:
+		// Code 200.
+		ct, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err != nil {
+			return res, errors.Wrap(err, "parse media type")
+		}
+		switch {
+		case ct == "text/plain":
+			reader := resp.Body
+			b, err := io.ReadAll(reader)
+			if err != nil {
+				return res, err
+			}
+
+			response := GetNoteOK{Data: bytes.NewReader(b)}
+			return response, nil
+		default:
+			return res, validate.InvalidContentType(ct)
+		}
+	}
+	return res, validate.UnexpectedStatusCodeWithResponse(resp)
+}
+`
+
+const clientSource = `package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-faster/errors"
+)
+
+type Client struct {
+	client *http.Client
+}
+
+func (c *Client) sendDownload(ctx context.Context) (res DownloadRes, err error) {
+	r, err := http.NewRequestWithContext(ctx, "GET", "/download", nil)
+	if err != nil {
+		return res, errors.Wrap(err, "create request")
+	}
+
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return res, errors.Wrap(err, "do request")
+	}
+	body := resp.Body
+	defer body.Close()
+
+	result, err := decodeDownloadResponse(resp)
+	if err != nil {
+		return res, errors.Wrap(err, "decode response")
+	}
+
+	return result, nil
+}
+
+func (c *Client) sendGetNote(ctx context.Context) (res GetNoteOK, err error) {
+	r, err := http.NewRequestWithContext(ctx, "GET", "/note", nil)
+	if err != nil {
+		return res, errors.Wrap(err, "create request")
+	}
+
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return res, errors.Wrap(err, "do request")
+	}
+	body := resp.Body
+	defer body.Close()
+
+	result, err := decodeGetNoteResponse(resp)
+	if err != nil {
+		return res, errors.Wrap(err, "decode response")
+	}
+
+	return result, nil
+}
+`
+
+func TestFindStreams(t *testing.T) {
+	streams := FindStreams([]byte(decodersSource), map[string]bool{"application/octet-stream": true})
+	if len(streams) != 1 {
+		t.Fatalf("FindStreams() = %+v, want 1 stream", streams)
+	}
+	if s := streams[0]; s.Operation != "Download" || s.Type != "DownloadOK" || s.Wrapper != "DownloadOKHeaders" {
+		t.Errorf("FindStreams() = %+v", s)
+	}
+
+	// Other content types are streamed on request.
+	streams = FindStreams([]byte(decodersSource), map[string]bool{"application/octet-stream": true, "text/plain": true})
+	if len(streams) != 2 {
+		t.Errorf("FindStreams() = %+v, want 2 streams", streams)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource)
+	writeFile(t, filepath.Join(dir, decodersFile), decodersSource)
+	writeFile(t, filepath.Join(dir, clientFile), clientSource)
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	for file, wants := range map[string][]string{
+		decodersFile: {
+			"\t\tcase ct == \"application/octet-stream\":\n\t\t\tresponse := DownloadOK{Data: resp.Body}\n\t\t\tvar wrapper DownloadOKHeaders\n\t\t\twrapper.Response = response\n",
+			// Other content types are still buffered.
+			"\t\t\tresponse := GetNoteOK{Data: bytes.NewReader(b)}\n",
+		},
+		clientFile: {
+			"\t\"io\"\n",
+			"\tbody := resp.Body\n\tdefer func() {\n\t\t// Streamed responses are closed by the caller.\n\t\tif _, ok := any(res).(io.Closer); !ok || err != nil {\n\t\t\tbody.Close()\n\t\t}\n\t}()\n\n\tresult, err := decodeDownloadResponse(resp)",
+			"\tbody := resp.Body\n\tdefer body.Close()\n\n\tresult, err := decodeGetNoteResponse(resp)",
+		},
+		outputFile: {
+			"func (s DownloadOK) Close() error {\n\tif c, ok := s.Data.(io.Closer); ok {\n\t\treturn c.Close()\n\t}\n\treturn nil\n}",
+			"func (s *DownloadOKHeaders) Read(p []byte) (int, error) {\n\treturn s.Response.Read(p)\n}",
+			"func (s *DownloadOKHeaders) Close() error {\n\treturn s.Response.Close()\n}",
+		},
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(string(got), want) {
+				t.Errorf("%s missing %q:\n%s", file, want, got)
+			}
+		}
+	}
+
+	// The fixed package must compile.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Errorf("fixed code does not type-check: %v", err)
+	}
+}
+
+func TestRun_AlreadyDeclared(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource+`
+func (s DownloadOK) Close() error { return nil }
+`)
+	writeFile(t, filepath.Join(dir, decodersFile), decodersSource)
+	writeFile(t, filepath.Join(dir, clientFile), clientSource)
+
+	err := run([]string{dir})
+	if err == nil || err.Error() != "DownloadOK.Close is already declared" {
+		t.Errorf("run() error = %v, want DownloadOK.Close is already declared", err)
+	}
+	// Nothing is written.
+	if got, _ := os.ReadFile(filepath.Join(dir, clientFile)); string(got) != clientSource {
+		t.Error("client was modified")
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
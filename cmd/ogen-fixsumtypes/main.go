@@ -0,0 +1,254 @@
+// Command ogen-fixsumtypes gives friendly names to ogen-generated sum types.
+//
+// ogen names inline oneOf/anyOf unions after the operation and response that
+// contain them (GetPetOK, GetUserOKApplicationJSON) and names inline variants
+// by position (GetPetOK0, GetPetOKApplicationJSONOneOf1). This tool renames a
+// sum type, its variants, and everything ogen derives from those names: the
+// discriminator type and constants, the New*/Is*/Set*/Get* helpers, and every
+// reference in the generated package.
+//
+// Usage:
+//
+//	ogen-fixsumtypes -config sumtypes.json <generated-dir>
+//	ogen-fixsumtypes -list <generated-dir>
+//
+// The config file maps sum type names to their new name and variant names:
+//
+//	{
+//	  "GetPetOK": {
+//	    "name": "Pet",
+//	    "variants": {"GetPetOK0": "Dog", "Int": "Age"}
+//	  }
+//	}
+//
+// With the config above GetPetOK becomes Pet, GetPetOKType becomes PetType,
+// NewGetPetOK0GetPetOK becomes NewDogPet, IsInt becomes IsAge, and so on.
+// Use -list to print the sum types and variants found in a package.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// Config maps sum type names to their renames.
+type Config map[string]SumTypeConfig
+
+// SumTypeConfig describes the renames for one sum type.
+type SumTypeConfig struct {
+	// Name is the new sum type name. Empty keeps the current name.
+	Name string `json:"name"`
+
+	// Variants maps variant field names to their new names.
+	Variants map[string]string `json:"variants"`
+}
+
+// SumType is an ogen-generated sum type found in a package.
+type SumType struct {
+	Name     string
+	Variants []string
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixsumtypes: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixsumtypes", flag.ContinueOnError)
+	configFile := fs.String("config", "", "JSON file with sum type and variant names")
+	list := fs.Bool("list", false, "list sum types and their variants instead of renaming")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || (*configFile == "") == !*list {
+		return fmt.Errorf("usage: ogen-fixsumtypes (-config sumtypes.json | -list) <generated-dir>")
+	}
+
+	dir := fs.Arg(0)
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	if *list {
+		for _, st := range FindSumTypes(pkg.Types) {
+			fmt.Printf("%s: %s\n", st.Name, strings.Join(st.Variants, ", "))
+		}
+		return nil
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	renames, err := SumTypeRenames(pkg.Types, cfg)
+	if err != nil {
+		return err
+	}
+
+	count, err := pkg.Rename(func(obj types.Object) string {
+		if newName, ok := renames[obj]; ok {
+			return newName
+		}
+		return obj.Name()
+	})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Printf("No sum types needed renaming in %s\n", dir)
+		return nil
+	}
+
+	written, err := pkg.Write()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Renamed %d identifiers of %d sum types in %d files in %s\n", count, len(cfg), len(written), dir)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// FindSumTypes returns the sum types declared in pkg, sorted by name.
+//
+// ogen generates a sum type as a struct whose first field is
+// "Type <Name>Type"; the remaining fields are the variants.
+func FindSumTypes(pkg *types.Package) []SumType {
+	var found []SumType
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		st, ok := sumTypeStruct(scope.Lookup(name))
+		if !ok {
+			continue
+		}
+		sum := SumType{Name: name}
+		for i := 1; i < st.NumFields(); i++ {
+			sum.Variants = append(sum.Variants, st.Field(i).Name())
+		}
+		found = append(found, sum)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+	return found
+}
+
+func sumTypeStruct(obj types.Object) (*types.Struct, bool) {
+	tn, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil, false
+	}
+	st, ok := tn.Type().Underlying().(*types.Struct)
+	if !ok || st.NumFields() < 2 || st.Field(0).Name() != "Type" {
+		return nil, false
+	}
+	discriminator, ok := st.Field(0).Type().(*types.Named)
+	if !ok || discriminator.Obj().Name() != tn.Name()+"Type" {
+		return nil, false
+	}
+	return st, true
+}
+
+// SumTypeRenames computes the new name of every object derived from the sum
+// types configured in cfg.
+func SumTypeRenames(pkg *types.Package, cfg Config) (map[types.Object]string, error) {
+	renames := make(map[types.Object]string)
+	scope := pkg.Scope()
+
+	names := make([]string, 0, len(cfg))
+	for name := range cfg {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, sumName := range names {
+		sumCfg := cfg[sumName]
+		obj := scope.Lookup(sumName)
+		st, ok := sumTypeStruct(obj)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a sum type", sumName)
+		}
+
+		newSum := sumName
+		if sumCfg.Name != "" {
+			newSum = sumCfg.Name
+		}
+
+		variants := make(map[string]string)
+		for i := 1; i < st.NumFields(); i++ {
+			field := st.Field(i)
+			newVariant := field.Name()
+			if v, ok := sumCfg.Variants[field.Name()]; ok {
+				newVariant = v
+			}
+			variants[field.Name()] = newVariant
+			renames[field] = newVariant
+
+			// Inline variants are named after the sum type; rename the
+			// variant type along with the field. Shared component types
+			// such as User are left alone.
+			if named, ok := field.Type().(*types.Named); ok && named.Obj().Pkg() == pkg &&
+				named.Obj().Name() == field.Name() && strings.HasPrefix(field.Name(), sumName) {
+				renames[named.Obj()] = newVariant
+			}
+		}
+		for v := range sumCfg.Variants {
+			if _, ok := variants[v]; !ok {
+				return nil, fmt.Errorf("%s has no variant %s", sumName, v)
+			}
+		}
+
+		renames[obj] = newSum
+		discriminator := st.Field(0).Type().(*types.Named).Obj()
+		renames[discriminator] = newSum + "Type"
+
+		for oldVariant, newVariant := range variants {
+			// Discriminator constants: <Variant><Sum>.
+			if c := scope.Lookup(oldVariant + sumName); c != nil {
+				renames[c] = newVariant + newSum
+			}
+			// Constructors: New<Variant><Sum>.
+			if fn := scope.Lookup("New" + oldVariant + sumName); fn != nil {
+				renames[fn] = "New" + newVariant + newSum
+			}
+			// Accessors: Is<Variant>, Set<Variant>, Get<Variant>.
+			for _, prefix := range []string{"Is", "Set", "Get"} {
+				m, _, _ := types.LookupFieldOrMethod(types.NewPointer(obj.Type()), false, pkg, prefix+oldVariant)
+				if fn, ok := m.(*types.Func); ok {
+					renames[fn] = prefix + newVariant
+				}
+			}
+		}
+	}
+
+	// Drop no-op entries so callers can count real renames.
+	for obj, newName := range renames {
+		if obj.Name() == newName {
+			delete(renames, obj)
+		}
+	}
+	return renames, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sumTypeSource = `package api
+
+// GetPetOK represents sum type.
+type GetPetOK struct {
+	Type      GetPetOKType // switch on this field
+	GetPetOK0 GetPetOK0
+	Int       int
+}
+
+// GetPetOKType is oneOf type of GetPetOK.
+type GetPetOKType string
+
+// Possible values for GetPetOKType.
+const (
+	GetPetOK0GetPetOK GetPetOKType = "GetPetOK0"
+	IntGetPetOK       GetPetOKType = "int"
+)
+
+// IsGetPetOK0 reports whether GetPetOK is GetPetOK0.
+func (s GetPetOK) IsGetPetOK0() bool { return s.Type == GetPetOK0GetPetOK }
+
+// IsInt reports whether GetPetOK is int.
+func (s GetPetOK) IsInt() bool { return s.Type == IntGetPetOK }
+
+// SetGetPetOK0 sets GetPetOK to GetPetOK0.
+func (s *GetPetOK) SetGetPetOK0(v GetPetOK0) {
+	s.Type = GetPetOK0GetPetOK
+	s.GetPetOK0 = v
+}
+
+// NewGetPetOK0GetPetOK returns new GetPetOK from GetPetOK0.
+func NewGetPetOK0GetPetOK(v GetPetOK0) GetPetOK {
+	var s GetPetOK
+	s.SetGetPetOK0(v)
+	return s
+}
+
+// SetInt sets GetPetOK to int.
+func (s *GetPetOK) SetInt(v int) {
+	s.Type = IntGetPetOK
+	s.Int = v
+}
+
+type GetPetOK0 struct {
+	Bark string
+}
+
+// Other has an Int field that must not be renamed.
+type Other struct {
+	Int int
+}
+
+func (s *Other) SetInt(v int) { s.Int = v }
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), sumTypeSource)
+	writeFile(t, filepath.Join(dir, "sumtypes.json"),
+		`{"GetPetOK": {"name": "Pet", "variants": {"GetPetOK0": "Dog", "Int": "Age"}}}`)
+
+	if err := run([]string{"-config", filepath.Join(dir, "sumtypes.json"), dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "oas_schemas_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+
+	wants := []string{
+		"// Pet represents sum type.\ntype Pet struct {",
+		"Type PetType // switch on this field",
+		"Dog  Dog",
+		"Age  int",
+		"type PetType string",
+		`DogPet PetType = "GetPetOK0"`,
+		`AgePet PetType = "int"`,
+		"// IsDog reports whether Pet is Dog.\nfunc (s Pet) IsDog() bool { return s.Type == DogPet }",
+		"func (s *Pet) SetDog(v Dog) {",
+		"// NewDogPet returns new Pet from Dog.\nfunc NewDogPet(v Dog) Pet {",
+		"func (s *Pet) SetAge(v int) {\n\ts.Type = AgePet\n\ts.Age = v",
+		"type Dog struct {",
+		// Fields and methods of unrelated types keep their names.
+		"type Other struct {\n\tInt int\n}",
+		"func (s *Other) SetInt(v int) { s.Int = v }",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRun_UnknownVariant(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), sumTypeSource)
+	writeFile(t, filepath.Join(dir, "sumtypes.json"), `{"GetPetOK": {"variants": {"Cat": "Kitty"}}}`)
+
+	err := run([]string{"-config", filepath.Join(dir, "sumtypes.json"), dir})
+	if err == nil || !strings.Contains(err.Error(), "has no variant Cat") {
+		t.Errorf("err = %v, want unknown variant error", err)
+	}
+}
+
+func TestRun_NotSumType(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), sumTypeSource)
+	writeFile(t, filepath.Join(dir, "sumtypes.json"), `{"Other": {"name": "Another"}}`)
+
+	err := run([]string{"-config", filepath.Join(dir, "sumtypes.json"), dir})
+	if err == nil || !strings.Contains(err.Error(), "not a sum type") {
+		t.Errorf("err = %v, want not a sum type error", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,598 @@
+// Command ogen-fixtext encodes the text/plain and text/csv request and
+// response bodies of ogen-generated code as text instead of JSON.
+//
+// ogen only generates text bodies whose schema is a string, and returns them
+// as an io.Reader; an integer, enum or array schema fails generation.
+// Aliasing the text content types to JSON in ogen.yml makes ogen generate
+// the operations with typed bodies, but decodes them as JSON:
+//
+//	generator:
+//	  content_type_aliases:
+//	    text/plain: application/json
+//	    text/csv: application/json
+//
+// This tool then swaps the JSON codec of every text body, in the client and
+// in the server. Strings and enums are sent as is, numbers and booleans in
+// their strconv form, and [][]string text/csv bodies as CSV records:
+//
+//	records, err := client.ExportOrders(ctx) // [][]string
+//
+// Usage:
+//
+//	ogen --config ogen.yml --package api --target internal/api --clean openapi.json
+//	ogen-fixtext internal/api
+//
+// The text helpers are written to oas_text_gen.go.
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"go/types"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the text helpers are written to.
+const outputFile = "oas_text_gen.go"
+
+// codecFiles are the generated files holding body codecs.
+var codecFiles = []string{
+	"oas_request_encoders_gen.go",
+	"oas_response_decoders_gen.go",
+	"oas_request_decoders_gen.go",
+	"oas_response_encoders_gen.go",
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixtext: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-fixtext <generated-dir>")
+	}
+
+	dir := args[0]
+	files := make(map[string][]byte)
+	var bodies []Body
+	for _, name := range codecFiles {
+		content, err := os.ReadFile(filepath.Join(dir, name)) // #nosec G703 -- CLI tool, filename from trusted args
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		files[name] = content
+		bodies = append(bodies, FindTextBodies(name, content)...)
+	}
+
+	if len(bodies) == 0 {
+		fmt.Printf("No text bodies found in %s\n", dir)
+		return nil
+	}
+
+	// The output file stays in: bodies fixed by an earlier run call its
+	// helpers.
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	edits := make(map[string][]gopkg.Edit)
+	used := make(map[string]bool)
+	fixed := 0
+	for _, b := range bodies {
+		c, err := resolveCodec(pkg, b)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ogen-fixtext: %s: %v, body left as JSON\n", b.Func, err)
+			continue
+		}
+		edits[b.File] = append(edits[b.File], b.edit(c))
+		used[c.helper] = true
+		fixed++
+	}
+
+	if fixed == 0 {
+		fmt.Printf("No text bodies fixed in %s\n", dir)
+		return nil
+	}
+
+	gen, err := generateHelpers(pkg, used)
+	if err != nil {
+		return err
+	}
+
+	for name, content := range files {
+		if len(edits[name]) == 0 {
+			continue
+		}
+		content, err := format.Source(gopkg.FixImports(gopkg.ApplyEdits(content, edits[name]), "github.com/go-faster/jx"))
+		if err != nil {
+			return fmt.Errorf("format %s: %w", name, err)
+		}
+		// #nosec G703 -- CLI tool, filename from trusted args
+		if err := os.WriteFile(filepath.Join(pkg.Dir, name), content, 0600); err != nil {
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+	if err := gen.WriteFile(filepath.Join(pkg.Dir, outputFile)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Fixed %d text bodies in %s\n", fixed, dir)
+	return nil
+}
+
+// bodyKind is where a body codec sits.
+type bodyKind int
+
+const (
+	encodeRequest  bodyKind = iota // client request encoder
+	decodeResponse                 // client response decoder
+	decodeRequest                  // server request decoder
+	encodeResponse                 // server response encoder
+)
+
+// Body is the JSON codec of a text request or response body.
+type Body struct {
+	// File is the base name of the generated file.
+	File string
+	// Func is the generated function, for messages.
+	Func string
+	Kind bodyKind
+	// ContentType is the media type of the body, without parameters.
+	ContentType string
+	// Type is the Go type of the body as spelled in the code, e.g. OptInt.
+	Type string
+	// Value is the variable holding the body, e.g. req or response. It is
+	// response.Response when the body is the Response field of Type.
+	Value string
+
+	// start and end delimit the JSON codec code.
+	start, end int
+	indent     string
+}
+
+var (
+	funcPattern = regexp.MustCompile(`(?m)^func (?:\(s \*Server\) )?(\w+)\(`)
+
+	// requestEncoderPattern matches a client request encoder; only
+	// encoders with a single content type are plain functions like this.
+	requestEncoderPattern = regexp.MustCompile(
+		`(?ms)^func (encode\w+Request)\(\n\treq (\S+),\n\tr \*http\.Request,\n\) error \{\n\tconst contentType = "([^"]+)"\n.*?^\}\n`)
+	requestEncodePattern = regexp.MustCompile(
+		`(?s)\te := new\(jx\.Encoder\)\n\t\{\n.*?\n\t\}\n\tencoded := e\.Bytes\(\)\n`)
+
+	contentCasePattern = regexp.MustCompile(`(?m)^\t+case ct == "([^"]+)":\n`)
+	decodePattern      = regexp.MustCompile(`^(\t+)d := jx\.DecodeBytes\(buf\)\n\n\t+var (response|request) (\S+)\n\t+if err := func\(\) error \{\n`)
+
+	contentHeaderPattern = regexp.MustCompile(`(?m)^(\t+)w\.Header\(\)\.Set\("Content-Type", "([^"]+)"\)\n`)
+	responseCasePattern  = regexp.MustCompile(
+		`(?m)^\tcase (\*?\w+):\n|^func encode\w+Response\(response (\S+), w http\.ResponseWriter\b`)
+	encodePattern = regexp.MustCompile(
+		`(?ms)^\t+e := new\(jx\.Encoder\)\n.*?^\t+if _, err := e\.WriteTo\(w\); err != nil \{\n`)
+)
+
+// isText reports whether a content type is one this tool encodes as text.
+func isText(contentType string) bool {
+	return contentType == "text/plain" || contentType == "text/csv"
+}
+
+// FindTextBodies finds the JSON codecs of text bodies in a generated file.
+func FindTextBodies(file string, content []byte) []Body {
+	switch file {
+	case "oas_request_encoders_gen.go":
+		return findRequestEncoders(file, content)
+	case "oas_response_encoders_gen.go":
+		return findResponseEncoders(file, content)
+	default:
+		kind := decodeResponse
+		if file == "oas_request_decoders_gen.go" {
+			kind = decodeRequest
+		}
+		return findDecoders(file, content, kind)
+	}
+}
+
+func findRequestEncoders(file string, content []byte) []Body {
+	var bodies []Body
+	for _, m := range requestEncoderPattern.FindAllSubmatchIndex(content, -1) {
+		ct := string(content[m[6]:m[7]])
+		if !isText(ct) {
+			continue
+		}
+		loc := requestEncodePattern.FindIndex(content[m[0]:m[1]])
+		if loc == nil {
+			continue
+		}
+		bodies = append(bodies, Body{
+			File:        file,
+			Func:        string(content[m[2]:m[3]]),
+			Kind:        encodeRequest,
+			ContentType: ct,
+			Type:        string(content[m[4]:m[5]]),
+			Value:       "req",
+			start:       m[0] + loc[0],
+			end:         m[0] + loc[1],
+			indent:      "\t",
+		})
+	}
+	return bodies
+}
+
+func findDecoders(file string, content []byte, kind bodyKind) []Body {
+	cases := contentCasePattern.FindAllSubmatchIndex(content, -1)
+	var bodies []Body
+	for n, m := range cases {
+		ct := string(content[m[2]:m[3]])
+		if !isText(ct) {
+			continue
+		}
+		// The codec is the first one after the case, and before the next
+		// case or function.
+		end := len(content)
+		if n+1 < len(cases) {
+			end = cases[n+1][0]
+		}
+		if f := strings.Index(string(content[m[1]:end]), "\nfunc "); f >= 0 {
+			end = m[1] + f
+		}
+		i := strings.Index(string(content[m[1]:end]), "d := jx.DecodeBytes(buf)")
+		if i < 0 {
+			continue
+		}
+		start := lineStart(content, m[1]+i)
+		d := decodePattern.FindSubmatchIndex(content[start:])
+		if d == nil {
+			continue
+		}
+		// The codec ends where the decoding closure is called.
+		indent := string(content[start+d[2] : start+d[3]])
+		call := "\n" + indent + "}(); err != nil {\n"
+		j := strings.Index(string(content[start:]), call)
+		if j < 0 {
+			continue
+		}
+		bodies = append(bodies, Body{
+			File:        file,
+			Func:        enclosingFunc(content, start),
+			Kind:        kind,
+			ContentType: ct,
+			Type:        string(content[start+d[6] : start+d[7]]),
+			Value:       string(content[start+d[4] : start+d[5]]),
+			start:       start,
+			end:         start + j + len(call),
+			indent:      indent,
+		})
+	}
+	return bodies
+}
+
+func findResponseEncoders(file string, content []byte) []Body {
+	headers := contentHeaderPattern.FindAllSubmatchIndex(content, -1)
+	var bodies []Body
+	for i, m := range headers {
+		ct, _, err := mime.ParseMediaType(string(content[m[4]:m[5]]))
+		if err != nil || !isText(ct) {
+			continue
+		}
+		// The codec is the first one after the header, and before the
+		// next response.
+		end := len(content)
+		if i+1 < len(headers) {
+			end = headers[i+1][0]
+		}
+		e := encodePattern.FindIndex(content[m[1]:end])
+		if e == nil {
+			continue
+		}
+
+		// The type is that of the case of the type switch on responses,
+		// or of the function parameter for a single response.
+		var typ string
+		for _, c := range responseCasePattern.FindAllSubmatch(content[:m[0]], -1) {
+			typ = string(c[1]) + string(c[2])
+		}
+		value := "response"
+		if strings.Contains(string(content[m[1]+e[0]:m[1]+e[1]]), "response.Response") {
+			value = "response.Response"
+		}
+
+		bodies = append(bodies, Body{
+			File:        file,
+			Func:        enclosingFunc(content, m[0]),
+			Kind:        encodeResponse,
+			ContentType: ct,
+			Type:        typ,
+			Value:       value,
+			start:       m[1] + e[0],
+			end:         m[1] + e[1],
+			indent:      string(content[m[2]:m[3]]),
+		})
+	}
+	return bodies
+}
+
+func lineStart(content []byte, i int) int {
+	for i > 0 && content[i-1] != '\n' {
+		i--
+	}
+	return i
+}
+
+func enclosingFunc(content []byte, i int) string {
+	var name string
+	for _, m := range funcPattern.FindAllSubmatch(content[:i], -1) {
+		name = string(m[1])
+	}
+	return name
+}
+
+// codec is how a body is converted to and from text.
+type codec struct {
+	// helper is the name of the helpers without their encode or decode
+	// prefix, e.g. TextInt.
+	helper string
+	// args are the extra arguments of the helpers, e.g. the bit size.
+	args string
+	// opt is set when the body is an Opt wrapper, coded through its Value.
+	opt bool
+	// deref is set when the body is a pointer, as in the cases of a type
+	// switch on responses.
+	deref bool
+}
+
+// resolveCodec picks the text codec for the type of b.
+func resolveCodec(pkg *gopkg.Package, b Body) (codec, error) {
+	tv, err := types.Eval(pkg.Fset, pkg.Types, token.NoPos, b.Type)
+	if err != nil || !tv.IsType() {
+		return codec{}, fmt.Errorf("type %s not found", b.Type)
+	}
+	t := tv.Type
+	if b.Value == "response.Response" {
+		field, _, _ := types.LookupFieldOrMethod(t, true, pkg.Types, "Response")
+		v, ok := field.(*types.Var)
+		if !ok {
+			return codec{}, fmt.Errorf("%s has no Response field", b.Type)
+		}
+		t = v.Type()
+	}
+
+	var c codec
+	if p, ok := t.(*types.Pointer); ok {
+		c.deref = true
+		t = p.Elem()
+	}
+	switch wrapper, value := gopkg.Unwrap(t); wrapper {
+	case gopkg.NotWrapped:
+	case gopkg.Opt:
+		c.opt = true
+		t = value
+	default:
+		return codec{}, fmt.Errorf("%s: null has no text form", b.Type)
+	}
+
+	if b.ContentType == "text/csv" && types.TypeString(t.Underlying(), nil) == "[][]string" {
+		c.helper = "CSV"
+		return c, nil
+	}
+
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return codec{}, fmt.Errorf("%s has no %s form", types.TypeString(t, nil), b.ContentType)
+	}
+	info := basic.Info()
+	switch {
+	case info&types.IsString != 0:
+		c.helper = "TextString"
+	case info&types.IsBoolean != 0:
+		c.helper = "TextBool"
+	case info&types.IsUnsigned != 0:
+		c.helper, c.args = "TextUint", fmt.Sprintf(", %d", bitSize(basic))
+	case info&types.IsInteger != 0:
+		c.helper, c.args = "TextInt", fmt.Sprintf(", %d", bitSize(basic))
+	case info&types.IsFloat != 0:
+		c.helper, c.args = "TextFloat", fmt.Sprintf(", %d", bitSize(basic))
+	default:
+		return codec{}, fmt.Errorf("%s has no %s form", types.TypeString(t, nil), b.ContentType)
+	}
+	return c, nil
+}
+
+// bitSize returns the size strconv parses a basic type with; 0 is int.
+func bitSize(b *types.Basic) int {
+	switch b.Kind() {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32, types.Float32:
+		return 32
+	case types.Int64, types.Uint64, types.Float64:
+		return 64
+	}
+	return 0
+}
+
+// edit returns the edit swapping the JSON codec of b for c.
+func (b Body) edit(c codec) gopkg.Edit {
+	in := b.indent
+	value := b.Value
+	if c.opt {
+		value += ".Value"
+	}
+	if c.deref {
+		value = "*" + value
+	}
+	switch b.Kind {
+	case encodeRequest:
+		return gopkg.Edit{Start: b.start, End: b.end, Text: fmt.Sprintf("%sencoded := encode%s(%s%s)\n", in, c.helper, value, c.args)}
+	case encodeResponse:
+		return gopkg.Edit{Start: b.start, End: b.end, Text: fmt.Sprintf("%sif _, err := w.Write(encode%s(%s%s)); err != nil {\n", in, c.helper, value, c.args)}
+	default:
+		text := fmt.Sprintf("%svar %s %s\n", in, b.Value, b.Type)
+		if c.opt {
+			text += fmt.Sprintf("%s%s.Set = true\n", in, b.Value)
+		}
+		text += fmt.Sprintf("%sif err := decode%s(buf, &%s%s); err != nil {\n", in, c.helper, value, c.args)
+		return gopkg.Edit{Start: b.start, End: b.end, Text: text}
+	}
+}
+
+// generateHelpers writes the encode and decode helpers in use, and those an
+// earlier run wrote.
+func generateHelpers(pkg *gopkg.Package, used map[string]bool) (*gopkg.Generated, error) {
+	scope := pkg.Types.Scope()
+	for name := range helpers {
+		for _, decl := range []string{"encode" + name, "decode" + name} {
+			obj := scope.Lookup(decl)
+			if obj == nil {
+				continue
+			}
+			if filepath.Base(pkg.Fset.Position(obj.Pos()).Filename) != outputFile {
+				return nil, fmt.Errorf("%s is already declared", decl)
+			}
+			used[name] = true
+		}
+	}
+
+	var names []string
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	gen := gopkg.NewGenerated("ogen-fixtext", pkg.Types)
+	for _, name := range names {
+		h := helpers[name]
+		for _, path := range h.imports {
+			gen.Import(path, path[strings.LastIndex(path, "/")+1:])
+		}
+		gen.Printf("%s", h.source)
+	}
+	return gen, nil
+}
+
+// helper is the source of an encode and decode helper pair.
+type helper struct {
+	imports []string
+	source  string
+}
+
+var helpers = map[string]helper{
+	"TextString": {nil, `// encodeTextString encodes a string body as is.
+func encodeTextString[T ~string](v T) []byte {
+	return []byte(v)
+}
+
+// decodeTextString decodes a string body as is.
+func decodeTextString[T ~string](buf []byte, v *T) error {
+	*v = T(buf)
+	return nil
+}
+
+`},
+	"TextBool": {[]string{"bytes", "strconv"}, `// encodeTextBool encodes a boolean body as true or false.
+func encodeTextBool[T ~bool](v T) []byte {
+	return strconv.AppendBool(nil, bool(v))
+}
+
+// decodeTextBool decodes a boolean body, ignoring surrounding whitespace.
+func decodeTextBool[T ~bool](buf []byte, v *T) error {
+	b, err := strconv.ParseBool(string(bytes.TrimSpace(buf)))
+	if err != nil {
+		return err
+	}
+	*v = T(b)
+	return nil
+}
+
+`},
+	"TextInt": {[]string{"bytes", "strconv"}, `// encodeTextInt encodes an integer body in decimal.
+func encodeTextInt[T ~int | ~int8 | ~int16 | ~int32 | ~int64](v T, _ int) []byte {
+	return strconv.AppendInt(nil, int64(v), 10)
+}
+
+// decodeTextInt decodes a decimal integer body, ignoring surrounding
+// whitespace.
+func decodeTextInt[T ~int | ~int8 | ~int16 | ~int32 | ~int64](buf []byte, v *T, bitSize int) error {
+	n, err := strconv.ParseInt(string(bytes.TrimSpace(buf)), 10, bitSize)
+	if err != nil {
+		return err
+	}
+	*v = T(n)
+	return nil
+}
+
+`},
+	"TextUint": {[]string{"bytes", "strconv"}, `// encodeTextUint encodes an unsigned integer body in decimal.
+func encodeTextUint[T ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64](v T, _ int) []byte {
+	return strconv.AppendUint(nil, uint64(v), 10)
+}
+
+// decodeTextUint decodes a decimal unsigned integer body, ignoring
+// surrounding whitespace.
+func decodeTextUint[T ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64](buf []byte, v *T, bitSize int) error {
+	n, err := strconv.ParseUint(string(bytes.TrimSpace(buf)), 10, bitSize)
+	if err != nil {
+		return err
+	}
+	*v = T(n)
+	return nil
+}
+
+`},
+	"TextFloat": {[]string{"bytes", "strconv"}, `// encodeTextFloat encodes a number body in its shortest form.
+func encodeTextFloat[T ~float32 | ~float64](v T, bitSize int) []byte {
+	return strconv.AppendFloat(nil, float64(v), 'g', -1, bitSize)
+}
+
+// decodeTextFloat decodes a number body, ignoring surrounding whitespace.
+func decodeTextFloat[T ~float32 | ~float64](buf []byte, v *T, bitSize int) error {
+	f, err := strconv.ParseFloat(string(bytes.TrimSpace(buf)), bitSize)
+	if err != nil {
+		return err
+	}
+	*v = T(f)
+	return nil
+}
+
+`},
+	"CSV": {[]string{"bytes", "encoding/csv"}, `// encodeCSV encodes records as a CSV body.
+func encodeCSV(records [][]string) []byte {
+	var buf bytes.Buffer
+	// Writing to a bytes.Buffer can't fail.
+	_ = csv.NewWriter(&buf).WriteAll(records)
+	return buf.Bytes()
+}
+
+// decodeCSV decodes a CSV body into records. Records may have different
+// numbers of fields; an empty body has no records.
+func decodeCSV(buf []byte, records *[][]string) error {
+	r := csv.NewReader(bytes.NewReader(buf))
+	r.FieldsPerRecord = -1
+	all, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+	if all == nil {
+		all = [][]string{}
+	}
+	*records = all
+	return nil
+}
+
+`},
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const schemasSource = `package api
+
+import "github.com/go-faster/jx"
+
+type Status string
+
+func (s Status) Encode(e *jx.Encoder)        {}
+func (s *Status) Decode(d *jx.Decoder) error { return nil }
+
+type Report struct {
+	Title string
+}
+
+func (s *Report) Encode(e *jx.Encoder)       {}
+func (s *Report) Decode(d *jx.Decoder) error { return nil }
+
+type ReportTextCsv string
+
+func (s ReportTextCsv) Encode(e *jx.Encoder) {}
+
+type GetReportRes interface{ getReportRes() }
+
+func (*Report) getReportRes()        {}
+func (*ReportTextCsv) getReportRes() {}
+
+type GetStatusOKHeaders struct {
+	RequestID string
+	Response  Status
+}
+
+type OptInt struct {
+	Value int
+	Set   bool
+}
+
+func (o OptInt) Encode(e *jx.Encoder)        {}
+func (o *OptInt) Decode(d *jx.Decoder) error { return nil }
+func (o *OptInt) Reset()                     {}
+`
+
+const encodersSource = `package api
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/go-faster/jx"
+	ht "github.com/ogen-go/ogen/http"
+)
+
+func encodeSetLimitRequest(
+	req OptInt,
+	r *http.Request,
+) error {
+	const contentType = "text/plain"
+	if !req.Set {
+		// Keep request with empty body if value is not set.
+		return nil
+	}
+	e := new(jx.Encoder)
+	{
+		if req.Set {
+			req.Encode(e)
+		}
+	}
+	encoded := e.Bytes()
+	ht.SetBody(r, bytes.NewReader(encoded), contentType)
+	return nil
+}
+
+func encodeImportRequest(
+	req [][]string,
+	r *http.Request,
+) error {
+	const contentType = "text/csv"
+	e := new(jx.Encoder)
+	{
+		if req != nil {
+			e.ArrStart()
+			for _, elem := range req {
+				e.ArrStart()
+				for _, elem := range elem {
+					e.Str(elem)
+				}
+				e.ArrEnd()
+			}
+			e.ArrEnd()
+		}
+	}
+	encoded := e.Bytes()
+	ht.SetBody(r, bytes.NewReader(encoded), contentType)
+	return nil
+}
+`
+
+const decodersSource = `package api
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+
+	"github.com/ogen-go/ogen/validate"
+)
+
+func decodeCountResponse(resp *http.Response) (res int, _ error) {
+	switch resp.StatusCode {
+	case 200:
+		// Code 200.
+		ct, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err != nil {
+			return res, errors.Wrap(err, "parse media type")
+		}
+		switch {
+		case ct == "text/plain":
+			buf, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return res, err
+			}
+			d := jx.DecodeBytes(buf)
+
+			var response int
+			if err := func() error {
+				v, err := d.Int()
+				response = int(v)
+				if err != nil {
+					return err
+				}
+				if err := d.Skip(); err != io.EOF {
+					return errors.New("unexpected trailing data")
+				}
+				return nil
+			}(); err != nil {
+				return res, errors.Wrap(err, "decode body")
+			}
+			return response, nil
+		default:
+			return res, validate.InvalidContentType(ct)
+		}
+	}
+	return res, validate.UnexpectedStatusCodeWithResponse(resp)
+}
+
+func decodeGetReportResponse(resp *http.Response) (res GetReportRes, _ error) {
+	switch resp.StatusCode {
+	case 200:
+		// Code 200.
+		ct, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err != nil {
+			return res, errors.Wrap(err, "parse media type")
+		}
+		switch {
+		case ct == "text/plain":
+			buf, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return res, err
+			}
+			d := jx.DecodeBytes(buf)
+
+			var response Report
+			if err := func() error {
+				if err := response.Decode(d); err != nil {
+					return err
+				}
+				if err := d.Skip(); err != io.EOF {
+					return errors.New("unexpected trailing data")
+				}
+				return nil
+			}(); err != nil {
+				return res, errors.Wrap(err, "decode body")
+			}
+			return &response, nil
+		default:
+			return res, validate.InvalidContentType(ct)
+		}
+	}
+	return res, validate.UnexpectedStatusCodeWithResponse(resp)
+}
+
+func decodeExportResponse(resp *http.Response) (res [][]string, _ error) {
+	switch resp.StatusCode {
+	case 200:
+		// Code 200.
+		ct, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err != nil {
+			return res, errors.Wrap(err, "parse media type")
+		}
+		switch {
+		case ct == "text/csv":
+			buf, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return res, err
+			}
+			d := jx.DecodeBytes(buf)
+
+			var response [][]string
+			if err := func() error {
+				response = make([][]string, 0)
+				if err := d.Arr(func(d *jx.Decoder) error {
+					var elem []string
+					elem = make([]string, 0)
+					if err := d.Arr(func(d *jx.Decoder) error {
+						var elemElem string
+						v, err := d.Str()
+						elemElem = string(v)
+						if err != nil {
+							return err
+						}
+						elem = append(elem, elemElem)
+						return nil
+					}); err != nil {
+						return err
+					}
+					response = append(response, elem)
+					return nil
+				}); err != nil {
+					return err
+				}
+				if err := d.Skip(); err != io.EOF {
+					return errors.New("unexpected trailing data")
+				}
+				return nil
+			}(); err != nil {
+				return res, errors.Wrap(err, "decode body")
+			}
+			return response, nil
+		default:
+			return res, validate.InvalidContentType(ct)
+		}
+	}
+	return res, validate.UnexpectedStatusCodeWithResponse(resp)
+}
+`
+
+const responseEncodersSource = `package api
+
+import (
+	"net/http"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+)
+
+func encodeGetReportResponse(response GetReportRes, w http.ResponseWriter) error {
+	switch response := response.(type) {
+	case *ReportTextCsv:
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(200)
+
+		e := new(jx.Encoder)
+		response.Encode(e)
+		if _, err := e.WriteTo(w); err != nil {
+			return errors.Wrap(err, "write")
+		}
+
+		return nil
+
+	default:
+		return errors.Errorf("unexpected response type: %T", response)
+	}
+}
+
+func encodeGetStatusResponse(response *GetStatusOKHeaders, w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Request-Id", response.RequestID)
+	w.WriteHeader(200)
+
+	e := new(jx.Encoder)
+	response.Response.Encode(e)
+	if _, err := e.WriteTo(w); err != nil {
+		return errors.Wrap(err, "write")
+	}
+
+	return nil
+}
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource)
+	writeFile(t, filepath.Join(dir, "oas_request_encoders_gen.go"), encodersSource)
+	writeFile(t, filepath.Join(dir, "oas_response_decoders_gen.go"), decodersSource)
+	writeFile(t, filepath.Join(dir, "oas_response_encoders_gen.go"), responseEncodersSource)
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	for file, wants := range map[string][]string{
+		"oas_request_encoders_gen.go": {
+			"\t\treturn nil\n\t}\n\tencoded := encodeTextInt(req.Value, 0)\n\tht.SetBody(",
+			"\tencoded := encodeCSV(req)\n\tht.SetBody(",
+		},
+		"oas_response_decoders_gen.go": {
+			"\t\t\tvar response int\n\t\t\tif err := decodeTextInt(buf, &response, 0); err != nil {\n\t\t\t\treturn res, errors.Wrap(err, \"decode body\")",
+			"\t\t\tvar response [][]string\n\t\t\tif err := decodeCSV(buf, &response); err != nil {",
+			// Objects have no text form and keep their codec.
+			"\t\t\tvar response Report\n\t\t\tif err := func() error {\n\t\t\t\tif err := response.Decode(d); err != nil {",
+		},
+		"oas_response_encoders_gen.go": {
+			"\t\tif _, err := w.Write(encodeTextString(*response)); err != nil {",
+			"\tif _, err := w.Write(encodeTextString(response.Response)); err != nil {",
+		},
+		outputFile: {
+			"// Code generated by ogen-fixtext, DO NOT EDIT.",
+			"func decodeCSV(buf []byte, records *[][]string) error {",
+			"func decodeTextInt[T ~int | ~int8 | ~int16 | ~int32 | ~int64](buf []byte, v *T, bitSize int) error {",
+			"func encodeTextString[T ~string](v T) []byte {",
+		},
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(string(got), want) {
+				t.Errorf("%s missing %q:\n%s", file, want, got)
+			}
+		}
+	}
+
+	// Only the helpers in use are generated.
+	if got, _ := os.ReadFile(filepath.Join(dir, outputFile)); strings.Contains(string(got), "TextFloat") {
+		t.Error("generated unused helpers")
+	}
+	// The response encoders no longer use jx.
+	if got, _ := os.ReadFile(filepath.Join(dir, "oas_response_encoders_gen.go")); strings.Contains(string(got), "go-faster/jx") {
+		t.Error("unused jx import left in response encoders")
+	}
+
+	// The fixed package must compile.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Errorf("fixed code does not type-check: %v", err)
+	}
+
+	// A second run only finds the object body, which is left as JSON.
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+}
+
+func TestRun_OptionalRequestBody(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource)
+	writeFile(t, filepath.Join(dir, "oas_request_decoders_gen.go"), `package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+)
+
+func decodeSetLimitRequest(r *http.Request) (req OptInt, rerr error) {
+	switch ct := r.Header.Get("Content-Type"); {
+	case ct == "text/plain":
+		buf, err := io.ReadAll(r.Body)
+		if err != nil {
+			return req, err
+		}
+		d := jx.DecodeBytes(buf)
+
+		var request OptInt
+		if err := func() error {
+			request.Reset()
+			if err := request.Decode(d); err != nil {
+				return err
+			}
+			if err := d.Skip(); err != io.EOF {
+				return errors.New("unexpected trailing data")
+			}
+			return nil
+		}(); err != nil {
+			return req, err
+		}
+		return request, nil
+	default:
+		return req, errors.New("unexpected content type")
+	}
+}
+`)
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "oas_request_decoders_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\t\tvar request OptInt\n\t\trequest.Set = true\n\t\tif err := decodeTextInt(buf, &request.Value, 0); err != nil {\n\t\t\treturn req, err\n\t\t}\n"
+	if !strings.Contains(string(got), want) {
+		t.Errorf("output missing %q:\n%s", want, got)
+	}
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Errorf("fixed code does not type-check: %v", err)
+	}
+}
+
+func TestFindTextBodies(t *testing.T) {
+	// JSON and XML bodies are left alone.
+	source := `func encodeGetOrderResponse(response *Order, w http.ResponseWriter, span trace.Span) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(200)
+
+	e := new(jx.Encoder)
+	response.Encode(e)
+	if _, err := e.WriteTo(w); err != nil {
+		return errors.Wrap(err, "write")
+	}
+
+	return nil
+}
+
+func encodeGetNoteResponse(response string, w http.ResponseWriter, span trace.Span) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	span.SetStatus(codes.Ok, http.StatusText(200))
+
+	e := new(jx.Encoder)
+	e.Str(response)
+	if _, err := e.WriteTo(w); err != nil {
+		return errors.Wrap(err, "write")
+	}
+
+	return nil
+}
+`
+	bodies := FindTextBodies("oas_response_encoders_gen.go", []byte(source))
+	if len(bodies) != 1 {
+		t.Fatalf("FindTextBodies() = %+v, want 1 body", bodies)
+	}
+	b := bodies[0]
+	if b.Func != "encodeGetNoteResponse" || b.ContentType != "text/plain" || b.Type != "string" || b.Value != "response" {
+		t.Errorf("FindTextBodies() = %+v", b)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
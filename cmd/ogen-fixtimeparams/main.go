@@ -0,0 +1,423 @@
+// Command ogen-fixtimeparams rewrites the encoding and decoding of date-time
+// parameters in ogen-generated code to use custom layouts.
+//
+// ogen always formats `format: date-time` path, query, header and cookie
+// parameters as RFC 3339. Some upstreams expect another layout, such as
+// "2006-01-02 15:04:05", or a Unix timestamp. This tool rewrites the client
+// encoding and server decoding of the configured parameters.
+//
+// Usage:
+//
+//	ogen-fixtimeparams -config timeparams.json <generated-dir>
+//
+// The config file maps operationIds to parameter names and layouts. Layouts
+// use Go's reference time; "unix" and "unixmilli" encode seconds or
+// milliseconds since the epoch:
+//
+//	{
+//	  "getMetrics": {"since": "2006-01-02 15:04:05", "until": "unixmilli"}
+//	}
+//
+// The helpers used by the rewritten code are written to
+// oas_timeparams_gen.go, so `ogen --clean` removes them together with the
+// rest of the generated code.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// Config maps operationIds to parameter names and their layouts.
+type Config map[string]map[string]string
+
+// Special layouts that are not Go time layouts.
+const (
+	layoutUnix      = "unix"
+	layoutUnixMilli = "unixmilli"
+)
+
+// helpersFile is the file the format/parse helpers are written to.
+const helpersFile = "oas_timeparams_gen.go"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixtimeparams: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixtimeparams", flag.ContinueOnError)
+	configFile := fs.String("config", "", "JSON file mapping operationIds to parameter layouts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *configFile == "" {
+		return fmt.Errorf("usage: ogen-fixtimeparams -config timeparams.json <generated-dir>")
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	dir := fs.Arg(0)
+	clientFile := filepath.Join(dir, "oas_client_gen.go")
+	paramsFile := filepath.Join(dir, "oas_parameters_gen.go")
+	handlersFile := filepath.Join(dir, "oas_handlers_gen.go")
+
+	client, err := readOptional(clientFile)
+	if err != nil {
+		return err
+	}
+	params, err := readOptional(paramsFile)
+	if err != nil {
+		return err
+	}
+	handlers, err := readOptional(handlersFile)
+	if err != nil {
+		return err
+	}
+
+	ops := OperationNames(client, handlers)
+	fixedClient, fixedParams, count, err := FixTimeParams(client, params, ops, cfg)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Printf("No time parameters needed fixing in %s\n", dir)
+		return nil
+	}
+
+	if string(fixedClient) != string(client) {
+		if err := os.WriteFile(clientFile, fixedClient, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+	if string(fixedParams) != string(params) {
+		if err := os.WriteFile(paramsFile, fixedParams, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+
+	pkgName := packageName(client)
+	if pkgName == "" {
+		pkgName = packageName(params)
+	}
+	helpers, err := Helpers(pkgName)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, helpersFile), helpers, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	fmt.Printf("Fixed %d time parameter conversions in %s\n", count, dir)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// readOptional reads filename, returning nil if it does not exist. Client-only
+// and server-only packages lack some of the files this tool looks at.
+func readOptional(filename string) ([]byte, error) {
+	content, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	return content, nil
+}
+
+var (
+	packagePattern = regexp.MustCompile(`(?m)^package (\w+)$`)
+
+	// clientOpPattern and handlerOpPattern match the doc comments ogen puts
+	// on client methods and server handlers, which name the operationId.
+	clientOpPattern  = regexp.MustCompile(`(?m)^// (\w+) invokes (\S+) operation\.$`)
+	handlerOpPattern = regexp.MustCompile(`(?m)^// handle(\w+)Request handles (\S+) operation\.$`)
+
+	// funcPattern matches a whole top-level function.
+	funcPattern = regexp.MustCompile(`(?ms)^func (?:\([^)]*\) )?(\w+)\(.*?^\}\n`)
+
+	// encodeBlockPattern matches a parameter encoding block in a client
+	// send method: a brace block at one tab of indentation.
+	encodeBlockPattern = regexp.MustCompile(`(?ms)^\t\{\n.*?^\t\}\n`)
+
+	// encodeParamPattern finds the parameter an encoding block is for.
+	encodeParamPattern = regexp.MustCompile(`(?:Param|Name):\s+"([^"]+)"`)
+
+	// decodeSectionPattern matches the comment that starts each parameter in
+	// a server decodeXParams function.
+	decodeSectionPattern = regexp.MustCompile(`\n\t// Decode (?:path|query|header|cookie): (.+)\.\n`)
+)
+
+func packageName(content []byte) string {
+	if m := packagePattern.FindSubmatch(content); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// OperationNames maps operationIds to the Go names ogen derived from them,
+// using the client and handler doc comments.
+func OperationNames(client, handlers []byte) map[string]string {
+	ops := make(map[string]string)
+	for _, m := range clientOpPattern.FindAllSubmatch(client, -1) {
+		ops[string(m[2])] = string(m[1])
+	}
+	for _, m := range handlerOpPattern.FindAllSubmatch(handlers, -1) {
+		ops[string(m[2])] = string(m[1])
+	}
+	return ops
+}
+
+// FixTimeParams rewrites the date-time conversions of the configured
+// parameters in the client and parameter decoder sources.
+//
+// Client encoding:
+//
+//	return e.EncodeValue(conv.DateTimeToString(params.Since))
+//
+// becomes:
+//
+//	return e.EncodeValue(formatTimeParam(params.Since, "2006-01-02 15:04:05"))
+//
+// Server decoding:
+//
+//	c, err := conv.ToDateTime(val)
+//
+// becomes:
+//
+//	c, err := parseTimeParam(val, "2006-01-02 15:04:05")
+//
+// It returns an error if a configured operation or parameter does not exist,
+// or if the parameter is not a date-time.
+func FixTimeParams(client, params []byte, ops map[string]string, cfg Config) ([]byte, []byte, int, error) {
+	// Key is Go operation name, then parameter name.
+	layouts := make(map[string]map[string]string)
+	var errs []string
+	for opID, paramLayouts := range cfg {
+		name, ok := ops[opID]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("unknown operation %q", opID))
+			continue
+		}
+		for param, layout := range paramLayouts {
+			if layout == "" {
+				errs = append(errs, fmt.Sprintf("%s: empty layout for %q", opID, param))
+			}
+		}
+		layouts[name] = paramLayouts
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, nil, 0, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	found := make(map[string]map[string]bool)
+	markFound := func(op, param string) {
+		if found[op] == nil {
+			found[op] = make(map[string]bool)
+		}
+		found[op][param] = true
+	}
+
+	count := 0
+	fixedClient := funcPattern.ReplaceAllFunc(client, func(fn []byte) []byte {
+		name := string(funcPattern.FindSubmatch(fn)[1])
+		if !strings.HasPrefix(name, "send") {
+			return fn
+		}
+		op := strings.TrimPrefix(name, "send")
+		opLayouts := layouts[op]
+		if opLayouts == nil {
+			return fn
+		}
+		return encodeBlockPattern.ReplaceAllFunc(fn, func(block []byte) []byte {
+			m := encodeParamPattern.FindSubmatch(block)
+			if m == nil {
+				return block
+			}
+			param := string(m[1])
+			layout, ok := opLayouts[param]
+			if !ok {
+				return block
+			}
+			fixed, n := replaceConv(block, "conv.DateTimeToString(", "formatTimeParam(", layout)
+			if n > 0 || strings.Contains(string(block), "formatTimeParam(") {
+				markFound(op, param)
+			}
+			count += n
+			return fixed
+		})
+	})
+
+	fixedParams := funcPattern.ReplaceAllFunc(params, func(fn []byte) []byte {
+		name := string(funcPattern.FindSubmatch(fn)[1])
+		if !strings.HasPrefix(name, "decode") || !strings.HasSuffix(name, "Params") {
+			return fn
+		}
+		op := strings.TrimSuffix(strings.TrimPrefix(name, "decode"), "Params")
+		opLayouts := layouts[op]
+		if opLayouts == nil {
+			return fn
+		}
+
+		sections := decodeSectionPattern.FindAllSubmatchIndex(fn, -1)
+		var out []byte
+		prev := 0
+		for i, loc := range sections {
+			end := len(fn)
+			if i+1 < len(sections) {
+				end = sections[i+1][0]
+			}
+			out = append(out, fn[prev:loc[0]]...)
+			section := fn[loc[0]:end]
+			param := string(fn[loc[2]:loc[3]])
+			if layout, ok := opLayouts[param]; ok {
+				fixed, n := replaceConv(section, "conv.ToDateTime(", "parseTimeParam(", layout)
+				if n > 0 || strings.Contains(string(section), "parseTimeParam(") {
+					markFound(op, param)
+				}
+				count += n
+				section = fixed
+			}
+			out = append(out, section...)
+			prev = end
+		}
+		return append(out, fn[prev:]...)
+	})
+
+	for opID, paramLayouts := range cfg {
+		op := ops[opID]
+		for param := range paramLayouts {
+			if !found[op][param] {
+				errs = append(errs, fmt.Sprintf("%s has no date-time parameter %q", opID, param))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, nil, 0, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return removeConvImport(fixedClient), removeConvImport(fixedParams), count, nil
+}
+
+// convImport is the import line for ogen's conversion package.
+const convImport = "\t\"github.com/ogen-go/ogen/conv\"\n"
+
+// convUsePattern matches a use of the conv package, but not of semconv.
+var convUsePattern = regexp.MustCompile(`\bconv\.`)
+
+// removeConvImport drops the conv import if the rewrite removed its last use.
+func removeConvImport(content []byte) []byte {
+	s := string(content)
+	if !strings.Contains(s, convImport) || convUsePattern.MatchString(s) {
+		return content
+	}
+	return []byte(strings.Replace(s, convImport, "", 1))
+}
+
+// replaceConv replaces each call to the conv function from with a call to
+// the helper to, adding layout as the last argument.
+func replaceConv(block []byte, from, to, layout string) ([]byte, int) {
+	s := string(block)
+	count := 0
+	var out strings.Builder
+	for {
+		i := strings.Index(s, from)
+		if i < 0 {
+			out.WriteString(s)
+			break
+		}
+		// Find the matching closing parenthesis of the call.
+		start := i + len(from)
+		depth := 1
+		j := start
+		for ; j < len(s) && depth > 0; j++ {
+			switch s[j] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+		out.WriteString(s[:i])
+		out.WriteString(to)
+		out.WriteString(s[start : j-1])
+		out.WriteString(", ")
+		out.WriteString(strconv.Quote(layout))
+		out.WriteString(")")
+		s = s[j:]
+		count++
+	}
+	return []byte(out.String()), count
+}
+
+// Helpers returns the source of oas_timeparams_gen.go for package pkgName.
+func Helpers(pkgName string) ([]byte, error) {
+	g := gopkg.NewGenerated("ogen-fixtimeparams", types.NewPackage(pkgName, pkgName))
+	g.Import("strconv", "strconv")
+	g.Import("time", "time")
+	g.Printf(`// formatTimeParam formats a date-time parameter with layout. The layouts
+// %[1]q and %[2]q format seconds or milliseconds since the epoch.
+func formatTimeParam(v time.Time, layout string) string {
+	switch layout {
+	case %[1]q:
+		return strconv.FormatInt(v.Unix(), 10)
+	case %[2]q:
+		return strconv.FormatInt(v.UnixMilli(), 10)
+	default:
+		return v.Format(layout)
+	}
+}
+
+// parseTimeParam parses a date-time parameter formatted by formatTimeParam.
+func parseTimeParam(s, layout string) (time.Time, error) {
+	switch layout {
+	case %[1]q:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(n, 0), nil
+	case %[2]q:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(n), nil
+	default:
+		return time.Parse(layout, s)
+	}
+}
+`, layoutUnix, layoutUnixMilli)
+	return g.Bytes()
+}
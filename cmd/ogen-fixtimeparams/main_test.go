@@ -0,0 +1,244 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const timeClientInput = `package api
+
+import (
+	"github.com/ogen-go/ogen/conv"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// GetMetrics invokes getMetrics operation.
+func (c *Client) GetMetrics(ctx context.Context, params GetMetricsParams) (*Metric, error) {
+	res, err := c.sendGetMetrics(ctx, params)
+	return res, err
+}
+
+func (c *Client) sendGetMetrics(ctx context.Context, params GetMetricsParams) (res *Metric, err error) {
+	otelAttrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String("GET"),
+	}
+	{
+		// Encode "at" parameter.
+		e := uri.NewPathEncoder(uri.PathEncoderConfig{
+			Param:   "at",
+			Style:   uri.PathStyleSimple,
+			Explode: false,
+		})
+		if err := func() error {
+			return e.EncodeValue(conv.DateTimeToString(params.At))
+		}(); err != nil {
+			return res, errors.Wrap(err, "encode path")
+		}
+	}
+	{
+		// Encode "since" parameter.
+		cfg := uri.QueryParameterEncodingConfig{
+			Name:    "since",
+			Style:   uri.QueryStyleForm,
+			Explode: true,
+		}
+
+		if err := q.EncodeParam(cfg, func(e uri.Encoder) error {
+			if val, ok := params.Since.Get(); ok {
+				return e.EncodeValue(conv.DateTimeToString(val))
+			}
+			return nil
+		}); err != nil {
+			return res, errors.Wrap(err, "encode query")
+		}
+	}
+	{
+		// Encode "until" parameter.
+		cfg := uri.QueryParameterEncodingConfig{
+			Name:    "until",
+			Style:   uri.QueryStyleForm,
+			Explode: true,
+		}
+
+		if err := q.EncodeParam(cfg, func(e uri.Encoder) error {
+			return e.EncodeValue(conv.DateTimeToString(params.Until))
+		}); err != nil {
+			return res, errors.Wrap(err, "encode query")
+		}
+	}
+	return res, nil
+}
+`
+
+const timeParamsInput = `package api
+
+import (
+	"github.com/ogen-go/ogen/conv"
+)
+
+func decodeGetMetricsParams(args [1]string, argsEscaped bool, r *http.Request) (params GetMetricsParams, _ error) {
+	// Decode path: at.
+	if err := func() error {
+		val, err := d.DecodeValue()
+		if err != nil {
+			return err
+		}
+
+		c, err := conv.ToDateTime(val)
+		if err != nil {
+			return err
+		}
+
+		params.At = c
+		return nil
+	}(); err != nil {
+		return params, err
+	}
+	// Decode query: since.
+	if err := func() error {
+		val, err := d.DecodeValue()
+		if err != nil {
+			return err
+		}
+
+		c, err := conv.ToDateTime(val)
+		if err != nil {
+			return err
+		}
+
+		params.Since.SetTo(c)
+		return nil
+	}(); err != nil {
+		return params, err
+	}
+	return params, nil
+}
+`
+
+var timeOps = map[string]string{"getMetrics": "GetMetrics"}
+
+func TestOperationNames(t *testing.T) {
+	handlers := "// handlePostMetricRequest handles postMetric operation.\n"
+	ops := OperationNames([]byte(timeClientInput), []byte(handlers))
+
+	want := map[string]string{"getMetrics": "GetMetrics", "postMetric": "PostMetric"}
+	for opID, name := range want {
+		if ops[opID] != name {
+			t.Errorf("ops[%q] = %q, want %q", opID, ops[opID], name)
+		}
+	}
+}
+
+func TestFixTimeParams(t *testing.T) {
+	cfg := Config{"getMetrics": {"at": "unix", "until": "2006-01-02 15:04:05"}}
+	client, params, count, err := FixTimeParams([]byte(timeClientInput), []byte(timeParamsInput), timeOps, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+
+	out := string(client)
+	wants := []string{
+		`return e.EncodeValue(formatTimeParam(params.At, "unix"))`,
+		`return e.EncodeValue(conv.DateTimeToString(val))`,
+		`return e.EncodeValue(formatTimeParam(params.Until, "2006-01-02 15:04:05"))`,
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("client missing %q", want)
+		}
+	}
+	if !strings.Contains(out, `"github.com/ogen-go/ogen/conv"`) {
+		t.Error("conv import removed while still in use")
+	}
+
+	out = string(params)
+	if !strings.Contains(out, `c, err := parseTimeParam(val, "unix")`) {
+		t.Error("path parameter decoding not rewritten")
+	}
+	if !strings.Contains(out, "c, err := conv.ToDateTime(val)\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}\n\n\t\tparams.Since") {
+		t.Error("unconfigured parameter should keep RFC 3339")
+	}
+}
+
+func TestFixTimeParamsRemovesConvImport(t *testing.T) {
+	cfg := Config{"getMetrics": {"at": "unix", "since": "unix", "until": "unix"}}
+	client, params, _, err := FixTimeParams([]byte(timeClientInput), []byte(timeParamsInput), timeOps, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, out := range map[string][]byte{"client": client, "params": params} {
+		if strings.Contains(string(out), `"github.com/ogen-go/ogen/conv"`) {
+			t.Errorf("%s: unused conv import left behind", name)
+		}
+	}
+	if !strings.Contains(string(client), "semconv.HTTPRequestMethodKey") {
+		t.Error("semconv use should be untouched")
+	}
+}
+
+func TestFixTimeParamsIdempotent(t *testing.T) {
+	cfg := Config{"getMetrics": {"at": "unix"}}
+	client, params, _, err := FixTimeParams([]byte(timeClientInput), []byte(timeParamsInput), timeOps, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client2, params2, count, err := FixTimeParams(client, params, timeOps, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 || string(client2) != string(client) || string(params2) != string(params) {
+		t.Errorf("second run changed %d conversions", count)
+	}
+}
+
+func TestFixTimeParamsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"unknown operation", Config{"listThings": {"at": "unix"}}, `unknown operation "listThings"`},
+		{"unknown parameter", Config{"getMetrics": {"from": "unix"}}, `getMetrics has no date-time parameter "from"`},
+		{"empty layout", Config{"getMetrics": {"at": ""}}, `getMetrics: empty layout for "at"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := FixTimeParams([]byte(timeClientInput), []byte(timeParamsInput), timeOps, tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	src, err := Helpers("api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parser.ParseFile(token.NewFileSet(), helpersFile, src, 0); err != nil {
+		t.Fatalf("helpers do not parse: %v", err)
+	}
+	out := string(src)
+	wants := []string{
+		"// Code generated by ogen-fixtimeparams, DO NOT EDIT.",
+		"package api",
+		"func formatTimeParam(v time.Time, layout string) string {",
+		"func parseTimeParam(s, layout string) (time.Time, error) {",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("helpers missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Command ogen-fixtrailing makes ogen clients ignore data after the JSON
+// document of a response body.
+//
+// After decoding a JSON response, the generated decoders fail the call if
+// anything but whitespace follows the document:
+//
+//	decode response: unexpected trailing data
+//
+// Some upstreams append a byte order mark, a log line or other junk to their
+// responses. This tool removes the check from the response decoders:
+//
+//	if err := d.Skip(); err != io.EOF {
+//		return errors.New("unexpected trailing data")
+//	}
+//
+// so the document is decoded and the rest of the body is ignored. Request
+// decoders of servers are left strict.
+//
+// Usage:
+//
+//	ogen-fixtrailing <oas_response_decoders_gen.go>
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixtrailing: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-fixtrailing <oas_response_decoders_gen.go>")
+	}
+
+	filename := args[0]
+
+	content, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	fixed, count := RemoveTrailingDataChecks(content)
+
+	if count == 0 {
+		fmt.Printf("No trailing data checks found in %s\n", filename)
+		return nil
+	}
+
+	if err := os.WriteFile(filename, fixed, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	fmt.Printf("Removed %d trailing data checks in %s\n", count, filename)
+	return nil
+}
+
+// checkPattern matches a trailing data check with the line break before it.
+var checkPattern = regexp.MustCompile(
+	`\n\t+if err := d\.Skip\(\); err != io\.EOF \{\n\t+return errors\.New\("unexpected trailing data"\)\n\t+\}`)
+
+// RemoveTrailingDataChecks removes the checks that fail decoding when data
+// follows the JSON document. io and errors stay imported: the decoders use
+// them to read the body and wrap errors.
+func RemoveTrailingDataChecks(content []byte) ([]byte, int) {
+	count := len(checkPattern.FindAllIndex(content, -1))
+	return checkPattern.ReplaceAll(content, nil), count
+}
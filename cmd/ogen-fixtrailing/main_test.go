@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const decodersSource = `package api
+
+func decodeGetUserResponse(resp *http.Response) (res *User, _ error) {
+	switch resp.StatusCode {
+	case 200:
+		buf, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return res, err
+		}
+		d := jx.DecodeBytes(buf)
+
+		var response User
+		if err := func() error {
+			if err := response.Decode(d); err != nil {
+				return err
+			}
+			if err := d.Skip(); err != io.EOF {
+				return errors.New("unexpected trailing data")
+			}
+			return nil
+		}(); err != nil {
+			return res, err
+		}
+		return &response, nil
+	}
+	return res, validate.UnexpectedStatusCodeWithResponse(resp)
+}
+`
+
+func TestRemoveTrailingDataChecks(t *testing.T) {
+	fixed, count := RemoveTrailingDataChecks([]byte(decodersSource))
+	if count != 1 {
+		t.Errorf("count = %d, want 1", count)
+	}
+
+	want := `			if err := response.Decode(d); err != nil {
+				return err
+			}
+			return nil
+`
+	if !strings.Contains(string(fixed), want) {
+		t.Errorf("output does not contain %q:\n%s", want, fixed)
+	}
+	if strings.Contains(string(fixed), "trailing data") {
+		t.Errorf("output still checks trailing data:\n%s", fixed)
+	}
+
+	// A second run finds nothing left to remove.
+	again, count := RemoveTrailingDataChecks(fixed)
+	if count != 0 || string(again) != string(fixed) {
+		t.Errorf("second run: count = %d, changed = %v, want 0, false", count, string(again) != string(fixed))
+	}
+}
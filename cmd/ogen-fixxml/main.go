@@ -0,0 +1,953 @@
+// Command ogen-fixxml encodes the XML request and response bodies of
+// ogen-generated code with encoding/xml.
+//
+// ogen doesn't support XML: operations with only XML bodies fail generation,
+// or are skipped with ignore_not_implemented, and XML alternatives of JSON
+// bodies are dropped. Aliasing the XML content types to JSON in ogen.yml makes
+// ogen generate the operations, parameters, security and validation, with
+// JSON codecs for the bodies:
+//
+//	generator:
+//	  content_type_aliases:
+//	    application/xml: application/json
+//
+// This tool then swaps the JSON codec of every XML body for encoding/xml, in
+// the client and in the server, and adds xml struct tags to the schema types
+// the bodies use, following the xml objects in the spec: element and
+// attribute names, namespaces and wrapped arrays. Opt, OptNil and Nil
+// wrappers get XML methods, so unset fields are omitted and null fields are
+// sent as xsi:nil.
+//
+// Usage:
+//
+//	ogen --config ogen.yml --package api --target internal/api --clean openapi.json
+//	ogen-fixxml -spec openapi.json internal/api
+//
+// Without -spec, elements are named after the JSON fields and root elements
+// after the Go types. The XML helpers are written to oas_xml_gen.go.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// outputFile is the file the XML helpers are written to.
+const outputFile = "oas_xml_gen.go"
+
+// codecFiles are the generated files holding body codecs.
+var codecFiles = []string{
+	"oas_request_encoders_gen.go",
+	"oas_response_decoders_gen.go",
+	"oas_request_decoders_gen.go",
+	"oas_response_encoders_gen.go",
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-fixxml: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-fixxml", flag.ContinueOnError)
+	specFile := fs.String("spec", "", "OpenAPI spec with the xml objects of the schemas")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: ogen-fixxml [-spec openapi.json] <generated-dir>")
+	}
+
+	var spec map[string]any
+	if *specFile != "" {
+		var err error
+		spec, err = specdoc.ReadFileMap(*specFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	dir := fs.Arg(0)
+	files := make(map[string][]byte)
+	var bodies []Body
+	for _, name := range codecFiles {
+		content, err := os.ReadFile(filepath.Join(dir, name)) // #nosec G703 -- CLI tool, filename from trusted args
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		files[name] = content
+		bodies = append(bodies, FindXMLBodies(name, content)...)
+	}
+
+	if len(bodies) == 0 {
+		fmt.Printf("No XML bodies found in %s\n", dir)
+		return nil
+	}
+
+	// The output file stays in: bodies fixed by an earlier run call its
+	// helpers.
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	x := newFixer(pkg, spec)
+	edits := make(map[string][]gopkg.Edit)
+	fixed := 0
+	for _, b := range bodies {
+		root, err := x.root(b)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ogen-fixxml: %s: %v, body left as JSON\n", b.Func, err)
+			continue
+		}
+		edits[b.File] = append(edits[b.File], b.edits(root)...)
+		fixed++
+	}
+
+	for _, field := range x.maps {
+		fmt.Fprintf(os.Stderr, "ogen-fixxml: %s: maps have no XML form, field left out with xml:\"-\"\n", field)
+	}
+
+	if fixed == 0 {
+		fmt.Printf("No XML bodies fixed in %s\n", dir)
+		return nil
+	}
+
+	gen, err := x.generate()
+	if err != nil {
+		return err
+	}
+
+	if _, err := pkg.Write(); err != nil {
+		return err
+	}
+	for name, content := range files {
+		if len(edits[name]) == 0 {
+			continue
+		}
+		content, err := format.Source(gopkg.FixImports(gopkg.ApplyEdits(content, edits[name]), "encoding/xml", "github.com/go-faster/errors", "github.com/go-faster/jx"))
+		if err != nil {
+			return fmt.Errorf("format %s: %w", name, err)
+		}
+		// #nosec G703 -- CLI tool, filename from trusted args
+		if err := os.WriteFile(filepath.Join(pkg.Dir, name), content, 0600); err != nil {
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+	if err := gen.WriteFile(filepath.Join(pkg.Dir, outputFile)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Fixed %d XML bodies and tagged %d types in %s\n", fixed, len(x.structs), dir)
+	return nil
+}
+
+// bodyKind is where a body codec sits.
+type bodyKind int
+
+const (
+	encodeRequest  bodyKind = iota // client request encoder
+	decodeResponse                 // client response decoder
+	decodeRequest                  // server request decoder
+	encodeResponse                 // server response encoder
+)
+
+// Body is the JSON codec of an XML request or response body.
+type Body struct {
+	// File is the base name of the generated file.
+	File string
+	// Func is the generated function, for messages.
+	Func string
+	Kind bodyKind
+	// Type is the Go type of the body as spelled in the code, e.g. OptOrder.
+	Type string
+	// Value is the variable holding the body, e.g. req or response. It is
+	// response.Response when the body is the Response field of Type.
+	Value string
+
+	// start and end delimit the JSON codec code.
+	start, end int
+	// header is where a server response body is marshaled, before the
+	// response status is written.
+	header int
+	indent string
+}
+
+var (
+	funcPattern = regexp.MustCompile(`(?m)^func (?:\(s \*Server\) )?(\w+)\(`)
+
+	// requestEncoderPattern matches a client request encoder; only
+	// encoders with a single content type are plain functions like this.
+	requestEncoderPattern = regexp.MustCompile(
+		`(?ms)^func (encode\w+Request)\(\n\treq (\S+),\n\tr \*http\.Request,\n\) error \{\n\tconst contentType = "([^"]+)"\n.*?^\}\n`)
+	requestEncodePattern = regexp.MustCompile(
+		`\te := new\(jx\.Encoder\)\n\t\{\n(\t\t(?:if req\.Set \{\n\t\t\t)?req\.Encode\(e\)\n(?:\t\t\}\n)?)\t\}\n\tencoded := e\.Bytes\(\)\n`)
+
+	contentCasePattern = regexp.MustCompile(`(?m)^\t+case ct == "([^"]+)":\n`)
+	decodePattern      = regexp.MustCompile(
+		`^(\t+)d := jx\.DecodeBytes\(buf\)\n\n\t+var (response|request) (\w+)\n\t+if err := func\(\) error \{\n` +
+			`(?:\t+(?:response|request)\.Reset\(\)\n)?` +
+			`\t+if err := (?:response|request)\.Decode\(d\); err != nil \{\n\t+return err\n\t+\}\n` +
+			`\t+if err := d\.Skip\(\); err != io\.EOF \{\n\t+return errors\.New\("unexpected trailing data"\)\n\t+\}\n` +
+			`\t+return nil\n\t+\}\(\); err != nil \{\n`)
+
+	contentHeaderPattern = regexp.MustCompile(`(?m)^(\t+)w\.Header\(\)\.Set\("Content-Type", "([^"]+)"\)\n`)
+	responseCasePattern  = regexp.MustCompile(
+		`(?m)^\tcase (\*?\w+):\n|^func encode\w+Response\(response (\S+), w http\.ResponseWriter\b`)
+	encodePattern = regexp.MustCompile(
+		`(?m)^\t+e := new\(jx\.Encoder\)\n\t+(response(?:\.Response)?)\.Encode\(e\)\n\t+if _, err := e\.WriteTo\(w\); err != nil \{\n`)
+)
+
+// isXML reports whether a content type is XML: application/xml, text/xml, or
+// a +xml type such as application/soap+xml.
+func isXML(contentType string) bool {
+	return contentType == "application/xml" || contentType == "text/xml" || strings.HasSuffix(contentType, "+xml")
+}
+
+// FindXMLBodies finds the JSON codecs of XML bodies in a generated file.
+// Bodies of types ogen doesn't encode with an Encode method, such as strings
+// and arrays, are not found.
+func FindXMLBodies(file string, content []byte) []Body {
+	switch file {
+	case "oas_request_encoders_gen.go":
+		return findRequestEncoders(file, content)
+	case "oas_response_encoders_gen.go":
+		return findResponseEncoders(file, content)
+	default:
+		kind := decodeResponse
+		if file == "oas_request_decoders_gen.go" {
+			kind = decodeRequest
+		}
+		return findDecoders(file, content, kind)
+	}
+}
+
+func findRequestEncoders(file string, content []byte) []Body {
+	var bodies []Body
+	for _, m := range requestEncoderPattern.FindAllSubmatchIndex(content, -1) {
+		if !isXML(string(content[m[6]:m[7]])) {
+			continue
+		}
+		loc := requestEncodePattern.FindIndex(content[m[0]:m[1]])
+		if loc == nil {
+			continue
+		}
+		bodies = append(bodies, Body{
+			File:   file,
+			Func:   string(content[m[2]:m[3]]),
+			Kind:   encodeRequest,
+			Type:   string(content[m[4]:m[5]]),
+			Value:  "req",
+			start:  m[0] + loc[0],
+			end:    m[0] + loc[1],
+			indent: "\t",
+		})
+	}
+	return bodies
+}
+
+func findDecoders(file string, content []byte, kind bodyKind) []Body {
+	cases := contentCasePattern.FindAllSubmatchIndex(content, -1)
+	var bodies []Body
+	for n, m := range cases {
+		if !isXML(string(content[m[2]:m[3]])) {
+			continue
+		}
+		// The codec is the first one after the case, and before the next
+		// case or function.
+		end := len(content)
+		if n+1 < len(cases) {
+			end = cases[n+1][0]
+		}
+		if f := strings.Index(string(content[m[1]:end]), "\nfunc "); f >= 0 {
+			end = m[1] + f
+		}
+		i := strings.Index(string(content[m[1]:end]), "d := jx.DecodeBytes(buf)")
+		if i < 0 {
+			continue
+		}
+		start := lineStart(content, m[1]+i)
+		d := decodePattern.FindSubmatchIndex(content[start:])
+		if d == nil {
+			continue
+		}
+		bodies = append(bodies, Body{
+			File:   file,
+			Func:   enclosingFunc(content, start),
+			Kind:   kind,
+			Type:   string(content[start+d[6] : start+d[7]]),
+			Value:  string(content[start+d[4] : start+d[5]]),
+			start:  start,
+			end:    start + d[1],
+			indent: string(content[start+d[2] : start+d[3]]),
+		})
+	}
+	return bodies
+}
+
+func findResponseEncoders(file string, content []byte) []Body {
+	headers := contentHeaderPattern.FindAllSubmatchIndex(content, -1)
+	var bodies []Body
+	for i, m := range headers {
+		if !isXML(string(content[m[4]:m[5]])) {
+			continue
+		}
+		// The codec is the first one after the header, and before the
+		// next response.
+		end := len(content)
+		if i+1 < len(headers) {
+			end = headers[i+1][0]
+		}
+		e := encodePattern.FindSubmatchIndex(content[m[1]:end])
+		if e == nil {
+			continue
+		}
+
+		// The type is that of the case of the type switch on responses,
+		// or of the function parameter for a single response.
+		var typ string
+		for _, c := range responseCasePattern.FindAllSubmatch(content[:m[0]], -1) {
+			typ = string(c[1]) + string(c[2])
+		}
+		value := string(content[m[1]+e[2] : m[1]+e[3]])
+		if value == "response.Response" {
+			typ = strings.TrimPrefix(typ, "*")
+		}
+
+		bodies = append(bodies, Body{
+			File:   file,
+			Func:   enclosingFunc(content, m[0]),
+			Kind:   encodeResponse,
+			Type:   typ,
+			Value:  value,
+			start:  m[1] + e[0],
+			end:    m[1] + e[1],
+			header: m[0],
+			indent: string(content[m[2]:m[3]]),
+		})
+	}
+	return bodies
+}
+
+func lineStart(content []byte, i int) int {
+	for i > 0 && content[i-1] != '\n' {
+		i--
+	}
+	return i
+}
+
+func enclosingFunc(content []byte, i int) string {
+	var name string
+	for _, m := range funcPattern.FindAllSubmatch(content[:i], -1) {
+		name = string(m[1])
+	}
+	return name
+}
+
+// edits returns the edits swapping the JSON codec of b for encoding/xml,
+// with root as the root element of encoded bodies.
+func (b Body) edits(root string) []gopkg.Edit {
+	in := b.indent
+	switch b.Kind {
+	case encodeRequest:
+		return []gopkg.Edit{{Start: b.start, End: b.end, Text: fmt.Sprintf(
+			"%sencoded, err := xmlMarshal(%s, %s)\n%sif err != nil {\n%s\treturn errors.Wrap(err, \"encode xml\")\n%s}\n",
+			in, b.Value, root, in, in, in)}}
+	case encodeResponse:
+		return []gopkg.Edit{
+			gopkg.Insert(b.header, fmt.Sprintf(
+				"%sencoded, err := xmlMarshal(%s, %s)\n%sif err != nil {\n%s\treturn errors.Wrap(err, \"encode xml\")\n%s}\n",
+				in, b.Value, root, in, in, in)),
+			{Start: b.start, End: b.end, Text: in + "if _, err := w.Write(encoded); err != nil {\n"},
+		}
+	default:
+		return []gopkg.Edit{{Start: b.start, End: b.end, Text: fmt.Sprintf(
+			"%svar %s %s\n%sif err := xml.Unmarshal(buf, &%s); err != nil {\n",
+			in, b.Value, b.Type, in, b.Value)}}
+	}
+}
+
+// wrapperUse records how an Opt, OptNil or Nil wrapper appears in XML.
+type wrapperUse struct {
+	kind          gopkg.Wrapper
+	value         types.Type
+	element, attr bool
+}
+
+// fixer resolves XML names from the spec and tags the types of XML bodies.
+type fixer struct {
+	pkg     *gopkg.Package
+	schemas map[string]map[string]any
+	// components maps normalized names to component schema names.
+	components map[string]string
+	typeSpecs  map[string]*ast.TypeSpec
+	fields     map[token.Pos]*ast.Field
+
+	structs  map[*types.Struct]bool
+	wrappers map[*types.Named]*wrapperUse
+	// maps lists the map fields left out of XML, as Type.field.
+	maps []string
+}
+
+func newFixer(pkg *gopkg.Package, spec map[string]any) *fixer {
+	x := &fixer{
+		pkg:        pkg,
+		schemas:    make(map[string]map[string]any),
+		components: make(map[string]string),
+		typeSpecs:  make(map[string]*ast.TypeSpec),
+		fields:     make(map[token.Pos]*ast.Field),
+		structs:    make(map[*types.Struct]bool),
+		wrappers:   make(map[*types.Named]*wrapperUse),
+	}
+
+	components, _ := spec["components"].(map[string]any)
+	schemas, _ := components["schemas"].(map[string]any)
+	ambiguous := make(map[string]bool)
+	for name, s := range schemas {
+		schema, ok := s.(map[string]any)
+		if !ok {
+			continue
+		}
+		x.schemas[name] = schema
+		key := normalize(name)
+		if _, ok := x.components[key]; ok {
+			ambiguous[key] = true
+		}
+		x.components[key] = name
+	}
+	for key := range ambiguous {
+		delete(x.components, key)
+	}
+
+	for _, file := range pkg.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.TypeSpec:
+				x.typeSpecs[n.Name.Name] = n
+			case *ast.Field:
+				if len(n.Names) == 1 {
+					x.fields[n.Names[0].Pos()] = n
+				}
+			}
+			return true
+		})
+	}
+	return x
+}
+
+// normalize reduces a schema or type name to lower-case letters and digits,
+// so that order_item matches OrderItem.
+func normalize(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// root returns the xml.Name expression of the root element of b, and records
+// the types b uses.
+func (x *fixer) root(b Body) (string, error) {
+	obj, ok := x.pkg.Types.Scope().Lookup(strings.TrimPrefix(b.Type, "*")).(*types.TypeName)
+	if !ok {
+		return "", fmt.Errorf("type %s not found", b.Type)
+	}
+	t := obj.Type()
+	if b.Value == "response.Response" {
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			return "", fmt.Errorf("%s has no Response field", b.Type)
+		}
+		t = nil
+		for i := range st.NumFields() {
+			if st.Field(i).Name() == "Response" {
+				t = st.Field(i).Type()
+			}
+		}
+		if t == nil {
+			return "", fmt.Errorf("%s has no Response field", b.Type)
+		}
+	}
+
+	named := x.elementType(t)
+	if named == nil {
+		return "", fmt.Errorf("%s is not a named type", b.Type)
+	}
+	switch named.Underlying().(type) {
+	case *types.Slice, *types.Map, *types.Interface:
+		return "", fmt.Errorf("%s: only objects and primitives are supported as XML bodies", b.Type)
+	}
+
+	name, schema := x.component(named)
+	local, space := name, ""
+	if xmlObj, ok := schema["xml"].(map[string]any); ok {
+		if n, ok := xmlObj["name"].(string); ok && n != "" {
+			local = n
+		}
+		space, _ = xmlObj["namespace"].(string)
+	}
+
+	if err := x.visit(t, schema); err != nil {
+		return "", err
+	}
+
+	if space != "" {
+		return fmt.Sprintf("xml.Name{Space: %q, Local: %q}", space, local), nil
+	}
+	return fmt.Sprintf("xml.Name{Local: %q}", local), nil
+}
+
+// elementType strips pointers and wrappers from t.
+func (x *fixer) elementType(t types.Type) *types.Named {
+	for {
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+			continue
+		}
+		if w, v := gopkg.Unwrap(t); w != gopkg.NotWrapped {
+			t = v
+			continue
+		}
+		named, _ := t.(*types.Named)
+		return named
+	}
+}
+
+// component returns the schema name and the component schema of named,
+// following ogen's type definitions such as
+// type GetOrderApplicationXMLOK Order. Without a component it returns the
+// name of the type and a nil schema.
+func (x *fixer) component(named *types.Named) (string, map[string]any) {
+	name := named.Obj().Name()
+	for range 8 {
+		spec, ok := x.typeSpecs[name]
+		if !ok {
+			break
+		}
+		ident, ok := spec.Type.(*ast.Ident)
+		if !ok || x.typeSpecs[ident.Name] == nil {
+			break
+		}
+		name = ident.Name
+	}
+	if component, ok := x.components[normalize(name)]; ok {
+		return component, x.schemas[component]
+	}
+	return name, nil
+}
+
+// resolve follows a local $ref to a component schema.
+func (x *fixer) resolve(schema map[string]any) map[string]any {
+	for range 32 {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema
+		}
+		name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+		if !ok {
+			return nil
+		}
+		schema = x.schemas[name]
+	}
+	return nil
+}
+
+// visit records the structs and wrappers used by an XML element of type t,
+// and tags the struct fields with their XML names from schema.
+func (x *fixer) visit(t types.Type, schema map[string]any) error {
+	switch t := t.(type) {
+	case *types.Pointer:
+		return x.visit(t.Elem(), schema)
+	case *types.Slice:
+		items, _ := x.resolve(schema)["items"].(map[string]any)
+		return x.visit(t.Elem(), items)
+	case *types.Named:
+		if t.Obj().Pkg() != x.pkg.Types {
+			return nil
+		}
+		if w, v := gopkg.Unwrap(t); w != gopkg.NotWrapped {
+			x.wrapper(t, w, v).element = true
+			return x.visit(v, schema)
+		}
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok || x.structs[st] {
+			return nil
+		}
+		x.structs[st] = true
+		if _, component := x.component(t); component != nil {
+			schema = component
+		}
+		return x.tagFields(t.Obj().Name(), st, x.resolve(schema))
+	}
+	return nil
+}
+
+func (x *fixer) wrapper(t *types.Named, kind gopkg.Wrapper, value types.Type) *wrapperUse {
+	use, ok := x.wrappers[t]
+	if !ok {
+		use = &wrapperUse{kind: kind, value: value}
+		x.wrappers[t] = use
+	}
+	return use
+}
+
+// tagFields adds an xml tag to every field of st, the struct of the type
+// named typeName.
+func (x *fixer) tagFields(typeName string, st *types.Struct, schema map[string]any) error {
+	props, _ := schema["properties"].(map[string]any)
+	for i := range st.NumFields() {
+		f := st.Field(i)
+		jsonName, _, _ := strings.Cut(reflect.StructTag(st.Tag(i)).Get("json"), ",")
+		if jsonName == "" || jsonName == "-" {
+			x.setTag(f, "-")
+			continue
+		}
+
+		prop, _ := props[jsonName].(map[string]any)
+		xmlObj, _ := prop["xml"].(map[string]any)
+		name := jsonName
+		if n, ok := xmlObj["name"].(string); ok && n != "" {
+			name = n
+		}
+		attr, _ := xmlObj["attribute"].(bool)
+		space, _ := xmlObj["namespace"].(string)
+
+		if isMap(f.Type()) {
+			x.setTag(f, "-")
+			x.maps = append(x.maps, typeName+"."+jsonName)
+			continue
+		}
+
+		t := f.Type()
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+		}
+
+		var tag string
+		switch {
+		case attr:
+			tag = name + ",attr"
+			if named, ok := t.(*types.Named); ok {
+				if w, v := gopkg.Unwrap(named); w != gopkg.NotWrapped {
+					x.wrapper(named, w, v).attr = true
+				}
+			}
+		case isSlice(t):
+			items, _ := x.resolve(prop)["items"].(map[string]any)
+			item := xmlName(items)
+			if item == "" {
+				item = xmlName(x.resolve(items))
+			}
+			wrapped, _ := xmlObj["wrapped"].(bool)
+			switch {
+			case wrapped && item != "":
+				tag = name + ">" + item
+			case wrapped:
+				tag = name + ">" + name
+			case item != "":
+				tag = item
+			default:
+				tag = name
+			}
+			space = ""
+			if err := x.visit(f.Type(), prop); err != nil {
+				return err
+			}
+		default:
+			tag = name
+			if err := x.visit(f.Type(), prop); err != nil {
+				return err
+			}
+		}
+		if space != "" {
+			tag = space + " " + tag
+		}
+		x.setTag(f, tag)
+	}
+	return nil
+}
+
+// isMap reports whether t, without pointers and ogen wrappers, is a map.
+// encoding/xml cannot encode maps.
+func isMap(t types.Type) bool {
+	for {
+		if p, ok := t.(*types.Pointer); ok {
+			t = p.Elem()
+			continue
+		}
+		if w, v := gopkg.Unwrap(t); w != gopkg.NotWrapped {
+			t = v
+			continue
+		}
+		_, ok := t.Underlying().(*types.Map)
+		return ok
+	}
+}
+
+func isSlice(t types.Type) bool {
+	s, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	b, ok := s.Elem().(*types.Basic)
+	return !ok || b.Kind() != types.Byte
+}
+
+func xmlName(schema map[string]any) string {
+	xmlObj, _ := schema["xml"].(map[string]any)
+	name, _ := xmlObj["name"].(string)
+	return name
+}
+
+// setTag sets the xml key of the struct tag of f, keeping the other keys.
+func (x *fixer) setTag(f *types.Var, value string) {
+	field, ok := x.fields[f.Pos()]
+	if !ok {
+		return
+	}
+
+	var tag string
+	if field.Tag != nil {
+		tag, _ = strconv.Unquote(field.Tag.Value)
+	}
+	tag = strings.TrimSpace(xmlTagPattern.ReplaceAllString(tag, ""))
+	if tag != "" {
+		tag += " "
+	}
+	tag += `xml:"` + value + `"`
+
+	if field.Tag == nil {
+		field.Tag = &ast.BasicLit{Kind: token.STRING, ValuePos: field.Type.End()}
+	}
+	field.Tag.Value = "`" + tag + "`"
+	x.pkg.MarkChanged(x.pkg.Fset.Position(f.Pos()).Filename)
+}
+
+var xmlTagPattern = regexp.MustCompile(`\s*xml:"[^"]*"`)
+
+// generate writes the XML helpers and the methods of the wrappers.
+func (x *fixer) generate() (*gopkg.Generated, error) {
+	scope := x.pkg.Types.Scope()
+	for _, decl := range []string{"xmlMarshal", "xmlEncodeNil", "xmlIsNil"} {
+		if x.declaredElsewhere(scope.Lookup(decl)) {
+			return nil, fmt.Errorf("%s is already declared", decl)
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-fixxml", x.pkg.Types)
+	gen.Import("bytes", "bytes")
+	gen.Import("encoding/xml", "xml")
+	gen.Printf("%s", helpersSource)
+
+	var names []*types.Named
+	for t := range x.wrappers {
+		names = append(names, t)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i].Obj().Name() < names[j].Obj().Name() })
+
+	for _, t := range names {
+		use := x.wrappers[t]
+		for _, m := range []string{"MarshalXML", "UnmarshalXML", "MarshalXMLAttr", "UnmarshalXMLAttr"} {
+			if obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), true, x.pkg.Types, m); x.declaredElsewhere(obj) {
+				return nil, fmt.Errorf("%s.%s is already declared", t.Obj().Name(), m)
+			}
+		}
+		if use.element {
+			writeElementMethods(gen, t.Obj().Name(), use.kind)
+		}
+		if use.attr {
+			if err := writeAttrMethods(gen, t.Obj().Name(), use); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return gen, nil
+}
+
+// declaredElsewhere reports whether obj is declared outside the output file,
+// which is rewritten.
+func (x *fixer) declaredElsewhere(obj types.Object) bool {
+	return obj != nil && filepath.Base(x.pkg.Fset.Position(obj.Pos()).Filename) != outputFile
+}
+
+const helpersSource = `// xmlMarshal encodes v as an XML document with the given root element.
+func xmlMarshal(v any, root xml.Name) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	e := xml.NewEncoder(&buf)
+	if err := e.EncodeElement(v, xml.StartElement{Name: root}); err != nil {
+		return nil, err
+	}
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// xmlEncodeNil encodes a null value as an empty element with xsi:nil="true".
+func xmlEncodeNil(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr,
+		xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"},
+		xml.Attr{Name: xml.Name{Local: "xsi:nil"}, Value: "true"},
+	)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// xmlIsNil reports whether an element has xsi:nil="true".
+func xmlIsNil(start xml.StartElement) bool {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "nil" && attr.Value == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+`
+
+// writeElementMethods writes MarshalXML and UnmarshalXML for a wrapper.
+func writeElementMethods(gen *gopkg.Generated, name string, kind gopkg.Wrapper) {
+	gen.Printf("// MarshalXML encodes o as an element if it is set.\n")
+	gen.Printf("func (o %s) MarshalXML(e *xml.Encoder, start xml.StartElement) error {\n", name)
+	if kind != gopkg.Nil {
+		gen.Printf("\tif !o.Set {\n\t\treturn nil\n\t}\n")
+	}
+	if kind != gopkg.Opt {
+		gen.Printf("\tif o.Null {\n\t\treturn xmlEncodeNil(e, start)\n\t}\n")
+	}
+	gen.Printf("\treturn e.EncodeElement(o.Value, start)\n}\n\n")
+
+	gen.Printf("// UnmarshalXML decodes o from an element.\n")
+	gen.Printf("func (o *%s) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {\n", name)
+	if kind != gopkg.Nil {
+		gen.Printf("\to.Set = true\n")
+	}
+	if kind != gopkg.Opt {
+		gen.Printf("\tif xmlIsNil(start) {\n\t\to.Null = true\n\t\treturn d.Skip()\n\t}\n")
+	}
+	gen.Printf("\treturn d.DecodeElement(&o.Value, &start)\n}\n\n")
+}
+
+// writeAttrMethods writes MarshalXMLAttr and UnmarshalXMLAttr for a wrapper
+// of a primitive or a text marshaler. Null attributes are omitted.
+func writeAttrMethods(gen *gopkg.Generated, name string, use *wrapperUse) error {
+	format, parse, err := attrConversion(gen, use.value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	var unset string
+	switch use.kind {
+	case gopkg.Opt:
+		unset = "!o.Set"
+	case gopkg.OptNil:
+		unset = "!o.Set || o.Null"
+	case gopkg.Nil:
+		unset = "o.Null"
+	}
+
+	gen.Printf("// MarshalXMLAttr encodes o as an attribute if it is set.\n")
+	gen.Printf("func (o %s) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {\n", name)
+	gen.Printf("\tif %s {\n\t\treturn xml.Attr{}, nil\n\t}\n", unset)
+	gen.Printf("%s}\n\n", format)
+
+	gen.Printf("// UnmarshalXMLAttr decodes o from an attribute.\n")
+	gen.Printf("func (o *%s) UnmarshalXMLAttr(attr xml.Attr) error {\n", name)
+	gen.Printf("%s", parse)
+	if use.kind != gopkg.Nil {
+		gen.Printf("\to.Set = true\n")
+	}
+	gen.Printf("\treturn nil\n}\n\n")
+	return nil
+}
+
+// attrConversion returns the statements converting o.Value to and from an
+// attribute value.
+func attrConversion(gen *gopkg.Generated, t types.Type) (format, parse string, err error) {
+	if hasMethod(t, "MarshalText") && hasMethod(types.NewPointer(t), "UnmarshalText") {
+		return "\ttext, err := o.Value.MarshalText()\n\tif err != nil {\n\t\treturn xml.Attr{}, err\n\t}\n" +
+				"\treturn xml.Attr{Name: name, Value: string(text)}, nil\n",
+			"\tif err := o.Value.UnmarshalText([]byte(attr.Value)); err != nil {\n\t\treturn err\n\t}\n",
+			nil
+	}
+
+	b, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return "", "", fmt.Errorf("%s can't be an XML attribute", t)
+	}
+	typ := gen.TypeString(t)
+	value := func(expr string) string {
+		return "\treturn xml.Attr{Name: name, Value: " + expr + "}, nil\n"
+	}
+	parsed := func(call string) string {
+		return "\tv, err := " + call + "\n\tif err != nil {\n\t\treturn err\n\t}\n\to.Value = " + typ + "(v)\n"
+	}
+
+	info := b.Info()
+	switch {
+	case info&types.IsString != 0:
+		return value("string(o.Value)"), "\to.Value = " + typ + "(attr.Value)\n", nil
+	case info&types.IsBoolean != 0:
+		gen.Import("strconv", "strconv")
+		return value("strconv.FormatBool(bool(o.Value))"), parsed("strconv.ParseBool(attr.Value)"), nil
+	case info&types.IsUnsigned != 0:
+		gen.Import("strconv", "strconv")
+		return value("strconv.FormatUint(uint64(o.Value), 10)"),
+			parsed(fmt.Sprintf("strconv.ParseUint(attr.Value, 10, %d)", bitSize(b))), nil
+	case info&types.IsInteger != 0:
+		gen.Import("strconv", "strconv")
+		return value("strconv.FormatInt(int64(o.Value), 10)"),
+			parsed(fmt.Sprintf("strconv.ParseInt(attr.Value, 10, %d)", bitSize(b))), nil
+	case info&types.IsFloat != 0:
+		gen.Import("strconv", "strconv")
+		return value(fmt.Sprintf("strconv.FormatFloat(float64(o.Value), 'g', -1, %d)", bitSize(b))),
+			parsed(fmt.Sprintf("strconv.ParseFloat(attr.Value, %d)", bitSize(b))), nil
+	}
+	return "", "", fmt.Errorf("%s can't be an XML attribute", t)
+}
+
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// bitSize returns the size strconv parses a basic type with; 0 is int.
+func bitSize(b *types.Basic) int {
+	switch b.Kind() {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32, types.Float32:
+		return 32
+	case types.Int64, types.Uint64, types.Float64:
+		return 64
+	}
+	return 0
+}
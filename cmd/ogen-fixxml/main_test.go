@@ -0,0 +1,406 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const schemasSource = `package api
+
+import "github.com/go-faster/jx"
+
+type Order struct {
+	ID       string            ` + "`json:\"id\"`" + `
+	Version  OptInt            ` + "`json:\"version\"`" + `
+	Note     OptNilString      ` + "`json:\"note\"`" + `
+	Customer Customer          ` + "`json:\"customer\"`" + `
+	Items    []string          ` + "`json:\"items\"`" + `
+	Labels   OrderLabels       ` + "`json:\"labels\"`" + `
+	Meta     OptOrderLabels    ` + "`json:\"meta\"`" + `
+	Extra    map[string]string
+}
+
+type OrderLabels map[string]string
+
+type OptOrderLabels struct {
+	Value OrderLabels
+	Set   bool
+}
+
+func (s *Order) Encode(e *jx.Encoder)       {}
+func (s *Order) Decode(d *jx.Decoder) error { return nil }
+
+type Customer struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+
+type Orders []Order
+
+func (s Orders) Encode(e *jx.Encoder) {}
+
+type GetOrderApplicationXMLOK Order
+
+func (s *GetOrderApplicationXMLOK) Decode(d *jx.Decoder) error { return nil }
+
+type Fault struct {
+	Message string ` + "`json:\"message\"`" + `
+}
+
+func (s *Fault) Encode(e *jx.Encoder) {}
+
+type FaultStatusCode struct {
+	StatusCode int
+	Response   Fault
+}
+
+type GetOrderRes interface{ getOrderRes() }
+
+func (*GetOrderApplicationXMLOK) getOrderRes() {}
+func (*FaultStatusCode) getOrderRes()          {}
+
+type OptInt struct {
+	Value int
+	Set   bool
+}
+
+type OptNilString struct {
+	Value string
+	Set   bool
+	Null  bool
+}
+`
+
+const encodersSource = `package api
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/go-faster/jx"
+	ht "github.com/ogen-go/ogen/http"
+)
+
+func encodeCreateOrderRequest(
+	req *Order,
+	r *http.Request,
+) error {
+	const contentType = "application/xml"
+	e := new(jx.Encoder)
+	{
+		req.Encode(e)
+	}
+	encoded := e.Bytes()
+	ht.SetBody(r, bytes.NewReader(encoded), contentType)
+	return nil
+}
+
+func encodeCreateOrdersRequest(
+	req Orders,
+	r *http.Request,
+) error {
+	const contentType = "application/xml"
+	e := new(jx.Encoder)
+	{
+		req.Encode(e)
+	}
+	encoded := e.Bytes()
+	ht.SetBody(r, bytes.NewReader(encoded), contentType)
+	return nil
+}
+
+func encodeUpdateOrderRequest(
+	req *Order,
+	r *http.Request,
+) error {
+	const contentType = "application/json"
+	e := new(jx.Encoder)
+	{
+		req.Encode(e)
+	}
+	encoded := e.Bytes()
+	ht.SetBody(r, bytes.NewReader(encoded), contentType)
+	return nil
+}
+`
+
+const decodersSource = `package api
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+
+	"github.com/ogen-go/ogen/validate"
+)
+
+func decodeGetOrderResponse(resp *http.Response) (res GetOrderRes, _ error) {
+	switch resp.StatusCode {
+	case 200:
+		// Code 200.
+		ct, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if err != nil {
+			return res, errors.Wrap(err, "parse media type")
+		}
+		switch {
+		case ct == "application/soap+xml":
+			buf, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return res, err
+			}
+			d := jx.DecodeBytes(buf)
+
+			var response GetOrderApplicationXMLOK
+			if err := func() error {
+				if err := response.Decode(d); err != nil {
+					return err
+				}
+				if err := d.Skip(); err != io.EOF {
+					return errors.New("unexpected trailing data")
+				}
+				return nil
+			}(); err != nil {
+				return res, errors.Wrap(err, "decode body")
+			}
+			return &response, nil
+		default:
+			return res, validate.InvalidContentType(ct)
+		}
+	}
+	return res, validate.UnexpectedStatusCodeWithResponse(resp)
+}
+`
+
+const responseEncodersSource = `package api
+
+import (
+	"net/http"
+
+	"github.com/go-faster/errors"
+	"github.com/go-faster/jx"
+)
+
+func encodeGetOrderResponse(response GetOrderRes, w http.ResponseWriter) error {
+	switch response := response.(type) {
+	case *FaultStatusCode:
+		w.Header().Set("Content-Type", "text/xml")
+		w.WriteHeader(response.StatusCode)
+
+		e := new(jx.Encoder)
+		response.Response.Encode(e)
+		if _, err := e.WriteTo(w); err != nil {
+			return errors.Wrap(err, "write")
+		}
+
+		return nil
+
+	default:
+		return errors.Errorf("unexpected response type: %T", response)
+	}
+}
+`
+
+const specSource = `{
+  "components": {
+    "schemas": {
+      "Order": {
+        "type": "object",
+        "xml": {"name": "order", "namespace": "urn:shop"},
+        "properties": {
+          "id": {"type": "string", "xml": {"attribute": true}},
+          "version": {"type": "integer", "xml": {"attribute": true}},
+          "note": {"type": "string", "nullable": true, "xml": {"name": "remark"}},
+          "customer": {"$ref": "#/components/schemas/Customer"},
+          "items": {"type": "array", "xml": {"wrapped": true}, "items": {"type": "string", "xml": {"name": "item"}}},
+          "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+          "meta": {"type": "object", "additionalProperties": {"type": "string"}}
+        }
+      },
+      "Customer": {"type": "object", "properties": {"name": {"type": "string"}}},
+      "Fault": {"type": "object", "xml": {"name": "fault"}, "properties": {"message": {"type": "string"}}}
+    }
+  }
+}`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource)
+	writeFile(t, filepath.Join(dir, "oas_request_encoders_gen.go"), encodersSource)
+	writeFile(t, filepath.Join(dir, "oas_response_decoders_gen.go"), decodersSource)
+	writeFile(t, filepath.Join(dir, "oas_response_encoders_gen.go"), responseEncodersSource)
+	specFile := filepath.Join(t.TempDir(), "openapi.json")
+	writeFile(t, specFile, specSource)
+
+	if err := run([]string{"-spec", specFile, dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	for file, wants := range map[string][]string{
+		"oas_schemas_gen.go": {
+			"ID       string            `json:\"id\" xml:\"id,attr\"`",
+			"Version  OptInt            `json:\"version\" xml:\"version,attr\"`",
+			"Note     OptNilString      `json:\"note\" xml:\"remark\"`",
+			"Customer Customer          `json:\"customer\" xml:\"customer\"`",
+			"Items    []string          `json:\"items\" xml:\"items>item\"`",
+			// encoding/xml cannot encode maps.
+			"Labels   OrderLabels       `json:\"labels\" xml:\"-\"`",
+			"Meta     OptOrderLabels    `json:\"meta\" xml:\"-\"`",
+			"Extra    map[string]string `xml:\"-\"`",
+			"Name string `json:\"name\" xml:\"name\"`",
+		},
+		"oas_request_encoders_gen.go": {
+			"\tencoded, err := xmlMarshal(req, xml.Name{Space: \"urn:shop\", Local: \"order\"})\n\tif err != nil {\n\t\treturn errors.Wrap(err, \"encode xml\")\n\t}\n\tht.SetBody(",
+			"\"github.com/go-faster/errors\"",
+			// JSON bodies keep their codec.
+			"\tconst contentType = \"application/json\"\n\te := new(jx.Encoder)",
+		},
+		"oas_response_decoders_gen.go": {
+			"\t\t\tvar response GetOrderApplicationXMLOK\n\t\t\tif err := xml.Unmarshal(buf, &response); err != nil {\n\t\t\t\treturn res, errors.Wrap(err, \"decode body\")",
+		},
+		"oas_response_encoders_gen.go": {
+			// The body is marshaled before the status is written.
+			"\t\tencoded, err := xmlMarshal(response.Response, xml.Name{Local: \"fault\"})\n\t\tif err != nil {\n\t\t\treturn errors.Wrap(err, \"encode xml\")\n\t\t}\n\t\tw.Header().Set(\"Content-Type\", \"text/xml\")",
+			"\t\tif _, err := w.Write(encoded); err != nil {",
+		},
+		outputFile: {
+			"// Code generated by ogen-fixxml, DO NOT EDIT.",
+			"func (o OptNilString) MarshalXML(e *xml.Encoder, start xml.StartElement) error {\n\tif !o.Set {\n\t\treturn nil\n\t}\n\tif o.Null {\n\t\treturn xmlEncodeNil(e, start)\n\t}",
+			"func (o OptInt) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {",
+			"\tv, err := strconv.ParseInt(attr.Value, 10, 0)",
+		},
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(string(got), want) {
+				t.Errorf("%s missing %q:\n%s", file, want, got)
+			}
+		}
+	}
+
+	// OptInt is only used as an attribute.
+	if got, _ := os.ReadFile(filepath.Join(dir, outputFile)); strings.Contains(string(got), "func (o OptInt) MarshalXML(") {
+		t.Error("generated element methods for an attribute-only wrapper")
+	}
+	// The decoders no longer use jx.
+	if got, _ := os.ReadFile(filepath.Join(dir, "oas_response_decoders_gen.go")); strings.Contains(string(got), "go-faster/jx") {
+		t.Error("unused jx import left in decoders")
+	}
+
+	// The fixed package must compile.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Errorf("fixed code does not type-check: %v", err)
+	}
+
+	// A second run only finds the array body, which is left as JSON.
+	if err := run([]string{"-spec", specFile, dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+}
+
+func TestRun_NoSpec(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource)
+	writeFile(t, filepath.Join(dir, "oas_request_encoders_gen.go"), encodersSource)
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "oas_request_encoders_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `xmlMarshal(req, xml.Name{Local: "Order"})`; !strings.Contains(string(got), want) {
+		t.Errorf("output missing %q:\n%s", want, got)
+	}
+	schemas, err := os.ReadFile(filepath.Join(dir, "oas_schemas_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "`json:\"note\" xml:\"note\"`"; !strings.Contains(string(schemas), want) {
+		t.Errorf("output missing %q:\n%s", want, schemas)
+	}
+}
+
+func TestFindXMLBodies(t *testing.T) {
+	// Bodies without an Encode method, like strings, are left alone.
+	source := `func encodePingResponse(response string, w http.ResponseWriter, span trace.Span) error {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(200)
+
+	e := new(jx.Encoder)
+	e.Str(response)
+	if _, err := e.WriteTo(w); err != nil {
+		return errors.Wrap(err, "write")
+	}
+
+	return nil
+}
+
+func encodeGetOrderResponse(response *Order, w http.ResponseWriter, span trace.Span) error {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(200)
+
+	e := new(jx.Encoder)
+	response.Encode(e)
+	if _, err := e.WriteTo(w); err != nil {
+		return errors.Wrap(err, "write")
+	}
+
+	return nil
+}
+`
+	bodies := FindXMLBodies("oas_response_encoders_gen.go", []byte(source))
+	if len(bodies) != 1 {
+		t.Fatalf("FindXMLBodies() = %+v, want 1 body", bodies)
+	}
+	if b := bodies[0]; b.Func != "encodeGetOrderResponse" || b.Type != "*Order" || b.Value != "response" {
+		t.Errorf("FindXMLBodies() = %+v", b)
+	}
+
+	// A fixed XML case doesn't claim the JSON codec of the next case.
+	source = `		switch {
+		case ct == "application/xml":
+			var response Order
+			if err := xml.Unmarshal(buf, &response); err != nil {
+				return res, err
+			}
+			return &response, nil
+		case ct == "application/json":
+			d := jx.DecodeBytes(buf)
+
+			var response Order
+			if err := func() error {
+				if err := response.Decode(d); err != nil {
+					return err
+				}
+				if err := d.Skip(); err != io.EOF {
+					return errors.New("unexpected trailing data")
+				}
+				return nil
+			}(); err != nil {
+				return res, err
+			}
+			return &response, nil
+		}
+`
+	if bodies := FindXMLBodies("oas_response_decoders_gen.go", []byte(source)); len(bodies) != 0 {
+		t.Errorf("FindXMLBodies() = %+v, want none", bodies)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,229 @@
+// Command ogen-genbuilders generates fluent builders for ogen request types.
+//
+// Constructing request bodies and parameter structs by hand means wrapping
+// every optional field in Opt*/OptNil* values and nesting struct literals.
+// This tool generates a builder per request type, and per struct type nested
+// inside one, that handles the wrapping:
+//
+//	req := api.NewUserBuilder().
+//		Name("Ada").
+//		Email("ada@example.com"). // OptString handled internally
+//		ManagerNull().            // OptNilManager set to null
+//		Build()
+//
+// Usage:
+//
+//	ogen-genbuilders <generated-dir>
+//
+// The builders are written to oas_builders_gen.go in the generated directory,
+// so ogen --clean removes them along with the rest of the generated code.
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the builders are written to.
+const outputFile = "oas_builders_gen.go"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-genbuilders: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-genbuilders <generated-dir>")
+	}
+
+	dir := args[0]
+	pkg, err := gopkg.Load(dir, outputFile)
+	if err != nil {
+		return err
+	}
+
+	gen, count, err := GenerateBuilders(pkg)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		fmt.Printf("No request types found in %s\n", dir)
+		return nil
+	}
+
+	path := filepath.Join(pkg.Dir, outputFile)
+	if err := gen.WriteFile(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated %d builders in %s\n", count, path)
+	return nil
+}
+
+// target is a struct type that gets a builder.
+type target struct {
+	named *types.Named
+	ptr   bool // Build returns *T because an operation takes *T
+}
+
+// GenerateBuilders generates builders for every request body and parameter
+// struct of the package's client operations, and for struct types nested in
+// them.
+func GenerateBuilders(pkg *gopkg.Package) (*gopkg.Generated, int, error) {
+	targets := make(map[*types.Named]*target)
+	var queue []*types.Named
+
+	add := func(t types.Type, root bool) {
+		ptr := false
+		if p, ok := t.(*types.Pointer); ok {
+			t, ptr = p.Elem(), true
+		}
+		if _, value := gopkg.Unwrap(t); value != t {
+			t = value
+		}
+		named, ok := t.(*types.Named)
+		if !ok || named.Obj().Pkg() != pkg.Types || !isBuildable(named) {
+			return
+		}
+		if tg, ok := targets[named]; ok {
+			tg.ptr = tg.ptr || (root && ptr)
+			return
+		}
+		targets[named] = &target{named: named, ptr: root && ptr}
+		queue = append(queue, named)
+	}
+
+	for _, op := range pkg.Operations() {
+		params := op.Sig.Params()
+		for i := range params.Len() {
+			add(params.At(i).Type(), true)
+		}
+	}
+	for len(queue) > 0 {
+		named := queue[0]
+		queue = queue[1:]
+		st := named.Underlying().(*types.Struct)
+		for i := range st.NumFields() {
+			field := st.Field(i)
+			t := field.Type()
+			if s, ok := t.(*types.Slice); ok {
+				t = s.Elem()
+			}
+			add(t, false)
+		}
+	}
+
+	ordered := make([]*target, 0, len(targets))
+	for _, tg := range targets {
+		ordered = append(ordered, tg)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].named.Obj().Name() < ordered[j].named.Obj().Name()
+	})
+
+	gen := gopkg.NewGenerated("ogen-genbuilders", pkg.Types)
+	for _, tg := range ordered {
+		for _, name := range []string{tg.named.Obj().Name() + "Builder", "New" + tg.named.Obj().Name() + "Builder"} {
+			if pkg.Types.Scope().Lookup(name) != nil {
+				return nil, 0, fmt.Errorf("%s is already declared", name)
+			}
+		}
+		if err := writeBuilder(gen, tg); err != nil {
+			return nil, 0, err
+		}
+	}
+	return gen, len(ordered), nil
+}
+
+// isBuildable reports whether named is a plain struct worth a builder: not an
+// ogen wrapper, not a sum type, and with at least one exported field.
+func isBuildable(named *types.Named) bool {
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
+	if kind, _ := gopkg.Unwrap(named); kind != gopkg.NotWrapped {
+		return false
+	}
+	if st.NumFields() > 0 && st.Field(0).Name() == "Type" {
+		if d, ok := st.Field(0).Type().(*types.Named); ok && d.Obj().Name() == named.Obj().Name()+"Type" {
+			return false
+		}
+	}
+	for i := range st.NumFields() {
+		if st.Field(i).Exported() {
+			return true
+		}
+	}
+	return false
+}
+
+func writeBuilder(gen *gopkg.Generated, tg *target) error {
+	name := tg.named.Obj().Name()
+	builder := name + "Builder"
+	st := tg.named.Underlying().(*types.Struct)
+
+	gen.Printf("// %s builds %s values.\n", builder, name)
+	gen.Printf("type %s struct {\n\tv %s\n}\n\n", builder, name)
+	gen.Printf("// New%s returns a builder for %s.\n", builder, name)
+	gen.Printf("func New%s() *%s {\n\treturn &%s{}\n}\n\n", builder, builder, builder)
+
+	used := map[string]string{"Build": "Build"}
+	method := func(methodName, field string) error {
+		if prev, ok := used[methodName]; ok {
+			return fmt.Errorf("%s: method %s for field %s collides with %s", builder, methodName, field, prev)
+		}
+		used[methodName] = field
+		return nil
+	}
+
+	for i := range st.NumFields() {
+		field := st.Field(i)
+		if !field.Exported() {
+			continue
+		}
+		fieldName := field.Name()
+		kind, value := gopkg.Unwrap(field.Type())
+
+		if err := method(fieldName, fieldName); err != nil {
+			return err
+		}
+		gen.Printf("// %s sets %s.\n", fieldName, fieldName)
+		switch kind {
+		case gopkg.NotWrapped:
+			gen.Printf("func (b *%s) %s(v %s) *%s {\n\tb.v.%s = v\n\treturn b\n}\n\n",
+				builder, fieldName, gen.TypeString(field.Type()), builder, fieldName)
+		default:
+			gen.Printf("func (b *%s) %s(v %s) *%s {\n\tb.v.%s.SetTo(v)\n\treturn b\n}\n\n",
+				builder, fieldName, gen.TypeString(value), builder, fieldName)
+		}
+
+		if kind == gopkg.OptNil || kind == gopkg.Nil {
+			nullName := fieldName + "Null"
+			if err := method(nullName, fieldName); err != nil {
+				return err
+			}
+			gen.Printf("// %s sets %s to null.\n", nullName, fieldName)
+			gen.Printf("func (b *%s) %s() *%s {\n\tb.v.%s.SetToNull()\n\treturn b\n}\n\n",
+				builder, nullName, builder, fieldName)
+		}
+	}
+
+	if tg.ptr {
+		gen.Printf("// Build returns the built *%s.\n", name)
+		gen.Printf("func (b *%s) Build() *%s {\n\tv := b.v\n\treturn &v\n}\n\n", builder, name)
+	} else {
+		gen.Printf("// Build returns the built %s.\n", name)
+		gen.Printf("func (b *%s) Build() %s {\n\treturn b.v\n}\n\n", builder, name)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const clientSource = `package api
+
+import (
+	"context"
+	"time"
+)
+
+type Invoker interface {
+	CreateUser(ctx context.Context, request *User) (*User, error)
+	ListUsers(ctx context.Context, params ListUsersParams) ([]User, error)
+}
+
+type User struct {
+	Name    string
+	Email   OptString
+	Manager OptNilManager
+	Tags    []string
+	Address Address
+}
+
+type Address struct {
+	City OptString
+}
+
+type Manager struct {
+	Name string
+}
+
+type ListUsersParams struct {
+	Since OptDateTime
+	Limit OptInt
+}
+
+type OptString struct {
+	Value string
+	Set   bool
+}
+
+func (o *OptString) SetTo(v string) { o.Set = true; o.Value = v }
+
+type OptInt struct {
+	Value int
+	Set   bool
+}
+
+func (o *OptInt) SetTo(v int) { o.Set = true; o.Value = v }
+
+type OptDateTime struct {
+	Value time.Time
+	Set   bool
+}
+
+func (o *OptDateTime) SetTo(v time.Time) { o.Set = true; o.Value = v }
+
+type OptNilManager struct {
+	Value Manager
+	Set   bool
+	Null  bool
+}
+
+func (o *OptNilManager) SetTo(v Manager) { o.Set = true; o.Null = false; o.Value = v }
+func (o *OptNilManager) SetToNull()      { o.Set = true; o.Null = true }
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), clientSource)
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, outputFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+
+	wants := []string{
+		"// Code generated by ogen-genbuilders, DO NOT EDIT.",
+		"func NewUserBuilder() *UserBuilder {",
+		"func (b *UserBuilder) Name(v string) *UserBuilder {\n\tb.v.Name = v",
+		"func (b *UserBuilder) Email(v string) *UserBuilder {\n\tb.v.Email.SetTo(v)",
+		"func (b *UserBuilder) Manager(v Manager) *UserBuilder {\n\tb.v.Manager.SetTo(v)",
+		"func (b *UserBuilder) ManagerNull() *UserBuilder {\n\tb.v.Manager.SetToNull()",
+		"func (b *UserBuilder) Tags(v []string) *UserBuilder {",
+		// CreateUser takes *User, so Build returns a pointer.
+		"func (b *UserBuilder) Build() *User {",
+		// Nested and parameter types get value builders.
+		"func (b *AddressBuilder) Build() Address {",
+		"func (b *ManagerBuilder) Build() Manager {",
+		"func (b *ListUsersParamsBuilder) Since(v time.Time) *ListUsersParamsBuilder {",
+		"func (b *ListUsersParamsBuilder) Build() ListUsersParams {",
+	}
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Wrappers never get builders of their own.
+	if strings.Contains(out, "OptStringBuilder") {
+		t.Error("generated a builder for an Opt wrapper")
+	}
+
+	// The generated file must compile against the package.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Errorf("generated code does not type-check: %v", err)
+	}
+
+	// Regenerating replaces the previous output.
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+}
+
+func TestRun_NoClient(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), "package api\n\ntype User struct{ Name string }\n")
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, outputFile)); !os.IsNotExist(err) {
+		t.Errorf("builders file written without a client: %v", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,290 @@
+// Command ogen-genconsts generates typed constants for the operation IDs,
+// path templates and header names of an ogen-generated package.
+//
+// Middleware, metrics and tests often refer to operations and headers by
+// name, and string literals drift from the spec unnoticed. ogen only exports
+// the Go names of operations (GetUserOperation). This tool generates the
+// rest from the generated code, so a renamed operation or header breaks the
+// build instead:
+//
+//	if route.OperationID() == string(api.GetUserOperationID) { ... }
+//	requestID := r.Header.Get(string(api.XRequestIDHeader))
+//	metrics.WithLabelValues(string(api.GetUserPath))
+//
+// Usage:
+//
+//	ogen-genconsts <generated-dir>
+//
+// The constants are written to oas_consts_gen.go in the generated directory,
+// so ogen --clean removes them along with the rest of the generated code.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the constants are written to.
+const outputFile = "oas_consts_gen.go"
+
+// sourceFiles hold the operations and headers: the client, the server
+// handlers and the parameter and response codecs.
+var sourceFiles = []string{
+	"oas_client_gen.go",
+	"oas_handlers_gen.go",
+	"oas_parameters_gen.go",
+	"oas_response_encoders_gen.go",
+	"oas_response_decoders_gen.go",
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-genconsts: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-genconsts <generated-dir>")
+	}
+
+	dir := args[0]
+	var ops []Operation
+	var headers []string
+	for _, name := range sourceFiles {
+		content, err := os.ReadFile(filepath.Join(dir, name)) // #nosec G703 -- CLI tool, filename from trusted args
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		ops = append(ops, FindOperations(content)...)
+		headers = append(headers, FindHeaders(content)...)
+	}
+
+	if len(ops) == 0 && len(headers) == 0 {
+		fmt.Printf("No operations found in %s\n", dir)
+		return nil
+	}
+
+	pkg, err := gopkg.Load(dir, outputFile)
+	if err != nil {
+		return err
+	}
+
+	gen, ops, headers, err := GenerateConsts(pkg, ops, headers)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(pkg.Dir, outputFile)
+	if err := gen.WriteFile(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated constants for %d operations and %d headers in %s\n", len(ops), len(headers), path)
+	return nil
+}
+
+// Operation is an operation of the spec.
+type Operation struct {
+	// Name is the Go name, e.g. GetUser.
+	Name string
+	// ID is the operationId, or empty if the spec has none.
+	ID     string
+	Method string
+	// Path is the path template, e.g. /users/{id}.
+	Path string
+}
+
+var (
+	// operationFuncPattern matches the client send methods and the server
+	// handlers, which describe their operation in otel attributes.
+	operationFuncPattern = regexp.MustCompile(
+		`(?m)^func \(c \*Client\) send(\w+)\(|^func \(s \*Server\) handle(\w+)Request\(`)
+	// Operations without an operationId have no OperationID attribute.
+	operationAttrsPattern = regexp.MustCompile(
+		`(?:\n\t\totelogen\.OperationID\(("(?:[^"\\]|\\.)*")\),)?` +
+			`\n\t\tsemconv\.HTTPRequestMethodKey\.String\("(\w+)"\),` +
+			`\n\t\tsemconv\.(?:URLTemplateKey|HTTPRouteKey)\.String\(("(?:[^"\\]|\\.)*")\),\n`)
+
+	// headerPattern matches the name of a header parameter or response
+	// header in its uri encoding or decoding config.
+	headerPattern = regexp.MustCompile(
+		`uri\.HeaderParameter(?:Encoding|Decoding)Config\{\n\t+Name:\s+("(?:[^"\\]|\\.)*"),\n`)
+)
+
+// FindOperations returns the operations described in a client or handlers
+// file, in declaration order.
+func FindOperations(content []byte) []Operation {
+	funcs := operationFuncPattern.FindAllSubmatchIndex(content, -1)
+	var ops []Operation
+	for i, f := range funcs {
+		end := len(content)
+		if i+1 < len(funcs) {
+			end = funcs[i+1][0]
+		}
+		if next := strings.Index(string(content[f[1]:end]), "\nfunc "); next >= 0 {
+			end = f[1] + next
+		}
+		m := operationAttrsPattern.FindSubmatch(content[f[1]:end])
+		if m == nil {
+			continue
+		}
+		var name string
+		if f[2] >= 0 {
+			name = string(content[f[2]:f[3]])
+		} else {
+			name = string(content[f[4]:f[5]])
+		}
+		ops = append(ops, Operation{
+			Name:   name,
+			ID:     unquote(m[1]),
+			Method: string(m[2]),
+			Path:   unquote(m[3]),
+		})
+	}
+	return ops
+}
+
+// FindHeaders returns the header names in a generated file.
+func FindHeaders(content []byte) []string {
+	var names []string
+	for _, m := range headerPattern.FindAllSubmatch(content, -1) {
+		names = append(names, unquote(m[1]))
+	}
+	return names
+}
+
+func unquote(b []byte) string {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return string(b)
+	}
+	return s
+}
+
+// headerConstName returns the Go constant for a header name: "X-Request-ID"
+// becomes XRequestIDHeader.
+func headerConstName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 || !unicode.IsLetter([]rune(b.String())[0]) {
+		return "Header" + b.String()
+	}
+	return b.String() + "Header"
+}
+
+// GenerateConsts generates the OperationID, PathTemplate and HeaderName
+// types and their constants. Operations found in both the client and the
+// server, and headers declared more than once, get one constant; it returns
+// the deduplicated operations and headers.
+func GenerateConsts(pkg *gopkg.Package, found []Operation, foundHeaders []string) (*gopkg.Generated, []Operation, []string, error) {
+	var ops []Operation
+	seen := make(map[string]Operation)
+	for _, op := range found {
+		if prev, ok := seen[op.Name]; ok {
+			if prev != op {
+				return nil, nil, nil, fmt.Errorf("%s: client and server disagree: %+v, %+v", op.Name, prev, op)
+			}
+			continue
+		}
+		seen[op.Name] = op
+		ops = append(ops, op)
+	}
+
+	// Header names are case-insensitive; the first spelling wins.
+	seenHeaders := make(map[string]bool) // lower-case names
+	consts := make(map[string]string)    // constant -> spelling
+	for _, name := range foundHeaders {
+		if seenHeaders[strings.ToLower(name)] {
+			continue
+		}
+		c := headerConstName(name)
+		if prev, ok := consts[c]; ok {
+			return nil, nil, nil, fmt.Errorf("headers %q and %q both map to %s", prev, name, c)
+		}
+		seenHeaders[strings.ToLower(name)] = true
+		consts[c] = name
+	}
+	headers := make([]string, 0, len(consts))
+	for c := range consts {
+		headers = append(headers, c)
+	}
+	sort.Strings(headers)
+
+	decls := []string{"OperationID", "PathTemplate", "HeaderName"}
+	for _, op := range ops {
+		decls = append(decls, op.Name+"Path")
+		if op.ID != "" {
+			decls = append(decls, op.Name+"OperationID")
+		}
+	}
+	decls = append(decls, headers...)
+	scope := pkg.Types.Scope()
+	for _, decl := range decls {
+		if scope.Lookup(decl) != nil {
+			return nil, nil, nil, fmt.Errorf("%s is already declared", decl)
+		}
+	}
+
+	var ids []Operation
+	for _, op := range ops {
+		if op.ID != "" {
+			ids = append(ids, op)
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-genconsts", pkg.Types)
+	if len(ids) > 0 {
+		gen.Printf("// OperationID is the operationId of an operation in the spec.\ntype OperationID string\n\n")
+		gen.Printf("// Operation IDs.\nconst (\n")
+		for _, op := range ids {
+			gen.Printf("\t%sOperationID OperationID = %q\n", op.Name, op.ID)
+		}
+		gen.Printf(")\n\n")
+	}
+	if len(ops) > 0 {
+		gen.Printf("// PathTemplate is the path of an operation in the spec, with its parameters\n// in braces, as in /users/{id}.\ntype PathTemplate string\n\n")
+		gen.Printf("// Operation paths.\nconst (\n")
+		for _, op := range ops {
+			gen.Printf("\t%sPath PathTemplate = %q // %s\n", op.Name, op.Path, op.Method)
+		}
+		gen.Printf(")\n\n")
+	}
+	if len(headers) > 0 {
+		gen.Printf("// HeaderName is the name of a header parameter or response header, as\n// spelled in the spec.\ntype HeaderName string\n\n")
+		gen.Printf("// Header names.\nconst (\n")
+		for _, c := range headers {
+			gen.Printf("\t%s HeaderName = %q\n", c, consts[c])
+		}
+		gen.Printf(")\n")
+	}
+
+	names := make([]string, len(headers))
+	for i, c := range headers {
+		names[i] = consts[c]
+	}
+	return gen, ops, names, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const clientSource = `package api
+
+func (c *Client) sendGetUser(ctx context.Context, params GetUserParams) (res *User, err error) {
+	otelAttrs := []attribute.KeyValue{
+		otelogen.OperationID("getUser"),
+		semconv.HTTPRequestMethodKey.String("GET"),
+		semconv.URLTemplateKey.String("/users/{id}"),
+	}
+
+	stage = "EncodeHeaderParams"
+	h := uri.NewHeaderEncoder(r.Header)
+	{
+		cfg := uri.HeaderParameterEncodingConfig{
+			Name:    "X-Request-ID",
+			Explode: false,
+		}
+		if err := h.EncodeParam(cfg, func(e uri.Encoder) error {
+			return nil
+		}); err != nil {
+			return res, errors.Wrap(err, "encode header")
+		}
+	}
+	return res, nil
+}
+
+func (c *Client) sendThingsGet(ctx context.Context) (res *ThingsGetOK, err error) {
+	otelAttrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String("GET"),
+		semconv.URLTemplateKey.String("/things"),
+	}
+	return res, nil
+}
+`
+
+const handlersSource = `package api
+
+func (s *Server) handleGetUserRequest(args [1]string, argsEscaped bool, w http.ResponseWriter, r *http.Request) {
+	otelAttrs := []attribute.KeyValue{
+		otelogen.OperationID("getUser"),
+		semconv.HTTPRequestMethodKey.String("GET"),
+		semconv.HTTPRouteKey.String("/users/{id}"),
+	}
+}
+
+func (s *Server) handleOrderCreatedWebhookRequest(args [0]string, argsEscaped bool, w http.ResponseWriter, r *http.Request) {
+	otelAttrs := []attribute.KeyValue{
+		otelogen.WebhookName("orderCreated"),
+	}
+}
+`
+
+func TestFindOperations(t *testing.T) {
+	got := FindOperations([]byte(clientSource))
+	want := []Operation{
+		{Name: "GetUser", ID: "getUser", Method: "GET", Path: "/users/{id}"},
+		// Operations without an operationId still have a path.
+		{Name: "ThingsGet", Method: "GET", Path: "/things"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindOperations(client) = %+v, want %+v", got, want)
+	}
+
+	// Webhooks have no route.
+	got = FindOperations([]byte(handlersSource))
+	want = want[:1]
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindOperations(handlers) = %+v, want %+v", got, want)
+	}
+}
+
+func TestFindHeaders(t *testing.T) {
+	if got := FindHeaders([]byte(clientSource)); !reflect.DeepEqual(got, []string{"X-Request-ID"}) {
+		t.Errorf("FindHeaders() = %q, want [X-Request-ID]", got)
+	}
+}
+
+func TestHeaderConstName(t *testing.T) {
+	for name, want := range map[string]string{
+		"X-Request-ID": "XRequestIDHeader",
+		"set-cookie":   "SetCookieHeader",
+		"ETag":         "ETagHeader",
+		"1-Header":     "Header1Header",
+	} {
+		if got := headerConstName(name); got != want {
+			t.Errorf("headerConstName(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestGenerateConsts(t *testing.T) {
+	pkg := loadPackage(t, "package api\n\ntype OperationName = string\n")
+	ops := append(FindOperations([]byte(clientSource)), FindOperations([]byte(handlersSource))...)
+	headers := []string{"X-Request-ID", "x-request-id", "Set-Cookie"}
+
+	gen, ops, headers, err := GenerateConsts(pkg, ops, headers)
+	if err != nil {
+		t.Fatalf("GenerateConsts: %v", err)
+	}
+	if len(ops) != 2 {
+		t.Errorf("GenerateConsts() = %d operations, want 2", len(ops))
+	}
+	if !reflect.DeepEqual(headers, []string{"Set-Cookie", "X-Request-ID"}) {
+		t.Errorf("GenerateConsts() headers = %q", headers)
+	}
+
+	out, err := gen.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"type OperationID string",
+		"\tGetUserOperationID OperationID = \"getUser\"\n)",
+		"\tGetUserPath   PathTemplate = \"/users/{id}\" // GET\n\tThingsGetPath PathTemplate = \"/things\"     // GET\n",
+		"\tSetCookieHeader  HeaderName = \"Set-Cookie\"\n\tXRequestIDHeader HeaderName = \"X-Request-ID\"\n",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestGenerateConsts_AlreadyDeclared(t *testing.T) {
+	pkg := loadPackage(t, "package api\n\nconst GetUserPath = \"/users/{id}\"\n")
+	_, _, _, err := GenerateConsts(pkg, FindOperations([]byte(clientSource)), nil)
+	if err == nil || err.Error() != "GetUserPath is already declared" {
+		t.Errorf("GenerateConsts() error = %v, want GetUserPath is already declared", err)
+	}
+}
+
+func TestGenerateConsts_Disagree(t *testing.T) {
+	pkg := loadPackage(t, "package api\n")
+	ops := []Operation{
+		{Name: "GetUser", ID: "getUser", Method: "GET", Path: "/users/{id}"},
+		{Name: "GetUser", ID: "getUser", Method: "GET", Path: "/users/{userId}"},
+	}
+	if _, _, _, err := GenerateConsts(pkg, ops, nil); err == nil {
+		t.Error("GenerateConsts() succeeded for a client and server that disagree")
+	}
+}
+
+func loadPackage(t *testing.T, source string) *gopkg.Package {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "oas_schemas_gen.go"), []byte(source), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
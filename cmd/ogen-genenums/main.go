@@ -0,0 +1,171 @@
+// Command ogen-genenums generates helpers for the enum types of an
+// ogen-generated package.
+//
+// ogen lists the values of an enum with a method on a zero value,
+// Status("").AllValues(), and only string enums can be parsed, through
+// UnmarshalText. Code validating user input or filling a dropdown ends up
+// keeping its own list of values, which rots when the spec changes. This
+// tool generates, for every enum type:
+//
+//	for _, s := range api.AllStatusValues() { ... }
+//
+//	status, err := api.ParseStatus(r.FormValue("status"))
+//
+//	if !status.IsValid() { ... }
+//
+// Usage:
+//
+//	ogen-genenums <generated-dir>
+//
+// The helpers are written to oas_enums_gen.go in the generated directory, so
+// ogen --clean removes them along with the rest of the generated code.
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the helpers are written to.
+const outputFile = "oas_enums_gen.go"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-genenums: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-genenums <generated-dir>")
+	}
+
+	pkg, err := gopkg.Load(args[0], outputFile)
+	if err != nil {
+		return err
+	}
+
+	enums := FindEnums(pkg)
+	if len(enums) == 0 {
+		fmt.Printf("No enum types found in %s\n", args[0])
+		return nil
+	}
+
+	gen, err := GenerateHelpers(pkg, enums)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(pkg.Dir, outputFile)
+	if err := gen.WriteFile(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated helpers for %d enum types in %s\n", len(enums), path)
+	return nil
+}
+
+// FindEnums returns the enum types of pkg, sorted by name: the named basic
+// types with an AllValues method returning their values.
+func FindEnums(pkg *gopkg.Package) []*types.Named {
+	var enums []*types.Named
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || obj.IsAlias() {
+			continue
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		if _, ok := named.Underlying().(*types.Basic); !ok {
+			continue
+		}
+		m, _, _ := types.LookupFieldOrMethod(named, false, pkg.Types, "AllValues")
+		fn, ok := m.(*types.Func)
+		if !ok {
+			continue
+		}
+		sig := fn.Signature()
+		if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+			continue
+		}
+		if s, ok := sig.Results().At(0).Type().(*types.Slice); ok && types.Identical(s.Elem(), named) {
+			enums = append(enums, named)
+		}
+	}
+	return enums
+}
+
+// GenerateHelpers generates AllXValues, ParseX and IsValid for each enum.
+func GenerateHelpers(pkg *gopkg.Package, enums []*types.Named) (*gopkg.Generated, error) {
+	scope := pkg.Types.Scope()
+	for _, named := range enums {
+		name := named.Obj().Name()
+		for _, decl := range []string{"All" + name + "Values", "Parse" + name} {
+			if scope.Lookup(decl) != nil {
+				return nil, fmt.Errorf("%s is already declared", decl)
+			}
+		}
+		if m, _, _ := types.LookupFieldOrMethod(named, true, pkg.Types, "IsValid"); m != nil {
+			return nil, fmt.Errorf("%s.IsValid is already declared", name)
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-genenums", pkg.Types)
+	gen.Import("github.com/go-faster/errors", "errors")
+	sorted := append([]*types.Named(nil), enums...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Obj().Name() < sorted[j].Obj().Name() })
+	for i, named := range sorted {
+		if i > 0 {
+			gen.Printf("\n")
+		}
+		writeHelpers(gen, named)
+	}
+	return gen, nil
+}
+
+func writeHelpers(gen *gopkg.Generated, named *types.Named) {
+	name := named.Obj().Name()
+	gen.Printf("// All%sValues returns all %s values, in the order of the spec.\n", name, name)
+	gen.Printf("func All%sValues() []%s {\n\tvar zero %s\n\treturn zero.AllValues()\n}\n\n", name, name, name)
+
+	gen.Printf("// IsValid reports whether s is one of the %s values.\n", name)
+	gen.Printf("func (s %s) IsValid() bool {\n\tfor _, v := range s.AllValues() {\n", name)
+	gen.Printf("\t\tif s == v {\n\t\t\treturn true\n\t\t}\n\t}\n\treturn false\n}\n\n")
+
+	gen.Printf("// Parse%s parses s as one of the %s values.\n", name, name)
+	gen.Printf("func Parse%s(s string) (%s, error) {\n", name, name)
+	basic := named.Underlying().(*types.Basic)
+	info := basic.Info()
+	parse, zero := "", "0"
+	switch {
+	case info&types.IsString != 0:
+		gen.Printf("\tv := %s(s)\n", name)
+		zero = `""`
+	case info&types.IsBoolean != 0:
+		parse, zero = "strconv.ParseBool(s)", "false"
+	case info&types.IsUnsigned != 0:
+		parse = "strconv.ParseUint(s, 10, 64)"
+	case info&types.IsInteger != 0:
+		parse = "strconv.ParseInt(s, 10, 64)"
+	case info&types.IsFloat != 0:
+		parse = "strconv.ParseFloat(s, 64)"
+	}
+	if parse != "" {
+		gen.Import("strconv", "strconv")
+		gen.Printf("\tparsed, err := %s\n\tv := %s(parsed)\n", parse, name)
+		gen.Printf("\tif err != nil || !v.IsValid() {\n")
+	} else {
+		gen.Printf("\tif !v.IsValid() {\n")
+	}
+	gen.Printf("\t\treturn %s, errors.Errorf(\"invalid %s %%q: want one of %%v\", s, v.AllValues())\n", zero, name)
+	gen.Printf("\t}\n\treturn v, nil\n}\n")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const schemasSource = `package api
+
+type Status string
+
+const (
+	StatusActive   Status = "active"
+	StatusInactive Status = "inactive"
+)
+
+func (Status) AllValues() []Status {
+	return []Status{StatusActive, StatusInactive}
+}
+
+type Priority int
+
+const (
+	Priority1 Priority = 1
+	Priority2 Priority = 2
+)
+
+func (Priority) AllValues() []Priority {
+	return []Priority{Priority1, Priority2}
+}
+
+// Not an enum: AllValues returns another type.
+type Name string
+
+func (Name) AllValues() []string { return nil }
+`
+
+func TestRun(t *testing.T) {
+	dir := writePackage(t, schemasSource)
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	// The generated file must type-check with the package.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Fatalf("load after run: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, outputFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(content)
+	for _, want := range []string{
+		"func AllStatusValues() []Status {\n\tvar zero Status\n\treturn zero.AllValues()\n}",
+		"func (s Status) IsValid() bool {",
+		"func ParseStatus(s string) (Status, error) {\n\tv := Status(s)\n\tif !v.IsValid() {\n\t\treturn \"\", errors.Errorf(",
+		"func ParsePriority(s string) (Priority, error) {\n\tparsed, err := strconv.ParseInt(s, 10, 64)\n\tv := Priority(parsed)\n\tif err != nil || !v.IsValid() {\n\t\treturn 0, errors.Errorf(",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Name") {
+		t.Errorf("output has helpers for Name:\n%s", out)
+	}
+
+	// Running again replaces the file instead of colliding with it.
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+}
+
+func TestGenerateHelpers_AlreadyDeclared(t *testing.T) {
+	dir := writePackage(t, schemasSource+"\nfunc (s Status) IsValid() bool { return true }\n")
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = GenerateHelpers(pkg, FindEnums(pkg))
+	if err == nil || err.Error() != "Status.IsValid is already declared" {
+		t.Errorf("GenerateHelpers() error = %v, want Status.IsValid is already declared", err)
+	}
+}
+
+func writePackage(t *testing.T, source string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "oas_schemas_gen.go"), []byte(source), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,741 @@
+// Command ogen-geniterators generates pagination iterators for ogen client
+// operations.
+//
+// Every consumer of a paginated API writes the same loop around the generated
+// client: request a page, hand out its items, work out the next page from a
+// page number, a cursor or a Link header, and stop at the right time. This
+// tool generates that loop once per paginated operation:
+//
+//	it := api.NewListUsersIter(client, api.ListUsersParams{})
+//	for it.Next(ctx) {
+//		user := it.Item()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// Usage:
+//
+//	ogen-geniterators [-config pagination.json] <generated-dir>
+//
+// Operations are detected from common parameter and field names:
+//
+//   - link: the response has a Link header; the page or cursor parameter is
+//     taken from its rel="next" URL.
+//   - cursor: a cursor parameter (cursor, after, page_token) and a next cursor
+//     field in the response (next_cursor, next_page_token, next).
+//   - page: a page parameter, optionally with a page size parameter
+//     (per_page, page_size, limit).
+//
+// The config file maps operationIds to explicit settings, for APIs whose names
+// don't follow these conventions:
+//
+//	{
+//	  "listUsers": {"style": "page", "param": "p", "perPage": "size", "items": "results"},
+//	  "listEvents": {"style": "cursor", "param": "from", "next": "continuation"},
+//	  "getStats": {"style": "none"}
+//	}
+//
+// The iterators are written to oas_iterators_gen.go in the generated
+// directory, so ogen --clean removes them along with the rest of the generated
+// code.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the iterators are written to.
+const outputFile = "oas_iterators_gen.go"
+
+// Pagination styles.
+const (
+	StylePage   = "page"
+	StyleCursor = "cursor"
+	StyleLink   = "link"
+	StyleNone   = "none"
+)
+
+// Settings configures the pagination of one operation. Empty fields are
+// detected.
+type Settings struct {
+	// Style is "page", "cursor", "link" or "none".
+	Style string `json:"style"`
+	// Param is the page or cursor parameter.
+	Param string `json:"param"`
+	// PerPage is the page size parameter of the page style.
+	PerPage string `json:"perPage"`
+	// Next is the response field holding the next cursor.
+	Next string `json:"next"`
+	// Items is the response field holding the items.
+	Items string `json:"items"`
+	// FirstPage is the page requested when the page parameter is unset.
+	// Defaults to 1.
+	FirstPage *int `json:"firstPage"`
+}
+
+// Config maps operationIds to their pagination settings.
+type Config map[string]Settings
+
+// Names tried when detecting pagination.
+var (
+	pageParams    = []string{"page"}
+	perPageParams = []string{"per_page", "perPage", "page_size", "pageSize", "limit"}
+	cursorParams  = []string{"cursor", "after", "page_token", "pageToken", "starting_after"}
+	nextFields    = []string{"next_cursor", "nextCursor", "next_page_token", "nextPageToken", "next"}
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-geniterators: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-geniterators", flag.ContinueOnError)
+	configFile := fs.String("config", "", "JSON file mapping operationIds to pagination settings")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: ogen-geniterators [-config pagination.json] <generated-dir>")
+	}
+
+	cfg := Config{}
+	if *configFile != "" {
+		var err error
+		if cfg, err = loadConfig(*configFile); err != nil {
+			return err
+		}
+	}
+
+	dir := fs.Arg(0)
+	client, err := os.ReadFile(filepath.Join(dir, "oas_client_gen.go")) // #nosec G703 -- CLI tool, filename from trusted args
+	if os.IsNotExist(err) {
+		fmt.Printf("No client found in %s\n", dir)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	pkg, err := gopkg.Load(dir, outputFile)
+	if err != nil {
+		return err
+	}
+
+	pagers, err := FindPagers(pkg, client, cfg)
+	if err != nil {
+		return err
+	}
+	if len(pagers) == 0 {
+		fmt.Printf("No paginated operations found in %s\n", dir)
+		return nil
+	}
+
+	gen, err := GenerateIterators(pkg, pagers)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(pkg.Dir, outputFile)
+	if err := gen.WriteFile(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated %d iterators in %s\n", len(pagers), path)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+var (
+	// clientOpPattern matches the doc comment of a generated client method.
+	clientOpPattern = regexp.MustCompile(`(?m)^// (\w+) invokes (\S+) operation\.$`)
+
+	// sendPattern matches a whole generated send method.
+	sendPattern = regexp.MustCompile(`(?ms)^func \(c \*Client\) send(\w+)\(.*?^\}\n`)
+
+	// paramPattern matches the encoding of one parameter in a send method and
+	// the params field it reads.
+	paramPattern = regexp.MustCompile(`(?s)// Encode "((?:[^"\\]|\\.)*)" parameter\.\n.*?\bparams\.(\w+)`)
+)
+
+// OperationIDs maps the Go names of the client operations to their
+// operationIds.
+func OperationIDs(client []byte) map[string]string {
+	ids := make(map[string]string)
+	for _, m := range clientOpPattern.FindAllSubmatch(client, -1) {
+		ids[string(m[1])] = string(m[2])
+	}
+	return ids
+}
+
+// ParamFields maps the Go names of the client operations to their parameter
+// names and the params fields holding them.
+func ParamFields(client []byte) map[string]map[string]string {
+	fields := make(map[string]map[string]string)
+	for _, send := range sendPattern.FindAllSubmatch(client, -1) {
+		m := make(map[string]string)
+		for _, p := range paramPattern.FindAllSubmatch(send[0], -1) {
+			m[string(p[1])] = string(p[2])
+		}
+		fields[string(send[1])] = m
+	}
+	return fields
+}
+
+// Pager is a paginated operation.
+type Pager struct {
+	Name  string // Go operation name, e.g. ListUsers
+	ID    string // operationId, e.g. listUsers
+	Style string
+
+	params    *types.Named // the Params struct
+	result    types.Type   // the result type in the Invoker signature
+	page      *types.Named // the page type, *page is returned on success
+	items     string       // expression for the items, relative to page
+	elem      types.Type   // item type
+	param     field        // page or cursor parameter
+	perPage   *field       // page size parameter
+	next      *field       // next cursor, relative to page
+	link      *field       // Link header, relative to page
+	firstPage int
+}
+
+// field is a params or response field of a scalar type.
+type field struct {
+	name    string // spec name
+	expr    string // Go expression
+	wrapper gopkg.Wrapper
+	basic   *types.Basic
+}
+
+// FindPagers detects the paginated operations of the client, applying cfg.
+// Operations named in cfg must be paginated; the others are skipped if their
+// pagination isn't recognized.
+func FindPagers(pkg *gopkg.Package, client []byte, cfg Config) ([]*Pager, error) {
+	ids := OperationIDs(client)
+	params := ParamFields(client)
+
+	known := make(map[string]bool)
+	var pagers []*Pager
+	var errs []string
+	for _, op := range pkg.Operations() {
+		id := ids[op.Name]
+		known[id] = true
+		settings, explicit := cfg[id]
+		if settings.Style == StyleNone {
+			continue
+		}
+		p, err := detect(pkg, op, params[op.Name], settings)
+		if err != nil {
+			if explicit {
+				errs = append(errs, fmt.Sprintf("%s: %v", id, err))
+			}
+			continue
+		}
+		p.ID = id
+		pagers = append(pagers, p)
+	}
+	for id := range cfg {
+		if !known[id] {
+			errs = append(errs, fmt.Sprintf("operation %s not found", id))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return pagers, nil
+}
+
+// detect works out the pagination of op, which must take only a context and
+// parameters.
+func detect(pkg *gopkg.Package, op gopkg.Operation, paramFields map[string]string, s Settings) (*Pager, error) {
+	sig := op.Sig
+	if sig.Params().Len() != 2 || sig.Results().Len() != 2 {
+		return nil, fmt.Errorf("operation must take only parameters")
+	}
+	params, ok := sig.Params().At(1).Type().(*types.Named)
+	if !ok || !strings.HasSuffix(params.Obj().Name(), "Params") {
+		return nil, fmt.Errorf("operation must take only parameters")
+	}
+
+	p := &Pager{Name: op.Name, params: params, result: sig.Results().At(0).Type(), firstPage: 1}
+	if s.FirstPage != nil {
+		p.firstPage = *s.FirstPage
+	}
+
+	page, err := pageType(pkg, p.result)
+	if err != nil {
+		return nil, err
+	}
+	p.page = page
+
+	// Header wrappers hold the body in Response.
+	body, bodyExpr := types.Type(page), "page"
+	if st, ok := page.Underlying().(*types.Struct); ok && strings.HasSuffix(page.Obj().Name(), "Headers") {
+		for i := range st.NumFields() {
+			if st.Field(i).Name() == "Response" {
+				body, bodyExpr = st.Field(i).Type(), "page.Response"
+			}
+		}
+		if f, ok := headerField(st, "Link"); ok {
+			p.link = &f
+		}
+	}
+
+	if err := findItems(p, body, bodyExpr, s.Items); err != nil {
+		return nil, err
+	}
+
+	param := func(names []string, explicit string) (field, bool) {
+		if explicit != "" {
+			names = []string{explicit}
+		}
+		for _, name := range names {
+			if goName, ok := paramFields[name]; ok {
+				return scalarField(name, "it.params."+goName, structField(params, goName))
+			}
+		}
+		return field{}, false
+	}
+	pageParam, hasPage := param(pageParams, s.Param)
+	cursorParam, hasCursor := param(cursorParams, s.Param)
+	next, hasNext := jsonField(body, bodyExpr, nextFields, s.Next)
+
+	style := s.Style
+	if style == "" {
+		switch {
+		case p.link != nil && (hasPage || hasCursor):
+			style = StyleLink
+		case hasCursor && hasNext:
+			style = StyleCursor
+		case hasPage:
+			style = StylePage
+		default:
+			return nil, fmt.Errorf("no page or cursor parameter")
+		}
+	}
+	p.Style = style
+
+	switch style {
+	case StylePage:
+		if !hasPage || !isInt(pageParam) {
+			return nil, fmt.Errorf("no integer page parameter")
+		}
+		p.param = pageParam
+		if f, ok := param(perPageParams, s.PerPage); ok && isInt(f) {
+			p.perPage = &f
+		} else if s.PerPage != "" {
+			return nil, fmt.Errorf("no integer page size parameter %q", s.PerPage)
+		}
+	case StyleCursor:
+		if !hasCursor || !isString(cursorParam) {
+			return nil, fmt.Errorf("no string cursor parameter")
+		}
+		if !hasNext || !isString(next) {
+			return nil, fmt.Errorf("no string next cursor field")
+		}
+		p.param, p.next = cursorParam, &next
+	case StyleLink:
+		if p.link == nil || !isString(*p.link) {
+			return nil, fmt.Errorf("response has no Link header")
+		}
+		switch {
+		case hasPage && (isInt(pageParam) || isString(pageParam)):
+			p.param = pageParam
+		case hasCursor && isString(cursorParam):
+			p.param = cursorParam
+		default:
+			return nil, fmt.Errorf("no page or cursor parameter")
+		}
+	default:
+		return nil, fmt.Errorf("unknown style %q", style)
+	}
+	return p, nil
+}
+
+// pageType returns the type an operation returns a pointer to on success. If
+// the result is a sum of responses, that is the only response with items.
+func pageType(pkg *gopkg.Package, result types.Type) (*types.Named, error) {
+	if ptr, ok := result.(*types.Pointer); ok {
+		if named, ok := ptr.Elem().(*types.Named); ok {
+			return named, nil
+		}
+	}
+	iface, ok := result.Underlying().(*types.Interface)
+	if !ok {
+		return nil, fmt.Errorf("unsupported result type %s", result)
+	}
+
+	var candidates []*types.Named
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || obj.IsAlias() {
+			continue
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok || !types.Implements(types.NewPointer(named), iface) {
+			continue
+		}
+		if _, ok := named.Underlying().(*types.Interface); ok {
+			continue
+		}
+		if hasItems(named) {
+			candidates = append(candidates, named)
+		}
+	}
+	if len(candidates) != 1 {
+		return nil, fmt.Errorf("cannot tell which response of %s is the page", result)
+	}
+	return candidates[0], nil
+}
+
+// hasItems reports whether a response type can hold a page of items: it is a
+// slice or has a slice field.
+func hasItems(named *types.Named) bool {
+	switch u := named.Underlying().(type) {
+	case *types.Slice:
+		return true
+	case *types.Struct:
+		for i := range u.NumFields() {
+			t := u.Field(i).Type()
+			if _, ok := t.Underlying().(*types.Slice); ok {
+				return true
+			}
+			if n, ok := t.(*types.Named); ok && u.Field(i).Name() == "Response" && hasItems(n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findItems sets the items expression and type of p from the response body.
+func findItems(p *Pager, body types.Type, bodyExpr, name string) error {
+	if s, ok := body.Underlying().(*types.Slice); ok && name == "" {
+		p.items, p.elem = bodyExpr, s.Elem()
+		if bodyExpr == "page" {
+			p.items = "*page"
+		}
+		return nil
+	}
+	st, ok := body.Underlying().(*types.Struct)
+	if !ok {
+		return fmt.Errorf("response has no items")
+	}
+
+	var found []int
+	for i := range st.NumFields() {
+		if _, ok := st.Field(i).Type().Underlying().(*types.Slice); !ok {
+			continue
+		}
+		if name == "" || jsonName(st, i) == name {
+			found = append(found, i)
+		}
+	}
+	switch {
+	case len(found) == 1:
+		f := st.Field(found[0])
+		p.items = bodyExpr + "." + f.Name()
+		p.elem = f.Type().Underlying().(*types.Slice).Elem()
+		return nil
+	case name != "":
+		return fmt.Errorf("response has no array field %q", name)
+	case len(found) == 0:
+		return fmt.Errorf("response has no items")
+	default:
+		return fmt.Errorf("response has several array fields; set items")
+	}
+}
+
+// jsonField finds a scalar response field by JSON name.
+func jsonField(body types.Type, bodyExpr string, names []string, explicit string) (field, bool) {
+	st, ok := body.Underlying().(*types.Struct)
+	if !ok {
+		return field{}, false
+	}
+	if explicit != "" {
+		names = []string{explicit}
+	}
+	for _, name := range names {
+		for i := range st.NumFields() {
+			if jsonName(st, i) == name {
+				return scalarField(name, bodyExpr+"."+st.Field(i).Name(), st.Field(i))
+			}
+		}
+	}
+	return field{}, false
+}
+
+// headerField finds a response header field of a headers wrapper.
+func headerField(st *types.Struct, goName string) (field, bool) {
+	for i := range st.NumFields() {
+		if st.Field(i).Name() == goName {
+			return scalarField(goName, "page."+goName, st.Field(i))
+		}
+	}
+	return field{}, false
+}
+
+func jsonName(st *types.Struct, i int) string {
+	tag := st.Tag(i)
+	const prefix = `json:"`
+	start := strings.Index(tag, prefix)
+	if start < 0 {
+		return ""
+	}
+	name := tag[start+len(prefix):]
+	name = name[:strings.IndexAny(name, `",`)]
+	return name
+}
+
+func structField(named *types.Named, goName string) *types.Var {
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	for i := range st.NumFields() {
+		if st.Field(i).Name() == goName {
+			return st.Field(i)
+		}
+	}
+	return nil
+}
+
+// scalarField describes v if it is a string or integer, possibly wrapped in
+// an ogen Opt/OptNil/Nil type.
+func scalarField(name, expr string, v *types.Var) (field, bool) {
+	if v == nil {
+		return field{}, false
+	}
+	wrapper, value := gopkg.Unwrap(v.Type())
+	basic, ok := value.(*types.Basic)
+	if !ok {
+		return field{}, false
+	}
+	return field{name: name, expr: expr, wrapper: wrapper, basic: basic}, true
+}
+
+func isInt(f field) bool {
+	return f.basic != nil && f.basic.Info()&types.IsInteger != 0
+}
+
+func isString(f field) bool {
+	return f.basic != nil && f.basic.Info()&types.IsString != 0
+}
+
+// GenerateIterators generates an iterator per pager.
+func GenerateIterators(pkg *gopkg.Package, pagers []*Pager) (*gopkg.Generated, error) {
+	scope := pkg.Types.Scope()
+	decls := []string{"paginateResponseError", "paginateNextLink"}
+	for _, p := range pagers {
+		decls = append(decls, p.Name+"Iter", "New"+p.Name+"Iter")
+	}
+	for _, decl := range decls {
+		if scope.Lookup(decl) != nil {
+			return nil, fmt.Errorf("%s is already declared", decl)
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-geniterators", pkg.Types)
+	gen.Import("context", "context")
+
+	needsResponseError := false
+	needsLink := false
+	for _, p := range pagers {
+		writeIterator(gen, p)
+		if _, ok := p.result.(*types.Pointer); !ok {
+			needsResponseError = true
+		}
+		if p.Style == StyleLink {
+			needsLink = true
+		}
+	}
+
+	if needsResponseError {
+		gen.Import("github.com/go-faster/errors", "errors")
+		gen.Printf("%s", responseErrorSource)
+	}
+	if needsLink {
+		gen.Import("net/url", "url")
+		gen.Import("slices", "slices")
+		gen.Import("strings", "strings")
+		gen.Printf("%s", nextLinkSource)
+	}
+	return gen, nil
+}
+
+func writeIterator(gen *gopkg.Generated, p *Pager) {
+	iter := p.Name + "Iter"
+	params := p.params.Obj().Name()
+	elem := gen.TypeString(p.elem)
+
+	gen.Printf("// %s iterates over the items of every page of the %s operation.\n", iter, p.ID)
+	gen.Printf("type %s struct {\n", iter)
+	gen.Printf("\tc      Invoker\n\tparams %s\n\titems  []%s\n\titem   %s\n\terr    error\n\tdone   bool\n}\n\n", params, elem, elem)
+
+	gen.Printf("// New%s returns an iterator over %s starting from the page selected\n", iter, p.ID)
+	gen.Printf("// by params. Pages are requested as Next needs them.\n")
+	gen.Printf("func New%s(c Invoker, params %s) *%s {\n", iter, params, iter)
+	if p.Style == StylePage && p.param.wrapper != gopkg.NotWrapped {
+		gen.Printf("\tif !params.%s.Set {\n\t\tparams.%s.SetTo(%d)\n\t}\n",
+			goFieldName(p.param), goFieldName(p.param), p.firstPage)
+	}
+	gen.Printf("\treturn &%s{c: c, params: params}\n}\n\n", iter)
+
+	gen.Printf("// Next advances to the next item, requesting the next page when the current\n")
+	gen.Printf("// one is used up. It returns false when there are no more items or a request\n")
+	gen.Printf("// failed; Err tells them apart.\n")
+	gen.Printf("func (it *%s) Next(ctx context.Context) bool {\n", iter)
+	gen.Printf("\tfor len(it.items) == 0 {\n\t\tif it.done || it.err != nil {\n\t\t\treturn false\n\t\t}\n")
+	gen.Printf("\t\tit.fetch(ctx)\n\t}\n")
+	gen.Printf("\tit.item, it.items = it.items[0], it.items[1:]\n\treturn true\n}\n\n")
+
+	gen.Printf("// Item returns the current item.\n")
+	gen.Printf("func (it *%s) Item() %s {\n\treturn it.item\n}\n\n", iter, elem)
+
+	gen.Printf("// Err returns the error that stopped the iteration, if any.\n")
+	gen.Printf("func (it *%s) Err() error {\n\treturn it.err\n}\n\n", iter)
+
+	gen.Printf("func (it *%s) fetch(ctx context.Context) {\n", iter)
+	if _, ok := p.result.(*types.Pointer); ok {
+		gen.Printf("\tpage, err := it.c.%s(ctx, it.params)\n", p.Name)
+		gen.Printf("\tif err != nil {\n\t\tit.err = err\n\t\treturn\n\t}\n")
+	} else {
+		gen.Printf("\tres, err := it.c.%s(ctx, it.params)\n", p.Name)
+		gen.Printf("\tif err != nil {\n\t\tit.err = err\n\t\treturn\n\t}\n")
+		gen.Printf("\tpage, ok := res.(*%s)\n", p.page.Obj().Name())
+		gen.Printf("\tif !ok {\n\t\tit.err = paginateResponseError(%q, res)\n\t\treturn\n\t}\n", p.ID)
+	}
+	gen.Printf("\tit.items = %s\n", p.items)
+
+	switch p.Style {
+	case StylePage:
+		gen.Printf("\tif len(it.items) == 0 {\n\t\tit.done = true\n\t\treturn\n\t}\n")
+		if p.perPage != nil {
+			gen.Printf("\tif perPage, ok := %s; ok && len(it.items) < int(perPage) {\n", get(*p.perPage))
+			gen.Printf("\t\t// A short page is the last one.\n\t\tit.done = true\n\t\treturn\n\t}\n")
+		}
+		gen.Printf("\t%s\n", set(p.param, current(p.param)+" + 1"))
+	case StyleCursor:
+		gen.Printf("\tnext, ok := %s\n", get(*p.next))
+		gen.Printf("\tif cur, _ := %s; !ok || next == \"\" || next == cur {\n", get(p.param))
+		gen.Printf("\t\tit.done = true\n\t\treturn\n\t}\n")
+		gen.Printf("\t%s\n", set(p.param, "next"))
+	case StyleLink:
+		gen.Printf("\tlink, _ := %s\n", get(*p.link))
+		gen.Printf("\tnext, ok := paginateNextLink(link, %q)\n", p.param.name)
+		gen.Printf("\tif !ok {\n\t\tit.done = true\n\t\treturn\n\t}\n")
+		if isInt(p.param) {
+			gen.Import("strconv", "strconv")
+			gen.Import("github.com/go-faster/errors", "errors")
+			gen.Printf("\tn, err := strconv.ParseInt(next, 10, 64)\n")
+			gen.Printf("\tif err != nil {\n\t\tit.err = errors.Wrap(err, \"parse next %s\")\n\t\treturn\n\t}\n", p.param.name)
+			gen.Printf("\t%s\n", set(p.param, p.param.basic.Name()+"(n)"))
+		} else {
+			gen.Printf("\t%s\n", set(p.param, "next"))
+		}
+	}
+	gen.Printf("}\n\n")
+}
+
+func goFieldName(f field) string {
+	return f.expr[strings.LastIndex(f.expr, ".")+1:]
+}
+
+// get returns an expression with the value of f and whether it is set.
+func get(f field) string {
+	if f.wrapper == gopkg.NotWrapped {
+		if isInt(f) {
+			return fmt.Sprintf("%s, %s != 0", f.expr, f.expr)
+		}
+		return fmt.Sprintf("%s, %s != \"\"", f.expr, f.expr)
+	}
+	return f.expr + ".Get()"
+}
+
+// current returns the value of a parameter, which New has set for wrappers.
+func current(f field) string {
+	if f.wrapper == gopkg.NotWrapped {
+		return f.expr
+	}
+	return f.expr + ".Value"
+}
+
+// set returns a statement setting a parameter to v.
+func set(f field, v string) string {
+	if f.wrapper == gopkg.NotWrapped {
+		return fmt.Sprintf("%s = %s", f.expr, v)
+	}
+	return fmt.Sprintf("%s.SetTo(%s)", f.expr, v)
+}
+
+// responseErrorSource turns responses other than the page into errors.
+const responseErrorSource = `// paginateResponseError returns the error for a response that is not a page.
+// Error responses that implement error are returned as is.
+func paginateResponseError(op string, res any) error {
+	if err, ok := res.(error); ok {
+		return err
+	}
+	return errors.Errorf("%s: unexpected response %T", op, res)
+}
+
+`
+
+// nextLinkSource parses RFC 8288 Link headers.
+const nextLinkSource = `// paginateNextLink returns the value of the query parameter name in the
+// rel="next" URL of a Link header.
+func paginateNextLink(header, name string) (string, bool) {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+		if !ok {
+			continue
+		}
+		next := false
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "rel") && slices.Contains(strings.Fields(strings.Trim(value, ` + "`\"`" + `)), "next") {
+				next = true
+			}
+		}
+		if !next {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			return "", false
+		}
+		v := u.Query().Get(name)
+		return v, v != ""
+	}
+	return "", false
+}
+
+`
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const clientSource = `package api
+
+import "context"
+
+type Invoker interface {
+	GetUser(ctx context.Context, params GetUserParams) (*User, error)
+	ListEvents(ctx context.Context, params ListEventsParams) (ListEventsRes, error)
+	ListRepos(ctx context.Context, params ListReposParams) (*ListReposOKHeaders, error)
+	ListUsers(ctx context.Context, params ListUsersParams) (*ListUsersOK, error)
+}
+
+type Client struct{}
+
+// GetUser invokes getUser operation.
+func (c *Client) GetUser(ctx context.Context, params GetUserParams) (*User, error) {
+	return c.sendGetUser(ctx, params)
+}
+
+func (c *Client) sendGetUser(ctx context.Context, params GetUserParams) (res *User, err error) {
+	{
+		// Encode "id" parameter.
+		_ = params.ID
+	}
+	return res, nil
+}
+
+// ListEvents invokes listEvents operation.
+func (c *Client) ListEvents(ctx context.Context, params ListEventsParams) (ListEventsRes, error) {
+	return c.sendListEvents(ctx, params)
+}
+
+func (c *Client) sendListEvents(ctx context.Context, params ListEventsParams) (res ListEventsRes, err error) {
+	{
+		// Encode "after" parameter.
+		if val, ok := params.After.Get(); ok {
+			_ = val
+		}
+	}
+	return res, nil
+}
+
+// ListRepos invokes listRepos operation.
+func (c *Client) ListRepos(ctx context.Context, params ListReposParams) (*ListReposOKHeaders, error) {
+	return c.sendListRepos(ctx, params)
+}
+
+func (c *Client) sendListRepos(ctx context.Context, params ListReposParams) (res *ListReposOKHeaders, err error) {
+	{
+		// Encode "page" parameter.
+		_ = params.Page
+	}
+	return res, nil
+}
+
+// ListUsers invokes listUsers operation.
+func (c *Client) ListUsers(ctx context.Context, params ListUsersParams) (*ListUsersOK, error) {
+	return c.sendListUsers(ctx, params)
+}
+
+func (c *Client) sendListUsers(ctx context.Context, params ListUsersParams) (res *ListUsersOK, err error) {
+	{
+		// Encode "page" parameter.
+		if val, ok := params.Page.Get(); ok {
+			_ = val
+		}
+	}
+	{
+		// Encode "per_page" parameter.
+		if val, ok := params.PerPage.Get(); ok {
+			_ = val
+		}
+	}
+	return res, nil
+}
+`
+
+const schemasSource = `package api
+
+type User struct {
+	ID string ` + "`json:\"id\"`" + `
+}
+
+type GetUserParams struct {
+	ID string
+}
+
+type ListEventsParams struct {
+	After OptString
+}
+
+type ListEventsRes interface {
+	listEventsRes()
+}
+
+type ListEventsOK struct {
+	Data []string     ` + "`json:\"data\"`" + `
+	Next OptNilString ` + "`json:\"next\"`" + `
+}
+
+func (*ListEventsOK) listEventsRes() {}
+
+type Error struct {
+	Message string ` + "`json:\"message\"`" + `
+}
+
+func (*Error) listEventsRes() {}
+
+type ListReposParams struct {
+	Page int
+}
+
+type ListReposOKHeaders struct {
+	Link     OptString
+	Response []User
+}
+
+type ListUsersParams struct {
+	Page    OptInt
+	PerPage OptInt
+}
+
+type ListUsersOK struct {
+	Users []User ` + "`json:\"users\"`" + `
+	Total OptInt ` + "`json:\"total\"`" + `
+}
+
+type OptInt struct {
+	Value int
+	Set   bool
+}
+
+func (o *OptInt) SetTo(v int)          { o.Set = true; o.Value = v }
+func (o OptInt) Get() (v int, ok bool) { return o.Value, o.Set }
+
+type OptString struct {
+	Value string
+	Set   bool
+}
+
+func (o *OptString) SetTo(v string)          { o.Set = true; o.Value = v }
+func (o OptString) Get() (v string, ok bool) { return o.Value, o.Set }
+
+type OptNilString struct {
+	Value string
+	Set   bool
+	Null  bool
+}
+
+func (o OptNilString) Get() (v string, ok bool) { return o.Value, o.Set && !o.Null }
+`
+
+func TestParamFields(t *testing.T) {
+	got := ParamFields([]byte(clientSource))
+	if got["ListUsers"]["per_page"] != "PerPage" || got["ListEvents"]["after"] != "After" {
+		t.Errorf("ParamFields() = %v", got)
+	}
+	if ids := OperationIDs([]byte(clientSource)); ids["ListUsers"] != "listUsers" {
+		t.Errorf("OperationIDs() = %v", ids)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := writePackage(t)
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, outputFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+
+	for _, want := range []string{
+		"// Code generated by ogen-geniterators, DO NOT EDIT.",
+		// page: an unset page starts at 1, a short page is the last one.
+		"func NewListUsersIter(c Invoker, params ListUsersParams) *ListUsersIter {\n\tif !params.Page.Set {\n\t\tparams.Page.SetTo(1)",
+		"func (it *ListUsersIter) Item() User {",
+		"\tif perPage, ok := it.params.PerPage.Get(); ok && len(it.items) < int(perPage) {",
+		"\tit.params.Page.SetTo(it.params.Page.Value + 1)",
+		// cursor: the page is picked out of the sum of responses.
+		"\tpage, ok := res.(*ListEventsOK)\n",
+		"\tnext, ok := page.Next.Get()\n",
+		"\tit.params.After.SetTo(next)",
+		"func (it *ListEventsIter) Item() string {",
+		// link: the items are the body of the headers wrapper.
+		"\tit.items = page.Response\n",
+		"\tnext, ok := paginateNextLink(link, \"page\")\n",
+		"\tit.params.Page = int(n)",
+		"func paginateNextLink(header, name string) (string, bool) {",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "GetUserIter") {
+		t.Error("generated an iterator for an operation without pagination")
+	}
+
+	// The generated file must compile against the package.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Errorf("generated code does not type-check: %v", err)
+	}
+}
+
+func TestRun_Config(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    []string
+		notWant []string
+		wantErr string
+	}{
+		{
+			name:    "disable",
+			config:  `{"listUsers": {"style": "none"}}`,
+			notWant: []string{"ListUsersIter"},
+		},
+		{
+			name:   "first page",
+			config: `{"listUsers": {"firstPage": 0}}`,
+			want:   []string{"params.Page.SetTo(0)"},
+		},
+		{
+			name:    "explicit style must apply",
+			config:  `{"getUser": {"style": "page"}}`,
+			wantErr: "getUser: response has no items",
+		},
+		{
+			name:    "unknown operation",
+			config:  `{"listThings": {}}`,
+			wantErr: "operation listThings not found",
+		},
+		{
+			name:    "unknown items field",
+			config:  `{"listUsers": {"items": "results"}}`,
+			wantErr: `listUsers: response has no array field "results"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writePackage(t)
+			configFile := filepath.Join(t.TempDir(), "pagination.json")
+			writeFile(t, configFile, tt.config)
+
+			err := run([]string{"-config", configFile, dir})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run: %v", err)
+			}
+
+			got, err := os.ReadFile(filepath.Join(dir, outputFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("output missing %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(string(got), notWant) {
+					t.Errorf("output contains %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+func writePackage(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_client_gen.go"), clientSource)
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource)
+	return dir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,403 @@
+// Command ogen-genmergepatch generates tri-state JSON Merge Patch request
+// types for ogen clients.
+//
+// A JSON Merge Patch (RFC 7396) distinguishes three states per field: absent
+// (leave unchanged), null (remove) and a value (replace). ogen generates the
+// same request type as for a full object, which cannot express null for
+// non-nullable fields and always sends required fields, so merge-patch bodies
+// end up hand-written as map[string]any outside the generated client.
+//
+// For every operation with an application/merge-patch+json request body this
+// tool generates a <Type>Patch type and switches the client method to it:
+//
+//	patch := api.NewUserPatch().
+//		SetEmail("ada@example.com"). // {"email": "ada@example.com"}
+//		SetManagerNull()             // {"manager": null}
+//	err := client.PatchUser(ctx, patch, api.PatchUserParams{ID: "42"})
+//
+// Usage:
+//
+//	ogen-genmergepatch <generated-dir>
+//
+// The patch types are written to oas_mergepatch_gen.go. The client methods in
+// oas_client_gen.go and the request encoders in oas_request_encoders_gen.go
+// are rewritten in place; server code is left unchanged.
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the patch types are written to.
+const outputFile = "oas_mergepatch_gen.go"
+
+// mergePatchContentType is the media type of JSON Merge Patch bodies.
+const mergePatchContentType = "application/merge-patch+json"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-genmergepatch: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-genmergepatch <generated-dir>")
+	}
+
+	dir := args[0]
+	encodersFile := filepath.Join(dir, "oas_request_encoders_gen.go")
+	clientFile := filepath.Join(dir, "oas_client_gen.go")
+
+	encoders, err := os.ReadFile(encodersFile) // #nosec G703 -- CLI tool, filename from trusted args
+	if os.IsNotExist(err) {
+		fmt.Printf("No merge-patch operations found in %s\n", dir)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	ops := FindMergePatchOperations(encoders)
+	if len(ops) == 0 {
+		fmt.Printf("No merge-patch operations found in %s\n", dir)
+		return nil
+	}
+
+	client, err := os.ReadFile(clientFile) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	// The output file is loaded too: on a second run the client already
+	// refers to the patch types it declares.
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	gen, patchTypes, err := GeneratePatchTypes(pkg, ops)
+	if err != nil {
+		return err
+	}
+
+	fixedClient, fixedEncoders := RewriteClient(client, encoders, ops, patchTypes)
+
+	if err := gen.WriteFile(filepath.Join(pkg.Dir, outputFile)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(clientFile, fixedClient, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := os.WriteFile(encodersFile, fixedEncoders, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	fmt.Printf("Generated %d patch types for %d operations in %s\n", countTypes(patchTypes), len(ops), dir)
+	return nil
+}
+
+// Operation is a client operation with a merge-patch request body.
+type Operation struct {
+	// Name is the Go operation name, e.g. PatchUser.
+	Name string
+	// ReqType is the request type as spelled in the encoder, e.g. OptUser,
+	// *User, or *UserPatch once rewritten.
+	ReqType string
+	// Optional reports whether the request body is optional.
+	Optional bool
+}
+
+// encoderPattern matches a generated request encoder with a single content
+// type. Operations accepting several content types take a sum type and are
+// not supported.
+var encoderPattern = regexp.MustCompile(
+	`(?ms)^func encode(\w+)Request\(\n\treq (\S+),\n\tr \*http\.Request,\n\) error \{\n\tconst contentType = "([^"]+)"\n.*?^\}\n`)
+
+// FindMergePatchOperations returns the operations whose request encoder sends
+// application/merge-patch+json, in file order.
+func FindMergePatchOperations(encoders []byte) []Operation {
+	var ops []Operation
+	for _, m := range encoderPattern.FindAllSubmatch(encoders, -1) {
+		if string(m[3]) != mergePatchContentType {
+			continue
+		}
+		ops = append(ops, Operation{
+			Name:     string(m[1]),
+			ReqType:  string(m[2]),
+			Optional: strings.Contains(string(m[0]), "Keep request with empty body"),
+		})
+	}
+	return ops
+}
+
+// GeneratePatchTypes generates a patch type for the request body struct of
+// each operation. It returns the patch type name for each operation.
+func GeneratePatchTypes(pkg *gopkg.Package, ops []Operation) (*gopkg.Generated, map[string]string, error) {
+	scope := pkg.Types.Scope()
+	outputPath := filepath.Join(pkg.Dir, outputFile)
+	declaredHere := func(name string) bool {
+		obj := scope.Lookup(name)
+		return obj != nil && pkg.Fset.Position(obj.Pos()).Filename == outputPath
+	}
+
+	structs := make(map[string]*types.Named)
+	patchTypes := make(map[string]string)
+	for _, op := range ops {
+		named, err := requestStruct(pkg, op.ReqType, declaredHere)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", op.Name, err)
+		}
+		structs[named.Obj().Name()] = named
+		patchTypes[op.Name] = named.Obj().Name() + "Patch"
+	}
+
+	var names []string
+	for name := range structs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var decls []string
+	for _, name := range names {
+		decls = append(decls, name+"Patch", "New"+name+"Patch", "jsonFieldsOf"+name+"Patch")
+	}
+	decls = append(decls, "mergePatchState", "mergePatchAbsent", "mergePatchValue", "mergePatchNull", "encodeMergePatch")
+	for _, decl := range decls {
+		if scope.Lookup(decl) != nil && !declaredHere(decl) {
+			return nil, nil, fmt.Errorf("%s is already declared", decl)
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-genmergepatch", pkg.Types)
+	writeCommon(gen)
+	for _, name := range names {
+		if err := writePatchType(gen, structs[name]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return gen, patchTypes, nil
+}
+
+func countTypes(patchTypes map[string]string) int {
+	seen := make(map[string]bool)
+	for _, t := range patchTypes {
+		seen[t] = true
+	}
+	return len(seen)
+}
+
+// requestStruct resolves the struct a merge-patch request body is made of.
+// reqType is OptT or *T as generated by ogen, or *TPatch after a previous run.
+func requestStruct(pkg *gopkg.Package, reqType string, declaredHere func(string) bool) (*types.Named, error) {
+	name := strings.TrimPrefix(reqType, "*")
+	obj, ok := pkg.Types.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("request type %s not found", reqType)
+	}
+
+	t := obj.Type()
+	if declaredHere(name) {
+		// A patch type from a previous run wraps the original struct in v.
+		t = t.Underlying().(*types.Struct).Field(0).Type()
+	} else if _, value := gopkg.Unwrap(t); value != t {
+		t = value
+	}
+
+	named, ok := t.(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("request type %s is not a named struct", reqType)
+	}
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok || st.NumFields() == 0 {
+		return nil, fmt.Errorf("request type %s is not an object", reqType)
+	}
+	if st.Field(0).Name() == "Type" {
+		if d, ok := st.Field(0).Type().(*types.Named); ok && d.Obj().Name() == named.Obj().Name()+"Type" {
+			return nil, fmt.Errorf("request type %s is a sum type", reqType)
+		}
+	}
+	return named, nil
+}
+
+func writeCommon(gen *gopkg.Generated) {
+	gen.Import("github.com/go-faster/jx", "jx")
+	gen.Printf(`// mergePatchState is the state of one field of a merge patch.
+type mergePatchState uint8
+
+const (
+	mergePatchAbsent mergePatchState = iota // not sent, left unchanged
+	mergePatchValue                         // sent with its value
+	mergePatchNull                          // sent as null, removed
+)
+
+// encodeMergePatch writes the fields of v selected by state. The values come
+// from v's generated encoder, so they are encoded exactly as in a full
+// request.
+func encodeMergePatch(e *jx.Encoder, v interface{ Encode(*jx.Encoder) }, names []string, state []mergePatchState) {
+	var full jx.Encoder
+	v.Encode(&full)
+	values := make(map[string]jx.Raw, len(names))
+	// full was written by a generated encoder, so it is always a valid object.
+	_ = jx.DecodeBytes(full.Bytes()).ObjBytes(func(d *jx.Decoder, k []byte) error {
+		raw, err := d.Raw()
+		if err != nil {
+			return err
+		}
+		values[string(k)] = raw
+		return nil
+	})
+	for i, name := range names {
+		switch state[i] {
+		case mergePatchValue:
+			if raw, ok := values[name]; ok {
+				e.FieldStart(name)
+				e.Raw(raw)
+			}
+		case mergePatchNull:
+			e.FieldStart(name)
+			e.Null()
+		}
+	}
+}
+
+`)
+}
+
+func writePatchType(gen *gopkg.Generated, named *types.Named) error {
+	name := named.Obj().Name()
+	patch := name + "Patch"
+	st := named.Underlying().(*types.Struct)
+
+	type field struct {
+		goName   string
+		jsonName string
+		v        *types.Var
+	}
+	var fields []field
+	for i := range st.NumFields() {
+		v := st.Field(i)
+		jsonName, _, _ := strings.Cut(reflect.StructTag(st.Tag(i)).Get("json"), ",")
+		if !v.Exported() || jsonName == "" || jsonName == "-" {
+			continue
+		}
+		fields = append(fields, field{goName: v.Name(), jsonName: jsonName, v: v})
+	}
+
+	gen.Printf("// %s is a JSON Merge Patch (RFC 7396) for %s.\n", patch, name)
+	gen.Printf("//\n// Fields are left out until set: SetX sends a value and SetXNull sends null,\n")
+	gen.Printf("// which removes the field.\n")
+	gen.Printf("type %s struct {\n\tv     %s\n\tstate [%d]mergePatchState\n}\n\n", patch, name, len(fields))
+
+	gen.Printf("var jsonFieldsOf%s = [%d]string{\n", patch, len(fields))
+	for _, f := range fields {
+		gen.Printf("\t%q,\n", f.jsonName)
+	}
+	gen.Printf("}\n\n")
+
+	gen.Printf("// New%s returns an empty patch for %s.\n", patch, name)
+	gen.Printf("func New%s() *%s {\n\treturn &%s{}\n}\n\n", patch, patch, patch)
+
+	used := map[string]string{"Encode": "", "MarshalJSON": ""}
+	method := func(methodName, goName string) error {
+		if prev, ok := used[methodName]; ok {
+			return fmt.Errorf("%s: method %s for field %s collides with %s", patch, methodName, goName, prev)
+		}
+		used[methodName] = goName
+		return nil
+	}
+
+	for i, f := range fields {
+		setter, nuller, unsetter := "Set"+f.goName, "Set"+f.goName+"Null", "Unset"+f.goName
+		for _, m := range []string{setter, nuller, unsetter} {
+			if err := method(m, f.goName); err != nil {
+				return err
+			}
+		}
+
+		kind, value := gopkg.Unwrap(f.v.Type())
+		gen.Printf("// %s sets %s to v.\n", setter, f.jsonName)
+		gen.Printf("func (p *%s) %s(v %s) *%s {\n", patch, setter, gen.TypeString(value), patch)
+		if _, ok := value.Underlying().(*types.Slice); ok {
+			// Generated encoders skip nil slices; a set slice is always sent.
+			gen.Printf("\tif v == nil {\n\t\tv = %s{}\n\t}\n", gen.TypeString(value))
+		}
+		if kind == gopkg.NotWrapped {
+			gen.Printf("\tp.v.%s = v\n", f.goName)
+		} else {
+			gen.Printf("\tp.v.%s.SetTo(v)\n", f.goName)
+		}
+		gen.Printf("\tp.state[%d] = mergePatchValue\n\treturn p\n}\n\n", i)
+
+		gen.Printf("// %s sets %s to null, removing it.\n", nuller, f.jsonName)
+		gen.Printf("func (p *%s) %s() *%s {\n\tp.state[%d] = mergePatchNull\n\treturn p\n}\n\n",
+			patch, nuller, patch, i)
+
+		gen.Printf("// %s leaves %s out of the patch.\n", unsetter, f.jsonName)
+		gen.Printf("func (p *%s) %s() *%s {\n\tp.state[%d] = mergePatchAbsent\n\treturn p\n}\n\n",
+			patch, unsetter, patch, i)
+	}
+
+	gen.Printf("// Encode encodes the fields that were set. A nil patch encodes as {}.\n")
+	gen.Printf("func (p *%s) Encode(e *jx.Encoder) {\n", patch)
+	gen.Printf("\te.ObjStart()\n\tif p != nil {\n")
+	gen.Printf("\t\tencodeMergePatch(e, &p.v, jsonFieldsOf%s[:], p.state[:])\n", patch)
+	gen.Printf("\t}\n\te.ObjEnd()\n}\n\n")
+
+	gen.Printf("// MarshalJSON implements stdjson.Marshaler.\n")
+	gen.Printf("func (p *%s) MarshalJSON() ([]byte, error) {\n", patch)
+	gen.Printf("\te := jx.Encoder{}\n\tp.Encode(&e)\n\treturn e.Bytes(), nil\n}\n\n")
+	return nil
+}
+
+// validatePattern matches the request validation block ogen adds to send
+// methods when request validation is enabled. A patch is partial by design,
+// so validating it as a full object would reject valid patches.
+var validatePattern = regexp.MustCompile(
+	`(?s)\n\t// Validate request before sending\.\n\tif err := func\(\) error \{\n.*?\n\t\}\(\); err != nil \{\n\t\treturn res, errors\.Wrap\(err, "validate"\)\n\t\}\n`)
+
+// RewriteClient switches the client methods and request encoders of ops to
+// the patch types.
+func RewriteClient(client, encoders []byte, ops []Operation, patchTypes map[string]string) ([]byte, []byte) {
+	for _, op := range ops {
+		patch := "*" + patchTypes[op.Name]
+		reqType := regexp.QuoteMeta(op.ReqType)
+
+		// Invoker method, Client method and send method signatures.
+		sig := regexp.MustCompile(`(?m)^((?:\t|func \(c \*Client\) )(?:send)?` + op.Name +
+			`\(ctx context\.Context, request )` + reqType + `([,)])`)
+		client = sig.ReplaceAll(client, []byte("${1}"+patch+"${2}"))
+
+		send := regexp.MustCompile(`(?ms)^func \(c \*Client\) send` + op.Name + `\(.*?^\}\n`)
+		client = send.ReplaceAllFunc(client, func(fn []byte) []byte {
+			return validatePattern.ReplaceAll(fn, []byte("\n"))
+		})
+
+		encoder := regexp.MustCompile(`(?ms)^func encode` + op.Name + `Request\(\n.*?^\}\n`)
+		encoders = encoder.ReplaceAllLiteral(encoders, []byte(patchEncoder(op, patch)))
+	}
+	return client, encoders
+}
+
+// patchEncoder returns the request encoder for op sending a patch.
+func patchEncoder(op Operation, patch string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "func encode%sRequest(\n\treq %s,\n\tr *http.Request,\n) error {\n", op.Name, patch)
+	fmt.Fprintf(&b, "\tconst contentType = %q\n", mergePatchContentType)
+	if op.Optional {
+		b.WriteString("\tif req == nil {\n\t\t// Keep request with empty body if value is not set.\n\t\treturn nil\n\t}\n")
+	}
+	b.WriteString("\te := new(jx.Encoder)\n\treq.Encode(e)\n")
+	b.WriteString("\tencoded := e.Bytes()\n\tht.SetBody(r, bytes.NewReader(encoded), contentType)\n\treturn nil\n}\n")
+	return b.String()
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+
+	// The fixture package and the generated code import these, so they must
+	// be in go.mod for TestRun to type-check them.
+	_ "github.com/go-faster/jx"
+	_ "github.com/ogen-go/ogen/http"
+)
+
+const encodersSource = `package api
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/go-faster/jx"
+
+	ht "github.com/ogen-go/ogen/http"
+)
+
+func encodeCreateUserRequest(
+	req *User,
+	r *http.Request,
+) error {
+	const contentType = "application/json"
+	e := new(jx.Encoder)
+	{
+		req.Encode(e)
+	}
+	encoded := e.Bytes()
+	ht.SetBody(r, bytes.NewReader(encoded), contentType)
+	return nil
+}
+
+func encodePatchUserRequest(
+	req OptUser,
+	r *http.Request,
+) error {
+	const contentType = "application/merge-patch+json"
+	if !req.Set {
+		// Keep request with empty body if value is not set.
+		return nil
+	}
+	e := new(jx.Encoder)
+	{
+		if req.Set {
+			req.Encode(e)
+		}
+	}
+	encoded := e.Bytes()
+	ht.SetBody(r, bytes.NewReader(encoded), contentType)
+	return nil
+}
+
+func encodeReplaceUserRequest(
+	req *User,
+	r *http.Request,
+) error {
+	const contentType = "application/merge-patch+json"
+	e := new(jx.Encoder)
+	{
+		req.Encode(e)
+	}
+	encoded := e.Bytes()
+	ht.SetBody(r, bytes.NewReader(encoded), contentType)
+	return nil
+}
+`
+
+const clientSource = `package api
+
+import (
+	"context"
+
+	"github.com/go-faster/errors"
+)
+
+type Invoker interface {
+	CreateUser(ctx context.Context, request *User) error
+	PatchUser(ctx context.Context, request OptUser, params PatchUserParams) error
+	ReplaceUser(ctx context.Context, request *User) error
+}
+
+type Client struct{}
+
+type PatchUserParams struct {
+	ID string
+}
+
+// CreateUser invokes createUser operation.
+func (c *Client) CreateUser(ctx context.Context, request *User) error {
+	_, err := c.sendCreateUser(ctx, request)
+	return err
+}
+
+func (c *Client) sendCreateUser(ctx context.Context, request *User) (res struct{}, err error) {
+	if request == nil {
+		return res, errors.New("nil request")
+	}
+	return res, nil
+}
+
+// PatchUser invokes patchUser operation.
+func (c *Client) PatchUser(ctx context.Context, request OptUser, params PatchUserParams) error {
+	_, err := c.sendPatchUser(ctx, request, params)
+	return err
+}
+
+func (c *Client) sendPatchUser(ctx context.Context, request OptUser, params PatchUserParams) (res struct{}, err error) {
+	// Validate request before sending.
+	if err := func() error {
+		if value, ok := request.Get(); ok {
+			if err := func() error {
+				return value.Validate()
+			}(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}(); err != nil {
+		return res, errors.Wrap(err, "validate")
+	}
+	return res, nil
+}
+
+// ReplaceUser invokes replaceUser operation.
+func (c *Client) ReplaceUser(ctx context.Context, request *User) error {
+	_, err := c.sendReplaceUser(ctx, request)
+	return err
+}
+
+func (c *Client) sendReplaceUser(ctx context.Context, request *User) (res struct{}, err error) {
+	return res, nil
+}
+`
+
+const schemasSource = `package api
+
+import "github.com/go-faster/jx"
+
+type User struct {
+	Name    string     ` + "`json:\"name\"`" + `
+	Email   OptString  ` + "`json:\"email\"`" + `
+	Manager OptNilUser ` + "`json:\"manager\"`" + `
+	Tags    []string   ` + "`json:\"tags\"`" + `
+}
+
+func (s *User) Encode(e *jx.Encoder) {
+	e.ObjStart()
+	e.FieldStart("name")
+	e.Str(s.Name)
+	if s.Email.Set {
+		e.FieldStart("email")
+		e.Str(s.Email.Value)
+	}
+	if s.Tags != nil {
+		e.FieldStart("tags")
+		e.ArrStart()
+		for _, tag := range s.Tags {
+			e.Str(tag)
+		}
+		e.ArrEnd()
+	}
+	e.ObjEnd()
+}
+
+func (s *User) Validate() error { return nil }
+
+type OptString struct {
+	Value string
+	Set   bool
+}
+
+func (o *OptString) SetTo(v string) { o.Set = true; o.Value = v }
+
+type OptUser struct {
+	Value User
+	Set   bool
+}
+
+func (o OptUser) Get() (User, bool) { return o.Value, o.Set }
+
+func (o OptUser) Encode(e *jx.Encoder) { o.Value.Encode(e) }
+
+type OptNilUser struct {
+	Value *User
+	Set   bool
+	Null  bool
+}
+
+func (o *OptNilUser) SetTo(v *User) { o.Set = true; o.Null = false; o.Value = v }
+`
+
+func TestFindMergePatchOperations(t *testing.T) {
+	ops := FindMergePatchOperations([]byte(encodersSource))
+	want := []Operation{
+		{Name: "PatchUser", ReqType: "OptUser", Optional: true},
+		{Name: "ReplaceUser", ReqType: "*User", Optional: false},
+	}
+	if len(ops) != len(want) {
+		t.Fatalf("got %d operations, want %d: %+v", len(ops), len(want), ops)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Errorf("operation %d = %+v, want %+v", i, ops[i], want[i])
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_request_encoders_gen.go"), encodersSource)
+	writeFile(t, filepath.Join(dir, "oas_client_gen.go"), clientSource)
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), schemasSource)
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	out := readFile(t, filepath.Join(dir, outputFile))
+	for _, want := range []string{
+		"// Code generated by ogen-genmergepatch, DO NOT EDIT.",
+		"type UserPatch struct {\n\tv     User\n\tstate [4]mergePatchState\n}",
+		"func (p *UserPatch) SetName(v string) *UserPatch {\n\tp.v.Name = v",
+		"func (p *UserPatch) SetEmail(v string) *UserPatch {\n\tp.v.Email.SetTo(v)",
+		"func (p *UserPatch) SetManager(v *User) *UserPatch {\n\tp.v.Manager.SetTo(v)",
+		"func (p *UserPatch) SetEmailNull() *UserPatch {\n\tp.state[1] = mergePatchNull",
+		"func (p *UserPatch) UnsetTags() *UserPatch {\n\tp.state[3] = mergePatchAbsent",
+		// A set slice is sent even when empty.
+		"\tif v == nil {\n\t\tv = []string{}\n\t}\n\tp.v.Tags = v",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	client := readFile(t, filepath.Join(dir, "oas_client_gen.go"))
+	for _, want := range []string{
+		"\tPatchUser(ctx context.Context, request *UserPatch, params PatchUserParams) error",
+		"\tReplaceUser(ctx context.Context, request *UserPatch) error",
+		"func (c *Client) PatchUser(ctx context.Context, request *UserPatch, params PatchUserParams) error {",
+		"func (c *Client) sendPatchUser(ctx context.Context, request *UserPatch, params PatchUserParams) (",
+		"func (c *Client) sendReplaceUser(ctx context.Context, request *UserPatch) (",
+		// application/json operations are left alone.
+		"func (c *Client) sendCreateUser(ctx context.Context, request *User) (",
+	} {
+		if !strings.Contains(client, want) {
+			t.Errorf("client missing %q", want)
+		}
+	}
+	if strings.Contains(client, "Validate request before sending") {
+		t.Error("patch request is still validated as a full object")
+	}
+
+	encoders := readFile(t, filepath.Join(dir, "oas_request_encoders_gen.go"))
+	if !strings.Contains(encoders, "\treq *UserPatch,\n\tr *http.Request,\n) error {\n\tconst contentType = \"application/merge-patch+json\"\n\tif req == nil {") {
+		t.Errorf("optional patch encoder not rewritten:\n%s", encoders)
+	}
+
+	// The rewritten package must compile.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Fatalf("generated code does not type-check: %v", err)
+	}
+
+	// Running again on the rewritten package changes nothing.
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if got := readFile(t, filepath.Join(dir, outputFile)); got != out {
+		t.Errorf("second run changed the output:\n%s", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "oas_client_gen.go")); got != client {
+		t.Errorf("second run changed the client:\n%s", got)
+	}
+}
+
+func TestRun_NoMergePatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_request_encoders_gen.go"),
+		strings.ReplaceAll(encodersSource, "application/merge-patch+json", "application/json"))
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, outputFile)); !os.IsNotExist(err) {
+		t.Errorf("patch file written without merge-patch operations: %v", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,380 @@
+// Command ogen-genretry generates a retrying wrapper for ogen clients.
+//
+// Retrying in an http.RoundTripper is blind: it doesn't know which operations
+// are safe to repeat, so it either retries POSTs or none at all. This tool
+// knows the HTTP method of every operation and generates a RetryClient that
+// implements Invoker, retrying idempotent operations on 429, 5xx and network
+// errors and passing the others straight through:
+//
+//	client, err := api.NewClient(serverURL)
+//	retrying := api.NewRetryClient(client, api.RetryPolicy{
+//		MaxAttempts: 5,
+//		// Safe to retry: the server deduplicates by Idempotency-Key.
+//		Operations: []api.OperationName{api.CreateOrderOperation},
+//	})
+//
+// Usage:
+//
+//	ogen-genretry <generated-dir>
+//
+// The wrapper is written to oas_retry_gen.go in the generated directory, so
+// ogen --clean removes it along with the rest of the generated code.
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the wrapper is written to.
+const outputFile = "oas_retry_gen.go"
+
+// idempotentMethods are the HTTP methods RFC 9110 defines as idempotent.
+var idempotentMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"OPTIONS": true,
+	"TRACE":   true,
+	"PUT":     true,
+	"DELETE":  true,
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-genretry: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-genretry <generated-dir>")
+	}
+
+	dir := args[0]
+	client, err := os.ReadFile(filepath.Join(dir, "oas_client_gen.go")) // #nosec G703 -- CLI tool, filename from trusted args
+	if os.IsNotExist(err) {
+		fmt.Printf("No client found in %s\n", dir)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	pkg, err := gopkg.Load(dir, outputFile)
+	if err != nil {
+		return err
+	}
+
+	ops := pkg.Operations()
+	if len(ops) == 0 {
+		fmt.Printf("No client found in %s\n", dir)
+		return nil
+	}
+
+	gen, retried, err := GenerateRetryClient(pkg, ops, HTTPMethods(client))
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(pkg.Dir, outputFile)
+	if err := gen.WriteFile(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated retry client for %d operations (%d retried by default) in %s\n", len(ops), retried, path)
+	return nil
+}
+
+// newRequestPattern finds the HTTP method of each generated send method.
+var newRequestPattern = regexp.MustCompile(
+	`(?ms)^func \(c \*Client\) send(\w+)\(.*?\tr, err := ht\.NewRequest\(ctx, "(\w+)", u\)`)
+
+// HTTPMethods maps the Go names of the client operations to their HTTP
+// methods.
+func HTTPMethods(client []byte) map[string]string {
+	methods := make(map[string]string)
+	for _, m := range newRequestPattern.FindAllSubmatch(client, -1) {
+		methods[string(m[1])] = string(m[2])
+	}
+	return methods
+}
+
+// GenerateRetryClient generates RetryClient with a method per operation. It
+// returns the number of operations retried without being listed in
+// RetryPolicy.Operations.
+func GenerateRetryClient(pkg *gopkg.Package, ops []gopkg.Operation, methods map[string]string) (*gopkg.Generated, int, error) {
+	scope := pkg.Types.Scope()
+	for _, decl := range []string{"RetryClient", "NewRetryClient", "RetryPolicy", "retryDelay", "retryStatus", "retryAfter"} {
+		if scope.Lookup(decl) != nil {
+			return nil, 0, fmt.Errorf("%s is already declared", decl)
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-genretry", pkg.Types)
+	gen.Import("context", "context")
+	gen.Import("time", "time")
+	gen.Printf("%s", retryClientSource)
+
+	retried := 0
+	for _, op := range ops {
+		method, ok := methods[op.Name]
+		if !ok {
+			return nil, 0, fmt.Errorf("%s: HTTP method not found", op.Name)
+		}
+		if scope.Lookup(op.Name+"Operation") == nil {
+			return nil, 0, fmt.Errorf("%s: %sOperation not found", op.Name, op.Name)
+		}
+
+		mode := retryListed
+		switch {
+		case streamsBody(op.Sig):
+			mode = retryNever
+		case idempotentMethods[method]:
+			mode = retryAlways
+			retried++
+		}
+		writeMethod(gen, op, method, mode)
+	}
+
+	gen.Import("math/rand/v2", "rand")
+	gen.Import("net/http", "http")
+	gen.Import("net/url", "url")
+	gen.Import("strconv", "strconv")
+	gen.Import("github.com/go-faster/errors", "errors")
+	gen.Import("github.com/ogen-go/ogen/validate", "validate")
+	gen.Printf("%s", retryHelpersSource)
+	return gen, retried, nil
+}
+
+// streamsBody reports whether an operation takes a request body that is read
+// from an io.Reader, such as an application/octet-stream upload.
+func streamsBody(sig *types.Signature) bool {
+	for i := range sig.Params().Len() {
+		if hasReader(sig.Params().At(i).Type(), 0) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasReader(t types.Type, depth int) bool {
+	if depth > 3 {
+		return false
+	}
+	if named, ok := t.(*types.Named); ok {
+		if obj := named.Obj(); obj.Pkg() != nil && obj.Pkg().Path() == "io" && obj.Name() == "Reader" {
+			return true
+		}
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		return hasReader(u.Elem(), depth+1)
+	case *types.Struct:
+		for i := range u.NumFields() {
+			if hasReader(u.Field(i).Type(), depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retryMode is when a RetryClient method retries.
+type retryMode int
+
+const (
+	retryListed retryMode = iota // only if listed in RetryPolicy.Operations
+	retryAlways                  // idempotent operations
+	retryNever                   // the request body can only be read once
+)
+
+// writeMethod writes the RetryClient method for op.
+func writeMethod(gen *gopkg.Generated, op gopkg.Operation, method string, mode retryMode) {
+	params := op.Sig.Params()
+	var decl, args []string
+	for i := range params.Len() {
+		p := params.At(i)
+		decl = append(decl, p.Name()+" "+gen.TypeString(p.Type()))
+		args = append(args, p.Name())
+	}
+	call := fmt.Sprintf("c.c.%s(%s)", op.Name, strings.Join(args, ", "))
+
+	results := op.Sig.Results()
+	resultDecl := "error"
+	if results.Len() == 2 {
+		resultDecl = "(" + gen.TypeString(results.At(0).Type()) + ", error)"
+	}
+
+	switch mode {
+	case retryNever:
+		gen.Printf("// %s calls the wrapped %s (%s) without retrying: its request body is a\n// stream that can't be replayed.\n", op.Name, op.Name, method)
+	case retryAlways:
+		gen.Printf("// %s calls the wrapped %s (%s), retrying on failure.\n", op.Name, op.Name, method)
+	case retryListed:
+		gen.Printf("// %s calls the wrapped %s (%s). %s is not idempotent, so it is only\n// retried if the policy lists %sOperation.\n", op.Name, op.Name, method, method, op.Name)
+	}
+	gen.Printf("func (c *RetryClient) %s(%s) %s {\n", op.Name, strings.Join(decl, ", "), resultDecl)
+
+	if mode == retryNever {
+		gen.Printf("\treturn %s\n}\n\n", call)
+		return
+	}
+
+	ctx := params.At(0).Name()
+	idempotent := mode == retryAlways
+	if results.Len() == 2 {
+		gen.Printf("\tvar res %s\n", gen.TypeString(results.At(0).Type()))
+		gen.Printf("\terr := c.retry(%s, %sOperation, %t, func(%s context.Context) (err error) {\n", ctx, op.Name, idempotent, ctx)
+		gen.Printf("\t\tres, err = %s\n\t\treturn err\n\t})\n\treturn res, err\n}\n\n", call)
+	} else {
+		gen.Printf("\treturn c.retry(%s, %sOperation, %t, func(%s context.Context) error {\n", ctx, op.Name, idempotent, ctx)
+		gen.Printf("\t\treturn %s\n\t})\n}\n\n", call)
+	}
+}
+
+// retryClientSource declares the wrapper and its policy.
+const retryClientSource = `// RetryPolicy configures a RetryClient. The zero value makes up to 3 attempts
+// with backoff between 100ms and 10s.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, including the first one.
+	MaxAttempts int
+	// MinBackoff is the base delay before the first retry. It doubles with
+	// every retry, and a random jitter is applied.
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. If a Retry-After header asks
+	// for a longer delay, the error is returned instead.
+	MaxBackoff time.Duration
+	// Operations lists non-idempotent operations that are safe to retry, such
+	// as POSTs deduplicated by an idempotency key.
+	Operations []OperationName
+}
+
+// RetryClient wraps an Invoker, retrying idempotent operations on 429 and 5xx
+// responses and on network errors. A Retry-After header on the response sets
+// the delay before the next attempt.
+//
+// Only errors are retried: responses described in the spec, including 429
+// and 5xx ones, are returned to the caller as is.
+type RetryClient struct {
+	c      Invoker
+	policy RetryPolicy
+	extra  map[OperationName]bool
+}
+
+var _ Invoker = (*RetryClient)(nil)
+
+// NewRetryClient returns a client that calls c, retrying failed calls as
+// configured by policy.
+func NewRetryClient(c Invoker, policy RetryPolicy) *RetryClient {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.MinBackoff <= 0 {
+		policy.MinBackoff = 100 * time.Millisecond
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 10 * time.Second
+	}
+	extra := make(map[OperationName]bool, len(policy.Operations))
+	for _, op := range policy.Operations {
+		extra[op] = true
+	}
+	return &RetryClient{c: c, policy: policy, extra: extra}
+}
+
+// retry calls call until it succeeds, fails with an error that isn't worth
+// retrying, or runs out of attempts.
+func (c *RetryClient) retry(ctx context.Context, op OperationName, idempotent bool, call func(ctx context.Context) error) error {
+	if !idempotent && !c.extra[op] {
+		return call(ctx)
+	}
+	for attempt := 1; ; attempt++ {
+		err := call(ctx)
+		if err == nil || attempt >= c.policy.MaxAttempts {
+			return err
+		}
+		delay, ok := retryDelay(err, attempt, c.policy)
+		if !ok {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+`
+
+// retryHelpersSource classifies errors and parses Retry-After.
+const retryHelpersSource = `// retryDelay reports whether err is worth retrying and how long to wait
+// before the next attempt.
+func retryDelay(err error, attempt int, policy RetryPolicy) (time.Duration, bool) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 0, false
+	}
+
+	var wait time.Duration
+	var status *validate.UnexpectedStatusCodeError
+	var coded interface{ GetStatusCode() int }
+	var netErr *url.Error
+	switch {
+	case errors.As(err, &status):
+		if !retryStatus(status.StatusCode) {
+			return 0, false
+		}
+		if status.Payload != nil {
+			wait = retryAfter(status.Payload.Header.Get("Retry-After"))
+		}
+	case errors.As(err, &coded):
+		if !retryStatus(coded.GetStatusCode()) {
+			return 0, false
+		}
+	case errors.As(err, &netErr):
+	default:
+		return 0, false
+	}
+	if wait > policy.MaxBackoff {
+		return 0, false
+	}
+
+	// Exponential backoff with jitter in [backoff/2, backoff).
+	backoff := policy.MinBackoff << (attempt - 1)
+	if backoff <= 0 || backoff > policy.MaxBackoff {
+		backoff = policy.MaxBackoff
+	}
+	backoff = backoff/2 + rand.N(backoff/2+1)
+	return max(wait, backoff), true
+}
+
+// retryStatus reports whether a response status is worth retrying.
+func retryStatus(code int) bool {
+	return code == http.StatusTooManyRequests ||
+		(code >= 500 && code != http.StatusNotImplemented && code != http.StatusHTTPVersionNotSupported)
+}
+
+// retryAfter parses a Retry-After header, which is either a number of seconds
+// or an HTTP date.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+`
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const clientSource = `package api
+
+import (
+	"context"
+	"io"
+)
+
+type Invoker interface {
+	CreateUser(ctx context.Context, request *User) (*User, error)
+	DeleteUser(ctx context.Context, params DeleteUserParams) error
+	ListUsers(ctx context.Context) ([]User, error)
+	UploadAvatar(ctx context.Context, request UploadAvatarReq) error
+}
+
+type OperationName = string
+
+const (
+	CreateUserOperation   OperationName = "CreateUser"
+	DeleteUserOperation   OperationName = "DeleteUser"
+	ListUsersOperation    OperationName = "ListUsers"
+	UploadAvatarOperation OperationName = "UploadAvatar"
+)
+
+type User struct {
+	Name string
+}
+
+type DeleteUserParams struct {
+	ID string
+}
+
+type UploadAvatarReq struct {
+	Data io.Reader
+}
+
+type Client struct{}
+
+func (c *Client) sendCreateUser(ctx context.Context, request *User) (res *User, err error) {
+	r, err := ht.NewRequest(ctx, "POST", u)
+	return res, nil
+}
+
+func (c *Client) sendDeleteUser(ctx context.Context, params DeleteUserParams) (res struct{}, err error) {
+	r, err := ht.NewRequest(ctx, "DELETE", u)
+	return res, nil
+}
+
+func (c *Client) sendListUsers(ctx context.Context) (res []User, err error) {
+	r, err := ht.NewRequest(ctx, "GET", u)
+	return res, nil
+}
+
+func (c *Client) sendUploadAvatar(ctx context.Context, request UploadAvatarReq) (res struct{}, err error) {
+	r, err := ht.NewRequest(ctx, "PUT", u)
+	return res, nil
+}
+`
+
+func TestHTTPMethods(t *testing.T) {
+	got := HTTPMethods([]byte(clientSource))
+	want := map[string]string{
+		"CreateUser":   "POST",
+		"DeleteUser":   "DELETE",
+		"ListUsers":    "GET",
+		"UploadAvatar": "PUT",
+	}
+	for name, method := range want {
+		if got[name] != method {
+			t.Errorf("HTTPMethods()[%s] = %q, want %q", name, got[name], method)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	// The send methods above don't compile; the tool only reads their text.
+	writeFile(t, filepath.Join(dir, "oas_client_gen.go"), clientSource)
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), strings.Split(clientSource, "type Client struct{}")[0])
+
+	pkg, err := gopkg.Load(dir, "oas_client_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, retried, err := GenerateRetryClient(pkg, pkg.Operations(), HTTPMethods([]byte(clientSource)))
+	if err != nil {
+		t.Fatalf("GenerateRetryClient: %v", err)
+	}
+	if retried != 2 {
+		t.Errorf("retried = %d, want 2", retried)
+	}
+
+	got, err := gen.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+
+	for _, want := range []string{
+		"// Code generated by ogen-genretry, DO NOT EDIT.",
+		"var _ Invoker = (*RetryClient)(nil)",
+		// Idempotent operations are retried.
+		"func (c *RetryClient) ListUsers(ctx context.Context) ([]User, error) {\n\tvar res []User\n\terr := c.retry(ctx, ListUsersOperation, true,",
+		"func (c *RetryClient) DeleteUser(ctx context.Context, params DeleteUserParams) error {\n\treturn c.retry(ctx, DeleteUserOperation, true,",
+		// POST is only retried when listed in the policy.
+		"\terr := c.retry(ctx, CreateUserOperation, false,",
+		// A streamed body can't be replayed, even for PUT.
+		"func (c *RetryClient) UploadAvatar(ctx context.Context, request UploadAvatarReq) error {\n\treturn c.c.UploadAvatar(ctx, request)\n}",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// The generated file must compile against the package.
+	os.Remove(filepath.Join(dir, "oas_client_gen.go"))
+	if err := os.WriteFile(filepath.Join(dir, outputFile), got, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Errorf("generated code does not type-check: %v", err)
+	}
+}
+
+func TestRun_NoClient(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), "package api\n\ntype User struct{ Name string }\n")
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, outputFile)); !os.IsNotExist(err) {
+		t.Errorf("retry client written without a client: %v", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
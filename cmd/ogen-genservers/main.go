@@ -0,0 +1,305 @@
+// Command ogen-genservers generates client constructors for the server URL
+// templates of an OpenAPI spec.
+//
+// ogen ignores the servers of the spec: NewClient takes the base URL as a
+// string, so callers of an API served from
+// https://{region}.api.example.com/{version} build it by hand and find out
+// about a mistyped region at the first request. This tool generates, for
+// every server with variables, a struct of the variables, a function
+// building the URL with defaults and enum checks, and a constructor:
+//
+//	client, err := api.NewClientForServer(api.ServerParams{Region: "us"}, opts...)
+//
+// Usage:
+//
+//	ogen-genservers -spec openapi.json <generated-dir>
+//
+// With a single templated server, the names are ServerParams, ServerURL and
+// NewClientForServer. With several, each gets a prefix from its description
+// (SandboxServerParams) or, without one, its position (Server2Params).
+//
+// The code is written to oas_servers_gen.go in the generated directory.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// outputFile is the file the constructors are written to.
+const outputFile = "oas_servers_gen.go"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-genservers: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-genservers", flag.ContinueOnError)
+	specFile := fs.String("spec", "", "OpenAPI spec with the servers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *specFile == "" {
+		return fmt.Errorf("usage: ogen-genservers -spec openapi.json <generated-dir>")
+	}
+
+	spec, err := specdoc.ReadFileMap(*specFile)
+	if err != nil {
+		return err
+	}
+	servers, err := FindServers(spec)
+	if err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		fmt.Printf("No servers with variables found in %s\n", *specFile)
+		return nil
+	}
+
+	pkg, err := gopkg.Load(fs.Arg(0), outputFile)
+	if err != nil {
+		return err
+	}
+	gen, err := GenerateServers(pkg, servers)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(pkg.Dir, outputFile)
+	if err := gen.WriteFile(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated constructors for %d servers in %s\n", len(servers), path)
+	return nil
+}
+
+// Server is a server of the spec with variables.
+type Server struct {
+	// Name prefixes the generated names: Server, SandboxServer, Server2.
+	Name string
+	URL  string
+	// Parts is the URL split at its variables: literal text at even
+	// indices, variable names at odd ones.
+	Parts     []string
+	Variables []Variable
+}
+
+// Variable is a server variable.
+type Variable struct {
+	Name    string
+	Field   string
+	Default string
+	Enum    []string
+}
+
+// FindServers returns the top-level servers of spec that have variables. It
+// returns an error if a URL uses a variable the server doesn't declare, or if
+// two servers get the same name.
+func FindServers(spec map[string]any) ([]Server, error) {
+	list, _ := spec["servers"].([]any)
+	var servers []Server
+	var descriptions []string
+	var positions []int
+	for i, s := range list {
+		m, _ := s.(map[string]any)
+		rawURL, _ := m["url"].(string)
+		vars, _ := m["variables"].(map[string]any)
+		if len(vars) == 0 {
+			continue
+		}
+
+		server := Server{URL: rawURL, Parts: splitTemplate(rawURL)}
+		for j := 1; j < len(server.Parts); j += 2 {
+			if _, ok := vars[server.Parts[j]]; !ok {
+				return nil, fmt.Errorf("server %s: variable {%s} is not declared", rawURL, server.Parts[j])
+			}
+		}
+		var names []string
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			v, _ := vars[name].(map[string]any)
+			field := goName(name)
+			if field == "" {
+				return nil, fmt.Errorf("server %s: variable %q has no Go name", rawURL, name)
+			}
+			variable := Variable{Name: name, Field: field}
+			variable.Default, _ = v["default"].(string)
+			enum, _ := v["enum"].([]any)
+			for _, e := range enum {
+				if s, ok := e.(string); ok {
+					variable.Enum = append(variable.Enum, s)
+				}
+			}
+			server.Variables = append(server.Variables, variable)
+		}
+
+		description, _ := m["description"].(string)
+		servers = append(servers, server)
+		descriptions = append(descriptions, description)
+		positions = append(positions, i+1)
+	}
+
+	seen := make(map[string]bool)
+	for i := range servers {
+		switch name := goName(descriptions[i]); {
+		case len(servers) == 1:
+			servers[i].Name = "Server"
+		case name != "":
+			servers[i].Name = strings.TrimSuffix(name, "Server") + "Server"
+		default:
+			servers[i].Name = "Server" + strconv.Itoa(positions[i])
+		}
+		if seen[servers[i].Name] {
+			return nil, fmt.Errorf("server %s: another server is also named %s", servers[i].URL, servers[i].Name)
+		}
+		seen[servers[i].Name] = true
+	}
+	return servers, nil
+}
+
+// splitTemplate splits a URL template at its {variables}.
+func splitTemplate(s string) []string {
+	parts := []string{""}
+	for {
+		start := strings.IndexByte(s, '{')
+		end := strings.IndexByte(s[start+1:], '}')
+		if start < 0 || end < 0 {
+			parts[len(parts)-1] += s
+			return parts
+		}
+		parts[len(parts)-1] += s[:start]
+		parts = append(parts, s[start+1:start+1+end], "")
+		s = s[start+end+2:]
+	}
+}
+
+// goName converts a variable name or description to an exported Go name:
+// "api-version" becomes ApiVersion.
+func goName(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		return ""
+	}
+	return name
+}
+
+// GenerateServers generates the params struct, URL function and, if the
+// package has a client, the constructor of each server.
+func GenerateServers(pkg *gopkg.Package, servers []Server) (*gopkg.Generated, error) {
+	scope := pkg.Types.Scope()
+	newClient, _ := scope.Lookup("NewClient").(*types.Func)
+	for _, s := range servers {
+		names := []string{s.Name + "Params", s.Name + "URL"}
+		if newClient != nil {
+			names = append(names, "NewClientFor"+s.Name)
+		}
+		for _, name := range names {
+			if scope.Lookup(name) != nil {
+				return nil, fmt.Errorf("%s is already declared", name)
+			}
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-genservers", pkg.Types)
+	for i, s := range servers {
+		if i > 0 {
+			gen.Printf("\n")
+		}
+		writeServer(gen, s, newClient)
+	}
+	return gen, nil
+}
+
+func writeServer(gen *gopkg.Generated, s Server, newClient *types.Func) {
+	gen.Printf("// %sParams are the variables of the server URL\n// %s.\n", s.Name, s.URL)
+	gen.Printf("type %sParams struct {\n", s.Name)
+	for _, v := range s.Variables {
+		gen.Printf("\t// %s is the {%s} variable", v.Field, v.Name)
+		if len(v.Enum) > 0 {
+			gen.Printf(", one of %s", strings.Join(v.Enum, ", "))
+		}
+		gen.Printf(". Defaults to %q.\n\t%s string\n", v.Default, v.Field)
+	}
+	gen.Printf("}\n\n")
+
+	gen.Printf("// %sURL returns the server URL with the variables of p. Empty variables\n", s.Name)
+	gen.Printf("// take their default.\n")
+	gen.Printf("func %sURL(p %sParams) (string, error) {\n", s.Name, s.Name)
+	for _, v := range s.Variables {
+		gen.Printf("\tif p.%s == \"\" {\n\t\tp.%s = %q\n\t}\n", v.Field, v.Field, v.Default)
+		if len(v.Enum) == 0 {
+			continue
+		}
+		gen.Import("github.com/go-faster/errors", "errors")
+		quoted := make([]string, len(v.Enum))
+		for i, e := range v.Enum {
+			quoted[i] = strconv.Quote(e)
+		}
+		gen.Printf("\tswitch p.%s {\n\tcase %s:\n\tdefault:\n", v.Field, strings.Join(quoted, ", "))
+		gen.Printf("\t\treturn \"\", errors.Errorf(\"server variable %s: invalid value %%q: want one of %s\", p.%s)\n\t}\n",
+			v.Name, strings.Join(v.Enum, ", "), v.Field)
+	}
+	var expr []string
+	for i, part := range s.Parts {
+		switch {
+		case i%2 == 1:
+			expr = append(expr, "p."+goName(part))
+		case part != "":
+			expr = append(expr, strconv.Quote(part))
+		}
+	}
+	gen.Printf("\treturn %s, nil\n}\n", strings.Join(expr, " + "))
+
+	if newClient == nil {
+		return
+	}
+	sig := newClient.Signature()
+	var params, call []string
+	for i := 1; i < sig.Params().Len(); i++ {
+		p := sig.Params().At(i)
+		typ := gen.TypeString(p.Type())
+		arg := p.Name()
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			typ = "..." + gen.TypeString(p.Type().(*types.Slice).Elem())
+			arg += "..."
+		}
+		params = append(params, p.Name()+" "+typ)
+		call = append(call, arg)
+	}
+	gen.Printf("\n// NewClientFor%s initializes a new Client for the server URL with the\n", s.Name)
+	gen.Printf("// variables of p.\n")
+	gen.Printf("func NewClientFor%s(%s) (*Client, error) {\n", s.Name, strings.Join(append([]string{"p " + s.Name + "Params"}, params...), ", "))
+	gen.Printf("\tserverURL, err := %sURL(p)\n\tif err != nil {\n\t\treturn nil, err\n\t}\n", s.Name)
+	gen.Printf("\treturn NewClient(%s)\n}\n", strings.Join(append([]string{"serverURL"}, call...), ", "))
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const clientSource = `package api
+
+type Client struct {
+	serverURL string
+}
+
+type ClientOption func(*Client)
+
+type SecuritySource interface {
+	APIKey() (string, error)
+}
+
+func NewClient(serverURL string, sec SecuritySource, opts ...ClientOption) (*Client, error) {
+	return &Client{serverURL: serverURL}, nil
+}
+`
+
+const specSource = `{
+  "servers": [
+    {
+      "url": "https://{region}.api.example.com/{api-version}",
+      "description": "Production",
+      "variables": {
+        "region": {"default": "eu", "enum": ["eu", "us"]},
+        "api-version": {"default": "v1"}
+      }
+    },
+    {"url": "https://static.example.com"},
+    {
+      "url": "http://localhost:{port}",
+      "variables": {"port": {"default": "8080"}}
+    }
+  ]
+}`
+
+func TestRun(t *testing.T) {
+	dir := writePackage(t, clientSource)
+	spec := filepath.Join(t.TempDir(), "openapi.json")
+	if err := os.WriteFile(spec, []byte(specSource), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-spec", spec, dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	// The generated file must type-check with the package.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Fatalf("load after run: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, outputFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(content)
+	for _, want := range []string{
+		"type ProductionServerParams struct {",
+		"\t// ApiVersion is the {api-version} variable. Defaults to \"v1\".\n\tApiVersion string\n",
+		"\tif p.Region == \"\" {\n\t\tp.Region = \"eu\"\n\t}\n",
+		"\tcase \"eu\", \"us\":\n",
+		`return "https://" + p.Region + ".api.example.com/" + p.ApiVersion, nil`,
+		"func NewClientForProductionServer(p ProductionServerParams, sec SecuritySource, opts ...ClientOption) (*Client, error) {",
+		"\treturn NewClient(serverURL, sec, opts...)\n",
+		"func Server3URL(p Server3Params) (string, error) {",
+		`return "http://localhost:" + p.Port, nil`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "static.example.com") {
+		t.Errorf("output has the server without variables:\n%s", out)
+	}
+
+	// Running again replaces the file instead of colliding with it.
+	if err := run([]string{"-spec", spec, dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+}
+
+func TestFindServers(t *testing.T) {
+	servers, err := FindServers(map[string]any{
+		"servers": []any{
+			map[string]any{
+				"url":       "https://{region}.example.com",
+				"variables": map[string]any{"region": map[string]any{"default": "eu"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 1 || servers[0].Name != "Server" {
+		t.Fatalf("FindServers() = %+v, want one server named Server", servers)
+	}
+	if want := []string{"https://", "region", ".example.com"}; !reflect.DeepEqual(servers[0].Parts, want) {
+		t.Errorf("Parts = %q, want %q", servers[0].Parts, want)
+	}
+}
+
+func TestFindServersErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []any
+		want    string
+	}{
+		{
+			name: "undeclared variable",
+			servers: []any{map[string]any{
+				"url":       "https://{region}.example.com/{version}",
+				"variables": map[string]any{"region": map[string]any{"default": "eu"}},
+			}},
+			want: "server https://{region}.example.com/{version}: variable {version} is not declared",
+		},
+		{
+			name: "same description",
+			servers: []any{
+				map[string]any{
+					"url":         "https://{region}.example.com",
+					"description": "Production",
+					"variables":   map[string]any{"region": map[string]any{"default": "eu"}},
+				},
+				map[string]any{
+					"url":         "https://{region}.example.org",
+					"description": "production server",
+					"variables":   map[string]any{"region": map[string]any{"default": "eu"}},
+				},
+			},
+			want: "server https://{region}.example.org: another server is also named ProductionServer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FindServers(map[string]any{"servers": tt.servers})
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("FindServers() error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateServers_AlreadyDeclared(t *testing.T) {
+	dir := writePackage(t, clientSource+"\ntype ServerParams struct{}\n")
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := []Server{{Name: "Server", URL: "https://example.com", Parts: []string{"https://example.com"}}}
+	_, err = GenerateServers(pkg, servers)
+	if err == nil || err.Error() != "ServerParams is already declared" {
+		t.Errorf("GenerateServers() error = %v, want ServerParams is already declared", err)
+	}
+}
+
+func writePackage(t *testing.T, source string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "oas_client_gen.go"), []byte(source), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
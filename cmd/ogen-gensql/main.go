@@ -0,0 +1,258 @@
+// Command ogen-gensql generates database/sql support for the enum, UUID and
+// date types of an ogen-generated package.
+//
+// Values decoded from an API rarely go into a database as is: enums are named
+// types the drivers don't know, and optional fields are OptX wrappers whose
+// Value field rules out a Value method. This tool generates:
+//
+//	// Enums implement sql.Scanner and driver.Valuer, and only scan their
+//	// values.
+//	db.QueryRow(q).Scan(&order.Status)
+//	db.Exec(q, order.Status)
+//
+//	// Opt, Nil and OptNil wrappers of enums, UUIDs and times implement
+//	// sql.Scanner, mapping NULL to unset or null, and convert to sql.Null.
+//	db.QueryRow(q).Scan(&user.Birthday)
+//	db.Exec(q, user.Birthday.SQL())
+//
+// pgx v5 uses these interfaces as well, so no pgx-specific codecs are needed.
+//
+// Usage:
+//
+//	ogen-gensql <generated-dir>
+//
+// The methods are written to oas_sql_gen.go in the generated directory.
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the methods are written to.
+const outputFile = "oas_sql_gen.go"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-gensql: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-gensql <generated-dir>")
+	}
+
+	pkg, err := gopkg.Load(args[0], outputFile)
+	if err != nil {
+		return err
+	}
+
+	enums := FindEnums(pkg)
+	wrappers := FindWrappers(pkg, enums)
+	if len(enums)+len(wrappers) == 0 {
+		fmt.Printf("No enum, UUID or date types found in %s\n", args[0])
+		return nil
+	}
+
+	gen, err := GenerateSQL(pkg, enums, wrappers)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(pkg.Dir, outputFile)
+	if err := gen.WriteFile(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated database/sql support for %d enums and %d wrappers in %s\n", len(enums), len(wrappers), path)
+	return nil
+}
+
+// FindEnums returns the enum types of pkg, sorted by name: the named basic
+// types with an AllValues method returning their values.
+func FindEnums(pkg *gopkg.Package) []*types.Named {
+	var enums []*types.Named
+	for _, named := range namedTypes(pkg) {
+		if _, ok := named.Underlying().(*types.Basic); !ok {
+			continue
+		}
+		m, _, _ := types.LookupFieldOrMethod(named, false, pkg.Types, "AllValues")
+		fn, ok := m.(*types.Func)
+		if !ok {
+			continue
+		}
+		sig := fn.Signature()
+		if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+			continue
+		}
+		if s, ok := sig.Results().At(0).Type().(*types.Slice); ok && types.Identical(s.Elem(), named) {
+			enums = append(enums, named)
+		}
+	}
+	return enums
+}
+
+// FindWrappers returns the Opt, Nil and OptNil wrappers of pkg whose value is
+// one of enums, a time.Time, or a type that implements sql.Scanner and
+// driver.Valuer itself, such as uuid.UUID. They are sorted by name.
+func FindWrappers(pkg *gopkg.Package, enums []*types.Named) []*types.Named {
+	isEnum := make(map[*types.Named]bool)
+	for _, named := range enums {
+		isEnum[named] = true
+	}
+
+	var wrappers []*types.Named
+	for _, named := range namedTypes(pkg) {
+		kind, value := gopkg.Unwrap(named)
+		if kind == gopkg.NotWrapped {
+			continue
+		}
+		v, ok := value.(*types.Named)
+		if !ok {
+			continue
+		}
+		if isEnum[v] || isTime(v) || hasMethod(v, true, "Scan") && hasMethod(v, false, "Value") {
+			wrappers = append(wrappers, named)
+		}
+	}
+	return wrappers
+}
+
+// namedTypes returns the named types declared by pkg, sorted by name.
+func namedTypes(pkg *gopkg.Package) []*types.Named {
+	var named []*types.Named
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || obj.IsAlias() {
+			continue
+		}
+		if n, ok := obj.Type().(*types.Named); ok {
+			named = append(named, n)
+		}
+	}
+	return named
+}
+
+func isTime(named *types.Named) bool {
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "time" && obj.Name() == "Time"
+}
+
+func hasMethod(named *types.Named, pointer bool, name string) bool {
+	var t types.Type = named
+	if pointer {
+		t = types.NewPointer(named)
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, false, named.Obj().Pkg(), name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// GenerateSQL generates Scan and Value for each enum, and Scan and SQL for
+// each wrapper.
+func GenerateSQL(pkg *gopkg.Package, enums, wrappers []*types.Named) (*gopkg.Generated, error) {
+	for _, named := range enums {
+		for _, method := range []string{"Scan", "Value"} {
+			if m, _, _ := types.LookupFieldOrMethod(named, true, pkg.Types, method); m != nil {
+				return nil, fmt.Errorf("%s.%s is already declared", named.Obj().Name(), method)
+			}
+		}
+	}
+	for _, named := range wrappers {
+		for _, method := range []string{"Scan", "SQL"} {
+			if m, _, _ := types.LookupFieldOrMethod(named, true, pkg.Types, method); m != nil {
+				return nil, fmt.Errorf("%s.%s is already declared", named.Obj().Name(), method)
+			}
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-gensql", pkg.Types)
+	gen.Import("database/sql", "sql")
+	gen.Import("github.com/go-faster/errors", "errors")
+
+	all := append(append([]*types.Named(nil), enums...), wrappers...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Obj().Name() < all[j].Obj().Name() })
+	for i, named := range all {
+		if i > 0 {
+			gen.Printf("\n")
+		}
+		if _, ok := named.Underlying().(*types.Basic); ok {
+			writeEnum(gen, named)
+		} else {
+			writeWrapper(gen, named)
+		}
+	}
+	return gen, nil
+}
+
+// writeEnum writes Value, returning the enum as the driver type of its kind,
+// and Scan, which scans through sql.Null of that type and checks the value.
+func writeEnum(gen *gopkg.Generated, named *types.Named) {
+	gen.Import("database/sql/driver", "driver")
+	name := named.Obj().Name()
+	info := named.Underlying().(*types.Basic).Info()
+	base := "string"
+	switch {
+	case info&types.IsBoolean != 0:
+		base = "bool"
+	case info&types.IsInteger != 0:
+		base = "int64"
+	case info&types.IsFloat != 0:
+		base = "float64"
+	}
+
+	gen.Printf("// Value implements driver.Valuer.\n")
+	gen.Printf("func (s %s) Value() (driver.Value, error) {\n\treturn %s(s), nil\n}\n\n", name, base)
+
+	gen.Printf("// Scan implements sql.Scanner. It fails on NULL and on values that are not\n")
+	gen.Printf("// one of the %s values.\n", name)
+	gen.Printf("func (s *%s) Scan(src any) error {\n", name)
+	gen.Printf("\tvar n sql.Null[%s]\n", base)
+	gen.Printf("\tif err := n.Scan(src); err != nil {\n\t\treturn errors.Wrap(err, \"scan %s\")\n\t}\n", name)
+	gen.Printf("\tif !n.Valid {\n\t\treturn errors.New(\"scan %s: unexpected NULL\")\n\t}\n", name)
+	gen.Printf("\tv := %s(n.V)\n", name)
+	gen.Printf("\tfor _, allowed := range v.AllValues() {\n\t\tif v == allowed {\n\t\t\t*s = v\n\t\t\treturn nil\n\t\t}\n\t}\n")
+	gen.Printf("\treturn errors.Errorf(\"scan %s: invalid value %%v\", v)\n}\n", name)
+}
+
+// writeWrapper writes Scan, mapping NULL to unset or null, and SQL, returning
+// the wrapper as a sql.Null.
+func writeWrapper(gen *gopkg.Generated, named *types.Named) {
+	name := named.Obj().Name()
+	kind, value := gopkg.Unwrap(named)
+	valueType := gen.TypeString(value)
+
+	null, valid := "", ""
+	switch kind {
+	case gopkg.Opt:
+		null, valid = "unsets o", "o.Set"
+	case gopkg.Nil:
+		null, valid = "sets o to null", "!o.Null"
+	case gopkg.OptNil:
+		null, valid = "sets o to null", "o.Set && !o.Null"
+	}
+
+	gen.Printf("// Scan implements sql.Scanner. NULL %s.\n", null)
+	gen.Printf("func (o *%s) Scan(src any) error {\n", name)
+	gen.Printf("\tvar n sql.Null[%s]\n", valueType)
+	gen.Printf("\tif err := n.Scan(src); err != nil {\n\t\treturn errors.Wrap(err, \"scan %s\")\n\t}\n", name)
+	if kind == gopkg.Opt {
+		gen.Printf("\tif !n.Valid {\n\t\t*o = %s{}\n\t\treturn nil\n\t}\n", name)
+	} else {
+		gen.Printf("\tif !n.Valid {\n\t\to.SetToNull()\n\t\treturn nil\n\t}\n")
+	}
+	gen.Printf("\to.SetTo(n.V)\n\treturn nil\n}\n\n")
+
+	gen.Printf("// SQL returns o as a sql.Null, which implements driver.Valuer: NULL unless\n")
+	gen.Printf("// o holds a value.\n")
+	gen.Printf("func (o %s) SQL() sql.Null[%s] {\n", name, valueType)
+	gen.Printf("\treturn sql.Null[%s]{V: o.Value, Valid: %s}\n}\n", valueType, valid)
+}
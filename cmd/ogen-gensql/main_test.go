@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const schemasSource = `package api
+
+import "time"
+
+type Status string
+
+const (
+	StatusActive   Status = "active"
+	StatusInactive Status = "inactive"
+)
+
+func (Status) AllValues() []Status {
+	return []Status{StatusActive, StatusInactive}
+}
+
+type Priority int
+
+const (
+	Priority1 Priority = 1
+	Priority2 Priority = 2
+)
+
+func (Priority) AllValues() []Priority {
+	return []Priority{Priority1, Priority2}
+}
+
+type OptStatus struct {
+	Value Status
+	Set   bool
+}
+
+func (o *OptStatus) SetTo(v Status) {
+	o.Set = true
+	o.Value = v
+}
+
+type NilPriority struct {
+	Value Priority
+	Null  bool
+}
+
+func (o *NilPriority) SetTo(v Priority) {
+	o.Null = false
+	o.Value = v
+}
+
+func (o *NilPriority) SetToNull() {
+	o.Null = true
+	var v Priority
+	o.Value = v
+}
+
+type OptNilDateTime struct {
+	Value time.Time
+	Set   bool
+	Null  bool
+}
+
+func (o *OptNilDateTime) SetTo(v time.Time) {
+	o.Set = true
+	o.Null = false
+	o.Value = v
+}
+
+func (o *OptNilDateTime) SetToNull() {
+	o.Set = true
+	o.Null = true
+	var v time.Time
+	o.Value = v
+}
+
+// Not supported: a wrapper of a plain string.
+type OptString struct {
+	Value string
+	Set   bool
+}
+`
+
+func TestRun(t *testing.T) {
+	dir := writePackage(t, schemasSource)
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	// The generated file must type-check with the package.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Fatalf("load after run: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, outputFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(content)
+	for _, want := range []string{
+		"func (s Status) Value() (driver.Value, error) {\n\treturn string(s), nil\n}",
+		"func (s *Status) Scan(src any) error {\n\tvar n sql.Null[string]\n",
+		"func (s Priority) Value() (driver.Value, error) {\n\treturn int64(s), nil\n}",
+		"func (s *Priority) Scan(src any) error {\n\tvar n sql.Null[int64]\n",
+		"\tif !n.Valid {\n\t\t*o = OptStatus{}\n\t\treturn nil\n\t}\n",
+		"func (o OptStatus) SQL() sql.Null[Status] {\n\treturn sql.Null[Status]{V: o.Value, Valid: o.Set}\n}",
+		"func (o NilPriority) SQL() sql.Null[Priority] {\n\treturn sql.Null[Priority]{V: o.Value, Valid: !o.Null}\n}",
+		"func (o *OptNilDateTime) Scan(src any) error {\n\tvar n sql.Null[time.Time]\n",
+		"\tif !n.Valid {\n\t\to.SetToNull()\n\t\treturn nil\n\t}\n",
+		"Valid: o.Set && !o.Null}",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "OptString") {
+		t.Errorf("output has methods for OptString:\n%s", out)
+	}
+
+	// Running again replaces the file instead of colliding with it.
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+}
+
+func TestGenerateSQL_AlreadyDeclared(t *testing.T) {
+	dir := writePackage(t, schemasSource+"\nfunc (o *OptStatus) Scan(src any) error { return nil }\n")
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enums := FindEnums(pkg)
+	_, err = GenerateSQL(pkg, enums, FindWrappers(pkg, enums))
+	if err == nil || err.Error() != "OptStatus.Scan is already declared" {
+		t.Errorf("GenerateSQL() error = %v, want OptStatus.Scan is already declared", err)
+	}
+}
+
+func writePackage(t *testing.T, source string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "oas_schemas_gen.go"), []byte(source), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,689 @@
+// Command ogen-genvalidatehooks calls user-registered validation functions
+// from the Validate methods of an ogen-generated package.
+//
+// Business rules such as IBAN checksums or "ends after starts" cannot be
+// written as JSON Schema constraints. This tool reads the x-validate vendor
+// extension of component schemas and their properties:
+//
+//	Payment:
+//	  type: object
+//	  x-validate: checkPayment
+//	  properties:
+//	    iban:
+//	      type: string
+//	      x-validate: iban
+//
+// and generates a ValidateHooks struct with one typed function per name,
+// called by Validate once the checks generated by ogen pass:
+//
+//	api.SetValidateHooks(api.ValidateHooks{
+//		CheckPayment: func(p api.Payment) error { ... },
+//		Iban:         func(s string) error { ... },
+//	})
+//
+// ogen only generates Validate for types with constraints, so the tool adds
+// Validate to the other types with hooks, and calls it from their parents and
+// from the request and response decoders.
+//
+// Usage:
+//
+//	ogen-genvalidatehooks -spec openapi.json <generated-dir>
+//
+// The hooks are written to oas_validate_hooks_gen.go. The Validate methods in
+// oas_validators_gen.go and the decoders in oas_request_decoders_gen.go and
+// oas_response_decoders_gen.go are rewritten in place.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// outputFile is the file the hooks are written to.
+const outputFile = "oas_validate_hooks_gen.go"
+
+const (
+	validatorsFile       = "oas_validators_gen.go"
+	requestDecodersFile  = "oas_request_decoders_gen.go"
+	responseDecodersFile = "oas_response_decoders_gen.go"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-genvalidatehooks: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-genvalidatehooks", flag.ContinueOnError)
+	specFile := fs.String("spec", "", "OpenAPI spec with the x-validate extensions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *specFile == "" {
+		return fmt.Errorf("usage: ogen-genvalidatehooks -spec openapi.json <generated-dir>")
+	}
+
+	spec, err := specdoc.ReadFileMap(*specFile)
+	if err != nil {
+		return err
+	}
+	hooks := FindHooks(spec)
+
+	dir := fs.Arg(0)
+	if len(hooks) == 0 {
+		fmt.Printf("No x-validate extensions found in %s\n", *specFile)
+		return nil
+	}
+
+	// The output file stays in: Validate methods and decoders edited by an
+	// earlier run call the methods it declares.
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	plan, err := Plan(pkg, hooks)
+	if err != nil {
+		return err
+	}
+
+	sources := make(map[string][]byte)
+	for _, name := range []string{validatorsFile, requestDecodersFile, responseDecodersFile} {
+		content, err := os.ReadFile(filepath.Join(pkg.Dir, name)) // #nosec G703 -- CLI tool, filename from trusted args
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		sources[name] = content
+	}
+
+	if src, ok := sources[validatorsFile]; ok {
+		if sources[validatorsFile], err = plan.CallHooks(src); err != nil {
+			return err
+		}
+	}
+	decoders := 0
+	for _, name := range []string{requestDecodersFile, responseDecodersFile} {
+		if src, ok := sources[name]; ok {
+			var n int
+			sources[name], n = plan.ValidateBodies(pkg, src)
+			decoders += n
+		}
+	}
+
+	for name, src := range sources {
+		src, err := format.Source(src)
+		if err != nil {
+			return fmt.Errorf("format %s: %w", name, err)
+		}
+		// #nosec G703 -- CLI tool, filename from trusted args
+		if err := os.WriteFile(filepath.Join(pkg.Dir, name), src, 0600); err != nil {
+			return fmt.Errorf("write file: %w", err)
+		}
+	}
+	if err := plan.Generate(pkg).WriteFile(filepath.Join(pkg.Dir, outputFile)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated %d validate hooks for %d types and %d decoders in %s\n",
+		len(plan.Funcs), len(plan.Types), decoders, dir)
+	return nil
+}
+
+// Hook is an x-validate extension in the spec.
+type Hook struct {
+	// Schema is the component schema name, e.g. Payment.
+	Schema string
+	// Property is the JSON name of the property, or empty for a hook on the
+	// whole schema.
+	Property string
+	// Func is the value of x-validate, e.g. iban.
+	Func string
+}
+
+// FindHooks returns the x-validate extensions of the component schemas and
+// their properties, sorted by schema and property.
+func FindHooks(spec map[string]any) []Hook {
+	components, _ := spec["components"].(map[string]any)
+	schemas, _ := components["schemas"].(map[string]any)
+
+	var hooks []Hook
+	for name, schema := range schemas {
+		schema, _ := schema.(map[string]any)
+		if fn, ok := schema["x-validate"].(string); ok && fn != "" {
+			hooks = append(hooks, Hook{Schema: name, Func: fn})
+		}
+		props, _ := schema["properties"].(map[string]any)
+		for prop, s := range props {
+			s, _ := s.(map[string]any)
+			if fn, ok := s["x-validate"].(string); ok && fn != "" {
+				hooks = append(hooks, Hook{Schema: name, Property: prop, Func: fn})
+			}
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		if hooks[i].Schema != hooks[j].Schema {
+			return hooks[i].Schema < hooks[j].Schema
+		}
+		return hooks[i].Property < hooks[j].Property
+	})
+	return hooks
+}
+
+// HookFunc is a field of the generated ValidateHooks struct.
+type HookFunc struct {
+	// Name is the Go field name, e.g. Iban.
+	Name string
+	// Func is the x-validate value, e.g. iban.
+	Func string
+	// Arg is the type the hook validates.
+	Arg types.Type
+	// Uses lists what the hook validates, e.g. Account.iban.
+	Uses []string
+}
+
+// TypeHooks are the hooks Validate of a generated type calls.
+type TypeHooks struct {
+	Named *types.Named
+	// Schema is the hook on the whole type, or nil.
+	Schema *HookFunc
+	// Fields maps struct field indexes to their hooks.
+	Fields map[int]*HookFunc
+	// HasValidate reports whether ogen generated Validate for the type.
+	HasValidate bool
+	// Pointer reports whether Validate has a pointer receiver.
+	Pointer bool
+}
+
+// HookPlan is what the tool generates for a package.
+type HookPlan struct {
+	// Funcs are the hook functions, sorted by name.
+	Funcs []*HookFunc
+	// Types are the types whose Validate runs hooks, directly or in a
+	// nested value, sorted by name.
+	Types []*TypeHooks
+
+	byName map[*types.TypeName]*TypeHooks
+}
+
+// Plan resolves hooks against the generated types, and finds the types that
+// reach a hook through their fields or elements.
+func Plan(pkg *gopkg.Package, hooks []Hook) (*HookPlan, error) {
+	refs := schemaTypes(pkg)
+	scope := pkg.Types.Scope()
+	outputPath := filepath.Join(pkg.Dir, outputFile)
+
+	plan := &HookPlan{byName: make(map[*types.TypeName]*TypeHooks)}
+	funcs := make(map[string]*HookFunc)
+	typeHooks := func(named *types.Named) *TypeHooks {
+		th, ok := plan.byName[named.Obj()]
+		if !ok {
+			th = &TypeHooks{Named: named, Fields: make(map[int]*HookFunc)}
+			plan.byName[named.Obj()] = th
+		}
+		return th
+	}
+	addFunc := func(fn string, arg types.Type, use string) (*HookFunc, error) {
+		name := hookFuncName(fn)
+		if name == "" {
+			return nil, fmt.Errorf("x-validate %q is not a valid function name", fn)
+		}
+		hf, ok := funcs[name]
+		if !ok {
+			hf = &HookFunc{Name: name, Func: fn, Arg: arg}
+			funcs[name] = hf
+		}
+		if hf.Func != fn {
+			return nil, fmt.Errorf("x-validate %q and %q both map to %s", hf.Func, fn, name)
+		}
+		if !types.Identical(hf.Arg, arg) {
+			return nil, fmt.Errorf("x-validate %q is used for %s and %s (%s)", fn, strings.Join(hf.Uses, ", "), use, arg)
+		}
+		hf.Uses = append(hf.Uses, use)
+		return hf, nil
+	}
+
+	for _, h := range hooks {
+		named, ok := refs[h.Schema]
+		if !ok {
+			return nil, fmt.Errorf("schema %s: no generated type", h.Schema)
+		}
+		name := named.Obj().Name()
+		if h.Property == "" {
+			hf, err := addFunc(h.Func, named, name)
+			if err != nil {
+				return nil, err
+			}
+			typeHooks(named).Schema = hf
+			continue
+		}
+
+		st, ok := named.Underlying().(*types.Struct)
+		if !ok || isSumType(named) {
+			return nil, fmt.Errorf("schema %s: %s is not a struct", h.Schema, name)
+		}
+		index := -1
+		for i := range st.NumFields() {
+			jsonName, _, _ := strings.Cut(reflect.StructTag(st.Tag(i)).Get("json"), ",")
+			if jsonName == h.Property {
+				index = i
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("schema %s: no field for property %s", h.Schema, h.Property)
+		}
+		_, arg := gopkg.Unwrap(st.Field(index).Type())
+		hf, err := addFunc(h.Func, arg, name+"."+h.Property)
+		if err != nil {
+			return nil, err
+		}
+		typeHooks(named).Fields[index] = hf
+	}
+
+	// Types that reach a hook through their fields or elements need Validate
+	// too, until no more are found.
+	var all []*types.Named
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || obj.IsAlias() || pkg.Fset.Position(obj.Pos()).Filename == outputPath {
+			continue
+		}
+		if named, ok := obj.Type().(*types.Named); ok && !isSumType(named) {
+			if w, _ := gopkg.Unwrap(named); w == gopkg.NotWrapped {
+				all = append(all, named)
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, named := range all {
+			if _, ok := plan.byName[named.Obj()]; !ok && plan.reaches(named.Underlying()) {
+				typeHooks(named)
+				changed = true
+			}
+		}
+	}
+
+	for _, th := range plan.byName {
+		fn, ok := methodOf(th.Named, "Validate")
+		if ok && pkg.Fset.Position(fn.Pos()).Filename != outputPath {
+			th.HasValidate = true
+			_, th.Pointer = fn.Signature().Recv().Type().(*types.Pointer)
+		} else {
+			_, th.Pointer = th.Named.Underlying().(*types.Struct)
+		}
+		if fn, ok := methodOf(th.Named, "validateHooks"); ok && pkg.Fset.Position(fn.Pos()).Filename != outputPath {
+			return nil, fmt.Errorf("%s.validateHooks is already declared", th.Named.Obj().Name())
+		}
+		plan.Types = append(plan.Types, th)
+	}
+	sort.Slice(plan.Types, func(i, j int) bool {
+		return plan.Types[i].Named.Obj().Name() < plan.Types[j].Named.Obj().Name()
+	})
+	for _, hf := range funcs {
+		plan.Funcs = append(plan.Funcs, hf)
+	}
+	sort.Slice(plan.Funcs, func(i, j int) bool { return plan.Funcs[i].Name < plan.Funcs[j].Name })
+
+	for _, decl := range []string{"ValidateHooks", "SetValidateHooks", "currentValidateHooks", "callValidateHook"} {
+		obj := scope.Lookup(decl)
+		if obj != nil && pkg.Fset.Position(obj.Pos()).Filename != outputPath {
+			return nil, fmt.Errorf("%s is already declared", decl)
+		}
+	}
+	return plan, nil
+}
+
+// schemaTypes maps component schema names to the types ogen generated for
+// them, from the "Ref: #/components/schemas/..." comments.
+func schemaTypes(pkg *gopkg.Package) map[string]*types.Named {
+	const prefix = "Ref: #/components/schemas/"
+	refs := make(map[string]*types.Named)
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE || gen.Doc == nil || len(gen.Specs) != 1 {
+				continue
+			}
+			for _, line := range strings.Split(gen.Doc.Text(), "\n") {
+				ref, ok := strings.CutPrefix(line, prefix)
+				if !ok {
+					continue
+				}
+				ts := gen.Specs[0].(*ast.TypeSpec)
+				if named, ok := pkg.Info.Defs[ts.Name].Type().(*types.Named); ok {
+					refs[strings.NewReplacer("~1", "/", "~0", "~").Replace(ref)] = named
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// isSumType reports whether named is a oneOf/anyOf sum type, whose fields
+// hold every variant but only the one selected by Type is set.
+func isSumType(named *types.Named) bool {
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok || st.NumFields() == 0 || st.Field(0).Name() != "Type" {
+		return false
+	}
+	d, ok := st.Field(0).Type().(*types.Named)
+	return ok && d.Obj().Name() == named.Obj().Name()+"Type"
+}
+
+func methodOf(named *types.Named, name string) (*types.Func, bool) {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(named), false, named.Obj().Pkg(), name)
+	fn, ok := obj.(*types.Func)
+	return fn, ok
+}
+
+// hookFuncName returns the ValidateHooks field for an x-validate value:
+// checkPayment becomes CheckPayment, and iban-checksum IbanChecksum.
+func hookFuncName(fn string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range fn {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		return ""
+	}
+	return name
+}
+
+// reaches reports whether a value of type t holds a value whose Validate
+// runs hooks and that ogen does not validate itself.
+func (p *HookPlan) reaches(t types.Type) bool {
+	if named, ok := t.(*types.Named); ok {
+		if _, ok := p.byName[named.Obj()]; ok {
+			return true
+		}
+		if w, value := gopkg.Unwrap(named); w != gopkg.NotWrapped {
+			return p.reaches(value)
+		}
+		return false
+	}
+	switch t := t.(type) {
+	case *types.Pointer:
+		return p.reaches(t.Elem())
+	case *types.Slice:
+		return p.reaches(t.Elem())
+	case *types.Map:
+		return p.reaches(t.Elem())
+	case *types.Struct:
+		for i := range t.NumFields() {
+			if p.reaches(t.Field(i).Type()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validates reports whether code must call Validate on a value of type t:
+// it holds a type with hooks that ogen did not generate Validate for, and so
+// never calls.
+func (p *HookPlan) validates(t types.Type) bool {
+	if named, ok := t.(*types.Named); ok {
+		if th, ok := p.byName[named.Obj()]; ok {
+			return !th.HasValidate
+		}
+		if w, value := gopkg.Unwrap(named); w != gopkg.NotWrapped {
+			return p.validates(value)
+		}
+		return false
+	}
+	switch t := t.(type) {
+	case *types.Pointer:
+		return p.validates(t.Elem())
+	case *types.Slice:
+		return p.validates(t.Elem())
+	case *types.Map:
+		return p.validates(t.Elem())
+	}
+	return false
+}
+
+// writeValidate writes the statements validating expr of type t, which
+// return the first error. depth names the variables of nested values.
+func (p *HookPlan) writeValidate(b *strings.Builder, t types.Type, expr string, depth int) {
+	if !p.validates(t) {
+		return
+	}
+	suffix := ""
+	if depth > 0 {
+		suffix = fmt.Sprint(depth)
+	}
+	if named, ok := t.(*types.Named); ok {
+		if _, ok := p.byName[named.Obj()]; ok {
+			fmt.Fprintf(b, "if err := %s.Validate(); err != nil {\nreturn err\n}\n", expr)
+			return
+		}
+		_, value := gopkg.Unwrap(named)
+		v := "v" + suffix
+		fmt.Fprintf(b, "if %s, ok := %s.Get(); ok {\n", v, expr)
+		p.writeValidate(b, value, v, depth+1)
+		b.WriteString("}\n")
+		return
+	}
+	switch t := t.(type) {
+	case *types.Pointer:
+		fmt.Fprintf(b, "if %s != nil {\n", expr)
+		p.writeValidate(b, t.Elem(), expr, depth)
+		b.WriteString("}\n")
+	case *types.Slice:
+		i, elem := "i"+suffix, "elem"+suffix
+		fmt.Fprintf(b, "for %s, %s := range %s {\nif err := func() error {\n", i, elem, expr)
+		p.writeValidate(b, t.Elem(), elem, depth+1)
+		fmt.Fprintf(b, "return nil\n}(); err != nil {\nreturn errors.Wrapf(err, \"[%%d]\", %s)\n}\n}\n", i)
+	case *types.Map:
+		k, elem := "key"+suffix, "elem"+suffix
+		fmt.Fprintf(b, "for %s, %s := range %s {\nif err := func() error {\n", k, elem, expr)
+		p.writeValidate(b, t.Elem(), elem, depth+1)
+		fmt.Fprintf(b, "return nil\n}(); err != nil {\nreturn errors.Wrapf(err, \"[%%q]\", %s)\n}\n}\n", k)
+	}
+}
+
+// callHook returns the statement calling hf on expr.
+func callHook(hf *HookFunc, expr string) string {
+	return fmt.Sprintf("if err := callValidateHook(%q, currentValidateHooks.Load().%s, %s); err != nil {\nreturn err\n}\n",
+		hf.Func, hf.Name, expr)
+}
+
+// Generate writes ValidateHooks, SetValidateHooks and the validateHooks
+// methods, and Validate for the types ogen generated none for.
+func (p *HookPlan) Generate(pkg *gopkg.Package) *gopkg.Generated {
+	gen := gopkg.NewGenerated("ogen-genvalidatehooks", pkg.Types)
+	gen.Import("sync/atomic", "atomic")
+	gen.Import("github.com/go-faster/errors", "errors")
+
+	gen.Printf("// ValidateHooks are the functions named by x-validate in the spec. Validate\n")
+	gen.Printf("// calls them once the checks generated by ogen pass; a hook that is not set\n")
+	gen.Printf("// fails validation.\ntype ValidateHooks struct {\n")
+	for _, hf := range p.Funcs {
+		gen.Printf("\t// %s (%s) validates %s.\n", hf.Name, hf.Func, strings.Join(hf.Uses, ", "))
+		gen.Printf("\t%s func(%s) error\n", hf.Name, gen.TypeString(hf.Arg))
+	}
+	gen.Printf("}\n\n")
+
+	gen.Printf(`var currentValidateHooks atomic.Pointer[ValidateHooks]
+
+func init() {
+	currentValidateHooks.Store(&ValidateHooks{})
+}
+
+// SetValidateHooks sets the hooks Validate calls. Set them before the client
+// or server is used.
+func SetValidateHooks(h ValidateHooks) {
+	currentValidateHooks.Store(&h)
+}
+
+func callValidateHook[T any](name string, hook func(T) error, v T) error {
+	if hook == nil {
+		return errors.Errorf("x-validate hook %%q is not set", name)
+	}
+	return hook(v)
+}
+`)
+
+	for _, th := range p.Types {
+		name := th.Named.Obj().Name()
+		recv := name
+		if th.Pointer {
+			recv = "*" + name
+		}
+
+		var b strings.Builder
+		if st, ok := th.Named.Underlying().(*types.Struct); ok {
+			gen.Import("github.com/ogen-go/ogen/validate", "validate")
+			b.WriteString("var failures []validate.FieldError\n")
+			for i := range st.NumFields() {
+				field := st.Field(i)
+				var fb strings.Builder
+				p.writeValidate(&fb, field.Type(), "s."+field.Name(), 0)
+				if hf, ok := th.Fields[i]; ok {
+					if w, _ := gopkg.Unwrap(field.Type()); w == gopkg.NotWrapped {
+						fb.WriteString(callHook(hf, "s."+field.Name()))
+					} else {
+						fmt.Fprintf(&fb, "if v, ok := s.%s.Get(); ok {\n%s}\n", field.Name(), callHook(hf, "v"))
+					}
+				}
+				if fb.Len() == 0 {
+					continue
+				}
+				jsonName, _, _ := strings.Cut(reflect.StructTag(st.Tag(i)).Get("json"), ",")
+				fmt.Fprintf(&b, "if err := func() error {\n%sreturn nil\n}(); err != nil {\n", fb.String())
+				fmt.Fprintf(&b, "failures = append(failures, validate.FieldError{\nName: %q,\nError: err,\n})\n}\n", jsonName)
+			}
+			b.WriteString("if len(failures) > 0 {\nreturn &validate.Error{Fields: failures}\n}\n")
+		} else {
+			p.writeValidate(&b, th.Named.Underlying(), "s", 0)
+		}
+		if th.Schema != nil {
+			arg := "s"
+			if th.Pointer {
+				arg = "*s"
+			}
+			b.WriteString(callHook(th.Schema, arg))
+		}
+
+		if !th.HasValidate {
+			gen.Printf("\n// Validate runs the x-validate hooks of %s.\n", name)
+			gen.Printf("func (s %s) Validate() error {\n", recv)
+			if th.Pointer {
+				gen.Import("github.com/ogen-go/ogen/validate", "validate")
+				gen.Printf("if s == nil {\nreturn validate.ErrNilPointer\n}\n")
+			}
+			gen.Printf("return s.validateHooks()\n}\n")
+		}
+		gen.Printf("\nfunc (s %s) validateHooks() error {\n%sreturn nil\n}\n", recv, b.String())
+	}
+	return gen
+}
+
+// hookedReturn is the statement ending a Validate method that runs hooks.
+const hookedReturn = "\treturn s.validateHooks()\n}\n"
+
+// CallHooks makes the Validate methods ogen generated for types with hooks
+// end by calling validateHooks. Methods changed by an earlier run are reset
+// first.
+func (p *HookPlan) CallHooks(validators []byte) ([]byte, error) {
+	validators = []byte(strings.ReplaceAll(string(validators), hookedReturn, "\treturn nil\n}\n"))
+	for _, th := range p.Types {
+		if !th.HasValidate {
+			continue
+		}
+		name := th.Named.Obj().Name()
+		pattern := regexp.MustCompile(`(?ms)^func \(s \*?` + name + `\) Validate\(\) error \{\n.*?^\}\n`)
+		loc := pattern.FindIndex(validators)
+		if loc == nil {
+			return nil, fmt.Errorf("%s.Validate not found in %s", name, validatorsFile)
+		}
+		method := validators[loc[0]:loc[1]]
+		if !strings.HasSuffix(string(method), "\n\treturn nil\n}\n") {
+			return nil, fmt.Errorf("%s.Validate does not end with return nil", name)
+		}
+		fixed := string(method[:len(method)-len("\treturn nil\n}\n")]) + hookedReturn
+		validators = append(validators[:loc[0]:loc[0]], append([]byte(fixed), validators[loc[1]:]...)...)
+	}
+	return validators, nil
+}
+
+var (
+	// bodyVarPattern matches the variable a decoder decodes the body into.
+	bodyVarPattern = regexp.MustCompile(`(?m)^\t+var (response|request) (\S+)\n`)
+	// decodeErrorPattern matches the end of the error handling after the body
+	// is decoded.
+	decodeErrorPattern = regexp.MustCompile(`\n\t+Err: +err,\n\t+\}\n\t+return ([^\n]*)err\n\t+\}\n`)
+	// hookBlockPattern matches a block inserted by an earlier run.
+	hookBlockPattern = regexp.MustCompile(
+		`(?s)\t+// Run x-validate hooks\.\n.*?\}\(\); err != nil \{\n\t+return [^\n]*errors\.Wrap\(err, "validate"\)\n\t+\}\n`)
+)
+
+// ValidateBodies makes the request and response decoders validate bodies
+// holding types ogen did not generate Validate for, right after decoding.
+// Blocks inserted by an earlier run are removed first. It returns the number
+// of bodies validated.
+func (p *HookPlan) ValidateBodies(pkg *gopkg.Package, decoders []byte) ([]byte, int) {
+	decoders = hookBlockPattern.ReplaceAll(decoders, nil)
+
+	var out []byte
+	count := 0
+	last := 0
+	for _, m := range bodyVarPattern.FindAllSubmatchIndex(decoders, -1) {
+		if m[0] < last {
+			continue
+		}
+		name, typeExpr := string(decoders[m[2]:m[3]]), string(decoders[m[4]:m[5]])
+		tv, err := types.Eval(pkg.Fset, pkg.Types, token.NoPos, typeExpr)
+		if err != nil || !tv.IsType() || !p.validates(tv.Type) {
+			continue
+		}
+		e := decodeErrorPattern.FindSubmatchIndex(decoders[m[1]:])
+		if e == nil {
+			continue
+		}
+		end := m[1] + e[1]
+		ret := string(decoders[m[1]+e[2] : m[1]+e[3]])
+
+		var b strings.Builder
+		b.WriteString("// Run x-validate hooks.\nif err := func() error {\n")
+		p.writeValidate(&b, tv.Type, name, 0)
+		fmt.Fprintf(&b, "return nil\n}(); err != nil {\nreturn %serrors.Wrap(err, \"validate\")\n}\n", ret)
+
+		out = append(out, decoders[last:end]...)
+		out = append(out, b.String()...)
+		last = end
+		count++
+	}
+	out = append(out, decoders[last:]...)
+	return out, count
+}
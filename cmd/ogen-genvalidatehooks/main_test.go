@@ -0,0 +1,271 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const schemasSource = `package api
+
+// Ref: #/components/schemas/Account
+type Account struct {
+	Iban string    ` + "`json:\"iban\"`" + `
+	Name OptString ` + "`json:\"name\"`" + `
+}
+
+// Ref: #/components/schemas/Payment
+type Payment struct {
+	Amount int       ` + "`json:\"amount\"`" + `
+	From   Account   ` + "`json:\"from\"`" + `
+	To     OptString ` + "`json:\"to\"`" + `
+}
+
+type ListAccountsOK []Account
+
+type OptString struct {
+	Value string
+	Set   bool
+}
+
+func (o OptString) Get() (v string, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+`
+
+const validatorsSource = `package api
+
+import (
+	"github.com/ogen-go/ogen/validate"
+)
+
+func (s *Payment) Validate() error {
+	if s == nil {
+		return validate.ErrNilPointer
+	}
+
+	var failures []validate.FieldError
+	if s.Amount < 1 {
+		failures = append(failures, validate.FieldError{Name: "amount", Error: validate.ErrFieldRequired})
+	}
+	if len(failures) > 0 {
+		return &validate.Error{Fields: failures}
+	}
+	return nil
+}
+`
+
+const specSource = `{
+	"components": {
+		"schemas": {
+			"Account": {
+				"type": "object",
+				"properties": {
+					"iban": {"type": "string", "x-validate": "iban"},
+					"name": {"type": "string"}
+				}
+			},
+			"Payment": {
+				"type": "object",
+				"x-validate": "checkPayment",
+				"properties": {
+					"amount": {"type": "integer", "minimum": 1},
+					"from": {"$ref": "#/components/schemas/Account"},
+					"to": {"type": "string", "x-validate": "iban"}
+				}
+			}
+		}
+	}
+}`
+
+func TestFindHooks(t *testing.T) {
+	spec := map[string]any{
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"Payment": map[string]any{
+					"x-validate": "checkPayment",
+					"properties": map[string]any{
+						"to":     map[string]any{"x-validate": "iban"},
+						"amount": map[string]any{"type": "integer"},
+					},
+				},
+				"Account": map[string]any{
+					"properties": map[string]any{"iban": map[string]any{"x-validate": "iban"}},
+				},
+			},
+		},
+	}
+	want := []Hook{
+		{Schema: "Account", Property: "iban", Func: "iban"},
+		{Schema: "Payment", Func: "checkPayment"},
+		{Schema: "Payment", Property: "to", Func: "iban"},
+	}
+	if got := FindHooks(spec); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindHooks() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHookFuncName(t *testing.T) {
+	for fn, want := range map[string]string{
+		"iban":           "Iban",
+		"checkPayment":   "CheckPayment",
+		"iban-checksum":  "IbanChecksum",
+		"rules.v2_check": "RulesV2Check",
+		"2fa":            "",
+		"-":              "",
+	} {
+		if got := hookFuncName(fn); got != want {
+			t.Errorf("hookFuncName(%q) = %q, want %q", fn, got, want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "oas_schemas_gen.go", schemasSource)
+	writeFile(t, dir, validatorsFile, validatorsSource)
+	spec := writeFile(t, dir, "openapi.json", specSource)
+
+	if err := run([]string{"-spec", spec, dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	// The rewritten package must still type-check.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Fatalf("load after run: %v", err)
+	}
+
+	out := readFile(t, dir, outputFile)
+	for _, want := range []string{
+		"\tCheckPayment func(Payment) error\n",
+		"\t// Iban (iban) validates Account.iban, Payment.to.\n\tIban func(string) error\n",
+		// ogen generated no Validate for Account.
+		"func (s *Account) Validate() error {",
+		// Nor does ogen's Payment.Validate validate From.
+		"\t\tif err := s.From.Validate(); err != nil {",
+		"\t\tif v, ok := s.To.Get(); ok {\n\t\t\tif err := callValidateHook(\"iban\", currentValidateHooks.Load().Iban, v); err != nil {",
+		"\tif err := callValidateHook(\"checkPayment\", currentValidateHooks.Load().CheckPayment, *s); err != nil {",
+		// ListAccountsOK holds Accounts.
+		"func (s ListAccountsOK) Validate() error {\n\treturn s.validateHooks()\n}",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "func (s *Payment) Validate()") {
+		t.Errorf("output redeclares Payment.Validate:\n%s", out)
+	}
+
+	validators := readFile(t, dir, validatorsFile)
+	if !strings.HasSuffix(validators, "\treturn s.validateHooks()\n}\n") {
+		t.Errorf("Payment.Validate does not call the hooks:\n%s", validators)
+	}
+
+	// Running again changes nothing.
+	if err := run([]string{"-spec", spec, dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if got := readFile(t, dir, outputFile); got != out {
+		t.Errorf("second run changed %s:\n%s", outputFile, got)
+	}
+	if got := readFile(t, dir, validatorsFile); got != validators {
+		t.Errorf("second run changed %s:\n%s", validatorsFile, got)
+	}
+}
+
+func TestPlan_Conflict(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "oas_schemas_gen.go", schemasSource)
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Plan(pkg, []Hook{
+		{Schema: "Account", Property: "iban", Func: "iban"},
+		{Schema: "Payment", Property: "amount", Func: "iban"},
+	})
+	if err == nil || !strings.Contains(err.Error(), `x-validate "iban" is used for Account.iban and Payment.amount`) {
+		t.Errorf("Plan() error = %v, want a conflict for iban", err)
+	}
+}
+
+func TestValidateBodies(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "oas_schemas_gen.go", schemasSource)
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := Plan(pkg, []Hook{{Schema: "Account", Property: "iban", Func: "iban"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoders := `package api
+
+func decodeListAccountsResponse(resp *http.Response) (res []Account, _ error) {
+			var response []Account
+			if err := func() error {
+				return nil
+			}(); err != nil {
+				err = &ogenerrors.DecodeBodyError{
+					ContentType: ct,
+					Body:        buf,
+					Err:         err,
+				}
+				return res, err
+			}
+			return response, nil
+}
+
+func decodeGetNameResponse(resp *http.Response) (res string, _ error) {
+			var response string
+			if err := func() error {
+				return nil
+			}(); err != nil {
+				err = &ogenerrors.DecodeBodyError{
+					ContentType: ct,
+					Body:        buf,
+					Err:         err,
+				}
+				return res, err
+			}
+			return response, nil
+}
+`
+	got, n := plan.ValidateBodies(pkg, []byte(decoders))
+	if n != 1 {
+		t.Errorf("ValidateBodies() validated %d bodies, want 1", n)
+	}
+	want := "\t\t\t}\n// Run x-validate hooks.\nif err := func() error {\nfor i, elem := range response {\n"
+	if !strings.Contains(string(got), want) {
+		t.Errorf("output missing %q:\n%s", want, got)
+	}
+	if !strings.Contains(string(got), "return res, errors.Wrap(err, \"validate\")\n}\n\t\t\treturn response, nil\n}\n\nfunc decodeGetNameResponse") {
+		t.Errorf("validation not inserted after the decode error:\n%s", got)
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
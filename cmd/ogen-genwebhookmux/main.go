@@ -0,0 +1,262 @@
+// Command ogen-genwebhookmux generates a single-endpoint webhook receiver for
+// ogen servers, with a hook for signature verification.
+//
+// ogen generates a WebhookServer for the spec's webhooks section, but its
+// Handler takes the webhook name from the caller, so every webhook needs its
+// own route. Providers usually deliver every event to one URL, name the event
+// in a header or a body field, and sign the raw body. This tool generates a
+// WebhookMux that reads the body once, verifies it, finds the webhook for the
+// event and hands the request to the generated handler:
+//
+//	mux := api.NewWebhookMux(srv, api.WebhookEventHeader("X-Event-Type"), verifySignature)
+//	http.Handle("/webhooks", mux)
+//
+// Usage:
+//
+//	ogen-genwebhookmux <generated-dir>
+//
+// The mux is written to oas_webhookmux_gen.go in the generated directory, so
+// ogen --clean removes it along with the rest of the generated code.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the mux is written to.
+const outputFile = "oas_webhookmux_gen.go"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-genwebhookmux: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-genwebhookmux <generated-dir>")
+	}
+
+	dir := args[0]
+	router, err := os.ReadFile(filepath.Join(dir, "oas_router_gen.go")) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	names := FindWebhooks(router)
+	if len(names) == 0 {
+		fmt.Printf("No webhooks found in %s\n", dir)
+		return nil
+	}
+
+	pkg, err := gopkg.Load(dir, outputFile)
+	if err != nil {
+		return err
+	}
+
+	gen, err := GenerateMux(pkg, names)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(pkg.Dir, outputFile)
+	if err := gen.WriteFile(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated webhook mux for %d webhooks in %s\n", len(names), path)
+	return nil
+}
+
+var (
+	// webhookHandlePattern matches the generated WebhookServer.Handle method.
+	webhookHandlePattern = regexp.MustCompile(
+		`(?ms)^func \(s \*WebhookServer\) Handle\(webhookName string, .*?^\}\n`)
+
+	// webhookCasePattern matches a webhook name in the Handle switch.
+	webhookCasePattern = regexp.MustCompile(`\n\tcase "((?:[^"\\]|\\.)*)":\n`)
+)
+
+// FindWebhooks returns the webhook names the generated router handles, in
+// spec order.
+func FindWebhooks(router []byte) []string {
+	handle := webhookHandlePattern.Find(router)
+	var names []string
+	for _, m := range webhookCasePattern.FindAllSubmatch(handle, -1) {
+		names = append(names, string(m[1]))
+	}
+	return names
+}
+
+// constName returns the Go constant for a webhook name: "order.created"
+// becomes WebhookOrderCreated.
+func constName(name string) string {
+	var b strings.Builder
+	b.WriteString("Webhook")
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// GenerateMux generates the webhook name constants and the WebhookMux.
+func GenerateMux(pkg *gopkg.Package, names []string) (*gopkg.Generated, error) {
+	scope := pkg.Types.Scope()
+	if scope.Lookup("WebhookServer") == nil {
+		return nil, fmt.Errorf("WebhookServer not found; the package has no webhook server")
+	}
+
+	decls := []string{
+		"WebhookMux", "NewWebhookMux", "WebhookEventFunc", "WebhookVerifyFunc",
+		"WebhookEventHeader", "WebhookEventField", "WebhookEventMap",
+	}
+	consts := make(map[string]string)
+	for _, name := range names {
+		c := constName(name)
+		if prev, ok := consts[c]; ok {
+			return nil, fmt.Errorf("webhooks %q and %q both map to %s", prev, name, c)
+		}
+		consts[c] = name
+		decls = append(decls, c)
+	}
+	for _, decl := range decls {
+		if scope.Lookup(decl) != nil {
+			return nil, fmt.Errorf("%s is already declared", decl)
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-genwebhookmux", pkg.Types)
+	gen.Import("bytes", "bytes")
+	gen.Import("io", "io")
+	gen.Import("net/http", "http")
+	gen.Import("github.com/go-faster/errors", "errors")
+	gen.Import("github.com/go-faster/jx", "jx")
+
+	gen.Printf("// Webhook names, as passed to WebhookServer.Handle.\nconst (\n")
+	for _, name := range names {
+		gen.Printf("\t%s = %q\n", constName(name), name)
+	}
+	gen.Printf(")\n\n")
+
+	gen.Printf("%s", muxSource)
+	return gen, nil
+}
+
+// muxSource is the spec-independent part of the generated file.
+const muxSource = `// WebhookEventFunc returns the name of the webhook a request is for. body is
+// the raw request body.
+type WebhookEventFunc func(r *http.Request, body []byte) (string, error)
+
+// WebhookVerifyFunc checks the signature of a webhook request before it is
+// decoded. body is the raw request body.
+type WebhookVerifyFunc func(r *http.Request, body []byte) error
+
+// WebhookMux serves every webhook of the spec from a single endpoint.
+type WebhookMux struct {
+	s      *WebhookServer
+	event  WebhookEventFunc
+	verify WebhookVerifyFunc
+}
+
+// NewWebhookMux returns a mux that finds the webhook for each request with
+// event and dispatches it to s. If verify is not nil, requests it rejects are
+// answered with 401 Unauthorized and never decoded.
+func NewWebhookMux(s *WebhookServer, event WebhookEventFunc, verify WebhookVerifyFunc) *WebhookMux {
+	return &WebhookMux{s: s, event: event, verify: verify}
+}
+
+// ServeHTTP implements http.Handler.
+func (m *WebhookMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if m.verify != nil {
+		if err := m.verify(r, body); err != nil {
+			http.Error(w, "verify webhook: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+	name, err := m.event(r, body)
+	if err != nil {
+		http.Error(w, "webhook event: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if !m.s.Handle(name, w, r) {
+		http.Error(w, "unknown webhook "+name+" for "+r.Method, http.StatusNotFound)
+	}
+}
+
+// WebhookEventHeader returns a WebhookEventFunc that reads the webhook name
+// from a request header.
+func WebhookEventHeader(header string) WebhookEventFunc {
+	return func(r *http.Request, _ []byte) (string, error) {
+		if v := r.Header.Get(header); v != "" {
+			return v, nil
+		}
+		return "", errors.Errorf("missing header %s", header)
+	}
+}
+
+// WebhookEventField returns a WebhookEventFunc that reads the webhook name
+// from a top-level string field of the JSON body.
+func WebhookEventField(field string) WebhookEventFunc {
+	return func(_ *http.Request, body []byte) (string, error) {
+		var name string
+		found := false
+		if err := jx.DecodeBytes(body).ObjBytes(func(d *jx.Decoder, key []byte) error {
+			if found || string(key) != field {
+				return d.Skip()
+			}
+			v, err := d.Str()
+			if err != nil {
+				return err
+			}
+			name, found = v, true
+			return nil
+		}); err != nil {
+			return "", err
+		}
+		if !found {
+			return "", errors.Errorf("missing field %q", field)
+		}
+		return name, nil
+	}
+}
+
+// WebhookEventMap returns a WebhookEventFunc that translates the event names
+// returned by event to webhook names, such as "order.created" to
+// WebhookOrderCreated. Events missing from names are passed through.
+func WebhookEventMap(event WebhookEventFunc, names map[string]string) WebhookEventFunc {
+	return func(r *http.Request, body []byte) (string, error) {
+		name, err := event(r, body)
+		if err != nil {
+			return "", err
+		}
+		if mapped, ok := names[name]; ok {
+			return mapped, nil
+		}
+		return name, nil
+	}
+}
+`
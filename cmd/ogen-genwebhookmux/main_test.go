@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const routerSource = `package api
+
+import "net/http"
+
+type WebhookServer struct{}
+
+func (s *Server) Handle(name string, w http.ResponseWriter, r *http.Request) bool {
+	switch name {
+	case "notAWebhook":
+		return true
+	}
+	return false
+}
+
+type Server struct{}
+
+// Handle handles webhook request.
+//
+// Returns true if there is a webhook handler for given name and requested method.
+func (s *WebhookServer) Handle(webhookName string, w http.ResponseWriter, r *http.Request) bool {
+	switch webhookName {
+	case "orderCreated":
+		switch r.Method {
+		case "POST":
+		default:
+			return false
+		}
+		return true
+	case "order.shipped":
+		switch r.Method {
+		case "POST":
+		default:
+			return false
+		}
+		return true
+	default:
+		return false
+	}
+}
+`
+
+func TestFindWebhooks(t *testing.T) {
+	got := FindWebhooks([]byte(routerSource))
+	want := []string{"orderCreated", "order.shipped"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("FindWebhooks() = %q, want %q", got, want)
+	}
+}
+
+func TestConstName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"orderCreated", "WebhookOrderCreated"},
+		{"order.created", "WebhookOrderCreated"},
+		{"invoice_paid-v2", "WebhookInvoicePaidV2"},
+	}
+	for _, tt := range tests {
+		if got := constName(tt.name); got != tt.want {
+			t.Errorf("constName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_router_gen.go"), routerSource)
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, outputFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+
+	for _, want := range []string{
+		"// Code generated by ogen-genwebhookmux, DO NOT EDIT.",
+		"\tWebhookOrderCreated = \"orderCreated\"\n",
+		"\tWebhookOrderShipped = \"order.shipped\"\n",
+		"func NewWebhookMux(s *WebhookServer, event WebhookEventFunc, verify WebhookVerifyFunc) *WebhookMux {",
+		"func WebhookEventHeader(header string) WebhookEventFunc {",
+		"func WebhookEventField(field string) WebhookEventFunc {",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "notAWebhook") {
+		t.Error("picked up a case outside WebhookServer.Handle")
+	}
+
+	// The generated file must compile against the package.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Errorf("generated code does not type-check: %v", err)
+	}
+
+	// Regenerating replaces the previous output.
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+}
+
+func TestRun_Collision(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_router_gen.go"),
+		strings.Replace(routerSource, `"order.shipped"`, `"order.created"`, 1))
+
+	err := run([]string{dir})
+	if err == nil || !strings.Contains(err.Error(), "both map to WebhookOrderCreated") {
+		t.Errorf("run() error = %v, want collision", err)
+	}
+}
+
+func TestRun_NoWebhooks(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oas_schemas_gen.go"), "package api\n\ntype User struct{ Name string }\n")
+
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, outputFile)); !os.IsNotExist(err) {
+		t.Errorf("mux file written without webhooks: %v", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,228 @@
+// Command ogen-genyaml generates YAML marshaling for the schema types of an
+// ogen-generated package.
+//
+// Config files and fixtures written in YAML should load straight into the
+// types of the spec, with the same field names, formats and validation as the
+// API. This tool makes every type with generated JSON methods implement the
+// Marshaler and func-based Unmarshaler interfaces of gopkg.in/yaml.v2 and
+// gopkg.in/yaml.v3 by converting to and from its JSON encoding:
+//
+//	var payment api.Payment
+//	err := yaml.Unmarshal(data, &payment)
+//
+// The generated code imports no YAML package.
+//
+// Usage:
+//
+//	ogen-genyaml <generated-dir>
+//
+// The methods are written to oas_yaml_gen.go in the generated directory.
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// outputFile is the file the methods are written to.
+const outputFile = "oas_yaml_gen.go"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-genyaml: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ogen-genyaml <generated-dir>")
+	}
+
+	pkg, err := gopkg.Load(args[0], outputFile)
+	if err != nil {
+		return err
+	}
+
+	schemas := FindSchemaTypes(pkg)
+	if len(schemas) == 0 {
+		fmt.Printf("No types with JSON methods found in %s\n", args[0])
+		return nil
+	}
+
+	gen, err := GenerateYAML(pkg, schemas)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(pkg.Dir, outputFile)
+	if err := gen.WriteFile(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated YAML methods for %d types in %s\n", len(schemas), path)
+	return nil
+}
+
+// FindSchemaTypes returns the named types of pkg, sorted by name, that have
+// MarshalJSON and UnmarshalJSON methods. The Opt, Nil and OptNil wrappers are
+// left out: YAML reaches them through the types that hold them.
+func FindSchemaTypes(pkg *gopkg.Package) []*types.Named {
+	var schemas []*types.Named
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || obj.IsAlias() {
+			continue
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		if kind, _ := gopkg.Unwrap(named); kind != gopkg.NotWrapped {
+			continue
+		}
+		mset := types.NewMethodSet(types.NewPointer(named))
+		if mset.Lookup(pkg.Types, "MarshalJSON") != nil && mset.Lookup(pkg.Types, "UnmarshalJSON") != nil {
+			schemas = append(schemas, named)
+		}
+	}
+	return schemas
+}
+
+// GenerateYAML generates MarshalYAML and UnmarshalYAML for each type, and the
+// helpers they share.
+func GenerateYAML(pkg *gopkg.Package, schemas []*types.Named) (*gopkg.Generated, error) {
+	scope := pkg.Types.Scope()
+	for _, name := range []string{"marshalYAML", "yamlValue", "unmarshalYAML", "yamlRaw"} {
+		if scope.Lookup(name) != nil {
+			return nil, fmt.Errorf("%s is already declared", name)
+		}
+	}
+	for _, named := range schemas {
+		for _, method := range []string{"MarshalYAML", "UnmarshalYAML"} {
+			if m, _, _ := types.LookupFieldOrMethod(named, true, pkg.Types, method); m != nil {
+				return nil, fmt.Errorf("%s.%s is already declared", named.Obj().Name(), method)
+			}
+		}
+	}
+
+	gen := gopkg.NewGenerated("ogen-genyaml", pkg.Types)
+	for _, named := range schemas {
+		name := named.Obj().Name()
+		gen.Printf("// MarshalYAML implements yaml.Marshaler.\n")
+		gen.Printf("func (s %s) MarshalYAML() (any, error) {\n\treturn marshalYAML(&s)\n}\n\n", name)
+		gen.Printf("// UnmarshalYAML implements yaml.Unmarshaler.\n")
+		gen.Printf("func (s *%s) UnmarshalYAML(unmarshal func(any) error) error {\n\treturn unmarshalYAML(unmarshal, s)\n}\n\n", name)
+	}
+	writeHelpers(gen)
+	return gen, nil
+}
+
+func writeHelpers(gen *gopkg.Generated) {
+	gen.Import("bytes", "bytes")
+	gen.Import("encoding/json", "json")
+	gen.Import("time", "time")
+	gen.Import("github.com/go-faster/errors", "errors")
+	gen.Printf(`// marshalYAML returns the JSON encoding of v as plain values for a YAML
+// encoder: maps, slices, strings, numbers, booleans and nil.
+func marshalYAML(v json.Marshaler) (any, error) {
+	data, err := v.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var out any
+	if err := d.Decode(&out); err != nil {
+		return nil, errors.Wrap(err, "marshal yaml")
+	}
+	return yamlValue(out), nil
+}
+
+// yamlValue replaces the json.Numbers in v by int64 or float64, which YAML
+// encoders write unquoted.
+func yamlValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = yamlValue(elem)
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = yamlValue(elem)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	}
+	return v
+}
+
+// unmarshalYAML decodes the YAML value unmarshal reads into v through its
+// JSON encoding, and validates v if it has a Validate method, as the API does.
+func unmarshalYAML(unmarshal func(any) error, v json.Unmarshaler) error {
+	var raw yamlRaw
+	if err := raw.UnmarshalYAML(unmarshal); err != nil {
+		return err
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return errors.Wrap(err, "unmarshal yaml")
+	}
+	if err := v.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	if validator, ok := v.(interface{ Validate() error }); ok {
+		if err := validator.Validate(); err != nil {
+			return errors.Wrap(err, "validate")
+		}
+	}
+	return nil
+}
+
+// yamlRaw is a YAML value decoded as if into any, except that timestamps
+// keep their text: the JSON decoders parse dates and times from strings in
+// the format of the spec.
+type yamlRaw struct {
+	v any
+}
+
+func (r *yamlRaw) UnmarshalYAML(unmarshal func(any) error) error {
+	var m map[string]yamlRaw
+	if err := unmarshal(&m); err == nil {
+		r.v = m
+		return nil
+	}
+	var s []yamlRaw
+	if err := unmarshal(&s); err == nil {
+		r.v = s
+		return nil
+	}
+	if err := unmarshal(&r.v); err != nil {
+		return err
+	}
+	if _, ok := r.v.(time.Time); ok {
+		var text string
+		if err := unmarshal(&text); err != nil {
+			return err
+		}
+		r.v = text
+	}
+	return nil
+}
+
+func (r yamlRaw) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.v)
+}
+`)
+}
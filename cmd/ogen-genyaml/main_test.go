@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+const schemasSource = `package api
+
+type Status string
+
+func (s Status) MarshalJSON() ([]byte, error) { return nil, nil }
+
+func (s *Status) UnmarshalJSON(data []byte) error { return nil }
+
+type User struct {
+	Name     OptString
+	Status   Status
+}
+
+func (s *User) MarshalJSON() ([]byte, error) { return nil, nil }
+
+func (s *User) UnmarshalJSON(data []byte) error { return nil }
+
+func (s *User) Validate() error { return nil }
+
+type OptString struct {
+	Value string
+	Set   bool
+}
+
+func (o OptString) MarshalJSON() ([]byte, error) { return nil, nil }
+
+func (o *OptString) UnmarshalJSON(data []byte) error { return nil }
+
+// Not a schema type: no JSON methods.
+type UserHandler struct{}
+`
+
+func TestRun(t *testing.T) {
+	dir := writePackage(t, schemasSource)
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	// The generated file must type-check with the package.
+	if _, err := gopkg.Load(dir); err != nil {
+		t.Fatalf("load after run: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, outputFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(content)
+	for _, want := range []string{
+		"func (s Status) MarshalYAML() (any, error) {\n\treturn marshalYAML(&s)\n}",
+		"func (s *Status) UnmarshalYAML(unmarshal func(any) error) error {\n\treturn unmarshalYAML(unmarshal, s)\n}",
+		"func (s User) MarshalYAML() (any, error) {",
+		"func (s *User) UnmarshalYAML(unmarshal func(any) error) error {",
+		"func unmarshalYAML(unmarshal func(any) error, v json.Unmarshaler) error {",
+		"if validator, ok := v.(interface{ Validate() error }); ok {",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	for _, name := range []string{"OptString", "UserHandler"} {
+		if strings.Contains(out, name) {
+			t.Errorf("output has methods for %s:\n%s", name, out)
+		}
+	}
+
+	// Running again replaces the file instead of colliding with it.
+	if err := run([]string{dir}); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+}
+
+func TestGenerateYAML_AlreadyDeclared(t *testing.T) {
+	dir := writePackage(t, schemasSource+"\nfunc (s User) MarshalYAML() (any, error) { return nil, nil }\n")
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = GenerateYAML(pkg, FindSchemaTypes(pkg))
+	if err == nil || err.Error() != "User.MarshalYAML is already declared" {
+		t.Errorf("GenerateYAML() error = %v, want User.MarshalYAML is already declared", err)
+	}
+}
+
+func writePackage(t *testing.T, source string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "oas_schemas_gen.go"), []byte(source), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,652 @@
+// Command ogen-prune removes unused operations and types from an ogen-generated
+// client package.
+//
+// ogen generates every operation in the spec, along with its parameters,
+// request and response types, encoders, decoders, and validators. For a large
+// spec of which an application calls a handful of operations, the generated
+// package dominates compile time and binary size. This tool keeps only the
+// operations you name (or that your code calls) and deletes everything that is
+// no longer reachable from them.
+//
+// Usage:
+//
+//	ogen-prune -keep GetUser,ListUsers <generated-dir>
+//	ogen-prune -keep-file operations.txt <generated-dir>
+//	ogen-prune -scan ./internal <generated-dir>
+//
+// Operations can be named by Go method name (GetUser) or operationId
+// (getUser). -scan parses the Go files under a directory and keeps every
+// operation whose client method name is called there; it can be combined with
+// -keep and -keep-file.
+//
+// Only client packages are supported: generate with the paths/server feature
+// disabled, because the generated router cannot be pruned safely.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/gopkg"
+)
+
+// prunableFiles hold declarations that are only needed if some kept operation
+// reaches them. Declarations in every other file are client infrastructure
+// and are always kept.
+var prunableFiles = map[string]bool{
+	"oas_faker_gen.go":             true,
+	"oas_interfaces_gen.go":        true,
+	"oas_json_gen.go":              true,
+	"oas_parameters_gen.go":        true,
+	"oas_request_encoders_gen.go":  true,
+	"oas_response_decoders_gen.go": true,
+	"oas_schemas_gen.go":           true,
+	"oas_uri_gen.go":               true,
+	"oas_validators_gen.go":        true,
+}
+
+// Operation is a client operation found in the generated package.
+type Operation struct {
+	Name        string // Go method name, e.g. GetUser
+	OperationID string // spec operationId, e.g. getUser
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-prune: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("ogen-prune", flag.ContinueOnError)
+	keepList := flags.String("keep", "", "comma-separated operations to keep (Go name or operationId)")
+	keepFile := flags.String("keep-file", "", "file listing operations to keep, one per line")
+	scanDir := flags.String("scan", "", "keep operations called from Go code under this directory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 || (*keepList == "" && *keepFile == "" && *scanDir == "") {
+		return fmt.Errorf("usage: ogen-prune (-keep ops | -keep-file file | -scan dir) <generated-dir>")
+	}
+
+	dir := flags.Arg(0)
+	pkg, err := gopkg.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	ops, err := FindOperations(pkg)
+	if err != nil {
+		return err
+	}
+
+	keep, err := resolveKeep(ops, splitList(*keepList))
+	if err != nil {
+		return err
+	}
+	if *keepFile != "" {
+		names, err := readKeepFile(*keepFile)
+		if err != nil {
+			return err
+		}
+		fromFile, err := resolveKeep(ops, names)
+		if err != nil {
+			return err
+		}
+		for name := range fromFile {
+			keep[name] = true
+		}
+	}
+	if *scanDir != "" {
+		called, err := ScanCalls(*scanDir, pkg.Dir)
+		if err != nil {
+			return err
+		}
+		for _, op := range ops {
+			if called[op.Name] {
+				keep[op.Name] = true
+			}
+		}
+	}
+
+	if len(keep) == len(ops) {
+		fmt.Printf("All %d operations are used; nothing to prune in %s\n", len(ops), dir)
+		return nil
+	}
+
+	stats, err := Prune(pkg, keep)
+	if err != nil {
+		return err
+	}
+
+	if _, err := pkg.Write(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Kept %d of %d operations; removed %d declarations (%d lines) in %s\n",
+		len(keep), len(ops), stats.Decls, stats.Lines, dir)
+	return nil
+}
+
+func splitList(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func readKeepFile(filename string) ([]string, error) {
+	f, err := os.Open(filename) // #nosec G304 -- CLI tool, filename from trusted args
+	if err != nil {
+		return nil, fmt.Errorf("read keep file: %w", err)
+	}
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read keep file: %w", err)
+	}
+	return names, nil
+}
+
+// resolveKeep maps Go names and operationIds to Go names, failing on names
+// that match no operation so typos don't silently prune everything.
+func resolveKeep(ops []Operation, names []string) (map[string]bool, error) {
+	keep := make(map[string]bool)
+	for _, name := range names {
+		found := false
+		for _, op := range ops {
+			if name == op.Name || name == op.OperationID {
+				keep[op.Name] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown operation %q", name)
+		}
+	}
+	return keep, nil
+}
+
+var invokesPattern = regexp.MustCompile(`invokes (\S+) operation`)
+
+// FindOperations returns the operations of the generated Invoker interface.
+func FindOperations(pkg *gopkg.Package) ([]Operation, error) {
+	scope := pkg.Types.Scope()
+	if scope.Lookup("Handler") != nil && scope.Lookup("Server") != nil {
+		return nil, fmt.Errorf("package contains generated server code; generate a client-only package (disable the paths/server feature) before pruning")
+	}
+
+	invoker := findInterface(pkg, "Invoker")
+	if invoker == nil {
+		return nil, fmt.Errorf("no Invoker interface found; is this an ogen client package?")
+	}
+
+	var ops []Operation
+	for _, field := range invoker.Methods.List {
+		for _, name := range field.Names {
+			op := Operation{Name: name.Name}
+			if m := invokesPattern.FindStringSubmatch(field.Doc.Text()); m != nil {
+				op.OperationID = m[1]
+			}
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+func findInterface(pkg *gopkg.Package, name string) *ast.InterfaceType {
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if iface, ok := ts.Type.(*ast.InterfaceType); ok && ts.Name.Name == name {
+					return iface
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ScanCalls returns the names of all selectors (x.Name) used in Go files under
+// dir, skipping skipDir. Every client method called through any receiver is
+// included; the result may keep more than strictly needed, never less.
+func ScanCalls(dir, skipDir string) (map[string]bool, error) {
+	skipDir, err := filepath.Abs(skipDir)
+	if err != nil {
+		return nil, err
+	}
+
+	called := make(map[string]bool)
+	fset := token.NewFileSet()
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			name := d.Name()
+			if abs == skipDir || name == "vendor" || name == "testdata" ||
+				(path != dir && strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return fmt.Errorf("scan: %w", err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				called[sel.Sel.Name] = true
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return called, nil
+}
+
+// Stats summarizes what Prune removed.
+type Stats struct {
+	Decls int
+	Lines int
+}
+
+// decl is one removable top-level declaration: a function, or a single spec
+// of a const, var, or type declaration.
+type decl struct {
+	file    *ast.File
+	path    string
+	node    ast.Node // *ast.FuncDecl or ast.Spec
+	parent  *ast.GenDecl
+	defines []types.Object
+	uses    []types.Object
+	root    bool
+	marker  string // unexported interface marker method name, if any
+}
+
+// Prune removes the operations not in keep and every declaration that is no
+// longer reachable from the kept operations and the client infrastructure.
+func Prune(pkg *gopkg.Package, keep map[string]bool) (Stats, error) {
+	var stats Stats
+
+	ops, err := FindOperations(pkg)
+	if err != nil {
+		return stats, err
+	}
+	dropped := make(map[string]bool)
+	for _, op := range ops {
+		if !keep[op.Name] {
+			dropped[op.Name] = true
+		}
+	}
+
+	// Objects owned by dropped operations: the Client method, its send
+	// helper, and the OperationName constant.
+	owned := make(map[types.Object]bool)
+	scope := pkg.Types.Scope()
+	if client, ok := scope.Lookup("Client").(*types.TypeName); ok {
+		for name := range dropped {
+			for _, method := range []string{name, "send" + name} {
+				obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(client.Type()), false, pkg.Types, method)
+				if obj != nil {
+					owned[obj] = true
+				}
+			}
+		}
+	}
+	for name := range dropped {
+		if obj := scope.Lookup(name + "Operation"); obj != nil {
+			owned[obj] = true
+		}
+	}
+
+	// Remove dropped methods from the Invoker interface.
+	invoker := findInterface(pkg, "Invoker")
+	invokerPath := pathOf(pkg, invoker)
+	var methods []*ast.Field
+	var spans []span
+	for _, field := range invoker.Methods.List {
+		if len(field.Names) == 1 && dropped[field.Names[0].Name] {
+			stats.Decls++
+			stats.Lines += lineSpan(pkg.Fset, field.Doc, field)
+			spans = append(spans, spanOf(field.Doc, field))
+			continue
+		}
+		methods = append(methods, field)
+	}
+	invoker.Methods.List = methods
+	dropComments(pkg.Files[invokerPath], spans)
+	pkg.MarkChanged(invokerPath)
+
+	decls := collectDecls(pkg)
+
+	// Mark reachable objects, starting from root declarations.
+	byObject := make(map[types.Object][]*decl)
+	methodsOf := make(map[types.Object][]*decl)
+	for _, d := range decls {
+		for _, obj := range d.defines {
+			byObject[obj] = append(byObject[obj], d)
+		}
+		if fn, ok := d.node.(*ast.FuncDecl); ok && fn.Recv != nil {
+			if recv := receiverType(pkg, fn); recv != nil {
+				methodsOf[recv] = append(methodsOf[recv], d)
+			}
+		}
+	}
+
+	reached := make(map[*decl]bool)
+	var queue []*decl
+	reach := func(d *decl) {
+		if !reached[d] && !isOwned(d, owned) {
+			reached[d] = true
+			queue = append(queue, d)
+		}
+	}
+	for _, d := range decls {
+		if d.root {
+			reach(d)
+		}
+	}
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		for _, obj := range d.uses {
+			for _, target := range byObject[obj] {
+				reach(target)
+			}
+			for _, method := range methodsOf[obj] {
+				reach(method)
+			}
+		}
+		for _, obj := range d.defines {
+			for _, method := range methodsOf[obj] {
+				reach(method)
+			}
+		}
+	}
+
+	// Marker methods implement sealed response interfaces; drop those whose
+	// interface is gone even if the receiver type is still in use.
+	liveMarkers := make(map[string]bool)
+	for _, d := range decls {
+		if reached[d] {
+			for _, obj := range d.defines {
+				if tn, ok := obj.(*types.TypeName); ok {
+					if iface, ok := tn.Type().Underlying().(*types.Interface); ok {
+						for i := range iface.NumExplicitMethods() {
+							liveMarkers[iface.ExplicitMethod(i).Name()] = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	remove := make(map[ast.Node]bool)
+	for _, d := range decls {
+		if reached[d] && (d.marker == "" || liveMarkers[d.marker]) {
+			continue
+		}
+		remove[d.node] = true
+		stats.Decls++
+		pkg.MarkChanged(d.path)
+	}
+
+	for path, file := range pkg.Files {
+		stats.Lines += removeNodes(pkg.Fset, file, remove)
+		pkg.RemoveUnusedImports(file)
+		if !hasDecls(file) {
+			pkg.RemoveFile(path)
+		}
+	}
+
+	return stats, nil
+}
+
+func isOwned(d *decl, owned map[types.Object]bool) bool {
+	for _, obj := range d.defines {
+		if owned[obj] {
+			return true
+		}
+	}
+	return false
+}
+
+func collectDecls(pkg *gopkg.Package) []*decl {
+	var decls []*decl
+	for path, file := range pkg.Files {
+		root := !prunableFiles[filepath.Base(path)]
+		for _, node := range file.Decls {
+			switch node := node.(type) {
+			case *ast.FuncDecl:
+				d := &decl{file: file, path: path, node: node, root: root}
+				d.defines = append(d.defines, pkg.Info.Defs[node.Name])
+				d.uses = usesIn(pkg, node)
+				if node.Recv != nil && !ast.IsExported(node.Name.Name) &&
+					node.Body != nil && len(node.Body.List) == 0 {
+					d.marker = node.Name.Name
+				}
+				if node.Recv == nil && node.Name.Name == "init" {
+					d.root = true
+				}
+				decls = append(decls, d)
+			case *ast.GenDecl:
+				if node.Tok == token.IMPORT {
+					continue
+				}
+				for _, spec := range node.Specs {
+					d := &decl{file: file, path: path, node: spec, parent: node, root: root}
+					blank := false
+					for _, id := range specNames(spec) {
+						if id.Name == "_" {
+							blank = true
+						}
+						if obj := pkg.Info.Defs[id]; obj != nil {
+							d.defines = append(d.defines, obj)
+						}
+					}
+					d.uses = usesIn(pkg, spec)
+					// Blank declarations (var _ = ...) are assertions about
+					// other declarations; keep them with what they assert.
+					d.root = d.root || (blank && root)
+					decls = append(decls, d)
+				}
+			}
+		}
+	}
+	return decls
+}
+
+func specNames(spec ast.Spec) []*ast.Ident {
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		return []*ast.Ident{spec.Name}
+	case *ast.ValueSpec:
+		return spec.Names
+	}
+	return nil
+}
+
+// usesIn returns the package-level objects of this package that node
+// references, including methods and fields through selectors.
+func usesIn(pkg *gopkg.Package, node ast.Node) []types.Object {
+	var uses []types.Object
+	ast.Inspect(node, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if obj := pkg.Info.Uses[id]; obj != nil && obj.Pkg() == pkg.Types {
+				uses = append(uses, obj)
+			}
+		}
+		return true
+	})
+	return uses
+}
+
+func receiverType(pkg *gopkg.Package, fn *ast.FuncDecl) types.Object {
+	obj, ok := pkg.Info.Defs[fn.Name].(*types.Func)
+	if !ok {
+		return nil
+	}
+	recv := obj.Type().(*types.Signature).Recv().Type()
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	if named, ok := recv.(*types.Named); ok {
+		return named.Obj()
+	}
+	return nil
+}
+
+func pathOf(pkg *gopkg.Package, node ast.Node) string {
+	for path, file := range pkg.Files {
+		if file.Pos() <= node.Pos() && node.End() <= file.End() {
+			return path
+		}
+	}
+	return ""
+}
+
+// removeNodes deletes the given declarations and specs from file, along with
+// their comments, and returns the number of source lines removed.
+func removeNodes(fset *token.FileSet, file *ast.File, remove map[ast.Node]bool) int {
+	lines := 0
+	var spans []span
+	drop := func(doc *ast.CommentGroup, node ast.Node) {
+		spans = append(spans, spanOf(doc, node))
+		lines += lineSpan(fset, doc, node)
+	}
+
+	var decls []ast.Decl
+	for _, node := range file.Decls {
+		switch node := node.(type) {
+		case *ast.FuncDecl:
+			if remove[node] {
+				drop(node.Doc, node)
+				continue
+			}
+		case *ast.GenDecl:
+			var specs []ast.Spec
+			for _, spec := range node.Specs {
+				if remove[spec] {
+					continue
+				}
+				specs = append(specs, spec)
+			}
+			if len(specs) == 0 && len(node.Specs) > 0 {
+				drop(node.Doc, node)
+				continue
+			}
+			if len(specs) < len(node.Specs) {
+				for _, spec := range node.Specs {
+					if remove[spec] {
+						drop(specDoc(spec), spec)
+					}
+				}
+				node.Specs = specs
+			}
+		}
+		decls = append(decls, node)
+	}
+	file.Decls = decls
+	dropComments(file, spans)
+	return lines
+}
+
+// span is a source range covering a removed node and its doc comment.
+type span struct{ from, to token.Pos }
+
+func spanOf(doc *ast.CommentGroup, node ast.Node) span {
+	from := node.Pos()
+	if doc != nil {
+		from = doc.Pos()
+	}
+	return span{from, node.End()}
+}
+
+// dropComments removes the comment groups inside spans, which would otherwise
+// be printed as stray comments where the removed nodes used to be.
+func dropComments(file *ast.File, spans []span) {
+	var comments []*ast.CommentGroup
+	for _, group := range file.Comments {
+		inside := false
+		for _, s := range spans {
+			if s.from <= group.Pos() && group.End() <= s.to {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			comments = append(comments, group)
+		}
+	}
+	file.Comments = comments
+}
+
+func specDoc(spec ast.Spec) *ast.CommentGroup {
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		return spec.Doc
+	case *ast.ValueSpec:
+		return spec.Doc
+	}
+	return nil
+}
+
+func lineSpan(fset *token.FileSet, doc *ast.CommentGroup, node ast.Node) int {
+	from := node.Pos()
+	if doc != nil {
+		from = doc.Pos()
+	}
+	return fset.Position(node.End()).Line - fset.Position(from).Line + 1
+}
+
+func hasDecls(file *ast.File) bool {
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		return true
+	}
+	return false
+}
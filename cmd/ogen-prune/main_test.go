@@ -0,0 +1,268 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clientSource is a trimmed-down ogen client package that only depends on
+// the standard library, so it type-checks without module dependencies.
+var clientSource = map[string]string{
+	"oas_client_gen.go": `package api
+
+import (
+	"context"
+	"net/url"
+)
+
+// Invoker invokes operations described by OpenAPI v3 specification.
+type Invoker interface {
+	// GetUser invokes getUser operation.
+	//
+	// GET /users/{id}
+	GetUser(ctx context.Context, params GetUserParams) (GetUserRes, error)
+	// ListPets invokes listPets operation.
+	//
+	// GET /pets
+	ListPets(ctx context.Context) (*PetList, error)
+}
+
+// Client implements OAS client.
+type Client struct {
+	serverURL *url.URL
+}
+
+var _ Invoker = (*Client)(nil)
+
+// GetUser invokes getUser operation.
+func (c *Client) GetUser(ctx context.Context, params GetUserParams) (GetUserRes, error) {
+	return c.sendGetUser(ctx, params)
+}
+
+func (c *Client) sendGetUser(ctx context.Context, params GetUserParams) (GetUserRes, error) {
+	return decodeGetUserResponse(params.ID)
+}
+
+// ListPets invokes listPets operation.
+func (c *Client) ListPets(ctx context.Context) (*PetList, error) {
+	return c.sendListPets(ctx)
+}
+
+func (c *Client) sendListPets(ctx context.Context) (*PetList, error) {
+	return decodeListPetsResponse()
+}
+`,
+	"oas_operations_gen.go": `package api
+
+// OperationName is the ogen operation name
+type OperationName = string
+
+const (
+	GetUserOperation  OperationName = "GetUser"
+	ListPetsOperation OperationName = "ListPets"
+)
+`,
+	"oas_interfaces_gen.go": `package api
+
+type GetUserRes interface {
+	getUserRes()
+}
+
+type ListPetsRes interface {
+	listPetsRes()
+}
+`,
+	"oas_schemas_gen.go": `package api
+
+import "strings"
+
+// User is a user.
+type User struct {
+	Name string
+	Pet  OptPet
+}
+
+func (*User) getUserRes() {}
+
+// Error is an error response.
+type Error struct {
+	Message string
+}
+
+func (*Error) getUserRes()  {}
+func (*Error) listPetsRes() {}
+
+// Pet is a pet.
+type Pet struct {
+	Name string
+}
+
+// OptPet is optional Pet.
+type OptPet struct {
+	Value Pet
+	Set   bool
+}
+
+// PetList is a list of pets.
+type PetList struct {
+	Items []Pet
+	Owner Owner
+}
+
+func (*PetList) listPetsRes() {}
+
+// Owner is only used by PetList.
+type Owner struct {
+	Name string
+}
+
+// Upper uses an import that goes away with Owner.
+func (o Owner) Upper() string { return strings.ToUpper(o.Name) }
+`,
+	"oas_parameters_gen.go": `package api
+
+// GetUserParams is parameters of getUser operation.
+type GetUserParams struct {
+	ID string
+}
+`,
+	"oas_response_decoders_gen.go": `package api
+
+func decodeGetUserResponse(id string) (GetUserRes, error) {
+	if id == "" {
+		return &Error{}, nil
+	}
+	return &User{Name: id}, nil
+}
+
+func decodeListPetsResponse() (*PetList, error) {
+	return &PetList{}, nil
+}
+`,
+}
+
+func writePackage(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range clientSource {
+		writeFile(t, filepath.Join(dir, name), src)
+	}
+	return dir
+}
+
+func readPackage(t *testing.T, dir string) string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all strings.Builder
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		all.WriteString("// file: " + entry.Name() + "\n")
+		all.Write(data)
+	}
+	return all.String()
+}
+
+func TestRun_Keep(t *testing.T) {
+	dir := writePackage(t)
+
+	if err := run([]string{"-keep", "getUser", dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := readPackage(t, dir)
+
+	kept := []string{
+		"GetUser(ctx context.Context, params GetUserParams) (GetUserRes, error)",
+		"func (c *Client) sendGetUser(",
+		"GetUserOperation OperationName",
+		"type User struct",
+		"type OptPet struct",
+		"type Pet struct",
+		"func (*Error) getUserRes()",
+		"func decodeGetUserResponse(",
+	}
+	for _, want := range kept {
+		if !strings.Contains(out, want) {
+			t.Errorf("pruned %q, want kept:\n%s", want, out)
+		}
+	}
+
+	removed := []string{
+		"ListPets",
+		"listPetsRes",
+		"// ListPets invokes",
+		"type PetList struct",
+		"type Owner struct",
+		"func (o Owner) Upper()",
+		`"strings"`,
+		"decodeListPetsResponse",
+	}
+	for _, unwanted := range removed {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("kept %q, want pruned:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestRun_Scan(t *testing.T) {
+	dir := writePackage(t)
+	app := t.TempDir()
+	writeFile(t, filepath.Join(app, "app.go"), `package app
+
+func use(c interface{ ListPets() }) {
+	c.ListPets()
+}
+`)
+
+	if err := run([]string{"-scan", app, dir}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	out := readPackage(t, dir)
+
+	if !strings.Contains(out, "type PetList struct") || !strings.Contains(out, "type Owner struct") {
+		t.Errorf("ListPets types were pruned:\n%s", out)
+	}
+	if strings.Contains(out, "GetUserParams") || strings.Contains(out, "type User struct") {
+		t.Errorf("GetUser types were kept:\n%s", out)
+	}
+	// Error is only reachable through GetUser.
+	if strings.Contains(out, "type Error struct") || strings.Contains(out, "getUserRes") {
+		t.Errorf("GetUser responses were kept:\n%s", out)
+	}
+}
+
+func TestRun_UnknownOperation(t *testing.T) {
+	dir := writePackage(t)
+
+	err := run([]string{"-keep", "GetUsr", dir})
+	if err == nil || !strings.Contains(err.Error(), `unknown operation "GetUsr"`) {
+		t.Errorf("err = %v, want unknown operation error", err)
+	}
+}
+
+func TestReadKeepFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ops.txt")
+	writeFile(t, path, "# operations we call\nGetUser\n\n  listPets  # used by the sync job\n")
+
+	names, err := readKeepFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "GetUser,listPets" {
+		t.Errorf("names = %q", names)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
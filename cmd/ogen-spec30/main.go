@@ -0,0 +1,548 @@
+// Command ogen-spec30 converts an OpenAPI 3.1 spec to OpenAPI 3.0.
+//
+// ogen reads 3.1 specs, but fails on some of their constructs: type arrays
+// such as ["string", "null"], numeric exclusiveMinimum and exclusiveMaximum,
+// and several 3.1 schema keywords are rejected or ignored. This tool rewrites
+// them into their 3.0 equivalents:
+//
+//	{"type": ["string", "null"]}          {"type": "string", "nullable": true}
+//	{"anyOf": [{"$ref": ...}, {"type": "null"}]}
+//	                                      {"allOf": [{"$ref": ...}], "nullable": true}
+//	{"const": "dog"}                      {"enum": ["dog"]}
+//	{"examples": ["a", "b"]}              {"example": "a"}
+//	{"exclusiveMinimum": 0}               {"minimum": 0, "exclusiveMinimum": true}
+//	{"contentEncoding": "base64"}         {"format": "byte"}
+//
+// Path item components are copied to the paths that reference them.
+// Constructs 3.0 has no form for, such as webhooks, mutualTLS security
+// schemes, $schema dialects and if/then/else, are removed. Each removal, and
+// each conversion that loses information (a second example), is reported on
+// stderr with its location.
+//
+// Usage:
+//
+//	ogen-spec30 -o openapi.ogen.json openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-spec30: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-spec30", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the converted spec to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-spec30 -o <output.json> <openapi.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Convert(spec)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, l := range report.Lossy {
+		fmt.Fprintf(os.Stderr, "ogen-spec30: #%s: %s\n", l.Pointer, l.Message)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Converted %d constructs (%d lossy) to OpenAPI 3.0 in %s\n", report.Converted, len(report.Lossy), *outputFile)
+	return nil
+}
+
+// Version is the OpenAPI version of converted specs.
+const Version = "3.0.3"
+
+// Report lists what Convert changed.
+type Report struct {
+	// Converted is the number of constructs rewritten or removed.
+	Converted int
+	// Lossy lists the conversions that lost information.
+	Lossy []Note
+}
+
+// Note is a lossy conversion at a JSON pointer of the spec.
+type Note struct {
+	Pointer string
+	Message string
+}
+
+// defaultDialects are the JSON Schema dialects of 3.1 specs that need no
+// report when removed: they describe the schemas 3.0 has.
+var defaultDialects = map[string]bool{
+	"https://spec.openapis.org/oas/3.1/dialect/base": true,
+	"https://json-schema.org/draft/2020-12/schema":   true,
+}
+
+// droppedKeywords are the 3.1 schema keywords without a 3.0 form.
+var droppedKeywords = []string{
+	"$id",
+	"$anchor",
+	"$dynamicAnchor",
+	"$dynamicRef",
+	"$vocabulary",
+	"prefixItems",
+	"contains",
+	"minContains",
+	"maxContains",
+	"propertyNames",
+	"if",
+	"then",
+	"else",
+	"dependentRequired",
+	"dependentSchemas",
+	"unevaluatedItems",
+	"unevaluatedProperties",
+}
+
+type converter struct {
+	spec   *specdoc.Object
+	report *Report
+	// pathItems holds the removed path item components.
+	pathItems *specdoc.Object
+	// mutualTLS holds the names of the removed mutualTLS security schemes.
+	mutualTLS map[string]bool
+}
+
+// Convert rewrites spec, an OpenAPI 3.1 document, to OpenAPI 3.0.
+func Convert(spec *specdoc.Object) (*Report, error) {
+	version, _ := spec.Get("openapi").(string)
+	if !strings.HasPrefix(version, "3.1.") {
+		return nil, fmt.Errorf("openapi version %q is not 3.1", version)
+	}
+	spec.Set("openapi", Version)
+
+	c := &converter{spec: spec, report: &Report{}, mutualTLS: make(map[string]bool)}
+	c.convertRoot()
+	if err := specdoc.Walk(spec, c.convertObject); err != nil {
+		return nil, err
+	}
+	return c.report, nil
+}
+
+// converted counts a conversion.
+func (c *converter) converted() {
+	c.report.Converted++
+}
+
+// lossy counts a conversion that lost information.
+func (c *converter) lossy(ptr, format string, args ...any) {
+	c.report.Converted++
+	c.report.Lossy = append(c.report.Lossy, Note{Pointer: ptr, Message: fmt.Sprintf(format, args...)})
+}
+
+// convertRoot converts the fields of the document outside of paths and
+// schemas.
+func (c *converter) convertRoot() {
+	if dialect, ok := c.spec.Get("jsonSchemaDialect").(string); ok {
+		c.spec.Delete("jsonSchemaDialect")
+		if defaultDialects[dialect] {
+			c.converted()
+		} else {
+			c.lossy("/jsonSchemaDialect", "removed JSON Schema dialect %s", dialect)
+		}
+	}
+
+	if webhooks, ok := c.spec.Get("webhooks").(*specdoc.Object); ok {
+		c.spec.Delete("webhooks")
+		c.lossy("/webhooks", "removed %d webhooks: %s", webhooks.Len(), strings.Join(webhooks.Keys(), ", "))
+	}
+
+	if !c.spec.Has("paths") {
+		// Paths are required in 3.0.
+		c.spec.Set("paths", specdoc.NewObject())
+		c.converted()
+	}
+
+	info, _ := c.spec.Get("info").(*specdoc.Object)
+	if summary, ok := info.Get("summary").(string); ok {
+		if info.Has("description") {
+			info.Delete("summary")
+			c.lossy("/info/summary", "removed summary %q", summary)
+		} else {
+			info.Replace("summary", "description", summary)
+			c.converted()
+		}
+	}
+	license, _ := info.Get("license").(*specdoc.Object)
+	if id, ok := license.Get("identifier").(string); ok {
+		if license.Has("url") {
+			license.Delete("identifier")
+		} else {
+			license.Replace("identifier", "url", "https://spdx.org/licenses/"+id+".html")
+		}
+		c.converted()
+	}
+
+	// Path item components are removed; the paths get copies of them.
+	components, _ := c.spec.Get("components").(*specdoc.Object)
+	c.pathItems, _ = components.Get("pathItems").(*specdoc.Object)
+	if components.Has("pathItems") {
+		components.Delete("pathItems")
+	}
+
+	schemes, _ := components.Get("securitySchemes").(*specdoc.Object)
+	for _, name := range schemes.Keys() {
+		if scheme, ok := schemes.Get(name).(*specdoc.Object); ok && scheme.Get("type") == "mutualTLS" {
+			schemes.Delete(name)
+			c.mutualTLS[name] = true
+			c.lossy(specdoc.Pointer("/components/securitySchemes", name), "removed mutualTLS security scheme")
+		}
+	}
+}
+
+// convertObject converts the 3.1 constructs of an object of the spec.
+func (c *converter) convertObject(o *specdoc.Object, ptr string) error {
+	if err := c.inlinePathItem(o, ptr); err != nil {
+		return err
+	}
+	c.removeMutualTLS(o, ptr)
+
+	c.convertTypeArray(o)
+	c.convertNullMembers(o)
+	c.convertNullType(o, ptr)
+	c.convertConst(o, ptr)
+	c.convertExamples(o, ptr)
+	c.convertExclusive(o, "exclusiveMinimum", "minimum", 1)
+	c.convertExclusive(o, "exclusiveMaximum", "maximum", -1)
+	c.convertContent(o, ptr)
+	c.dropKeywords(o, ptr)
+	c.convertRefSiblings(o)
+	return nil
+}
+
+// inlinePathItem replaces a reference to a path item component by a copy
+// of the component, keeping the summary and description of the reference.
+func (c *converter) inlinePathItem(o *specdoc.Object, ptr string) error {
+	ref, ok := o.Get("$ref").(string)
+	if !ok || !strings.HasPrefix(ref, "#/components/pathItems/") {
+		return nil
+	}
+	v, ok := specdoc.Lookup(c.pathItems, strings.TrimPrefix(ref, "#/components/pathItems"))
+	item, isObject := v.(*specdoc.Object)
+	if !ok || !isObject {
+		return fmt.Errorf("#%s: unresolved $ref %s", ptr, ref)
+	}
+	item = specdoc.Clone(item).(*specdoc.Object)
+	o.Delete("$ref")
+	for _, key := range item.Keys() {
+		if !o.Has(key) {
+			o.Set(key, item.Get(key))
+		}
+	}
+	c.converted()
+	return nil
+}
+
+// removeMutualTLS removes the removed mutualTLS schemes from the security
+// requirements of an operation or the document. A requirement of mutualTLS
+// alone becomes empty: the connection, not the request, carries it.
+func (c *converter) removeMutualTLS(o *specdoc.Object, ptr string) {
+	requirements, ok := o.Get("security").([]any)
+	if !ok || len(c.mutualTLS) == 0 {
+		return
+	}
+	for i, r := range requirements {
+		requirement, _ := r.(*specdoc.Object)
+		for _, name := range requirement.Keys() {
+			if c.mutualTLS[name] {
+				requirement.Delete(name)
+				c.lossy(specdoc.Pointer(ptr, "security")+"/"+strconv.Itoa(i), "removed mutualTLS security requirement %s", name)
+			}
+		}
+	}
+}
+
+// convertTypeArray replaces a type array by its single type, or by an anyOf
+// of its types, with nullable if the array has "null".
+func (c *converter) convertTypeArray(o *specdoc.Object) {
+	list, ok := o.Get("type").([]any)
+	if !ok {
+		return
+	}
+	var types []any
+	null := false
+	for _, t := range list {
+		if t == "null" {
+			null = true
+		} else {
+			types = append(types, t)
+		}
+	}
+
+	switch {
+	case len(types) == 0 && null:
+		o.Set("type", "null")
+	case len(types) == 1:
+		o.Set("type", types[0])
+	case len(types) == 0:
+		o.Delete("type")
+	default:
+		members := make([]any, len(types))
+		for i, t := range types {
+			member := specdoc.NewObject()
+			member.Set("type", t)
+			members[i] = member
+		}
+		if o.Has("anyOf") {
+			// Both must hold.
+			group := specdoc.NewObject()
+			group.Set("anyOf", members)
+			allOf, _ := o.Get("allOf").([]any)
+			o.Set("allOf", append(allOf, group))
+			o.Delete("type")
+		} else {
+			o.Replace("type", "anyOf", members)
+		}
+	}
+	if null && len(types) > 0 {
+		o.Set("nullable", true)
+	}
+	c.converted()
+}
+
+// convertNullMembers removes the {"type": "null"} members of an anyOf or
+// oneOf, making the schema nullable. A single member left becomes an allOf,
+// where ogen reads the nullable.
+func (c *converter) convertNullMembers(o *specdoc.Object) {
+	for _, key := range []string{"anyOf", "oneOf"} {
+		list, ok := o.Get(key).([]any)
+		if !ok {
+			continue
+		}
+		var members []any
+		for _, m := range list {
+			if !isNullSchema(m) {
+				members = append(members, m)
+			}
+		}
+		if len(members) == len(list) || len(members) == 0 {
+			continue
+		}
+
+		o.Set("nullable", true)
+		if len(members) == 1 && !o.Has("allOf") {
+			o.Replace(key, "allOf", members)
+		} else {
+			o.Set(key, members)
+		}
+		c.converted()
+	}
+}
+
+// isNullSchema reports whether v is a schema of null only.
+func isNullSchema(v any) bool {
+	o, ok := v.(*specdoc.Object)
+	if !ok {
+		return false
+	}
+	switch t := o.Get("type").(type) {
+	case string:
+		return t == "null"
+	case []any:
+		return len(t) == 1 && t[0] == "null"
+	}
+	return false
+}
+
+// convertNullType replaces the type null, which 3.0 doesn't have, by a
+// nullable schema that only allows null.
+func (c *converter) convertNullType(o *specdoc.Object, ptr string) {
+	if o.Get("type") != "null" {
+		return
+	}
+	o.Delete("type")
+	o.Set("nullable", true)
+	o.Set("enum", []any{nil})
+	c.lossy(ptr, "replaced type null by a nullable enum of null")
+}
+
+// convertConst replaces const by an enum of one value, of the type of the
+// value if the schema has none.
+func (c *converter) convertConst(o *specdoc.Object, ptr string) {
+	if !o.Has("const") {
+		return
+	}
+	if o.Has("enum") {
+		o.Delete("const")
+		c.lossy(ptr, "removed const next to enum")
+		return
+	}
+	value := o.Get("const")
+	o.Replace("const", "enum", []any{value})
+	if !o.Has("type") {
+		// A 3.0 enum without a type has no Go type in ogen.
+		if t := jsonType(value); t != "" {
+			o.Set("type", t)
+		}
+	}
+	c.converted()
+}
+
+// jsonType returns the schema type of a JSON value, or "" for null.
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case *specdoc.Object:
+		return "object"
+	}
+	return ""
+}
+
+// convertExamples replaces the examples of a schema by its first example.
+// Parameters and media types have examples in 3.0 too, as a map rather than
+// a list.
+func (c *converter) convertExamples(o *specdoc.Object, ptr string) {
+	examples, ok := o.Get("examples").([]any)
+	if !ok {
+		return
+	}
+	switch {
+	case len(examples) == 0:
+		o.Delete("examples")
+	case o.Has("example"):
+		o.Delete("examples")
+		c.lossy(ptr, "removed %d examples next to example", len(examples))
+		return
+	default:
+		o.Replace("examples", "example", examples[0])
+	}
+	if len(examples) > 1 {
+		c.lossy(ptr, "kept the first of %d examples", len(examples))
+		return
+	}
+	c.converted()
+}
+
+// convertExclusive replaces a numeric exclusive bound by the bound and the
+// 3.0 boolean form. sign is 1 for a minimum and -1 for a maximum; if the
+// inclusive bound is stricter, it is kept instead.
+func (c *converter) convertExclusive(o *specdoc.Object, exclusiveKey, boundKey string, sign float64) {
+	exclusive, ok := o.Get(exclusiveKey).(json.Number)
+	if !ok {
+		return
+	}
+	c.converted()
+	if bound, ok := o.Get(boundKey).(json.Number); ok {
+		b, err1 := bound.Float64()
+		e, err2 := exclusive.Float64()
+		if err1 == nil && err2 == nil && sign*b > sign*e {
+			o.Delete(exclusiveKey)
+			return
+		}
+	}
+	o.Set(boundKey, exclusive)
+	o.Set(exclusiveKey, true)
+}
+
+// convertContent replaces contentEncoding and contentMediaType by the format
+// of a 3.0 string: byte for base64, binary for other content.
+func (c *converter) convertContent(o *specdoc.Object, ptr string) {
+	encoding, hasEncoding := o.Get("contentEncoding").(string)
+	_, hasMediaType := o.Get("contentMediaType").(string)
+	if !hasEncoding && !hasMediaType {
+		return
+	}
+	o.Delete("contentEncoding")
+	o.Delete("contentMediaType")
+
+	format := "binary"
+	if hasEncoding {
+		if encoding != "base64" {
+			c.lossy(ptr, "removed contentEncoding %s", encoding)
+			return
+		}
+		format = "byte"
+	}
+	if !o.Has("format") {
+		o.Set("format", format)
+	}
+	c.converted()
+}
+
+// dropKeywords removes the schema keywords 3.0 has no form for.
+func (c *converter) dropKeywords(o *specdoc.Object, ptr string) {
+	if dialect, ok := o.Get("$schema").(string); ok {
+		o.Delete("$schema")
+		if defaultDialects[dialect] {
+			c.converted()
+		} else {
+			c.lossy(ptr, "removed JSON Schema dialect %s", dialect)
+		}
+	}
+	if o.Has("$comment") {
+		o.Delete("$comment")
+		c.converted()
+	}
+	for _, key := range droppedKeywords {
+		if o.Has(key) {
+			o.Delete(key)
+			c.lossy(ptr, "removed %s", key)
+		}
+	}
+}
+
+// convertRefSiblings moves a schema $ref with other keywords into an allOf:
+// 3.0 ignores the siblings of a $ref. A description or summary next to a
+// $ref is left alone, as 3.1 allows them on any reference.
+func (c *converter) convertRefSiblings(o *specdoc.Object) {
+	ref, ok := o.Get("$ref").(string)
+	if !ok {
+		return
+	}
+	for _, key := range o.Keys() {
+		switch key {
+		case "$ref", "description", "summary":
+			continue
+		}
+		member := specdoc.NewObject()
+		member.Set("$ref", ref)
+		if list, ok := o.Get("allOf").([]any); ok {
+			o.Set("allOf", append([]any{member}, list...))
+			o.Delete("$ref")
+		} else {
+			o.Replace("$ref", "allOf", []any{member})
+		}
+		c.converted()
+		return
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.1.0",
+  "jsonSchemaDialect": "https://spec.openapis.org/oas/3.1/dialect/base",
+  "info": {"title": "Pets", "version": "1", "summary": "Pet store", "license": {"name": "MIT", "identifier": "MIT"}},
+  "paths": {
+    "/pets": {"$ref": "#/components/pathItems/Pets", "description": "All pets."}
+  },
+  "webhooks": {"newPet": {"post": {"responses": {"200": {"description": "ok"}}}}},
+  "components": {
+    "pathItems": {
+      "Pets": {
+        "description": "Pets.",
+        "get": {"security": [{"mtls": []}, {"key": []}], "responses": {"200": {"description": "ok"}}}
+      }
+    },
+    "securitySchemes": {
+      "mtls": {"type": "mutualTLS"},
+      "key": {"type": "apiKey", "in": "header", "name": "X-Key"}
+    },
+    "schemas": {
+      "Pet": {
+        "$schema": "https://json-schema.org/draft/2020-12/schema",
+        "type": "object",
+        "properties": {
+          "name": {"type": ["string", "null"], "examples": ["Rex", "Tom"]},
+          "kind": {"const": "dog"},
+          "age": {"type": "integer", "exclusiveMinimum": 0, "maximum": 30, "exclusiveMaximum": 100},
+          "id": {"type": ["string", "integer"]},
+          "owner": {"anyOf": [{"$ref": "#/components/schemas/Owner"}, {"type": "null"}]},
+          "sitter": {"$ref": "#/components/schemas/Owner", "readOnly": true, "description": "Sitter."},
+          "walker": {"$ref": "#/components/schemas/Owner", "description": "Walker."},
+          "photo": {"type": "string", "contentEncoding": "base64"},
+          "tags": {"type": "array", "prefixItems": [{"type": "string"}], "items": {"type": "string"}, "$comment": "Tags."},
+          "nothing": {"type": "null"}
+        }
+      },
+      "Owner": {"type": "object", "properties": {"name": {"type": "string"}}}
+    }
+  }
+}`
+
+func TestConvert(t *testing.T) {
+	spec, err := specdoc.Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Convert(spec)
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+
+	got, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+	for _, want := range []string{
+		`"openapi":"3.0.3"`,
+		`"info":{"title":"Pets","version":"1","description":"Pet store","license":{"name":"MIT","url":"https://spdx.org/licenses/MIT.html"}}`,
+		// The path item is copied, keeping the description of the reference.
+		`"/pets":{"description":"All pets.","get":{"security":[{},{"key":[]}],"responses":{"200":{"description":"ok"}}}}`,
+		`"components":{"securitySchemes":{"key":`,
+		`"Pet":{"type":"object","properties"`,
+		`"name":{"type":"string","example":"Rex","nullable":true}`,
+		`"kind":{"enum":["dog"],"type":"string"}`,
+		// The inclusive maximum is stricter than the exclusive one.
+		`"age":{"type":"integer","exclusiveMinimum":true,"maximum":30,"minimum":0}`,
+		`"id":{"anyOf":[{"type":"string"},{"type":"integer"}]}`,
+		`"owner":{"allOf":[{"$ref":"#/components/schemas/Owner"}],"nullable":true}`,
+		`"sitter":{"allOf":[{"$ref":"#/components/schemas/Owner"}],"readOnly":true,"description":"Sitter."}`,
+		`"walker":{"$ref":"#/components/schemas/Owner","description":"Walker."}`,
+		`"photo":{"type":"string","format":"byte"}`,
+		`"tags":{"type":"array","items":{"type":"string"}}`,
+		`"nothing":{"nullable":true,"enum":[null]}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+	for _, gone := range []string{"jsonSchemaDialect", "webhooks", "pathItems", "mutualTLS", "$schema", "$comment"} {
+		if strings.Contains(out, gone) {
+			t.Errorf("output still has %s:\n%s", gone, out)
+		}
+	}
+
+	wantLossy := []Note{
+		{"/webhooks", "removed 1 webhooks: newPet"},
+		{"/components/securitySchemes/mtls", "removed mutualTLS security scheme"},
+		{"/paths/~1pets/get/security/0", "removed mutualTLS security requirement mtls"},
+		{"/components/schemas/Pet/properties/name", "kept the first of 2 examples"},
+		{"/components/schemas/Pet/properties/tags", "removed prefixItems"},
+		{"/components/schemas/Pet/properties/nothing", "replaced type null by a nullable enum of null"},
+	}
+	if !reflect.DeepEqual(report.Lossy, wantLossy) {
+		t.Errorf("Lossy =\n%q\nwant\n%q", report.Lossy, wantLossy)
+	}
+	if report.Converted != 20 {
+		t.Errorf("Converted = %d, want 20", report.Converted)
+	}
+}
+
+func TestConvert_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{
+			name:    "3.0",
+			spec:    `{"openapi": "3.0.3"}`,
+			wantErr: `openapi version "3.0.3" is not 3.1`,
+		},
+		{
+			name:    "unresolved path item",
+			spec:    `{"openapi": "3.1.0", "paths": {"/pets": {"$ref": "#/components/pathItems/Pets"}}}`,
+			wantErr: "#/paths/~1pets: unresolved $ref #/components/pathItems/Pets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := specdoc.Parse([]byte(tt.spec))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Convert(spec)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Convert() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(got); !strings.Contains(out, `"openapi": "3.0.3"`) || strings.Contains(out, `"webhooks"`) {
+		t.Errorf("output is not OpenAPI 3.0:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
@@ -0,0 +1,186 @@
+// Command ogen-spec31 converts an OpenAPI 3.0 spec to OpenAPI 3.1.
+//
+// It is the reverse of ogen-spec30, for pipelines that normalize every spec
+// to 3.1 before generation or linting. Schemas are rewritten into their 3.1
+// forms:
+//
+//	{"type": "string", "nullable": true}  {"type": ["string", "null"]}
+//	{"allOf": [{"$ref": ...}], "nullable": true}
+//	                                      {"anyOf": [{"$ref": ...}, {"type": "null"}]}
+//	{"minimum": 0, "exclusiveMinimum": true}
+//	                                      {"exclusiveMinimum": 0}
+//	{"example": "Rex"}                    {"examples": ["Rex"]}
+//
+// 3.1 is a superset of 3.0 otherwise, so nothing is lost.
+//
+// Usage:
+//
+//	ogen-spec31 -o openapi.31.json openapi.json
+//
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-spec31: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-spec31", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the converted spec to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-spec31 -o <output.json> <openapi.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	stats, err := Convert(spec)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Converted %d nullable schemas, %d exclusive bounds and %d examples to OpenAPI 3.1 in %s\n",
+		stats.Nullable, stats.Bounds, stats.Examples, *outputFile)
+	return nil
+}
+
+// Version is the OpenAPI version of converted specs.
+const Version = "3.1.0"
+
+// Stats counts the schema keywords Convert rewrote.
+type Stats struct {
+	Nullable int
+	Bounds   int
+	Examples int
+}
+
+// Convert rewrites spec, an OpenAPI 3.0 document, to OpenAPI 3.1.
+func Convert(spec *specdoc.Object) (Stats, error) {
+	var stats Stats
+	version, _ := spec.Get("openapi").(string)
+	if !strings.HasPrefix(version, "3.0.") {
+		return stats, fmt.Errorf("openapi version %q is not 3.0", version)
+	}
+	spec.Set("openapi", Version)
+
+	err := specdoc.Walk(spec, func(o *specdoc.Object, ptr string) error {
+		// Parameters and media types have an example too, which 3.1 keeps.
+		if !specdoc.IsSchemaPointer(ptr, false) {
+			return nil
+		}
+		if convertNullable(o) {
+			stats.Nullable++
+		}
+		if convertExclusive(o, "exclusiveMinimum", "minimum") {
+			stats.Bounds++
+		}
+		if convertExclusive(o, "exclusiveMaximum", "maximum") {
+			stats.Bounds++
+		}
+		if o.Has("example") && !o.Has("examples") {
+			o.Replace("example", "examples", []any{o.Get("example")})
+			stats.Examples++
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// convertNullable replaces nullable by a null type: in the type array of a
+// typed schema, or as an anyOf member of a composed or referencing one.
+func convertNullable(o *specdoc.Object) bool {
+	nullable, ok := o.Get("nullable").(bool)
+	if !ok {
+		return false
+	}
+	o.Delete("nullable")
+	if !nullable {
+		return true
+	}
+
+	if enum, ok := o.Get("enum").([]any); ok && !hasNull(enum) {
+		// A nullable enum allows null in 3.0, an enum never does in 3.1.
+		o.Set("enum", append(enum, nil))
+	}
+
+	null := specdoc.NewObject()
+	null.Set("type", "null")
+
+	if t, ok := o.Get("type").(string); ok {
+		o.Set("type", []any{t, "null"})
+		return true
+	}
+	if ref := o.Get("$ref"); ref != nil {
+		member := specdoc.NewObject()
+		member.Set("$ref", ref)
+		o.Replace("$ref", "anyOf", []any{member, null})
+		return true
+	}
+	switch allOf, _ := o.Get("allOf").([]any); {
+	case len(allOf) == 1 && !o.Has("anyOf"):
+		o.Replace("allOf", "anyOf", []any{allOf[0], null})
+		return true
+	case len(allOf) > 1 && !o.Has("anyOf"):
+		group := specdoc.NewObject()
+		group.Set("allOf", allOf)
+		o.Replace("allOf", "anyOf", []any{group, null})
+		return true
+	}
+	for _, key := range []string{"anyOf", "oneOf"} {
+		if list, ok := o.Get(key).([]any); ok {
+			o.Set(key, append(list, null))
+			return true
+		}
+	}
+	// A schema without a type already allows null.
+	return true
+}
+
+func hasNull(values []any) bool {
+	for _, v := range values {
+		if v == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// convertExclusive replaces a boolean exclusive bound by the numeric form,
+// which holds the bound itself.
+func convertExclusive(o *specdoc.Object, exclusiveKey, boundKey string) bool {
+	exclusive, ok := o.Get(exclusiveKey).(bool)
+	if !ok {
+		return false
+	}
+	if exclusive && o.Has(boundKey) {
+		o.Set(exclusiveKey, o.Get(boundKey))
+		o.Delete(boundKey)
+	} else {
+		o.Delete(exclusiveKey)
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/pets": {
+      "get": {
+        "parameters": [{"name": "q", "in": "query", "example": "rex", "schema": {"type": "string", "nullable": true}}],
+        "responses": {"200": {"description": "ok", "content": {"application/json": {"example": {"name": "Rex"}, "schema": {"$ref": "#/components/schemas/Pet"}}}}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Pet": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string", "nullable": true, "example": "Rex"},
+          "kind": {"type": "string", "enum": ["cat", "dog"], "nullable": true},
+          "age": {"type": "integer", "minimum": 0, "exclusiveMinimum": true, "maximum": 30, "exclusiveMaximum": false},
+          "owner": {"allOf": [{"$ref": "#/components/schemas/Owner"}], "nullable": true},
+          "sitter": {"$ref": "#/components/schemas/Owner", "nullable": true},
+          "walker": {"allOf": [{"$ref": "#/components/schemas/Owner"}, {"required": ["name"]}], "nullable": true},
+          "id": {"oneOf": [{"type": "string"}, {"type": "integer"}], "nullable": true},
+          "any": {"nullable": true},
+          "tag": {"type": "string", "nullable": false},
+          "nullable": {"type": "boolean"}
+        }
+      },
+      "Owner": {"type": "object", "properties": {"name": {"type": "string"}}}
+    }
+  }
+}`
+
+func TestConvert(t *testing.T) {
+	spec, err := specdoc.Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := Convert(spec)
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if want := (Stats{Nullable: 9, Bounds: 2, Examples: 1}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	got, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+	for _, want := range []string{
+		`"openapi":"3.1.0"`,
+		// Parameters and media types keep their example.
+		`"example":"rex","schema":{"type":["string","null"]}`,
+		`"example":{"name":"Rex"}`,
+		`"name":{"type":["string","null"],"examples":["Rex"]}`,
+		`"kind":{"type":["string","null"],"enum":["cat","dog",null]}`,
+		`"age":{"type":"integer","exclusiveMinimum":0,"maximum":30}`,
+		`"owner":{"anyOf":[{"$ref":"#/components/schemas/Owner"},{"type":"null"}]}`,
+		`"sitter":{"anyOf":[{"$ref":"#/components/schemas/Owner"},{"type":"null"}]}`,
+		`"walker":{"anyOf":[{"allOf":[{"$ref":"#/components/schemas/Owner"},{"required":["name"]}]},{"type":"null"}]}`,
+		`"id":{"oneOf":[{"type":"string"},{"type":"integer"},{"type":"null"}]}`,
+		`"any":{}`,
+		`"tag":{"type":"string"}`,
+		// A property named nullable is not a keyword.
+		`"nullable":{"type":"boolean"}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+
+	// A second run rejects the 3.1 output.
+	if _, err := Convert(spec); err == nil || err.Error() != `openapi version "3.1.0" is not 3.0` {
+		t.Errorf("second run: err = %v, want a version error", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.31.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(got); !strings.Contains(out, `"openapi": "3.1.0"`) || strings.Contains(out, `"nullable": true`) {
+		t.Errorf("output is not OpenAPI 3.1:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
@@ -0,0 +1,470 @@
+// Command ogen-specbundle bundles an OpenAPI spec split over several files
+// into a single document for ogen.
+//
+// ogen resolves $refs to other files and URLs poorly: schemas from other
+// files get names derived from their location, and relative references
+// inside them break. This tool inlines every external $ref into the root
+// document:
+//
+//   - Schemas are added to components/schemas, under the name of the
+//     component they point to (#/components/schemas/Pet gives Pet) or of
+//     their file (pet.json gives pet). A name already taken by another schema
+//     gets a number: Pet2. References to the same schema share one component.
+//   - Parameters, responses, request bodies, headers and path items are
+//     copied in place.
+//
+// Names are assigned in document order, so the same input always gives the
+// same output.
+//
+// Usage:
+//
+//	ogen-specbundle -o openapi.bundled.json openapi.json
+//	ogen --package api --target internal/api --clean openapi.bundled.json
+//
+// References may be relative paths or http(s) URLs, to JSON or YAML files.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specbundle: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specbundle", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the bundled spec to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specbundle -o <output.json> <openapi.json>")
+	}
+
+	b, err := NewBundler(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := b.Bundle(); err != nil {
+		return err
+	}
+
+	if err := specdoc.WriteFile(*outputFile, b.Spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Bundled %d files and %d schemas into %s\n", len(b.docs)-1, b.Schemas, *outputFile)
+	return nil
+}
+
+// maxInlineDepth bounds the $refs to parameters, responses and other
+// non-schema objects that lead to each other, which would otherwise be
+// copied forever.
+const maxInlineDepth = 32
+
+// Bundler inlines the external $refs of a spec.
+type Bundler struct {
+	// Spec is the root document, bundled by Bundle.
+	Spec *specdoc.Object
+	// Schemas is the number of schemas Bundle copied from other files.
+	Schemas int
+
+	root   string
+	docs   map[string]*specdoc.Object
+	names  map[string]string // location#pointer of a schema -> component name
+	taken  map[string]bool   // component names in use
+	client *http.Client
+}
+
+// NewBundler reads the root document of a spec from a file or URL.
+func NewBundler(location string) (*Bundler, error) {
+	if !isURL(location) {
+		abs, err := filepath.Abs(location)
+		if err != nil {
+			return nil, err
+		}
+		location = abs
+	}
+	b := &Bundler{
+		root:   location,
+		docs:   make(map[string]*specdoc.Object),
+		names:  make(map[string]string),
+		taken:  make(map[string]bool),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	spec, err := b.load(location)
+	if err != nil {
+		return nil, err
+	}
+	b.Spec = spec
+	return b, nil
+}
+
+// Bundle rewrites the root document so that it has no external $refs.
+func (b *Bundler) Bundle() error {
+	// Component schemas of the root keep their names. Those that are only a
+	// reference to another file get the content of the file under the same
+	// name.
+	components, _ := b.Spec.Get("components").(*specdoc.Object)
+	schemas, _ := components.Get("schemas").(*specdoc.Object)
+	for _, name := range schemas.Keys() {
+		b.taken[name] = true
+		o, ok := schemas.Get(name).(*specdoc.Object)
+		if !ok {
+			continue
+		}
+		if ref, ok := o.Get("$ref").(string); ok && o.Len() == 1 {
+			loc, ptr, err := b.resolve(b.root, ref)
+			if err == nil && loc != b.root {
+				if _, named := b.names[loc+"#"+ptr]; !named {
+					b.names[loc+"#"+ptr] = name
+				}
+			}
+		}
+	}
+	return b.rewrite(b.Spec, b.root, false, 0)
+}
+
+// rewrite rewrites the $refs of v, a value of the document at location
+// base. schema tells whether v itself is a schema.
+func (b *Bundler) rewrite(v any, base string, schema bool, depth int) error {
+	return specdoc.Walk(v, func(o *specdoc.Object, ptr string) error {
+		if err := b.rewriteMapping(o, base, ptr); err != nil {
+			return err
+		}
+		if err := b.rewriteExamples(o, base, ptr); err != nil {
+			return err
+		}
+
+		ref, ok := o.Get("$ref").(string)
+		if !ok {
+			return nil
+		}
+		loc, target, err := b.resolve(base, ref)
+		if err != nil {
+			return fmt.Errorf("%s#%s: %w", base, ptr, err)
+		}
+		if loc == b.root {
+			o.Set("$ref", "#"+target)
+			return nil
+		}
+
+		if specdoc.IsSchemaPointer(ptr, schema) {
+			name, err := b.schema(loc, target)
+			if err != nil {
+				return fmt.Errorf("%s#%s: %w", base, ptr, err)
+			}
+			if base == b.root && ptr == specdoc.Pointer("/components/schemas", name) {
+				// The root component that only references the schema
+				// becomes the schema.
+				content, err := b.object(loc, target)
+				if err != nil {
+					return fmt.Errorf("%s#%s: %w", base, ptr, err)
+				}
+				b.Schemas++
+				return b.inline(o, loc, content, true, depth)
+			}
+			o.Set("$ref", "#"+specdoc.Pointer("/components/schemas", name))
+			return nil
+		}
+		if depth >= maxInlineDepth {
+			return fmt.Errorf("%s#%s: $ref chain too long", base, ptr)
+		}
+		content, err := b.object(loc, target)
+		if err != nil {
+			return fmt.Errorf("%s#%s: %w", base, ptr, err)
+		}
+		return b.inline(o, loc, content, false, depth+1)
+	})
+}
+
+// inline replaces o, a $ref object, by content, a copy of the object it
+// references in the document at loc, rewrites the references of the copy,
+// and tells Walk not to enter it again.
+func (b *Bundler) inline(o *specdoc.Object, loc string, content *specdoc.Object, schema bool, depth int) error {
+	o.Delete("$ref")
+	for _, key := range content.Keys() {
+		o.Set(key, content.Get(key))
+	}
+	if err := b.rewrite(o, loc, schema, depth); err != nil {
+		return err
+	}
+	return specdoc.SkipChildren
+}
+
+// schema returns the component name of the schema at loc#ptr, adding the
+// schema to components/schemas the first time.
+func (b *Bundler) schema(loc, ptr string) (string, error) {
+	key := loc + "#" + ptr
+	if name, ok := b.names[key]; ok {
+		return name, nil
+	}
+	v, err := b.lookup(loc, ptr)
+	if err != nil {
+		return "", err
+	}
+
+	name := b.newName(loc, ptr)
+	b.names[key] = name
+	b.taken[name] = true
+
+	content := specdoc.Clone(v)
+	b.componentSchemas().Set(name, content)
+	b.Schemas++
+	// The copy is rewritten relative to its own file. Its references to
+	// itself find the name registered above.
+	if err := b.rewrite(content, loc, true, 0); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// invalidName matches the characters not allowed in component names.
+var invalidName = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// newName returns an unused component name for the schema at loc#ptr: the
+// last segment of ptr, or the file name without its extension.
+func (b *Bundler) newName(loc, ptr string) string {
+	var name string
+	if i := strings.LastIndexByte(ptr, '/'); i >= 0 && i < len(ptr)-1 {
+		name = specdoc.Unescape(ptr[i+1:])
+	} else {
+		base := path.Base(loc)
+		if !isURL(loc) {
+			base = filepath.Base(loc)
+		}
+		name = strings.TrimSuffix(base, path.Ext(base))
+	}
+	name = invalidName.ReplaceAllString(name, "_")
+	if name == "" {
+		name = "Schema"
+	}
+
+	unique := name
+	for i := 2; b.taken[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	return unique
+}
+
+// componentSchemas returns components/schemas of the root document,
+// adding it if needed.
+func (b *Bundler) componentSchemas() *specdoc.Object {
+	components, ok := b.Spec.Get("components").(*specdoc.Object)
+	if !ok {
+		components = specdoc.NewObject()
+		b.Spec.Set("components", components)
+	}
+	schemas, ok := components.Get("schemas").(*specdoc.Object)
+	if !ok {
+		schemas = specdoc.NewObject()
+		components.Set("schemas", schemas)
+	}
+	return schemas
+}
+
+// isFile reports whether a discriminator mapping value names a spec file.
+func isFile(ref string) bool {
+	switch path.Ext(ref) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// rewriteMapping rewrites the discriminator mapping of a schema, whose
+// values are references to schemas without the $ref keyword.
+func (b *Bundler) rewriteMapping(o *specdoc.Object, base, ptr string) error {
+	discriminator, ok := o.Get("discriminator").(*specdoc.Object)
+	if !ok {
+		return nil
+	}
+	mapping, ok := discriminator.Get("mapping").(*specdoc.Object)
+	if !ok {
+		return nil
+	}
+	for _, value := range mapping.Keys() {
+		ref, ok := mapping.Get(value).(string)
+		if !ok || !strings.ContainsAny(ref, "#/") && !isFile(ref) {
+			// A schema name rather than a reference.
+			continue
+		}
+		loc, target, err := b.resolve(base, ref)
+		if err != nil {
+			return fmt.Errorf("%s#%s: mapping %s: %w", base, ptr, value, err)
+		}
+		if loc == b.root {
+			mapping.Set(value, "#"+target)
+			continue
+		}
+		name, err := b.schema(loc, target)
+		if err != nil {
+			return fmt.Errorf("%s#%s: mapping %s: %w", base, ptr, value, err)
+		}
+		mapping.Set(value, "#"+specdoc.Pointer("/components/schemas", name))
+	}
+	return nil
+}
+
+// rewriteExamples copies the examples of o that reference other files in
+// place. Walk doesn't enter examples, which are data.
+func (b *Bundler) rewriteExamples(o *specdoc.Object, base, ptr string) error {
+	examples, ok := o.Get("examples").(*specdoc.Object)
+	if !ok {
+		return nil
+	}
+	for _, name := range examples.Keys() {
+		example, ok := examples.Get(name).(*specdoc.Object)
+		loc := base
+		for depth := 0; ok && example.Has("$ref"); depth++ {
+			ref, _ := example.Get("$ref").(string)
+			var target string
+			var err error
+			loc, target, err = b.resolve(loc, ref)
+			if err == nil && depth >= maxInlineDepth {
+				err = fmt.Errorf("$ref chain too long")
+			}
+			if err != nil {
+				return fmt.Errorf("%s#%s: example %s: %w", base, ptr, name, err)
+			}
+			if loc == b.root {
+				example = specdoc.NewObject()
+				example.Set("$ref", "#"+target)
+				break
+			}
+			v, err := b.lookup(loc, target)
+			if err != nil {
+				return fmt.Errorf("%s#%s: example %s: %w", base, ptr, name, err)
+			}
+			example, ok = specdoc.Clone(v).(*specdoc.Object)
+		}
+		if ok {
+			examples.Set(name, example)
+		}
+	}
+	return nil
+}
+
+// resolve returns the document location and JSON pointer of a $ref found in
+// the document at base.
+func (b *Bundler) resolve(base, ref string) (loc, ptr string, err error) {
+	file, fragment, _ := strings.Cut(ref, "#")
+	ptr, err = url.PathUnescape(fragment)
+	if err != nil {
+		return "", "", fmt.Errorf("$ref %q: %w", ref, err)
+	}
+	if ptr != "" && !strings.HasPrefix(ptr, "/") {
+		return "", "", fmt.Errorf("$ref %q: only JSON pointer fragments are supported", ref)
+	}
+
+	switch {
+	case file == "":
+		loc = base
+	case isURL(file):
+		loc = file
+	case isURL(base):
+		u, err := url.Parse(base)
+		if err != nil {
+			return "", "", err
+		}
+		rel, err := url.Parse(file)
+		if err != nil {
+			return "", "", fmt.Errorf("$ref %q: %w", ref, err)
+		}
+		loc = u.ResolveReference(rel).String()
+	default:
+		name, err := url.PathUnescape(file)
+		if err != nil {
+			return "", "", fmt.Errorf("$ref %q: %w", ref, err)
+		}
+		loc = filepath.Join(filepath.Dir(base), filepath.FromSlash(name))
+	}
+	return loc, ptr, nil
+}
+
+// lookup returns the value at ptr of the document at loc.
+func (b *Bundler) lookup(loc, ptr string) (any, error) {
+	doc, err := b.load(loc)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := specdoc.Lookup(doc, ptr)
+	if !ok {
+		return nil, fmt.Errorf("unresolved $ref %s#%s", loc, ptr)
+	}
+	return v, nil
+}
+
+// object returns a copy of the object at ptr of the document at loc.
+func (b *Bundler) object(loc, ptr string) (*specdoc.Object, error) {
+	v, err := b.lookup(loc, ptr)
+	if err != nil {
+		return nil, err
+	}
+	o, ok := specdoc.Clone(v).(*specdoc.Object)
+	if !ok {
+		return nil, fmt.Errorf("%s#%s is not an object", loc, ptr)
+	}
+	return o, nil
+}
+
+// load returns the document at loc, reading it the first time.
+func (b *Bundler) load(loc string) (*specdoc.Object, error) {
+	if doc, ok := b.docs[loc]; ok {
+		return doc, nil
+	}
+	var doc *specdoc.Object
+	var err error
+	if isURL(loc) {
+		doc, err = b.fetch(loc)
+	} else {
+		doc, err = specdoc.ReadFile(loc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.docs[loc] = doc
+	return doc, nil
+}
+
+func (b *Bundler) fetch(loc string) (*specdoc.Object, error) {
+	resp, err := b.client.Get(loc) // #nosec G107 -- CLI tool, URL from the spec being bundled
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s: %s", loc, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", loc, err)
+	}
+	doc, err := specdoc.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", loc, err)
+	}
+	return doc, nil
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
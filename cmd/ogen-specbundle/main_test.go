@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testFiles is a spec split over several files.
+var testFiles = map[string]string{
+	"openapi.json": `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/pets": {"$ref": "paths/pets.json"},
+    "/pets/{id}": {
+      "get": {
+        "parameters": [{"$ref": "common.json#/components/parameters/ID"}],
+        "responses": {
+          "200": {"description": "ok", "content": {"application/json": {"schema": {"$ref": "schemas/pet.json"}}}},
+          "404": {"$ref": "common.json#/components/responses/NotFound"}
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Error": {"$ref": "common.json#/components/schemas/Error"},
+      "Local": {"type": "object"}
+    }
+  }
+}`,
+	"paths/pets.json": `{
+  "get": {
+    "responses": {
+      "200": {"description": "ok", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "../schemas/pet.json"}}}}}
+    }
+  }
+}`,
+	"schemas/pet.json": `{
+  "type": "object",
+  "properties": {
+    "parent": {"$ref": "#"},
+    "owner": {"$ref": "owner.json#/Owner"},
+    "local": {"$ref": "../openapi.json#/components/schemas/Local"}
+  },
+  "discriminator": {"propertyName": "kind", "mapping": {"dog": "dog.json", "cat": "Local"}}
+}`,
+	"schemas/owner.json": `{"Owner": {"type": "object", "properties": {"pets": {"items": {"$ref": "pet.json"}}}}}`,
+	"schemas/dog.json":   `{"allOf": [{"$ref": "pet.json"}]}`,
+	"common.json": `{
+  "components": {
+    "parameters": {"ID": {"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}},
+    "responses": {"NotFound": {"description": "not found", "content": {"application/json": {"schema": {"$ref": "schemas/error.json#/Local"}}}}},
+    "schemas": {"Error": {"type": "object", "properties": {"message": {"type": "string"}}}}
+  }
+}`,
+	"schemas/error.json": `{"Local": {"type": "string"}}`,
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func bundle(t *testing.T, location string) (string, *Bundler) {
+	t.Helper()
+	b, err := NewBundler(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Bundle(); err != nil {
+		t.Fatalf("Bundle: %v", err)
+	}
+	got, err := json.Marshal(b.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(got), b
+}
+
+func TestBundle(t *testing.T) {
+	dir := writeFiles(t, testFiles)
+	out, b := bundle(t, filepath.Join(dir, "openapi.json"))
+
+	if b.Schemas != 5 {
+		t.Errorf("Schemas = %d, want 5", b.Schemas)
+	}
+	for _, want := range []string{
+		// Path items, parameters and responses are copied in place.
+		`"/pets":{"get":{"responses":{"200":{"description":"ok","content":{"application/json":{"schema":{"type":"array","items":{"$ref":"#/components/schemas/pet"}}}}}}}}`,
+		`"parameters":[{"name":"id","in":"path","required":true,"schema":{"type":"integer"}}]`,
+		// The Local of error.json is not the Local of the root.
+		`"404":{"description":"not found","content":{"application/json":{"schema":{"$ref":"#/components/schemas/Local2"}}}}`,
+		// A root component referencing another file gets its content.
+		`"Error":{"type":"object","properties":{"message":{"type":"string"}}}`,
+		// References to the same schema share one component, and references
+		// back into the root point into the root.
+		`"pet":{"type":"object","properties":{"parent":{"$ref":"#/components/schemas/pet"},"owner":{"$ref":"#/components/schemas/Owner"},"local":{"$ref":"#/components/schemas/Local"}},` +
+			`"discriminator":{"propertyName":"kind","mapping":{"dog":"#/components/schemas/dog","cat":"Local"}}}`,
+		`"Owner":{"type":"object","properties":{"pets":{"items":{"$ref":"#/components/schemas/pet"}}}}`,
+		`"dog":{"allOf":[{"$ref":"#/components/schemas/pet"}]}`,
+		`"Local2":{"type":"string"}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+
+	// The output is the same every time.
+	if again, _ := bundle(t, filepath.Join(dir, "openapi.json")); again != out {
+		t.Errorf("second bundle differs:\n%s\nwant\n%s", again, out)
+	}
+}
+
+func TestBundle_URL(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.Dir(writeFiles(t, map[string]string{
+		"specs/openapi.json": `{"components": {"schemas": {"Pet": {"properties": {"owner": {"$ref": "owner.json"}}}}}}`,
+		"specs/owner.json":   `{"type": "object", "properties": {"name": {"type": "string"}}}`,
+	}))))
+	defer srv.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"openapi.json": `{"paths": {}, "components": {"schemas": {"Pet": {"$ref": "` + srv.URL + `/specs/openapi.json#/components/schemas/Pet"}}}}`,
+	})
+	out, _ := bundle(t, filepath.Join(dir, "openapi.json"))
+
+	want := `{"paths":{},"components":{"schemas":{"Pet":{"properties":{"owner":{"$ref":"#/components/schemas/owner"}}},"owner":{"type":"object","properties":{"name":{"type":"string"}}}}}}`
+	if out != want {
+		t.Errorf("output =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestBundle_YAML(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"openapi.yaml": "components:\n  schemas:\n    Pet:\n      $ref: pet.yaml\n",
+		"pet.yaml":     "oneOf:\n  - $ref: dog.yml\ndiscriminator:\n  propertyName: kind\n  mapping:\n    dog: dog.yml\n",
+		"dog.yml":      "type: object\n",
+	})
+	out, _ := bundle(t, filepath.Join(dir, "openapi.yaml"))
+
+	want := `{"components":{"schemas":{"Pet":{"oneOf":[{"$ref":"#/components/schemas/dog"}],"discriminator":{"propertyName":"kind","mapping":{"dog":"#/components/schemas/dog"}}},"dog":{"type":"object"}}}}`
+	if out != want {
+		t.Errorf("output =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestBundle_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "missing file",
+			files:   map[string]string{"openapi.json": `{"components": {"schemas": {"Pet": {"$ref": "pet.json"}}}}`},
+			wantErr: "read file: open {dir}/pet.json: no such file or directory",
+		},
+		{
+			name: "unresolved pointer",
+			files: map[string]string{
+				"openapi.json": `{"components": {"schemas": {"Pet": {"$ref": "pet.json#/Pet"}}}}`,
+				"pet.json":     `{"Dog": {}}`,
+			},
+			wantErr: "{dir}/openapi.json#/components/schemas/Pet: unresolved $ref {dir}/pet.json#/Pet",
+		},
+		{
+			name: "anchor",
+			files: map[string]string{
+				"openapi.json": `{"components": {"schemas": {"Pet": {"$ref": "pet.json#pet"}}}}`,
+			},
+			wantErr: `{dir}/openapi.json#/components/schemas/Pet: $ref "pet.json#pet": only JSON pointer fragments are supported`,
+		},
+		{
+			name: "loop",
+			files: map[string]string{
+				"openapi.json": `{"paths": {"/pets": {"$ref": "a.json"}}}`,
+				"a.json":       `{"$ref": "b.json"}`,
+				"b.json":       `{"$ref": "a.json"}`,
+			},
+			wantErr: "$ref chain too long",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			b, err := NewBundler(filepath.Join(dir, "openapi.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = b.Bundle()
+			wantErr := strings.ReplaceAll(tt.wantErr, "{dir}", dir)
+			if err == nil || !strings.HasSuffix(err.Error(), wantErr) {
+				t.Errorf("Bundle() error = %v, want %q", err, wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := writeFiles(t, testFiles)
+	output := filepath.Join(dir, "openapi.bundled.json")
+
+	if err := run([]string{"-o", output, filepath.Join(dir, "openapi.json")}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(got), ".json") {
+		t.Errorf("output has references to other files:\n%s", got)
+	}
+
+	// The input files are not modified.
+	for name, content := range testFiles {
+		if orig, _ := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); string(orig) != content {
+			t.Errorf("%s was modified", name)
+		}
+	}
+}
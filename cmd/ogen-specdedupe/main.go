@@ -0,0 +1,503 @@
+// Command ogen-specdedupe moves identical inline schemas of an OpenAPI spec
+// into shared components.
+//
+// ogen generates a type for every inline object, enum and sum schema, and
+// for the inline arrays of bodies it wraps, named after where it occurs:
+// ListPetsOKApplicationJSON, ListShelterPetsOKApplicationJSON, PetAddress
+// and OwnerAddress for schemas that are all the same. This tool replaces
+// identical inline schemas with a $ref to one component in
+// components/schemas, or to an existing component they are identical to:
+//
+//	"address": {"type": "object", "properties": {"street": {"type": "string"}}}
+//	"address": {"$ref": "#/components/schemas/Address"}
+//
+// Schemas are identical if they have the same keywords and values in any
+// order; with -ignore-docs, their descriptions, titles and examples may
+// differ too, and the first one is kept.
+//
+// Usage:
+//
+//	ogen-specdedupe -o openapi.ogen.json [-config dedupe.json] [-ignore-docs] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// New components are named after the first place the schema occurs: the
+// property, parameter or header name, or the operationId with Request,
+// Response or Error. The config file renames them:
+//
+//	{
+//	  "names": {"ListPetsResponse": "PetList"}
+//	}
+//
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// Config is the component name map read from the -config file.
+type Config struct {
+	// Names maps the derived name of a new component to the name it gets.
+	Names map[string]string `json:"names"`
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specdedupe: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specdedupe", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	configFile := fs.String("config", "", "JSON file with names of new components")
+	ignoreDocs := fs.Bool("ignore-docs", false, "treat schemas that differ only in descriptions, titles and examples as identical")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specdedupe -o <output.json> [-config dedupe.json] [-ignore-docs] <openapi.json>")
+	}
+
+	var cfg Config
+	if *configFile != "" {
+		var err error
+		if cfg, err = loadConfig(*configFile); err != nil {
+			return err
+		}
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Dedupe(spec, cfg, *ignoreDocs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	replaced := 0
+	for _, m := range report.Moved {
+		var refs []string
+		for _, ptr := range m.Pointers {
+			refs = append(refs, "#"+ptr)
+		}
+		fmt.Fprintf(os.Stderr, "ogen-specdedupe: %s: replaced %s\n", m.Name, strings.Join(refs, ", "))
+		replaced += len(m.Pointers)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Replaced %d inline schemas with $refs to %d components in %s\n", replaced, len(report.Moved), *outputFile)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Report lists what Dedupe changed.
+type Report struct {
+	Moved []Move
+}
+
+// Move is a component that replaced identical inline schemas.
+type Move struct {
+	// Name is the name of the component in components/schemas.
+	Name string
+	// Existing tells whether the component was in the spec already.
+	Existing bool
+	// Pointers are the JSON pointers of the replaced schemas, as they were
+	// when they were replaced.
+	Pointers []string
+}
+
+// docKeys are the schema keywords -ignore-docs leaves out of the comparison.
+var docKeys = map[string]bool{
+	"description": true,
+	"title":       true,
+	"example":     true,
+	"examples":    true,
+}
+
+// nameMapKeys hold maps from names to schemas, whose keys are not keywords.
+var nameMapKeys = map[string]bool{
+	"properties":        true,
+	"patternProperties": true,
+	"dependentSchemas":  true,
+	"$defs":             true,
+	"definitions":       true,
+}
+
+// deduper holds the state of Dedupe.
+type deduper struct {
+	spec       *specdoc.Object
+	schemas    *specdoc.Object
+	ignoreDocs bool
+}
+
+// Dedupe replaces the inline schemas of spec that are identical to another
+// inline schema, or to a schema of components/schemas, with a $ref to one
+// component, adding it to components/schemas if needed. Outer schemas are
+// replaced first; schemas nested in them are compared again once the outer
+// ones are components. It returns an error for a config name that no new
+// component has.
+func Dedupe(spec *specdoc.Object, cfg Config, ignoreDocs bool) (*Report, error) {
+	d := &deduper{spec: spec, ignoreDocs: ignoreDocs}
+	components, _ := spec.Get("components").(*specdoc.Object)
+	d.schemas, _ = components.Get("schemas").(*specdoc.Object)
+
+	report := &Report{}
+	used := make(map[string]bool)
+	for {
+		existing := make(map[string]string)
+		for _, name := range d.schemas.Keys() {
+			form := d.form(d.schemas.Get(name))
+			if _, ok := existing[form]; !ok {
+				existing[form] = name
+			}
+		}
+
+		groups := d.collect()
+		// Outer schemas first, then in document order.
+		sort.SliceStable(groups, func(i, j int) bool {
+			return depth(groups[i].ptrs[0]) < depth(groups[j].ptrs[0])
+		})
+
+		var replaced []string
+		within := func(ptr string) bool {
+			for _, outer := range replaced {
+				if strings.HasPrefix(ptr, outer+"/") {
+					return true
+				}
+			}
+			return false
+		}
+		for _, g := range groups {
+			name, ok := existing[g.form]
+			if !ok && len(g.ptrs) < 2 {
+				continue
+			}
+			if anyOf(g.ptrs, within) {
+				// Moved with an outer schema; compared again next round.
+				continue
+			}
+
+			first, _ := specdoc.Lookup(spec, g.ptrs[0])
+			if !ok {
+				name = d.name(g.ptrs[0])
+				if renamed, ok := cfg.Names[name]; ok {
+					used[name] = true
+					name = renamed
+				}
+				name = d.unique(name)
+				d.addSchema(name, first)
+			}
+			for _, ptr := range g.ptrs {
+				ref := specdoc.NewObject()
+				ref.Set("$ref", "#/components/schemas/"+specdoc.Escape(name))
+				replace(spec, ptr, ref)
+			}
+			replaced = append(replaced, g.ptrs...)
+			report.Moved = append(report.Moved, Move{Name: name, Existing: ok, Pointers: g.ptrs})
+		}
+		if len(replaced) == 0 {
+			break
+		}
+	}
+
+	for name := range cfg.Names {
+		if !used[name] {
+			return nil, fmt.Errorf("config: no new component %s", name)
+		}
+	}
+	return report, nil
+}
+
+// group is the locations of inline schemas with the same form.
+type group struct {
+	form string
+	ptrs []string
+}
+
+// collect returns the inline schemas ogen generates a type for, grouped by
+// their form, in document order.
+func (d *deduper) collect() []*group {
+	var groups []*group
+	byForm := make(map[string]*group)
+	// Walk only returns the errors of its function, which has none.
+	_ = specdoc.Walk(d.spec, func(o *specdoc.Object, ptr string) error {
+		if !specdoc.IsSchemaPointer(ptr, false) || specdoc.IsComponentSchema(ptr) || !d.isNamed(o, ptr) {
+			return nil
+		}
+		form := d.form(o)
+		g, ok := byForm[form]
+		if !ok {
+			g = &group{form: form}
+			byForm[form] = g
+			groups = append(groups, g)
+		}
+		g.ptrs = append(g.ptrs, ptr)
+		return nil
+	})
+	return groups
+}
+
+// isNamed reports whether ogen generates a named type for the inline schema
+// o at ptr: objects, enums and sum types anywhere, and the arrays of a body
+// with several media types or of an operation with several responses, which
+// ogen wraps in types such as ListPetsOKApplicationJSON.
+//
+// Nullable free-form objects are left inline: ogen names the optional type
+// of a $ref to one after the component, OptBias, for each use, and fails
+// with an anonymous type name conflict.
+func (d *deduper) isNamed(o *specdoc.Object, ptr string) bool {
+	if o.Has("$ref") || isNullableFreeForm(o) {
+		return false
+	}
+	for _, key := range []string{"properties", "additionalProperties", "enum", "oneOf", "anyOf", "allOf"} {
+		if o.Has(key) {
+			return true
+		}
+	}
+	switch o.Get("type") {
+	case "object":
+		return true
+	case "array":
+		parts := specdoc.SplitPointer(ptr)
+		n := len(parts)
+		if n < 3 || parts[n-1] != "schema" || parts[n-3] != "content" {
+			return false
+		}
+		content := specdoc.Parent(specdoc.Parent(ptr))
+		if o, ok := specdoc.LookupObject(d.spec, content); ok && o.Len() > 1 {
+			return true
+		}
+		if n >= 5 && parts[n-5] == "responses" {
+			o, ok := specdoc.LookupObject(d.spec, specdoc.Parent(specdoc.Parent(content)))
+			return ok && o.Len() > 1
+		}
+	}
+	return false
+}
+
+// isNullableFreeForm reports whether o is a nullable object schema with no
+// properties and any additional properties.
+func isNullableFreeForm(o *specdoc.Object) bool {
+	if o.Get("nullable") != true || o.Get("type") != "object" {
+		return false
+	}
+	for _, key := range []string{"properties", "patternProperties", "enum", "oneOf", "anyOf", "allOf"} {
+		if o.Has(key) {
+			return false
+		}
+	}
+	switch v := o.Get("additionalProperties").(type) {
+	case nil, bool:
+		return v != false
+	case *specdoc.Object:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// form returns the JSON of a schema with its keys sorted, which is the same
+// for identical schemas.
+func (d *deduper) form(v any) string {
+	data, _ := json.Marshal(d.canonical(v, true))
+	return string(data)
+}
+
+// canonical returns v as maps, which encoding/json writes with sorted keys,
+// leaving out the documentation keywords of schemas with -ignore-docs.
+func (d *deduper) canonical(v any, schema bool) any {
+	switch v := v.(type) {
+	case *specdoc.Object:
+		m := make(map[string]any, v.Len())
+		for _, key := range v.Keys() {
+			if schema && d.ignoreDocs && docKeys[key] {
+				continue
+			}
+			switch {
+			case !schema:
+				m[key] = d.canonical(v.Get(key), false)
+			case nameMapKeys[key]:
+				names, ok := v.Get(key).(*specdoc.Object)
+				if !ok {
+					m[key] = d.canonical(v.Get(key), false)
+					continue
+				}
+				mm := make(map[string]any, names.Len())
+				for _, name := range names.Keys() {
+					mm[name] = d.canonical(names.Get(name), true)
+				}
+				m[key] = mm
+			default:
+				m[key] = d.canonical(v.Get(key), !isDataKey(key))
+			}
+		}
+		return m
+	case []any:
+		list := make([]any, len(v))
+		for i, elem := range v {
+			list[i] = d.canonical(elem, schema)
+		}
+		return list
+	}
+	return v
+}
+
+// isDataKey reports whether the value of a schema keyword is data rather
+// than schemas.
+func isDataKey(key string) bool {
+	switch key {
+	case "enum", "const", "default", "example", "examples", "discriminator", "xml", "externalDocs", "required", "type":
+		return true
+	}
+	return strings.HasPrefix(key, "x-")
+}
+
+// name derives the component name of the inline schema at ptr.
+func (d *deduper) name(ptr string) string {
+	parts := specdoc.SplitPointer(ptr)
+	n := len(parts)
+	switch {
+	case n == 0:
+		return "Schema"
+	case n >= 2 && nameMapKeys[parts[n-2]]:
+		return specdoc.Pascal(parts[n-1])
+	case n == 3 && parts[0] == "components" && parts[1] == "schemas":
+		return parts[2]
+	case n >= 2 && (parts[n-2] == "oneOf" || parts[n-2] == "anyOf" || parts[n-2] == "allOf"):
+		return d.name(specdoc.Parent(specdoc.Parent(ptr))) + "Variant"
+	case parts[n-1] == "items":
+		return d.name(specdoc.Parent(ptr)) + "Item"
+	case parts[n-1] == "additionalProperties":
+		return d.name(specdoc.Parent(ptr)) + "Value"
+	case parts[n-1] != "schema":
+		return "Schema"
+	}
+
+	// The schema of a parameter, header or media type.
+	owner := specdoc.Parent(ptr)
+	if n >= 3 && parts[n-3] == "content" {
+		owner = specdoc.Parent(specdoc.Parent(specdoc.Parent(ptr)))
+	}
+	ownerParts := specdoc.SplitPointer(owner)
+	m := len(ownerParts)
+	switch {
+	case m == 3 && ownerParts[0] == "components":
+		return specdoc.Pascal(ownerParts[2])
+	case m >= 2 && ownerParts[m-2] == "headers":
+		return specdoc.Pascal(ownerParts[m-1])
+	case m >= 1 && ownerParts[m-1] == "requestBody":
+		return d.operationName(specdoc.Parent(owner)) + "Request"
+	case m >= 2 && ownerParts[m-2] == "responses" && strings.HasPrefix(ownerParts[m-1], "2"):
+		return d.operationName(specdoc.Parent(specdoc.Parent(owner))) + "Response"
+	case m >= 2 && ownerParts[m-2] == "responses":
+		return d.operationName(specdoc.Parent(specdoc.Parent(owner))) + "Error"
+	}
+	if param, ok := specdoc.LookupObject(d.spec, owner); ok {
+		if name, ok := param.Get("name").(string); ok && param.Has("in") {
+			return specdoc.Pascal(name)
+		}
+	}
+	return "Schema"
+}
+
+// operationName returns the pascal case operationId of the operation at
+// ptr, or the words of its path and method.
+func (d *deduper) operationName(ptr string) string {
+	if op, ok := specdoc.LookupObject(d.spec, ptr); ok {
+		if id, ok := op.Get("operationId").(string); ok && id != "" {
+			return specdoc.Pascal(id)
+		}
+	}
+	parts := specdoc.SplitPointer(ptr)
+	if len(parts) == 3 && parts[0] == "paths" {
+		return specdoc.Pascal(parts[1] + " " + parts[2])
+	}
+	return "Operation"
+}
+
+// unique returns name, or name with the first free numeric suffix if a
+// component has the same Go name.
+func (d *deduper) unique(name string) string {
+	taken := make(map[string]bool)
+	for _, key := range d.schemas.Keys() {
+		taken[specdoc.Normalize(key)] = true
+	}
+	unique := name
+	for i := 2; taken[specdoc.Normalize(unique)]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	return unique
+}
+
+// addSchema adds the component schema name, creating components/schemas if
+// needed.
+func (d *deduper) addSchema(name string, schema any) {
+	if d.schemas == nil {
+		components, ok := d.spec.Get("components").(*specdoc.Object)
+		if !ok {
+			components = specdoc.NewObject()
+			d.spec.Set("components", components)
+		}
+		d.schemas = specdoc.NewObject()
+		components.Set("schemas", d.schemas)
+	}
+	d.schemas.Set(name, schema)
+}
+
+// replace sets the value at ptr of spec to v.
+func replace(spec *specdoc.Object, ptr string, v any) {
+	parts := specdoc.SplitPointer(ptr)
+	key := parts[len(parts)-1]
+	switch c := lookup(spec, specdoc.Parent(ptr)).(type) {
+	case *specdoc.Object:
+		c.Set(key, v)
+	case []any:
+		i, _ := strconv.Atoi(key)
+		c[i] = v
+	}
+}
+
+func lookup(spec *specdoc.Object, ptr string) any {
+	v, _ := specdoc.Lookup(spec, ptr)
+	return v
+}
+
+func depth(ptr string) int {
+	return strings.Count(ptr, "/")
+}
+
+func anyOf(ptrs []string, fn func(string) bool) bool {
+	for _, ptr := range ptrs {
+		if fn(ptr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/pets": {
+      "get": {
+        "operationId": "listPets",
+        "parameters": [{"name": "status", "in": "query", "schema": {"type": "string", "enum": ["available", "sold"]}}],
+        "responses": {
+          "200": {"description": "ok", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}}}}},
+          "404": {"description": "not found", "content": {"application/json": {"schema": {"type": "object", "properties": {"message": {"type": "string"}}}}}}
+        }
+      }
+    },
+    "/shelters/{id}/pets": {
+      "get": {
+        "operationId": "listShelterPets",
+        "parameters": [{"name": "status", "in": "query", "schema": {"enum": ["available", "sold"], "type": "string"}}],
+        "responses": {
+          "200": {"description": "ok", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}}}}},
+          "404": {"description": "not found", "content": {"application/json": {"schema": {"type": "object", "properties": {"message": {"type": "string"}}}}}}
+        }
+      }
+    },
+    "/owners": {
+      "get": {
+        "operationId": "listOwners",
+        "responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"type": "array", "items": {
+          "type": "object",
+          "properties": {"name": {"type": "string"}, "address": {"type": "object", "properties": {"street": {"type": "string"}}}}
+        }}}}}}
+      }
+    },
+    "/tags": {
+      "get": {
+        "operationId": "listTags",
+        "responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}}}}}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Owner": {
+        "type": "object",
+        "properties": {"name": {"type": "string"}, "address": {"type": "object", "properties": {"street": {"type": "string"}}}}
+      },
+      "Pet": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "home": {"type": "object", "description": "Where it lives", "properties": {"street": {"type": "string"}}},
+          "tags": {"type": "array", "items": {"type": "string"}}
+        }
+      },
+      "Status": {"type": "string"}
+    }
+  }
+}`
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func get(t *testing.T, spec *specdoc.Object, ptr string) string {
+	t.Helper()
+	v, ok := specdoc.Lookup(spec, ptr)
+	if !ok {
+		t.Fatalf("no value at %s", ptr)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDedupe(t *testing.T) {
+	spec := parse(t, testSpec)
+	report, err := Dedupe(spec, Config{}, false)
+	if err != nil {
+		t.Fatalf("Dedupe: %v", err)
+	}
+
+	var moved []string
+	for _, m := range report.Moved {
+		moved = append(moved, m.Name+": "+strings.Join(m.Pointers, " "))
+	}
+	want := []string{
+		// Status is taken by another schema.
+		"Status2: /paths/~1pets/get/parameters/0/schema /paths/~1shelters~1{id}~1pets/get/parameters/0/schema",
+		// Wrapped in ListPetsOKApplicationJSON types, but not in listTags,
+		// which has one response.
+		"ListPetsResponse: /paths/~1pets/get/responses/200/content/application~1json/schema /paths/~1shelters~1{id}~1pets/get/responses/200/content/application~1json/schema",
+		"ListPetsError: /paths/~1pets/get/responses/404/content/application~1json/schema /paths/~1shelters~1{id}~1pets/get/responses/404/content/application~1json/schema",
+		// Its address is not hoisted: the copy in listOwners went with it.
+		"Owner: /paths/~1owners/get/responses/200/content/application~1json/schema/items",
+	}
+	if !reflect.DeepEqual(moved, want) {
+		t.Errorf("moved:\n%s\nwant:\n%s", strings.Join(moved, "\n"), strings.Join(want, "\n"))
+	}
+	if !report.Moved[3].Existing || report.Moved[0].Existing {
+		t.Errorf("Existing = %v, %v, want Owner only", report.Moved[0].Existing, report.Moved[3].Existing)
+	}
+
+	for ptr, want := range map[string]string{
+		"/paths/~1owners/get/responses/200/content/application~1json/schema": `{"type":"array","items":{"$ref":"#/components/schemas/Owner"}}`,
+		"/paths/~1tags/get/responses/200/content/application~1json/schema":   `{"type":"array","items":{"$ref":"#/components/schemas/Pet"}}`,
+		"/components/schemas/Owner/properties/address":                       `{"type":"object","properties":{"street":{"type":"string"}}}`,
+		"/components/schemas/Status2":                                        `{"type":"string","enum":["available","sold"]}`,
+		// The description differs.
+		"/components/schemas/Pet/properties/home": `{"type":"object","description":"Where it lives","properties":{"street":{"type":"string"}}}`,
+	} {
+		if got := get(t, spec, ptr); got != want {
+			t.Errorf("%s = %s, want %s", ptr, got, want)
+		}
+	}
+}
+
+func TestDedupe_IgnoreDocs(t *testing.T) {
+	spec := parse(t, testSpec)
+	cfg := Config{Names: map[string]string{"ListPetsResponse": "PetList"}}
+	report, err := Dedupe(spec, cfg, true)
+	if err != nil {
+		t.Fatalf("Dedupe: %v", err)
+	}
+
+	last := report.Moved[len(report.Moved)-1]
+	wantPointers := []string{"/components/schemas/Owner/properties/address", "/components/schemas/Pet/properties/home"}
+	if last.Name != "Address" || !reflect.DeepEqual(last.Pointers, wantPointers) {
+		t.Errorf("last move = %+v, want Address at %v", last, wantPointers)
+	}
+	if got := get(t, spec, "/paths/~1pets/get/responses/200/content/application~1json/schema"); got != `{"$ref":"#/components/schemas/PetList"}` {
+		t.Errorf("renamed component not used: %s", got)
+	}
+}
+
+func TestDedupe_NullableFreeForm(t *testing.T) {
+	// ogen fails on a $ref to a nullable free-form object used twice, with
+	// "anonymous type name conflict: OptBias".
+	spec := parse(t, `{
+	  "openapi": "3.0.3",
+	  "components": {
+	    "schemas": {
+	      "A": {"type": "object", "properties": {"bias": {"type": "object", "nullable": true}, "meta": {"type": "object"}}},
+	      "B": {"type": "object", "properties": {"bias": {"type": "object", "nullable": true}, "meta": {"type": "object"}}}
+	    }
+	  }
+	}`)
+	report, err := Dedupe(spec, Config{}, false)
+	if err != nil {
+		t.Fatalf("Dedupe: %v", err)
+	}
+
+	if len(report.Moved) != 1 || report.Moved[0].Name != "Meta" {
+		t.Errorf("moved = %+v, want Meta only", report.Moved)
+	}
+	for _, ptr := range []string{"/components/schemas/A/properties/bias", "/components/schemas/B/properties/bias"} {
+		if got, want := get(t, spec, ptr), `{"type":"object","nullable":true}`; got != want {
+			t.Errorf("%s = %s, want %s", ptr, got, want)
+		}
+	}
+}
+
+func TestDedupe_Errors(t *testing.T) {
+	cfg := Config{Names: map[string]string{"Pets": "PetList"}}
+	_, err := Dedupe(parse(t, testSpec), cfg, false)
+	if want := "config: no new component Pets"; err == nil || err.Error() != want {
+		t.Errorf("Dedupe() error = %v, want %q", err, want)
+	}
+}
+
+func TestName(t *testing.T) {
+	d := &deduper{spec: parse(t, `{
+	  "paths": {"/pets/{id}": {"put": {"parameters": [{"name": "sort-order", "in": "query"}]}}},
+	  "components": {"responses": {"NotFound": {}}, "headers": {"X-Rate-Limit": {}}}
+	}`)}
+	tests := []struct {
+		ptr  string
+		want string
+	}{
+		{"/components/schemas/Pet/properties/home_address", "HomeAddress"},
+		{"/components/schemas/Pet/properties/tags/items", "TagsItem"},
+		{"/components/schemas/Pet/properties/labels/additionalProperties", "LabelsValue"},
+		{"/components/schemas/Shape/oneOf/1", "ShapeVariant"},
+		{"/paths/~1pets~1{id}/put/parameters/0/schema", "SortOrder"},
+		{"/paths/~1pets~1{id}/put/requestBody/content/application~1json/schema", "PetsIdPutRequest"},
+		{"/paths/~1pets~1{id}/put/responses/default/content/application~1json/schema", "PetsIdPutError"},
+		{"/components/responses/NotFound/content/application~1json/schema", "NotFound"},
+		{"/components/headers/X-Rate-Limit/schema", "XRateLimit"},
+	}
+	for _, tt := range tests {
+		if got := d.name(tt.ptr); got != tt.want {
+			t.Errorf("name(%s) = %s, want %s", tt.ptr, got, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `"Status": {`+"\n"+`        "type": "string"`+"\n"+`      },`+"\n"+`      "Status2": {`) {
+		t.Errorf("new components not added after the existing ones:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
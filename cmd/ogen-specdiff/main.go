@@ -0,0 +1,642 @@
+// Command ogen-specdiff reports the changes between two versions of an
+// OpenAPI spec, and which of them break the Go API ogen generates.
+//
+// A new vendor spec regenerates a client whose callers may no longer
+// compile, or send requests the server now rejects. This tool compares the
+// operations, parameters, request bodies, responses and schemas of the two
+// specs, and prints each change with its location and whether it breaks
+// callers of the generated code:
+//
+//	#/paths/~1pets/get: breaking: removed operation GET /pets (listPets)
+//	#/components/schemas/Pet/properties/tag: breaking: removed property tag
+//	#/components/schemas/Status: breaking: removed enum values "sold"
+//	#/components/schemas/Pet/properties/age: compatible: added optional property age
+//
+// Breaking changes are those that remove or rename Go identifiers, change
+// Go types, such as OptString becoming string, or add a value callers must
+// set: a required parameter, or a required property of a schema used in
+// requests.
+//
+// Usage:
+//
+//	ogen-specdiff [-fail-on breaking|any|none] old.yaml new.yaml
+//
+// The exit status is 1 if there are changes of the -fail-on kind, breaking
+// ones by default, so the diff can gate CI.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specdiff: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specdiff", flag.ContinueOnError)
+	failOn := fs.String("fail-on", "breaking", "changes that fail: breaking, any or none")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: ogen-specdiff [-fail-on breaking|any|none] <old-spec> <new-spec>")
+	}
+	if !slices.Contains([]string{"breaking", "any", "none"}, *failOn) {
+		return fmt.Errorf("-fail-on: unknown value %q", *failOn)
+	}
+
+	oldFile, newFile := fs.Arg(0), fs.Arg(1)
+	oldSpec, err := specdoc.ReadFile(oldFile)
+	if err != nil {
+		return err
+	}
+	newSpec, err := specdoc.ReadFile(newFile)
+	if err != nil {
+		return err
+	}
+
+	changes := Diff(oldSpec, newSpec)
+	breaking := 0
+	for _, c := range changes {
+		kind := "compatible"
+		if c.Breaking {
+			kind = "breaking"
+			breaking++
+		}
+		fmt.Printf("#%s: %s: %s\n", c.Pointer, kind, c.Message)
+	}
+	fmt.Printf("%d breaking and %d compatible changes from %s to %s\n", breaking, len(changes)-breaking, oldFile, newFile)
+
+	switch {
+	case *failOn == "breaking" && breaking > 0:
+		return fmt.Errorf("%d breaking changes", breaking)
+	case *failOn == "any" && len(changes) > 0:
+		return fmt.Errorf("%d changes", len(changes))
+	}
+	return nil
+}
+
+// Change is a difference between two specs.
+type Change struct {
+	// Pointer locates the change: in the new spec, or in the old one for
+	// removals.
+	Pointer string
+	// Breaking reports whether the change breaks callers of the generated
+	// code.
+	Breaking bool
+	Message  string
+}
+
+type differ struct {
+	old, new *specdoc.Object
+	// requests holds the names of the schemas of the new spec that
+	// requests use, directly or through other schemas.
+	requests map[string]bool
+	changes  []Change
+}
+
+// Diff returns the changes from old to new: of operations, matched by
+// method and path, or by operationId if their path changed, and of
+// component schemas, matched by name.
+func Diff(old, new *specdoc.Object) []Change {
+	d := &differ{old: old, new: new, requests: requestSchemas(new)}
+	d.diffOperations()
+	d.diffSchemas()
+	return d.changes
+}
+
+// add records a change, once: the parameters of a path item are compared
+// for each of its operations.
+func (d *differ) add(ptr string, breaking bool, format string, args ...any) {
+	c := Change{Pointer: ptr, Breaking: breaking, Message: fmt.Sprintf(format, args...)}
+	if !slices.Contains(d.changes, c) {
+		d.changes = append(d.changes, c)
+	}
+}
+
+// operation is an operation with the path item it is in.
+type operation struct {
+	path, method string
+	ptr          string
+	op, item     *specdoc.Object
+}
+
+func (o operation) id() string {
+	id, _ := o.op.Get("operationId").(string)
+	return id
+}
+
+func (o operation) String() string {
+	s := strings.ToUpper(o.method) + " " + o.path
+	if id := o.id(); id != "" {
+		s += " (" + id + ")"
+	}
+	return s
+}
+
+// operations returns the operations of spec, keyed by method and path
+// template, in order.
+func operations(spec *specdoc.Object) ([]string, map[string]operation) {
+	var keys []string
+	ops := map[string]operation{}
+	paths, _ := spec.Get("paths").(*specdoc.Object)
+	for _, path := range paths.Keys() {
+		item, _ := paths.Get(path).(*specdoc.Object)
+		for _, method := range item.Keys() {
+			op, ok := item.Get(method).(*specdoc.Object)
+			if !ok || !specdoc.IsMethod(method) {
+				continue
+			}
+			key := method + " " + template(path)
+			keys = append(keys, key)
+			ops[key] = operation{
+				path:   path,
+				method: method,
+				ptr:    specdoc.Pointer(specdoc.Pointer("/paths", path), method),
+				op:     op,
+				item:   item,
+			}
+		}
+	}
+	return keys, ops
+}
+
+func (d *differ) diffOperations() {
+	oldKeys, oldOps := operations(d.old)
+	newKeys, newOps := operations(d.new)
+
+	// Operations whose path or method changed are matched by operationId.
+	moved := map[string]string{}
+	newByID := map[string]string{}
+	for _, key := range newKeys {
+		if id := newOps[key].id(); id != "" {
+			newByID[id] = key
+		}
+	}
+	for _, key := range oldKeys {
+		if _, ok := newOps[key]; ok {
+			continue
+		}
+		if newKey, ok := newByID[oldOps[key].id()]; ok {
+			if _, ok := oldOps[newKey]; !ok {
+				moved[newKey] = key
+				continue
+			}
+		}
+		d.add(oldOps[key].ptr, true, "removed operation %s", oldOps[key])
+	}
+
+	for _, key := range newKeys {
+		n := newOps[key]
+		o, ok := oldOps[key]
+		if oldKey, isMoved := moved[key]; isMoved {
+			o = oldOps[oldKey]
+			d.add(n.ptr, false, "moved operation %s from %s %s", n.id(), strings.ToUpper(o.method), o.path)
+		} else if !ok {
+			d.add(n.ptr, false, "added operation %s", n)
+			continue
+		}
+		d.diffOperation(o, n)
+	}
+}
+
+func (d *differ) diffOperation(o, n operation) {
+	if oldID, newID := o.id(), n.id(); oldID != newID {
+		d.add(n.ptr+"/operationId", true, "operationId changed from %q to %q, which renames its Go method", oldID, newID)
+	}
+	d.diffParameters(o, n)
+	d.diffRequestBody(o, n)
+	d.diffResponses(o, n)
+}
+
+// parameter is a parameter of an operation, with its location.
+type parameter struct {
+	ptr string
+	p   *specdoc.Object
+}
+
+// parameters returns the parameters of an operation and its path item,
+// resolved and keyed by location and name, in order.
+func parameters(spec *specdoc.Object, o operation) ([]string, map[string]parameter) {
+	var keys []string
+	params := map[string]parameter{}
+	for _, src := range []struct {
+		obj *specdoc.Object
+		ptr string
+	}{
+		{o.item, specdoc.Pointer("/paths", o.path)},
+		{o.op, o.ptr},
+	} {
+		list, _ := src.obj.Get("parameters").([]any)
+		for i, v := range list {
+			p := resolve(spec, v)
+			name, _ := p.Get("name").(string)
+			in, _ := p.Get("in").(string)
+			key := in + " " + name
+			if _, ok := params[key]; !ok {
+				keys = append(keys, key)
+			}
+			// Operation parameters override those of the path item.
+			params[key] = parameter{ptr: fmt.Sprintf("%s/parameters/%d", src.ptr, i), p: p}
+		}
+	}
+	return keys, params
+}
+
+// paramName returns the parameter of a key of parameters for messages, such
+// as "query parameter limit".
+func paramName(key string) string {
+	in, name, _ := strings.Cut(key, " ")
+	return in + " parameter " + name
+}
+
+func (d *differ) diffParameters(o, n operation) {
+	oldKeys, oldParams := parameters(d.old, o)
+	newKeys, newParams := parameters(d.new, n)
+	for _, key := range oldKeys {
+		if _, ok := newParams[key]; !ok {
+			d.add(oldParams[key].ptr, true, "removed %s", paramName(key))
+		}
+	}
+	for _, key := range newKeys {
+		np := newParams[key]
+		required := np.p.Get("required") == true
+		op, ok := oldParams[key]
+		if !ok {
+			if required {
+				d.add(np.ptr, true, "added required %s", paramName(key))
+			} else {
+				d.add(np.ptr, false, "added optional %s", paramName(key))
+			}
+			continue
+		}
+		if wasRequired := op.p.Get("required") == true; wasRequired != required {
+			d.add(np.ptr, true, "%s is now %s", paramName(key), requiredWord(required))
+		}
+		d.diffSchema(op.p.Get("schema"), np.p.Get("schema"), np.ptr+"/schema", true)
+	}
+}
+
+func (d *differ) diffRequestBody(o, n operation) {
+	oldBody := resolve(d.old, o.op.Get("requestBody"))
+	newBody := resolve(d.new, n.op.Get("requestBody"))
+	ptr := n.ptr + "/requestBody"
+	switch {
+	case oldBody == nil && newBody == nil:
+		return
+	case newBody == nil:
+		d.add(o.ptr+"/requestBody", true, "removed request body")
+		return
+	case oldBody == nil:
+		// ogen adds an argument to the method either way.
+		d.add(ptr, true, "added %s request body", requiredWord(newBody.Get("required") == true))
+		return
+	}
+	if wasRequired, required := oldBody.Get("required") == true, newBody.Get("required") == true; wasRequired != required {
+		d.add(ptr, true, "request body is now %s", requiredWord(required))
+	}
+
+	oldContent, _ := oldBody.Get("content").(*specdoc.Object)
+	newContent, _ := newBody.Get("content").(*specdoc.Object)
+	if oldTypes, newTypes := oldContent.Keys(), newContent.Keys(); !slices.Equal(sorted(oldTypes), sorted(newTypes)) {
+		// The request type of the method depends on the media types.
+		d.add(ptr+"/content", true, "request media types changed from %s to %s", strings.Join(oldTypes, ", "), strings.Join(newTypes, ", "))
+	}
+	d.diffContent(oldContent, newContent, ptr+"/content", true)
+}
+
+func (d *differ) diffResponses(o, n operation) {
+	oldResponses, _ := o.op.Get("responses").(*specdoc.Object)
+	newResponses, _ := n.op.Get("responses").(*specdoc.Object)
+	for _, code := range oldResponses.Keys() {
+		if !newResponses.Has(code) {
+			d.add(specdoc.Pointer(o.ptr+"/responses", code), true, "removed %s response", code)
+		}
+	}
+	for _, code := range newResponses.Keys() {
+		ptr := specdoc.Pointer(n.ptr+"/responses", code)
+		if !oldResponses.Has(code) {
+			d.add(ptr, false, "added %s response", code)
+			continue
+		}
+		oldContent, _ := resolve(d.old, oldResponses.Get(code)).Get("content").(*specdoc.Object)
+		newContent, _ := resolve(d.new, newResponses.Get(code)).Get("content").(*specdoc.Object)
+		for _, t := range oldContent.Keys() {
+			if !newContent.Has(t) {
+				d.add(specdoc.Pointer(ptr+"/content", t), true, "removed %s content", t)
+			}
+		}
+		for _, t := range newContent.Keys() {
+			if !oldContent.Has(t) {
+				d.add(specdoc.Pointer(ptr+"/content", t), false, "added %s content", t)
+			}
+		}
+		d.diffContent(oldContent, newContent, ptr+"/content", false)
+	}
+}
+
+// diffContent compares the schemas of the media types of both content
+// objects.
+func (d *differ) diffContent(oldContent, newContent *specdoc.Object, ptr string, request bool) {
+	for _, t := range newContent.Keys() {
+		oldMedia, _ := oldContent.Get(t).(*specdoc.Object)
+		newMedia, _ := newContent.Get(t).(*specdoc.Object)
+		if oldMedia != nil {
+			d.diffSchema(oldMedia.Get("schema"), newMedia.Get("schema"), specdoc.Pointer(ptr, t)+"/schema", request)
+		}
+	}
+}
+
+func (d *differ) diffSchemas() {
+	oldSchemas := schemas(d.old)
+	newSchemas := schemas(d.new)
+	for _, name := range oldSchemas.Keys() {
+		if !newSchemas.Has(name) {
+			d.add(specdoc.Pointer("/components/schemas", name), true, "removed schema %s", name)
+		}
+	}
+	for _, name := range newSchemas.Keys() {
+		ptr := specdoc.Pointer("/components/schemas", name)
+		if !oldSchemas.Has(name) {
+			d.add(ptr, false, "added schema %s", name)
+			continue
+		}
+		d.diffSchema(oldSchemas.Get(name), newSchemas.Get(name), ptr, d.requests[name])
+	}
+}
+
+// diffSchema compares two schemas at ptr. References are compared by
+// target: the component schemas are compared by diffSchemas. request
+// reports whether requests use the schema, where new required properties
+// break callers.
+func (d *differ) diffSchema(oldV, newV any, ptr string, request bool) {
+	o, _ := oldV.(*specdoc.Object)
+	n, _ := newV.(*specdoc.Object)
+	switch {
+	case o == nil && n == nil:
+		return
+	case o == nil || n == nil:
+		d.add(ptr, true, "schema changed from %s to %s", describe(o), describe(n))
+		return
+	}
+	oldRef, _ := o.Get("$ref").(string)
+	newRef, _ := n.Get("$ref").(string)
+	if oldRef != "" || newRef != "" {
+		if oldRef != newRef {
+			d.add(ptr, true, "type changed from %s to %s", describe(o), describe(n))
+		}
+		return
+	}
+
+	for _, key := range []string{"type", "format"} {
+		if oldValue, newValue := value(o.Get(key)), value(n.Get(key)); oldValue != newValue {
+			d.add(ptr, true, "%s changed from %s to %s", key, oldValue, newValue)
+		}
+	}
+	if wasNullable, nullable := o.Get("nullable") == true, n.Get("nullable") == true; wasNullable != nullable {
+		if nullable {
+			d.add(ptr, true, "is now nullable")
+		} else {
+			d.add(ptr, true, "is no longer nullable")
+		}
+	}
+	d.diffEnum(o, n, ptr)
+	d.diffProperties(o, n, ptr, request)
+
+	for _, key := range []string{"items", "additionalProperties", "not"} {
+		oldSub, oldObj := o.Get(key).(*specdoc.Object)
+		newSub, newObj := n.Get(key).(*specdoc.Object)
+		switch {
+		case oldObj && newObj:
+			d.diffSchema(oldSub, newSub, ptr+"/"+key, request)
+		case value(o.Get(key)) != value(n.Get(key)):
+			d.add(ptr+"/"+key, true, "%s changed from %s to %s", key, value(o.Get(key)), value(n.Get(key)))
+		}
+	}
+	for _, key := range []string{"allOf", "oneOf", "anyOf"} {
+		d.diffVariants(o, n, key, ptr)
+	}
+}
+
+// diffEnum reports removed enum values, whose constants are removed, and
+// added ones.
+func (d *differ) diffEnum(o, n *specdoc.Object, ptr string) {
+	oldEnum, _ := o.Get("enum").([]any)
+	newEnum, _ := n.Get("enum").([]any)
+	oldValues := compactAll(oldEnum)
+	newValues := compactAll(newEnum)
+	var removed, added []string
+	for _, v := range oldValues {
+		if !slices.Contains(newValues, v) {
+			removed = append(removed, v)
+		}
+	}
+	for _, v := range newValues {
+		if !slices.Contains(oldValues, v) {
+			added = append(added, v)
+		}
+	}
+	switch {
+	case len(oldEnum) > 0 && len(newEnum) == 0:
+		d.add(ptr, true, "removed enum")
+	case len(oldEnum) == 0 && len(newEnum) > 0:
+		d.add(ptr, true, "added enum %s", strings.Join(added, ", "))
+	default:
+		if len(removed) > 0 {
+			d.add(ptr, true, "removed enum values %s", strings.Join(removed, ", "))
+		}
+		if len(added) > 0 {
+			d.add(ptr, false, "added enum values %s", strings.Join(added, ", "))
+		}
+	}
+}
+
+func (d *differ) diffProperties(o, n *specdoc.Object, ptr string, request bool) {
+	oldProps, _ := o.Get("properties").(*specdoc.Object)
+	newProps, _ := n.Get("properties").(*specdoc.Object)
+	oldRequired := stringList(o.Get("required"))
+	newRequired := stringList(n.Get("required"))
+	for _, name := range oldProps.Keys() {
+		if !newProps.Has(name) {
+			d.add(specdoc.Pointer(ptr+"/properties", name), true, "removed property %s", name)
+		}
+	}
+	for _, name := range newProps.Keys() {
+		pptr := specdoc.Pointer(ptr+"/properties", name)
+		required := slices.Contains(newRequired, name)
+		if !oldProps.Has(name) {
+			if required {
+				d.add(pptr, request, "added required property %s", name)
+			} else {
+				d.add(pptr, false, "added optional property %s", name)
+			}
+			continue
+		}
+		if slices.Contains(oldRequired, name) != required {
+			// The field changes between T and OptT.
+			d.add(pptr, true, "property %s is now %s", name, requiredWord(required))
+		}
+		d.diffSchema(oldProps.Get(name), newProps.Get(name), pptr, request)
+	}
+}
+
+// diffVariants reports the removed and added schemas of an allOf, oneOf or
+// anyOf.
+func (d *differ) diffVariants(o, n *specdoc.Object, key, ptr string) {
+	oldList, _ := o.Get(key).([]any)
+	newList, _ := n.Get(key).([]any)
+	oldValues := compactAll(oldList)
+	newValues := compactAll(newList)
+	for i, v := range oldValues {
+		if !slices.Contains(newValues, v) {
+			d.add(fmt.Sprintf("%s/%s/%d", ptr, key, i), true, "removed %s schema %s", key, describe(oldList[i]))
+		}
+	}
+	for i, v := range newValues {
+		if !slices.Contains(oldValues, v) {
+			d.add(fmt.Sprintf("%s/%s/%d", ptr, key, i), false, "added %s schema %s", key, describe(newList[i]))
+		}
+	}
+}
+
+// requestSchemas returns the names of the component schemas that
+// parameters and request bodies reference, directly or through other
+// schemas.
+func requestSchemas(spec *specdoc.Object) map[string]bool {
+	used := map[string]bool{}
+	var queue []string
+	var collect func(v any)
+	collect = func(v any) {
+		switch v := v.(type) {
+		case *specdoc.Object:
+			if ref, ok := v.Get("$ref").(string); ok {
+				if name, ok := strings.CutPrefix(ref, "#/components/schemas/"); ok {
+					if !used[name] {
+						used[name] = true
+						queue = append(queue, name)
+					}
+				} else if target, ok := specdoc.Lookup(spec, strings.TrimPrefix(ref, "#")); ok {
+					// A parameter or request body component.
+					collect(target)
+				}
+			}
+			for _, key := range v.Keys() {
+				collect(v.Get(key))
+			}
+		case []any:
+			for _, elem := range v {
+				collect(elem)
+			}
+		}
+	}
+
+	_, ops := operations(spec)
+	for _, o := range ops {
+		collect(o.item.Get("parameters"))
+		collect(o.op.Get("parameters"))
+		collect(o.op.Get("requestBody"))
+	}
+	all := schemas(spec)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		collect(all.Get(name))
+	}
+	return used
+}
+
+// schemas returns the component schemas of spec.
+func schemas(spec *specdoc.Object) *specdoc.Object {
+	components, _ := spec.Get("components").(*specdoc.Object)
+	s, _ := components.Get("schemas").(*specdoc.Object)
+	return s
+}
+
+// resolve returns the object v, or the one its local $ref points to.
+func resolve(spec *specdoc.Object, v any) *specdoc.Object {
+	o, _ := v.(*specdoc.Object)
+	for i := 0; i < 10; i++ {
+		ref, ok := o.Get("$ref").(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			break
+		}
+		target, _ := specdoc.Lookup(spec, ref[1:])
+		o, _ = target.(*specdoc.Object)
+	}
+	return o
+}
+
+// describe returns a short description of a schema for messages: the name
+// of the component it references, or its JSON.
+func describe(v any) string {
+	o, _ := v.(*specdoc.Object)
+	if o == nil {
+		return "none"
+	}
+	if ref, ok := o.Get("$ref").(string); ok {
+		return ref[strings.LastIndex(ref, "/")+1:]
+	}
+	return specdoc.Compact(o)
+}
+
+// value returns v as compact JSON, or none if it is not set.
+func value(v any) string {
+	if v == nil {
+		return "none"
+	}
+	return specdoc.Compact(v)
+}
+
+func requiredWord(required bool) string {
+	if required {
+		return "required"
+	}
+	return "optional"
+}
+
+func sorted(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
+
+// compactAll returns the elements of list as compact JSON.
+func compactAll(list []any) []string {
+	s := make([]string, len(list))
+	for i, v := range list {
+		s[i] = specdoc.Compact(v)
+	}
+	return s
+}
+
+// stringList returns the strings of a JSON array.
+func stringList(v any) []string {
+	list, _ := v.([]any)
+	var s []string
+	for _, elem := range list {
+		if str, ok := elem.(string); ok {
+			s = append(s, str)
+		}
+	}
+	return s
+}
+
+var paramRE = regexp.MustCompile(`\{[^}]*\}`)
+
+// template returns path with the names of its parameters left out, so that
+// a renamed path parameter does not make another operation.
+func template(path string) string {
+	return paramRE.ReplaceAllString(path, "{}")
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const oldSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1"},
+  "paths": {
+    "/pets": {
+      "get": {"operationId": "listPets", "parameters": [{"name": "limit", "in": "query", "schema": {"type": "integer"}}, {"$ref": "#/components/parameters/Tag"}], "responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}}}}}, "404": {"description": "none"}}},
+      "post": {"operationId": "createPet", "requestBody": {"$ref": "#/components/requestBodies/NewPet"}, "responses": {"201": {"description": "created"}}}
+    },
+    "/pets/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "get": {"operationId": "getPet", "responses": {"200": {"description": "ok"}}},
+      "delete": {"operationId": "deletePet", "responses": {"204": {"description": "deleted"}}}
+    },
+    "/legacy": {"get": {"operationId": "legacy", "responses": {"200": {"description": "ok"}}}},
+    "/search": {"get": {"operationId": "search", "responses": {"200": {"description": "ok"}}}}
+  },
+  "components": {
+    "parameters": {"Tag": {"name": "tag", "in": "query", "schema": {"type": "string"}}},
+    "requestBodies": {"NewPet": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/NewPet"}}}}},
+    "schemas": {
+      "Pet": {"type": "object", "required": ["id"], "properties": {"id": {"type": "string"}, "tag": {"type": "string"}, "status": {"$ref": "#/components/schemas/Status"}}},
+      "NewPet": {"type": "object", "properties": {"name": {"type": "string"}, "owner": {"$ref": "#/components/schemas/Owner"}}},
+      "Owner": {"type": "object", "properties": {"name": {"type": "string"}}},
+      "Status": {"type": "string", "enum": ["available", "sold"]},
+      "Shape": {"oneOf": [{"$ref": "#/components/schemas/Pet"}, {"$ref": "#/components/schemas/Owner"}]},
+      "Old": {"type": "object"}
+    }
+  }
+}`
+
+const newSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "2"},
+  "paths": {
+    "/pets": {
+      "get": {"operationId": "listPets", "parameters": [{"name": "limit", "in": "query", "required": true, "schema": {"type": "integer", "format": "int64"}}, {"name": "sort", "in": "query", "schema": {"type": "string"}}], "responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}}}}}, "default": {"description": "error"}}},
+      "post": {"operationId": "createPet", "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NewPet"}}}}, "responses": {"201": {"description": "created"}}}
+    },
+    "/pets/{petId}": {
+      "parameters": [{"name": "petId", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "get": {"operationId": "showPet", "responses": {"200": {"description": "ok"}}},
+      "delete": {"operationId": "deletePet", "responses": {"204": {"description": "deleted"}}}
+    },
+    "/v2/search": {"get": {"operationId": "search", "responses": {"200": {"description": "ok"}}}},
+    "/new": {"put": {"operationId": "putNew", "responses": {"204": {"description": "ok"}}}}
+  },
+  "components": {
+    "schemas": {
+      "Pet": {"type": "object", "required": ["id", "name"], "properties": {"id": {"type": "integer"}, "name": {"type": "string"}, "status": {"$ref": "#/components/schemas/Status"}, "age": {"type": "integer", "nullable": true}}},
+      "NewPet": {"type": "object", "properties": {"name": {"type": "string"}, "owner": {"$ref": "#/components/schemas/Owner"}}},
+      "Owner": {"type": "object", "required": ["name", "email"], "properties": {"name": {"type": "string"}, "email": {"type": "string"}}},
+      "Status": {"type": "string", "enum": ["available", "pending"]},
+      "Shape": {"oneOf": [{"$ref": "#/components/schemas/Pet"}, {"type": "string"}]},
+      "New": {"type": "object"}
+    }
+  }
+}`
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestDiff(t *testing.T) {
+	got := Diff(parse(t, oldSpec), parse(t, newSpec))
+	want := []Change{
+		{"/paths/~1legacy/get", true, "removed operation GET /legacy (legacy)"},
+		{"/paths/~1pets/get/parameters/1", true, "removed query parameter tag"},
+		{"/paths/~1pets/get/parameters/0", true, "query parameter limit is now required"},
+		{"/paths/~1pets/get/parameters/0/schema", true, `format changed from none to "int64"`},
+		{"/paths/~1pets/get/parameters/1", false, "added optional query parameter sort"},
+		{"/paths/~1pets/get/responses/404", true, "removed 404 response"},
+		{"/paths/~1pets/get/responses/default", false, "added default response"},
+		{"/paths/~1pets/post/requestBody", true, "request body is now required"},
+		// A renamed path parameter renames the field of the Params struct.
+		{"/paths/~1pets~1{petId}/get/operationId", true, `operationId changed from "getPet" to "showPet", which renames its Go method`},
+		{"/paths/~1pets~1{id}/parameters/0", true, "removed path parameter id"},
+		{"/paths/~1pets~1{petId}/parameters/0", true, "added required path parameter petId"},
+		{"/paths/~1v2~1search/get", false, "moved operation search from GET /search"},
+		{"/paths/~1new/put", false, "added operation PUT /new (putNew)"},
+		{"/components/schemas/Old", true, "removed schema Old"},
+		{"/components/schemas/Pet/properties/tag", true, "removed property tag"},
+		{"/components/schemas/Pet/properties/id", true, `type changed from "string" to "integer"`},
+		// Pet is only used in responses.
+		{"/components/schemas/Pet/properties/name", false, "added required property name"},
+		{"/components/schemas/Pet/properties/age", false, "added optional property age"},
+		// Owner is used in the request body, through NewPet.
+		{"/components/schemas/Owner/properties/name", true, "property name is now required"},
+		{"/components/schemas/Owner/properties/email", true, "added required property email"},
+		{"/components/schemas/Status", true, `removed enum values "sold"`},
+		{"/components/schemas/Status", false, `added enum values "pending"`},
+		{"/components/schemas/Shape/oneOf/1", true, "removed oneOf schema Owner"},
+		{"/components/schemas/Shape/oneOf/1", false, `added oneOf schema {"type":"string"}`},
+		{"/components/schemas/New", false, "added schema New"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestDiff_Same(t *testing.T) {
+	if got := Diff(parse(t, oldSpec), parse(t, oldSpec)); len(got) != 0 {
+		t.Errorf("Diff() of the same spec = %v", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, "old.json")
+	newFile := filepath.Join(dir, "new.json")
+	if err := os.WriteFile(oldFile, []byte(oldSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newFile, []byte(newSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// YAML specs are read too.
+	yamlFile := filepath.Join(dir, "old.yaml")
+	yamlSpec := "openapi: 3.0.3\npaths:\n  /legacy:\n    get:\n      responses:\n        200:\n          description: ok\n"
+	if err := os.WriteFile(yamlFile, []byte(yamlSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty.json")
+	if err := os.WriteFile(emptyFile, []byte(`{"openapi": "3.0.3", "paths": {}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{oldFile, newFile}, "16 breaking changes"},
+		{[]string{"-fail-on", "any", oldFile, oldFile}, ""},
+		{[]string{"-fail-on", "none", oldFile, newFile}, ""},
+		{[]string{"-fail-on", "some", oldFile, newFile}, `-fail-on: unknown value "some"`},
+		{[]string{yamlFile, emptyFile}, "1 breaking changes"},
+		{[]string{emptyFile, yamlFile}, ""},
+	}
+	for _, tt := range tests {
+		err := run(tt.args)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+			t.Errorf("run(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+		}
+	}
+}
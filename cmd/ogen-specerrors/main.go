@@ -0,0 +1,272 @@
+// Command ogen-specerrors adds a shared error response to the operations of
+// an OpenAPI spec, as their default response.
+//
+// Specs often leave out error responses, and ogen then generates nothing
+// for them: the client returns an untyped error for any status code the
+// spec does not list, without decoding the body. This tool adds a response
+// component with an error schema, and references it as the default response
+// of every operation:
+//
+//	"default": {"$ref": "#/components/responses/Error"}
+//
+// When every operation has the same default response, ogen generates
+// convenient errors: client methods return *ErrorStatusCode with the decoded
+// body as their error, and handlers return it through NewError.
+//
+// Usage:
+//
+//	ogen-specerrors -o openapi.ogen.json [-schema error.json] [-name Error] [-operations 'GET /pets*'] [-tag pets] [-replace] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// The schema is read from the -schema file, or is the component schema of
+// -name already in the spec. Operations with a default response of their own
+// keep it, and are reported, unless -replace is given. The rest of the spec
+// is written back unchanged, with its keys in their original order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specerrors: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specerrors", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	schemaFile := fs.String("schema", "", "JSON file with the error schema, if the spec has none")
+	var opts Options
+	fs.StringVar(&opts.Name, "name", "Error", "name of the error schema and response components")
+	fs.StringVar(&opts.ContentType, "content-type", "application/json", "media type of error responses")
+	fs.StringVar(&opts.Description, "description", "Error response.", "description of the error response")
+	fs.Var((*specdoc.ListFlag)(&opts.Operations), "operations", `operations to add the response to, as "METHOD /path" patterns with * wildcards (default all)`)
+	fs.Var((*specdoc.ListFlag)(&opts.Tags), "tag", "add the response to operations with one of these tags")
+	fs.BoolVar(&opts.Replace, "replace", false, "replace the default responses of operations that have one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specerrors -o <output.json> [-schema error.json] [-name Error] [-operations <patterns>] [-tag <tags>] [-replace] <openapi.json>")
+	}
+
+	if *schemaFile != "" {
+		schema, err := specdoc.ReadFile(*schemaFile)
+		if err != nil {
+			return fmt.Errorf("-schema: %w", err)
+		}
+		opts.Schema = schema
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := AddDefault(spec, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, ptr := range report.Kept {
+		fmt.Fprintf(os.Stderr, "ogen-specerrors: #%s: kept the default response of the operation; convenient errors need the same one everywhere (use -replace)\n", ptr)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Added the default response %s to %d of %d operations in %s\n", opts.Name, report.Added, report.Operations, *outputFile)
+	return nil
+}
+
+// Options configures AddDefault.
+type Options struct {
+	// Name is the name of the error schema and response components.
+	Name string
+	// Schema is the error schema. If it is nil, the spec must have a
+	// component schema of Name.
+	Schema *specdoc.Object
+	// ContentType is the media type of the error response.
+	ContentType string
+	// Description is the description of the error response.
+	Description string
+	// Operations selects the operations matching one of these
+	// "METHOD /path" patterns, with * wildcards, where the method may be
+	// left out. All operations are selected if it is empty.
+	Operations []string
+	// Tags selects the operations with one of these tags, of those
+	// Operations selects.
+	Tags []string
+	// Replace replaces the default responses operations have.
+	Replace bool
+}
+
+// Report lists what AddDefault changed.
+type Report struct {
+	// Operations counts the operations of the spec.
+	Operations int
+	// Added counts the operations given the error response.
+	Added int
+	// Kept lists the JSON pointers of the selected operations that keep a
+	// default response of their own.
+	Kept []string
+}
+
+// keyRE is the form of component names the OpenAPI specification requires.
+var keyRE = regexp.MustCompile(`^[a-zA-Z0-9.\-_]+$`)
+
+// opPattern is an operations pattern, split into its method and path.
+type opPattern struct {
+	method, path *regexp.Regexp
+}
+
+// AddDefault adds the error schema and a response component with it to
+// spec, and sets the response as the default response of the operations
+// opts selects. It returns an error if the spec has a different schema or
+// response of the name already, or if an operations pattern selects nothing.
+func AddDefault(spec *specdoc.Object, opts Options) (*Report, error) {
+	if !keyRE.MatchString(opts.Name) {
+		return nil, fmt.Errorf("-name %q is not a component name", opts.Name)
+	}
+	patterns := make([]opPattern, len(opts.Operations))
+	for i, pattern := range opts.Operations {
+		method, path := splitOperations(pattern)
+		methodRE, err := specdoc.Glob(strings.ToLower(method))
+		if err != nil {
+			return nil, err
+		}
+		pathRE, err := specdoc.Glob(path)
+		if err != nil {
+			return nil, err
+		}
+		patterns[i] = opPattern{method: methodRE, path: pathRE}
+	}
+
+	components, ok := spec.Get("components").(*specdoc.Object)
+	if !ok {
+		components = specdoc.NewObject()
+		spec.Set("components", components)
+	}
+	schemaRef := "#/components/schemas/" + opts.Name
+	if err := addComponent(components, "schemas", opts.Name, opts.Schema); err != nil {
+		return nil, err
+	}
+	response := specdoc.NewObject()
+	response.Set("description", opts.Description)
+	content := specdoc.NewObject()
+	media := specdoc.NewObject()
+	schema := specdoc.NewObject()
+	schema.Set("$ref", schemaRef)
+	media.Set("schema", schema)
+	content.Set(opts.ContentType, media)
+	response.Set("content", content)
+	if err := addComponent(components, "responses", opts.Name, response); err != nil {
+		return nil, err
+	}
+	ref := "#/components/responses/" + opts.Name
+
+	report := &Report{}
+	matched := make([]bool, len(patterns))
+	paths, _ := spec.Get("paths").(*specdoc.Object)
+	for _, path := range paths.Keys() {
+		item, _ := paths.Get(path).(*specdoc.Object)
+		for _, method := range item.Keys() {
+			op, ok := item.Get(method).(*specdoc.Object)
+			if !ok || !specdoc.IsMethod(method) {
+				continue
+			}
+			report.Operations++
+			selected := len(patterns) == 0
+			for i, p := range patterns {
+				if p.method.MatchString(method) && p.path.MatchString(path) {
+					matched[i] = true
+					selected = true
+				}
+			}
+			if !selected || !hasTag(op, opts.Tags) {
+				continue
+			}
+
+			ptr := specdoc.Pointer(specdoc.Pointer("/paths", path), method)
+			responses, ok := op.Get("responses").(*specdoc.Object)
+			if !ok {
+				responses = specdoc.NewObject()
+				op.Set("responses", responses)
+			}
+			if existing, ok := responses.Get("default").(*specdoc.Object); ok {
+				if existing.Get("$ref") == ref && existing.Len() == 1 {
+					continue
+				}
+				if !opts.Replace {
+					report.Kept = append(report.Kept, ptr)
+					continue
+				}
+			}
+			def := specdoc.NewObject()
+			def.Set("$ref", ref)
+			responses.Set("default", def)
+			report.Added++
+		}
+	}
+	for i, pattern := range opts.Operations {
+		if !matched[i] {
+			return nil, fmt.Errorf("-operations %s matches no operation", pattern)
+		}
+	}
+	return report, nil
+}
+
+// addComponent adds v as the component name of kind to components. If v is
+// nil, the component must exist. If the component exists, it must equal v.
+func addComponent(components *specdoc.Object, kind, name string, v *specdoc.Object) error {
+	objects, ok := components.Get(kind).(*specdoc.Object)
+	if !ok {
+		objects = specdoc.NewObject()
+		components.Set(kind, objects)
+	}
+	existing := objects.Get(name)
+	switch {
+	case existing == nil && v == nil:
+		return fmt.Errorf("no schema %s; pass one with -schema", name)
+	case existing == nil:
+		objects.Set(name, v)
+	case v != nil && specdoc.Compact(existing) != specdoc.Compact(v):
+		return fmt.Errorf("#/components/%s/%s differs from the one to add; pick another -name", kind, name)
+	}
+	return nil
+}
+
+// hasTag reports whether op has one of tags, or tags is empty.
+func hasTag(op *specdoc.Object, tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	list, _ := op.Get("tags").([]any)
+	for _, v := range list {
+		if s, ok := v.(string); ok && slices.Contains(tags, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitOperations returns the method and path patterns of an operations
+// pattern, * for the method if it is left out.
+func splitOperations(pattern string) (method, path string) {
+	if m, p, ok := strings.Cut(pattern, " "); ok {
+		return m, strings.TrimSpace(p)
+	}
+	return "*", pattern
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1"},
+  "paths": {
+    "/pets": {
+      "get": {"tags": ["pets"], "responses": {"200": {"description": "OK"}}},
+      "post": {"tags": ["pets"], "responses": {"201": {"description": "Created"}, "default": {"description": "Other"}}}
+    },
+    "/pets/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "get": {"tags": ["pets"], "responses": {"200": {"description": "OK"}, "default": {"$ref": "#/components/responses/Error"}}},
+      "delete": {"tags": ["admin"]}
+    }
+  },
+  "components": {
+    "schemas": {"Error": {"type": "object", "properties": {"message": {"type": "string"}}}}
+  }
+}`
+
+const errorSchema = `{"type": "object", "properties": {"message": {"type": "string"}}}`
+
+func TestAddDefault(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		want      []string
+		wantAdded int
+		wantKept  []string
+	}{
+		{
+			name: "all",
+			opts: Options{},
+			want: []string{
+				`"responses":{"Error":{"description":"Error response.","content":{"application/json":{"schema":{"$ref":"#/components/schemas/Error"}}}}}`,
+				`"get":{"tags":["pets"],"responses":{"200":{"description":"OK"},"default":{"$ref":"#/components/responses/Error"}}}`,
+				`"default":{"description":"Other"}`,
+				`"delete":{"tags":["admin"],"responses":{"default":{"$ref":"#/components/responses/Error"}}}`,
+			},
+			// The get of /pets/{id} has the response already.
+			wantAdded: 2,
+			wantKept:  []string{"/paths/~1pets/post"},
+		},
+		{
+			name: "replace",
+			opts: Options{Replace: true, Operations: []string{"/pets"}},
+			want: []string{
+				`"post":{"tags":["pets"],"responses":{"201":{"description":"Created"},"default":{"$ref":"#/components/responses/Error"}}}`,
+				`"delete":{"tags":["admin"]}`,
+			},
+			wantAdded: 2,
+		},
+		{
+			name: "filtered",
+			opts: Options{Operations: []string{"DELETE /pets/*", "get *"}, Tags: []string{"admin"}},
+			want: []string{
+				`"get":{"tags":["pets"],"responses":{"200":{"description":"OK"}}}`,
+				`"delete":{"tags":["admin"],"responses":{"default":{"$ref":"#/components/responses/Error"}}}`,
+			},
+			wantAdded: 1,
+		},
+		{
+			name: "schema",
+			opts: Options{Schema: parse(t, errorSchema), Name: "Error", ContentType: "application/problem+json", Description: "Problem."},
+			want: []string{
+				`"Error":{"description":"Problem.","content":{"application/problem+json":{"schema":{"$ref":"#/components/schemas/Error"}}}}`,
+			},
+			wantAdded: 2,
+			wantKept:  []string{"/paths/~1pets/post"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := parse(t, testSpec)
+			opts := tt.opts
+			if opts.Name == "" {
+				opts.Name, opts.ContentType, opts.Description = "Error", "application/json", "Error response."
+			}
+			report, err := AddDefault(spec, opts)
+			if err != nil {
+				t.Fatalf("AddDefault: %v", err)
+			}
+
+			got, err := json.Marshal(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("output missing %s:\n%s", want, got)
+				}
+			}
+			if report.Operations != 4 {
+				t.Errorf("Operations = %d, want 4", report.Operations)
+			}
+			if report.Added != tt.wantAdded {
+				t.Errorf("Added = %d, want %d", report.Added, tt.wantAdded)
+			}
+			if !reflect.DeepEqual(report.Kept, tt.wantKept) {
+				t.Errorf("Kept = %v, want %v", report.Kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestAddDefault_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"name", Options{Name: "Error[]"}, `-name "Error[]" is not a component name`},
+		{"no schema", Options{Name: "Problem"}, "no schema Problem; pass one with -schema"},
+		{"different schema", Options{Name: "Error", Schema: parse(t, `{"type": "string"}`)}, "#/components/schemas/Error differs from the one to add; pick another -name"},
+		{"unmatched", Options{Name: "Error", Operations: []string{"PUT /pets"}}, "-operations PUT /pets matches no operation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := AddDefault(parse(t, testSpec), tt.opts)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("AddDefault error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	schema := filepath.Join(dir, "problem.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(schema, []byte(errorSchema), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, "-schema", schema, "-name", "Problem", "-replace", input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(got), `"$ref": "#/components/responses/Problem"`); n != 4 {
+		t.Errorf("output has %d references to the response, want 4:\n%s", n, got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
@@ -0,0 +1,664 @@
+// Command ogen-specexamples checks the examples of an OpenAPI spec against
+// the schemas they illustrate.
+//
+// Examples are documentation, and nothing checks them: a vendor spec can show
+// an "id" as a number while the schema, and the API, make it a string. Code
+// written against such examples breaks on the first real response. This tool
+// validates every example of a schema, media type, parameter and header, and
+// the example components they reference, and reports each mismatch:
+//
+//	#/components/schemas/Pet/example: /id: want string, got number 42
+//	#/paths/~1pets/get/responses/200/content/application~1json/examples/list/value: /0: missing required property "name"
+//
+// Usage:
+//
+//	ogen-specexamples [-warn] [-formats=false] openapi.json
+//
+// The exit status is 1 if an example does not match its schema, unless -warn
+// is given, so the check can gate CI.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specexamples: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specexamples", flag.ContinueOnError)
+	warn := fs.Bool("warn", false, "report mismatches without failing")
+	formats := fs.Bool("formats", true, "check the formats ogen validates, such as date-time and uuid")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: ogen-specexamples [-warn] [-formats=false] <openapi.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Check(spec, Options{Formats: *formats})
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, m := range report.Mismatches {
+		fmt.Printf("#%s: %s\n", m.Pointer, m.Message)
+	}
+	fmt.Printf("Checked %d examples: %d invalid in %s\n", report.Examples, report.Invalid, filename)
+
+	if report.Invalid > 0 && !*warn {
+		return fmt.Errorf("%d examples do not match their schemas", report.Invalid)
+	}
+	return nil
+}
+
+// Options selects what Check validates.
+type Options struct {
+	// Formats checks the string formats ogen validates, and the range of
+	// int32 and int64 numbers.
+	Formats bool
+}
+
+// Report lists what Check found.
+type Report struct {
+	// Examples counts the examples checked.
+	Examples int
+	// Invalid counts the examples with a problem.
+	Invalid int
+	// Mismatches lists the problems of the invalid examples.
+	Mismatches []Mismatch
+}
+
+// Mismatch is a problem of the example at a JSON pointer.
+type Mismatch struct {
+	Pointer string
+	// Message locates the problem in the example, by a JSON pointer into
+	// it, and describes it.
+	Message string
+}
+
+// Check validates the examples of spec against their schemas: the example
+// and examples of schemas, and the example and examples of media types,
+// parameters and headers, following $refs to example components. Examples
+// with an externalValue are not checked.
+func Check(spec *specdoc.Object, opts Options) (*Report, error) {
+	c := &checker{spec: spec, opts: opts, patterns: make(map[string]*regexp.Regexp), report: &Report{}}
+	err := specdoc.Walk(spec, func(o *specdoc.Object, ptr string) error {
+		if schema, ok := o.Get("schema").(*specdoc.Object); ok {
+			// A media type, parameter or header.
+			if o.Has("example") {
+				c.check(schema, o.Get("example"), specdoc.Pointer(ptr, "example"))
+			}
+			examples, _ := o.Get("examples").(*specdoc.Object)
+			for _, name := range examples.Keys() {
+				c.checkExample(schema, examples.Get(name), specdoc.Pointer(specdoc.Pointer(ptr, "examples"), name))
+			}
+			return nil
+		}
+		if o.Has("content") || o.Has("in") {
+			// A parameter or header with content has its examples in
+			// the media type.
+			return nil
+		}
+		if o.Has("example") {
+			c.check(o, o.Get("example"), specdoc.Pointer(ptr, "example"))
+		}
+		// The examples of a 3.1 schema are a list.
+		examples, _ := o.Get("examples").([]any)
+		for i, v := range examples {
+			c.check(o, v, specdoc.Pointer(specdoc.Pointer(ptr, "examples"), fmt.Sprint(i)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.report, nil
+}
+
+type checker struct {
+	spec     *specdoc.Object
+	opts     Options
+	patterns map[string]*regexp.Regexp
+	report   *Report
+}
+
+// checkExample checks the value of an example object, or of the example
+// component it references.
+func (c *checker) checkExample(schema *specdoc.Object, v any, ptr string) {
+	example, ok := v.(*specdoc.Object)
+	if ref, isRef := example.Get("$ref").(string); isRef {
+		// Problems are reported at the component, which may be
+		// referenced more than once.
+		ptr = strings.TrimPrefix(ref, "#")
+		example, ok = c.resolve(ref)
+	}
+	if !ok || !example.Has("value") {
+		return
+	}
+	c.check(schema, example.Get("value"), specdoc.Pointer(ptr, "value"))
+}
+
+// check validates an example and reports its problems at ptr, once.
+func (c *checker) check(schema any, value any, ptr string) {
+	c.report.Examples++
+	problems := c.validate(schema, value, "")
+	if len(problems) > 0 {
+		c.report.Invalid++
+	}
+	for _, problem := range problems {
+		m := Mismatch{Pointer: ptr, Message: problem}
+		if !slices.Contains(c.report.Mismatches, m) {
+			c.report.Mismatches = append(c.report.Mismatches, m)
+		}
+	}
+}
+
+// resolve returns the object a local $ref points to. The $ref is written as
+// it appears in the spec, with a leading #.
+func (c *checker) resolve(ref string) (*specdoc.Object, bool) {
+	ptr, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, false
+	}
+	v, _ := specdoc.Lookup(c.spec, ptr)
+	o, ok := v.(*specdoc.Object)
+	return o, ok
+}
+
+// validate returns the problems of value against schema, each prefixed with
+// the JSON pointer of the value at path it is about.
+func (c *checker) validate(schemaValue any, value any, path string) []string {
+	var problems []string
+	fail := failer(path, &problems)
+
+	if b, ok := schemaValue.(bool); ok {
+		if !b {
+			fail("no value is allowed")
+		}
+		return problems
+	}
+	schema, ok := schemaValue.(*specdoc.Object)
+	if !ok {
+		return nil
+	}
+	if value == nil && schema.Get("nullable") == true {
+		return nil
+	}
+	if ref, ok := schema.Get("$ref").(string); ok {
+		target, found := c.resolve(ref)
+		if !found {
+			// External $refs are not followed.
+			return nil
+		}
+		problems = append(problems, c.validate(target, value, path)...)
+	}
+
+	if !c.validateType(schema, value) {
+		fail("want %s, got %s", typeNames(schema), describe(value))
+		return problems
+	}
+	if enum, ok := schema.Get("enum").([]any); ok && !contains(enum, value) {
+		fail("%s is not one of %s", specdoc.Compact(value), specdoc.Compact(enum))
+	}
+	if schema.Has("const") && !equal(schema.Get("const"), value) {
+		fail("%s is not %s", specdoc.Compact(value), specdoc.Compact(schema.Get("const")))
+	}
+
+	switch v := value.(type) {
+	case string:
+		c.validateString(schema, v, fail)
+	case json.Number:
+		c.validateNumber(schema, v, fail)
+	case []any:
+		if items := schema.Get("items"); items != nil {
+			for i, item := range v {
+				problems = append(problems, c.validate(items, item, fmt.Sprintf("%s/%d", path, i))...)
+			}
+		}
+		if n, ok := intKeyword(schema, "minItems"); ok && len(v) < n {
+			fail("%d items, want at least %d", len(v), n)
+		}
+		if n, ok := intKeyword(schema, "maxItems"); ok && len(v) > n {
+			fail("%d items, want at most %d", len(v), n)
+		}
+		if schema.Get("uniqueItems") == true {
+			for i := range v {
+				for j := range i {
+					if equal(v[i], v[j]) {
+						fail("items %d and %d are equal", j, i)
+					}
+				}
+			}
+		}
+	case *specdoc.Object:
+		problems = append(problems, c.validateObject(schema, v, path)...)
+	}
+
+	for _, member := range listKeyword(schema, "allOf") {
+		problems = append(problems, c.validate(member, value, path)...)
+	}
+	problems = append(problems, c.validateUnion(schema, value, path)...)
+	if not := schema.Get("not"); not != nil && len(c.validate(not, value, path)) == 0 {
+		fail("matches the schema of not")
+	}
+	return problems
+}
+
+// validateType reports whether value has a type the schema allows: by its
+// type, or its type array in 3.1.
+func (c *checker) validateType(schema *specdoc.Object, value any) bool {
+	var types []string
+	switch t := schema.Get("type").(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+	default:
+		return true
+	}
+	for _, t := range types {
+		switch v := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case json.Number:
+			if t == "number" || t == "integer" && isInteger(v) {
+				return true
+			}
+		case []any:
+			if t == "array" {
+				return true
+			}
+		case *specdoc.Object:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *checker) validateString(schema *specdoc.Object, s string, fail func(string, ...any)) {
+	n := utf8.RuneCountInString(s)
+	if min, ok := intKeyword(schema, "minLength"); ok && n < min {
+		fail("%q is %d characters, want at least %d", s, n, min)
+	}
+	if max, ok := intKeyword(schema, "maxLength"); ok && n > max {
+		fail("%q is %d characters, want at most %d", s, n, max)
+	}
+	if pattern, ok := schema.Get("pattern").(string); ok {
+		re, seen := c.patterns[pattern]
+		if !seen {
+			// Patterns RE2 does not support are not checked.
+			re, _ = regexp.Compile(pattern)
+			c.patterns[pattern] = re
+		}
+		if re != nil && !re.MatchString(s) {
+			fail("%q does not match the pattern %s", s, pattern)
+		}
+	}
+	if format, ok := schema.Get("format").(string); ok && c.opts.Formats && !validFormat(format, s) {
+		fail("%q is not a valid %s", s, format)
+	}
+}
+
+// validFormat reports whether s is valid in a format ogen parses or
+// validates. Other formats are not checked.
+func validFormat(format, s string) bool {
+	var err error
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, s)
+	case "date":
+		_, err = time.Parse(time.DateOnly, s)
+	case "time":
+		_, err = time.Parse(time.TimeOnly, s)
+	case "uuid":
+		return uuidRE.MatchString(s)
+	case "email":
+		_, err = mail.ParseAddress(s)
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	case "ipv6":
+		return net.ParseIP(s) != nil && strings.Contains(s, ":")
+	case "uri":
+		var u *url.URL
+		u, err = url.Parse(s)
+		if err == nil && !u.IsAbs() {
+			return false
+		}
+	}
+	return err == nil
+}
+
+var uuidRE = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func (c *checker) validateNumber(schema *specdoc.Object, n json.Number, fail func(string, ...any)) {
+	v, ok := new(big.Float).SetString(n.String())
+	if !ok {
+		return
+	}
+	bound := func(key string) (*big.Float, bool) {
+		b, ok := schema.Get(key).(json.Number)
+		if !ok {
+			return nil, false
+		}
+		return new(big.Float).SetString(b.String())
+	}
+	// In 3.0, exclusiveMinimum and exclusiveMaximum are booleans that make
+	// minimum and maximum exclusive; in 3.1 they are bounds of their own.
+	if min, ok := bound("minimum"); ok {
+		if cmp := v.Cmp(min); cmp < 0 || cmp == 0 && schema.Get("exclusiveMinimum") == true {
+			fail("%s is below the minimum %s", n, schema.Get("minimum"))
+		}
+	}
+	if max, ok := bound("maximum"); ok {
+		if cmp := v.Cmp(max); cmp > 0 || cmp == 0 && schema.Get("exclusiveMaximum") == true {
+			fail("%s is above the maximum %s", n, schema.Get("maximum"))
+		}
+	}
+	if min, ok := bound("exclusiveMinimum"); ok && v.Cmp(min) <= 0 {
+		fail("%s is not above %s", n, schema.Get("exclusiveMinimum"))
+	}
+	if max, ok := bound("exclusiveMaximum"); ok && v.Cmp(max) >= 0 {
+		fail("%s is not below %s", n, schema.Get("exclusiveMaximum"))
+	}
+	if m, ok := bound("multipleOf"); ok && m.Sign() > 0 {
+		q := new(big.Float).Quo(v, m)
+		if !q.IsInt() {
+			fail("%s is not a multiple of %s", n, schema.Get("multipleOf"))
+		}
+	}
+	if format, ok := schema.Get("format").(string); ok && c.opts.Formats && isInteger(n) {
+		if bits := map[string]uint{"int32": 31, "int64": 63}[format]; bits > 0 {
+			limit := new(big.Int).Lsh(big.NewInt(1), bits)
+			lo := new(big.Float).SetInt(new(big.Int).Neg(limit))
+			hi := new(big.Float).SetInt(new(big.Int).Sub(limit, big.NewInt(1)))
+			if v.Cmp(lo) < 0 || v.Cmp(hi) > 0 {
+				fail("%s is out of the %s range", n, format)
+			}
+		}
+	}
+}
+
+func (c *checker) validateObject(schema, o *specdoc.Object, path string) []string {
+	var problems []string
+	fail := failer(path, &problems)
+	props, _ := schema.Get("properties").(*specdoc.Object)
+	required, _ := schema.Get("required").([]any)
+	for _, r := range required {
+		name, _ := r.(string)
+		if o.Has(name) {
+			continue
+		}
+		// Examples show requests or responses, which leave out the
+		// properties that are only in the other.
+		if prop, ok := c.deref(props.Get(name)); ok && (prop.Get("readOnly") == true || prop.Get("writeOnly") == true) {
+			continue
+		}
+		fail("missing required property %q", name)
+	}
+
+	patterns, _ := schema.Get("patternProperties").(*specdoc.Object)
+	additional := schema.Get("additionalProperties")
+	for _, name := range o.Keys() {
+		valuePath := specdoc.Pointer(path, name)
+		matched := false
+		if props.Has(name) {
+			matched = true
+			problems = append(problems, c.validate(props.Get(name), o.Get(name), valuePath)...)
+		}
+		for _, pattern := range patterns.Keys() {
+			if re, err := regexp.Compile(pattern); err == nil && re.MatchString(name) {
+				matched = true
+				problems = append(problems, c.validate(patterns.Get(pattern), o.Get(name), valuePath)...)
+			}
+		}
+		if matched || additional == nil {
+			continue
+		}
+		if additional == false {
+			fail("unknown property %q", name)
+			continue
+		}
+		problems = append(problems, c.validate(additional, o.Get(name), valuePath)...)
+	}
+
+	if n, ok := intKeyword(schema, "minProperties"); ok && o.Len() < n {
+		fail("%d properties, want at least %d", o.Len(), n)
+	}
+	if n, ok := intKeyword(schema, "maxProperties"); ok && o.Len() > n {
+		fail("%d properties, want at most %d", o.Len(), n)
+	}
+	return problems
+}
+
+// validateUnion validates the oneOf or anyOf of a schema. With a
+// discriminator, the value is validated against the variant its property
+// maps to. Without one, a value matching any variant is valid: oneOf
+// variants often overlap, and ogen tells them apart by their fields.
+func (c *checker) validateUnion(schema *specdoc.Object, value any, path string) []string {
+	var problems []string
+	fail := failer(path, &problems)
+	for _, key := range []string{"oneOf", "anyOf"} {
+		variants := listKeyword(schema, key)
+		if len(variants) == 0 {
+			continue
+		}
+		if discriminator, ok := schema.Get("discriminator").(*specdoc.Object); ok {
+			if o, ok := value.(*specdoc.Object); ok {
+				return c.validateDiscriminated(discriminator, variants, o, path)
+			}
+		}
+		var closest []string
+		for _, variant := range variants {
+			problems := c.validate(variant, value, path)
+			if len(problems) == 0 {
+				return nil
+			}
+			if closest == nil || len(problems) < len(closest) {
+				closest = problems
+			}
+		}
+		if len(variants) == 1 {
+			return closest
+		}
+		fail("matches none of the %d %s schemas", len(variants), key)
+	}
+	return problems
+}
+
+func (c *checker) validateDiscriminated(discriminator *specdoc.Object, variants []any, o *specdoc.Object, path string) []string {
+	var problems []string
+	fail := failer(path, &problems)
+	property, _ := discriminator.Get("propertyName").(string)
+	kind, ok := o.Get(property).(string)
+	if !ok {
+		fail("missing discriminator property %q", property)
+		return problems
+	}
+	ref := ""
+	if mapping, ok := discriminator.Get("mapping").(*specdoc.Object); ok && mapping.Has(kind) {
+		ref, _ = mapping.Get(kind).(string)
+		if !strings.Contains(ref, "#") {
+			ref = "#/components/schemas/" + ref
+		}
+	} else {
+		for _, v := range variants {
+			variant, _ := v.(*specdoc.Object)
+			r, _ := variant.Get("$ref").(string)
+			if r == "#/components/schemas/"+kind {
+				ref = r
+			}
+		}
+	}
+	target, found := c.resolve(ref)
+	if !found {
+		fail("%s %q maps to none of the schemas", property, kind)
+		return problems
+	}
+	return c.validate(target, o, path)
+}
+
+// failer returns a function that adds a problem of the value at path to
+// problems.
+func failer(path string, problems *[]string) func(string, ...any) {
+	return func(format string, args ...any) {
+		msg := fmt.Sprintf(format, args...)
+		if path != "" {
+			msg = path + ": " + msg
+		}
+		*problems = append(*problems, msg)
+	}
+}
+
+// deref returns a schema, or the schema its local $ref points to.
+func (c *checker) deref(v any) (*specdoc.Object, bool) {
+	o, ok := v.(*specdoc.Object)
+	if ref, isRef := o.Get("$ref").(string); isRef {
+		return c.resolve(ref)
+	}
+	return o, ok
+}
+
+// intKeyword returns the value of a keyword that holds a count.
+func intKeyword(schema *specdoc.Object, key string) (int, bool) {
+	n, ok := schema.Get(key).(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return int(i), err == nil
+}
+
+// listKeyword returns the schemas of a keyword that holds a list of them.
+func listKeyword(schema *specdoc.Object, key string) []any {
+	list, _ := schema.Get(key).([]any)
+	return list
+}
+
+// isInteger reports whether a number has no fractional part, as 1.0 has not.
+func isInteger(n json.Number) bool {
+	f, ok := new(big.Float).SetString(n.String())
+	return ok && f.IsInt()
+}
+
+// equal reports whether two JSON values are equal, comparing numbers by
+// value.
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okA := new(big.Float).SetString(a.String())
+		y, okB := new(big.Float).SetString(b.String())
+		return okA && okB && x.Cmp(y) == 0
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case *specdoc.Object:
+		b, ok := b.(*specdoc.Object)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for _, key := range a.Keys() {
+			if !b.Has(key) || !equal(a.Get(key), b.Get(key)) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func contains(list []any, v any) bool {
+	for _, elem := range list {
+		if equal(elem, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeNames returns the types a schema allows, such as "string or null".
+func typeNames(schema *specdoc.Object) string {
+	var names []string
+	switch t := schema.Get("type").(type) {
+	case string:
+		names = []string{t}
+	case []any:
+		for _, v := range t {
+			names = append(names, fmt.Sprint(v))
+		}
+	}
+	if schema.Get("nullable") == true {
+		names = append(names, "null")
+	}
+	return strings.Join(names, " or ")
+}
+
+// describe returns the type and value of a JSON value, such as number 42.
+func describe(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean " + specdoc.Compact(v)
+	case string:
+		return "string " + specdoc.Compact(v)
+	case json.Number:
+		return "number " + specdoc.Compact(v)
+	case []any:
+		return "array"
+	}
+	return "object"
+}
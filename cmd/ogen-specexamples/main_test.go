@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1"},
+  "paths": {
+    "/pets": {
+      "get": {
+        "parameters": [{"name": "limit", "in": "query", "schema": {"type": "integer", "maximum": 100}, "example": 500}],
+        "responses": {"200": {"description": "OK", "content": {"application/json": {
+          "schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}},
+          "examples": {
+            "list": {"value": [{"id": "0b9c1c8e-6f3b-4d6e-9c2a-1a2b3c4d5e6f", "name": "Rex"}, {"id": 2}]},
+            "ref": {"$ref": "#/components/examples/Pets"},
+            "external": {"externalValue": "https://example.com/pets.json"}
+          }
+        }}}}
+      }
+    }
+  },
+  "components": {
+    "examples": {"Pets": {"value": [{"name": "Fido", "kind": "cat", "tag": "x"}]}},
+    "schemas": {
+      "Pet": {
+        "type": "object",
+        "required": ["id", "name"],
+        "properties": {
+          "id": {"type": "string", "format": "uuid", "readOnly": true},
+          "name": {"type": "string", "maxLength": 8},
+          "kind": {"type": "string", "enum": ["dog"]},
+          "owner": {"$ref": "#/components/schemas/Owner", "nullable": true},
+          "shape": {"$ref": "#/components/schemas/Shape"}
+        },
+        "additionalProperties": false,
+        "example": {"id": "0b9c1c8e-6f3b-4d6e-9c2a-1a2b3c4d5e6f", "name": "Rex", "owner": null, "shape": {"type": "square", "side": 2}}
+      },
+      "Owner": {"type": "object", "properties": {"name": {"type": "string"}}, "example": {"name": 1}},
+      "Shape": {
+        "oneOf": [{"$ref": "#/components/schemas/Circle"}, {"$ref": "#/components/schemas/Square"}],
+        "discriminator": {"propertyName": "type", "mapping": {"circle": "Circle", "square": "Square"}},
+        "example": {"type": "circle", "side": 2}
+      },
+      "Circle": {"type": "object", "required": ["radius"], "properties": {"type": {"type": "string"}, "radius": {"type": "number"}}},
+      "Square": {"type": "object", "required": ["side"], "properties": {"type": {"type": "string"}, "side": {"type": "number"}}},
+      "Size": {"type": "integer", "format": "int32", "minimum": 1, "multipleOf": 2, "example": 3000000001}
+    }
+  }
+}`
+
+func TestCheck(t *testing.T) {
+	spec := parse(t, testSpec)
+	report, err := Check(spec, Options{Formats: true})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	var got []string
+	for _, m := range report.Mismatches {
+		got = append(got, m.Pointer+": "+m.Message)
+	}
+	want := []string{
+		"/paths/~1pets/get/parameters/0/example: 500 is above the maximum 100",
+		// The id is read-only, and left out of requests.
+		`/paths/~1pets/get/responses/200/content/application~1json/examples/list/value: /1: missing required property "name"`,
+		"/paths/~1pets/get/responses/200/content/application~1json/examples/list/value: /1/id: want string, got number 2",
+		`/components/examples/Pets/value: /0/kind: "cat" is not one of ["dog"]`,
+		`/components/examples/Pets/value: /0: unknown property "tag"`,
+		"/components/schemas/Owner/example: /name: want string, got number 1",
+		`/components/schemas/Shape/example: missing required property "radius"`,
+		"/components/schemas/Size/example: 3000000001 is not a multiple of 2",
+		"/components/schemas/Size/example: 3000000001 is out of the int32 range",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mismatches:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	// The external example is not checked.
+	if report.Examples != 7 || report.Invalid != 6 {
+		t.Errorf("Examples, Invalid = %d, %d, want 7, 6", report.Examples, report.Invalid)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		schema string
+		value  string
+		want   []string
+	}{
+		{`{"type": "string", "nullable": true}`, `null`, nil},
+		{`{"type": ["string", "null"]}`, `null`, nil},
+		{`{"type": "string"}`, `null`, []string{"want string, got null"}},
+		{`{"type": "integer"}`, `1.0`, nil},
+		{`{"type": "integer"}`, `1.5`, []string{"want integer, got number 1.5"}},
+		{`{"type": "number", "minimum": 1, "exclusiveMinimum": true}`, `1`, []string{"1 is below the minimum 1"}},
+		{`{"type": "number", "exclusiveMaximum": 1}`, `1`, []string{"1 is not below 1"}},
+		{`{"type": "string", "pattern": "^[a-z]+$"}`, `"a1"`, []string{`"a1" does not match the pattern ^[a-z]+$`}},
+		// RE2 has no lookahead: the pattern is not checked.
+		{`{"type": "string", "pattern": "^(?=a)"}`, `"b"`, nil},
+		{`{"type": "string", "format": "date-time"}`, `"2024-01-02"`, []string{`"2024-01-02" is not a valid date-time`}},
+		{`{"type": "string", "format": "hostname"}`, `"?"`, nil},
+		{`{"type": "array", "uniqueItems": true, "maxItems": 2}`, `[1, 1.0, 2]`, []string{"3 items, want at most 2", "items 0 and 1 are equal"}},
+		{`{"type": "object", "additionalProperties": {"type": "integer"}}`, `{"a": "b"}`, []string{`/a: want integer, got string "b"`}},
+		{`{"type": "object", "patternProperties": {"^x-": {}}, "additionalProperties": false}`, `{"x-a": 1, "b": 2}`, []string{`unknown property "b"`}},
+		{`{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, `true`, []string{"matches none of the 2 anyOf schemas"}},
+		{`{"oneOf": [{"type": "object"}, {"type": "object"}]}`, `{}`, nil},
+		{`{"allOf": [{"minLength": 2}, {"maxLength": 1}]}`, `"ab"`, []string{`"ab" is 2 characters, want at most 1`}},
+		{`{"not": {"type": "string"}}`, `"a"`, []string{"matches the schema of not"}},
+		{`{"const": "a"}`, `"b"`, []string{`"b" is not "a"`}},
+	}
+	for _, tt := range tests {
+		doc := parse(t, `{"schema": `+tt.schema+`, "value": `+tt.value+`}`)
+		c := &checker{spec: doc, opts: Options{Formats: true}, patterns: make(map[string]*regexp.Regexp)}
+		if got := c.validate(doc.Get("schema"), doc.Get("value"), ""); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("validate(%s, %s) = %q, want %q", tt.schema, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{input}); err == nil || err.Error() != "6 examples do not match their schemas" {
+		t.Errorf("run: error = %v, want 6 examples", err)
+	}
+	if err := run([]string{"-warn", input}); err != nil {
+		t.Errorf("run -warn: %v", err)
+	}
+}
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
@@ -0,0 +1,421 @@
+// Command ogen-specext adds x-ogen vendor extensions to an OpenAPI spec from
+// rules in a config file.
+//
+// ogen reads naming hints from extensions of the spec: x-ogen-name for the
+// Go name of a schema or parameter, x-ogen-properties for the field names of
+// an object, x-ogen-operation-group to split the handler interface, and
+// others. Vendor specs don't have them, and
+// adding them by hand means keeping a fork of the spec. This tool adds them
+// from rules that select operations, component schemas or JSONPath targets:
+//
+//	{
+//	  "rules": [
+//	    {"operations": "*", "set": {"x-ogen-operation-group": "{tag}"}},
+//	    {"schemas": "V1*", "set": {"x-ogen-name": "{1}"}},
+//	    {"target": "$.paths.*.*.parameters[?@.name == 'id']", "set": {"x-ogen-name": "ID"}}
+//	  ]
+//	}
+//
+// Usage:
+//
+//	ogen-specext -o openapi.ogen.json -config ext.json openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// Rules are applied in order, so a later rule overrides the values an
+// earlier one set. The rest of the spec is written back unchanged, with its
+// keys in their original order.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/jsonpath"
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// Config is the rule list read from the -config file.
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule sets extensions on the nodes it selects. It selects operations,
+// component schemas or the nodes of a JSONPath target.
+type Rule struct {
+	// Operations selects the operations matching "METHOD /path", where
+	// both may contain * wildcards and the method may be left out.
+	Operations string `json:"operations,omitempty"`
+	// Tags selects the operations with one of the tags, of the operations
+	// Operations selects if it is set.
+	Tags []string `json:"tags,omitempty"`
+	// Schemas selects the schemas of components/schemas whose name matches
+	// a pattern with * wildcards.
+	Schemas string `json:"schemas,omitempty"`
+	// Target selects the objects of a JSONPath query.
+	Target string `json:"target,omitempty"`
+	// Set holds the extensions to set, in order. String values may hold
+	// placeholders, such as {tag}, that are replaced for each node.
+	Set json.RawMessage `json:"set"`
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specext: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specext", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	configFile := fs.String("config", "", "JSON file with the extension rules")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" || *configFile == "" {
+		return fmt.Errorf("usage: ogen-specext -o <output.json> -config <ext.json> <openapi.json>")
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Apply(spec, cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, i := range report.Unmatched {
+		fmt.Fprintf(os.Stderr, "ogen-specext: rule %d selects nothing\n", i+1)
+	}
+	for _, r := range report.Replaced {
+		fmt.Fprintf(os.Stderr, "ogen-specext: #%s: replaced %s %s with %s\n", r.Pointer, r.Key, r.Old, r.New)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Set %d extensions on %d nodes in %s\n", report.Set, report.Nodes, *outputFile)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Report lists what Apply changed.
+type Report struct {
+	// Set is the number of extensions set to a new value.
+	Set int
+	// Nodes is the number of nodes the rules selected.
+	Nodes int
+	// Unmatched holds the indices of the rules that selected nothing.
+	Unmatched []int
+	// Replaced lists the extensions the spec or an earlier rule had set to
+	// another value.
+	Replaced []Replace
+}
+
+// Replace is an extension whose value a rule replaced, at the JSON pointer
+// of its object.
+type Replace struct {
+	Pointer string
+	Key     string
+	Old     string
+	New     string
+}
+
+// match is a node a rule selected, with the values of its placeholders.
+type match struct {
+	o    *specdoc.Object
+	ptr  string
+	vars map[string]string
+}
+
+// Apply applies the rules of cfg to spec in order. It returns an error for
+// an invalid rule, a target that selects a value other than an object, or
+// an x-ogen-name that is not a Go identifier.
+func Apply(spec *specdoc.Object, cfg Config) (*Report, error) {
+	report := &Report{}
+	for i, r := range cfg.Rules {
+		set, err := specdoc.Parse(r.Set)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: set: %w", i+1, err)
+		}
+		for _, key := range set.Keys() {
+			if !strings.HasPrefix(key, "x-") {
+				return nil, fmt.Errorf("rule %d: set: %s is not an extension", i+1, key)
+			}
+		}
+		if err := r.checkPlaceholders(set); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		matches, err := r.selectNodes(spec)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		if len(matches) == 0 {
+			report.Unmatched = append(report.Unmatched, i)
+			continue
+		}
+
+		for _, m := range matches {
+			values, ok := expand(set, m.vars).(*specdoc.Object)
+			if !ok {
+				// A placeholder without a value, such as the tag of an
+				// untagged operation.
+				continue
+			}
+			report.Nodes++
+			for _, key := range values.Keys() {
+				v := values.Get(key)
+				if name, ok := v.(string); ok && key == "x-ogen-name" && !token.IsIdentifier(name) {
+					return nil, fmt.Errorf("rule %d: #%s: x-ogen-name %q is not a Go identifier", i+1, m.ptr, name)
+				}
+				old, had := m.o.Get(key), m.o.Has(key)
+				if had && specdoc.Compact(old) == specdoc.Compact(v) {
+					continue
+				}
+				if had {
+					report.Replaced = append(report.Replaced, Replace{Pointer: m.ptr, Key: key, Old: specdoc.Compact(old), New: specdoc.Compact(v)})
+				}
+				m.o.Set(key, v)
+				report.Set++
+			}
+		}
+	}
+	return report, nil
+}
+
+// checkPlaceholders returns an error for a placeholder of set the nodes of
+// the rule have no value for.
+func (r Rule) checkPlaceholders(set *specdoc.Object) error {
+	known := map[string]bool{"name": true}
+	wildcards := strings.Count(r.Schemas, "*")
+	if r.Target == "" && r.Schemas == "" {
+		known = map[string]bool{"path": true, "method": true, "operationId": true, "tag": true}
+		_, path := splitOperations(r.Operations)
+		wildcards = strings.Count(path, "*")
+	}
+	for i := 1; i <= wildcards; i++ {
+		known[strconv.Itoa(i)] = true
+	}
+	for _, m := range placeholderRE.FindAllStringSubmatch(specdoc.Compact(set), -1) {
+		if !known[m[1]] {
+			return fmt.Errorf("set: unknown placeholder {%s}", m[1])
+		}
+	}
+	return nil
+}
+
+// selectNodes returns the nodes of spec the rule selects, in document
+// order.
+func (r Rule) selectNodes(spec *specdoc.Object) ([]match, error) {
+	kinds := 0
+	for _, set := range []bool{r.Operations != "" || len(r.Tags) > 0, r.Schemas != "", r.Target != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return nil, fmt.Errorf("set one of operations and tags, schemas, or target")
+	}
+
+	switch {
+	case r.Schemas != "":
+		return selectSchemas(spec, r.Schemas)
+	case r.Target != "":
+		return selectTarget(spec, r.Target)
+	}
+	return selectOperations(spec, r.Operations, r.Tags)
+}
+
+// selectOperations returns the operations matching pattern, or any if it is
+// empty, that have one of tags, if any.
+func selectOperations(spec *specdoc.Object, pattern string, tags []string) ([]match, error) {
+	methodPattern, pathPattern := splitOperations(pattern)
+	methodRE, err := specdoc.Glob(strings.ToLower(methodPattern))
+	if err != nil {
+		return nil, err
+	}
+	pathRE, err := specdoc.Glob(pathPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []match
+	paths, _ := spec.Get("paths").(*specdoc.Object)
+	for _, path := range paths.Keys() {
+		item, _ := paths.Get(path).(*specdoc.Object)
+		captures := pathRE.FindStringSubmatch(path)
+		if captures == nil {
+			continue
+		}
+		for _, method := range item.Keys() {
+			op, ok := item.Get(method).(*specdoc.Object)
+			if !ok || !specdoc.IsMethod(method) || !methodRE.MatchString(method) {
+				continue
+			}
+			var opTags []string
+			list, _ := op.Get("tags").([]any)
+			for _, v := range list {
+				if s, ok := v.(string); ok {
+					opTags = append(opTags, s)
+				}
+			}
+			if len(tags) > 0 && !slices.ContainsFunc(opTags, func(tag string) bool { return slices.Contains(tags, tag) }) {
+				continue
+			}
+
+			vars := placeholders(captures)
+			vars["path"] = path
+			vars["method"] = method
+			if id, ok := op.Get("operationId").(string); ok && id != "" {
+				vars["operationId"] = id
+			}
+			if len(opTags) > 0 {
+				vars["tag"] = opTags[0]
+			}
+			matches = append(matches, match{o: op, ptr: specdoc.Pointer(specdoc.Pointer("/paths", path), method), vars: vars})
+		}
+	}
+	return matches, nil
+}
+
+// splitOperations returns the method and path patterns of an operations
+// pattern, * for the parts left out.
+func splitOperations(pattern string) (method, path string) {
+	if pattern == "" {
+		return "*", "*"
+	}
+	if m, p, ok := strings.Cut(pattern, " "); ok {
+		return m, strings.TrimSpace(p)
+	}
+	return "*", pattern
+}
+
+// selectSchemas returns the schemas of components/schemas whose name
+// matches pattern.
+func selectSchemas(spec *specdoc.Object, pattern string) ([]match, error) {
+	re, err := specdoc.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var matches []match
+	components, _ := spec.Get("components").(*specdoc.Object)
+	schemas, _ := components.Get("schemas").(*specdoc.Object)
+	for _, name := range schemas.Keys() {
+		o, ok := schemas.Get(name).(*specdoc.Object)
+		captures := re.FindStringSubmatch(name)
+		if !ok || captures == nil {
+			continue
+		}
+		vars := placeholders(captures)
+		vars["name"] = name
+		matches = append(matches, match{o: o, ptr: specdoc.Pointer("/components/schemas", name), vars: vars})
+	}
+	return matches, nil
+}
+
+// selectTarget returns the objects the JSONPath query target selects.
+func selectTarget(spec *specdoc.Object, target string) ([]match, error) {
+	q, err := jsonpath.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	var matches []match
+	seen := make(map[string]bool)
+	for _, n := range q.Select(spec) {
+		ptr := n.Pointer()
+		o, ok := n.Value.(*specdoc.Object)
+		if !ok {
+			return nil, fmt.Errorf("target %s selects #%s, which is not an object", target, ptr)
+		}
+		if seen[ptr] {
+			continue
+		}
+		seen[ptr] = true
+		vars := make(map[string]string)
+		if len(n.Location) > 0 {
+			if name, ok := n.Location[len(n.Location)-1].(string); ok {
+				vars["name"] = name
+			}
+		}
+		matches = append(matches, match{o: o, ptr: ptr, vars: vars})
+	}
+	return matches, nil
+}
+
+// placeholders returns the placeholders {1}, {2}... of the text the *s of a
+// pattern matched.
+func placeholders(captures []string) map[string]string {
+	vars := make(map[string]string)
+	for i, c := range captures[1:] {
+		vars[strconv.Itoa(i+1)] = c
+	}
+	return vars
+}
+
+var placeholderRE = regexp.MustCompile(`\{([A-Za-z0-9]+)\}`)
+
+// expand returns a copy of v with the placeholders of its strings replaced
+// by their values, or nil if a placeholder has no value.
+func expand(v any, vars map[string]string) any {
+	switch v := v.(type) {
+	case *specdoc.Object:
+		o := specdoc.NewObject()
+		for _, key := range v.Keys() {
+			e := expand(v.Get(key), vars)
+			if e == nil && v.Get(key) != nil {
+				return nil
+			}
+			o.Set(key, e)
+		}
+		return o
+	case []any:
+		list := make([]any, len(v))
+		for i, elem := range v {
+			if list[i] = expand(elem, vars); list[i] == nil && elem != nil {
+				return nil
+			}
+		}
+		return list
+	case string:
+		missing := false
+		s := placeholderRE.ReplaceAllStringFunc(v, func(p string) string {
+			value, ok := vars[p[1:len(p)-1]]
+			if !ok {
+				missing = true
+			}
+			return value
+		})
+		if missing {
+			return nil
+		}
+		return s
+	}
+	return v
+}
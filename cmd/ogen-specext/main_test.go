@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/pets": {
+      "get": {"operationId": "listPets", "tags": ["pets"], "responses": {"200": {"description": "ok"}}},
+      "post": {"operationId": "createPet", "tags": ["pets"], "x-ogen-operation-group": "Animals", "responses": {"201": {"description": "created"}}}
+    },
+    "/store/orders/{id}": {
+      "get": {"tags": ["Store Orders"], "responses": {"200": {"description": "ok"}}},
+      "delete": {"operationId": "deleteOrder", "responses": {"204": {"description": "deleted"}}}
+    }
+  },
+  "components": {
+    "schemas": {
+      "V1Pet": {"type": "object", "properties": {"name": {"type": "string"}}},
+      "V1Order": {"type": "object"},
+      "Error": {"type": "object"}
+    }
+  }
+}`
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// rules returns the config of rules given as JSON.
+func rules(t *testing.T, doc string) Config {
+	t.Helper()
+	var cfg Config
+	if err := json.Unmarshal([]byte(`{"rules": `+doc+`}`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// extensions returns the extensions of the object at ptr, as compact JSON.
+func extensions(spec *specdoc.Object, ptr string) string {
+	v, _ := specdoc.Lookup(spec, ptr)
+	o, _ := v.(*specdoc.Object)
+	ext := specdoc.NewObject()
+	for _, key := range o.Keys() {
+		if strings.HasPrefix(key, "x-") {
+			ext.Set(key, o.Get(key))
+		}
+	}
+	return specdoc.Compact(ext)
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		want  map[string]string
+	}{
+		{
+			name:  "operation group by tag",
+			rules: `[{"operations": "*", "set": {"x-ogen-operation-group": "{tag}"}}]`,
+			want: map[string]string{
+				"/paths/~1pets/get":                `{"x-ogen-operation-group":"pets"}`,
+				"/paths/~1pets/post":               `{"x-ogen-operation-group":"pets"}`,
+				"/paths/~1store~1orders~1{id}/get": `{"x-ogen-operation-group":"Store Orders"}`,
+				// No tag to set it to.
+				"/paths/~1store~1orders~1{id}/delete": `{}`,
+			},
+		},
+		{
+			name:  "method, path wildcard and tags",
+			rules: `[{"operations": "GET /store/*", "set": {"x-ogen-operation-group": "{1}"}}, {"tags": ["pets"], "operations": "POST *", "set": {"x-a": "{method} {path} {operationId}"}}]`,
+			want: map[string]string{
+				"/paths/~1pets/get":                   `{}`,
+				"/paths/~1pets/post":                  `{"x-ogen-operation-group":"Animals","x-a":"post /pets createPet"}`,
+				"/paths/~1store~1orders~1{id}/get":    `{"x-ogen-operation-group":"orders/{id}"}`,
+				"/paths/~1store~1orders~1{id}/delete": `{}`,
+			},
+		},
+		{
+			name:  "schemas",
+			rules: `[{"schemas": "V1*", "set": {"x-ogen-name": "{1}", "x-source": ["{name}"]}}]`,
+			want: map[string]string{
+				"/components/schemas/V1Pet":   `{"x-ogen-name":"Pet","x-source":["V1Pet"]}`,
+				"/components/schemas/V1Order": `{"x-ogen-name":"Order","x-source":["V1Order"]}`,
+				"/components/schemas/Error":   `{}`,
+			},
+		},
+		{
+			name:  "target, later rule wins",
+			rules: `[{"target": "$.components.schemas.V1Pet", "set": {"x-ogen-properties": {"name": {"name": "Title"}}}}, {"target": "$.components.schemas[?@.type == 'object']", "set": {"x-ogen-name": "Api{name}"}}, {"schemas": "Error", "set": {"x-ogen-name": "Failure"}}]`,
+			want: map[string]string{
+				"/components/schemas/V1Pet":   `{"x-ogen-properties":{"name":{"name":"Title"}},"x-ogen-name":"ApiV1Pet"}`,
+				"/components/schemas/V1Order": `{"x-ogen-name":"ApiV1Order"}`,
+				"/components/schemas/Error":   `{"x-ogen-name":"Failure"}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := parse(t, testSpec)
+			if _, err := Apply(spec, rules(t, tt.rules)); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			for ptr, want := range tt.want {
+				if got := extensions(spec, ptr); got != want {
+					t.Errorf("#%s extensions = %s, want %s", ptr, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApply_Report(t *testing.T) {
+	spec := parse(t, testSpec)
+	report, err := Apply(spec, rules(t, `[
+		{"operations": "* /pets", "set": {"x-ogen-operation-group": "Pets"}},
+		{"operations": "GET /pets", "set": {"x-ogen-operation-group": "Pets"}},
+		{"schemas": "V2*", "set": {"x-ogen-name": "{1}"}}
+	]`))
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	want := &Report{
+		Set:       2,
+		Nodes:     3,
+		Unmatched: []int{2},
+		Replaced:  []Replace{{Pointer: "/paths/~1pets/post", Key: "x-ogen-operation-group", Old: `"Animals"`, New: `"Pets"`}},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+}
+
+func TestApply_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr string
+	}{
+		{
+			name:    "not an extension",
+			rules:   `[{"operations": "*", "set": {"summary": "x"}}]`,
+			wantErr: "rule 1: set: summary is not an extension",
+		},
+		{
+			name:    "unknown placeholder",
+			rules:   `[{"schemas": "*", "set": {"x-ogen-name": "{tag}"}}]`,
+			wantErr: "rule 1: set: unknown placeholder {tag}",
+		},
+		{
+			name:    "wildcard of the method",
+			rules:   `[{"operations": "* /pets", "set": {"x-a": "{1}"}}]`,
+			wantErr: "rule 1: set: unknown placeholder {1}",
+		},
+		{
+			name:    "no selector",
+			rules:   `[{"set": {"x-a": 1}}]`,
+			wantErr: "rule 1: set one of operations and tags, schemas, or target",
+		},
+		{
+			name:    "two selectors",
+			rules:   `[{"tags": ["pets"], "schemas": "*", "set": {"x-a": 1}}]`,
+			wantErr: "rule 1: set one of operations and tags, schemas, or target",
+		},
+		{
+			name:    "target not an object",
+			rules:   `[{"target": "$.openapi", "set": {"x-a": 1}}]`,
+			wantErr: "rule 1: target $.openapi selects #/openapi, which is not an object",
+		},
+		{
+			name:    "name not an identifier",
+			rules:   `[{"operations": "*", "set": {"x-a": 1}}, {"operations": "*", "set": {"x-ogen-name": "{tag}"}}]`,
+			wantErr: `rule 2: #/paths/~1store~1orders~1{id}/get: x-ogen-name "Store Orders" is not a Go identifier`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Apply(parse(t, testSpec), rules(t, tt.rules))
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Apply() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	config := filepath.Join(dir, "ext.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, []byte(`{"rules": [{"schemas": "V1*", "set": {"x-ogen-name": "{1}"}}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, "-config", config, input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext := extensions(parse(t, string(got)), "/components/schemas/V1Pet"); ext != `{"x-ogen-name":"Pet"}` {
+		t.Errorf("V1Pet extensions = %s", ext)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+
+	if err := run([]string{"-o", output, input}); err == nil {
+		t.Error("run without -config succeeded")
+	}
+}
@@ -0,0 +1,346 @@
+// Command ogen-specfilter trims an OpenAPI spec to the operations a client
+// uses, before ogen generates it.
+//
+// ogen generates every operation of a spec, and every type they and the
+// components reference. A client of a few operations of a large API pays for
+// all of them in code size, compile time and ogen run time. This tool keeps
+// the operations selected by tag, path, method or operationId, and the
+// components they need:
+//
+//	ogen-specfilter -o openapi.ogen.json -include-tag billing -exclude-path '/internal/*' openapi.json
+//
+// Each flag takes a comma-separated list and may be repeated. An operation is
+// kept if it matches every kind of -include flag given, and no -exclude flag.
+// Path patterns match the whole path, with * matching any characters,
+// including /.
+//
+// Usage:
+//
+//	ogen-specfilter -o openapi.ogen.json [filters] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specfilter: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specfilter", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the filtered spec to")
+	var f Filter
+	fs.Var((*specdoc.ListFlag)(&f.IncludeTags), "include-tag", "keep operations with one of these tags")
+	fs.Var((*specdoc.ListFlag)(&f.ExcludeTags), "exclude-tag", "remove operations with one of these tags")
+	fs.Var((*specdoc.ListFlag)(&f.IncludePaths), "include-path", "keep operations of paths matching one of these patterns")
+	fs.Var((*specdoc.ListFlag)(&f.ExcludePaths), "exclude-path", "remove operations of paths matching one of these patterns")
+	fs.Var((*specdoc.ListFlag)(&f.IncludeMethods), "include-method", "keep operations with one of these methods")
+	fs.Var((*specdoc.ListFlag)(&f.ExcludeMethods), "exclude-method", "remove operations with one of these methods")
+	fs.Var((*specdoc.ListFlag)(&f.IncludeOperations), "include-op", "keep operations with one of these operationIds")
+	fs.Var((*specdoc.ListFlag)(&f.ExcludeOperations), "exclude-op", "remove operations with one of these operationIds")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specfilter -o <output.json> [-include-tag|-exclude-tag <tags>] [-include-path|-exclude-path <patterns>] [-include-method|-exclude-method <methods>] [-include-op|-exclude-op <operationIds>] <openapi.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Apply(spec, f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, u := range report.Unmatched {
+		fmt.Fprintf(os.Stderr, "ogen-specfilter: %s matches no operation\n", u)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Kept %d of %d operations and %d of %d components in %s\n",
+		report.Operations, report.TotalOperations, report.Components, report.TotalComponents, *outputFile)
+	return nil
+}
+
+// Filter selects operations. Empty lists select every operation.
+type Filter struct {
+	IncludeTags       []string
+	ExcludeTags       []string
+	IncludePaths      []string
+	ExcludePaths      []string
+	IncludeMethods    []string
+	ExcludeMethods    []string
+	IncludeOperations []string
+	ExcludeOperations []string
+}
+
+// Report counts what Apply kept.
+type Report struct {
+	Operations      int
+	TotalOperations int
+	Components      int
+	TotalComponents int
+	// Unmatched lists the filter values that match no operation of the
+	// spec, such as "-include-tag billing", which are likely stale.
+	Unmatched []string
+}
+
+// criterion is the values of a filter flag, each with a function that
+// reports whether an operation matches it.
+type criterion struct {
+	flag     string
+	include  bool
+	values   []string
+	matchers []func(op opInfo) bool
+	// matched records the values some operation matched.
+	matched []bool
+}
+
+// opInfo is what the filters match of an operation.
+type opInfo struct {
+	path   string
+	method string
+	id     string
+	tags   []string
+}
+
+func newCriterion(flag string, include bool, values []string, matcher func(v string) (func(op opInfo) bool, error)) (*criterion, error) {
+	c := &criterion{flag: flag, include: include, values: values, matched: make([]bool, len(values))}
+	for _, v := range values {
+		m, err := matcher(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", flag, err)
+		}
+		c.matchers = append(c.matchers, m)
+	}
+	return c, nil
+}
+
+func matchTag(v string) (func(op opInfo) bool, error) {
+	return func(op opInfo) bool { return slices.Contains(op.tags, v) }, nil
+}
+
+func matchPath(v string) (func(op opInfo) bool, error) {
+	if !strings.HasPrefix(v, "/") && !strings.HasPrefix(v, "*") {
+		return nil, fmt.Errorf("path pattern %q does not start with / or *", v)
+	}
+	re, err := specdoc.Glob(v)
+	if err != nil {
+		return nil, err
+	}
+	return func(op opInfo) bool { return re.MatchString(op.path) }, nil
+}
+
+func matchMethod(v string) (func(op opInfo) bool, error) {
+	return func(op opInfo) bool { return strings.EqualFold(v, op.method) }, nil
+}
+
+func matchOperation(v string) (func(op opInfo) bool, error) {
+	return func(op opInfo) bool { return v == op.id }, nil
+}
+
+// Apply removes the operations of spec that f does not select, the path
+// items left without operations, and the components, tags and security
+// schemes the rest of spec no longer references. Webhooks are filtered by
+// their name as path. It returns an error for an invalid path pattern, or
+// if no operation is left.
+func Apply(spec *specdoc.Object, f Filter) (*Report, error) {
+	var criteria []*criterion
+	for _, c := range []struct {
+		flag    string
+		include bool
+		values  []string
+		matcher func(v string) (func(op opInfo) bool, error)
+	}{
+		{"-include-tag", true, f.IncludeTags, matchTag},
+		{"-exclude-tag", false, f.ExcludeTags, matchTag},
+		{"-include-path", true, f.IncludePaths, matchPath},
+		{"-exclude-path", false, f.ExcludePaths, matchPath},
+		{"-include-method", true, f.IncludeMethods, matchMethod},
+		{"-exclude-method", false, f.ExcludeMethods, matchMethod},
+		{"-include-op", true, f.IncludeOperations, matchOperation},
+		{"-exclude-op", false, f.ExcludeOperations, matchOperation},
+	} {
+		crit, err := newCriterion(c.flag, c.include, c.values, c.matcher)
+		if err != nil {
+			return nil, err
+		}
+		criteria = append(criteria, crit)
+	}
+
+	report := &Report{}
+	for _, key := range specdoc.ItemMaps {
+		items, _ := spec.Get(key).(*specdoc.Object)
+		for _, name := range items.Keys() {
+			item, ok := items.Get(name).(*specdoc.Object)
+			if !ok {
+				continue
+			}
+			removed := false
+			for _, method := range item.Keys() {
+				o, ok := item.Get(method).(*specdoc.Object)
+				if !ok || !specdoc.IsMethod(method) {
+					continue
+				}
+				report.TotalOperations++
+				op := opInfo{path: name, method: method}
+				op.id, _ = o.Get("operationId").(string)
+				tags, _ := o.Get("tags").([]any)
+				for _, tag := range tags {
+					if s, ok := tag.(string); ok {
+						op.tags = append(op.tags, s)
+					}
+				}
+				if selected(criteria, op) {
+					report.Operations++
+				} else {
+					item.Delete(method)
+					removed = true
+				}
+			}
+			if removed && !hasOperations(item) {
+				items.Delete(name)
+			}
+		}
+	}
+	if report.Operations == 0 {
+		return nil, fmt.Errorf("no operations left of %d", report.TotalOperations)
+	}
+	for _, c := range criteria {
+		for i, v := range c.values {
+			if !c.matched[i] {
+				report.Unmatched = append(report.Unmatched, c.flag+" "+v)
+			}
+		}
+	}
+
+	keepTags(spec)
+	report.TotalComponents, report.Components = keepComponents(spec)
+	return report, nil
+}
+
+// selected reports whether op matches a value of each include criterion
+// with values, and no value of an exclude criterion, recording the values
+// it matches.
+func selected(criteria []*criterion, op opInfo) bool {
+	keep := true
+	for _, c := range criteria {
+		matches := false
+		for i, m := range c.matchers {
+			if m(op) {
+				c.matched[i] = true
+				matches = true
+			}
+		}
+		if len(c.values) > 0 && matches != c.include {
+			keep = false
+		}
+	}
+	return keep
+}
+
+// hasOperations reports whether a path item has an operation left.
+func hasOperations(item *specdoc.Object) bool {
+	for _, key := range item.Keys() {
+		if specdoc.IsMethod(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepTags removes the entries of the tags of spec that no operation has.
+func keepTags(spec *specdoc.Object) {
+	list, ok := spec.Get("tags").([]any)
+	if !ok {
+		return
+	}
+	used := make(map[string]bool)
+	for _, key := range specdoc.ItemMaps {
+		items, _ := spec.Get(key).(*specdoc.Object)
+		for _, name := range items.Keys() {
+			item, _ := items.Get(name).(*specdoc.Object)
+			for _, method := range item.Keys() {
+				o, ok := item.Get(method).(*specdoc.Object)
+				if !ok || !specdoc.IsMethod(method) {
+					continue
+				}
+				tags, _ := o.Get("tags").([]any)
+				for _, tag := range tags {
+					if s, ok := tag.(string); ok {
+						used[s] = true
+					}
+				}
+			}
+		}
+	}
+	kept := []any{}
+	for _, v := range list {
+		if o, ok := v.(*specdoc.Object); ok {
+			if name, _ := o.Get("name").(string); !used[name] {
+				continue
+			}
+		}
+		kept = append(kept, v)
+	}
+	spec.Set("tags", kept)
+}
+
+// keepComponents removes the components of spec that the rest of spec does
+// not reference, directly or through other components, except the security
+// schemes its requirements name. Component maps left empty are removed. It
+// returns the number of components before and after.
+func keepComponents(spec *specdoc.Object) (total, kept int) {
+	components, ok := spec.Get("components").(*specdoc.Object)
+	if !ok {
+		return 0, 0
+	}
+
+	pending := specdoc.SecuritySchemes(spec)
+	for _, key := range spec.Keys() {
+		if key != "components" {
+			pending = append(pending, specdoc.Refs(spec.Get(key))...)
+		}
+	}
+	used := specdoc.Reachable(spec, pending)
+
+	for _, kind := range components.Keys() {
+		byName, ok := components.Get(kind).(*specdoc.Object)
+		if !ok || strings.HasPrefix(kind, "x-") {
+			continue
+		}
+		for _, name := range byName.Keys() {
+			total++
+			if used[specdoc.Pointer(specdoc.Pointer("/components", kind), name)] {
+				kept++
+			} else {
+				byName.Delete(name)
+			}
+		}
+		if byName.Len() == 0 {
+			components.Delete(kind)
+		}
+	}
+	return total, kept
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "tags": [{"name": "billing"}, {"name": "pets"}, {"name": "admin"}],
+  "paths": {
+    "/invoices": {
+      "get": {"operationId": "listInvoices", "tags": ["billing"], "responses": {"200": {"$ref": "#/components/responses/Invoices"}}},
+      "post": {"operationId": "createInvoice", "tags": ["billing"], "security": [{"oauth": []}], "responses": {"201": {"description": "created"}}}
+    },
+    "/internal/invoices/{id}": {
+      "parameters": [{"$ref": "#/components/parameters/id"}],
+      "delete": {"operationId": "purgeInvoice", "tags": ["billing", "admin"], "responses": {"204": {"description": "purged"}}}
+    },
+    "/pets": {
+      "get": {"operationId": "listPets", "tags": ["pets"], "responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}}}}
+    },
+    "/shared": {"$ref": "#/components/pathItems/Shared"}
+  },
+  "components": {
+    "parameters": {"id": {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}},
+    "responses": {"Invoices": {"description": "ok", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Invoice"}}}}}},
+    "schemas": {
+      "Invoice": {"type": "object", "properties": {"lines": {"type": "array", "items": {"$ref": "#/components/schemas/Line"}}}},
+      "Line": {"type": "object", "properties": {"amount": {"type": "integer"}}},
+      "Pet": {"type": "object", "properties": {"name": {"type": "string"}}}
+    },
+    "pathItems": {"Shared": {"get": {"operationId": "shared", "responses": {"200": {"description": "ok"}}}}},
+    "securitySchemes": {"oauth": {"type": "oauth2", "flows": {}}, "apiKey": {"type": "apiKey", "in": "header", "name": "X-Key"}}
+  }
+}`
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// operations returns the "METHOD path" of the operations of spec.
+func operations(spec *specdoc.Object) []string {
+	var ops []string
+	paths, _ := spec.Get("paths").(*specdoc.Object)
+	for _, path := range paths.Keys() {
+		item, _ := paths.Get(path).(*specdoc.Object)
+		for _, method := range item.Keys() {
+			if specdoc.IsMethod(method) {
+				ops = append(ops, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	return ops
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name string
+		f    Filter
+		want []string
+	}{
+		{
+			name: "include tag",
+			f:    Filter{IncludeTags: []string{"billing"}},
+			want: []string{"GET /invoices", "POST /invoices", "DELETE /internal/invoices/{id}"},
+		},
+		{
+			name: "include tag, exclude path",
+			f:    Filter{IncludeTags: []string{"billing"}, ExcludePaths: []string{"/internal/*"}},
+			want: []string{"GET /invoices", "POST /invoices"},
+		},
+		{
+			name: "exclude tag, not the first",
+			f:    Filter{ExcludeTags: []string{"admin"}},
+			want: []string{"GET /invoices", "POST /invoices", "GET /pets"},
+		},
+		{
+			name: "include method and path",
+			f:    Filter{IncludePaths: []string{"/pets", "*/invoices"}, IncludeMethods: []string{"GET"}},
+			want: []string{"GET /invoices", "GET /pets"},
+		},
+		{
+			name: "include operation, exclude method",
+			f:    Filter{IncludeOperations: []string{"listPets", "createInvoice"}, ExcludeMethods: []string{"post"}},
+			want: []string{"GET /pets"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := parse(t, testSpec)
+			if _, err := Apply(spec, tt.f); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if got := operations(spec); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("operations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply_Components(t *testing.T) {
+	spec := parse(t, testSpec)
+	report, err := Apply(spec, Filter{IncludeOperations: []string{"listInvoices", "unknown"}, ExcludeTags: []string{"pets"}})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	want := &Report{
+		Operations:      1,
+		TotalOperations: 4,
+		Components:      4,
+		TotalComponents: 8,
+		Unmatched:       []string{"-include-op unknown"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+
+	components, _ := spec.Get("components").(*specdoc.Object)
+	var got []string
+	for _, kind := range components.Keys() {
+		byName, _ := components.Get(kind).(*specdoc.Object)
+		got = append(got, kind+": "+strings.Join(byName.Keys(), ","))
+	}
+	// The path item $ref keeps Shared, whose operation is not filtered.
+	wantComponents := []string{"responses: Invoices", "schemas: Invoice,Line", "pathItems: Shared"}
+	if !reflect.DeepEqual(got, wantComponents) {
+		t.Errorf("components = %v, want %v", got, wantComponents)
+	}
+	paths, _ := spec.Get("paths").(*specdoc.Object)
+	if got := paths.Keys(); !reflect.DeepEqual(got, []string{"/invoices", "/shared"}) {
+		t.Errorf("paths = %v", got)
+	}
+	if got := len(spec.Get("tags").([]any)); got != 1 {
+		t.Errorf("%d tags left, want billing only", got)
+	}
+}
+
+func TestApply_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		f       Filter
+		wantErr string
+	}{
+		{
+			name:    "pattern",
+			f:       Filter{IncludePaths: []string{"pets"}},
+			wantErr: `-include-path: path pattern "pets" does not start with / or *`,
+		},
+		{
+			name:    "nothing left",
+			f:       Filter{IncludeTags: []string{"store"}},
+			wantErr: "no operations left of 4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Apply(parse(t, testSpec), tt.f)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Apply() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, "--include-tag", "billing", "--exclude-path", "/internal/*", "-exclude-method", "post,put", input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := parse(t, string(got))
+	if ops := operations(spec); !reflect.DeepEqual(ops, []string{"GET /invoices"}) {
+		t.Errorf("operations = %v", ops)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
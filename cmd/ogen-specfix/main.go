@@ -0,0 +1,167 @@
+// Command ogen-specfix rewrites nullable schemas of an OpenAPI spec into the
+// forms ogen maps to OptNil and Nil types.
+//
+// ogen ignores nullable next to a $ref (ogen-go/ogen#1358), so the field is
+// an Opt type that fails to decode null, and it rejects OpenAPI 3.1 type
+// arrays altogether. This tool rewrites both before ogen reads the spec:
+//
+//	{"$ref": "#/components/schemas/Pet", "nullable": true}
+//	{"allOf": [{"$ref": "#/components/schemas/Pet"}], "nullable": true}
+//
+//	{"type": ["string", "null"]}
+//	{"type": "string", "nullable": true}
+//
+// A nullable oneOf or anyOf with a single member becomes an allOf as well.
+// Fixing the spec makes ogen-fixnull unnecessary for these fields, and
+// gives them OptNil types that can tell null from absent.
+//
+// Usage:
+//
+//	ogen-specfix -o openapi.ogen.json openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specfix: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specfix", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specfix -o <output.json> <openapi.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	stats, err := FixNullable(spec)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Fixed %d nullable $refs and %d type arrays in %s\n", stats.Refs, stats.TypeArrays, *outputFile)
+	return nil
+}
+
+// Stats counts the schemas FixNullable rewrote.
+type Stats struct {
+	Refs       int
+	TypeArrays int
+}
+
+// FixNullable rewrites the nullable $refs and type arrays of spec. It returns
+// an error for a type array with more than one type besides "null", which
+// ogen cannot represent.
+func FixNullable(spec *specdoc.Object) (Stats, error) {
+	var stats Stats
+	err := specdoc.Walk(spec, func(o *specdoc.Object, ptr string) error {
+		fixed, err := fixTypeArray(o)
+		if err != nil {
+			return fmt.Errorf("#%s: %w", ptr, err)
+		}
+		if fixed {
+			stats.TypeArrays++
+		}
+		if fixNullableRef(o) {
+			stats.Refs++
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// fixTypeArray replaces a type array by its single type, with nullable if
+// the array has "null".
+func fixTypeArray(o *specdoc.Object) (bool, error) {
+	list, ok := o.Get("type").([]any)
+	if !ok {
+		return false, nil
+	}
+	var types []string
+	null := false
+	for _, t := range list {
+		switch t {
+		case "null":
+			null = true
+		default:
+			s, ok := t.(string)
+			if !ok {
+				return false, fmt.Errorf("type %v is not a list of strings", list)
+			}
+			types = append(types, s)
+		}
+	}
+
+	switch {
+	case len(types) == 0 && null:
+		o.Set("type", "null")
+	case len(types) == 1:
+		o.Set("type", types[0])
+		if null {
+			o.Set("nullable", true)
+		}
+	case len(types) == 0:
+		return false, fmt.Errorf("type is an empty list")
+	default:
+		return false, fmt.Errorf("type %v has more than one type besides null", list)
+	}
+	return true, nil
+}
+
+// fixNullableRef moves a $ref with nullable into an allOf, where ogen reads
+// the nullable. A oneOf or anyOf with a single member becomes an allOf too:
+// ogen ignores their nullable, or generates a sum type of one.
+func fixNullableRef(o *specdoc.Object) bool {
+	if o.Get("nullable") != true {
+		return false
+	}
+
+	if ref := o.Get("$ref"); ref != nil {
+		if _, ok := ref.(string); !ok {
+			return false
+		}
+		member := specdoc.NewObject()
+		member.Set("$ref", ref)
+		if list, ok := o.Get("allOf").([]any); ok {
+			o.Set("allOf", append([]any{member}, list...))
+			o.Delete("$ref")
+		} else {
+			o.Replace("$ref", "allOf", []any{member})
+		}
+		return true
+	}
+
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if list, ok := o.Get(key).([]any); ok && len(list) == 1 && !o.Has("allOf") {
+			o.Replace(key, "allOf", list)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.1.0",
+  "components": {
+    "schemas": {
+      "Pet": {"type": "object", "properties": {"name": {"type": "string"}}},
+      "User": {
+        "type": "object",
+        "properties": {
+          "pet": {"$ref": "#/components/schemas/Pet", "nullable": true, "description": "The pet."},
+          "owner": {"$ref": "#/components/schemas/Pet"},
+          "sitter": {"oneOf": [{"$ref": "#/components/schemas/Pet"}], "nullable": true},
+          "nickname": {"type": ["string", "null"], "maxLength": 10},
+          "age": {"type": ["integer"]},
+          "tags": {"type": "array", "items": {"type": ["null", "string"]}},
+          "nothing": {"type": ["null"]},
+          "type": {"type": "string", "default": {"type": ["not", "a", "schema"]}}
+        }
+      }
+    }
+  }
+}`
+
+func TestFixNullable(t *testing.T) {
+	spec, err := specdoc.Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := FixNullable(spec)
+	if err != nil {
+		t.Fatalf("FixNullable: %v", err)
+	}
+	if want := (Stats{Refs: 2, TypeArrays: 4}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	got, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+	for _, want := range []string{
+		// The $ref moves into an allOf in its place.
+		`"pet":{"allOf":[{"$ref":"#/components/schemas/Pet"}],"nullable":true,"description":"The pet."}`,
+		// A $ref without nullable is left alone.
+		`"owner":{"$ref":"#/components/schemas/Pet"}`,
+		`"sitter":{"allOf":[{"$ref":"#/components/schemas/Pet"}],"nullable":true}`,
+		`"nickname":{"type":"string","maxLength":10,"nullable":true}`,
+		`"age":{"type":"integer"}`,
+		`"items":{"type":"string","nullable":true}`,
+		`"nothing":{"type":"null"}`,
+		// Defaults are data, not schemas.
+		`"default":{"type":["not","a","schema"]}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+
+	// A second run finds nothing left to fix.
+	if stats, err := FixNullable(spec); err != nil || stats != (Stats{}) {
+		t.Errorf("second run: stats = %+v, err = %v, want no fixes", stats, err)
+	}
+}
+
+func TestFixNullable_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{
+			name:    "several types",
+			schema:  `{"type": ["string", "integer", "null"]}`,
+			wantErr: "#/components/schemas/Value: type [string integer null] has more than one type besides null",
+		},
+		{
+			name:    "empty",
+			schema:  `{"type": []}`,
+			wantErr: "#/components/schemas/Value: type is an empty list",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := specdoc.Parse([]byte(`{"components": {"schemas": {"Value": ` + tt.schema + `}}}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = FixNullable(spec)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("FixNullable() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `"allOf": [`) {
+		t.Errorf("output has no nullable references in an allOf:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
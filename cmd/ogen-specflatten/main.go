@@ -0,0 +1,439 @@
+// Command ogen-specflatten moves deeply nested inline objects of an OpenAPI
+// spec into components, so that ogen gives them short type names.
+//
+// ogen names an inline object after the type it is in and its property, so
+// each level of nesting makes the name longer: an object five levels into a
+// response becomes ListPetsOKItemsItemOwnerAddressGeoLocation. This tool
+// moves the inline objects nested deeper than -depth into components/schemas,
+// where their names, and the names of the objects in them, start over:
+//
+//	"geo": {"type": "object", "properties": {"lat": {"type": "number"}}}
+//	"geo": {"$ref": "#/components/schemas/Geo"}
+//
+// Usage:
+//
+//	ogen-specflatten -o openapi.ogen.json [-depth 2] [-naming leaf|component] [-config flatten.json] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// With -naming leaf, the default, a component is named after its property,
+// prefixed with the properties it is in while the name is taken. With
+// -naming component, it is prefixed with the component or operation it was
+// in. The config file renames them:
+//
+//	{
+//	  "names": {"Geo": "Coordinates"}
+//	}
+//
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// Config is the component name map read from the -config file.
+type Config struct {
+	// Names maps the derived name of a new component to the name it gets.
+	Names map[string]string `json:"names"`
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specflatten: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specflatten", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	depth := fs.Int("depth", 2, "levels of inline objects to keep inside a component or operation")
+	naming := fs.String("naming", Leaf, "how to name new components: leaf or component")
+	configFile := fs.String("config", "", "JSON file with names of new components")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specflatten -o <output.json> [-depth <n>] [-naming leaf|component] [-config flatten.json] <openapi.json>")
+	}
+	if *depth < 0 {
+		return fmt.Errorf("-depth %d is negative", *depth)
+	}
+	if *naming != Leaf && *naming != Component {
+		return fmt.Errorf("-naming: unknown strategy %q, want %s or %s", *naming, Leaf, Component)
+	}
+
+	var cfg Config
+	if *configFile != "" {
+		var err error
+		if cfg, err = loadConfig(*configFile); err != nil {
+			return err
+		}
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Flatten(spec, cfg, Options{Depth: *depth, Naming: *naming})
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, e := range report.Extracted {
+		fmt.Fprintf(os.Stderr, "ogen-specflatten: #%s: moved to %s\n", e.Pointer, e.Name)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Moved %d inline objects to components in %s\n", len(report.Extracted), *outputFile)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// The naming strategies of Flatten.
+const (
+	// Leaf names a component after its property, such as Geo, prefixed
+	// with the properties it is in while the name is taken: AddressGeo.
+	Leaf = "leaf"
+	// Component names a component after the component or operation it
+	// was in and its property, such as PetGeo, adding the properties in
+	// between while the name is taken: PetAddressGeo.
+	Component = "component"
+)
+
+// Options configures Flatten.
+type Options struct {
+	// Depth is the number of levels of inline objects kept inside a
+	// component or operation. The inline objects of a component schema
+	// are at level 1, as is the inline schema of a body or parameter.
+	// Depth 0 moves every inline object.
+	Depth int
+	// Naming is the naming strategy of new components: Leaf or Component.
+	Naming string
+}
+
+// Report lists what Flatten changed.
+type Report struct {
+	Extracted []Extraction
+}
+
+// Extraction is an inline object moved to a component.
+type Extraction struct {
+	// Pointer is the JSON pointer of the object when it was moved.
+	Pointer string
+	// Name is the name of the component in components/schemas.
+	Name string
+}
+
+// nameMapKeys hold maps from names to schemas, whose keys are not keywords.
+var nameMapKeys = map[string]bool{
+	"properties":        true,
+	"patternProperties": true,
+	"dependentSchemas":  true,
+	"$defs":             true,
+	"definitions":       true,
+}
+
+// flattener holds the state of Flatten.
+type flattener struct {
+	spec    *specdoc.Object
+	schemas *specdoc.Object
+	cfg     Config
+	opts    Options
+	used    map[string]bool
+	report  *Report
+}
+
+// Flatten moves the inline objects of spec nested more than opts.Depth
+// levels into a component schema or operation into components/schemas,
+// replacing them with a $ref. Objects are moved outer first, and the levels
+// of the objects in a moved one count from it. It returns an error for a
+// config name that no new component has.
+func Flatten(spec *specdoc.Object, cfg Config, opts Options) (*Report, error) {
+	f := &flattener{spec: spec, cfg: cfg, opts: opts, used: make(map[string]bool), report: &Report{}}
+	components, _ := spec.Get("components").(*specdoc.Object)
+	f.schemas, _ = components.Get("schemas").(*specdoc.Object)
+
+	// The roots are collected first: moved objects are flattened when they
+	// are moved, and not again as components.
+	type root struct {
+		schema *specdoc.Object
+		ptr    string
+		name   string
+		level  int
+	}
+	var roots []root
+	// Walk only returns the errors of its function, which has none.
+	_ = specdoc.Walk(spec, func(o *specdoc.Object, ptr string) error {
+		parts := specdoc.SplitPointer(ptr)
+		n := len(parts)
+		switch {
+		case specdoc.IsComponentSchema(ptr):
+			roots = append(roots, root{o, ptr, specdoc.Pascal(parts[2]), 0})
+			return specdoc.SkipChildren
+		case n >= 2 && parts[n-1] == "schema" && !nameMapKeys[parts[n-2]]:
+			level := 0
+			if isObject(o) {
+				level = 1
+			}
+			roots = append(roots, root{o, ptr, f.rootName(ptr), level})
+			return specdoc.SkipChildren
+		}
+		return nil
+	})
+	for _, r := range roots {
+		if r.level > opts.Depth {
+			f.move(r.schema, r.ptr, []string{r.name})
+			continue
+		}
+		f.flatten(r.schema, r.ptr, []string{r.name}, r.level)
+	}
+
+	for name := range cfg.Names {
+		if !f.used[name] {
+			return nil, fmt.Errorf("config: no new component %s", name)
+		}
+	}
+	return f.report, nil
+}
+
+// flatten moves the inline objects in schema nested deeper than the depth,
+// given the level of schema and the names of the properties leading to it
+// from its root.
+func (f *flattener) flatten(schema *specdoc.Object, ptr string, chain []string, level int) {
+	child := func(key string, v any, childChain []string, movable bool) {
+		o, ok := v.(*specdoc.Object)
+		if !ok || o.Has("$ref") {
+			return
+		}
+		if !movable || !isObject(o) {
+			f.flatten(o, key, childChain, level)
+			return
+		}
+		if level+1 <= f.opts.Depth {
+			f.flatten(o, key, childChain, level+1)
+			return
+		}
+		f.move(o, key, childChain)
+	}
+	// item returns the chain of the items or values of schema.
+	item := func() []string {
+		c := append([]string(nil), chain...)
+		c[len(c)-1] += "Item"
+		return c
+	}
+
+	for _, key := range schema.Keys() {
+		keyPtr := specdoc.Pointer(ptr, key)
+		switch key {
+		case "properties":
+			props, _ := schema.Get(key).(*specdoc.Object)
+			for _, name := range props.Keys() {
+				child(specdoc.Pointer(keyPtr, name), props.Get(name), append(chain[:len(chain):len(chain)], specdoc.Pascal(name)), true)
+			}
+		case "patternProperties":
+			props, _ := schema.Get(key).(*specdoc.Object)
+			for _, name := range props.Keys() {
+				child(specdoc.Pointer(keyPtr, name), props.Get(name), item(), true)
+			}
+		case "items", "additionalProperties":
+			child(keyPtr, schema.Get(key), item(), true)
+		case "allOf", "prefixItems":
+			// Members of allOf are merged into schema: they stay, but
+			// their properties are flattened.
+			list, _ := schema.Get(key).([]any)
+			for i, v := range list {
+				child(specdoc.Pointer(keyPtr, strconv.Itoa(i)), v, chain, false)
+			}
+		case "oneOf", "anyOf":
+			// Variants are named after schema, and stay, but object
+			// variants are types of their own, a level deeper.
+			list, _ := schema.Get(key).([]any)
+			for i, v := range list {
+				if o, ok := v.(*specdoc.Object); ok && isObject(o) {
+					f.flatten(o, specdoc.Pointer(keyPtr, strconv.Itoa(i)), chain, level+1)
+					continue
+				}
+				child(specdoc.Pointer(keyPtr, strconv.Itoa(i)), v, chain, false)
+			}
+		case "not":
+			child(keyPtr, schema.Get(key), chain, false)
+		}
+	}
+}
+
+// move moves the object o at ptr into a component named after chain, and
+// flattens it.
+func (f *flattener) move(o *specdoc.Object, ptr string, chain []string) {
+	name := f.name(chain)
+	f.addSchema(name, o)
+	ref := specdoc.NewObject()
+	ref.Set("$ref", "#/components/schemas/"+specdoc.Escape(name))
+	replace(f.spec, ptr, ref)
+	f.report.Extracted = append(f.report.Extracted, Extraction{Pointer: ptr, Name: name})
+	f.flatten(o, specdoc.Pointer("/components/schemas", name), []string{name}, 0)
+}
+
+// isObject reports whether ogen generates a struct or map type for the
+// inline schema o.
+func isObject(o *specdoc.Object) bool {
+	if o.Has("$ref") {
+		return false
+	}
+	return o.Get("type") == "object" || o.Has("properties") || o.Has("additionalProperties") || o.Has("patternProperties")
+}
+
+// name returns the name of a new component for the object at the end of
+// chain, by the naming strategy and the config, that no component has.
+func (f *flattener) name(chain []string) string {
+	var candidates []string
+	n := len(chain)
+	for i := n - 1; i >= 0; i-- {
+		tail := strings.Join(chain[i:], "")
+		switch {
+		case f.opts.Naming == Leaf || n == 1:
+			candidates = append(candidates, tail)
+		case i > 0:
+			candidates = append(candidates, chain[0]+tail)
+		}
+	}
+	name := ""
+	for _, c := range candidates {
+		if !f.taken(c) {
+			name = c
+			break
+		}
+	}
+	if name == "" {
+		name = f.unique(candidates[len(candidates)-1])
+	}
+	if renamed, ok := f.cfg.Names[name]; ok {
+		f.used[name] = true
+		name = f.unique(renamed)
+	}
+	return name
+}
+
+// unique returns name, or name with the first free numeric suffix if a
+// component has the same Go name.
+func (f *flattener) unique(name string) string {
+	unique := name
+	for i := 2; f.taken(unique); i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	return unique
+}
+
+// taken reports whether a component has the same Go name as name.
+func (f *flattener) taken(name string) bool {
+	for _, key := range f.schemas.Keys() {
+		if specdoc.Normalize(key) == specdoc.Normalize(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// rootName returns the name of the schema of a parameter, header or media
+// type at ptr: the parameter or header name, or the operationId with
+// Request, Response or Error.
+func (f *flattener) rootName(ptr string) string {
+	parts := specdoc.SplitPointer(ptr)
+	n := len(parts)
+	owner := specdoc.Parent(ptr)
+	if n >= 3 && parts[n-3] == "content" {
+		owner = specdoc.Parent(specdoc.Parent(specdoc.Parent(ptr)))
+	}
+	ownerParts := specdoc.SplitPointer(owner)
+	m := len(ownerParts)
+	switch {
+	case m == 3 && ownerParts[0] == "components":
+		return specdoc.Pascal(ownerParts[2])
+	case m >= 2 && ownerParts[m-2] == "headers":
+		return specdoc.Pascal(ownerParts[m-1])
+	case m >= 1 && ownerParts[m-1] == "requestBody":
+		return f.operationName(specdoc.Parent(owner)) + "Request"
+	case m >= 2 && ownerParts[m-2] == "responses" && strings.HasPrefix(ownerParts[m-1], "2"):
+		return f.operationName(specdoc.Parent(specdoc.Parent(owner))) + "Response"
+	case m >= 2 && ownerParts[m-2] == "responses":
+		return f.operationName(specdoc.Parent(specdoc.Parent(owner))) + "Error"
+	}
+	if param, ok := specdoc.LookupObject(f.spec, owner); ok {
+		if name, ok := param.Get("name").(string); ok && param.Has("in") {
+			return specdoc.Pascal(name)
+		}
+	}
+	return "Schema"
+}
+
+// operationName returns the pascal case operationId of the operation at
+// ptr, or the words of its path and method.
+func (f *flattener) operationName(ptr string) string {
+	if op, ok := specdoc.LookupObject(f.spec, ptr); ok {
+		if id, ok := op.Get("operationId").(string); ok && id != "" {
+			return specdoc.Pascal(id)
+		}
+	}
+	parts := specdoc.SplitPointer(ptr)
+	if len(parts) == 3 && parts[0] == "paths" {
+		return specdoc.Pascal(parts[1] + " " + parts[2])
+	}
+	return "Operation"
+}
+
+// addSchema adds the component schema name, creating components/schemas if
+// needed.
+func (f *flattener) addSchema(name string, schema any) {
+	if f.schemas == nil {
+		components, ok := f.spec.Get("components").(*specdoc.Object)
+		if !ok {
+			components = specdoc.NewObject()
+			f.spec.Set("components", components)
+		}
+		f.schemas = specdoc.NewObject()
+		components.Set("schemas", f.schemas)
+	}
+	f.schemas.Set(name, schema)
+}
+
+// replace sets the value at ptr of spec to v.
+func replace(spec *specdoc.Object, ptr string, v any) {
+	parts := specdoc.SplitPointer(ptr)
+	key := parts[len(parts)-1]
+	switch c := lookup(spec, specdoc.Parent(ptr)).(type) {
+	case *specdoc.Object:
+		c.Set(key, v)
+	case []any:
+		i, _ := strconv.Atoi(key)
+		c[i] = v
+	}
+}
+
+func lookup(spec *specdoc.Object, ptr string) any {
+	v, _ := specdoc.Lookup(spec, ptr)
+	return v
+}
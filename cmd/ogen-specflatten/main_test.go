@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1"},
+  "paths": {
+    "/pets": {
+      "get": {
+        "operationId": "listPets",
+        "responses": {"200": {"description": "OK", "content": {"application/json": {"schema": {
+          "type": "object",
+          "properties": {"items": {"type": "array", "items": {
+            "type": "object",
+            "properties": {"owner": {"type": "object", "properties": {"name": {"type": "string"}}}}
+          }}}
+        }}}}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Pet": {
+        "type": "object",
+        "properties": {
+          "owner": {"type": "object", "properties": {
+            "address": {"type": "object", "properties": {
+              "geo": {"type": "object", "properties": {"lat": {"type": "number"}}}
+            }}
+          }},
+          "tags": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}}}},
+          "shape": {"oneOf": [{"type": "object", "properties": {"size": {"type": "object"}}}]},
+          "kind": {"$ref": "#/components/schemas/Geo"}
+        }
+      },
+      "Geo": {"type": "string"}
+    }
+  }
+}`
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		opts Options
+		want []Extraction
+	}{
+		{
+			name: "leaf",
+			opts: Options{Depth: 2, Naming: Leaf},
+			want: []Extraction{
+				{"/paths/~1pets/get/responses/200/content/application~1json/schema/properties/items/items/properties/owner", "Owner"},
+				{"/components/schemas/Pet/properties/owner/properties/address/properties/geo", "AddressGeo"},
+			},
+		},
+		{
+			name: "component",
+			opts: Options{Depth: 1, Naming: Component},
+			want: []Extraction{
+				{"/paths/~1pets/get/responses/200/content/application~1json/schema/properties/items/items", "ListPetsResponseItemsItem"},
+				{"/components/schemas/Pet/properties/owner/properties/address", "PetAddress"},
+				// The oneOf variant stays; the objects in it are moved.
+				{"/components/schemas/Pet/properties/shape/oneOf/0/properties/size", "PetSize"},
+			},
+		},
+		{
+			name: "config",
+			cfg:  Config{Names: map[string]string{"AddressGeo": "Coordinates"}},
+			opts: Options{Depth: 2, Naming: Leaf},
+			want: []Extraction{
+				{"/paths/~1pets/get/responses/200/content/application~1json/schema/properties/items/items/properties/owner", "Owner"},
+				{"/components/schemas/Pet/properties/owner/properties/address/properties/geo", "Coordinates"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := parse(t, testSpec)
+			report, err := Flatten(spec, tt.cfg, tt.opts)
+			if err != nil {
+				t.Fatalf("Flatten: %v", err)
+			}
+			if !reflect.DeepEqual(report.Extracted, tt.want) {
+				t.Errorf("Extracted = %v, want %v", report.Extracted, tt.want)
+			}
+
+			got, err := json.Marshal(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range tt.want {
+				if !strings.Contains(string(got), `{"$ref":"#/components/schemas/`+e.Name+`"}`) {
+					t.Errorf("output has no $ref to %s:\n%s", e.Name, got)
+				}
+				if _, ok := specdoc.Lookup(spec, "/components/schemas/"+e.Name); !ok {
+					t.Errorf("output has no component %s", e.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestFlatten_Errors(t *testing.T) {
+	cfg := Config{Names: map[string]string{"Address": "Place"}}
+	_, err := Flatten(parse(t, testSpec), cfg, Options{Depth: 2, Naming: Leaf})
+	if err == nil || err.Error() != "config: no new component Address" {
+		t.Errorf("Flatten error = %v, want no new component Address", err)
+	}
+}
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		naming string
+		chain  []string
+		want   string
+	}{
+		{Leaf, []string{"Pet", "Owner", "Address"}, "Address"},
+		// Geo is a component.
+		{Leaf, []string{"Pet", "Address", "Geo"}, "AddressGeo"},
+		{Leaf, []string{"Pet", "Geo"}, "PetGeo"},
+		{Leaf, []string{"Pet"}, "Pet2"},
+		{Component, []string{"Pet", "Owner", "Address"}, "PetAddress"},
+		{Component, []string{"ListPetsResponseItem"}, "ListPetsResponseItem"},
+	}
+	for _, tt := range tests {
+		spec := parse(t, testSpec)
+		f := &flattener{spec: spec, opts: Options{Naming: tt.naming}, used: map[string]bool{}}
+		f.schemas, _ = specdoc.LookupObject(spec, "/components/schemas")
+		if got := f.name(tt.chain); got != tt.want {
+			t.Errorf("name(%s, %v) = %s, want %s", tt.naming, tt.chain, got, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, "-naming", "path", input}); err == nil {
+		t.Error("run -naming path: want error")
+	}
+
+	if err := run([]string{"-o", output, "-depth", "0", input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every inline object is moved, the schema of the response too.
+	if !strings.Contains(string(got), `"ListPetsResponse": {`) || !strings.Contains(string(got), `"Owner": {`) {
+		t.Errorf("output has not moved every object:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
@@ -0,0 +1,376 @@
+// Command ogen-speclint reports the constructs of an OpenAPI spec that ogen
+// generates broken or surprising code for.
+//
+// Each finding has a rule ID, a severity, its location and a fix:
+//
+//	#/components/schemas/Pet/properties/owner: error: nullable-ref: nullable next to $ref is ignored: ogen generates an Opt type that fails to decode null
+//	    fix: rewrite it with ogen-specfix, or wrap the $ref in allOf
+//
+// Rules:
+//
+//	nullable-ref              error    nullable next to a $ref, or on a oneOf or anyOf of one schema
+//	type-array                error    3.1 type arrays, which ogen rejects
+//	duplicate-operation-id    error    operationIds that become the same Go method
+//	null-variant              warning  a null member of a oneOf or anyOf of several schemas
+//	discriminator-mapping     warning  discriminator members without a mapping entry
+//	response-without-content  warning  2xx responses other than 204 without content
+//	duplicate-inline-schema   info     identical inline schemas, generated as one type each
+//	missing-operation-id      info     operations without operationId
+//
+// Usage:
+//
+//	ogen-speclint [-fail-on error|warning|info|none] [-disable rule,...] openapi.json
+//
+// The exit status is 1 if a finding has the -fail-on severity or a higher
+// one (error by default), so the linter can gate CI.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-speclint: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-speclint", flag.ContinueOnError)
+	failOn := fs.String("fail-on", "error", "lowest severity that fails: error, warning, info or none")
+	disable := fs.String("disable", "", "comma-separated rule IDs to skip")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: ogen-speclint [-fail-on <severity>] [-disable <rules>] <openapi.json>")
+	}
+
+	threshold := Error + 1
+	if *failOn != "none" {
+		var err error
+		if threshold, err = ParseSeverity(*failOn); err != nil {
+			return err
+		}
+	}
+	disabled := make(map[string]bool)
+	if *disable != "" {
+		for _, id := range strings.Split(*disable, ",") {
+			id = strings.TrimSpace(id)
+			if !slices.ContainsFunc(Rules, func(r Rule) bool { return r.ID == id }) {
+				return fmt.Errorf("unknown rule %q", id)
+			}
+			disabled[id] = true
+		}
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var counts [Error + 1]int
+	failed := 0
+	for _, f := range Lint(spec) {
+		if disabled[f.Rule] {
+			continue
+		}
+		fmt.Printf("#%s: %s: %s: %s\n", f.Pointer, f.Severity, f.Rule, f.Message)
+		fmt.Printf("    fix: %s\n", f.Fix)
+		counts[f.Severity]++
+		if f.Severity >= threshold {
+			failed++
+		}
+	}
+	fmt.Printf("%d errors, %d warnings and %d infos in %s\n", counts[Error], counts[Warning], counts[Info], filename)
+
+	if failed > 0 {
+		return fmt.Errorf("%d findings at or above %s", failed, *failOn)
+	}
+	return nil
+}
+
+// Severity is how likely a finding is to break the generated code.
+type Severity int
+
+const (
+	// Info findings make the generated code harder to use.
+	Info Severity = iota
+	// Warning findings generate code that behaves differently than the
+	// spec reads.
+	Warning
+	// Error findings fail generation, or generate code that fails on valid
+	// messages.
+	Error
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+// ParseSeverity returns the severity named s.
+func ParseSeverity(s string) (Severity, error) {
+	i := slices.Index(severityNames, s)
+	if i < 0 {
+		return 0, fmt.Errorf("unknown severity %q", s)
+	}
+	return Severity(i), nil
+}
+
+// Rule is a check of the linter.
+type Rule struct {
+	ID       string
+	Severity Severity
+}
+
+// Rules lists the checks of Lint.
+var Rules = []Rule{
+	{"nullable-ref", Error},
+	{"type-array", Error},
+	{"duplicate-operation-id", Error},
+	{"null-variant", Warning},
+	{"discriminator-mapping", Warning},
+	{"response-without-content", Warning},
+	{"duplicate-inline-schema", Info},
+	{"missing-operation-id", Info},
+}
+
+// Finding is a construct a rule matched, at a JSON pointer of the spec.
+type Finding struct {
+	Pointer  string
+	Rule     string
+	Severity Severity
+	Message  string
+	Fix      string
+}
+
+type linter struct {
+	spec     *specdoc.Object
+	findings []Finding
+	// schemas holds the locations of the inline schemas of each JSON form.
+	schemas map[string][]string
+}
+
+// Lint returns the findings of every rule on spec, in document order for
+// each rule.
+func Lint(spec *specdoc.Object) []Finding {
+	l := &linter{spec: spec, schemas: make(map[string][]string)}
+	l.lintOperations()
+	// Walk only returns the errors of its function, and lintObject has none.
+	_ = specdoc.Walk(spec, l.lintObject)
+	l.lintDuplicateSchemas()
+	return l.findings
+}
+
+func (l *linter) report(ptr, rule, fix, format string, args ...any) {
+	i := slices.IndexFunc(Rules, func(r Rule) bool { return r.ID == rule })
+	l.findings = append(l.findings, Finding{
+		Pointer:  ptr,
+		Rule:     rule,
+		Severity: Rules[i].Severity,
+		Message:  fmt.Sprintf(format, args...),
+		Fix:      fix,
+	})
+}
+
+// lintOperations reports missing and duplicate operationIds.
+func (l *linter) lintOperations() {
+	paths, _ := l.spec.Get("paths").(*specdoc.Object)
+	seen := make(map[string]string)
+	for _, path := range paths.Keys() {
+		item, _ := paths.Get(path).(*specdoc.Object)
+		for _, method := range item.Keys() {
+			op, ok := item.Get(method).(*specdoc.Object)
+			if !ok || !specdoc.IsMethod(method) {
+				continue
+			}
+			ptr := specdoc.Pointer(specdoc.Pointer("/paths", path), method)
+			id, _ := op.Get("operationId").(string)
+			if id == "" {
+				l.report(ptr, "missing-operation-id", "add an operationId, or derive them with ogen-specopids",
+					"no operationId: ogen names the method after the path and method")
+				continue
+			}
+			norm := specdoc.Normalize(id)
+			if other, ok := seen[norm]; ok {
+				l.report(ptr, "duplicate-operation-id", "rename it, or rename duplicates with ogen-specopids",
+					"operationId %s is the same Go method as the one of #%s: ogen fails", id, other)
+				continue
+			}
+			seen[norm] = ptr
+		}
+	}
+}
+
+// lintObject applies the rules of schemas and responses to an object of the
+// spec.
+func (l *linter) lintObject(o *specdoc.Object, ptr string) error {
+	if isOperationResponse(ptr) {
+		l.lintResponse(o, ptr)
+	}
+	if !specdoc.IsSchemaPointer(ptr, false) {
+		return nil
+	}
+
+	l.lintNullable(o, ptr)
+	if list, ok := o.Get("type").([]any); ok {
+		l.report(ptr, "type-array", "rewrite it with ogen-specfix, or convert the spec with ogen-spec30",
+			"type %s is an array: ogen fails to parse it", specdoc.Compact(list))
+	}
+	l.lintDiscriminator(o, ptr)
+	if !specdoc.IsComponentSchema(ptr) && o.Get("$ref") == nil && (o.Has("properties") || o.Has("enum")) {
+		form := specdoc.Compact(o)
+		l.schemas[form] = append(l.schemas[form], ptr)
+	}
+	return nil
+}
+
+// lintNullable reports nullable schemas ogen reads as not nullable, and null
+// members that become a variant of a sum type.
+func (l *linter) lintNullable(o *specdoc.Object, ptr string) {
+	if o.Get("nullable") == true {
+		if o.Has("$ref") {
+			l.report(ptr, "nullable-ref", "rewrite it with ogen-specfix, or wrap the $ref in allOf",
+				"nullable next to $ref is ignored: ogen generates an Opt type that fails to decode null")
+		}
+		for _, key := range []string{"oneOf", "anyOf"} {
+			if list, ok := o.Get(key).([]any); ok && len(list) == 1 {
+				l.report(ptr, "nullable-ref", "rewrite it with ogen-specfix, or replace the "+key+" by an allOf",
+					"nullable on a %s of one schema is ignored: ogen generates an Opt type that fails to decode null", key)
+			}
+		}
+	}
+
+	for _, key := range []string{"oneOf", "anyOf"} {
+		list, _ := o.Get(key).([]any)
+		null := slices.IndexFunc(list, isNullSchema)
+		if null < 0 || len(list) < 3 {
+			// With one other member, ogen generates an OptNil type.
+			continue
+		}
+		l.report(specdoc.Pointer(ptr, key)+fmt.Sprintf("/%d", null), "null-variant",
+			"remove the null member and set nullable: true, which ogen reads as an OptNil sum type",
+			"null member among %d %s schemas: ogen generates a sum type with a Null variant rather than an OptNil type", len(list), key)
+	}
+}
+
+// isNullSchema reports whether v is {"type": "null"}.
+func isNullSchema(v any) bool {
+	o, ok := v.(*specdoc.Object)
+	return ok && o.Get("type") == "null"
+}
+
+// lintDiscriminator reports the $ref members of a discriminated oneOf or
+// anyOf without a mapping entry: ogen expects their schema names as values.
+func (l *linter) lintDiscriminator(o *specdoc.Object, ptr string) {
+	discriminator, ok := o.Get("discriminator").(*specdoc.Object)
+	if !ok {
+		return
+	}
+	mapping, _ := discriminator.Get("mapping").(*specdoc.Object)
+	mapped := make(map[string]bool)
+	for _, value := range mapping.Keys() {
+		if ref, ok := mapping.Get(value).(string); ok {
+			mapped[ref] = true
+		}
+	}
+
+	var unmapped []string
+	for _, key := range []string{"oneOf", "anyOf"} {
+		list, _ := o.Get(key).([]any)
+		for _, v := range list {
+			member, _ := v.(*specdoc.Object)
+			ref, ok := member.Get("$ref").(string)
+			if !ok || mapped[ref] {
+				continue
+			}
+			// A mapping value may be a schema name rather than a $ref.
+			name := ref[strings.LastIndex(ref, "/")+1:]
+			if !mapped[name] {
+				unmapped = append(unmapped, name)
+			}
+		}
+	}
+	if len(unmapped) == 0 {
+		return
+	}
+	property, _ := discriminator.Get("propertyName").(string)
+	l.report(specdoc.Pointer(ptr, "discriminator"), "discriminator-mapping",
+		"add mapping entries with the "+property+" values the API sends",
+		"no mapping for %s: ogen expects the schema names as %s values", strings.Join(unmapped, ", "), property)
+}
+
+// lintResponse reports a success response without content, which ogen
+// generates as an empty struct, dropping the body.
+func (l *linter) lintResponse(o *specdoc.Object, ptr string) {
+	code := ptr[strings.LastIndex(ptr, "/")+1:]
+	if !strings.HasPrefix(code, "2") || code == "204" || code == "205" {
+		return
+	}
+	response := o
+	if ref, ok := o.Get("$ref").(string); ok {
+		v, _ := specdoc.Lookup(l.spec, strings.TrimPrefix(ref, "#"))
+		response, _ = v.(*specdoc.Object)
+	}
+	if response == nil || response.Has("content") {
+		return
+	}
+	l.report(ptr, "response-without-content", "add the content of the response, or use 204 if it has no body",
+		"%s response without content: ogen generates an empty struct and discards the body", code)
+}
+
+// lintDuplicateSchemas reports the inline schemas that occur more than once.
+// Duplicates within a reported schema are not reported again.
+func (l *linter) lintDuplicateSchemas() {
+	var groups [][]string
+	for _, ptrs := range l.schemas {
+		if len(ptrs) > 1 {
+			groups = append(groups, ptrs)
+		}
+	}
+	// Outer schemas first, then in document order.
+	sort.Slice(groups, func(i, j int) bool {
+		if a, b := len(groups[i][0]), len(groups[j][0]); a != b {
+			return a < b
+		}
+		return groups[i][0] < groups[j][0]
+	})
+
+	var reported []string
+	within := func(ptr string) bool {
+		return slices.ContainsFunc(reported, func(outer string) bool {
+			return strings.HasPrefix(ptr, outer+"/")
+		})
+	}
+	for _, ptrs := range groups {
+		if !slices.ContainsFunc(ptrs, func(ptr string) bool { return !within(ptr) }) {
+			continue
+		}
+		reported = append(reported, ptrs...)
+		var others []string
+		for _, ptr := range ptrs[1:] {
+			others = append(others, "#"+ptr)
+		}
+		l.report(ptrs[0], "duplicate-inline-schema", "move it to components/schemas and use $refs, or run ogen-specdedupe",
+			"inline schema also at %s: ogen generates a type for each", strings.Join(others, ", "))
+	}
+}
+
+// isOperationResponse reports whether the object at ptr is a response of an
+// operation, whose key is its status code.
+func isOperationResponse(ptr string) bool {
+	parts := strings.Split(ptr, "/")
+	return len(parts) >= 3 && parts[len(parts)-2] == "responses" && specdoc.IsMethod(parts[len(parts)-3])
+}
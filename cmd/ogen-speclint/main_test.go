@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/pets": {
+      "get": {
+        "operationId": "listPets",
+        "responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}}}}}}
+      },
+      "post": {
+        "operationId": "list_pets",
+        "requestBody": {"content": {"application/json": {"schema": {"type": "object", "properties": {"street": {"type": "string"}}}}}},
+        "responses": {"201": {"$ref": "#/components/responses/Created"}}
+      }
+    },
+    "/ping": {
+      "get": {"responses": {"200": {"description": "pong"}, "204": {"description": "none"}}}
+    }
+  },
+  "components": {
+    "responses": {"Created": {"description": "created"}},
+    "schemas": {
+      "Owner": {"type": "object", "properties": {"name": {"type": "string"}}},
+      "Pet": {
+        "type": "object",
+        "properties": {
+          "owner": {"$ref": "#/components/schemas/Owner", "nullable": true},
+          "home": {"oneOf": [{"$ref": "#/components/schemas/Owner"}], "nullable": true},
+          "sitter": {"anyOf": [{"$ref": "#/components/schemas/Owner"}, {"type": "null"}]},
+          "address": {"type": "object", "properties": {"street": {"type": "string"}}},
+          "tag": {"type": ["string", "null"]},
+          "shape": {
+            "oneOf": [{"$ref": "#/components/schemas/Circle"}, {"$ref": "#/components/schemas/Square"}],
+            "discriminator": {"propertyName": "kind", "mapping": {"circle": "#/components/schemas/Circle"}}
+          },
+          "value": {"anyOf": [{"type": "string"}, {"type": "integer"}, {"type": "null"}]}
+        }
+      },
+      "Circle": {"type": "object", "properties": {"kind": {"type": "string"}}},
+      "Square": {"type": "object", "properties": {"kind": {"type": "string"}}}
+    }
+  }
+}`
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestLint(t *testing.T) {
+	var got []string
+	for _, f := range Lint(parse(t, testSpec)) {
+		got = append(got, f.Pointer+": "+f.Severity.String()+": "+f.Rule)
+	}
+
+	want := []string{
+		"/paths/~1pets/post: error: duplicate-operation-id",
+		"/paths/~1ping/get: info: missing-operation-id",
+		"/paths/~1pets/post/responses/201: warning: response-without-content",
+		"/paths/~1ping/get/responses/200: warning: response-without-content",
+		"/components/schemas/Pet/properties/owner: error: nullable-ref",
+		"/components/schemas/Pet/properties/home: error: nullable-ref",
+		"/components/schemas/Pet/properties/tag: error: type-array",
+		"/components/schemas/Pet/properties/shape/discriminator: warning: discriminator-mapping",
+		"/components/schemas/Pet/properties/value/anyOf/2: warning: null-variant",
+		"/paths/~1pets/post/requestBody/content/application~1json/schema: info: duplicate-inline-schema",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLint_Messages(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{
+			name: "discriminator mapping by schema name",
+			doc: `{"components": {"schemas": {"Shape": {
+				"oneOf": [{"$ref": "#/components/schemas/Circle"}, {"$ref": "#/components/schemas/Square"}, {"$ref": "#/components/schemas/Line"}],
+				"discriminator": {"propertyName": "type", "mapping": {"circle": "Circle"}}
+			}}}}`,
+			want: "no mapping for Square, Line: ogen expects the schema names as type values",
+		},
+		{
+			name: "nested duplicates reported once",
+			doc: `{"paths": {"/a": {"get": {"operationId": "a", "responses": {"200": {"description": "ok", "content": {"application/json": {"schema":
+				{"type": "object", "properties": {"b": {"type": "object", "properties": {"c": {"type": "string"}}}}}
+			}}}}}}, "/b": {"get": {"operationId": "b", "responses": {"200": {"description": "ok", "content": {"application/json": {"schema":
+				{"type": "object", "properties": {"b": {"type": "object", "properties": {"c": {"type": "string"}}}}}
+			}}}}}}}}`,
+			want: "inline schema also at #/paths/~1b/get/responses/200/content/application~1json/schema: ogen generates a type for each",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := Lint(parse(t, tt.doc))
+			if len(findings) != 1 || findings[0].Message != tt.want {
+				t.Errorf("Lint() = %+v, want one finding %q", findings, tt.want)
+			}
+		})
+	}
+}
+
+func TestLint_Clean(t *testing.T) {
+	doc := `{
+	  "openapi": "3.0.3",
+	  "paths": {"/pets/{id}": {"delete": {"operationId": "deletePet", "responses": {"204": {"description": "deleted"}}}}},
+	  "components": {"schemas": {
+	    "Pet": {"type": "object", "properties": {
+	      "owner": {"allOf": [{"$ref": "#/components/schemas/Owner"}], "nullable": true},
+	      "sitter": {"oneOf": [{"$ref": "#/components/schemas/Owner"}, {"type": "null"}]},
+	      "value": {"oneOf": [{"type": "string"}, {"type": "integer"}], "nullable": true}
+	    }},
+	    "Owner": {"type": "object", "properties": {"name": {"type": "string"}}}
+	  }}
+	}`
+	if findings := Lint(parse(t, doc)); len(findings) != 0 {
+		t.Errorf("Lint() = %+v, want none", findings)
+	}
+}
+
+func TestRun(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "openapi.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "errors fail", args: []string{input}, wantErr: "4 findings at or above error"},
+		{name: "warnings fail", args: []string{"-fail-on", "warning", input}, wantErr: "8 findings at or above warning"},
+		{name: "none", args: []string{"-fail-on", "none", input}},
+		{name: "disabled", args: []string{"-disable", "nullable-ref, type-array,duplicate-operation-id", input}},
+		{name: "unknown severity", args: []string{"-fail-on", "fatal", input}, wantErr: `unknown severity "fatal"`},
+		{name: "unknown rule", args: []string{"-disable", "nullable", input}, wantErr: `unknown rule "nullable"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := run(tt.args)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("run() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("run() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+// Command ogen-specmapping writes out the discriminator mappings of an
+// OpenAPI spec that the spec leaves implicit.
+//
+// Without a mapping, ogen maps the names of the oneOf schemas to them, and
+// decodes {"kind": "dog"} as an error when the Dog schema pins kind to
+// "dog". It fails on anyOf unions without a mapping, and ignores the
+// variants a partial mapping leaves out. This tool adds a mapping entry for
+// every variant of a discriminated union that has none, from the value the
+// variant pins its discriminator property to, or its schema name:
+//
+//	"oneOf": [{"$ref": "#/components/schemas/Dog"}, {"$ref": "#/components/schemas/Cat"}],
+//	"discriminator": {"propertyName": "kind", "mapping": {
+//	  "dog": "#/components/schemas/Dog",
+//	  "cat": "#/components/schemas/Cat"
+//	}}
+//
+// Usage:
+//
+//	ogen-specmapping -o openapi.ogen.json [-infer] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// With -infer, unions without a discriminator get one if every variant pins
+// the same property to a value of its own. The rest of the spec is written
+// back unchanged, with its keys in their original order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specmapping: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specmapping", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	infer := fs.Bool("infer", false, "add a discriminator to unions whose variants all pin a property to values of their own")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specmapping -o <output.json> [-infer] <openapi.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Materialize(spec, *infer)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, n := range report.Notes {
+		fmt.Fprintf(os.Stderr, "ogen-specmapping: #%s: %s\n", n.Pointer, n.Message)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Added %d mapping entries to %d discriminators (%d inferred) in %s\n",
+		report.Entries, report.Discriminators, report.Inferred, *outputFile)
+	return nil
+}
+
+// Report lists what Materialize changed.
+type Report struct {
+	// Discriminators counts the discriminators given mapping entries.
+	Discriminators int
+	// Entries counts the mapping entries added.
+	Entries int
+	// Inferred counts the discriminators added to unions without one.
+	Inferred int
+	// Notes lists the unions left as they were, and why.
+	Notes []Note
+}
+
+// Note is a message about the union at a JSON pointer.
+type Note struct {
+	Pointer string
+	Message string
+}
+
+const schemasPrefix = "#/components/schemas/"
+
+// Materialize adds mapping entries to the discriminators of the oneOf and
+// anyOf unions of spec, for the variants no entry maps. A variant is mapped
+// from the value it pins its discriminator property to with a const or a
+// one-value enum, or else from its schema name, as OpenAPI implies. Unions
+// with variants that are not $refs to component schemas, or whose variants
+// map the same value, are left as they are, and noted. With infer, unions
+// without a discriminator get one if exactly one property is pinned by
+// every variant to a value of its own.
+func Materialize(spec *specdoc.Object, infer bool) (*Report, error) {
+	report := &Report{}
+	err := specdoc.Walk(spec, func(o *specdoc.Object, ptr string) error {
+		var variants []any
+		for _, key := range []string{"oneOf", "anyOf"} {
+			if list, ok := o.Get(key).([]any); ok {
+				variants = list
+				break
+			}
+		}
+		discriminator, hasDiscriminator := o.Get("discriminator").(*specdoc.Object)
+		if len(variants) == 0 || !hasDiscriminator && !infer {
+			return nil
+		}
+		note := func(format string, args ...any) {
+			report.Notes = append(report.Notes, Note{Pointer: ptr, Message: fmt.Sprintf(format, args...)})
+		}
+
+		refs := make([]string, len(variants))
+		schemas := make([]*specdoc.Object, len(variants))
+		for i, v := range variants {
+			variant, _ := v.(*specdoc.Object)
+			ref, _ := variant.Get("$ref").(string)
+			name, ok := strings.CutPrefix(ref, schemasPrefix)
+			schema, found := lookupRef(spec, ref)
+			if !ok || strings.Contains(name, "/") || !found {
+				if hasDiscriminator {
+					note("variant %d is not a $ref to a component schema; move it to components/schemas", i)
+				}
+				return nil
+			}
+			refs[i], schemas[i] = ref, schema
+		}
+
+		if !hasDiscriminator {
+			property, ok := inferProperty(spec, schemas)
+			if !ok {
+				return nil
+			}
+			discriminator = specdoc.NewObject()
+			discriminator.Set("propertyName", property)
+			o.Set("discriminator", discriminator)
+			report.Inferred++
+		}
+		property, _ := discriminator.Get("propertyName").(string)
+		if property == "" {
+			note("discriminator has no propertyName")
+			return nil
+		}
+
+		mapping, ok := discriminator.Get("mapping").(*specdoc.Object)
+		if !ok {
+			mapping = specdoc.NewObject()
+		}
+		mapped := make(map[string]bool)
+		for _, value := range mapping.Keys() {
+			if target, ok := mapping.Get(value).(string); ok {
+				if !strings.Contains(target, "#") {
+					// A mapping may name a schema without a $ref.
+					target = specdoc.Pointer(strings.TrimSuffix(schemasPrefix, "/"), target)
+				}
+				mapped[target] = true
+			}
+		}
+
+		entries := specdoc.NewObject()
+		for i, ref := range refs {
+			if mapped[ref] {
+				continue
+			}
+			value := specdoc.Unescape(strings.TrimPrefix(ref, schemasPrefix))
+			if pinned := pinnedValues(spec, schemas[i], property); len(pinned) == 1 {
+				value = pinned[0]
+			}
+			if mapping.Has(value) || entries.Has(value) {
+				note("variants map the same value %q; set the mapping of %s", value, ref)
+				return nil
+			}
+			entries.Set(value, ref)
+		}
+		if entries.Len() == 0 {
+			return nil
+		}
+		for _, value := range entries.Keys() {
+			mapping.Set(value, entries.Get(value))
+		}
+		discriminator.Set("mapping", mapping)
+		report.Discriminators++
+		report.Entries += entries.Len()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// inferProperty returns the property every schema pins to one value, a
+// different value for each schema, if there is exactly one.
+func inferProperty(spec *specdoc.Object, schemas []*specdoc.Object) (string, bool) {
+	var found []string
+	for _, property := range propertyNames(spec, schemas[0]) {
+		values := make(map[string]bool)
+		for _, schema := range schemas {
+			pinned := pinnedValues(spec, schema, property)
+			if len(pinned) != 1 || values[pinned[0]] {
+				break
+			}
+			values[pinned[0]] = true
+		}
+		if len(values) == len(schemas) {
+			found = append(found, property)
+		}
+	}
+	if len(found) != 1 {
+		return "", false
+	}
+	return found[0], true
+}
+
+// pinnedValues returns the string values a schema allows for a property,
+// by its const or enum, looking through $refs and allOf.
+func pinnedValues(spec *specdoc.Object, schema *specdoc.Object, property string) []string {
+	var values []string
+	for _, s := range members(spec, schema) {
+		props, _ := s.Get("properties").(*specdoc.Object)
+		prop, ok := props.Get(property).(*specdoc.Object)
+		if !ok {
+			continue
+		}
+		if ref, ok := prop.Get("$ref").(string); ok {
+			if prop, ok = lookupRef(spec, ref); !ok {
+				continue
+			}
+		}
+		if c, ok := prop.Get("const").(string); ok {
+			return []string{c}
+		}
+		enum, _ := prop.Get("enum").([]any)
+		for _, v := range enum {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		if len(values) > 0 {
+			return values
+		}
+	}
+	return nil
+}
+
+// propertyNames returns the names of the properties of a schema, looking
+// through $refs and allOf.
+func propertyNames(spec *specdoc.Object, schema *specdoc.Object) []string {
+	var names []string
+	for _, s := range members(spec, schema) {
+		props, _ := s.Get("properties").(*specdoc.Object)
+		for _, name := range props.Keys() {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// members returns a schema and the schemas of its allOf, with their local
+// $refs resolved, recursively.
+func members(spec *specdoc.Object, schema *specdoc.Object) []*specdoc.Object {
+	var list []*specdoc.Object
+	var add func(s *specdoc.Object)
+	add = func(s *specdoc.Object) {
+		if ref, ok := s.Get("$ref").(string); ok {
+			if s, ok = lookupRef(spec, ref); !ok {
+				return
+			}
+		}
+		if slices.Contains(list, s) {
+			return
+		}
+		list = append(list, s)
+		allOf, _ := s.Get("allOf").([]any)
+		for _, v := range allOf {
+			if member, ok := v.(*specdoc.Object); ok {
+				add(member)
+			}
+		}
+	}
+	add(schema)
+	return list
+}
+
+// lookupRef returns the object a local $ref points to.
+func lookupRef(spec *specdoc.Object, ref string) (*specdoc.Object, bool) {
+	ptr, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, false
+	}
+	return specdoc.LookupObject(spec, ptr)
+}
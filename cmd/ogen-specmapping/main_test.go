@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1"},
+  "paths": {},
+  "components": {
+    "schemas": {
+      "Pet": {
+        "oneOf": [{"$ref": "#/components/schemas/Dog"}, {"$ref": "#/components/schemas/Cat"}, {"$ref": "#/components/schemas/Bird"}],
+        "discriminator": {"propertyName": "kind"}
+      },
+      "Partial": {
+        "anyOf": [{"$ref": "#/components/schemas/Dog"}, {"$ref": "#/components/schemas/Cat"}],
+        "discriminator": {"propertyName": "kind", "mapping": {"hound": "Dog"}}
+      },
+      "Inferred": {
+        "oneOf": [{"$ref": "#/components/schemas/Dog"}, {"$ref": "#/components/schemas/Cat"}]
+      },
+      "Inline": {
+        "oneOf": [{"$ref": "#/components/schemas/Dog"}, {"type": "object"}],
+        "discriminator": {"propertyName": "kind"}
+      },
+      "Clash": {
+        "oneOf": [{"$ref": "#/components/schemas/Cat"}, {"$ref": "#/components/schemas/Kitten"}],
+        "discriminator": {"propertyName": "kind"}
+      },
+      "Animal": {"type": "object", "required": ["kind"], "properties": {"kind": {"type": "string"}, "name": {"type": "string"}}},
+      "Dog": {"type": "object", "required": ["kind"], "properties": {"kind": {"type": "string", "enum": ["dog"]}, "bark": {"type": "string"}}},
+      "Cat": {"allOf": [{"$ref": "#/components/schemas/Animal"}, {"type": "object", "properties": {"kind": {"$ref": "#/components/schemas/CatKind"}}}]},
+      "CatKind": {"type": "string", "const": "cat"},
+      "Kitten": {"type": "object", "properties": {"kind": {"type": "string", "enum": ["cat"]}}},
+      "Bird": {"type": "object", "properties": {"kind": {"type": "string", "enum": ["bird", "parrot"]}}}
+    }
+  }
+}`
+
+func TestMaterialize(t *testing.T) {
+	tests := []struct {
+		name      string
+		infer     bool
+		want      []string
+		wantNotes []string
+		wantAdded int
+	}{
+		{
+			name: "mappings",
+			want: []string{
+				// Bird pins two values, and is mapped by its name.
+				`"discriminator":{"propertyName":"kind","mapping":{"dog":"#/components/schemas/Dog","cat":"#/components/schemas/Cat","Bird":"#/components/schemas/Bird"}}`,
+				`"mapping":{"hound":"Dog","cat":"#/components/schemas/Cat"}`,
+				`"Inferred":{"oneOf":[{"$ref":"#/components/schemas/Dog"},{"$ref":"#/components/schemas/Cat"}]}`,
+				`"Inline":{"oneOf":[{"$ref":"#/components/schemas/Dog"},{"type":"object"}],"discriminator":{"propertyName":"kind"}}`,
+			},
+			wantNotes: []string{
+				"/components/schemas/Inline: variant 1 is not a $ref to a component schema; move it to components/schemas",
+				`/components/schemas/Clash: variants map the same value "cat"; set the mapping of #/components/schemas/Kitten`,
+			},
+			wantAdded: 4,
+		},
+		{
+			name:  "infer",
+			infer: true,
+			want: []string{
+				`"Inferred":{"oneOf":[{"$ref":"#/components/schemas/Dog"},{"$ref":"#/components/schemas/Cat"}],"discriminator":{"propertyName":"kind","mapping":{"dog":"#/components/schemas/Dog","cat":"#/components/schemas/Cat"}}}`,
+			},
+			wantNotes: []string{
+				"/components/schemas/Inline: variant 1 is not a $ref to a component schema; move it to components/schemas",
+				`/components/schemas/Clash: variants map the same value "cat"; set the mapping of #/components/schemas/Kitten`,
+			},
+			wantAdded: 6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := specdoc.Parse([]byte(testSpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := Materialize(spec, tt.infer)
+			if err != nil {
+				t.Fatalf("Materialize: %v", err)
+			}
+
+			got, err := json.Marshal(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("output missing %s:\n%s", want, got)
+				}
+			}
+			var notes []string
+			for _, n := range report.Notes {
+				notes = append(notes, n.Pointer+": "+n.Message)
+			}
+			if !reflect.DeepEqual(notes, tt.wantNotes) {
+				t.Errorf("notes = %q, want %q", notes, tt.wantNotes)
+			}
+			if report.Entries != tt.wantAdded {
+				t.Errorf("Entries = %d, want %d", report.Entries, tt.wantAdded)
+			}
+		})
+	}
+}
+
+func TestInferProperty(t *testing.T) {
+	spec, err := specdoc.Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := func(name string) *specdoc.Object {
+		o, _ := lookupRef(spec, schemasPrefix+name)
+		return o
+	}
+	tests := []struct {
+		schemas []string
+		want    string
+	}{
+		{[]string{"Dog", "Cat"}, "kind"},
+		// Both pin kind to cat.
+		{[]string{"Cat", "Kitten"}, ""},
+		// Bird pins kind to two values.
+		{[]string{"Dog", "Bird"}, ""},
+		{[]string{"Dog", "Animal"}, ""},
+	}
+	for _, tt := range tests {
+		var schemas []*specdoc.Object
+		for _, name := range tt.schemas {
+			schemas = append(schemas, schema(name))
+		}
+		if got, _ := inferProperty(spec, schemas); got != tt.want {
+			t.Errorf("inferProperty(%v) = %q, want %q", tt.schemas, got, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{input}); err == nil {
+		t.Error("run without -o: want error")
+	}
+
+	if err := run([]string{"-o", output, "-infer", input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `"dog": "#/components/schemas/Dog"`) {
+		t.Errorf("output has no mapping:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
@@ -0,0 +1,487 @@
+// Command ogen-specmerge merges several OpenAPI specs into one, for
+// generating a single client.
+//
+// Services that publish a spec each, such as microservices behind one
+// gateway, get a client package per spec from ogen, with its own types for
+// shared schemas and its own configuration. This tool merges their paths,
+// components, tags and security schemes into one spec, and reports what
+// cannot be merged:
+//
+//	ogen-specmerge -o openapi.json users.json orders.json billing.json
+//
+// The first spec provides the rest of the document, such as info and
+// servers. Identical components are merged. A component of a later spec
+// whose name the merged spec has with another definition is a conflict;
+// with -rename-conflicts it is renamed with the pascal-cased name of its
+// file as prefix, and its references follow.
+//
+// Usage:
+//
+//	ogen-specmerge -o openapi.json [-rename-conflicts] <spec.json>...
+//	ogen --package api --target internal/api --clean openapi.json
+//
+// Keys are written in their original order, those of later specs after the
+// ones of the merged spec.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specmerge: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specmerge", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the merged spec to")
+	renameConflicts := fs.Bool("rename-conflicts", false, "prefix conflicting components of later specs with their file name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 2 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specmerge -o <output.json> [-rename-conflicts] <spec.json> <spec.json>...")
+	}
+
+	var inputs []Input
+	for _, filename := range fs.Args() {
+		spec, err := specdoc.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, Input{Name: filename, Spec: spec})
+	}
+
+	merged, report, err := Merge(inputs, *renameConflicts)
+	for _, c := range report.Conflicts {
+		fmt.Fprintf(os.Stderr, "ogen-specmerge: %s\n", c)
+	}
+	if err != nil {
+		return err
+	}
+	for _, r := range report.Renamed {
+		fmt.Fprintf(os.Stderr, "ogen-specmerge: %s: renamed %s to %s\n", r.File, r.Old, r.New)
+	}
+	for _, note := range report.Notes {
+		fmt.Fprintf(os.Stderr, "ogen-specmerge: %s\n", note)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, merged); err != nil {
+		return err
+	}
+
+	fmt.Printf("Merged %d specs with %d operations into %s\n", len(inputs), report.Operations, *outputFile)
+	return nil
+}
+
+// Input is a spec to merge and the name of its file.
+type Input struct {
+	Name string
+	Spec *specdoc.Object
+}
+
+// Report lists what Merge did and could not do.
+type Report struct {
+	// Operations is the number of operations of the merged spec.
+	Operations int
+	// Conflicts describes what could not be merged. Merge fails if there
+	// are any.
+	Conflicts []string
+	// Renamed lists the components renamed with -rename-conflicts.
+	Renamed []Rename
+	// Notes describes merged specs whose settings ogen does not follow.
+	Notes []string
+}
+
+// Rename is a component of a spec renamed to avoid a conflict, as JSON
+// pointers.
+type Rename struct {
+	File string
+	Old  string
+	New  string
+}
+
+// Merge merges the specs of inputs, which it modifies, into the first. It
+// returns an error, and the conflicts in the report, if two specs have the
+// same operation, operationId or security scheme name, path items of the
+// same path with different path-level keys, paths that differ only in the
+// names of their parameters, or a component name with different
+// definitions that is not renamed.
+func Merge(inputs []Input, renameConflicts bool) (*specdoc.Object, *Report, error) {
+	report := &Report{}
+	merged := inputs[0].Spec
+	version := majorMinor(merged)
+	opIDs := make(map[string]string)
+	templates := make(map[string]string)
+	owners := make(map[string]string)
+	componentOwners := make(map[string]string)
+	if components, ok := merged.Get("components").(*specdoc.Object); ok {
+		addOwners(componentOwners, components, inputs[0].Name)
+	}
+	for _, key := range specdoc.ItemMaps {
+		items, _ := merged.Get(key).(*specdoc.Object)
+		for _, path := range items.Keys() {
+			owners[key+" "+path] = inputs[0].Name
+			templates[key+" "+template(path)] = path
+			item, _ := items.Get(path).(*specdoc.Object)
+			for _, method := range item.Keys() {
+				op, ok := item.Get(method).(*specdoc.Object)
+				if !ok || !specdoc.IsMethod(method) {
+					continue
+				}
+				report.Operations++
+				owners[key+" "+method+" "+path] = inputs[0].Name
+				if id, ok := op.Get("operationId").(string); ok && id != "" {
+					opIDs[id] = inputs[0].Name
+				}
+			}
+		}
+	}
+
+	for _, in := range inputs[1:] {
+		spec := in.Spec
+		if v := majorMinor(spec); v != version {
+			report.Conflicts = append(report.Conflicts, fmt.Sprintf("%s: openapi %s, not %s as %s", in.Name, v, version, inputs[0].Name))
+			continue
+		}
+
+		report.Conflicts = append(report.Conflicts, mergeComponents(merged, in, componentOwners, renameConflicts, report)...)
+		inheritRoot(merged, in, report)
+
+		for _, key := range specdoc.ItemMaps {
+			items, _ := spec.Get(key).(*specdoc.Object)
+			if items == nil {
+				continue
+			}
+			target, ok := merged.Get(key).(*specdoc.Object)
+			if !ok {
+				target = specdoc.NewObject()
+				merged.Set(key, target)
+			}
+			for _, path := range items.Keys() {
+				item, _ := items.Get(path).(*specdoc.Object)
+				if prev, ok := templates[key+" "+template(path)]; ok && prev != path {
+					report.Conflicts = append(report.Conflicts, fmt.Sprintf("%s: %s and %s of %s differ only in parameter names", in.Name, path, prev, owners[key+" "+prev]))
+					continue
+				}
+				templates[key+" "+template(path)] = path
+				for _, method := range item.Keys() {
+					op, ok := item.Get(method).(*specdoc.Object)
+					if !ok || !specdoc.IsMethod(method) {
+						continue
+					}
+					report.Operations++
+					ref := key + " " + method + " " + path
+					if prev, ok := owners[ref]; ok {
+						report.Conflicts = append(report.Conflicts, fmt.Sprintf("%s: %s %s is in %s too", in.Name, strings.ToUpper(method), path, prev))
+					}
+					owners[ref] = in.Name
+					if id, ok := op.Get("operationId").(string); ok && id != "" {
+						if prev, ok := opIDs[id]; ok {
+							report.Conflicts = append(report.Conflicts, fmt.Sprintf("%s: operationId %s is in %s too", in.Name, id, prev))
+						}
+						opIDs[id] = in.Name
+					}
+				}
+
+				existing, ok := target.Get(path).(*specdoc.Object)
+				if !ok {
+					target.Set(path, item)
+					owners[key+" "+path] = in.Name
+					continue
+				}
+				for _, k := range item.Keys() {
+					if specdoc.IsMethod(k) {
+						existing.Set(k, item.Get(k))
+					} else if existing.Has(k) && specdoc.Compact(existing.Get(k)) != specdoc.Compact(item.Get(k)) {
+						report.Conflicts = append(report.Conflicts, fmt.Sprintf("%s: %s of %s differs from %s", in.Name, k, path, owners[key+" "+path]))
+					} else {
+						existing.Set(k, item.Get(k))
+					}
+				}
+			}
+		}
+
+		mergeTags(merged, spec)
+	}
+
+	if len(report.Conflicts) > 0 {
+		if len(report.Conflicts) == 1 {
+			return nil, report, fmt.Errorf("1 conflict")
+		}
+		return nil, report, fmt.Errorf("%d conflicts", len(report.Conflicts))
+	}
+	return merged, report, nil
+}
+
+// mergeComponents adds the components of in to merged and returns the
+// conflicts. Components identical to one of merged are dropped. With
+// renameConflicts, components with the name of another one of merged are
+// renamed first, until no conflicts are left, as a rename changes the
+// components that reference the renamed one.
+func mergeComponents(merged *specdoc.Object, in Input, owners map[string]string, renameConflicts bool, report *Report) []string {
+	components, ok := in.Spec.Get("components").(*specdoc.Object)
+	if !ok {
+		return nil
+	}
+	target, ok := merged.Get("components").(*specdoc.Object)
+	if !ok {
+		target = specdoc.NewObject()
+		merged.Set("components", target)
+	}
+
+	for renameConflicts {
+		renames := make(map[string]string)
+		for _, c := range componentConflicts(target, components, owners) {
+			if c.kind == "securitySchemes" {
+				continue
+			}
+			byName, _ := components.Get(c.kind).(*specdoc.Object)
+			prefix := specdoc.Pascal(strings.TrimSuffix(filepath.Base(in.Name), filepath.Ext(in.Name)))
+			name := prefix + specdoc.Pascal(c.name)
+			for i := 2; taken(target, components, c.kind, name); i++ {
+				name = fmt.Sprintf("%s%s%d", prefix, specdoc.Pascal(c.name), i)
+			}
+			byName.Replace(c.name, name, byName.Get(c.name))
+			old := specdoc.Pointer(specdoc.Pointer("/components", c.kind), c.name)
+			renames[old] = specdoc.Pointer(specdoc.Pointer("/components", c.kind), name)
+			report.Renamed = append(report.Renamed, Rename{File: in.Name, Old: "#" + old, New: "#" + renames[old]})
+		}
+		if len(renames) == 0 {
+			break
+		}
+		rewriteRefs(in.Spec, renames)
+	}
+
+	var conflicts []string
+	for _, c := range componentConflicts(target, components, owners) {
+		conflicts = append(conflicts, fmt.Sprintf("%s: %s", in.Name, c.message))
+	}
+	if len(conflicts) > 0 {
+		return conflicts
+	}
+
+	addOwners(owners, components, in.Name)
+	for _, kind := range components.Keys() {
+		byName, ok := components.Get(kind).(*specdoc.Object)
+		if !ok || strings.HasPrefix(kind, "x-") {
+			if !target.Has(kind) {
+				target.Set(kind, components.Get(kind))
+			}
+			continue
+		}
+		existing, ok := target.Get(kind).(*specdoc.Object)
+		if !ok {
+			existing = specdoc.NewObject()
+			target.Set(kind, existing)
+		}
+		for _, name := range byName.Keys() {
+			if !existing.Has(name) {
+				existing.Set(name, byName.Get(name))
+			}
+		}
+	}
+	return nil
+}
+
+// conflict is a component of a spec that cannot be merged as it is.
+type conflict struct {
+	kind    string
+	name    string
+	message string
+}
+
+// componentConflicts returns the components of components with the name of
+// a different one of target, or, for schemas, whose name gives the same Go
+// type name as another schema: pet_list and PetList are both PetList. owners
+// maps the components of target to the file they are from.
+func componentConflicts(target, components *specdoc.Object, owners map[string]string) []conflict {
+	var conflicts []conflict
+	for _, kind := range components.Keys() {
+		byName, ok := components.Get(kind).(*specdoc.Object)
+		if !ok || strings.HasPrefix(kind, "x-") {
+			continue
+		}
+		existing, _ := target.Get(kind).(*specdoc.Object)
+		normalized := make(map[string]string)
+		for _, name := range existing.Keys() {
+			normalized[specdoc.Normalize(name)] = name
+		}
+		for _, name := range byName.Keys() {
+			ptr := specdoc.Pointer(specdoc.Pointer("#/components", kind), name)
+			switch prev, ok := normalized[specdoc.Normalize(name)]; {
+			case existing.Has(name):
+				if specdoc.Compact(existing.Get(name)) != specdoc.Compact(byName.Get(name)) {
+					conflicts = append(conflicts, conflict{kind, name, fmt.Sprintf("%s differs from the one of %s", ptr, owners[ptr])})
+				}
+			case ok && kind == "schemas":
+				other := specdoc.Pointer("#/components/schemas", prev)
+				conflicts = append(conflicts, conflict{kind, name, fmt.Sprintf("%s and %s of %s give the same Go type", ptr, other, owners[other])})
+			}
+		}
+	}
+	return conflicts
+}
+
+// addOwners records file as the owner of the components of components that
+// have none yet.
+func addOwners(owners map[string]string, components *specdoc.Object, file string) {
+	for _, kind := range components.Keys() {
+		byName, ok := components.Get(kind).(*specdoc.Object)
+		if !ok || strings.HasPrefix(kind, "x-") {
+			continue
+		}
+		for _, name := range byName.Keys() {
+			ptr := specdoc.Pointer(specdoc.Pointer("#/components", kind), name)
+			if _, ok := owners[ptr]; !ok {
+				owners[ptr] = file
+			}
+		}
+	}
+}
+
+// taken reports whether a component name is used in target or components.
+func taken(target, components *specdoc.Object, kind, name string) bool {
+	a, _ := target.Get(kind).(*specdoc.Object)
+	b, _ := components.Get(kind).(*specdoc.Object)
+	return a.Has(name) || b.Has(name)
+}
+
+// rewriteRefs points the local $refs and discriminator mappings of spec to
+// renamed components at their new names. renames maps old to new component
+// pointers.
+func rewriteRefs(spec *specdoc.Object, renames map[string]string) {
+	rename := func(ref string) string {
+		for old, ptr := range renames {
+			if ref == "#"+old || strings.HasPrefix(ref, "#"+old+"/") {
+				return "#" + ptr + ref[len(old)+1:]
+			}
+		}
+		return ref
+	}
+	var scan func(v any)
+	scan = func(v any) {
+		switch v := v.(type) {
+		case *specdoc.Object:
+			for _, key := range v.Keys() {
+				if ref, ok := v.Get(key).(string); ok && key == "$ref" {
+					v.Set(key, rename(ref))
+				}
+				if discriminator, ok := v.Get(key).(*specdoc.Object); ok && key == "discriminator" {
+					mapping, _ := discriminator.Get("mapping").(*specdoc.Object)
+					for _, value := range mapping.Keys() {
+						ref, _ := mapping.Get(value).(string)
+						if strings.HasPrefix(ref, "#/") {
+							mapping.Set(value, rename(ref))
+						} else if ptr, ok := renames[specdoc.Pointer("/components/schemas", ref)]; ok && ref != "" {
+							mapping.Set(value, strings.TrimPrefix(ptr, "/components/schemas/"))
+						}
+					}
+				}
+				scan(v.Get(key))
+			}
+		case []any:
+			for _, elem := range v {
+				scan(elem)
+			}
+		}
+	}
+	scan(spec)
+}
+
+// inheritRoot copies the security requirements and servers of the root of
+// in that differ from those of merged to its operations, which would
+// otherwise inherit the ones of merged.
+func inheritRoot(merged *specdoc.Object, in Input, report *Report) {
+	security := in.Spec.Get("security")
+	if specdoc.Compact(security) != specdoc.Compact(merged.Get("security")) {
+		if security == nil {
+			security = []any{}
+		}
+		forEachItem(in.Spec, func(item *specdoc.Object) {
+			for _, method := range item.Keys() {
+				if op, ok := item.Get(method).(*specdoc.Object); ok && specdoc.IsMethod(method) && !op.Has("security") {
+					op.Set("security", specdoc.Clone(security))
+				}
+			}
+		})
+	}
+
+	servers := in.Spec.Get("servers")
+	if servers != nil && specdoc.Compact(servers) != specdoc.Compact(merged.Get("servers")) {
+		forEachItem(in.Spec, func(item *specdoc.Object) {
+			for _, method := range item.Keys() {
+				if op, ok := item.Get(method).(*specdoc.Object); ok && specdoc.IsMethod(method) && !op.Has("servers") && !item.Has("servers") {
+					op.Set("servers", specdoc.Clone(servers))
+				}
+			}
+		})
+		report.Notes = append(report.Notes, fmt.Sprintf("%s: servers differ, set on its operations; ogen uses the servers of the root only", in.Name))
+	}
+}
+
+// forEachItem calls fn for the path items of spec.
+func forEachItem(spec *specdoc.Object, fn func(item *specdoc.Object)) {
+	for _, key := range specdoc.ItemMaps {
+		items, _ := spec.Get(key).(*specdoc.Object)
+		for _, path := range items.Keys() {
+			if item, ok := items.Get(path).(*specdoc.Object); ok {
+				fn(item)
+			}
+		}
+	}
+}
+
+// mergeTags appends the tags of spec that merged does not have.
+func mergeTags(merged, spec *specdoc.Object) {
+	list, _ := spec.Get("tags").([]any)
+	if len(list) == 0 {
+		return
+	}
+	tags, _ := merged.Get("tags").([]any)
+	names := make(map[string]bool)
+	for _, tag := range tags {
+		o, _ := tag.(*specdoc.Object)
+		if name, ok := o.Get("name").(string); ok {
+			names[name] = true
+		}
+	}
+	for _, tag := range list {
+		o, _ := tag.(*specdoc.Object)
+		if name, _ := o.Get("name").(string); !names[name] {
+			tags = append(tags, tag)
+			names[name] = true
+		}
+	}
+	merged.Set("tags", tags)
+}
+
+// majorMinor returns the major and minor version of the openapi key of spec,
+// such as 3.0.
+func majorMinor(spec *specdoc.Object) string {
+	v, _ := spec.Get("openapi").(string)
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return v
+	}
+	return parts[0] + "." + parts[1]
+}
+
+var paramRE = regexp.MustCompile(`\{[^}]*\}`)
+
+// template returns path with the names of its parameters left out, so that
+// /pets/{id} and /pets/{petId} are the same.
+func template(path string) string {
+	return paramRE.ReplaceAllString(path, "{}")
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const usersSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Users", "version": "1"},
+  "servers": [{"url": "https://api.example.com"}],
+  "security": [{"apiKey": []}],
+  "tags": [{"name": "users"}],
+  "paths": {
+    "/users": {"get": {"operationId": "listUsers", "tags": ["users"], "responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}}, "default": {"$ref": "#/components/responses/Error"}}}},
+    "/shared": {"parameters": [{"name": "v", "in": "query", "schema": {"type": "string"}}], "get": {"operationId": "getShared", "responses": {"200": {"description": "ok"}}}}
+  },
+  "components": {
+    "schemas": {
+      "User": {"type": "object", "properties": {"name": {"type": "string"}}},
+      "Error": {"type": "object", "properties": {"message": {"type": "string"}}}
+    },
+    "responses": {"Error": {"description": "error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}},
+    "securitySchemes": {"apiKey": {"type": "apiKey", "in": "header", "name": "X-Key"}}
+  }
+}`
+
+const ordersSpec = `{
+  "openapi": "3.0.1",
+  "info": {"title": "Orders", "version": "2"},
+  "servers": [{"url": "https://orders.example.com"}],
+  "tags": [{"name": "orders"}, {"name": "users"}],
+  "paths": {
+    "/orders": {"post": {"operationId": "createOrder", "tags": ["orders"], "security": [{"apiKey": []}], "requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Order"}}}}, "responses": {"201": {"description": "created"}, "default": {"$ref": "#/components/responses/Error"}}}},
+    "/shared": {"parameters": [{"name": "v", "in": "query", "schema": {"type": "string"}}], "post": {"operationId": "postShared", "responses": {"204": {"description": "ok"}}}}
+  },
+  "components": {
+    "schemas": {
+      "Order": {"type": "object", "properties": {"user": {"$ref": "#/components/schemas/User"}, "shape": {"oneOf": [{"$ref": "#/components/schemas/Error"}], "discriminator": {"propertyName": "kind", "mapping": {"error": "Error"}}}}},
+      "User": {"type": "object", "properties": {"name": {"type": "string"}}},
+      "Error": {"type": "object", "properties": {"code": {"type": "integer"}}}
+    },
+    "responses": {"Error": {"description": "error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}},
+    "securitySchemes": {"apiKey": {"type": "apiKey", "in": "header", "name": "X-Key"}}
+  }
+}`
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// lookup returns the value at ptr of spec as compact JSON.
+func lookup(spec *specdoc.Object, ptr string) string {
+	v, _ := specdoc.Lookup(spec, ptr)
+	return specdoc.Compact(v)
+}
+
+func TestMerge(t *testing.T) {
+	inputs := []Input{{"users.json", parse(t, usersSpec)}, {"orders.json", parse(t, ordersSpec)}}
+	merged, report, err := Merge(inputs, true)
+	if err != nil {
+		t.Fatalf("Merge: %v (%v)", err, report.Conflicts)
+	}
+
+	wantRenamed := []Rename{
+		{File: "orders.json", Old: "#/components/schemas/Error", New: "#/components/schemas/OrdersError"},
+		{File: "orders.json", Old: "#/components/responses/Error", New: "#/components/responses/OrdersError"},
+	}
+	if !reflect.DeepEqual(report.Renamed, wantRenamed) {
+		t.Errorf("renamed = %+v, want %+v", report.Renamed, wantRenamed)
+	}
+	if report.Operations != 4 || len(report.Notes) != 1 {
+		t.Errorf("report = %+v", report)
+	}
+
+	tests := []struct {
+		ptr  string
+		want string
+	}{
+		{"/info/title", `"Users"`},
+		{"/tags", `[{"name":"users"},{"name":"orders"}]`},
+		{"/paths/~1shared/post/operationId", `"postShared"`},
+		// The operation of a spec without global security gets none, and
+		// the servers of its spec.
+		{"/paths/~1shared/post/security", `[]`},
+		{"/paths/~1shared/post/servers", `[{"url":"https://orders.example.com"}]`},
+		{"/paths/~1shared/get/servers", `null`},
+		{"/paths/~1orders/post/security", `[{"apiKey":[]}]`},
+		{"/paths/~1orders/post/responses/default/$ref", `"#/components/responses/OrdersError"`},
+		{"/components/responses/OrdersError/content/application~1json/schema/$ref", `"#/components/schemas/OrdersError"`},
+		{"/components/schemas/Order/properties/user/$ref", `"#/components/schemas/User"`},
+		{"/components/schemas/Order/properties/shape/discriminator/mapping/error", `"OrdersError"`},
+		{"/components/schemas/Error/properties/message/type", `"string"`},
+		{"/components/schemas/OrdersError/properties/code/type", `"integer"`},
+	}
+	for _, tt := range tests {
+		if got := lookup(merged, tt.ptr); got != tt.want {
+			t.Errorf("#%s = %s, want %s", tt.ptr, got, tt.want)
+		}
+	}
+
+	schemas, _ := specdoc.Lookup(merged, "/components/schemas")
+	if names := schemas.(*specdoc.Object).Keys(); !reflect.DeepEqual(names, []string{"User", "Error", "Order", "OrdersError"}) {
+		t.Errorf("schemas = %v", names)
+	}
+}
+
+func TestMerge_Conflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		second string
+		rename bool
+		want   []string
+	}{
+		{
+			name:   "components",
+			second: ordersSpec,
+			// responses/Error is the same text, but only conflicts once renamed.
+			want: []string{"orders.json: #/components/schemas/Error differs from the one of users.json"},
+		},
+		{
+			name:   "operations",
+			second: `{"openapi": "3.0.0", "paths": {"/users": {"get": {"operationId": "listUsers"}}, "/shared": {"parameters": [], "put": {"operationId": "listUsers"}}}}`,
+			rename: true,
+			want: []string{
+				"orders.json: GET /users is in users.json too",
+				"orders.json: operationId listUsers is in users.json too",
+				"orders.json: operationId listUsers is in orders.json too",
+				"orders.json: parameters of /shared differs from users.json",
+			},
+		},
+		{
+			name:   "parameter names",
+			second: `{"openapi": "3.0.0", "webhooks": {"/users": {}}, "paths": {"/users/{id}": {}, "/users/{userId}": {}}}`,
+			want:   []string{"orders.json: /users/{userId} and /users/{id} of orders.json differ only in parameter names"},
+		},
+		{
+			name:   "security schemes and Go type names",
+			second: `{"openapi": "3.0.0", "components": {"schemas": {"user": {"type": "string"}}, "securitySchemes": {"apiKey": {"type": "http", "scheme": "bearer"}}}}`,
+			rename: true,
+			want: []string{
+				"orders.json: #/components/securitySchemes/apiKey differs from the one of users.json",
+			},
+		},
+		{
+			name:   "version",
+			second: `{"openapi": "3.1.0"}`,
+			want:   []string{"orders.json: openapi 3.1, not 3.0 as users.json"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputs := []Input{{"users.json", parse(t, usersSpec)}, {"orders.json", parse(t, tt.second)}}
+			_, report, err := Merge(inputs, tt.rename)
+			if err == nil {
+				t.Fatal("Merge succeeded")
+			}
+			if !reflect.DeepEqual(report.Conflicts, tt.want) {
+				t.Errorf("conflicts = %q, want %q", report.Conflicts, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	users := filepath.Join(dir, "users.json")
+	orders := filepath.Join(dir, "orders.json")
+	output := filepath.Join(dir, "openapi.json")
+	if err := os.WriteFile(users, []byte(usersSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(orders, []byte(ordersSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, users, orders}); err == nil || err.Error() != "1 conflict" {
+		t.Errorf("run without -rename-conflicts: error = %v", err)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Error("output written despite conflicts")
+	}
+
+	if err := run([]string{"-o", output, "-rename-conflicts", users, orders}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(got); !strings.Contains(out, `"/users"`) || !strings.Contains(out, `"/orders"`) || !strings.Contains(out, `"OrdersError"`) {
+		t.Errorf("output is not the merged spec:\n%s", got)
+	}
+
+	// The input specs are not modified.
+	if orig, _ := os.ReadFile(orders); string(orig) != ordersSpec {
+		t.Error("input spec was modified")
+	}
+}
@@ -0,0 +1,425 @@
+// Command ogen-specmultipart rewrites the multipart/form-data request bodies
+// of an OpenAPI spec into the form ogen generates.
+//
+// ogen generates a multipart body as a struct of its parts, and a part of a
+// binary string as an ht.MultipartFile. File uploads are written in many
+// other ways, which ogen fails on or, with ignore_not_implemented, skips the
+// form for: Swagger's file type, the contentMediaType of 3.1, a form
+// schema without a type, or an encoding content type for the file. This tool
+// rewrites them to the structure ogen supports:
+//
+//	"file": {"type": "file"}
+//	"file": {"type": "string", "format": "binary"}
+//
+//	"file": {"type": "string", "contentMediaType": "image/png"}
+//	"file": {"type": "string", "contentMediaType": "image/png", "format": "binary"}
+//
+//	"encoding": {"file": {"contentType": "image/png"}, "meta": {"contentType": "application/json; charset=utf-8"}}
+//	"encoding": {"file": {}, "meta": {"contentType": "application/json"}}
+//
+// Usage:
+//
+//	ogen-specmultipart -o openapi.ogen.json openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// Forms it cannot rewrite, such as a oneOf of forms, are reported on stderr.
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"mime"
+	"os"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specmultipart: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specmultipart", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specmultipart -o <output.json> <openapi.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Normalize(spec)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, c := range report.Changes {
+		fmt.Fprintf(os.Stderr, "ogen-specmultipart: #%s: %s\n", c.Pointer, c.Message)
+	}
+	for _, n := range report.Notes {
+		fmt.Fprintf(os.Stderr, "ogen-specmultipart: #%s: %s\n", n.Pointer, n.Message)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Rewrote %d schemas and encodings of %d multipart bodies in %s\n", len(report.Changes), report.Bodies, *outputFile)
+	return nil
+}
+
+// Report lists what Normalize changed, and what it left.
+type Report struct {
+	// Bodies counts the multipart/form-data request bodies.
+	Bodies int
+	// Changes lists the rewritten schemas and encodings.
+	Changes []Change
+	// Notes lists the forms ogen cannot generate that were left as they
+	// were, and why.
+	Notes []Note
+}
+
+// Change is a schema or encoding rewritten at a JSON pointer of the spec.
+type Change struct {
+	Pointer string
+	Message string
+}
+
+// Note is a message about the schema or encoding at a JSON pointer.
+type Note struct {
+	Pointer string
+	Message string
+}
+
+// multipart is the media type ogen generates a form of parts for.
+const multipart = "multipart/form-data"
+
+type normalizer struct {
+	spec   *specdoc.Object
+	report *Report
+	// done holds the pointers of the schemas already rewritten, which
+	// bodies share through $refs.
+	done map[string]bool
+}
+
+// Normalize rewrites the multipart/form-data request bodies of spec, and the
+// schemas they $ref, into the forms ogen generates.
+func Normalize(spec *specdoc.Object) (*Report, error) {
+	n := &normalizer{spec: spec, report: &Report{}, done: map[string]bool{}}
+	err := specdoc.Walk(spec, func(o *specdoc.Object, ptr string) error {
+		if !isRequestBody(ptr) {
+			return nil
+		}
+		content, ok := o.Get("content").(*specdoc.Object)
+		if !ok {
+			return nil
+		}
+		for _, mediaType := range content.Keys() {
+			media, ok := content.Get(mediaType).(*specdoc.Object)
+			if mt, _, err := mime.ParseMediaType(mediaType); ok && err == nil && mt == multipart {
+				n.body(media, specdoc.Pointer(specdoc.Pointer(ptr, "content"), mediaType))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return n.report, nil
+}
+
+func (n *normalizer) change(ptr, format string, args ...any) {
+	n.report.Changes = append(n.report.Changes, Change{Pointer: ptr, Message: fmt.Sprintf(format, args...)})
+}
+
+func (n *normalizer) note(ptr, format string, args ...any) {
+	n.report.Notes = append(n.report.Notes, Note{Pointer: ptr, Message: fmt.Sprintf(format, args...)})
+}
+
+// body rewrites the schema of a multipart media type and its encoding.
+func (n *normalizer) body(media *specdoc.Object, ptr string) {
+	if !media.Has("schema") {
+		return
+	}
+	n.report.Bodies++
+	schema, schemaPtr := n.resolve(media.Get("schema"), specdoc.Pointer(ptr, "schema"))
+	if schema == nil {
+		n.note(specdoc.Pointer(ptr, "schema"), "the schema is not a local object; left as is")
+		return
+	}
+	n.form(schema, schemaPtr)
+	n.encoding(media, ptr, schema, schemaPtr)
+}
+
+// form rewrites the schema of a form, and of its parts. ogen generates a form
+// of an object schema only, and fails on one without a type, on a nullable
+// one, and on one with additional properties.
+func (n *normalizer) form(o *specdoc.Object, ptr string) {
+	if n.done[ptr] {
+		return
+	}
+	n.done[ptr] = true
+
+	typ, _ := o.Get("type").(string)
+	switch {
+	case typ == "" && o.Has("properties") && !o.Has("allOf"):
+		o.Set("type", "object")
+		n.change(ptr, "added type object: ogen generates a form of an object only")
+	case typ != "" && typ != "object":
+		n.note(ptr, "the form is a %s, not an object of parts; ogen skips the form", typ)
+		return
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		// ogen generates a oneOf of one schema as that schema.
+		if list, _ := o.Get(key).([]any); len(list) > 1 || key == "anyOf" && len(list) == 1 {
+			n.note(ptr, "ogen does not generate a form of a %s, and skips the form; list the parts as properties", key)
+		}
+	}
+	if nullable, _ := o.Get("nullable").(bool); nullable {
+		o.Delete("nullable")
+		n.change(ptr, "removed nullable: a form is never null, and ogen skips a nullable one")
+	}
+	switch v := o.Get("additionalProperties").(type) {
+	case bool:
+		if v {
+			o.Delete("additionalProperties")
+			n.change(ptr, "removed additionalProperties true: ogen fails on free-form forms")
+		}
+	case *specdoc.Object:
+		if v.Len() == 0 {
+			o.Delete("additionalProperties")
+			n.change(ptr, "removed additionalProperties {}: ogen fails on free-form forms")
+		} else {
+			n.note(ptr, "ogen does not generate additionalProperties of a form, and skips the form; list the parts as properties")
+		}
+	}
+	if o.Has("patternProperties") {
+		n.note(ptr, "ogen does not generate patternProperties of a form, and skips the form; list the parts as properties")
+	}
+
+	if properties, ok := o.Get("properties").(*specdoc.Object); ok {
+		propertiesPtr := specdoc.Pointer(ptr, "properties")
+		for _, name := range properties.Keys() {
+			n.part(properties.Get(name), specdoc.Pointer(propertiesPtr, name))
+		}
+	}
+	allOf, _ := o.Get("allOf").([]any)
+	for i, member := range allOf {
+		if m, mptr := n.resolve(member, fmt.Sprintf("%s/allOf/%d", ptr, i)); m != nil {
+			n.form(m, mptr)
+		}
+	}
+}
+
+// part rewrites the schema of a part into a binary string if it describes a
+// file, and notes files nested in object parts, on which ogen fails.
+func (n *normalizer) part(v any, ptr string) {
+	o, ptr := n.resolve(v, ptr)
+	if o == nil || n.done[ptr] {
+		return
+	}
+	n.done[ptr] = true
+
+	typ, _ := o.Get("type").(string)
+	switch typ {
+	case "array":
+		n.part(o.Get("items"), specdoc.Pointer(ptr, "items"))
+		return
+	case "object":
+		if n.hasFile(o, 0) {
+			n.note(ptr, "ogen fails on a file inside an object part; make the file a part of the form")
+		}
+		return
+	case "file":
+		o.Set("type", "string")
+		o.Set("format", "binary")
+		n.change(ptr, "rewrote type file to a binary string: ogen generates an ht.MultipartFile for it")
+		return
+	case "", "string":
+	default:
+		return
+	}
+
+	if !o.Has("format") {
+		switch encoding, _ := o.Get("contentEncoding").(string); {
+		case strings.EqualFold(encoding, "base64"):
+			o.Set("format", "byte")
+			n.change(ptr, "added format byte for contentEncoding %s: ogen ignores the contentEncoding", encoding)
+		case o.Has("contentMediaType") && !isJSON(fmt.Sprint(o.Get("contentMediaType"))),
+			strings.EqualFold(encoding, "binary"):
+			o.Set("format", "binary")
+			n.change(ptr, "added format binary for a file part: ogen ignores contentMediaType and contentEncoding")
+		}
+	}
+	if typ == "" && o.Get("format") == "binary" && !o.Has("$ref") && !o.Has("allOf") && !o.Has("oneOf") && !o.Has("anyOf") {
+		o.Set("type", "string")
+		n.change(ptr, "added type string to a binary format: ogen generates a raw JSON value without it")
+	}
+}
+
+// hasFile reports whether a property of object o, or of the objects in it, is
+// a binary string.
+func (n *normalizer) hasFile(o *specdoc.Object, depth int) bool {
+	if o == nil || depth > 8 {
+		return false
+	}
+	if o.Get("format") == "binary" || o.Get("type") == "file" {
+		return true
+	}
+	if items, _ := n.resolve(o.Get("items"), ""); items != nil && n.hasFile(items, depth+1) {
+		return true
+	}
+	properties, _ := o.Get("properties").(*specdoc.Object)
+	for _, name := range properties.Keys() {
+		if p, _ := n.resolve(properties.Get(name), ""); n.hasFile(p, depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// encoding rewrites the content types of the encoding of a form to the ones
+// ogen accepts: none, application/json or application/x-www-form-urlencoded.
+// ogen fails on any other, files included, which carry their own content
+// type in the Header of the ht.MultipartFile.
+func (n *normalizer) encoding(media *specdoc.Object, ptr string, schema *specdoc.Object, schemaPtr string) {
+	encoding, ok := media.Get("encoding").(*specdoc.Object)
+	if !ok {
+		return
+	}
+	encodingPtr := specdoc.Pointer(ptr, "encoding")
+	for _, name := range encoding.Keys() {
+		e, ok := encoding.Get(name).(*specdoc.Object)
+		if !ok {
+			continue
+		}
+		contentType, _ := e.Get("contentType").(string)
+		if contentType == "" {
+			continue
+		}
+		ePtr := specdoc.Pointer(encodingPtr, name)
+		// A list of content types allows any of them.
+		first, _, _ := strings.Cut(contentType, ",")
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(first))
+		if err != nil {
+			n.note(ePtr, "content type %q does not parse; left as is", contentType)
+			continue
+		}
+
+		part, partPtr := n.property(schema, schemaPtr, name)
+		switch {
+		case contentType == "application/json", contentType == "application/x-www-form-urlencoded":
+		case isJSON(mt):
+			e.Set("contentType", "application/json")
+			n.change(ePtr, "rewrote contentType %s to application/json: ogen fails on other JSON content types", contentType)
+		case part == nil:
+			n.note(ePtr, "the form has no part %s; left as is", name)
+		case n.isFile(part):
+			e.Delete("contentType")
+			n.change(ePtr, "removed contentType %s of a file part: ogen fails on it, and sends the content type in the Header of the ht.MultipartFile", contentType)
+		case n.isScalar(part) && strings.HasPrefix(mt, "text/"):
+			e.Delete("contentType")
+			n.change(ePtr, "removed contentType %s: ogen sends the part as a plain value", contentType)
+		case n.isScalar(part) && (part.Get("type") == "string" || !part.Has("type")):
+			part.Set("type", "string")
+			part.Set("format", "binary")
+			e.Delete("contentType")
+			n.change(ePtr, "removed contentType %s: ogen fails on it", contentType)
+			n.change(partPtr, "rewrote the %s part to a binary string: ogen generates an ht.MultipartFile for it", contentType)
+		default:
+			n.note(ePtr, "ogen sends %s parts as JSON only, and skips the form for contentType %s", name, contentType)
+		}
+	}
+}
+
+// property returns the schema of the named property of form o, or of its
+// allOf members, following local $refs.
+func (n *normalizer) property(o *specdoc.Object, ptr, name string) (*specdoc.Object, string) {
+	return n.findProperty(o, ptr, name, 0)
+}
+
+func (n *normalizer) findProperty(o *specdoc.Object, ptr, name string, depth int) (*specdoc.Object, string) {
+	if o == nil || depth > 8 {
+		return nil, ""
+	}
+	if properties, ok := o.Get("properties").(*specdoc.Object); ok && properties.Has(name) {
+		return n.resolve(properties.Get(name), specdoc.Pointer(specdoc.Pointer(ptr, "properties"), name))
+	}
+	allOf, _ := o.Get("allOf").([]any)
+	for i, member := range allOf {
+		m, mptr := n.resolve(member, fmt.Sprintf("%s/allOf/%d", ptr, i))
+		if p, pptr := n.findProperty(m, mptr, name, depth+1); p != nil {
+			return p, pptr
+		}
+	}
+	return nil, ""
+}
+
+// isScalar reports whether schema o is not an object or array, which ogen
+// sends as JSON.
+func (n *normalizer) isScalar(o *specdoc.Object) bool {
+	switch o.Get("type") {
+	case "object", "array":
+		return false
+	}
+	return !o.Has("properties") && !o.Has("items") && !o.Has("allOf") && !o.Has("oneOf") && !o.Has("anyOf")
+}
+
+// isFile reports whether schema o is a binary string, or an array of them.
+func (n *normalizer) isFile(o *specdoc.Object) bool {
+	if o.Get("type") == "array" {
+		if o, _ = n.resolve(o.Get("items"), ""); o == nil {
+			return false
+		}
+	}
+	return o.Get("format") == "binary" && (o.Get("type") == "string" || !o.Has("type"))
+}
+
+// isJSON reports whether ogen encodes mediaType as JSON.
+func isJSON(mediaType string) bool {
+	mt, _, _ := mime.ParseMediaType(mediaType)
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// resolve follows the local $refs of v, returning the schema and its
+// pointer, or nil for a value that is not an object or an unresolved $ref.
+func (n *normalizer) resolve(v any, ptr string) (*specdoc.Object, string) {
+	for range 32 {
+		o, ok := v.(*specdoc.Object)
+		if !ok {
+			return nil, ""
+		}
+		ref, ok := o.Get("$ref").(string)
+		if !ok {
+			return o, ptr
+		}
+		if ptr, ok = strings.CutPrefix(ref, "#"); !ok {
+			return nil, ""
+		}
+		if v, ok = specdoc.Lookup(n.spec, ptr); !ok {
+			return nil, ""
+		}
+	}
+	return nil, ""
+}
+
+// isRequestBody reports whether the object at ptr is a request body.
+func isRequestBody(ptr string) bool {
+	parts := strings.Split(ptr, "/")
+	if len(parts) < 2 {
+		return false
+	}
+	return parts[len(parts)-1] == "requestBody" || parts[len(parts)-2] == "requestBodies"
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1"},
+  "paths": {
+    "/photos": {
+      "post": {
+        "operationId": "uploadPhoto",
+        "requestBody": {"content": {"multipart/form-data": {
+          "schema": {"properties": {"photo": {"type": "file"}, "thumbs": {"type": "array", "items": {"type": "file"}}}},
+          "encoding": {"photo": {"contentType": "image/png, image/jpeg"}}
+        }}},
+        "responses": {"204": {"description": "OK"}}
+      }
+    },
+    "/pets": {
+      "post": {
+        "operationId": "createPet",
+        "requestBody": {"$ref": "#/components/requestBodies/NewPet"},
+        "responses": {"204": {"description": "OK"}}
+      }
+    },
+    "/shapes": {
+      "post": {
+        "operationId": "createShape",
+        "requestBody": {"content": {"multipart/form-data": {"schema": {"oneOf": [{"$ref": "#/components/schemas/NewPet"}, {"type": "object"}]}}}},
+        "responses": {"204": {"description": "OK"}}
+      }
+    }
+  },
+  "components": {
+    "requestBodies": {
+      "NewPet": {"content": {
+        "application/json": {"schema": {"$ref": "#/components/schemas/NewPet"}},
+        "multipart/form-data": {
+          "schema": {"$ref": "#/components/schemas/NewPet"},
+          "encoding": {
+            "meta": {"contentType": "application/json; charset=utf-8"},
+            "name": {"contentType": "text/plain"},
+            "blob": {"contentType": "application/octet-stream"},
+            "owner": {"contentType": "application/xml"}
+          }
+        }
+      }}
+    },
+    "schemas": {
+      "NewPet": {
+        "type": "object",
+        "nullable": true,
+        "additionalProperties": true,
+        "properties": {
+          "name": {"type": "string"},
+          "meta": {"type": "object"},
+          "blob": {"type": "string"},
+          "doc": {"type": "string", "contentMediaType": "application/pdf"},
+          "raw": {"format": "binary"},
+          "owner": {"type": "object", "properties": {"avatar": {"type": "string", "format": "binary"}}}
+        }
+      }
+    }
+  }
+}`
+
+func TestNormalize(t *testing.T) {
+	spec := parse(t, testSpec)
+	report, err := Normalize(spec)
+	if err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if report.Bodies != 3 {
+		t.Errorf("Bodies = %d, want 3", report.Bodies)
+	}
+
+	const (
+		photo  = "/paths/~1photos/post/requestBody/content/multipart~1form-data"
+		newPet = "/components/requestBodies/NewPet/content/multipart~1form-data"
+		schema = "/components/schemas/NewPet"
+	)
+	var changed []string
+	for _, c := range report.Changes {
+		changed = append(changed, c.Pointer)
+	}
+	want := []string{
+		photo + "/schema",
+		photo + "/schema/properties/photo",
+		photo + "/schema/properties/thumbs/items",
+		photo + "/encoding/photo",
+		schema,
+		schema,
+		schema + "/properties/doc",
+		schema + "/properties/raw",
+		newPet + "/encoding/meta",
+		newPet + "/encoding/name",
+		newPet + "/encoding/blob",
+		schema + "/properties/blob",
+	}
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed %q,\nwant %q", changed, want)
+	}
+
+	var noted []string
+	for _, n := range report.Notes {
+		noted = append(noted, n.Pointer)
+	}
+	wantNotes := []string{
+		"/paths/~1shapes/post/requestBody/content/multipart~1form-data/schema",
+		schema + "/properties/owner",
+		newPet + "/encoding/owner",
+	}
+	if !reflect.DeepEqual(noted, wantNotes) {
+		t.Errorf("noted %q,\nwant %q", noted, wantNotes)
+	}
+
+	tests := []struct {
+		ptr  string
+		want string
+	}{
+		{photo + "/schema/properties/photo", `{"type":"string","format":"binary"}`},
+		{photo + "/schema/properties/thumbs/items", `{"type":"string","format":"binary"}`},
+		{photo + "/encoding/photo", `{}`},
+		{newPet + "/encoding/meta", `{"contentType":"application/json"}`},
+		{newPet + "/encoding/name", `{}`},
+		{newPet + "/encoding/owner", `{"contentType":"application/xml"}`},
+		{schema + "/properties/blob", `{"type":"string","format":"binary"}`},
+		{schema + "/properties/doc", `{"type":"string","contentMediaType":"application/pdf","format":"binary"}`},
+		{schema + "/properties/raw", `{"format":"binary","type":"string"}`},
+	}
+	for _, tt := range tests {
+		v, ok := specdoc.Lookup(spec, tt.ptr)
+		if !ok {
+			t.Errorf("%s: not found", tt.ptr)
+			continue
+		}
+		if got := compact(t, v); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.ptr, got, tt.want)
+		}
+	}
+	if v, _ := specdoc.Lookup(spec, schema); v.(*specdoc.Object).Has("nullable") || v.(*specdoc.Object).Has("additionalProperties") {
+		t.Errorf("%s keeps nullable or additionalProperties: %s", schema, compact(t, v))
+	}
+}
+
+func TestNormalize_Unchanged(t *testing.T) {
+	const doc = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1"},
+  "paths": {"/pets": {"post": {
+    "requestBody": {"content": {
+      "multipart/form-data": {
+        "schema": {"type": "object", "properties": {"photo": {"type": "string", "format": "binary"}, "tags": {"type": "array", "items": {"type": "string"}}}},
+        "encoding": {"photo": {"headers": {"X-Rate": {"schema": {"type": "integer"}}}}, "tags": {"contentType": "application/json"}}
+      },
+      "application/json": {"schema": {"type": "file"}}
+    }},
+    "responses": {"200": {"description": "OK", "content": {"multipart/form-data": {"schema": {"type": "file"}}}}}
+  }}}
+}`
+	spec := parse(t, doc)
+	report, err := Normalize(spec)
+	if err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if report.Bodies != 1 || len(report.Changes) != 0 || len(report.Notes) != 0 {
+		t.Errorf("report = %+v, want one body and no changes", report)
+	}
+	if got := compact(t, spec); got != compact(t, parse(t, doc)) {
+		t.Errorf("spec changed:\n%s", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{input}); err == nil {
+		t.Error("run without -o: want error")
+	}
+
+	if err := run([]string{"-o", output, input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(got), `"type": "file"`) {
+		t.Errorf("output has file types left:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
+
+func compact(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
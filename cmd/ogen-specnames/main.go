@@ -0,0 +1,422 @@
+// Command ogen-specnames renames the components of an OpenAPI spec whose
+// names ogen rejects or turns into awkward Go identifiers, and updates the
+// references to them.
+//
+// ogen fails on component names with characters other than letters, digits,
+// dots, hyphens and underscores, and prefixes the Go types of names that
+// start with a digit with an R. Namespaced names, common in generated specs,
+// give long type names. This tool renames such components before generation,
+// from rules in a config file and a default normalization:
+//
+//	user.profile-v2[beta]          UserProfileV2Beta
+//	2fa                            N2fa
+//	io.k8s.api.core.v1.Pod         Pod            with {"components": "io.k8s.api.*.*.*", "name": "{3}"}
+//
+// Usage:
+//
+//	ogen-specnames -o openapi.ogen.json [-config names.json] [-digit-prefix N] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// The config file holds rules, of which the first whose pattern matches a
+// component name gives its new name:
+//
+//	{
+//	  "rules": [
+//	    {"kind": "schemas", "components": "io.k8s.api.*.*.*", "name": "{3}"},
+//	    {"components": "user.profile-v2[beta]", "name": "UserProfileBeta"}
+//	  ]
+//	}
+//
+// Names no rule matches are normalized if ogen rejects them or they start
+// with a digit. A new name that takes the Go name of another component of
+// its kind gets a numeric suffix. $refs, discriminator mappings and
+// security requirements are updated. The rest of the spec is written back
+// unchanged, with its keys in their original order.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// Config is the rule list read from the -config file.
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule renames the components whose name matches a pattern.
+type Rule struct {
+	// Kind restricts the rule to the components of a kind, such as
+	// schemas or parameters.
+	Kind string `json:"kind,omitempty"`
+	// Components selects the components whose name matches a pattern
+	// with * wildcards.
+	Components string `json:"components"`
+	// Name is the new name. It may hold {name}, the old name, and the
+	// placeholders {1}, {2}... of the text the *s matched.
+	Name string `json:"name"`
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specnames: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specnames", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	configFile := fs.String("config", "", "JSON file with the rename rules")
+	digitPrefix := fs.String("digit-prefix", "N", "prefix of normalized names that start with a digit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specnames -o <output.json> [-config names.json] [-digit-prefix N] <openapi.json>")
+	}
+	if !isLetter(*digitPrefix) {
+		return fmt.Errorf("-digit-prefix %q does not start with a letter", *digitPrefix)
+	}
+
+	var cfg Config
+	if *configFile != "" {
+		var err error
+		if cfg, err = loadConfig(*configFile); err != nil {
+			return err
+		}
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Rename(spec, cfg, *digitPrefix)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, i := range report.Unmatched {
+		fmt.Fprintf(os.Stderr, "ogen-specnames: rule %d matches no component\n", i+1)
+	}
+	for _, r := range report.Renamed {
+		fmt.Fprintf(os.Stderr, "ogen-specnames: #%s: renamed to %s\n", r.Pointer, r.New)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Renamed %d components and updated %d references in %s\n", len(report.Renamed), report.References, *outputFile)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Report lists what Rename changed.
+type Report struct {
+	// Renamed lists the renamed components, in the order of the spec.
+	Renamed []Renamed
+	// References counts the $refs, discriminator mappings and security
+	// requirements updated.
+	References int
+	// Unmatched lists the indexes of the rules that matched no component.
+	Unmatched []int
+}
+
+// Renamed is a component renamed at the JSON pointer of its old name.
+type Renamed struct {
+	Pointer string
+	Kind    string
+	Old     string
+	New     string
+}
+
+// Kinds lists the component kinds whose names ogen checks.
+var Kinds = []string{
+	"schemas", "responses", "parameters", "examples", "requestBodies",
+	"headers", "securitySchemes", "links", "callbacks", "pathItems",
+}
+
+// keyRE is the form of component names the OpenAPI specification requires,
+// and ogen enforces.
+var keyRE = regexp.MustCompile(`^[a-zA-Z0-9.\-_]+$`)
+
+// Rename renames the components of spec that a rule of cfg matches, and
+// normalizes the names of the others that ogen rejects or that start with a
+// digit, which get digitPrefix. It updates the local $refs to the renamed
+// components, the discriminator mappings naming their schemas, and the
+// security requirements naming their security schemes.
+func Rename(spec *specdoc.Object, cfg Config, digitPrefix string) (*Report, error) {
+	rules := make([]*regexp.Regexp, len(cfg.Rules))
+	for i, r := range cfg.Rules {
+		if r.Kind != "" && !isKind(r.Kind) {
+			return nil, fmt.Errorf("rule %d: unknown kind %q, want one of %s", i+1, r.Kind, strings.Join(Kinds, ", "))
+		}
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: no name", i+1)
+		}
+		re, err := specdoc.Glob(r.Components)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		for _, m := range placeholderRE.FindAllStringSubmatch(r.Name, -1) {
+			if n, err := strconv.Atoi(m[1]); m[1] != "name" && (err != nil || n < 1 || n > re.NumSubexp()) {
+				return nil, fmt.Errorf("rule %d: name: no value for placeholder %s", i+1, m[0])
+			}
+		}
+		rules[i] = re
+	}
+
+	report := &Report{}
+	matched := make([]bool, len(cfg.Rules))
+	// moved maps the JSON pointers of renamed components to their new ones.
+	moved := make(map[string]string)
+	components, _ := spec.Get("components").(*specdoc.Object)
+	for _, kind := range Kinds {
+		names, _ := components.Get(kind).(*specdoc.Object)
+		newNames := make(map[string]string)
+		for _, name := range names.Keys() {
+			newName := name
+			for i, re := range rules {
+				captures := re.FindStringSubmatch(name)
+				if captures == nil || (cfg.Rules[i].Kind != "" && cfg.Rules[i].Kind != kind) {
+					continue
+				}
+				matched[i] = true
+				newName = expand(cfg.Rules[i].Name, name, captures)
+				break
+			}
+			if !keyRE.MatchString(newName) || newName[0] >= '0' && newName[0] <= '9' {
+				newName = normalizeName(newName, digitPrefix)
+			}
+			if newName != name {
+				newNames[name] = newName
+			}
+		}
+
+		// Components keeping their name claim their Go names first, and
+		// renamed ones take the first free suffix in the order of the spec.
+		taken := make(map[string]bool)
+		for _, name := range names.Keys() {
+			if _, ok := newNames[name]; !ok {
+				taken[goName(name)] = true
+			}
+		}
+		if len(newNames) == 0 {
+			continue
+		}
+		// The components are set in a new map, as a new name may be the
+		// old name of another component.
+		renamed := specdoc.NewObject()
+		for _, name := range names.Keys() {
+			newName, ok := newNames[name]
+			if !ok {
+				renamed.Set(name, names.Get(name))
+				continue
+			}
+			unique := newName
+			for i := 2; taken[goName(unique)]; i++ {
+				unique = newName + strconv.Itoa(i)
+			}
+			taken[goName(unique)] = true
+
+			ptr := specdoc.Pointer("/components/"+kind, name)
+			renamed.Set(unique, names.Get(name))
+			moved[ptr] = specdoc.Pointer("/components/"+kind, unique)
+			report.Renamed = append(report.Renamed, Renamed{Pointer: ptr, Kind: kind, Old: name, New: unique})
+		}
+		components.Set(kind, renamed)
+	}
+	for i := range cfg.Rules {
+		if !matched[i] {
+			report.Unmatched = append(report.Unmatched, i)
+		}
+	}
+	if len(moved) == 0 {
+		return report, nil
+	}
+
+	report.References += updateRefs(spec, moved)
+	report.References += updateSecurity(spec, moved)
+	return report, nil
+}
+
+// updateRefs points the local $refs and discriminator mappings of v to the
+// components that moved, and returns how many it updated.
+func updateRefs(v any, moved map[string]string) int {
+	n := 0
+	switch v := v.(type) {
+	case *specdoc.Object:
+		for _, key := range v.Keys() {
+			if ref, ok := v.Get(key).(string); ok && key == "$ref" {
+				if newRef, ok := movedRef(ref, moved); ok {
+					v.Set(key, newRef)
+					n++
+				}
+			}
+			if discriminator, ok := v.Get(key).(*specdoc.Object); ok && key == "discriminator" {
+				mapping, _ := discriminator.Get("mapping").(*specdoc.Object)
+				for _, value := range mapping.Keys() {
+					ref, _ := mapping.Get(value).(string)
+					if newRef, ok := movedRef(ref, moved); ok {
+						mapping.Set(value, newRef)
+						n++
+					} else if newPtr, ok := moved[specdoc.Pointer("/components/schemas", ref)]; ok && !strings.HasPrefix(ref, "#") {
+						// A mapping may name a schema without a $ref.
+						mapping.Set(value, strings.TrimPrefix(newPtr, "/components/schemas/"))
+						n++
+					}
+				}
+			}
+			n += updateRefs(v.Get(key), moved)
+		}
+	case []any:
+		for _, elem := range v {
+			n += updateRefs(elem, moved)
+		}
+	}
+	return n
+}
+
+// movedRef returns the $ref of a component that moved, or of a value in
+// it, such as #/components/schemas/Pet/properties/owner.
+func movedRef(ref string, moved map[string]string) (string, bool) {
+	ptr, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return "", false
+	}
+	parts := strings.SplitN(ptr, "/", 5)
+	if len(parts) < 4 || parts[1] != "components" {
+		return "", false
+	}
+	// Fragments may percent-encode the characters of a name.
+	if unescaped, err := url.PathUnescape(parts[3]); err == nil {
+		parts[3] = unescaped
+	}
+	newPtr, ok := moved[strings.Join(parts[:4], "/")]
+	if !ok {
+		return "", false
+	}
+	if len(parts) == 5 {
+		newPtr += "/" + parts[4]
+	}
+	return "#" + newPtr, true
+}
+
+// updateSecurity renames the security schemes that moved in the security
+// requirements of spec, at the root and of its operations, and returns how
+// many it renamed.
+func updateSecurity(spec *specdoc.Object, moved map[string]string) int {
+	n := 0
+	update := func(v any) {
+		list, _ := v.([]any)
+		for _, req := range list {
+			o, _ := req.(*specdoc.Object)
+			for _, name := range o.Keys() {
+				if newPtr, ok := moved[specdoc.Pointer("/components/securitySchemes", name)]; ok {
+					o.Replace(name, strings.TrimPrefix(newPtr, "/components/securitySchemes/"), o.Get(name))
+					n++
+				}
+			}
+		}
+	}
+	update(spec.Get("security"))
+	for _, key := range specdoc.ItemMaps {
+		items, _ := spec.Get(key).(*specdoc.Object)
+		for _, name := range items.Keys() {
+			item, _ := items.Get(name).(*specdoc.Object)
+			for _, method := range item.Keys() {
+				if op, ok := item.Get(method).(*specdoc.Object); ok && specdoc.IsMethod(method) {
+					update(op.Get("security"))
+				}
+			}
+		}
+	}
+	return n
+}
+
+// normalizeName returns a component name ogen accepts: the words of name,
+// split at characters other than ASCII letters and digits, with their first
+// letters in upper case, and prefix if it would start with a digit. So
+// user.profile-v2[beta] gives UserProfileV2Beta.
+func normalizeName(name, prefix string) string {
+	var b strings.Builder
+	for _, w := range strings.FieldsFunc(name, func(r rune) bool {
+		return !isASCIILetter(r) && (r < '0' || r > '9')
+	}) {
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	s := b.String()
+	if !isLetter(s) {
+		s = prefix + s
+	}
+	return s
+}
+
+// goName returns the form of a component name ogen's Go type name depends
+// on: user.profile and UserProfile both become the type UserProfile.
+func goName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// isLetter reports whether s starts with an ASCII letter.
+func isLetter(s string) bool {
+	return s != "" && isASCIILetter(rune(s[0]))
+}
+
+func isASCIILetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+func isKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+var placeholderRE = regexp.MustCompile(`\{([A-Za-z0-9]+)\}`)
+
+// expand returns the name of a rule with its placeholders replaced: {name}
+// by the old name, and {1}, {2}... by the text the *s of the pattern
+// matched.
+func expand(template, name string, captures []string) string {
+	return placeholderRE.ReplaceAllStringFunc(template, func(m string) string {
+		key := m[1 : len(m)-1]
+		if key == "name" {
+			return name
+		}
+		i, _ := strconv.Atoi(key)
+		return captures[i]
+	})
+}
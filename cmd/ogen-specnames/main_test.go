@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1"},
+  "security": [{"api key": []}],
+  "paths": {
+    "/pets": {
+      "get": {
+        "parameters": [{"$ref": "#/components/parameters/page-size"}],
+        "responses": {
+          "200": {"description": "OK", "content": {"application/json": {
+            "schema": {"$ref": "#/components/schemas/user.profile-v2%5Bbeta%5D"},
+            "examples": {"rex": {"$ref": "#/components/examples/my example"}}
+          }}}
+        },
+        "security": [{"api key": [], "oauth": []}]
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "user.profile-v2[beta]": {"type": "object", "properties": {"code": {"$ref": "#/components/schemas/2fa/properties/code"}}},
+      "2fa": {"type": "object", "properties": {"code": {"type": "string"}}},
+      "Pet": {
+        "oneOf": [{"$ref": "#/components/schemas/io.k8s.api.core.v1.Pod"}, {"$ref": "#/components/schemas/2fa"}],
+        "discriminator": {"propertyName": "kind", "mapping": {"pod": "io.k8s.api.core.v1.Pod", "fa": "#/components/schemas/2fa", "pet": "Pet"}}
+      },
+      "io.k8s.api.core.v1.Pod": {"type": "object"},
+      "io.k8s.api.core.v2.Pod": {"type": "object"},
+      "Pet Owner": {"type": "object"},
+      "PetOwner": {"type": "object"}
+    },
+    "parameters": {"page-size": {"name": "size", "in": "query", "schema": {"type": "integer"}}},
+    "examples": {"my example": {"value": {}}},
+    "securitySchemes": {"api key": {"type": "apiKey", "in": "header", "name": "X-Key"}, "oauth": {"type": "http", "scheme": "bearer"}}
+  }
+}`
+
+func TestRename(t *testing.T) {
+	spec, err := specdoc.Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Rules: []Rule{
+		{Kind: "schemas", Components: "io.k8s.api.*.*.*", Name: "{3}"},
+		{Components: "legacy*", Name: "{1}"},
+	}}
+	report, err := Rename(spec, cfg, "N")
+	if err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	var renamed []string
+	for _, r := range report.Renamed {
+		renamed = append(renamed, r.Old+" "+r.New)
+	}
+	wantRenamed := []string{
+		"user.profile-v2[beta] UserProfileV2Beta",
+		"2fa N2fa",
+		"io.k8s.api.core.v1.Pod Pod",
+		"io.k8s.api.core.v2.Pod Pod2",
+		"Pet Owner PetOwner2",
+		"my example MyExample",
+		"api key ApiKey",
+	}
+	if !reflect.DeepEqual(renamed, wantRenamed) {
+		t.Errorf("renamed = %q, want %q", renamed, wantRenamed)
+	}
+	if report.References != 9 {
+		t.Errorf("References = %d, want 9", report.References)
+	}
+	if !reflect.DeepEqual(report.Unmatched, []int{1}) {
+		t.Errorf("Unmatched = %v, want [1]", report.Unmatched)
+	}
+
+	got, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"security":[{"ApiKey":[]}]`,
+		`"parameters":[{"$ref":"#/components/parameters/page-size"}]`,
+		`"schema":{"$ref":"#/components/schemas/UserProfileV2Beta"}`,
+		`"rex":{"$ref":"#/components/examples/MyExample"}`,
+		`"security":[{"ApiKey":[],"oauth":[]}]`,
+		// Renamed components keep their place.
+		`"schemas":{"UserProfileV2Beta":{"type":"object","properties":{"code":{"$ref":"#/components/schemas/N2fa/properties/code"}}},"N2fa":`,
+		`"oneOf":[{"$ref":"#/components/schemas/Pod"},{"$ref":"#/components/schemas/N2fa"}]`,
+		`"mapping":{"pod":"Pod","fa":"#/components/schemas/N2fa","pet":"Pet"}`,
+		`"Pod":{"type":"object"},"Pod2":{"type":"object"},"PetOwner2":{"type":"object"},"PetOwner":{"type":"object"}`,
+		`"securitySchemes":{"ApiKey":{`,
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("output missing %s:\n%s", want, got)
+		}
+	}
+}
+
+func TestRename_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		want string
+	}{
+		{"kind", Rule{Kind: "schema", Components: "*", Name: "{1}"}, `rule 1: unknown kind "schema", want one of schemas, responses, parameters, examples, requestBodies, headers, securitySchemes, links, callbacks, pathItems`},
+		{"no name", Rule{Components: "*"}, "rule 1: no name"},
+		{"placeholder", Rule{Components: "v1.*", Name: "{2}"}, "rule 1: name: no value for placeholder {2}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := specdoc.Parse([]byte(testSpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Rename(spec, Config{Rules: []Rule{tt.rule}}, "N")
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Rename error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"user.profile-v2[beta]", "UserProfileV2Beta"},
+		{"Pet Owner", "PetOwner"},
+		{"2fa", "N2fa"},
+		{"über_Straße", "BerStraE"},
+		{"$ref", "Ref"},
+	}
+	for _, tt := range tests {
+		if got := normalizeName(tt.in, "N"); got != tt.want {
+			t.Errorf("normalizeName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, "-digit-prefix", "1", input}); err == nil || err.Error() != `-digit-prefix "1" does not start with a letter` {
+		t.Errorf("run with -digit-prefix 1: error = %v", err)
+	}
+
+	if err := run([]string{"-o", output, input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(got), `user.profile`) {
+		t.Errorf("output has the old name:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
@@ -0,0 +1,306 @@
+// Command ogen-specnormalize rewrites schema constructs of an OpenAPI spec
+// that ogen generates oddly into equivalent ones it handles.
+//
+// Third-party specs use constructs ogen accepts but generates surprising code
+// for: a const becomes a field the encoder overwrites and the decoder never
+// checks, an anyOf of one schema becomes a sum type of one variant, and the
+// keywords next to a $ref are dropped. This tool applies a pass for each:
+//
+//	{"type": "string", "const": "dog"}
+//	{"type": "string", "enum": ["dog"]}
+//
+//	{"anyOf": [{"$ref": "#/components/schemas/Pet"}]}
+//	{"allOf": [{"$ref": "#/components/schemas/Pet"}]}
+//
+//	{"$ref": "#/components/schemas/Name", "maxLength": 64, "description": "The name."}
+//	{"allOf": [{"$ref": "#/components/schemas/Name"}, {"maxLength": 64}], "description": "The name."}
+//
+// Usage:
+//
+//	ogen-specnormalize -o openapi.ogen.json [-skip const,single-union,ref-siblings] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specnormalize: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specnormalize", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the normalized spec to")
+	var skip specdoc.ListFlag
+	fs.Var(&skip, "skip", "passes to skip: const, single-union, ref-siblings")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specnormalize -o <output.json> [-skip <passes>] <openapi.json>")
+	}
+	for _, pass := range skip {
+		if !slices.Contains(Passes, pass) {
+			return fmt.Errorf("-skip: unknown pass %q, want one of %s", pass, strings.Join(Passes, ", "))
+		}
+	}
+	var passes []string
+	for _, pass := range Passes {
+		if !slices.Contains(skip, pass) {
+			passes = append(passes, pass)
+		}
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Normalize(spec, passes)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, n := range report.Notes {
+		fmt.Fprintf(os.Stderr, "ogen-specnormalize: #%s: %s\n", n.Pointer, n.Message)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	var counts []string
+	for _, pass := range Passes {
+		if n := report.Changed[pass]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, pass))
+		}
+	}
+	done := "changed nothing"
+	if len(counts) > 0 {
+		done = "rewrote " + strings.Join(counts, ", ")
+	}
+	fmt.Printf("Normalized %s to %s: %s\n", filename, *outputFile, done)
+	return nil
+}
+
+// The passes of Normalize.
+const (
+	// Const replaces a const by an enum of its value, with the type of the
+	// value if the schema has none.
+	Const = "const"
+	// SingleUnion replaces a oneOf or anyOf of one schema by an allOf.
+	SingleUnion = "single-union"
+	// RefSiblings moves the keywords next to a $ref into a schema of an
+	// allOf with the $ref, leaving the documentation next to it.
+	RefSiblings = "ref-siblings"
+)
+
+// Passes lists the passes of Normalize, in the order it applies them to
+// each schema.
+var Passes = []string{Const, SingleUnion, RefSiblings}
+
+// refKeys are the keywords that stay next to a $ref: ogen reads them there,
+// or they document the field rather than its type. Keys starting with $ or
+// x- stay as well.
+var refKeys = map[string]bool{
+	"title":        true,
+	"description":  true,
+	"default":      true,
+	"example":      true,
+	"examples":     true,
+	"deprecated":   true,
+	"nullable":     true,
+	"readOnly":     true,
+	"writeOnly":    true,
+	"externalDocs": true,
+	"xml":          true,
+}
+
+// Report counts what Normalize changed.
+type Report struct {
+	// Changed counts the schemas rewritten by pass.
+	Changed map[string]int
+	// Notes lists the schemas a pass left as they were, and why.
+	Notes []Note
+}
+
+// Note is a message about the schema at a JSON pointer.
+type Note struct {
+	Pointer string
+	Message string
+}
+
+// Normalize applies the named passes to every schema of spec, parents
+// first.
+func Normalize(spec *specdoc.Object, passes []string) (*Report, error) {
+	report := &Report{Changed: map[string]int{}}
+	err := specdoc.Walk(spec, func(o *specdoc.Object, ptr string) error {
+		note := func(format string, args ...any) {
+			report.Notes = append(report.Notes, Note{Pointer: ptr, Message: fmt.Sprintf(format, args...)})
+		}
+		for _, pass := range passes {
+			var changed bool
+			switch pass {
+			case Const:
+				changed = normalizeConst(o, note)
+			case SingleUnion:
+				changed = normalizeSingleUnion(o)
+			case RefSiblings:
+				changed = normalizeRefSiblings(o, note)
+			}
+			if changed {
+				report.Changed[pass]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// normalizeConst replaces the const of o by an enum of its value. ogen
+// generates a field for a const that the encoder ignores, writing the const
+// instead, and that the decoder does not check; for an enum of one value it
+// generates a type that both check. Without a type, ogen generates a raw
+// JSON field for an enum, so the type of the value is added.
+func normalizeConst(o *specdoc.Object, note func(string, ...any)) bool {
+	if !o.Has("const") {
+		return false
+	}
+	value := o.Get("const")
+	typ := jsonType(value)
+	if typ == "" {
+		note("const %s is not a string, number or boolean; left as is", specdoc.Compact(value))
+		return false
+	}
+	if enum, ok := o.Get("enum").([]any); ok && !slices.ContainsFunc(enum, func(v any) bool { return reflect.DeepEqual(v, value) }) {
+		note("const %s is not one of the enum values; left as is", specdoc.Compact(value))
+		return false
+	}
+
+	o.Delete("enum")
+	o.Replace("const", "enum", []any{value})
+	if !o.Has("type") && !o.Has("$ref") && !o.Has("allOf") && !o.Has("oneOf") && !o.Has("anyOf") {
+		o.Set("type", typ)
+	}
+	return true
+}
+
+// jsonType returns the schema type of a const value, or "" for null, arrays
+// and objects.
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	}
+	return ""
+}
+
+// normalizeSingleUnion replaces a oneOf or anyOf of one schema by an allOf,
+// which ogen generates as that schema. ogen generates a sum type of one
+// variant for an anyOf. Unions with a discriminator, or next to another
+// union, are left as they are.
+func normalizeSingleUnion(o *specdoc.Object) bool {
+	if o.Has("discriminator") || o.Has("oneOf") && o.Has("anyOf") {
+		return false
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		list, ok := o.Get(key).([]any)
+		if !ok || len(list) != 1 {
+			continue
+		}
+		if allOf, ok := o.Get("allOf").([]any); ok {
+			o.Set("allOf", append(allOf, list...))
+			o.Delete(key)
+		} else {
+			o.Replace(key, "allOf", list)
+		}
+		return true
+	}
+	return false
+}
+
+// normalizeRefSiblings moves the keywords next to the $ref of o into a
+// schema of an allOf with the $ref. ogen resolves a $ref and ignores the
+// keywords next to it, and merges the schemas of an allOf, ignoring the
+// keywords next to it but for the nullable, default and documentation ones
+// it reads for the field. A $ref with a default is left as it is: ogen
+// fails on a default next to an allOf, and ignores one inside it.
+func normalizeRefSiblings(o *specdoc.Object, note func(string, ...any)) bool {
+	ref, ok := o.Get("$ref").(string)
+	if !ok || !isSchemaRef(ref) {
+		return false
+	}
+	var moved []string
+	for _, key := range o.Keys() {
+		if key != "$ref" && key != "allOf" && !refKeys[key] && !strings.HasPrefix(key, "$") && !strings.HasPrefix(key, "x-") {
+			moved = append(moved, key)
+		}
+	}
+	// A type alone restates the type of the $ref.
+	if len(moved) == 0 || len(moved) == 1 && moved[0] == "type" {
+		return false
+	}
+	if o.Has("default") {
+		note("ogen ignores %s next to the $ref, and fails on a default next to an allOf; move them into the referenced schema", strings.Join(moved, ", "))
+		return false
+	}
+
+	member := specdoc.NewObject()
+	member.Set("$ref", ref)
+	siblings := specdoc.NewObject()
+	for _, key := range moved {
+		siblings.Set(key, o.Get(key))
+		o.Delete(key)
+	}
+	allOf, _ := o.Get("allOf").([]any)
+	allOf = append(append([]any{member}, allOf...), siblings)
+	if o.Has("allOf") {
+		o.Set("allOf", allOf)
+		o.Delete("$ref")
+	} else {
+		o.Replace("$ref", "allOf", allOf)
+	}
+	return true
+}
+
+// isSchemaRef reports whether a $ref points to a schema, rather than to a
+// parameter, response or other component, whose keywords are not moved.
+func isSchemaRef(ref string) bool {
+	_, fragment, _ := strings.Cut(ref, "#")
+	if fragment == "" {
+		// A whole file is a schema.
+		return true
+	}
+	for _, dir := range []string{"/schemas/", "/$defs/", "/definitions/"} {
+		if strings.Contains(fragment, dir) {
+			return true
+		}
+	}
+	return false
+}
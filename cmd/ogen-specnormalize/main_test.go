@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1"},
+  "paths": {
+    "/pets": {
+      "get": {
+        "parameters": [{"$ref": "#/components/parameters/Limit", "description": "Page size."}],
+        "responses": {"200": {"description": "OK"}}
+      }
+    }
+  },
+  "components": {
+    "parameters": {"Limit": {"name": "limit", "in": "query", "schema": {"type": "integer"}}},
+    "schemas": {
+      "Name": {"type": "string"},
+      "Pet": {
+        "type": "object",
+        "properties": {
+          "kind": {"type": "string", "const": "dog"},
+          "legs": {"const": 4},
+          "tags": {"const": ["a"]},
+          "size": {"type": "string", "enum": ["s", "m"], "const": "l"},
+          "owner": {"anyOf": [{"$ref": "#/components/schemas/Owner"}]},
+          "either": {"oneOf": [{"type": "string"}], "allOf": [{"minLength": 1}]},
+          "mapped": {"oneOf": [{"$ref": "#/components/schemas/Owner"}], "discriminator": {"propertyName": "kind"}},
+          "name": {"$ref": "#/components/schemas/Name", "maxLength": 64, "description": "The name.", "x-order": 1},
+          "nick": {"$ref": "#/components/schemas/Name", "default": "rex", "maxLength": 8},
+          "alias": {"$ref": "#/components/schemas/Name", "type": "string"}
+        }
+      },
+      "Owner": {"type": "object", "properties": {"name": {"type": "string"}}},
+      "Keeper": {"$ref": "#/components/schemas/Owner", "required": ["name"], "nullable": true}
+    }
+  }
+}`
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name        string
+		passes      []string
+		want        []string
+		wantChanged map[string]int
+		wantNotes   []string
+	}{
+		{
+			name:   "all",
+			passes: Passes,
+			want: []string{
+				`"kind":{"type":"string","enum":["dog"]}`,
+				`"legs":{"enum":[4],"type":"integer"}`,
+				`"tags":{"const":["a"]}`,
+				`"size":{"type":"string","enum":["s","m"],"const":"l"}`,
+				`"owner":{"allOf":[{"$ref":"#/components/schemas/Owner"}]}`,
+				`"either":{"allOf":[{"minLength":1},{"type":"string"}]}`,
+				`"mapped":{"oneOf":[{"$ref":"#/components/schemas/Owner"}],"discriminator":{"propertyName":"kind"}}`,
+				`"name":{"allOf":[{"$ref":"#/components/schemas/Name"},{"maxLength":64}],"description":"The name.","x-order":1}`,
+				`"nick":{"$ref":"#/components/schemas/Name","default":"rex","maxLength":8}`,
+				`"alias":{"$ref":"#/components/schemas/Name","type":"string"}`,
+				`"Keeper":{"allOf":[{"$ref":"#/components/schemas/Owner"},{"required":["name"]}],"nullable":true}`,
+				// Parameters are not schemas.
+				`"parameters":[{"$ref":"#/components/parameters/Limit","description":"Page size."}]`,
+			},
+			wantChanged: map[string]int{Const: 2, SingleUnion: 2, RefSiblings: 2},
+			wantNotes: []string{
+				`/components/schemas/Pet/properties/tags: const ["a"] is not a string, number or boolean; left as is`,
+				`/components/schemas/Pet/properties/size: const "l" is not one of the enum values; left as is`,
+				"/components/schemas/Pet/properties/nick: ogen ignores maxLength next to the $ref, and fails on a default next to an allOf; move them into the referenced schema",
+			},
+		},
+		{
+			name:   "skip",
+			passes: []string{SingleUnion},
+			want: []string{
+				`"kind":{"type":"string","const":"dog"}`,
+				`"owner":{"allOf":[{"$ref":"#/components/schemas/Owner"}]}`,
+				`"name":{"$ref":"#/components/schemas/Name","maxLength":64,"description":"The name.","x-order":1}`,
+			},
+			wantChanged: map[string]int{SingleUnion: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := specdoc.Parse([]byte(testSpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := Normalize(spec, tt.passes)
+			if err != nil {
+				t.Fatalf("Normalize: %v", err)
+			}
+
+			got, err := json.Marshal(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("output missing %s:\n%s", want, got)
+				}
+			}
+			if !reflect.DeepEqual(report.Changed, tt.wantChanged) {
+				t.Errorf("Changed = %v, want %v", report.Changed, tt.wantChanged)
+			}
+			var notes []string
+			for _, n := range report.Notes {
+				notes = append(notes, n.Pointer+": "+n.Message)
+			}
+			if !reflect.DeepEqual(notes, tt.wantNotes) {
+				t.Errorf("notes = %q, want %q", notes, tt.wantNotes)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, "-skip", "consts", input}); err == nil || !strings.Contains(err.Error(), `unknown pass "consts"`) {
+		t.Errorf("run -skip consts: error = %v, want unknown pass", err)
+	}
+
+	if err := run([]string{"-o", output, "-skip", "ref-siblings", input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `"enum": [`) || !strings.Contains(string(got), `"maxLength": 64,`) {
+		t.Errorf("output does not skip ref-siblings only:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
@@ -0,0 +1,355 @@
+// Command ogen-specopids gives every operation of an OpenAPI spec a unique,
+// readable operationId.
+//
+// ogen names the methods of the client and the handler interface after the
+// operationIds. Without one, it falls back to the path and method, giving
+// names such as APIV1PetsPetIDGet, and it fails on duplicate operationIds,
+// which specs merged from several services often have. This tool derives
+// the missing operationIds from the method and path, and renames duplicates:
+//
+//	GET    /api/v1/pets                   listPets
+//	POST   /api/v1/pets                   createPet
+//	GET    /api/v1/pets/{petId}           getPet
+//	PATCH  /api/v1/pets/{petId}           updatePet
+//	GET    /api/v1/pets/{petId}/photos    getPetPhotos
+//
+// Usage:
+//
+//	ogen-specopids -o openapi.ogen.json [-config opids.json] [-all] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// The config file sets the operationIds of given operations, which take
+// precedence over the spec and the derived ones:
+//
+//	{
+//	  "operations": {"POST /api/v1/pets/{petId}/adopt": "adoptPet"}
+//	}
+//
+// With -all, existing operationIds are replaced by derived ones too. The rest
+// of the spec is written back unchanged, with its keys in their original
+// order.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// Config is the operationId map read from the -config file.
+type Config struct {
+	// Operations maps "METHOD /path" to an operationId.
+	Operations map[string]string `json:"operations"`
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specopids: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specopids", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	configFile := fs.String("config", "", "JSON file with operationIds of given operations")
+	all := fs.Bool("all", false, "replace existing operationIds with derived ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specopids -o <output.json> [-config opids.json] [-all] <openapi.json>")
+	}
+
+	var cfg Config
+	if *configFile != "" {
+		var err error
+		if cfg, err = loadConfig(*configFile); err != nil {
+			return err
+		}
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := AssignIDs(spec, cfg, *all)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, r := range report.Renamed {
+		fmt.Fprintf(os.Stderr, "ogen-specopids: #%s: duplicate operationId %s renamed to %s\n", r.Pointer, r.Old, r.New)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated %d and renamed %d duplicate operationIds in %s\n", report.Generated, len(report.Renamed), *outputFile)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Report lists what AssignIDs changed.
+type Report struct {
+	// Generated is the number of operationIds derived from the method and
+	// path, or set from the config.
+	Generated int
+	// Renamed lists the duplicate operationIds given a suffix.
+	Renamed []Rename
+}
+
+// Rename is a duplicate operationId renamed at the JSON pointer of its
+// operation.
+type Rename struct {
+	Pointer string
+	Old     string
+	New     string
+}
+
+// Sources of operationIds, in the order they claim names.
+const (
+	fromConfig = iota
+	fromSpec
+	derived
+)
+
+// operation is an operation of the spec with the operationId it gets.
+type operation struct {
+	o      *specdoc.Object
+	ptr    string
+	id     string
+	source int
+}
+
+// AssignIDs sets the operationIds of the operations of spec: from the
+// config, or derived from the method and path if they have none, or all of
+// them with all. Duplicates, compared as ogen compares the Go names it
+// derives from them, get a numeric suffix in document order; operationIds
+// from the config, then from the spec, are kept over derived ones. It
+// returns an error for a config entry that matches no operation, or two
+// config entries with the same operationId.
+func AssignIDs(spec *specdoc.Object, cfg Config, all bool) (*Report, error) {
+	paths, _ := spec.Get("paths").(*specdoc.Object)
+	names := newNamer(paths.Keys())
+
+	matched := make(map[string]bool)
+	var ops []*operation
+	for _, path := range paths.Keys() {
+		item, ok := paths.Get(path).(*specdoc.Object)
+		if !ok {
+			continue
+		}
+		for _, method := range item.Keys() {
+			o, ok := item.Get(method).(*specdoc.Object)
+			if !ok || !specdoc.IsMethod(method) {
+				continue
+			}
+			op := &operation{o: o, ptr: specdoc.Pointer(specdoc.Pointer("/paths", path), method)}
+			key := strings.ToUpper(method) + " " + path
+			existing, _ := o.Get("operationId").(string)
+			if id, ok := cfg.Operations[key]; ok {
+				op.id, op.source = id, fromConfig
+				matched[key] = true
+			} else if strings.TrimSpace(existing) != "" && !all {
+				op.id, op.source = existing, fromSpec
+			} else {
+				op.id, op.source = names.derive(method, path), derived
+			}
+			ops = append(ops, op)
+		}
+	}
+	for key := range cfg.Operations {
+		if !matched[key] {
+			return nil, fmt.Errorf("config: no operation %s", key)
+		}
+	}
+
+	report := &Report{}
+	taken := make(map[string]*operation)
+	var renamed []*operation
+	for source := fromConfig; source <= derived; source++ {
+		for _, op := range ops {
+			if op.source != source {
+				continue
+			}
+			norm := specdoc.Normalize(op.id)
+			other, ok := taken[norm]
+			switch {
+			case !ok:
+				taken[norm] = op
+			case source == fromConfig:
+				return nil, fmt.Errorf("config: operationId %s is set for #%s and #%s", op.id, other.ptr, op.ptr)
+			default:
+				renamed = append(renamed, op)
+			}
+		}
+		// Renamed operations take the first free suffix once the
+		// operationIds of their source have been claimed.
+		for _, op := range renamed {
+			id := op.id
+			for i := 2; taken[specdoc.Normalize(id)] != nil; i++ {
+				id = op.id + strconv.Itoa(i)
+			}
+			if op.source == fromSpec {
+				report.Renamed = append(report.Renamed, Rename{Pointer: op.ptr, Old: op.id, New: id})
+			}
+			op.id = id
+			taken[specdoc.Normalize(id)] = op
+		}
+		renamed = nil
+	}
+
+	for _, op := range ops {
+		if existing, _ := op.o.Get("operationId").(string); existing == op.id {
+			continue
+		}
+		op.o.Set("operationId", op.id)
+		if op.source != fromSpec {
+			report.Generated++
+		}
+	}
+	return report, nil
+}
+
+// namer derives operationIds from the paths of a spec.
+type namer struct {
+	// prefix is the number of leading literal segments all paths share,
+	// such as api and v1, which are left out of the names.
+	prefix int
+	// collections holds the paths with an item path below them, such as
+	// /pets for /pets/{petId}.
+	collections map[string]bool
+}
+
+func newNamer(paths []string) *namer {
+	n := &namer{prefix: -1, collections: make(map[string]bool)}
+	var first []string
+	for _, path := range paths {
+		segments := splitPath(path)
+		if len(segments) > 0 && isParam(segments[len(segments)-1]) {
+			n.collections["/"+strings.Join(segments[:len(segments)-1], "/")] = true
+		}
+		if n.prefix < 0 {
+			first = segments
+			n.prefix = len(segments)
+		}
+		common := 0
+		for common < n.prefix && common < len(segments) && segments[common] == first[common] && !isParam(segments[common]) {
+			common++
+		}
+		n.prefix = common
+	}
+	return n
+}
+
+// derive returns the operationId of the operation at method and path: a
+// verb for the method, then the words of the path, with the resource before
+// a path parameter made singular.
+func (n *namer) derive(method, path string) string {
+	segments := splitPath(path)
+	lastLiteral := -1
+	for i, s := range segments {
+		if !isParam(s) {
+			lastLiteral = i
+		}
+	}
+
+	var words []string
+	for i, s := range segments {
+		if isParam(s) || i < n.prefix && i < lastLiteral {
+			continue
+		}
+		parts := splitWords(s)
+		if i+1 < len(segments) && isParam(segments[i+1]) && len(parts) > 0 {
+			parts[len(parts)-1] = singular(parts[len(parts)-1])
+		}
+		words = append(words, parts...)
+	}
+	if len(words) == 0 {
+		words = []string{"root"}
+	}
+
+	item := len(segments) > 0 && isParam(segments[len(segments)-1])
+	collection := !item && n.collections[path]
+	verb := method
+	switch {
+	case method == "get" && collection:
+		verb = "list"
+	case method == "post" && collection:
+		verb = "create"
+		words[len(words)-1] = singular(words[len(words)-1])
+	case method == "put" && item:
+		verb = "replace"
+	case method == "patch" && item:
+		verb = "update"
+	}
+
+	var b strings.Builder
+	b.WriteString(verb)
+	for _, w := range words {
+		r := []rune(w)
+		b.WriteString(string(unicode.ToUpper(r[0])) + string(r[1:]))
+	}
+	return b.String()
+}
+
+func splitPath(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// splitWords splits a path segment such as user-groups or userGroups.json
+// at the characters that are not letters or digits.
+func splitWords(segment string) []string {
+	return strings.FieldsFunc(segment, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// singular returns the singular of an English plural, such as pet for pets
+// and category for categories, or word itself.
+func singular(word string) string {
+	lower := strings.ToLower(word)
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(word) > 3:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"),
+		strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(lower, "ss"), strings.HasSuffix(lower, "us"), strings.HasSuffix(lower, "is"):
+		return word
+	case strings.HasSuffix(lower, "s") && len(word) > 1:
+		return word[:len(word)-1]
+	}
+	return word
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/api/v1/pets": {
+      "get": {"summary": "List pets"},
+      "post": {"summary": "Create a pet"}
+    },
+    "/api/v1/pets/{petId}": {
+      "parameters": [{"name": "petId", "in": "path", "required": true}],
+      "get": {"operationId": "get_pet"},
+      "delete": {"operationId": ""}
+    },
+    "/api/v1/pets/{petId}/photos": {
+      "get": {"operationId": "GetPet"},
+      "post": {"operationId": "getPet"}
+    },
+    "/api/v1/pet": {
+      "get": {}
+    }
+  }
+}`
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// operationIDs returns the "METHOD path: operationId" of every operation.
+func operationIDs(spec *specdoc.Object) []string {
+	var ids []string
+	paths, _ := spec.Get("paths").(*specdoc.Object)
+	for _, path := range paths.Keys() {
+		item, _ := paths.Get(path).(*specdoc.Object)
+		for _, method := range item.Keys() {
+			if op, ok := item.Get(method).(*specdoc.Object); ok && specdoc.IsMethod(method) {
+				id, _ := op.Get("operationId").(string)
+				ids = append(ids, strings.ToUpper(method)+" "+path+": "+id)
+			}
+		}
+	}
+	return ids
+}
+
+func TestAssignIDs(t *testing.T) {
+	spec := parse(t, testSpec)
+	report, err := AssignIDs(spec, Config{}, false)
+	if err != nil {
+		t.Fatalf("AssignIDs: %v", err)
+	}
+
+	want := []string{
+		"GET /api/v1/pets: listPets",
+		"POST /api/v1/pets: createPet",
+		"GET /api/v1/pets/{petId}: get_pet",
+		"DELETE /api/v1/pets/{petId}: deletePet",
+		// GetPet and getPet are the same Go method as get_pet.
+		"GET /api/v1/pets/{petId}/photos: GetPet2",
+		"POST /api/v1/pets/{petId}/photos: getPet3",
+		// getPet is taken by the operationIds of the spec.
+		"GET /api/v1/pet: getPet4",
+	}
+	if got := operationIDs(spec); !reflect.DeepEqual(got, want) {
+		t.Errorf("operationIds:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if report.Generated != 4 {
+		t.Errorf("Generated = %d, want 4", report.Generated)
+	}
+	wantRenamed := []Rename{
+		{Pointer: "/paths/~1api~1v1~1pets~1{petId}~1photos/get", Old: "GetPet", New: "GetPet2"},
+		{Pointer: "/paths/~1api~1v1~1pets~1{petId}~1photos/post", Old: "getPet", New: "getPet3"},
+	}
+	if !reflect.DeepEqual(report.Renamed, wantRenamed) {
+		t.Errorf("Renamed = %+v, want %+v", report.Renamed, wantRenamed)
+	}
+}
+
+func TestAssignIDs_Config(t *testing.T) {
+	spec := parse(t, testSpec)
+	cfg := Config{Operations: map[string]string{
+		"GET /api/v1/pets/{petId}/photos":  "listPetPhotos",
+		"POST /api/v1/pets/{petId}/photos": "addPetPhoto",
+	}}
+	report, err := AssignIDs(spec, cfg, true)
+	if err != nil {
+		t.Fatalf("AssignIDs: %v", err)
+	}
+
+	want := []string{
+		"GET /api/v1/pets: listPets",
+		"POST /api/v1/pets: createPet",
+		"GET /api/v1/pets/{petId}: getPet",
+		"DELETE /api/v1/pets/{petId}: deletePet",
+		"GET /api/v1/pets/{petId}/photos: listPetPhotos",
+		"POST /api/v1/pets/{petId}/photos: addPetPhoto",
+		"GET /api/v1/pet: getPet2",
+	}
+	if got := operationIDs(spec); !reflect.DeepEqual(got, want) {
+		t.Errorf("operationIds:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if report.Generated != 7 || len(report.Renamed) != 0 {
+		t.Errorf("report = %+v, want 7 generated and no renames", report)
+	}
+}
+
+func TestAssignIDs_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			name:    "unknown operation",
+			cfg:     Config{Operations: map[string]string{"GET /api/v1/owners": "listOwners"}},
+			wantErr: "config: no operation GET /api/v1/owners",
+		},
+		{
+			name: "duplicate",
+			cfg: Config{Operations: map[string]string{
+				"GET /api/v1/pets":  "listPets",
+				"POST /api/v1/pets": "ListPets",
+			}},
+			wantErr: "config: operationId ListPets is set for #/paths/~1api~1v1~1pets/get and #/paths/~1api~1v1~1pets/post",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := AssignIDs(parse(t, testSpec), tt.cfg, false)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("AssignIDs() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDerive(t *testing.T) {
+	n := newNamer([]string{
+		"/v2/users",
+		"/v2/users/{userId}",
+		"/v2/users/{userId}/addresses",
+		"/v2/users/{userId}/addresses/{addressId}",
+		"/v2/categories/{id}/boxes/{boxId}/status",
+		"/v2/health-check",
+		"/v2/{tenant}",
+	})
+	tests := []struct {
+		method, path string
+		want         string
+	}{
+		{"get", "/v2/users", "listUsers"},
+		{"post", "/v2/users", "createUser"},
+		{"get", "/v2/users/{userId}", "getUser"},
+		{"put", "/v2/users/{userId}", "replaceUser"},
+		{"patch", "/v2/users/{userId}", "updateUser"},
+		{"delete", "/v2/users/{userId}", "deleteUser"},
+		{"get", "/v2/users/{userId}/addresses", "listUserAddresses"},
+		{"post", "/v2/users/{userId}/addresses", "createUserAddress"},
+		{"get", "/v2/users/{userId}/addresses/{addressId}", "getUserAddress"},
+		{"post", "/v2/categories/{id}/boxes/{boxId}/status", "postCategoryBoxStatus"},
+		{"get", "/v2/health-check", "getHealthCheck"},
+		// The shared prefix is kept when nothing else is left.
+		{"get", "/v2/{tenant}", "getV2"},
+	}
+	for _, tt := range tests {
+		if got := n.derive(tt.method, tt.path); got != tt.want {
+			t.Errorf("derive(%s %s) = %s, want %s", tt.method, tt.path, got, tt.want)
+		}
+	}
+
+	if got := newNamer([]string{"/"}).derive("get", "/"); got != "getRoot" {
+		t.Errorf("derive(get /) = %s, want getRoot", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	config := filepath.Join(dir, "opids.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, []byte(`{"operations": {"GET /api/v1/pet": "getDefaultPet"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, "-config", config, input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `"get": {`+"\n"+`        "summary": "List pets",`+"\n"+`        "operationId": "listPets"`) {
+		t.Errorf("operationId not added after the other keys:\n%s", got)
+	}
+	if !strings.Contains(string(got), `"operationId": "getDefaultPet"`) {
+		t.Errorf("configured operationId missing:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
@@ -0,0 +1,326 @@
+// Command ogen-specoverlay applies OpenAPI Overlays to a spec.
+//
+// An overlay (https://spec.openapis.org/overlay/v1.0.0) is a document of
+// actions, each selecting nodes of the spec with a JSONPath target and
+// updating or removing them:
+//
+//	{
+//	  "overlay": "1.0.0",
+//	  "info": {"title": "Local fixes", "version": "1"},
+//	  "actions": [
+//	    {"target": "$.paths['/pets'].get", "update": {"operationId": "listPets"}},
+//	    {"target": "$.paths.*[?@.x-internal == true]", "remove": true}
+//	  ]
+//	}
+//
+// It replaces sed scripts that patch the spec before ogen reads it: the
+// changes are declared on the nodes they apply to, and an action whose
+// target no longer selects anything is reported instead of doing nothing
+// silently.
+//
+// Usage:
+//
+//	ogen-specoverlay -o openapi.ogen.json openapi.json overlay.json [overlay.json...]
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// Overlays are applied in order.
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/jsonpath"
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specoverlay: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specoverlay", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the spec with the overlays applied to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 2 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specoverlay -o <output.json> <openapi.json> <overlay.json>...")
+	}
+
+	spec, err := specdoc.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var total Stats
+	for _, filename := range fs.Args()[1:] {
+		overlay, err := ReadOverlay(filename)
+		if err != nil {
+			return err
+		}
+		stats, err := overlay.Apply(spec)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		for _, i := range stats.Unmatched {
+			fmt.Fprintf(os.Stderr, "ogen-specoverlay: %s: action %d: target %s selects nothing\n",
+				filename, i+1, overlay.Actions[i].Target)
+		}
+		total.Updated += stats.Updated
+		total.Removed += stats.Removed
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated %d and removed %d nodes in %s\n", total.Updated, total.Removed, *outputFile)
+	return nil
+}
+
+// Overlay is an OpenAPI Overlay document.
+type Overlay struct {
+	Actions []Action
+}
+
+// Action updates or removes the nodes its target selects.
+type Action struct {
+	Target      string
+	Description string
+	// Update is merged into the selected objects, or appended to the
+	// selected arrays.
+	Update any
+	Remove bool
+
+	query *jsonpath.Query
+}
+
+// Stats counts the nodes Apply changed.
+type Stats struct {
+	Updated int
+	Removed int
+	// Unmatched holds the indices of the actions that selected no node.
+	Unmatched []int
+}
+
+// ReadOverlay reads an overlay document and parses the targets of its
+// actions.
+func ReadOverlay(path string) (*Overlay, error) {
+	doc, err := specdoc.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	overlay, err := ParseOverlay(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return overlay, nil
+}
+
+// ParseOverlay reads the actions of an overlay document.
+func ParseOverlay(doc *specdoc.Object) (*Overlay, error) {
+	version, _ := doc.Get("overlay").(string)
+	if !strings.HasPrefix(version, "1.") {
+		return nil, fmt.Errorf("overlay version %q is not 1.x", version)
+	}
+	list, ok := doc.Get("actions").([]any)
+	if !ok {
+		return nil, fmt.Errorf("actions is not a list")
+	}
+
+	overlay := &Overlay{}
+	for i, v := range list {
+		o, ok := v.(*specdoc.Object)
+		if !ok {
+			return nil, fmt.Errorf("action %d is not an object", i+1)
+		}
+		a := Action{Update: o.Get("update")}
+		a.Target, _ = o.Get("target").(string)
+		a.Description, _ = o.Get("description").(string)
+		a.Remove, _ = o.Get("remove").(bool)
+		if !a.Remove && !o.Has("update") {
+			return nil, fmt.Errorf("action %d has neither update nor remove", i+1)
+		}
+		q, err := jsonpath.Parse(a.Target)
+		if err != nil {
+			return nil, fmt.Errorf("action %d: %w", i+1, err)
+		}
+		a.query = q
+		overlay.Actions = append(overlay.Actions, a)
+	}
+	return overlay, nil
+}
+
+// Apply applies the actions of the overlay to spec in order.
+func (o *Overlay) Apply(spec *specdoc.Object) (Stats, error) {
+	var stats Stats
+	for i, a := range o.Actions {
+		nodes := unique(a.query.Select(spec))
+		if len(nodes) == 0 {
+			stats.Unmatched = append(stats.Unmatched, i)
+			continue
+		}
+
+		var err error
+		if a.Remove {
+			err = remove(spec, nodes)
+			stats.Removed += len(nodes)
+		} else {
+			err = update(spec, nodes, a.Update)
+			stats.Updated += len(nodes)
+		}
+		if err != nil {
+			return stats, fmt.Errorf("action %d (%s): %w", i+1, a.Target, err)
+		}
+	}
+	return stats, nil
+}
+
+// update merges value into the selected objects, or appends it to the
+// selected arrays.
+func update(spec *specdoc.Object, nodes []jsonpath.Node, value any) error {
+	for _, n := range nodes {
+		switch target := n.Value.(type) {
+		case *specdoc.Object:
+			u, ok := value.(*specdoc.Object)
+			if !ok {
+				return fmt.Errorf("update of object #%s is not an object", n.Pointer())
+			}
+			merge(target, u)
+		case []any:
+			set(spec, n.Location, append(target, specdoc.Clone(value)))
+		default:
+			return fmt.Errorf("#%s is not an object or array", n.Pointer())
+		}
+	}
+	return nil
+}
+
+// merge merges the members of update into target: objects recursively,
+// arrays by appending the new elements, and other values by replacing them.
+func merge(target, update *specdoc.Object) {
+	for _, key := range update.Keys() {
+		v := update.Get(key)
+		switch cur := target.Get(key).(type) {
+		case *specdoc.Object:
+			if u, ok := v.(*specdoc.Object); ok {
+				merge(cur, u)
+				continue
+			}
+		case []any:
+			if u, ok := v.([]any); ok {
+				target.Set(key, append(cur, specdoc.Clone(u).([]any)...))
+				continue
+			}
+		}
+		// Each target gets its own copy, so that later actions change one
+		// of them only.
+		target.Set(key, specdoc.Clone(v))
+	}
+}
+
+// unique removes the nodes a query selected more than once, as $.a[0, 0]
+// does.
+func unique(nodes []jsonpath.Node) []jsonpath.Node {
+	seen := make(map[string]bool)
+	return slices.DeleteFunc(nodes, func(n jsonpath.Node) bool {
+		ptr := n.Pointer()
+		if seen[ptr] {
+			return true
+		}
+		seen[ptr] = true
+		return false
+	})
+}
+
+// remove removes the selected nodes from their objects and arrays. Later
+// array elements are removed first, so that the indices of earlier ones
+// stay valid.
+func remove(spec *specdoc.Object, nodes []jsonpath.Node) error {
+	nodes = slices.Clone(nodes)
+	slices.SortFunc(nodes, func(a, b jsonpath.Node) int {
+		return compareLocations(b.Location, a.Location)
+	})
+	for _, n := range nodes {
+		if len(n.Location) == 0 {
+			return fmt.Errorf("cannot remove the document root")
+		}
+		parentLoc := n.Location[:len(n.Location)-1]
+		parent, ok := get(spec, parentLoc)
+		if !ok {
+			// Within a node removed before.
+			continue
+		}
+		switch parent := parent.(type) {
+		case *specdoc.Object:
+			parent.Delete(n.Location[len(n.Location)-1].(string))
+		case []any:
+			i := n.Location[len(n.Location)-1].(int)
+			set(spec, parentLoc, slices.Delete(slices.Clone(parent), i, i+1))
+		}
+	}
+	return nil
+}
+
+// compareLocations orders locations as the document does, with array
+// indices compared as numbers.
+func compareLocations(a, b []any) int {
+	for i := range min(len(a), len(b)) {
+		var c int
+		switch x := a[i].(type) {
+		case int:
+			y, _ := b[i].(int)
+			c = cmp.Compare(x, y)
+		case string:
+			y, _ := b[i].(string)
+			c = strings.Compare(x, y)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// get returns the value at a location.
+func get(v any, loc []any) (any, bool) {
+	for _, key := range loc {
+		switch c := v.(type) {
+		case *specdoc.Object:
+			name, _ := key.(string)
+			if !c.Has(name) {
+				return nil, false
+			}
+			v = c.Get(name)
+		case []any:
+			i, _ := key.(int)
+			if i < 0 || i >= len(c) {
+				return nil, false
+			}
+			v = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// set replaces the value at a location that is not the root.
+func set(spec *specdoc.Object, loc []any, v any) {
+	parent, _ := get(spec, loc[:len(loc)-1])
+	switch parent := parent.(type) {
+	case *specdoc.Object:
+		parent.Set(loc[len(loc)-1].(string), v)
+	case []any:
+		parent[loc[len(loc)-1].(int)] = v
+	}
+}
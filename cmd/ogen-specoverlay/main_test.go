@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/pets": {
+      "get": {
+        "tags": ["pets"],
+        "parameters": [
+          {"name": "limit", "in": "query", "schema": {"type": "integer"}},
+          {"name": "debug", "in": "query", "schema": {"type": "boolean"}},
+          {"name": "trace", "in": "query", "schema": {"type": "boolean"}}
+        ]
+      },
+      "post": {"x-internal": true}
+    },
+    "/admin": {"get": {"x-internal": true}}
+  },
+  "components": {
+    "schemas": {
+      "Pet": {"type": "object", "properties": {"name": {"type": "string"}}}
+    }
+  }
+}`
+
+const testOverlay = `{
+  "overlay": "1.0.0",
+  "info": {"title": "Local fixes", "version": "1"},
+  "actions": [
+    {
+      "target": "$.paths['/pets'].get",
+      "description": "Name the operation and tag it",
+      "update": {"operationId": "listPets", "tags": ["public"]}
+    },
+    {"target": "$.paths.*[?@.x-internal == true]", "remove": true},
+    {"target": "$.paths[?@.get == null]", "remove": true},
+    {"target": "$..parameters[?@.name == 'debug' || @.name == 'trace']", "remove": true},
+    {"target": "$.components.schemas.Pet.properties", "update": {"name": {"maxLength": 64}, "age": {"type": "integer"}}},
+    {"target": "$.components.schemas.Pet.required", "update": ["name"]},
+    {"target": "$.components.schemas.Pet", "update": {"required": ["name"]}},
+    {"target": "$.paths['/pets'].get.tags", "update": "new"}
+  ]
+}`
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestApply(t *testing.T) {
+	spec := parse(t, testSpec)
+	overlay, err := ParseOverlay(parse(t, testOverlay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := overlay.Apply(spec)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	// A missing member is not null, and Pet has no required list yet.
+	want := Stats{Updated: 4, Removed: 4, Unmatched: []int{2, 5}}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	got, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+	for _, want := range []string{
+		// Arrays are appended to, other values are set.
+		`"/pets":{"get":{"tags":["pets","public","new"],"parameters":[{"name":"limit","in":"query","schema":{"type":"integer"}}],"operationId":"listPets"}}`,
+		// Removing the only operation of /admin leaves it empty.
+		`"/admin":{}`,
+		// Objects are merged recursively.
+		`"Pet":{"type":"object","properties":{"name":{"type":"string","maxLength":64},"age":{"type":"integer"}},"required":["name"]}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestApply_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions string
+		wantErr string
+	}{
+		{
+			name:    "update scalar",
+			actions: `[{"target": "$.openapi", "update": "3.1.0"}]`,
+			wantErr: "action 1 ($.openapi): #/openapi is not an object or array",
+		},
+		{
+			name:    "update object with scalar",
+			actions: `[{"target": "$.paths", "update": 1}]`,
+			wantErr: "action 1 ($.paths): update of object #/paths is not an object",
+		},
+		{
+			name:    "remove root",
+			actions: `[{"target": "$", "remove": true}]`,
+			wantErr: "action 1 ($): cannot remove the document root",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overlay, err := ParseOverlay(parse(t, `{"overlay": "1.0.0", "actions": `+tt.actions+`}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = overlay.Apply(parse(t, testSpec))
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Apply() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseOverlay_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		overlay string
+		wantErr string
+	}{
+		{
+			name:    "version",
+			overlay: `{"overlay": "2.0.0", "actions": []}`,
+			wantErr: `overlay version "2.0.0" is not 1.x`,
+		},
+		{
+			name:    "no actions",
+			overlay: `{"overlay": "1.0.0"}`,
+			wantErr: "actions is not a list",
+		},
+		{
+			name:    "no update",
+			overlay: `{"overlay": "1.0.0", "actions": [{"target": "$.paths"}]}`,
+			wantErr: "action 1 has neither update nor remove",
+		},
+		{
+			name:    "bad target",
+			overlay: `{"overlay": "1.0.0", "actions": [{"target": "paths", "remove": true}]}`,
+			wantErr: `action 1: jsonpath "paths": query must start with $`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseOverlay(parse(t, tt.overlay))
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ParseOverlay() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	overlay := filepath.Join(dir, "overlay.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(overlay, []byte(testOverlay), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, input, overlay}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(got); !strings.Contains(out, `"operationId": "listPets"`) || strings.Contains(out, `"x-internal"`) {
+		t.Errorf("output has not applied the overlay:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
@@ -0,0 +1,320 @@
+// Command ogen-specpatterns rewrites the regular expressions of an OpenAPI
+// spec that Go's regexp cannot run.
+//
+// OpenAPI patterns are ECMA-262 regular expressions. The code ogen generates
+// runs them on Go's RE2 engine if ogen can convert them, or else on regexp2,
+// a backtracking engine whose matching time has no linear bound. Patterns
+// neither engine compiles fail generation. This tool translates patterns to
+// forms RE2 runs, and removes, with a report, those that have none:
+//
+//	^(?<area>\d{3})-\d{4}$        ^(?:\d{3})-\d{4}$
+//	^\p{Script=Greek}+$           ^\p{Greek}+$
+//	^\p{Letter}[\p{gc=Nd}_]*$     ^\p{L}[\p{Nd}_]*$
+//	^(?=.*\d)(?=.*[a-z]).{8,}$    removed: lookahead (?= has no RE2 form
+//
+// Usage:
+//
+//	ogen-specpatterns -o openapi.ogen.json [-keep-backtracking] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// With -keep-backtracking, patterns without an RE2 form are left to regexp2
+// and reported, rather than removed. The rest of the spec is written back
+// unchanged, with its keys in their original order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/ogen-go/ogen/ogenregex"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specpatterns: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specpatterns", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	keep := fs.Bool("keep-backtracking", false, "leave patterns without an RE2 form to ogen's backtracking engine instead of removing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specpatterns -o <output.json> [-keep-backtracking] <openapi.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Rewrite(spec, *keep)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	counts := map[Action]int{}
+	for _, c := range report.Changes {
+		counts[c.Action]++
+		switch c.Action {
+		case Translated:
+			fmt.Fprintf(os.Stderr, "ogen-specpatterns: #%s: translated %s to %s\n", c.Pointer, c.Pattern, c.Result)
+		default:
+			fmt.Fprintf(os.Stderr, "ogen-specpatterns: #%s: %s %s: %s\n", c.Pointer, c.Action, c.Pattern, c.Reason)
+		}
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Checked %d patterns: %d translated, %d removed, %d kept for backtracking in %s\n",
+		report.Patterns, counts[Translated], counts[Removed], counts[Kept], *outputFile)
+	return nil
+}
+
+// Action is what Rewrite did to a pattern.
+type Action string
+
+const (
+	// Translated patterns were replaced by a form RE2 runs.
+	Translated Action = "translated"
+	// Removed patterns had no RE2 form.
+	Removed Action = "removed"
+	// Kept patterns have no RE2 form, and are left to regexp2.
+	Kept Action = "kept"
+)
+
+// Change is a pattern Rewrite translated, removed or kept.
+type Change struct {
+	// Pointer is the JSON pointer of the pattern, or of the
+	// patternProperties entry.
+	Pointer string
+	Pattern string
+	Action  Action
+	// Result is the translated pattern.
+	Result string
+	// Reason is why the pattern has no RE2 form.
+	Reason string
+}
+
+// Report lists what Rewrite did.
+type Report struct {
+	// Patterns counts the patterns of the spec.
+	Patterns int
+	Changes  []Change
+}
+
+// Rewrite translates the pattern keywords and patternProperties of spec
+// that RE2 cannot run, as ogen converts them, to forms it can. Those that
+// have none are removed, or with keepBacktracking left as they are if
+// regexp2 compiles them.
+func Rewrite(spec *specdoc.Object, keepBacktracking bool) (*Report, error) {
+	report := &Report{}
+	rewrite := func(ptr, pattern string) (string, bool) {
+		report.Patterns++
+		if runsOnRE2(pattern) {
+			return pattern, true
+		}
+		translated, err := translate(pattern)
+		if err == nil && runsOnRE2(translated) {
+			report.Changes = append(report.Changes, Change{Pointer: ptr, Pattern: pattern, Action: Translated, Result: translated})
+			return translated, true
+		}
+		reason := "no RE2 form"
+		if err != nil {
+			reason = err.Error()
+		}
+		if _, compileErr := ogenregex.Compile(pattern); compileErr != nil {
+			// ogen would fail to generate.
+			report.Changes = append(report.Changes, Change{Pointer: ptr, Pattern: pattern, Action: Removed, Reason: "invalid: " + compileErr.Error()})
+			return "", false
+		}
+		if keepBacktracking {
+			report.Changes = append(report.Changes, Change{Pointer: ptr, Pattern: pattern, Action: Kept, Reason: reason})
+			return pattern, true
+		}
+		report.Changes = append(report.Changes, Change{Pointer: ptr, Pattern: pattern, Action: Removed, Reason: reason})
+		return "", false
+	}
+
+	err := specdoc.Walk(spec, func(o *specdoc.Object, ptr string) error {
+		if pattern, ok := o.Get("pattern").(string); ok {
+			if result, ok := rewrite(ptr+"/pattern", pattern); !ok {
+				o.Delete("pattern")
+			} else if result != pattern {
+				o.Set("pattern", result)
+			}
+		}
+		props, _ := o.Get("patternProperties").(*specdoc.Object)
+		for _, pattern := range props.Keys() {
+			result, ok := rewrite(specdoc.Pointer(ptr+"/patternProperties", pattern), pattern)
+			switch {
+			case !ok:
+				props.Delete(pattern)
+			case result != pattern && props.Has(result):
+				return fmt.Errorf("#%s: translated pattern %s is a patternProperties key already", specdoc.Pointer(ptr+"/patternProperties", pattern), result)
+			case result != pattern:
+				props.Replace(pattern, result, props.Get(pattern))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// runsOnRE2 reports whether the generated code runs pattern on Go's
+// regexp: whether ogen converts it, and the result compiles.
+func runsOnRE2(pattern string) bool {
+	converted, ok := ogenregex.Convert(pattern)
+	if !ok {
+		return false
+	}
+	_, err := regexp.Compile(converted)
+	return err == nil
+}
+
+// translate rewrites the ECMA-262 constructs of pattern that have an RE2
+// form ogen does not convert: named groups become non-capturing groups, as
+// names do not change what matches, and Unicode property escapes get the
+// names RE2 knows. It returns an error for constructs RE2 has no form of.
+func translate(pattern string) (string, error) {
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		rest := pattern[i:]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			next := pattern[i+1]
+			switch {
+			case next == 'p' || next == 'P':
+				end := strings.IndexByte(rest, '}')
+				if !strings.HasPrefix(rest[2:], "{") || end < 0 {
+					b.WriteString(rest[:2])
+					i++
+					continue
+				}
+				name, err := property(rest[3:end])
+				if err != nil {
+					return "", err
+				}
+				b.WriteString(rest[:2] + "{" + name + "}")
+				i += end
+			case !inClass && next >= '1' && next <= '9':
+				return "", fmt.Errorf("backreference \\%c has no RE2 form", next)
+			case !inClass && next == 'k' && strings.HasPrefix(rest[2:], "<"):
+				return "", fmt.Errorf("named backreference \\k<...> has no RE2 form")
+			default:
+				b.WriteString(rest[:2])
+				i++
+			}
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+			b.WriteByte(c)
+		case c == '[':
+			inClass = true
+			b.WriteByte(c)
+			// A ] right after [ or [^ is the end of an empty class in
+			// ECMA-262: leave it to the loop.
+			if strings.HasPrefix(rest, "[^") {
+				b.WriteByte('^')
+				i++
+			}
+		case strings.HasPrefix(rest, "(?=") || strings.HasPrefix(rest, "(?!"):
+			return "", fmt.Errorf("lookahead %s has no RE2 form", rest[:3])
+		case strings.HasPrefix(rest, "(?<=") || strings.HasPrefix(rest, "(?<!"):
+			return "", fmt.Errorf("lookbehind %s has no RE2 form", rest[:4])
+		case strings.HasPrefix(rest, "(?<"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated group name")
+			}
+			b.WriteString("(?:")
+			i += end
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// generalCategories maps the long names of Unicode general categories to
+// their short ones, which Go's regexp knew alone before Go 1.25.
+var generalCategories = map[string]string{
+	"Letter":                "L",
+	"Cased_Letter":          "LC",
+	"Uppercase_Letter":      "Lu",
+	"Lowercase_Letter":      "Ll",
+	"Titlecase_Letter":      "Lt",
+	"Modifier_Letter":       "Lm",
+	"Other_Letter":          "Lo",
+	"Mark":                  "M",
+	"Nonspacing_Mark":       "Mn",
+	"Spacing_Mark":          "Mc",
+	"Enclosing_Mark":        "Me",
+	"Number":                "N",
+	"Decimal_Number":        "Nd",
+	"Letter_Number":         "Nl",
+	"Other_Number":          "No",
+	"Punctuation":           "P",
+	"Connector_Punctuation": "Pc",
+	"Dash_Punctuation":      "Pd",
+	"Open_Punctuation":      "Ps",
+	"Close_Punctuation":     "Pe",
+	"Initial_Punctuation":   "Pi",
+	"Final_Punctuation":     "Pf",
+	"Other_Punctuation":     "Po",
+	"Symbol":                "S",
+	"Math_Symbol":           "Sm",
+	"Currency_Symbol":       "Sc",
+	"Modifier_Symbol":       "Sk",
+	"Other_Symbol":          "So",
+	"Separator":             "Z",
+	"Space_Separator":       "Zs",
+	"Line_Separator":        "Zl",
+	"Paragraph_Separator":   "Zp",
+	"Other":                 "C",
+	"Control":               "Cc",
+	"Format":                "Cf",
+	"Surrogate":             "Cs",
+	"Private_Use":           "Co",
+	"Unassigned":            "Cn",
+}
+
+// property returns the RE2 name of the Unicode property of a \p{...}
+// escape: Script=Greek is Greek, and gc=Letter is L.
+func property(name string) (string, error) {
+	key, value, ok := strings.Cut(name, "=")
+	if !ok {
+		if short, ok := generalCategories[name]; ok {
+			return short, nil
+		}
+		return name, nil
+	}
+	switch key {
+	case "Script", "sc":
+		return value, nil
+	case "General_Category", "gc":
+		if short, ok := generalCategories[value]; ok {
+			return short, nil
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("property \\p{%s} has no RE2 form", name)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1"},
+  "paths": {
+    "/pets": {
+      "get": {
+        "parameters": [{"name": "tag", "in": "query", "schema": {"type": "string", "pattern": "^\\p{gc=Letter}+$"}}],
+        "responses": {"200": {"description": "OK"}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Pet": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string", "pattern": "^[a-z]+$"},
+          "phone": {"type": "string", "pattern": "^(?<area>\\d{3})-\\d{4}$"},
+          "password": {"type": "string", "pattern": "^(?=.*\\d).{8,}$"},
+          "pattern": {"type": "string", "pattern": "^(a)\\1$"}
+        },
+        "patternProperties": {"^\\p{Script=Greek}+$": {"type": "string"}}
+      }
+    }
+  }
+}`
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		in, want, wantErr string
+	}{
+		{in: `^(?<area>\d{3})-(?<n>\d{4})$`, want: `^(?:\d{3})-(?:\d{4})$`},
+		{in: `^\p{Script=Greek}\p{sc=Latin}$`, want: `^\p{Greek}\p{Latin}$`},
+		{in: `^\p{Letter}[\P{gc=Decimal_Number}_]\p{General_Category=Lu}$`, want: `^\p{L}[\P{Nd}_]\p{Lu}$`},
+		{in: `[(?<x>)]\(?<`, want: `[(?<x>)]\(?<`},
+		{in: `[\1]`, want: `[\1]`},
+		{in: `^(?=.*\d)`, wantErr: `lookahead (?= has no RE2 form`},
+		{in: `^(?!x)`, wantErr: `lookahead (?! has no RE2 form`},
+		{in: `(?<!x)y`, wantErr: `lookbehind (?<! has no RE2 form`},
+		{in: `(a)\1`, wantErr: `backreference \1 has no RE2 form`},
+		{in: `(?<a>x)\k<a>`, wantErr: `named backreference \k<...> has no RE2 form`},
+		{in: `\p{Script_Extensions=Greek}`, wantErr: `property \p{Script_Extensions=Greek} has no RE2 form`},
+	}
+	for _, tt := range tests {
+		got, err := translate(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("translate(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("translate(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		name string
+		keep bool
+		want []string
+		// wantActions lists the pointer and action of each change.
+		wantActions []string
+	}{
+		{
+			name: "remove",
+			want: []string{
+				`"schema":{"type":"string","pattern":"^\\p{L}+$"}`,
+				`"name":{"type":"string","pattern":"^[a-z]+$"}`,
+				`"phone":{"type":"string","pattern":"^(?:\\d{3})-\\d{4}$"}`,
+				`"password":{"type":"string"}`,
+				`"pattern":{"type":"string"}`,
+				`"patternProperties":{"^\\p{Greek}+$":{"type":"string"}}`,
+			},
+			wantActions: []string{
+				"/paths/~1pets/get/parameters/0/schema/pattern translated",
+				"/components/schemas/Pet/patternProperties/^\\p{Script=Greek}+$ translated",
+				"/components/schemas/Pet/properties/phone/pattern translated",
+				"/components/schemas/Pet/properties/password/pattern removed",
+				"/components/schemas/Pet/properties/pattern/pattern removed",
+			},
+		},
+		{
+			name: "keep backtracking",
+			keep: true,
+			want: []string{
+				`"password":{"type":"string","pattern":"^(?=.*\\d).{8,}$"}`,
+				`"pattern":{"type":"string","pattern":"^(a)\\1$"}`,
+			},
+			wantActions: []string{
+				"/paths/~1pets/get/parameters/0/schema/pattern translated",
+				"/components/schemas/Pet/patternProperties/^\\p{Script=Greek}+$ translated",
+				"/components/schemas/Pet/properties/phone/pattern translated",
+				"/components/schemas/Pet/properties/password/pattern kept",
+				"/components/schemas/Pet/properties/pattern/pattern kept",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := specdoc.Parse([]byte(testSpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := Rewrite(spec, tt.keep)
+			if err != nil {
+				t.Fatalf("Rewrite: %v", err)
+			}
+
+			got, err := json.Marshal(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("output missing %s:\n%s", want, got)
+				}
+			}
+			var actions []string
+			for _, c := range report.Changes {
+				actions = append(actions, c.Pointer+" "+string(c.Action))
+			}
+			if !reflect.DeepEqual(actions, tt.wantActions) {
+				t.Errorf("changes = %q, want %q", actions, tt.wantActions)
+			}
+			if report.Patterns != 6 {
+				t.Errorf("Patterns = %d, want 6", report.Patterns)
+			}
+		})
+	}
+}
+
+func TestRewrite_Invalid(t *testing.T) {
+	spec, err := specdoc.Parse([]byte(`{"openapi": "3.0.3", "components": {"schemas": {"Code": {"type": "string", "pattern": "^(a$"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Patterns neither engine compiles are removed even when keeping
+	// backtracking ones: ogen would fail to generate.
+	report, err := Rewrite(spec, true)
+	if err != nil {
+		t.Fatalf("Rewrite: %v", err)
+	}
+	if len(report.Changes) != 1 || report.Changes[0].Action != Removed || !strings.HasPrefix(report.Changes[0].Reason, "invalid: ") {
+		t.Errorf("changes = %+v, want one invalid pattern removed", report.Changes)
+	}
+	if _, ok := specdoc.Lookup(spec, "/components/schemas/Code/pattern"); ok {
+		t.Error("invalid pattern not removed")
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{input}); err == nil {
+		t.Error("run without -o: want error")
+	}
+
+	if err := run([]string{"-o", output, input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(got), `(?=`) {
+		t.Errorf("output has a lookahead:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
@@ -0,0 +1,159 @@
+// Command ogen-specprune removes the components of an OpenAPI spec that no
+// operation references, before ogen generates them.
+//
+// ogen generates a type for every schema of components, whether or not an
+// operation uses it. Specs that were filtered, overlaid or stripped carry
+// hundreds of such leftovers, and vendor specs often ship schemas of
+// deprecated or internal endpoints. This tool keeps the components the
+// operations reference, directly or through other components, and removes the
+// rest:
+//
+//	ogen-specprune -o openapi.ogen.json -keep 'schemas/Error,schemas/*Event' openapi.json
+//
+// -keep names components to keep anyway, such as schemas used by hand-written
+// code, as kind/name patterns in which * matches any characters. The
+// components they reference are kept too.
+//
+// Usage:
+//
+//	ogen-specprune -o openapi.ogen.json [-keep <patterns>] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specprune: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specprune", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the pruned spec to")
+	var keep specdoc.ListFlag
+	fs.Var(&keep, "keep", "kind/name patterns of components to keep, such as schemas/Error")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specprune -o <output.json> [-keep <patterns>] <openapi.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Prune(spec, keep)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, pattern := range report.Unmatched {
+		fmt.Fprintf(os.Stderr, "ogen-specprune: -keep %s matches no component\n", pattern)
+	}
+	for _, ptr := range report.Removed {
+		fmt.Fprintf(os.Stderr, "ogen-specprune: removed #%s\n", ptr)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Kept %d of %d components in %s\n", report.Total-len(report.Removed), report.Total, *outputFile)
+	return nil
+}
+
+// Report lists what Prune removed.
+type Report struct {
+	// Total is the number of components before pruning.
+	Total int
+	// Removed holds the JSON pointers of the removed components, in
+	// document order.
+	Removed []string
+	// Unmatched lists the -keep patterns that match no component, which
+	// are likely stale.
+	Unmatched []string
+}
+
+// Prune removes the components of spec that the rest of spec does not
+// reference, directly or through other components, except the security
+// schemes its requirements name and the components matching a keep pattern.
+// Component maps left empty are removed, and components itself if nothing
+// is left in it.
+func Prune(spec *specdoc.Object, keep []string) (*Report, error) {
+	report := &Report{}
+	components, ok := spec.Get("components").(*specdoc.Object)
+	if !ok {
+		report.Unmatched = keep
+		return report, nil
+	}
+
+	var pending []string
+	for _, key := range spec.Keys() {
+		if key != "components" {
+			pending = append(pending, specdoc.Refs(spec.Get(key))...)
+		}
+	}
+	for _, pattern := range keep {
+		if !strings.Contains(pattern, "/") {
+			return nil, fmt.Errorf("-keep: pattern %q is not kind/name, such as schemas/Error", pattern)
+		}
+		re, err := specdoc.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matched := false
+		for _, kind := range components.Keys() {
+			byName, ok := components.Get(kind).(*specdoc.Object)
+			if !ok || strings.HasPrefix(kind, "x-") {
+				continue
+			}
+			for _, name := range byName.Keys() {
+				if re.MatchString(kind + "/" + name) {
+					pending = append(pending, specdoc.Pointer(specdoc.Pointer("/components", kind), name))
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			report.Unmatched = append(report.Unmatched, pattern)
+		}
+	}
+
+	used := specdoc.Reachable(spec, append(pending, specdoc.SecuritySchemes(spec)...))
+
+	for _, kind := range components.Keys() {
+		byName, ok := components.Get(kind).(*specdoc.Object)
+		if !ok || strings.HasPrefix(kind, "x-") {
+			continue
+		}
+		for _, name := range byName.Keys() {
+			report.Total++
+			if ptr := specdoc.Pointer(specdoc.Pointer("/components", kind), name); !used[ptr] {
+				byName.Delete(name)
+				report.Removed = append(report.Removed, ptr)
+			}
+		}
+		if byName.Len() == 0 {
+			components.Delete(kind)
+		}
+	}
+	if components.Len() == 0 {
+		spec.Delete("components")
+	}
+	return report, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "security": [{"apiKey": []}],
+  "paths": {
+    "/pets": {
+      "get": {"operationId": "listPets", "parameters": [{"$ref": "#/components/parameters/limit"}], "responses": {"200": {"$ref": "#/components/responses/Pets"}}},
+      "post": {"operationId": "createPet", "security": [{"oauth": []}], "requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet/properties/shape"}}}}, "responses": {"201": {"description": "created"}}}
+    }
+  },
+  "components": {
+    "parameters": {"limit": {"name": "limit", "in": "query", "schema": {"type": "integer"}}, "offset": {"name": "offset", "in": "query", "schema": {"type": "integer"}}},
+    "responses": {"Pets": {"description": "ok", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}}}}}},
+    "schemas": {
+      "Pet": {"type": "object", "properties": {"owner": {"$ref": "#/components/schemas/Owner"}, "shape": {"oneOf": [{"$ref": "#/components/schemas/Circle"}], "discriminator": {"propertyName": "kind", "mapping": {"circle": "Circle", "square": "#/components/schemas/Square"}}}}},
+      "Owner": {"type": "object"},
+      "Circle": {"type": "object"},
+      "Square": {"type": "object"},
+      "Legacy": {"type": "object", "properties": {"owner": {"$ref": "#/components/schemas/LegacyOwner"}}},
+      "LegacyOwner": {"type": "object"},
+      "LegacyEvent": {"type": "object"},
+      "Unused": {"type": "object", "properties": {"pet": {"$ref": "#/components/schemas/Pet"}}}
+    },
+    "headers": {"RateLimit": {"schema": {"type": "integer"}}},
+    "securitySchemes": {"apiKey": {"type": "apiKey", "in": "header", "name": "X-Key"}, "oauth": {"type": "oauth2", "flows": {}}, "basic": {"type": "http", "scheme": "basic"}},
+    "x-internal": {"Note": {"$ref": "#/components/schemas/LegacyEvent"}}
+  }
+}`
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name        string
+		keep        []string
+		wantRemoved []string
+		unmatched   []string
+	}{
+		{
+			name: "reachable from operations",
+			wantRemoved: []string{
+				"/components/parameters/offset",
+				"/components/schemas/Legacy",
+				"/components/schemas/LegacyOwner",
+				"/components/schemas/LegacyEvent",
+				"/components/schemas/Unused",
+				"/components/headers/RateLimit",
+				"/components/securitySchemes/basic",
+			},
+		},
+		{
+			name: "keep patterns and what they reference",
+			keep: []string{"schemas/Legacy", "*/Rate*", "schemas/Gone*"},
+			wantRemoved: []string{
+				"/components/parameters/offset",
+				"/components/schemas/LegacyEvent",
+				"/components/schemas/Unused",
+				"/components/securitySchemes/basic",
+			},
+			unmatched: []string{"schemas/Gone*"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := parse(t, testSpec)
+			report, err := Prune(spec, tt.keep)
+			if err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+			want := &Report{Total: 15, Removed: tt.wantRemoved, Unmatched: tt.unmatched}
+			if !reflect.DeepEqual(report, want) {
+				t.Errorf("report = %+v, want %+v", report, want)
+			}
+			for _, ptr := range tt.wantRemoved {
+				if _, ok := specdoc.Lookup(spec, ptr); ok {
+					t.Errorf("#%s is still in the spec", ptr)
+				}
+			}
+		})
+	}
+}
+
+func TestPrune_EmptyComponents(t *testing.T) {
+	spec := parse(t, `{"openapi": "3.0.3", "paths": {}, "components": {"schemas": {"A": {"type": "object"}}}}`)
+	report, err := Prune(spec, nil)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if len(report.Removed) != 1 || spec.Has("components") {
+		t.Errorf("removed %v, components left: %v", report.Removed, spec.Has("components"))
+	}
+}
+
+func TestPrune_Errors(t *testing.T) {
+	_, err := Prune(parse(t, testSpec), []string{"Pet"})
+	want := `-keep: pattern "Pet" is not kind/name, such as schemas/Error`
+	if err == nil || err.Error() != want {
+		t.Errorf("Prune() error = %v, want %q", err, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, "-keep", "schemas/LegacyEvent", input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	components, _ := parse(t, string(got)).Get("components").(*specdoc.Object)
+	if kinds := components.Keys(); !reflect.DeepEqual(kinds, []string{"parameters", "responses", "schemas", "securitySchemes", "x-internal"}) {
+		t.Errorf("component kinds = %v", kinds)
+	}
+	schemas, _ := components.Get("schemas").(*specdoc.Object)
+	if names := schemas.Keys(); !reflect.DeepEqual(names, []string{"Pet", "Owner", "Circle", "Square", "LegacyEvent"}) {
+		t.Errorf("schemas = %v", names)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
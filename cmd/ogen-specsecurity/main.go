@@ -0,0 +1,275 @@
+// Command ogen-specsecurity reduces the alternative security requirements of
+// an OpenAPI spec to one per operation.
+//
+// Specs often let an operation authenticate in several ways:
+//
+//	"security": [{"apiKey": []}, {"oauth2": ["pets:read"]}]
+//
+// ogen generates a SecuritySource method for every scheme of every
+// alternative, and the client calls each of them on each request, so code
+// that uses one scheme implements the others to return
+// ogenerrors.ErrSkipClientSecurity. This tool keeps the alternative with the
+// scheme chosen in a config file, and reports what it dropped:
+//
+//	"security": [{"oauth2": ["pets:read"]}]
+//
+// Usage:
+//
+//	ogen-specsecurity -o openapi.ogen.json -prefer oauth2,apiKey [-config security.json] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// Config chooses the security scheme operations keep.
+type Config struct {
+	// Prefer lists security schemes in order of preference. An operation
+	// keeps the alternative with the first of them it has.
+	Prefer []string `json:"prefer"`
+	// Operations maps an operationId to the scheme it keeps, overriding
+	// Prefer.
+	Operations map[string]string `json:"operations"`
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specsecurity: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specsecurity", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	configFile := fs.String("config", "", "JSON file with the preferred schemes and the schemes of operations")
+	var prefer specdoc.ListFlag
+	fs.Var(&prefer, "prefer", "security schemes in order of preference, replacing those of the config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" || *configFile == "" && len(prefer) == 0 {
+		return fmt.Errorf("usage: ogen-specsecurity -o <output.json> [-prefer <schemes>] [-config <security.json>] <openapi.json>")
+	}
+
+	var cfg Config
+	if *configFile != "" {
+		var err error
+		if cfg, err = loadConfig(*configFile); err != nil {
+			return err
+		}
+	}
+	if len(prefer) > 0 {
+		cfg.Prefer = prefer
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Simplify(spec, cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, r := range report.Reduced {
+		fmt.Fprintf(os.Stderr, "ogen-specsecurity: #%s: kept %s, dropped %s\n", r.Pointer, r.Kept, strings.Join(r.Dropped, ", "))
+	}
+	for _, n := range report.Notes {
+		fmt.Fprintf(os.Stderr, "ogen-specsecurity: #%s: %s\n", n.Pointer, n.Message)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Reduced %d security requirements to one alternative in %s\n", len(report.Reduced), *outputFile)
+	return nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filename) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Report lists what Simplify changed.
+type Report struct {
+	// Reduced lists the security requirements reduced to one alternative.
+	Reduced []Reduction
+	// Notes lists the security requirements with none of the preferred
+	// schemes, which were left as they were.
+	Notes []Note
+}
+
+// Reduction is a security requirement reduced to one alternative, at a JSON
+// pointer of the spec.
+type Reduction struct {
+	Pointer string
+	// Kept is the kept alternative, with the schemes it requires together
+	// joined by +, such as "apiKey+basic".
+	Kept string
+	// Dropped lists the dropped alternatives, as Kept.
+	Dropped []string
+}
+
+// Note is a message about the security requirement at a JSON pointer.
+type Note struct {
+	Pointer string
+	Message string
+}
+
+// Simplify reduces the alternative security requirements of the spec and
+// its operations to the one with the scheme cfg chooses. An empty
+// requirement, which makes security optional, is kept with it. Operations
+// that inherit the security of the spec and are chosen another scheme by
+// cfg.Operations get a security requirement of their own.
+func Simplify(spec *specdoc.Object, cfg Config) (*Report, error) {
+	v, _ := specdoc.Lookup(spec, "/components/securitySchemes")
+	schemes, _ := v.(*specdoc.Object)
+	for _, name := range cfg.Prefer {
+		if !schemes.Has(name) {
+			return nil, fmt.Errorf("config: prefer: no security scheme %s", name)
+		}
+	}
+
+	report := &Report{}
+	// Operations chosen a scheme by cfg.Operations choose from the
+	// alternatives of the spec, not the one kept.
+	global, _ := spec.Get("security").([]any)
+	if global != nil {
+		spec.Set("security", reduce(report, "/security", global, cfg.Prefer))
+	}
+	matched := map[string]bool{}
+	paths, _ := spec.Get("paths").(*specdoc.Object)
+	for _, path := range paths.Keys() {
+		item, _ := paths.Get(path).(*specdoc.Object)
+		for _, method := range item.Keys() {
+			op, ok := item.Get(method).(*specdoc.Object)
+			if !ok || !specdoc.IsMethod(method) {
+				continue
+			}
+			ptr := specdoc.Pointer(specdoc.Pointer(specdoc.Pointer("/paths", path), method), "security")
+			opID, _ := op.Get("operationId").(string)
+			scheme, chosen := cfg.Operations[opID]
+			if !chosen {
+				if security, ok := op.Get("security").([]any); ok {
+					op.Set("security", reduce(report, ptr, security, cfg.Prefer))
+				}
+				continue
+			}
+
+			matched[opID] = true
+			security, ok := op.Get("security").([]any)
+			if !ok {
+				security = global
+			}
+			reduced, kept, dropped := choose(security, scheme)
+			if kept == "" {
+				return nil, fmt.Errorf("config: operations: %s: %s is not one of its security schemes (%s)", opID, scheme, strings.Join(alternatives(security), ", "))
+			}
+			if len(dropped) > 0 {
+				op.Set("security", reduced)
+				report.Reduced = append(report.Reduced, Reduction{Pointer: ptr, Kept: kept, Dropped: dropped})
+			}
+		}
+	}
+
+	var unmatched []string
+	for opID := range cfg.Operations {
+		if !matched[opID] {
+			unmatched = append(unmatched, opID)
+		}
+	}
+	if len(unmatched) > 0 {
+		sort.Strings(unmatched)
+		return nil, fmt.Errorf("config: operations: no operation %s", strings.Join(unmatched, ", "))
+	}
+	return report, nil
+}
+
+// reduce returns security reduced to the alternative with the first of the
+// preferred schemes it has, recording the reduction in report. It returns
+// security as it is if it has one alternative, or none of the schemes.
+func reduce(report *Report, ptr string, security []any, prefer []string) []any {
+	if len(alternatives(security)) < 2 {
+		return security
+	}
+	for _, scheme := range prefer {
+		if reduced, kept, dropped := choose(security, scheme); kept != "" {
+			report.Reduced = append(report.Reduced, Reduction{Pointer: ptr, Kept: kept, Dropped: dropped})
+			return reduced
+		}
+	}
+	report.Notes = append(report.Notes, Note{
+		Pointer: ptr,
+		Message: fmt.Sprintf("none of the preferred schemes is one of %s; left as is", strings.Join(alternatives(security), ", ")),
+	})
+	return security
+}
+
+// choose returns security reduced to its alternative with scheme, and the
+// kept and dropped alternatives. Of several alternatives with scheme, the
+// one requiring the fewest schemes is kept. kept is "" if no alternative
+// has scheme.
+func choose(security []any, scheme string) (reduced []any, kept string, dropped []string) {
+	best := -1
+	for i, v := range security {
+		req, ok := v.(*specdoc.Object)
+		if ok && req.Has(scheme) && (best < 0 || req.Len() < security[best].(*specdoc.Object).Len()) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil, "", nil
+	}
+	for i, v := range security {
+		req, ok := v.(*specdoc.Object)
+		switch {
+		case i == best:
+			kept = name(req)
+			reduced = append(reduced, v)
+		case ok && req.Len() == 0:
+			// An empty requirement makes security optional.
+			reduced = append(reduced, v)
+		default:
+			dropped = append(dropped, name(req))
+		}
+	}
+	return reduced, kept, dropped
+}
+
+// alternatives returns the names of the non-empty alternatives of security.
+func alternatives(security []any) []string {
+	var names []string
+	for _, v := range security {
+		if req, ok := v.(*specdoc.Object); ok && req.Len() > 0 {
+			names = append(names, name(req))
+		}
+	}
+	return names
+}
+
+// name returns the schemes of a security requirement joined by +.
+func name(req *specdoc.Object) string {
+	return strings.Join(req.Keys(), "+")
+}
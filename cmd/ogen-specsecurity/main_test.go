@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1"},
+  "security": [{"apiKey": []}, {"oauth2": ["pets:read"]}],
+  "paths": {
+    "/pets": {
+      "get": {"operationId": "listPets", "responses": {"200": {"description": "OK"}}},
+      "post": {
+        "operationId": "createPet",
+        "security": [{"oauth2": ["pets:write"]}, {"basic": [], "apiKey": []}, {"apiKey": []}, {}],
+        "responses": {"200": {"description": "OK"}}
+      }
+    },
+    "/health": {
+      "get": {"operationId": "health", "security": [{"basic": []}, {"mtls": []}], "responses": {"200": {"description": "OK"}}}
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "apiKey": {"type": "apiKey", "in": "header", "name": "X-API-Key"},
+      "basic": {"type": "http", "scheme": "basic"},
+      "mtls": {"type": "http", "scheme": "bearer"},
+      "oauth2": {"type": "oauth2", "flows": {"clientCredentials": {"tokenUrl": "https://example.com/token", "scopes": {"pets:read": "", "pets:write": ""}}}}
+    }
+  }
+}`
+
+func TestSimplify(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []Reduction
+		// security maps pointers to the security requirement they have
+		// after Simplify.
+		security map[string]string
+		notes    []string
+	}{
+		{
+			name: "prefer",
+			cfg:  Config{Prefer: []string{"apiKey", "basic"}},
+			want: []Reduction{
+				{"/security", "apiKey", []string{"oauth2"}},
+				{"/paths/~1pets/post/security", "apiKey", []string{"oauth2", "basic+apiKey"}},
+				{"/paths/~1health/get/security", "basic", []string{"mtls"}},
+			},
+			security: map[string]string{
+				"/security":                   `[{"apiKey":[]}]`,
+				"/paths/~1pets/post/security": `[{"apiKey":[]},{}]`,
+			},
+		},
+		{
+			name: "operations",
+			cfg:  Config{Prefer: []string{"oauth2"}, Operations: map[string]string{"listPets": "apiKey", "createPet": "basic"}},
+			want: []Reduction{
+				{"/security", "oauth2", []string{"apiKey"}},
+				{"/paths/~1pets/get/security", "apiKey", []string{"oauth2"}},
+				{"/paths/~1pets/post/security", "basic+apiKey", []string{"oauth2", "apiKey"}},
+			},
+			security: map[string]string{
+				"/security":                    `[{"oauth2":["pets:read"]}]`,
+				"/paths/~1pets/get/security":   `[{"apiKey":[]}]`,
+				"/paths/~1pets/post/security":  `[{"basic":[],"apiKey":[]},{}]`,
+				"/paths/~1health/get/security": `[{"basic":[]},{"mtls":[]}]`,
+			},
+			notes: []string{"/paths/~1health/get/security"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := parse(t, testSpec)
+			report, err := Simplify(spec, tt.cfg)
+			if err != nil {
+				t.Fatalf("Simplify: %v", err)
+			}
+			if !reflect.DeepEqual(report.Reduced, tt.want) {
+				t.Errorf("Reduced = %v, want %v", report.Reduced, tt.want)
+			}
+			var notes []string
+			for _, n := range report.Notes {
+				notes = append(notes, n.Pointer)
+			}
+			if !reflect.DeepEqual(notes, tt.notes) {
+				t.Errorf("Notes = %v, want %v", report.Notes, tt.notes)
+			}
+			for ptr, want := range tt.security {
+				v, _ := specdoc.Lookup(spec, ptr)
+				if got, _ := json.Marshal(v); string(got) != want {
+					t.Errorf("%s = %s, want %s", ptr, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSimplify_Errors(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{Prefer: []string{"token"}}, "config: prefer: no security scheme token"},
+		{Config{Operations: map[string]string{"health": "apiKey"}}, "config: operations: health: apiKey is not one of its security schemes (basic, mtls)"},
+		{Config{Operations: map[string]string{"deletePet": "apiKey", "adoptPet": "basic"}}, "config: operations: no operation adoptPet, deletePet"},
+	}
+	for _, tt := range tests {
+		_, err := Simplify(parse(t, testSpec), tt.cfg)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Simplify(%+v) error = %v, want %s", tt.cfg, err, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	config := filepath.Join(dir, "security.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, []byte(`{"prefer": ["apiKey"], "operations": {"health": "basic"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, input}); err == nil {
+		t.Error("run without -prefer or -config: want error")
+	}
+
+	if err := run([]string{"-o", output, "-config", config, "-prefer", "oauth2", input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// -prefer replaces the preferred schemes of the config.
+	if !strings.Contains(string(got), "\"security\": [\n    {\n      \"oauth2\"") {
+		t.Errorf("output does not keep oauth2:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
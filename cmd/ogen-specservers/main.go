@@ -0,0 +1,257 @@
+// Command ogen-specservers reduces the servers of an OpenAPI spec to the one
+// the generated client uses, as a constant.
+//
+// ogen generates nothing for servers unless they are named with
+// x-ogen-server-name, and stops on URL templates whose variables are not
+// declared, or have no default. Specs often list several servers, such as
+// production, sandbox and a templated regional host, and the client code
+// passes one of them to NewClient as a string literal.
+//
+// This tool keeps one server, selected by a URL pattern, resolves its
+// variables from -var flags and their defaults, optionally rewrites its base
+// path, and names it so ogen generates it as a constant:
+//
+//	ogen-specservers -o openapi.ogen.json -server 'https://{region}.*' -var region=eu -base-path /v2 openapi.json
+//
+// keeps only https://eu.api.example.com/v2, named Default, which ogen
+// generates as the constant DefaultServer:
+//
+//	client, err := api.NewClient(api.DefaultServer.MustBuild(), sec)
+//
+// Usage:
+//
+//	ogen-specservers -o openapi.ogen.json [-server <pattern>] [-var name=value] [-base-path /v2] [-name Default] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/token"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specservers: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specservers", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the normalized spec to")
+	var opts Options
+	fs.StringVar(&opts.Server, "server", "", "pattern of the URL of the server to keep, with * matching any characters (default: the first server)")
+	var vars specdoc.ListFlag
+	fs.Var(&vars, "var", "name=value of a server variable")
+	basePath := fs.String("base-path", "", "path to replace the path of the server URL with")
+	fs.StringVar(&opts.Name, "name", "Default", "x-ogen-server-name of the server; ogen generates <name>Server")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specservers -o <output.json> [-server <pattern>] [-var name=value] [-base-path <path>] [-name <name>] <openapi.json>")
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "base-path" {
+			opts.BasePath = basePath
+		}
+	})
+	opts.Vars = map[string]string{}
+	for _, v := range vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("-var: %q is not name=value", v)
+		}
+		opts.Vars[name] = value
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Normalize(spec, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, r := range report.Removed {
+		fmt.Fprintf(os.Stderr, "ogen-specservers: removed server %s\n", r)
+	}
+	for _, u := range report.Unused {
+		fmt.Fprintf(os.Stderr, "ogen-specservers: -var %s matches no variable of the server\n", u)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Kept server %s as %sServer in %s\n", report.URL, opts.Name, *outputFile)
+	return nil
+}
+
+// Options selects the server to keep and how to rewrite it.
+type Options struct {
+	// Server is a pattern of the URL of the server to keep, with *
+	// matching any characters. Empty selects the first server.
+	Server string
+	// Vars holds the values of server variables, which override their
+	// defaults.
+	Vars map[string]string
+	// BasePath, if not nil, replaces the path of the server URL.
+	BasePath *string
+	// Name is the x-ogen-server-name of the server.
+	Name string
+}
+
+// Report lists what Normalize did.
+type Report struct {
+	// URL is the URL of the server kept.
+	URL string
+	// Removed lists the URLs of the other servers.
+	Removed []string
+	// Unused lists the names of Vars the server has no variable for.
+	Unused []string
+}
+
+// variable matches a server variable in a URL template.
+var variable = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// Normalize replaces the servers of spec by the one opts selects, with its
+// variables resolved and opts.Name as its x-ogen-server-name.
+func Normalize(spec *specdoc.Object, opts Options) (*Report, error) {
+	// ogen's own check of server names.
+	if !token.IsIdentifier(opts.Name + "Server") {
+		return nil, fmt.Errorf("-name %q is not a Go identifier", opts.Name)
+	}
+	servers, _ := spec.Get("servers").([]any)
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no servers; add one with an overlay, or pass the URL to NewClient")
+	}
+
+	report := &Report{}
+	var kept *specdoc.Object
+	for i, v := range servers {
+		s, ok := v.(*specdoc.Object)
+		if !ok {
+			return nil, fmt.Errorf("#/servers/%d is not an object", i)
+		}
+		u, _ := s.Get("url").(string)
+		if kept == nil && (opts.Server == "" || match(opts.Server, u)) {
+			kept = s
+		} else {
+			report.Removed = append(report.Removed, u)
+		}
+	}
+	if kept == nil {
+		return nil, fmt.Errorf("-server %s matches no server", opts.Server)
+	}
+
+	u, err := resolve(kept, opts.Vars, report)
+	if err != nil {
+		return nil, err
+	}
+	if u, err = rewritePath(u, opts.BasePath); err != nil {
+		return nil, err
+	}
+	report.URL = u
+
+	server := specdoc.NewObject()
+	server.Set("url", u)
+	for _, key := range kept.Keys() {
+		switch key {
+		case "url", "variables", "x-ogen-server-name":
+		default:
+			server.Set(key, kept.Get(key))
+		}
+	}
+	server.Set("x-ogen-server-name", opts.Name)
+	spec.Set("servers", []any{server})
+	return report, nil
+}
+
+// resolve returns the URL of server with its variables replaced by their
+// values in vars or their defaults.
+func resolve(server *specdoc.Object, vars map[string]string, report *Report) (string, error) {
+	u, _ := server.Get("url").(string)
+	if u == "" {
+		return "", fmt.Errorf("server has no url")
+	}
+	declared, _ := server.Get("variables").(*specdoc.Object)
+
+	used := map[string]bool{}
+	var errs []string
+	resolved := variable.ReplaceAllStringFunc(u, func(m string) string {
+		name := m[1 : len(m)-1]
+		used[name] = true
+		decl, _ := declared.Get(name).(*specdoc.Object)
+		value, ok := vars[name]
+		if !ok {
+			value, _ = decl.Get("default").(string)
+		}
+		if value == "" {
+			errs = append(errs, fmt.Sprintf("server variable {%s} of %s has no default; set it with -var %s=<value>", name, u, name))
+			return m
+		}
+		if enum, ok := decl.Get("enum").([]any); ok && !slices.Contains(enum, any(value)) {
+			errs = append(errs, fmt.Sprintf("-var %s=%s is not one of the enum of {%s}", name, value, name))
+		}
+		return value
+	})
+	if len(errs) > 0 {
+		return "", errors.New(strings.Join(errs, "; "))
+	}
+
+	for name := range vars {
+		if !used[name] {
+			report.Unused = append(report.Unused, name)
+		}
+	}
+	slices.Sort(report.Unused)
+	return resolved, nil
+}
+
+// rewritePath replaces the path of u by basePath, if not nil, and removes
+// its trailing slash: the client appends operation paths, which start with
+// a slash, to it.
+func rewritePath(u string, basePath *string) (string, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", fmt.Errorf("server url %q: %w", u, err)
+	}
+	if basePath != nil {
+		p := *basePath
+		if p != "" && !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+		parsed.Path = p
+		parsed.RawPath = ""
+	}
+	parsed.Path = strings.TrimRight(parsed.Path, "/")
+	parsed.RawPath = strings.TrimRight(parsed.RawPath, "/")
+	if parsed.String() == "" {
+		// ogen rejects an empty URL.
+		return "/", nil
+	}
+	return parsed.String(), nil
+}
+
+// match reports whether url matches pattern, with * matching any
+// characters.
+func match(pattern, url string) bool {
+	re, err := specdoc.Glob(pattern)
+	return err == nil && re.MatchString(url)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1"},
+  "servers": [
+    {"url": "https://api.example.com/v1/", "description": "Production"},
+    {"url": "https://{region}.api.example.com/{version}", "variables": {"region": {"default": "us", "enum": ["us", "eu"]}, "version": {"default": "v1"}}},
+    {"url": "https://sandbox.example.com/{version}", "x-ogen-server-name": "Sandbox"}
+  ],
+  "paths": {}
+}`
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		want        string
+		wantRemoved []string
+		wantUnused  []string
+	}{
+		{
+			name: "first",
+			opts: Options{Name: "Default"},
+			// The trailing slash would double the one of operation paths.
+			want:        `[{"url":"https://api.example.com/v1","description":"Production","x-ogen-server-name":"Default"}]`,
+			wantRemoved: []string{"https://{region}.api.example.com/{version}", "https://sandbox.example.com/{version}"},
+		},
+		{
+			name:        "defaults",
+			opts:        Options{Server: "https://{region}*", Name: "API"},
+			want:        `[{"url":"https://us.api.example.com/v1","x-ogen-server-name":"API"}]`,
+			wantRemoved: []string{"https://api.example.com/v1/", "https://sandbox.example.com/{version}"},
+		},
+		{
+			name:        "vars and base path",
+			opts:        Options{Server: "*{region}*", Vars: map[string]string{"region": "eu", "zone": "a"}, BasePath: ptr("v2/"), Name: "EU"},
+			want:        `[{"url":"https://eu.api.example.com/v2","x-ogen-server-name":"EU"}]`,
+			wantRemoved: []string{"https://api.example.com/v1/", "https://sandbox.example.com/{version}"},
+			wantUnused:  []string{"zone"},
+		},
+		{
+			name:        "undeclared variable",
+			opts:        Options{Server: "https://sandbox*", Vars: map[string]string{"version": "beta"}, BasePath: ptr(""), Name: "Default"},
+			want:        `[{"url":"https://sandbox.example.com","x-ogen-server-name":"Default"}]`,
+			wantRemoved: []string{"https://api.example.com/v1/", "https://{region}.api.example.com/{version}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := parse(t, testSpec)
+			report, err := Normalize(spec, tt.opts)
+			if err != nil {
+				t.Fatalf("Normalize: %v", err)
+			}
+			if got, _ := json.Marshal(spec.Get("servers")); string(got) != tt.want {
+				t.Errorf("servers = %s, want %s", got, tt.want)
+			}
+			if !reflect.DeepEqual(report.Removed, tt.wantRemoved) {
+				t.Errorf("Removed = %q, want %q", report.Removed, tt.wantRemoved)
+			}
+			if !reflect.DeepEqual(report.Unused, tt.wantUnused) {
+				t.Errorf("Unused = %q, want %q", report.Unused, tt.wantUnused)
+			}
+		})
+	}
+}
+
+func TestNormalize_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		opts    Options
+		wantErr string
+	}{
+		{
+			name:    "no servers",
+			spec:    `{"openapi": "3.0.3", "servers": []}`,
+			opts:    Options{Name: "Default"},
+			wantErr: "no servers; add one with an overlay, or pass the URL to NewClient",
+		},
+		{
+			name:    "no match",
+			spec:    testSpec,
+			opts:    Options{Server: "http://*", Name: "Default"},
+			wantErr: "-server http://* matches no server",
+		},
+		{
+			name:    "no default",
+			spec:    testSpec,
+			opts:    Options{Server: "*sandbox*", Name: "Default"},
+			wantErr: "server variable {version} of https://sandbox.example.com/{version} has no default; set it with -var version=<value>",
+		},
+		{
+			name:    "not in enum",
+			spec:    testSpec,
+			opts:    Options{Server: "*{region}*", Vars: map[string]string{"region": "ap"}, Name: "Default"},
+			wantErr: "-var region=ap is not one of the enum of {region}",
+		},
+		{
+			name:    "name",
+			spec:    testSpec,
+			opts:    Options{Name: "my-api"},
+			wantErr: `-name "my-api" is not a Go identifier`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Normalize(parse(t, tt.spec), tt.opts)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Normalize() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, "-var", "bad", input}); err == nil || err.Error() != `-var: "bad" is not name=value` {
+		t.Errorf("run with a bad -var: error = %v", err)
+	}
+	if err := run([]string{"-o", output, "-server", "*{region}*", "-var", "region=eu,version=v3", "-base-path", "", input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "\"servers\": [\n    {\n      \"url\": \"https://eu.api.example.com\",") {
+		t.Errorf("output has no resolved server:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
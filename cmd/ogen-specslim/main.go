@@ -0,0 +1,242 @@
+// Command ogen-specslim removes the documentation of an OpenAPI spec that
+// generation does not need, such as examples and descriptions.
+//
+// Vendor specs embed an example for every schema, parameter and response,
+// and paragraphs of description, which make up most of their size. ogen
+// parses all of it, which slows generation, and copies every description
+// into doc comments of the generated code. This tool removes them, leaving
+// what the generated types and methods depend on:
+//
+//	ogen-specslim -o openapi.ogen.json -remove examples,descriptions openapi.json
+//
+// Descriptions can be shortened to their first sentence instead, which
+// keeps a one-line doc comment on every generated type and field.
+//
+// Usage:
+//
+//	ogen-specslim -o openapi.ogen.json [-remove examples,descriptions,summaries,externalDocs] [-first-sentence] openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specslim: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specslim", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the slimmed spec to")
+	var remove specdoc.ListFlag
+	fs.Var(&remove, "remove", "what to remove: examples, descriptions, summaries, externalDocs (default examples,descriptions)")
+	firstSentence := fs.Bool("first-sentence", false, "shorten descriptions to their first sentence instead of removing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specslim -o <output.json> [-remove <kinds>] [-first-sentence] <openapi.json>")
+	}
+	if len(remove) == 0 {
+		remove = specdoc.ListFlag{Examples, Descriptions}
+	}
+	for _, kind := range remove {
+		if !slices.Contains(Kinds, kind) {
+			return fmt.Errorf("-remove: unknown kind %q, want one of %s", kind, strings.Join(Kinds, ", "))
+		}
+	}
+	if *firstSentence && !slices.Contains(remove, Descriptions) {
+		return fmt.Errorf("-first-sentence: -remove has no descriptions")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Slim(spec, Options{Remove: remove, FirstSentence: *firstSentence})
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	var counts []string
+	for _, kind := range Kinds {
+		if n := report.Removed[kind]; n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, kind))
+		}
+	}
+	done := "removed nothing"
+	if len(counts) > 0 {
+		done = "removed " + strings.Join(counts, ", ")
+	}
+	if report.Shortened > 0 {
+		done += fmt.Sprintf(", shortened %d descriptions", report.Shortened)
+	}
+	fmt.Printf("Slimmed %s (%s) to %s (%s): %s\n", filename, size(filename), *outputFile, size(*outputFile), done)
+	return nil
+}
+
+// The kinds of documentation Slim removes.
+const (
+	// Examples are the example and examples of schemas, parameters, media
+	// types and headers, and the example components.
+	Examples = "examples"
+	// Descriptions are the description of every object. Responses, which
+	// require one, keep an empty description.
+	Descriptions = "descriptions"
+	// Summaries are the summary of operations and path items.
+	Summaries = "summaries"
+	// ExternalDocs are the externalDocs of every object.
+	ExternalDocs = "externalDocs"
+)
+
+// Kinds lists the kinds of documentation Slim removes, in the order of
+// reports.
+var Kinds = []string{Examples, Descriptions, Summaries, ExternalDocs}
+
+// keys maps each kind to the keys it removes.
+var keys = map[string][]string{
+	Examples:     {"example", "examples"},
+	Descriptions: {"description"},
+	Summaries:    {"summary"},
+	ExternalDocs: {"externalDocs"},
+}
+
+// Options selects what Slim removes.
+type Options struct {
+	// Remove lists the kinds to remove.
+	Remove []string
+	// FirstSentence shortens descriptions to their first sentence instead
+	// of removing them.
+	FirstSentence bool
+}
+
+// Report counts what Slim removed.
+type Report struct {
+	// Removed counts the keys removed by kind.
+	Removed map[string]int
+	// Shortened counts the descriptions shortened to their first sentence.
+	Shortened int
+}
+
+// Slim removes the documentation opts selects from spec. It leaves the keys
+// of objects whose keys are names rather than keywords, such as properties,
+// discriminator mappings and oauth2 scopes, and the values of examples,
+// defaults, enums and extensions.
+func Slim(spec *specdoc.Object, opts Options) (*Report, error) {
+	report := &Report{Removed: map[string]int{}}
+	err := specdoc.Walk(spec, func(o *specdoc.Object, ptr string) error {
+		if isNameMap(ptr) {
+			return specdoc.SkipChildren
+		}
+		for _, kind := range opts.Remove {
+			for _, key := range keys[kind] {
+				if !o.Has(key) {
+					continue
+				}
+				switch {
+				case kind == Descriptions && opts.FirstSentence:
+					if d, ok := o.Get(key).(string); ok && firstSentence(d) != d {
+						o.Set(key, firstSentence(d))
+						report.Shortened++
+					}
+				case kind == Descriptions && isResponse(ptr):
+					if o.Get(key) != "" {
+						o.Set(key, "")
+						report.Removed[kind]++
+					}
+				default:
+					o.Delete(key)
+					report.Removed[kind]++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// isNameMap reports whether the object at ptr maps names to values, such as
+// a discriminator mapping, the scopes of an oauth2 flow or a security
+// requirement: a scope named description is not a description.
+func isNameMap(ptr string) bool {
+	segments := strings.Split(ptr, "/")
+	n := len(segments)
+	// Properties of these names are schemas.
+	parent := func(i int) string {
+		if n-i-1 < 0 {
+			return ""
+		}
+		return segments[n-i-1]
+	}
+	switch {
+	case (parent(0) == "mapping" || parent(0) == "scopes") && parent(1) != "properties":
+		return true
+	case parent(1) == "security" && parent(2) != "properties":
+		return true
+	}
+	return false
+}
+
+// isResponse reports whether the object at ptr is a response, whose
+// description is required.
+func isResponse(ptr string) bool {
+	segments := strings.Split(ptr, "/")
+	n := len(segments)
+	return n >= 3 && segments[n-2] == "responses" && segments[n-3] != "properties"
+}
+
+// firstSentence returns the first sentence of a description: up to the
+// first period followed by a space or line break, or the first paragraph,
+// on one line.
+func firstSentence(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "\n\n"); i >= 0 {
+		s = s[:i]
+	}
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] == '.' && (s[i+1] == ' ' || s[i+1] == '\n') {
+			s = s[:i+1]
+			break
+		}
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// size returns the size of a file, such as 28.4 MB.
+func size(filename string) string {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return "?"
+	}
+	n := float64(info.Size())
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", n/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", n/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", info.Size())
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Pets", "version": "1", "description": "The pet store.\n\nIt sells pets."},
+  "externalDocs": {"url": "https://example.com/docs"},
+  "paths": {
+    "/pets": {
+      "get": {
+        "summary": "List pets",
+        "description": "Lists the pets. Paginated.",
+        "parameters": [{"name": "limit", "in": "query", "description": "Page size.", "example": 10, "schema": {"type": "integer", "default": 20}}],
+        "responses": {
+          "200": {"description": "The pets.", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}, "examples": {"rex": {"$ref": "#/components/examples/Rex"}}}}}
+        },
+        "security": [{"oauth": ["read"]}]
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Pet": {
+        "type": "object",
+        "description": "A pet, with\na name.",
+        "example": {"name": "Rex", "description": "A dog."},
+        "properties": {
+          "name": {"type": "string", "description": "Name.", "enum": ["description"]},
+          "description": {"type": "string", "description": "What the owner says."},
+          "mapping": {"type": "string", "description": "Not a mapping."}
+        },
+        "discriminator": {"propertyName": "name", "mapping": {"description": "#/components/schemas/Pet"}},
+        "x-docs": {"description": "Extension."}
+      }
+    },
+    "examples": {"Rex": {"summary": "Rex", "value": {"name": "Rex"}}},
+    "securitySchemes": {
+      "oauth": {"type": "oauth2", "description": "OAuth.", "flows": {"implicit": {"authorizationUrl": "https://example.com/auth", "scopes": {"read": "Read pets.", "description": "A scope."}}}}
+    }
+  }
+}`
+
+func TestSlim(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		want        []string
+		wantRemoved map[string]int
+		wantShort   int
+	}{
+		{
+			name: "examples and descriptions",
+			opts: Options{Remove: []string{Examples, Descriptions}},
+			want: []string{
+				`"info":{"title":"Pets","version":"1"}`,
+				`"externalDocs":{"url":"https://example.com/docs"}`,
+				`"get":{"summary":"List pets","parameters":[{"name":"limit","in":"query","schema":{"type":"integer","default":20}}]`,
+				// Responses require a description.
+				`"200":{"description":"","content":{"application/json":{"schema":{"$ref":"#/components/schemas/Pet"}}}}`,
+				`"properties":{"name":{"type":"string","enum":["description"]},"description":{"type":"string"},"mapping":{"type":"string"}}`,
+				`"mapping":{"description":"#/components/schemas/Pet"}`,
+				`"x-docs":{"description":"Extension."}`,
+				`"components":{"schemas":{"Pet":{"type":"object","properties"`,
+				`"oauth":{"type":"oauth2","flows":{"implicit":{"authorizationUrl":"https://example.com/auth","scopes":{"read":"Read pets.","description":"A scope."}}}}`,
+			},
+			wantRemoved: map[string]int{Examples: 4, Descriptions: 9},
+		},
+		{
+			name: "first sentence",
+			opts: Options{Remove: []string{Descriptions, Summaries, ExternalDocs}, FirstSentence: true},
+			want: []string{
+				`"info":{"title":"Pets","version":"1","description":"The pet store."},"paths"`,
+				`"get":{"description":"Lists the pets.","parameters":[{"name":"limit","in":"query","description":"Page size.","example":10,`,
+				`"description":"A pet, with a name.","example":{"name":"Rex","description":"A dog."}`,
+				// Examples are data, left to -remove examples.
+				`"examples":{"Rex":{"summary":"Rex","value":{"name":"Rex"}}}`,
+			},
+			wantRemoved: map[string]int{Summaries: 1, ExternalDocs: 1},
+			wantShort:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := specdoc.Parse([]byte(testSpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := Slim(spec, tt.opts)
+			if err != nil {
+				t.Fatalf("Slim: %v", err)
+			}
+
+			got, err := json.Marshal(spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("output missing %s:\n%s", want, got)
+				}
+			}
+			if !reflect.DeepEqual(report.Removed, tt.wantRemoved) {
+				t.Errorf("Removed = %v, want %v", report.Removed, tt.wantRemoved)
+			}
+			if report.Shortened != tt.wantShort {
+				t.Errorf("Shortened = %d, want %d", report.Shortened, tt.wantShort)
+			}
+		})
+	}
+}
+
+func TestFirstSentence(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"A pet.", "A pet."},
+		{"A pet. With a name.", "A pet."},
+		{"A pet.\nWith a name.", "A pet."},
+		{"Version 1.2 of the pet", "Version 1.2 of the pet"},
+		{"A pet\nwith a name\n\nMore.", "A pet with a name"},
+		{"  Trimmed.  ", "Trimmed."},
+	}
+	for _, tt := range tests {
+		if got := firstSentence(tt.in); got != tt.want {
+			t.Errorf("firstSentence(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for args, wantErr := range map[string]string{
+		"-remove titles":                   `-remove: unknown kind "titles", want one of examples, descriptions, summaries, externalDocs`,
+		"-first-sentence -remove examples": "-first-sentence: -remove has no descriptions",
+	} {
+		err := run(append(append([]string{"-o", output}, strings.Fields(args)...), input))
+		if err == nil || err.Error() != wantErr {
+			t.Errorf("run(%s) error = %v, want %q", args, err, wantErr)
+		}
+	}
+
+	if err := run([]string{"-o", output, input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(got), `"example"`) {
+		t.Errorf("output has examples:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
@@ -0,0 +1,302 @@
+// Command ogen-specsplit splits an OpenAPI spec into one spec per tag, for
+// generating a Go package per API area.
+//
+// ogen generates one package for a spec. For large APIs that is a package of
+// 100k lines or more, slow to compile and hard to navigate. This tool writes
+// a spec per tag with the operations of the tag and only the components they
+// need, and a manifest of the packages to generate from them:
+//
+//	{
+//	  "packages": [
+//	    {"tag": "pets", "package": "pets", "spec": "pets.json", "target": "internal/api/pets", "operations": 4},
+//	    {"tag": "store", "package": "store", "spec": "store.json", "target": "internal/api/store", "operations": 3}
+//	  ]
+//	}
+//
+// An operation belongs to its first tag, so it is generated once; operations
+// without tags go to the -untagged package. A component used by several
+// packages is copied into each, and becomes a type of each package.
+//
+// Usage:
+//
+//	ogen-specsplit -o specs [-target internal/api] [-untagged other] openapi.json
+//	jq -r '.packages[] | "\(.package) \(.target) \(.spec)"' specs/manifest.json |
+//	while read pkg target spec; do
+//	    ogen --package "$pkg" --target "$target" --clean "specs/$spec"
+//	done
+//
+// The rest of each spec is copied unchanged, with its keys in their original
+// order.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// manifestFile is the name of the manifest in the output directory.
+const manifestFile = "manifest.json"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specsplit: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specsplit", flag.ContinueOnError)
+	outputDir := fs.String("o", "", "directory to write the specs and the manifest to")
+	target := fs.String("target", "internal/api", "directory of the generated packages, for the manifest")
+	untagged := fs.String("untagged", "other", "tag of the operations without tags")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputDir == "" {
+		return fmt.Errorf("usage: ogen-specsplit -o <dir> [-target internal/api] [-untagged other] <openapi.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	parts, err := Split(spec, *untagged)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	if err := os.MkdirAll(*outputDir, 0750); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+	manifest := Manifest{Packages: []Package{}}
+	for _, p := range parts {
+		pkg := Package{
+			Tag:        p.Tag,
+			Package:    p.Package,
+			Spec:       p.Package + ".json",
+			Target:     path.Join(*target, p.Package),
+			Operations: p.Operations,
+		}
+		if err := specdoc.WriteFile(filepath.Join(*outputDir, pkg.Spec), p.Spec); err != nil {
+			return err
+		}
+		manifest.Packages = append(manifest.Packages, pkg)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(*outputDir, manifestFile), append(data, '\n'), 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write manifest: %w", err)
+	}
+
+	fmt.Printf("Split %s into %d specs in %s\n", filename, len(parts), *outputDir)
+	return nil
+}
+
+// Manifest lists the packages to generate from the split specs.
+type Manifest struct {
+	Packages []Package `json:"packages"`
+}
+
+// Package is a package to generate, with paths relative to the manifest for
+// the spec and to the working directory of ogen for the target.
+type Package struct {
+	Tag        string `json:"tag"`
+	Package    string `json:"package"`
+	Spec       string `json:"spec"`
+	Target     string `json:"target"`
+	Operations int    `json:"operations"`
+}
+
+// Part is the spec of the operations of a tag.
+type Part struct {
+	Tag string
+	// Package is the Go package name derived from the tag.
+	Package    string
+	Spec       *specdoc.Object
+	Operations int
+}
+
+// Split returns a spec for each tag of the operations of spec, in the order
+// the tags first occur. Operations without tags get the tag untagged. Each
+// spec has the operations whose first tag is its tag, the components they
+// reference directly or indirectly, the security schemes of their
+// requirements, and the other keys of spec. It returns an error if spec has
+// no operations.
+func Split(spec *specdoc.Object, untagged string) ([]*Part, error) {
+	var parts []*Part
+	byTag := make(map[string]*Part)
+	packages := make(map[string]bool)
+	for _, key := range specdoc.ItemMaps {
+		items, _ := spec.Get(key).(*specdoc.Object)
+		for _, name := range items.Keys() {
+			item, ok := items.Get(name).(*specdoc.Object)
+			if !ok {
+				continue
+			}
+			for _, method := range item.Keys() {
+				op, ok := item.Get(method).(*specdoc.Object)
+				if !ok || !specdoc.IsMethod(method) {
+					continue
+				}
+				tag := untagged
+				if tags, _ := op.Get("tags").([]any); len(tags) > 0 {
+					if s, ok := tags[0].(string); ok && s != "" {
+						tag = s
+					}
+				}
+				p, ok := byTag[tag]
+				if !ok {
+					p = &Part{Tag: tag, Package: uniquePackage(packageName(tag), packages), Spec: skeleton(spec)}
+					byTag[tag] = p
+					parts = append(parts, p)
+				}
+				addOperation(p.Spec, key, name, item, method)
+				p.Operations++
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no operations")
+	}
+
+	for _, p := range parts {
+		keepTag(p.Spec, spec, p.Tag)
+		keepComponents(p.Spec, spec)
+	}
+	return parts, nil
+}
+
+// skeleton returns a copy of spec without its operations, components and
+// tags, with the keys of spec in their order.
+func skeleton(spec *specdoc.Object) *specdoc.Object {
+	s := specdoc.NewObject()
+	for _, key := range spec.Keys() {
+		switch key {
+		case "paths", "webhooks", "components", "tags":
+			s.Set(key, specdoc.NewObject())
+		default:
+			s.Set(key, specdoc.Clone(spec.Get(key)))
+		}
+	}
+	return s
+}
+
+// addOperation copies the operation method of the path item name of the map
+// key of a spec to s, with the keys of the path item that are not
+// operations, such as its parameters.
+func addOperation(s *specdoc.Object, key, name string, item *specdoc.Object, method string) {
+	items, ok := s.Get(key).(*specdoc.Object)
+	if !ok {
+		items = specdoc.NewObject()
+		s.Set(key, items)
+	}
+	copied, ok := items.Get(name).(*specdoc.Object)
+	if !ok {
+		copied = specdoc.NewObject()
+		for _, k := range item.Keys() {
+			if !specdoc.IsMethod(k) {
+				copied.Set(k, specdoc.Clone(item.Get(k)))
+			}
+		}
+		items.Set(name, copied)
+	}
+	copied.Set(method, specdoc.Clone(item.Get(method)))
+}
+
+// keepTag sets the tags of s to the entry of tag in the tags of spec, if
+// any.
+func keepTag(s, spec *specdoc.Object, tag string) {
+	if !s.Has("tags") {
+		return
+	}
+	list, _ := spec.Get("tags").([]any)
+	kept := []any{}
+	for _, v := range list {
+		if o, ok := v.(*specdoc.Object); ok && o.Get("name") == tag {
+			kept = append(kept, specdoc.Clone(o))
+		}
+	}
+	if len(kept) == 0 {
+		s.Delete("tags")
+		return
+	}
+	s.Set("tags", kept)
+}
+
+// keepComponents sets the components of s to the components of spec that
+// the rest of s references, directly or through other components, and the
+// security schemes its requirements name. Empty component maps are left
+// out.
+func keepComponents(s, spec *specdoc.Object) {
+	if !s.Has("components") {
+		return
+	}
+	components, _ := spec.Get("components").(*specdoc.Object)
+
+	used := specdoc.Reachable(spec, append(specdoc.Refs(s), specdoc.SecuritySchemes(s)...))
+
+	kept := specdoc.NewObject()
+	for _, kind := range components.Keys() {
+		all, ok := components.Get(kind).(*specdoc.Object)
+		if !ok || strings.HasPrefix(kind, "x-") {
+			kept.Set(kind, specdoc.Clone(components.Get(kind)))
+			continue
+		}
+		byName := specdoc.NewObject()
+		for _, name := range all.Keys() {
+			if used[specdoc.Pointer(specdoc.Pointer("/components", kind), name)] {
+				byName.Set(name, specdoc.Clone(all.Get(name)))
+			}
+		}
+		if byName.Len() > 0 {
+			kept.Set(kind, byName)
+		}
+	}
+	s.Set("components", kept)
+}
+
+// packageName returns the Go package name of a tag: its letters and digits
+// in lower case, such as petstore for "Pet Store", prefixed with api if it
+// would not be an identifier and suffixed with api if it is a keyword.
+func packageName(tag string) string {
+	var b strings.Builder
+	for _, r := range tag {
+		if (unicode.IsLetter(r) || unicode.IsDigit(r)) && r < unicode.MaxASCII {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	name := b.String()
+	switch {
+	case name == "" || unicode.IsDigit(rune(name[0])):
+		name = "api" + name
+	case token.IsKeyword(name):
+		name += "api"
+	}
+	return name
+}
+
+// uniquePackage returns name, or name with the first free numeric suffix if
+// another tag has the package name, and marks it taken.
+func uniquePackage(name string, taken map[string]bool) string {
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	taken[unique] = true
+	return unique
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Petstore", "version": "1"},
+  "tags": [{"name": "pets", "description": "Pets"}, {"name": "Store Orders"}],
+  "security": [{"apiKey": []}],
+  "paths": {
+    "/pets": {
+      "get": {
+        "operationId": "listPets",
+        "tags": ["pets"],
+        "parameters": [{"$ref": "#/components/parameters/limit"}],
+        "responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}}}}}}
+      },
+      "post": {
+        "operationId": "createPet",
+        "tags": ["pets", "Store Orders"],
+        "responses": {"201": {"$ref": "#/components/responses/Created"}}
+      }
+    },
+    "/orders/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "get": {
+        "operationId": "getOrder",
+        "tags": ["Store Orders"],
+        "security": [{"oauth": ["read"]}],
+        "responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Order"}}}}}
+      }
+    },
+    "/health": {
+      "get": {"operationId": "health", "responses": {"204": {"description": "ok"}}}
+    }
+  },
+  "components": {
+    "parameters": {
+      "limit": {"name": "limit", "in": "query", "schema": {"type": "integer"}}
+    },
+    "responses": {
+      "Created": {"description": "created", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet/properties/owner"}}}}
+    },
+    "schemas": {
+      "Pet": {
+        "type": "object",
+        "properties": {
+          "owner": {"$ref": "#/components/schemas/Owner"},
+          "shape": {
+            "oneOf": [{"$ref": "#/components/schemas/Circle"}],
+            "discriminator": {"propertyName": "kind", "mapping": {"circle": "Circle", "square": "#/components/schemas/Square"}}
+          }
+        }
+      },
+      "Owner": {"type": "object", "properties": {"name": {"type": "string"}}},
+      "Circle": {"type": "object", "properties": {"kind": {"type": "string"}}},
+      "Square": {"type": "object", "properties": {"kind": {"type": "string"}}},
+      "Order": {"type": "object", "properties": {"id": {"type": "string"}}},
+      "Unused": {"type": "string"}
+    },
+    "securitySchemes": {
+      "apiKey": {"type": "apiKey", "in": "header", "name": "X-Key"},
+      "oauth": {"type": "oauth2", "flows": {}}
+    }
+  }
+}`
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// summary returns the paths, the component names by kind and the tags of a
+// split spec.
+func summary(t *testing.T, spec *specdoc.Object) string {
+	t.Helper()
+	var b strings.Builder
+	paths, _ := spec.Get("paths").(*specdoc.Object)
+	for _, path := range paths.Keys() {
+		item, _ := paths.Get(path).(*specdoc.Object)
+		b.WriteString(path + " " + strings.Join(item.Keys(), ",") + "; ")
+	}
+	components, _ := spec.Get("components").(*specdoc.Object)
+	for _, kind := range components.Keys() {
+		byName, _ := components.Get(kind).(*specdoc.Object)
+		b.WriteString(kind + " " + strings.Join(byName.Keys(), ",") + "; ")
+	}
+	tags, err := json.Marshal(spec.Get("tags"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.WriteString("tags " + string(tags))
+	return b.String()
+}
+
+func TestSplit(t *testing.T) {
+	spec := parse(t, testSpec)
+	parts, err := Split(spec, "other")
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+
+	var got []string
+	for _, p := range parts {
+		got = append(got, p.Tag+" ("+p.Package+"): "+summary(t, p.Spec))
+	}
+	want := []string{
+		// createPet is in pets only, its first tag. Created references a
+		// property of Pet, which needs the whole schema.
+		`pets (pets): /pets get,post; parameters limit; responses Created; schemas Pet,Owner,Circle,Square; securitySchemes apiKey; tags [{"name":"pets","description":"Pets"}]`,
+		`Store Orders (storeorders): /orders/{id} parameters,get; schemas Order; securitySchemes apiKey,oauth; tags [{"name":"Store Orders"}]`,
+		`other (other): /health get; securitySchemes apiKey; tags null`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parts:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if parts[0].Operations != 2 || parts[1].Operations != 1 {
+		t.Errorf("Operations = %d, %d, want 2, 1", parts[0].Operations, parts[1].Operations)
+	}
+
+	// The other keys are copied, and spec is not modified.
+	if got := parts[2].Spec.Keys(); !reflect.DeepEqual(got, []string{"openapi", "info", "security", "paths", "components"}) {
+		t.Errorf("keys = %v", got)
+	}
+	if got := summary(t, spec); !strings.Contains(got, "schemas Pet,Owner,Circle,Square,Order,Unused") {
+		t.Errorf("spec was modified: %s", got)
+	}
+}
+
+func TestSplit_Errors(t *testing.T) {
+	_, err := Split(parse(t, `{"openapi": "3.0.3", "paths": {}}`), "other")
+	if err == nil || err.Error() != "no operations" {
+		t.Errorf("Split() error = %v, want no operations", err)
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	taken := make(map[string]bool)
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"pets", "pets"},
+		{"Store Orders", "storeorders"},
+		{"user-groups", "usergroups"},
+		{"2fa", "api2fa"},
+		{"Éclair", "clair"},
+		{"type", "typeapi"},
+		{"", "api"},
+		{"Pets", "pets2"},
+	}
+	for _, tt := range tests {
+		if got := uniquePackage(packageName(tt.tag), taken); got != tt.want {
+			t.Errorf("package of %q = %s, want %s", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "specs")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, "-target", "pkg/api", "-untagged", "misc", input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(output, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	want := []Package{
+		{Tag: "pets", Package: "pets", Spec: "pets.json", Target: "pkg/api/pets", Operations: 2},
+		{Tag: "Store Orders", Package: "storeorders", Spec: "storeorders.json", Target: "pkg/api/storeorders", Operations: 1},
+		{Tag: "misc", Package: "misc", Spec: "misc.json", Target: "pkg/api/misc", Operations: 1},
+	}
+	if !reflect.DeepEqual(manifest.Packages, want) {
+		t.Errorf("manifest = %+v, want %+v", manifest.Packages, want)
+	}
+
+	got, err := os.ReadFile(filepath.Join(output, "pets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(got); !strings.Contains(out, `"/pets"`) || strings.Contains(out, `"/orders/{id}"`) || strings.Contains(out, `"/health"`) {
+		t.Errorf("pets.json does not hold just the pets operations:\n%s", got)
+	}
+}
@@ -0,0 +1,454 @@
+// Command ogen-specstrip removes or rewrites the constructs of an OpenAPI
+// spec that ogen cannot generate.
+//
+// ogen fails on features it has not implemented, such as spaceDelimited
+// parameters, digest authentication or an XML-only request body, and with
+// ignore_not_implemented it skips the operations that use them. This tool
+// rewrites them to the closest construct ogen supports, or removes them, and
+// reports every change on stderr with its location:
+//
+//	{"type": "http", "scheme": "digest"}
+//	{"type": "apiKey", "in": "header", "name": "Authorization"}
+//
+//	{"in": "query", "name": "tags", "style": "spaceDelimited"}
+//	{"in": "query", "name": "tags", "style": "form", "explode": false}
+//
+//	"content": {"application/xml": {"schema": {"$ref": "#/components/schemas/Pet"}}}
+//	"content": {"application/xml": {"schema": {"type": "string", "format": "binary"}}}
+//
+// Each report names the ogen feature the change works around, as ogen's
+// "not implemented" errors do.
+//
+// Usage:
+//
+//	ogen-specstrip -o openapi.ogen.json openapi.json
+//	ogen --package api --target internal/api --clean openapi.ogen.json
+//
+// Content types aliased in ogen.yml, such as application/xml for
+// ogen-fixxml, are left alone with -keep. The rest of the spec is written
+// back unchanged, with its keys in their original order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"mime"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specstrip: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specstrip", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the rewritten spec to")
+	keep := fs.String("keep", "", "comma-separated content types to leave alone, such as those aliased in ogen.yml")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specstrip -o <output.json> [-keep <content types>] <openapi.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var keepTypes []string
+	if *keep != "" {
+		keepTypes = strings.Split(*keep, ",")
+	}
+	report, err := Strip(spec, keepTypes)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, c := range report.Changes {
+		fmt.Fprintf(os.Stderr, "ogen-specstrip: #%s: %s: %s\n", c.Pointer, c.Feature, c.Message)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Changed %d unsupported constructs in %s\n", len(report.Changes), *outputFile)
+	return nil
+}
+
+// Report lists what Strip changed.
+type Report struct {
+	Changes []Change
+}
+
+// Change is a construct removed or rewritten at a JSON pointer of the spec.
+type Change struct {
+	Pointer string
+	// Feature is the name of the unsupported feature in ogen's errors, such
+	// as "spaceDelimited parameter style".
+	Feature string
+	Message string
+}
+
+// enumTimeFormats are the string formats ogen rejects on enums.
+var enumTimeFormats = map[string]bool{
+	"date":      true,
+	"time":      true,
+	"date-time": true,
+	"http-date": true,
+}
+
+type stripper struct {
+	spec   *specdoc.Object
+	keep   map[string]bool
+	report *Report
+	// mutualTLS holds the names of the removed mutualTLS security schemes.
+	mutualTLS map[string]bool
+}
+
+// Strip removes or rewrites the constructs of spec ogen cannot generate.
+// Media types in keep are treated as supported.
+func Strip(spec *specdoc.Object, keep []string) (*Report, error) {
+	version, _ := spec.Get("openapi").(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("openapi version %q is not 3.x", version)
+	}
+
+	s := &stripper{
+		spec:      spec,
+		keep:      make(map[string]bool),
+		report:    &Report{},
+		mutualTLS: make(map[string]bool),
+	}
+	for _, ct := range keep {
+		s.keep[strings.ToLower(strings.TrimSpace(ct))] = true
+	}
+
+	s.stripSecuritySchemes()
+	if err := specdoc.Walk(spec, s.stripObject); err != nil {
+		return nil, err
+	}
+	return s.report, nil
+}
+
+func (s *stripper) change(ptr, feature, format string, args ...any) {
+	s.report.Changes = append(s.report.Changes, Change{
+		Pointer: ptr,
+		Feature: feature,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// stripSecuritySchemes rewrites the security schemes ogen has no
+// implementation for. The other schemes keep their names, so requirements
+// don't change, except for the removed mutualTLS ones.
+func (s *stripper) stripSecuritySchemes() {
+	components, _ := s.spec.Get("components").(*specdoc.Object)
+	schemes, _ := components.Get("securitySchemes").(*specdoc.Object)
+	for _, name := range schemes.Keys() {
+		scheme, ok := schemes.Get(name).(*specdoc.Object)
+		if !ok || scheme.Get("x-ogen-custom-security") == true {
+			continue
+		}
+		ptr := specdoc.Pointer("/components/securitySchemes", name)
+		switch scheme.Get("type") {
+		case "http":
+			httpScheme, _ := scheme.Get("scheme").(string)
+			switch strings.ToLower(httpScheme) {
+			case "basic", "bearer":
+				continue
+			}
+			// The client sets the whole header value, such as a digest
+			// response, and the server gets it as the API key.
+			scheme.Set("type", "apiKey")
+			scheme.Replace("scheme", "in", "header")
+			scheme.Delete("bearerFormat")
+			scheme.Set("name", "Authorization")
+			s.change(ptr, "http security scheme", "rewrote %s scheme to an apiKey in the Authorization header", httpScheme)
+		case "openIdConnect":
+			// OpenID Connect access tokens are sent as bearer tokens.
+			scheme.Set("type", "http")
+			scheme.Replace("openIdConnectUrl", "scheme", "bearer")
+			s.change(ptr, "openIdConnect security", "rewrote to http bearer")
+		case "mutualTLS":
+			schemes.Delete(name)
+			s.mutualTLS[name] = true
+			s.change(ptr, "mutualTLS security", "removed: configure client certificates on the http.Client and the tls.Config of the server")
+		}
+	}
+}
+
+// stripObject removes or rewrites the unsupported constructs of an object of
+// the spec.
+func (s *stripper) stripObject(o *specdoc.Object, ptr string) error {
+	s.removeMutualTLS(o, ptr)
+	if _, ok := o.Get("in").(string); ok && !specdoc.IsSchemaPointer(ptr, false) {
+		s.stripParameter(o, ptr)
+	}
+	if body, request := bodyPointer(ptr); body {
+		s.stripContent(o, ptr, request)
+	}
+	if _, ok := o.Get("x-ogen-server-name").(string); ok {
+		s.stripServer(o, ptr)
+	}
+	if specdoc.IsSchemaPointer(ptr, false) {
+		s.stripSchema(o, ptr)
+	}
+	return nil
+}
+
+// removeMutualTLS removes the removed mutualTLS schemes from the security
+// requirements of an operation or the document. A requirement of mutualTLS
+// alone becomes empty: the connection, not the request, carries it.
+func (s *stripper) removeMutualTLS(o *specdoc.Object, ptr string) {
+	requirements, ok := o.Get("security").([]any)
+	if !ok || len(s.mutualTLS) == 0 {
+		return
+	}
+	for i, r := range requirements {
+		requirement, _ := r.(*specdoc.Object)
+		for _, name := range requirement.Keys() {
+			if s.mutualTLS[name] {
+				requirement.Delete(name)
+				s.change(specdoc.Pointer(ptr, "security")+"/"+strconv.Itoa(i), "mutualTLS security", "removed requirement %s", name)
+			}
+		}
+	}
+}
+
+// stripParameter rewrites the styles and content of a parameter ogen cannot
+// encode.
+func (s *stripper) stripParameter(o *specdoc.Object, ptr string) {
+	switch style, _ := o.Get("style").(string); style {
+	case "spaceDelimited":
+		o.Set("style", "form")
+		o.Set("explode", false)
+		s.change(ptr, "spaceDelimited parameter style", "rewrote to form style without explode: values are separated by commas")
+	case "pipeDelimited":
+		if s.schemaType(o.Get("schema")) == "object" {
+			o.Set("style", "form")
+			o.Set("explode", false)
+			s.change(ptr, "pipeDelimited style for object parameters", "rewrote to form style without explode: values are separated by commas")
+		}
+	}
+
+	content, ok := o.Get("content").(*specdoc.Object)
+	if !ok || content.Len() != 1 {
+		return
+	}
+	if mediaType := content.Keys()[0]; mediaType != "application/json" {
+		o.Replace("content", "schema", typedSchema("string", ""))
+		s.change(ptr, "parameter content encoding", "replaced %s content by a string schema: the value is passed encoded", mediaType)
+	}
+}
+
+// stripContent makes every media type of a request body or response one ogen
+// generates: an unsupported media type is removed if the body has other ones,
+// and is otherwise read and written as a stream.
+func (s *stripper) stripContent(o *specdoc.Object, ptr string, request bool) {
+	content, ok := o.Get("content").(*specdoc.Object)
+	if !ok {
+		return
+	}
+	contentPtr := specdoc.Pointer(ptr, "content")
+
+	var unsupported []string
+	for _, mediaType := range content.Keys() {
+		media, _ := content.Get(mediaType).(*specdoc.Object)
+		switch {
+		case s.supported(mediaType):
+			if request && media != nil && !media.Has("schema") && isJSON(mediaType) {
+				media.Set("schema", specdoc.NewObject())
+				s.change(specdoc.Pointer(contentPtr, mediaType), "empty schema in request body", "added an empty schema: the body is any JSON value")
+			}
+		case !s.isStream(media.Get("schema")):
+			unsupported = append(unsupported, mediaType)
+		}
+	}
+	if len(unsupported) == 0 {
+		return
+	}
+
+	if len(unsupported) < content.Len() {
+		for _, mediaType := range unsupported {
+			content.Delete(mediaType)
+			s.change(specdoc.Pointer(contentPtr, mediaType), "unsupported content types", "removed: the other content types are generated")
+		}
+		return
+	}
+	for _, mediaType := range unsupported {
+		media, ok := content.Get(mediaType).(*specdoc.Object)
+		if !ok {
+			continue
+		}
+		media.Set("schema", typedSchema("string", "binary"))
+		s.change(specdoc.Pointer(contentPtr, mediaType), "unsupported content types", "rewrote schema to a binary string: the body is an io.Reader")
+	}
+}
+
+// supported reports whether ogen generates typed bodies of mediaType.
+func (s *stripper) supported(mediaType string) bool {
+	mt, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return false
+	}
+	if s.keep[mt] {
+		return true
+	}
+	switch mt {
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		return true
+	}
+	return isJSON(mt)
+}
+
+// isJSON reports whether ogen encodes mediaType as JSON.
+func isJSON(mediaType string) bool {
+	mt, _, _ := mime.ParseMediaType(mediaType)
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// isStream reports whether ogen generates the body of schema v as a stream,
+// as it does for missing schemas and strings of binary formats.
+func (s *stripper) isStream(v any) bool {
+	if v == nil {
+		return true
+	}
+	o := s.resolve(v)
+	if o == nil {
+		return false
+	}
+	if t := s.schemaType(o); t != "" && t != "string" {
+		return false
+	}
+	switch format, _ := o.Get("format").(string); format {
+	case "", "binary", "byte", "base64":
+		return true
+	}
+	return false
+}
+
+// stripServer sets the empty defaults of the variables of a server ogen
+// generates a constructor for to the first value of their enum. Without one,
+// the server is left to the documentation.
+func (s *stripper) stripServer(o *specdoc.Object, ptr string) {
+	variables, _ := o.Get("variables").(*specdoc.Object)
+	for _, name := range variables.Keys() {
+		variable, _ := variables.Get(name).(*specdoc.Object)
+		if def, _ := variable.Get("default").(string); def != "" {
+			continue
+		}
+		enum, _ := variable.Get("enum").([]any)
+		i := slices.IndexFunc(enum, func(v any) bool {
+			str, ok := v.(string)
+			return ok && str != ""
+		})
+		if i < 0 {
+			o.Delete("x-ogen-server-name")
+			s.change(ptr, "empty server variable default", "removed x-ogen-server-name: variable %s has no value to default to", name)
+			return
+		}
+		variable.Set("default", enum[i])
+		s.change(specdoc.Pointer(specdoc.Pointer(ptr, "variables"), name), "empty server variable default", "set default to %s", enum[i])
+	}
+}
+
+// stripSchema removes the defaults and keywords of a schema ogen cannot
+// generate.
+func (s *stripper) stripSchema(o *specdoc.Object, ptr string) {
+	typ := s.schemaType(o)
+
+	if o.Has("default") {
+		feature := ""
+		switch {
+		case typ == "object":
+			feature = "object defaults"
+		case typ == "array":
+			feature = "array defaults"
+		case o.Has("anyOf") || o.Has("oneOf") || typ == "" && !o.Has("$ref") && !o.Has("allOf"):
+			feature = "complex defaults"
+		}
+		if feature != "" {
+			o.Delete("default")
+			s.change(ptr, feature, "removed default")
+		}
+	}
+
+	if format, _ := o.Get("format").(string); o.Has("enum") && typ == "string" && enumTimeFormats[format] {
+		o.Delete("format")
+		s.change(ptr, "enum format", "removed format %s: the enum is a string enum", format)
+	}
+
+	if o.Get("uniqueItems") == true && typ == "array" && s.schemaType(o.Get("items")) == "" {
+		o.Delete("uniqueItems")
+		s.change(ptr, "empty uniqueItems", "removed uniqueItems: the items have no type")
+	}
+}
+
+// schemaType returns the type of schema v, following local $refs, or "" if
+// it has none.
+func (s *stripper) schemaType(v any) string {
+	o := s.resolve(v)
+	if t, ok := o.Get("type").(string); ok {
+		return t
+	}
+	return ""
+}
+
+// resolve follows the local $refs of v, returning nil for a value that is not
+// an object or an unresolved $ref.
+func (s *stripper) resolve(v any) *specdoc.Object {
+	for range 32 {
+		o, ok := v.(*specdoc.Object)
+		if !ok {
+			return nil
+		}
+		ref, ok := o.Get("$ref").(string)
+		if !ok {
+			return o
+		}
+		ptr, ok := strings.CutPrefix(ref, "#")
+		if !ok {
+			return nil
+		}
+		if v, ok = specdoc.Lookup(s.spec, ptr); !ok {
+			return nil
+		}
+	}
+	return nil
+}
+
+// bodyPointer reports whether the object at ptr is a request body or a
+// response, and which of them.
+func bodyPointer(ptr string) (body, request bool) {
+	parts := strings.Split(ptr, "/")
+	if len(parts) < 2 {
+		return false, false
+	}
+	switch {
+	case parts[len(parts)-1] == "requestBody", parts[len(parts)-2] == "requestBodies":
+		return true, true
+	case parts[len(parts)-2] == "responses":
+		return true, false
+	}
+	return false, false
+}
+
+func typedSchema(typ, format string) *specdoc.Object {
+	o := specdoc.NewObject()
+	o.Set("type", typ)
+	if format != "" {
+		o.Set("format", format)
+	}
+	return o
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "openapi": "3.1.0",
+  "servers": [
+    {"url": "https://{region}.example.com", "x-ogen-server-name": "regional", "variables": {"region": {"default": "", "enum": ["", "eu"]}}},
+    {"url": "https://{host}", "x-ogen-server-name": "custom", "variables": {"host": {"default": ""}}}
+  ],
+  "security": [{"tls": [], "oidc": []}, {"tls": []}],
+  "paths": {
+    "/pets": {
+      "get": {
+        "security": [{"digest": []}, {"token": []}],
+        "parameters": [
+          {"name": "tags", "in": "query", "style": "spaceDelimited", "schema": {"type": "array", "items": {"type": "string"}}},
+          {"name": "filter", "in": "query", "style": "pipeDelimited", "schema": {"$ref": "#/components/schemas/Filter"}},
+          {"name": "ids", "in": "query", "style": "pipeDelimited", "schema": {"type": "array", "items": {"type": "integer"}}},
+          {"name": "where", "in": "query", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          {"name": "q", "in": "query", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Filter"}}}},
+          {"name": "day", "in": "query", "schema": {"type": "string", "format": "date", "enum": ["2024-01-01"]}}
+        ],
+        "responses": {
+          "200": {"description": "OK", "content": {
+            "application/json": {"schema": {"$ref": "#/components/schemas/Pet"}},
+            "application/xml": {"schema": {"$ref": "#/components/schemas/Pet"}}
+          }}
+        }
+      },
+      "post": {
+        "requestBody": {"content": {"application/xml": {"schema": {"$ref": "#/components/schemas/Pet"}}}},
+        "responses": {
+          "201": {"description": "Created", "content": {"text/csv": {"schema": {"type": "array"}}}},
+          "default": {"description": "Error", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      },
+      "put": {
+        "requestBody": {"content": {"application/merge-patch+json": {}, "image/png": {"schema": {"type": "string", "format": "binary"}}}},
+        "responses": {"204": {"description": "No content", "content": {"application/json": {}}}}
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "digest": {"type": "http", "scheme": "Digest"},
+      "token": {"type": "http", "scheme": "Bearer", "bearerFormat": "JWT"},
+      "oidc": {"type": "openIdConnect", "openIdConnectUrl": "https://example.com/.well-known/openid-configuration"},
+      "tls": {"type": "mutualTLS"}
+    },
+    "schemas": {
+      "Filter": {"type": "object", "properties": {"kind": {"type": "string"}}},
+      "Pet": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string", "default": "rex"},
+          "meta": {"type": "object", "default": {}},
+          "tags": {"type": "array", "items": {"type": "string"}, "default": [], "uniqueItems": true},
+          "any": {"default": 1},
+          "choice": {"oneOf": [{"type": "string"}, {"type": "integer"}], "default": "a"},
+          "set": {"type": "array", "uniqueItems": true, "items": {}},
+          "filters": {"type": "array", "uniqueItems": true, "items": {"$ref": "#/components/schemas/Filter"}}
+        }
+      }
+    }
+  }
+}`
+
+func parse(t *testing.T, doc string) *specdoc.Object {
+	t.Helper()
+	o, err := specdoc.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestStrip(t *testing.T) {
+	spec := parse(t, testSpec)
+	report, err := Strip(spec, nil)
+	if err != nil {
+		t.Fatalf("Strip: %v", err)
+	}
+
+	var got []string
+	for _, c := range report.Changes {
+		got = append(got, c.Pointer+": "+c.Feature)
+	}
+	want := []string{
+		"/components/securitySchemes/digest: http security scheme",
+		"/components/securitySchemes/oidc: openIdConnect security",
+		"/components/securitySchemes/tls: mutualTLS security",
+		"/security/0: mutualTLS security",
+		"/security/1: mutualTLS security",
+		"/servers/0/variables/region: empty server variable default",
+		"/servers/1: empty server variable default",
+		"/paths/~1pets/get/parameters/0: spaceDelimited parameter style",
+		"/paths/~1pets/get/parameters/1: pipeDelimited style for object parameters",
+		"/paths/~1pets/get/parameters/3: parameter content encoding",
+		"/paths/~1pets/get/parameters/5/schema: enum format",
+		"/paths/~1pets/get/responses/200/content/application~1xml: unsupported content types",
+		"/paths/~1pets/post/requestBody/content/application~1xml: unsupported content types",
+		"/paths/~1pets/post/responses/201/content/text~1csv: unsupported content types",
+		"/paths/~1pets/put/requestBody/content/application~1merge-patch+json: empty schema in request body",
+		"/components/schemas/Pet/properties/meta: object defaults",
+		"/components/schemas/Pet/properties/tags: array defaults",
+		"/components/schemas/Pet/properties/any: complex defaults",
+		"/components/schemas/Pet/properties/choice: complex defaults",
+		"/components/schemas/Pet/properties/set: empty uniqueItems",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	out, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"digest":{"type":"apiKey","in":"header","name":"Authorization"}`,
+		`"token":{"type":"http","scheme":"Bearer","bearerFormat":"JWT"}`,
+		`"oidc":{"type":"http","scheme":"bearer"}`,
+		// A requirement of mutualTLS alone allows any request.
+		`"security":[{"oidc":[]},{}]`,
+		`"variables":{"region":{"default":"eu","enum":["","eu"]}}`,
+		`{"url":"https://{host}","variables":{"host":{"default":""}}}`,
+		`{"name":"tags","in":"query","style":"form","schema":{"type":"array","items":{"type":"string"}},"explode":false}`,
+		// pipeDelimited arrays are supported.
+		`{"name":"ids","in":"query","style":"pipeDelimited",`,
+		`{"name":"where","in":"query","schema":{"type":"string"}}`,
+		`{"name":"q","in":"query","content":{"application/json":`,
+		`{"type":"string","enum":["2024-01-01"]}`,
+		`"200":{"description":"OK","content":{"application/json":{"schema":{"$ref":"#/components/schemas/Pet"}}}}`,
+		`"requestBody":{"content":{"application/xml":{"schema":{"type":"string","format":"binary"}}}}`,
+		`"text/csv":{"schema":{"type":"string","format":"binary"}}`,
+		// Strings are streams already.
+		`"text/plain":{"schema":{"type":"string"}}`,
+		`"application/merge-patch+json":{"schema":{}},"image/png"`,
+		// Responses without a schema are read as jx.Raw.
+		`"204":{"description":"No content","content":{"application/json":{}}}`,
+		`"name":{"type":"string","default":"rex"}`,
+		`"meta":{"type":"object"}`,
+		`"set":{"type":"array","items":{}}`,
+		`"filters":{"type":"array","uniqueItems":true,`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestStrip_Keep(t *testing.T) {
+	spec := parse(t, testSpec)
+	report, err := Strip(spec, []string{"application/xml"})
+	if err != nil {
+		t.Fatalf("Strip: %v", err)
+	}
+	for _, c := range report.Changes {
+		if strings.Contains(c.Pointer, "application~1xml") {
+			t.Errorf("kept content type changed: %s: %s", c.Pointer, c.Message)
+		}
+	}
+	out, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"requestBody":{"content":{"application/xml":{"schema":{"$ref":"#/components/schemas/Pet"}}}}`; !strings.Contains(string(out), want) {
+		t.Errorf("output missing %s:\n%s", want, out)
+	}
+}
+
+func TestStrip_Errors(t *testing.T) {
+	_, err := Strip(parse(t, `{"swagger": "2.0"}`), nil)
+	if want := `openapi version "" is not 3.x`; err == nil || err.Error() != want {
+		t.Errorf("Strip() error = %v, want %q", err, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "openapi.json")
+	output := filepath.Join(dir, "openapi.ogen.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, "-keep", "application/xml, text/csv", input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `"text/csv": {`+"\n"+`                "schema": {`+"\n"+`                  "type": "array"`) {
+		t.Errorf("kept text/csv body was changed:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
@@ -0,0 +1,759 @@
+// Command ogen-specswagger converts a Swagger 2.0 spec to OpenAPI 3.0.
+//
+// ogen only reads OpenAPI 3 specs, and many vendors still publish Swagger
+// 2.0. This tool converts them, so that the pipeline from the vendor spec to
+// the generated client runs on Go tools alone:
+//
+//	host, basePath, schemes          servers
+//	definitions                      components/schemas
+//	parameters, responses            components/parameters, components/responses
+//	securityDefinitions              components/securitySchemes
+//	in: body parameters              requestBody, with the consumes media types
+//	in: formData parameters          requestBody of an object schema
+//	response schema                  content, with the produces media types
+//	type, format, items... of a      schema, and collectionFormat as style and
+//	parameter or header              explode
+//	type: file                       type: string, format: binary
+//	x-nullable                       nullable
+//
+// $refs are rewritten to the components they moved to. Each conversion that
+// loses information, such as a tsv collectionFormat, is reported on stderr
+// with its location.
+//
+// Usage:
+//
+//	ogen-specswagger -o openapi.json swagger.json
+//	ogen --package api --target internal/api --clean openapi.json
+//
+// The rest of the spec is written back unchanged, with its keys in their
+// original order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ogen-specswagger: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("ogen-specswagger", flag.ContinueOnError)
+	outputFile := fs.String("o", "", "file to write the converted spec to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 || *outputFile == "" {
+		return fmt.Errorf("usage: ogen-specswagger -o <output.json> <swagger.json>")
+	}
+
+	filename := fs.Arg(0)
+	spec, err := specdoc.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	report, err := Convert(spec)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, l := range report.Lossy {
+		fmt.Fprintf(os.Stderr, "ogen-specswagger: #%s: %s\n", l.Pointer, l.Message)
+	}
+
+	if err := specdoc.WriteFile(*outputFile, spec); err != nil {
+		return err
+	}
+
+	fmt.Printf("Converted %d operations (%d lossy) to OpenAPI 3.0 in %s\n", report.Operations, len(report.Lossy), *outputFile)
+	return nil
+}
+
+// Version is the OpenAPI version of converted specs.
+const Version = "3.0.3"
+
+// Report lists what Convert did.
+type Report struct {
+	// Operations is the number of operations converted.
+	Operations int
+	// Lossy lists the conversions that lost information, at the JSON
+	// pointers of the Swagger spec.
+	Lossy []Note
+}
+
+// Note is a lossy conversion at a JSON pointer of the spec.
+type Note struct {
+	Pointer string
+	Message string
+}
+
+// schemaKeys are the keys of a non-body parameter, header or items object
+// that move to its schema.
+var schemaKeys = []string{
+	"type", "format", "items", "default", "maximum", "exclusiveMaximum",
+	"minimum", "exclusiveMinimum", "maxLength", "minLength", "pattern",
+	"maxItems", "minItems", "uniqueItems", "enum", "multipleOf",
+}
+
+// methods are the keys of Swagger path items that are operations.
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// formTypes are the media types of formData parameters.
+var formTypes = []string{"application/x-www-form-urlencoded", "multipart/form-data"}
+
+type converter struct {
+	spec   *specdoc.Object
+	report *Report
+	// consumes and produces are the global media types.
+	consumes []string
+	produces []string
+	// parameters holds the global parameters, unconverted.
+	parameters *specdoc.Object
+}
+
+// Convert rewrites spec, a Swagger 2.0 document, to OpenAPI 3.0.
+func Convert(spec *specdoc.Object) (*Report, error) {
+	if version, _ := spec.Get("swagger").(string); version != "2.0" {
+		return nil, fmt.Errorf("swagger version %q is not 2.0", version)
+	}
+	spec.Replace("swagger", "openapi", Version)
+
+	c := &converter{
+		spec:     spec,
+		report:   &Report{},
+		consumes: stringList(spec.Get("consumes")),
+		produces: stringList(spec.Get("produces")),
+	}
+	c.parameters, _ = spec.Get("parameters").(*specdoc.Object)
+
+	c.convertServers()
+	if paths, ok := spec.Get("paths").(*specdoc.Object); ok {
+		for _, path := range paths.Keys() {
+			if item, ok := paths.Get(path).(*specdoc.Object); ok {
+				c.convertPathItem(item, specdoc.Pointer("/paths", path))
+			}
+		}
+	}
+	c.convertComponents()
+	spec.Delete("consumes")
+	spec.Delete("produces")
+
+	rewriteRefs(spec)
+	return c.report, nil
+}
+
+// lossy records a conversion that lost information.
+func (c *converter) lossy(ptr, format string, args ...any) {
+	c.report.Lossy = append(c.report.Lossy, Note{Pointer: ptr, Message: fmt.Sprintf(format, args...)})
+}
+
+// convertServers replaces host, basePath and schemes by a server for each
+// scheme.
+func (c *converter) convertServers() {
+	host, _ := c.spec.Get("host").(string)
+	basePath, _ := c.spec.Get("basePath").(string)
+	schemes := stringList(c.spec.Get("schemes"))
+
+	first := ""
+	for _, key := range []string{"host", "basePath", "schemes"} {
+		if c.spec.Has(key) && first == "" {
+			first = key
+		} else {
+			c.spec.Delete(key)
+		}
+	}
+	if first == "" {
+		return
+	}
+
+	var servers []any
+	if host == "" {
+		// Relative to the location of the spec.
+		servers = append(servers, server(basePath))
+	} else {
+		if len(schemes) == 0 {
+			schemes = []string{"https"}
+		}
+		for _, scheme := range schemes {
+			servers = append(servers, server(scheme+"://"+host+basePath))
+		}
+	}
+	c.spec.Replace(first, "servers", servers)
+}
+
+func server(url string) *specdoc.Object {
+	if url == "" {
+		url = "/"
+	}
+	o := specdoc.NewObject()
+	o.Set("url", url)
+	return o
+}
+
+// convertComponents moves definitions, parameters, responses and
+// securityDefinitions to components. Body parameters become request bodies;
+// formData parameters are only used inlined into the operations.
+func (c *converter) convertComponents() {
+	components := specdoc.NewObject()
+	first := ""
+	for _, key := range []string{"definitions", "parameters", "responses", "securityDefinitions"} {
+		byName, ok := c.spec.Get(key).(*specdoc.Object)
+		if !ok {
+			c.spec.Delete(key)
+			continue
+		}
+		if first == "" {
+			first = key
+		} else {
+			c.spec.Delete(key)
+		}
+		ptr := "/" + key
+		switch key {
+		case "definitions":
+			for _, name := range byName.Keys() {
+				convertSchema(byName.Get(name))
+			}
+			components.Set("schemas", byName)
+		case "parameters":
+			parameters := specdoc.NewObject()
+			bodies := specdoc.NewObject()
+			for _, name := range byName.Keys() {
+				p, _ := byName.Get(name).(*specdoc.Object)
+				switch p.Get("in") {
+				case "body":
+					bodies.Set(name, c.requestBody(p, c.consumes))
+				case "formData":
+				default:
+					parameters.Set(name, c.convertParameter(p, specdoc.Pointer(ptr, name)))
+				}
+			}
+			if parameters.Len() > 0 {
+				components.Set("parameters", parameters)
+			}
+			if bodies.Len() > 0 {
+				components.Set("requestBodies", bodies)
+			}
+		case "responses":
+			for _, name := range byName.Keys() {
+				if r, ok := byName.Get(name).(*specdoc.Object); ok {
+					byName.Set(name, c.convertResponse(r, c.produces, specdoc.Pointer(ptr, name)))
+				}
+			}
+			components.Set("responses", byName)
+		case "securityDefinitions":
+			for _, name := range byName.Keys() {
+				if s, ok := byName.Get(name).(*specdoc.Object); ok {
+					byName.Set(name, c.convertSecurityScheme(s, specdoc.Pointer(ptr, name)))
+				}
+			}
+			components.Set("securitySchemes", byName)
+		}
+	}
+	if first != "" {
+		c.spec.Replace(first, "components", components)
+	}
+}
+
+// convertPathItem converts the operations of a path item. Body and formData
+// parameters of the path item are moved to its operations, as they become
+// part of their request bodies.
+func (c *converter) convertPathItem(item *specdoc.Object, ptr string) {
+	shared, _ := item.Get("parameters").([]any)
+	var kept []any
+	var moved []any
+	for _, v := range shared {
+		if in := c.resolveParameter(v).Get("in"); in == "body" || in == "formData" {
+			moved = append(moved, v)
+		} else {
+			kept = append(kept, c.convertParameterRef(v, ptr+"/parameters"))
+		}
+	}
+	if len(kept) > 0 {
+		item.Set("parameters", kept)
+	} else {
+		item.Delete("parameters")
+	}
+
+	for _, method := range item.Keys() {
+		op, ok := item.Get(method).(*specdoc.Object)
+		if !ok || !slices.Contains(methods, method) {
+			continue
+		}
+		c.report.Operations++
+		c.convertOperation(op, moved, specdoc.Pointer(ptr, method))
+	}
+}
+
+// convertOperation converts the parameters and responses of an operation,
+// with the body and formData parameters of its path item.
+func (c *converter) convertOperation(op *specdoc.Object, shared []any, ptr string) {
+	consumes, produces := c.consumes, c.produces
+	if op.Has("consumes") {
+		consumes = stringList(op.Get("consumes"))
+	}
+	if op.Has("produces") {
+		produces = stringList(op.Get("produces"))
+	}
+
+	params, _ := op.Get("parameters").([]any)
+	params = append(slices.Clone(shared), params...)
+	var converted []any
+	var body any
+	form := specdoc.NewObject()
+	var formRequired []any
+	hasFile := false
+	for i, v := range params {
+		p := c.resolveParameter(v)
+		pptr := fmt.Sprintf("%s/parameters/%d", ptr, i-len(shared))
+		switch p.Get("in") {
+		case "body":
+			if ref, ok := v.(*specdoc.Object).Get("$ref").(string); ok && slices.Equal(consumes, c.consumes) {
+				body = refObject(strings.Replace(ref, "#/parameters/", "#/components/requestBodies/", 1))
+			} else {
+				body = c.requestBody(p, consumes)
+			}
+		case "formData":
+			name, _ := p.Get("name").(string)
+			schema := c.parameterSchema(p, pptr)
+			if schema.Get("type") == "string" && schema.Get("format") == "binary" {
+				hasFile = true
+			}
+			if d, ok := p.Get("description").(string); ok {
+				schema.Set("description", d)
+			}
+			form.Set(name, schema)
+			if p.Get("required") == true {
+				formRequired = append(formRequired, name)
+			}
+		default:
+			converted = append(converted, c.convertParameterRef(v, pptr))
+		}
+	}
+	if form.Len() > 0 {
+		schema := specdoc.NewObject()
+		schema.Set("type", "object")
+		schema.Set("properties", form)
+		if len(formRequired) > 0 {
+			schema.Set("required", formRequired)
+		}
+		var types []string
+		for _, t := range consumes {
+			if slices.Contains(formTypes, t) {
+				types = append(types, t)
+			}
+		}
+		if len(types) == 0 {
+			types = []string{"application/x-www-form-urlencoded"}
+			if hasFile {
+				types = []string{"multipart/form-data"}
+			}
+		}
+		if body != nil {
+			c.lossy(ptr, "removed the formData parameters next to a body parameter")
+		} else {
+			rb := specdoc.NewObject()
+			rb.Set("content", content(types, schema))
+			if len(formRequired) > 0 {
+				rb.Set("required", true)
+			}
+			body = rb
+		}
+	}
+
+	// The body and formData parameters become the requestBody, which takes
+	// the place of parameters if no other parameters are left.
+	if len(converted) > 0 {
+		op.Set("parameters", converted)
+	} else if body != nil && op.Has("parameters") {
+		op.Replace("parameters", "requestBody", body)
+		body = nil
+	} else {
+		op.Delete("parameters")
+	}
+	if body != nil {
+		insertBefore(op, "responses", "requestBody", body)
+	}
+	op.Delete("consumes")
+	op.Delete("produces")
+	if op.Has("schemes") {
+		op.Delete("schemes")
+		c.lossy(ptr+"/schemes", "removed the schemes of the operation")
+	}
+
+	responses, _ := op.Get("responses").(*specdoc.Object)
+	for _, code := range responses.Keys() {
+		if r, ok := responses.Get(code).(*specdoc.Object); ok && !r.Has("$ref") {
+			responses.Set(code, c.convertResponse(r, produces, specdoc.Pointer(ptr+"/responses", code)))
+		}
+	}
+}
+
+// resolveParameter returns the parameter v, or the global parameter it
+// references.
+func (c *converter) resolveParameter(v any) *specdoc.Object {
+	p, _ := v.(*specdoc.Object)
+	if ref, ok := p.Get("$ref").(string); ok && strings.HasPrefix(ref, "#/parameters/") {
+		if global, ok := specdoc.Lookup(c.spec, ref[1:]); ok {
+			p, _ = global.(*specdoc.Object)
+		}
+	}
+	return p
+}
+
+// convertParameterRef converts a parameter that is not a $ref.
+func (c *converter) convertParameterRef(v any, ptr string) any {
+	p, ok := v.(*specdoc.Object)
+	if !ok || p.Has("$ref") {
+		return v
+	}
+	return c.convertParameter(p, ptr)
+}
+
+// convertParameter converts a parameter that is not a body or formData
+// parameter: its type keys move to a schema, and its collectionFormat to a
+// style.
+func (c *converter) convertParameter(p *specdoc.Object, ptr string) *specdoc.Object {
+	in, _ := p.Get("in").(string)
+	out := specdoc.NewObject()
+	schemaSet := false
+	for _, key := range p.Keys() {
+		switch {
+		case slices.Contains(schemaKeys, key) || key == "collectionFormat":
+			if !schemaSet {
+				out.Set("schema", c.parameterSchema(p, ptr))
+				c.setStyle(out, in, p, ptr)
+				schemaSet = true
+			}
+		case key == "allowEmptyValue" && in != "query":
+		default:
+			out.Set(key, p.Get(key))
+		}
+	}
+	return out
+}
+
+// setStyle sets the style and explode of a parameter from the
+// collectionFormat of an array parameter p.
+func (c *converter) setStyle(out *specdoc.Object, in string, p *specdoc.Object, ptr string) {
+	if p.Get("type") != "array" {
+		return
+	}
+	format, _ := p.Get("collectionFormat").(string)
+	switch format {
+	case "", "csv":
+		if in == "query" {
+			out.Set("style", "form")
+			out.Set("explode", false)
+		}
+	case "multi":
+		if in == "query" {
+			out.Set("style", "form")
+			out.Set("explode", true)
+		} else {
+			c.lossy(ptr, "collectionFormat multi of a %s parameter converted as csv", in)
+		}
+	case "ssv", "pipes":
+		style := map[string]string{"ssv": "spaceDelimited", "pipes": "pipeDelimited"}[format]
+		if in == "query" {
+			out.Set("style", style)
+			out.Set("explode", false)
+		} else {
+			c.lossy(ptr, "collectionFormat %s of a %s parameter converted as csv", format, in)
+		}
+	default:
+		c.lossy(ptr, "collectionFormat %s has no OpenAPI 3 style, converted as csv", format)
+		if in == "query" {
+			out.Set("style", "form")
+			out.Set("explode", false)
+		}
+	}
+}
+
+// parameterSchema returns the schema of the type keys of a non-body
+// parameter, header or items object.
+func (c *converter) parameterSchema(p *specdoc.Object, ptr string) *specdoc.Object {
+	schema := specdoc.NewObject()
+	for _, key := range p.Keys() {
+		if !slices.Contains(schemaKeys, key) {
+			continue
+		}
+		v := p.Get(key)
+		if items, ok := v.(*specdoc.Object); ok && key == "items" {
+			if items.Has("collectionFormat") && items.Get("collectionFormat") != "csv" {
+				c.lossy(ptr+"/items", "removed collectionFormat %v of nested items", items.Get("collectionFormat"))
+			}
+			if !items.Has("$ref") {
+				v = c.parameterSchema(items, ptr+"/items")
+			}
+		}
+		schema.Set(key, v)
+	}
+	if schema.Get("type") == "file" {
+		schema.Set("type", "string")
+		schema.Set("format", "binary")
+	}
+	return schema
+}
+
+// requestBody returns the request body of a body parameter.
+func (c *converter) requestBody(p *specdoc.Object, consumes []string) *specdoc.Object {
+	rb := specdoc.NewObject()
+	if d, ok := p.Get("description").(string); ok {
+		rb.Set("description", d)
+	}
+	schema := p.Get("schema")
+	convertSchema(schema)
+	var types []string
+	for _, t := range consumes {
+		if !slices.Contains(formTypes, t) {
+			types = append(types, t)
+		}
+	}
+	if len(types) == 0 {
+		types = []string{"application/json"}
+	}
+	rb.Set("content", content(types, schema))
+	if p.Get("required") == true {
+		rb.Set("required", true)
+	}
+	for _, key := range p.Keys() {
+		if strings.HasPrefix(key, "x-") {
+			rb.Set(key, p.Get(key))
+		}
+	}
+	return rb
+}
+
+// convertResponse converts the schema, headers and examples of a response.
+func (c *converter) convertResponse(r *specdoc.Object, produces []string, ptr string) *specdoc.Object {
+	out := specdoc.NewObject()
+	examples, _ := r.Get("examples").(*specdoc.Object)
+	if !r.Has("description") {
+		// Descriptions are required in both versions.
+		out.Set("description", "")
+	}
+	for _, key := range r.Keys() {
+		switch key {
+		case "schema":
+			schema := r.Get(key)
+			convertSchema(schema)
+			types := produces
+			if len(types) == 0 {
+				types = []string{"application/json"}
+			}
+			media := content(types, schema)
+			for _, t := range examples.Keys() {
+				if m, ok := media.Get(t).(*specdoc.Object); ok {
+					m.Set("example", examples.Get(t))
+				} else {
+					c.lossy(specdoc.Pointer(ptr+"/examples", t), "removed the example of a media type the operation does not produce")
+				}
+			}
+			out.Set("content", media)
+		case "examples":
+			if !r.Has("schema") {
+				c.lossy(ptr+"/examples", "removed the examples of a response without a schema")
+			}
+		case "headers":
+			headers, _ := r.Get(key).(*specdoc.Object)
+			for _, name := range headers.Keys() {
+				if h, ok := headers.Get(name).(*specdoc.Object); ok {
+					headers.Set(name, c.convertHeader(h, specdoc.Pointer(ptr+"/headers", name)))
+				}
+			}
+			out.Set(key, headers)
+		default:
+			out.Set(key, r.Get(key))
+		}
+	}
+	return out
+}
+
+// convertHeader converts a response header: its type keys move to a schema.
+func (c *converter) convertHeader(h *specdoc.Object, ptr string) *specdoc.Object {
+	out := specdoc.NewObject()
+	schemaSet := false
+	for _, key := range h.Keys() {
+		switch {
+		case slices.Contains(schemaKeys, key) || key == "collectionFormat":
+			if !schemaSet {
+				out.Set("schema", c.parameterSchema(h, ptr))
+				schemaSet = true
+			}
+			if f := h.Get("collectionFormat"); key == "collectionFormat" && f != "csv" {
+				c.lossy(ptr, "collectionFormat %v of a header converted as csv", f)
+			}
+		default:
+			out.Set(key, h.Get(key))
+		}
+	}
+	return out
+}
+
+// convertSecurityScheme converts a security definition: basic becomes an
+// http scheme, and an oauth2 flow becomes one of flows.
+func (c *converter) convertSecurityScheme(s *specdoc.Object, ptr string) *specdoc.Object {
+	switch s.Get("type") {
+	case "basic":
+		out := specdoc.NewObject()
+		for _, key := range s.Keys() {
+			if key == "type" {
+				out.Set("type", "http")
+				out.Set("scheme", "basic")
+			} else {
+				out.Set(key, s.Get(key))
+			}
+		}
+		return out
+	case "oauth2":
+		flowName := map[string]string{
+			"implicit":    "implicit",
+			"password":    "password",
+			"application": "clientCredentials",
+			"accessCode":  "authorizationCode",
+		}
+		out := specdoc.NewObject()
+		flow := specdoc.NewObject()
+		name := ""
+		for _, key := range s.Keys() {
+			switch key {
+			case "flow":
+				f, _ := s.Get(key).(string)
+				if name = flowName[f]; name == "" {
+					c.lossy(ptr+"/flow", "unknown oauth2 flow %q converted as implicit", f)
+					name = "implicit"
+				}
+				flows := specdoc.NewObject()
+				flows.Set(name, flow)
+				out.Set("flows", flows)
+			case "authorizationUrl", "tokenUrl", "scopes":
+				flow.Set(key, s.Get(key))
+			default:
+				out.Set(key, s.Get(key))
+			}
+		}
+		if !flow.Has("scopes") {
+			flow.Set("scopes", specdoc.NewObject())
+		}
+		return out
+	}
+	return s
+}
+
+// convertSchema converts the schemas of v in place: type file becomes a
+// binary string, x-nullable becomes nullable, and a discriminator property
+// name becomes a discriminator object.
+func convertSchema(v any) {
+	_ = specdoc.Walk(v, func(o *specdoc.Object, ptr string) error {
+		if o.Get("type") == "file" {
+			o.Set("type", "string")
+			o.Set("format", "binary")
+		}
+		if nullable, ok := o.Get("x-nullable").(bool); ok {
+			o.Replace("x-nullable", "nullable", nullable)
+		}
+		if name, ok := o.Get("discriminator").(string); ok {
+			d := specdoc.NewObject()
+			d.Set("propertyName", name)
+			o.Set("discriminator", d)
+		}
+		return nil
+	})
+}
+
+// rewriteRefs points the $refs of v to the components the definitions,
+// parameters and responses moved to, in this and in other documents.
+func rewriteRefs(v any) {
+	prefixes := [][2]string{
+		{"#/definitions/", "#/components/schemas/"},
+		{"#/parameters/", "#/components/parameters/"},
+		{"#/responses/", "#/components/responses/"},
+	}
+	var scan func(v any)
+	scan = func(v any) {
+		switch v := v.(type) {
+		case *specdoc.Object:
+			for _, key := range v.Keys() {
+				if ref, ok := v.Get(key).(string); ok && key == "$ref" {
+					doc, fragment, _ := strings.Cut(ref, "#")
+					for _, p := range prefixes {
+						if strings.HasPrefix("#"+fragment, p[0]) {
+							v.Set(key, doc+p[1]+strings.TrimPrefix("#"+fragment, p[0]))
+						}
+					}
+				}
+				scan(v.Get(key))
+			}
+		case []any:
+			for _, elem := range v {
+				scan(elem)
+			}
+		}
+	}
+	scan(v)
+}
+
+// content returns a content object with the schema for each media type.
+func content(types []string, schema any) *specdoc.Object {
+	media := specdoc.NewObject()
+	for i, t := range types {
+		m := specdoc.NewObject()
+		if schema != nil {
+			if i > 0 {
+				schema = specdoc.Clone(schema)
+			}
+			m.Set("schema", schema)
+		}
+		media.Set(t, m)
+	}
+	return media
+}
+
+// refObject returns a $ref object.
+func refObject(ref string) *specdoc.Object {
+	o := specdoc.NewObject()
+	o.Set("$ref", ref)
+	return o
+}
+
+// insertBefore sets key of o to v, right before the key before, or last if o
+// has no key before.
+func insertBefore(o *specdoc.Object, before, key string, v any) {
+	keys := o.Keys()
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		values[i] = o.Get(k)
+		o.Delete(k)
+	}
+	for i, k := range keys {
+		if k == before {
+			o.Set(key, v)
+		}
+		o.Set(k, values[i])
+	}
+	if !o.Has(key) {
+		o.Set(key, v)
+	}
+}
+
+// stringList returns the strings of a JSON array.
+func stringList(v any) []string {
+	list, _ := v.([]any)
+	var s []string
+	for _, elem := range list {
+		if str, ok := elem.(string); ok {
+			s = append(s, str)
+		}
+	}
+	return s
+}
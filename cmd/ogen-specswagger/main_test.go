@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Pets", "version": "1"},
+  "host": "pets.example.com",
+  "basePath": "/v1",
+  "schemes": ["https"],
+  "consumes": ["application/json"],
+  "produces": ["application/json"],
+  "paths": {
+    "/pets": {
+      "get": {
+        "operationId": "listPets",
+        "schemes": ["http"],
+        "parameters": [
+          {"name": "tags", "in": "query", "type": "array", "items": {"type": "string"}, "collectionFormat": "multi"},
+          {"name": "ids", "in": "query", "type": "array", "items": {"type": "integer"}, "collectionFormat": "tsv"},
+          {"name": "X-Ids", "in": "header", "type": "array", "items": {"type": "integer"}, "collectionFormat": "pipes"},
+          {"$ref": "#/parameters/limit"}
+        ],
+        "responses": {
+          "200": {"description": "ok", "schema": {"type": "array", "items": {"$ref": "#/definitions/Pet"}}, "headers": {"X-Total": {"type": "integer"}}, "examples": {"application/json": [], "text/plain": ""}},
+          "default": {"$ref": "#/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "createPet",
+        "parameters": [{"$ref": "#/parameters/PetBody"}],
+        "responses": {"201": {"description": "created"}}
+      },
+      "put": {
+        "operationId": "updatePet",
+        "consumes": ["application/json", "application/xml"],
+        "parameters": [{"$ref": "#/parameters/PetBody"}],
+        "responses": {"204": {"description": "updated"}}
+      }
+    },
+    "/pets/{id}/photo": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "type": "integer"}, {"name": "note", "in": "formData", "type": "string"}],
+      "post": {
+        "operationId": "uploadPhoto",
+        "parameters": [{"name": "photo", "in": "formData", "type": "file", "required": true}],
+        "responses": {"200": {"description": "ok"}}
+      }
+    }
+  },
+  "definitions": {
+    "Pet": {"type": "object", "discriminator": "kind", "properties": {"kind": {"type": "string"}, "tag": {"type": "string", "x-nullable": true}}}
+  },
+  "parameters": {
+    "limit": {"name": "limit", "in": "query", "type": "integer", "maximum": 100},
+    "PetBody": {"name": "pet", "in": "body", "required": true, "schema": {"$ref": "#/definitions/Pet"}}
+  },
+  "responses": {"Error": {"description": "error"}},
+  "securityDefinitions": {
+    "oauth": {"type": "oauth2", "flow": "application", "tokenUrl": "https://pets.example.com/token", "scopes": {}},
+    "basic": {"type": "basic", "description": "Basic."}
+  }
+}`
+
+func TestConvert(t *testing.T) {
+	spec, err := specdoc.Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Convert(spec)
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+
+	got, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(got)
+	for _, want := range []string{
+		`{"openapi":"3.0.3","info":{"title":"Pets","version":"1"},"servers":[{"url":"https://pets.example.com/v1"}],"paths":`,
+		`{"name":"tags","in":"query","schema":{"type":"array","items":{"type":"string"}},"style":"form","explode":true}`,
+		`{"name":"ids","in":"query","schema":{"type":"array","items":{"type":"integer"}},"style":"form","explode":false}`,
+		`{"name":"X-Ids","in":"header","schema":{"type":"array","items":{"type":"integer"}}}`,
+		`{"$ref":"#/components/parameters/limit"}`,
+		`"200":{"description":"ok","content":{"application/json":{"schema":{"type":"array","items":{"$ref":"#/components/schemas/Pet"}},"example":[]}},"headers":{"X-Total":{"schema":{"type":"integer"}}}}`,
+		`"default":{"$ref":"#/components/responses/Error"}`,
+		// The body parameter stays a reference if the operation consumes the
+		// global media types.
+		`"operationId":"createPet","requestBody":{"$ref":"#/components/requestBodies/PetBody"}`,
+		`"operationId":"updatePet","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/Pet"}},"application/xml":{"schema":{"$ref":"#/components/schemas/Pet"}}},"required":true}`,
+		// formData parameters of the path item move to the operation.
+		`"/pets/{id}/photo":{"parameters":[{"name":"id","in":"path","required":true,"schema":{"type":"integer"}}],"post":{"operationId":"uploadPhoto","requestBody":{"content":{"multipart/form-data":{"schema":{"type":"object","properties":{"note":{"type":"string"},"photo":{"type":"string","format":"binary"}},"required":["photo"]}}},"required":true}`,
+		`"components":{"schemas":{"Pet":{"type":"object","discriminator":{"propertyName":"kind"},"properties":{"kind":{"type":"string"},"tag":{"type":"string","nullable":true}}}}`,
+		`"parameters":{"limit":{"name":"limit","in":"query","schema":{"type":"integer","maximum":100}}}`,
+		`"requestBodies":{"PetBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/Pet"}}},"required":true}}`,
+		`"oauth":{"type":"oauth2","flows":{"clientCredentials":{"tokenUrl":"https://pets.example.com/token","scopes":{}}}}`,
+		`"basic":{"type":"http","scheme":"basic","description":"Basic."}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+	for _, gone := range []string{"swagger", "host", "basePath", "consumes", "produces", "definitions", "collectionFormat", "formData", "x-nullable"} {
+		if strings.Contains(out, `"`+gone+`"`) {
+			t.Errorf("output still has %s:\n%s", gone, out)
+		}
+	}
+
+	wantLossy := []Note{
+		{"/paths/~1pets/get/parameters/1", "collectionFormat tsv has no OpenAPI 3 style, converted as csv"},
+		{"/paths/~1pets/get/parameters/2", "collectionFormat pipes of a header parameter converted as csv"},
+		{"/paths/~1pets/get/schemes", "removed the schemes of the operation"},
+		{"/paths/~1pets/get/responses/200/examples/text~1plain", "removed the example of a media type the operation does not produce"},
+	}
+	if !reflect.DeepEqual(report.Lossy, wantLossy) {
+		t.Errorf("Lossy =\n%q\nwant\n%q", report.Lossy, wantLossy)
+	}
+	if report.Operations != 4 {
+		t.Errorf("Operations = %d, want 4", report.Operations)
+	}
+}
+
+func TestConvert_Servers(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want string
+	}{
+		{
+			name: "host without schemes",
+			spec: `{"swagger": "2.0", "host": "api.example.com", "info": {}}`,
+			want: `{"openapi":"3.0.3","servers":[{"url":"https://api.example.com"}],"info":{}}`,
+		},
+		{
+			name: "base path only",
+			spec: `{"swagger": "2.0", "info": {}, "basePath": "/api"}`,
+			want: `{"openapi":"3.0.3","info":{},"servers":[{"url":"/api"}]}`,
+		},
+		{
+			name: "none",
+			spec: `{"swagger": "2.0", "info": {}}`,
+			want: `{"openapi":"3.0.3","info":{}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := specdoc.Parse([]byte(tt.spec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Convert(spec); err != nil {
+				t.Fatalf("Convert: %v", err)
+			}
+			if got, _ := json.Marshal(spec); string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvert_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{
+			name:    "OpenAPI 3",
+			spec:    `{"openapi": "3.0.3"}`,
+			wantErr: `swagger version "" is not 2.0`,
+		},
+		{
+			name:    "Swagger 1.2",
+			spec:    `{"swagger": "1.2"}`,
+			wantErr: `swagger version "1.2" is not 2.0`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := specdoc.Parse([]byte(tt.spec))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Convert(spec)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Convert() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "swagger.json")
+	output := filepath.Join(dir, "openapi.json")
+	if err := os.WriteFile(input, []byte(testSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-o", output, input}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(got); !strings.Contains(out, `"openapi": "3.0.3"`) || strings.Contains(out, `"swagger"`) {
+		t.Errorf("output is not OpenAPI 3.0:\n%s", got)
+	}
+
+	// The input spec is not modified.
+	if orig, _ := os.ReadFile(input); string(orig) != testSpec {
+		t.Error("input spec was modified")
+	}
+}
@@ -0,0 +1,200 @@
+// Package fieldfix rewrites the decoding of selected fields in ogen-generated
+// JSON code.
+//
+// Fixers that relax decoding (accepting strings for booleans, NaN for floats)
+// must not apply to every field, or they hide real bugs. This package finds
+// the `case "field":` blocks of the Decode methods named in a config, checks
+// the Go type of each field, and hands the block to a fixer-specific rewrite.
+package fieldfix
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Config maps generated type names to the JSON names of the fields to
+// rewrite. AllFields selects every field of a supported type.
+type Config map[string][]string
+
+// AllFields selects every field of a type that the rewriter supports.
+const AllFields = "*"
+
+// FieldTypes maps struct names to field names to the field's type
+// expression, as returned by LoadFieldTypes.
+type FieldTypes map[string]map[string]string
+
+// LoadFieldTypes parses a generated schemas file and returns the type
+// expression of every struct field.
+func LoadFieldTypes(filename string) (FieldTypes, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, nil, parser.SkipObjectResolution) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return nil, fmt.Errorf("parse schemas: %w", err)
+	}
+
+	fields := make(FieldTypes)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			m := make(map[string]string)
+			for _, field := range st.Fields.List {
+				for _, name := range field.Names {
+					m[name.Name] = types.ExprString(field.Type)
+				}
+			}
+			fields[ts.Name.Name] = m
+		}
+	}
+	return fields, nil
+}
+
+// Rewriter rewrites the decoding of fields of a family of types.
+type Rewriter struct {
+	// Kind names the family in errors, e.g. "boolean".
+	Kind string
+
+	// Types are the field type expressions the rewriter supports.
+	Types map[string]bool
+
+	// Rewrite rewrites one case block decoding into s.<goField> of type
+	// fieldType, reporting whether anything changed.
+	Rewrite func(block []byte, goField, fieldType string) ([]byte, bool)
+}
+
+var (
+	// decodeMethodPattern matches a whole generated struct Decode method.
+	decodeMethodPattern = regexp.MustCompile(
+		`(?s)func \(s \*(\w+)\) Decode\(d \*jx\.Decoder\) error \{\n.*?\n\}\n`)
+
+	// caseStartPattern matches the start of a field case in a Decode method.
+	caseStartPattern = regexp.MustCompile(`\n\t\tcase "((?:[^"\\]|\\.)*)":\n`)
+
+	// caseEndPattern matches the line after the last field case.
+	caseEndPattern = regexp.MustCompile(`\n\t\tdefault:\n`)
+
+	// fieldPattern finds the struct field a case block decodes into.
+	fieldPattern = regexp.MustCompile(`\bs\.(\w+)\b`)
+)
+
+// Apply rewrites the configured fields in the Decode methods in content and
+// returns the number of fields changed.
+//
+// It returns an error if a configured type or field does not exist, or if a
+// field named explicitly is not of a supported type. Fields selected with
+// AllFields are skipped if their type is not supported.
+func (r Rewriter) Apply(content []byte, fields FieldTypes, cfg Config) ([]byte, int, error) {
+	seen := make(map[string]bool)
+	count := 0
+	var errs []string
+
+	fixed := decodeMethodPattern.ReplaceAllFunc(content, func(method []byte) []byte {
+		typeName := string(decodeMethodPattern.FindSubmatch(method)[1])
+		names, ok := cfg[typeName]
+		if !ok {
+			return method
+		}
+		seen[typeName] = true
+
+		wanted := make(map[string]bool)
+		for _, name := range names {
+			wanted[name] = true
+		}
+		all := wanted[AllFields]
+		delete(wanted, AllFields)
+
+		var out bytes.Buffer
+		for _, block := range splitCases(method) {
+			explicit := wanted[block.name]
+			if block.name == "" || !(explicit || all) {
+				out.Write(block.text)
+				continue
+			}
+			delete(wanted, block.name)
+
+			m := fieldPattern.FindSubmatch(block.text)
+			if m == nil {
+				out.Write(block.text)
+				continue
+			}
+			goField := string(m[1])
+			fieldType := fields[typeName][goField]
+			if !r.Types[fieldType] {
+				if explicit {
+					errs = append(errs, fmt.Sprintf("%s.%s is %s, not a %s", typeName, block.name, fieldType, r.Kind))
+				}
+				out.Write(block.text)
+				continue
+			}
+
+			text, changed := r.Rewrite(block.text, goField, fieldType)
+			if changed {
+				count++
+			}
+			out.Write(text)
+		}
+
+		for name := range wanted {
+			errs = append(errs, fmt.Sprintf("%s has no field %q", typeName, name))
+		}
+		return out.Bytes()
+	})
+
+	for typeName := range cfg {
+		if !seen[typeName] {
+			errs = append(errs, fmt.Sprintf("type %s has no generated Decode method", typeName))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, 0, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return fixed, count, nil
+}
+
+// caseBlock is a piece of a Decode method. Blocks with a name are the body of
+// the case for that JSON field; the others are the code around them.
+type caseBlock struct {
+	name string
+	text []byte
+}
+
+// splitCases splits a Decode method into its field cases and the code
+// between them, so that concatenating the blocks gives back the method.
+func splitCases(method []byte) []caseBlock {
+	starts := caseStartPattern.FindAllSubmatchIndex(method, -1)
+	if len(starts) == 0 {
+		return []caseBlock{{text: method}}
+	}
+	end := len(method)
+	if loc := caseEndPattern.FindIndex(method); loc != nil {
+		end = loc[0]
+	}
+
+	blocks := []caseBlock{{text: method[:starts[0][0]]}}
+	for i, loc := range starts {
+		blockEnd := end
+		if i+1 < len(starts) {
+			blockEnd = starts[i+1][0]
+		}
+		blocks = append(blocks, caseBlock{
+			name: string(method[loc[2]:loc[3]]),
+			text: method[loc[0]:blockEnd],
+		})
+	}
+	return append(blocks, caseBlock{text: method[end:]})
+}
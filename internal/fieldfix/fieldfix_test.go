@@ -0,0 +1,175 @@
+package fieldfix
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadFieldTypes(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "oas_schemas_gen.go")
+	src := "package api\n\ntype Metric struct {\n\tEnabled bool `json:\"enabled\"`\n\tFlags []bool `json:\"flags\"`\n\tHidden OptNilBool `json:\"hidden\"`\n}\n"
+	if err := os.WriteFile(filename, []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fields, err := LoadFieldTypes(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"Enabled": "bool", "Flags": "[]bool", "Hidden": "OptNilBool"}
+	for name, typ := range want {
+		if got := fields["Metric"][name]; got != typ {
+			t.Errorf("Metric.%s = %q, want %q", name, got, typ)
+		}
+	}
+}
+
+const decodeInput = `// Decode decodes Metric from json.
+func (s *Metric) Decode(d *jx.Decoder) error {
+	if err := d.ObjBytes(func(d *jx.Decoder, k []byte) error {
+		switch string(k) {
+		case "enabled":
+			if err := func() error {
+				v, err := d.Bool()
+				s.Enabled = bool(v)
+				return err
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"enabled\"")
+			}
+		case "strict":
+			if err := func() error {
+				v, err := d.Bool()
+				s.Strict = bool(v)
+				return err
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"strict\"")
+			}
+		case "name":
+			if err := func() error {
+				v, err := d.Str()
+				s.Name = string(v)
+				return err
+			}(); err != nil {
+				return errors.Wrap(err, "decode field \"name\"")
+			}
+		default:
+			return d.Skip()
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "decode Metric")
+	}
+	return nil
+}
+`
+
+var decodeFields = FieldTypes{
+	"Metric": {"Enabled": "bool", "Strict": "bool", "Name": "string"},
+}
+
+// lenient is a Rewriter that marks the fields it rewrites.
+var lenient = Rewriter{
+	Kind:  "boolean",
+	Types: map[string]bool{"bool": true},
+	Rewrite: func(block []byte, goField, fieldType string) ([]byte, bool) {
+		text := strings.Replace(string(block), "d.Bool()", "lenientBool(d) // "+goField+" "+fieldType, 1)
+		return []byte(text), text != string(block)
+	},
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       Config
+		wantCount int
+		want      []string
+		wantErr   string
+	}{
+		{
+			name:      "explicit field",
+			cfg:       Config{"Metric": {"strict"}},
+			wantCount: 1,
+			want:      []string{"lenientBool(d) // Strict bool", "v, err := d.Bool()\n\t\t\t\ts.Enabled"},
+		},
+		{
+			name:      "all fields",
+			cfg:       Config{"Metric": {AllFields}},
+			wantCount: 2,
+			want:      []string{"lenientBool(d) // Enabled bool", "lenientBool(d) // Strict bool", "v, err := d.Str()"},
+		},
+		{
+			name:    "unsupported type",
+			cfg:     Config{"Metric": {"name"}},
+			wantErr: "Metric.name is string, not a boolean",
+		},
+		{
+			name:    "missing field and type",
+			cfg:     Config{"Metric": {"enabled", "missing"}, "Other": {"x"}},
+			wantErr: `Metric has no field "missing"; type Other has no generated Decode method`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count, err := lenient.Apply([]byte(decodeInput), decodeFields, tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Apply() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if count != tt.wantCount {
+				t.Errorf("count = %d, want %d", count, tt.wantCount)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("output missing %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestApply_Unchanged(t *testing.T) {
+	// A rewrite that changes nothing is not counted.
+	r := lenient
+	r.Rewrite = func(block []byte, _, _ string) ([]byte, bool) { return block, false }
+
+	got, count, err := r.Apply([]byte(decodeInput), decodeFields, Config{"Metric": {AllFields}})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if count != 0 || string(got) != decodeInput {
+		t.Errorf("count = %d, output changed = %v", count, string(got) != decodeInput)
+	}
+}
+
+func TestSplitCases(t *testing.T) {
+	blocks := splitCases([]byte(decodeInput))
+
+	var names []string
+	var joined strings.Builder
+	for _, b := range blocks {
+		if b.name != "" {
+			names = append(names, b.name)
+		}
+		joined.Write(b.text)
+	}
+	if want := []string{"enabled", "strict", "name"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+	if joined.String() != decodeInput {
+		t.Errorf("joined blocks =\n%s\nwant\n%s", joined.String(), decodeInput)
+	}
+	// The default case stays outside the field blocks.
+	if last := blocks[len(blocks)-2]; strings.Contains(string(last.text), "default:") {
+		t.Errorf("block %q has the default case", last.name)
+	}
+}
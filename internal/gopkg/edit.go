@@ -0,0 +1,29 @@
+package gopkg
+
+import "sort"
+
+// Edit replaces the bytes Start to End of a source file with Text. An
+// insertion has Start equal to End.
+type Edit struct {
+	Start, End int
+	Text       string
+}
+
+// Insert returns the edit inserting text at offset.
+func Insert(offset int, text string) Edit {
+	return Edit{Start: offset, End: offset, Text: text}
+}
+
+// ApplyEdits applies non-overlapping edits to src, in the order of their
+// offsets. Insertions at the same offset keep their order.
+func ApplyEdits(src []byte, edits []Edit) []byte {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].Start < edits[j].Start })
+	var out []byte
+	last := 0
+	for _, e := range edits {
+		out = append(out, src[last:e.Start]...)
+		out = append(out, e.Text...)
+		last = e.End
+	}
+	return append(out, src[last:]...)
+}
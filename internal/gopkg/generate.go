@@ -0,0 +1,105 @@
+package gopkg
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Generated accumulates the source of a new file for the package, tracking
+// the imports its code needs.
+type Generated struct {
+	tool    string
+	pkg     *types.Package
+	imports map[string]string // path -> name
+	body    bytes.Buffer
+}
+
+// NewGenerated starts a file for pkg written by tool; the tool name goes in
+// the "Code generated" header.
+func NewGenerated(tool string, pkg *types.Package) *Generated {
+	return &Generated{
+		tool:    tool,
+		pkg:     pkg,
+		imports: make(map[string]string),
+	}
+}
+
+// Printf appends formatted source to the file body.
+func (g *Generated) Printf(format string, args ...any) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+// Import records an import and returns the name to qualify it with.
+func (g *Generated) Import(path, name string) string {
+	g.imports[path] = name
+	return name
+}
+
+// TypeString renders t as it must be spelled in the generated file,
+// importing the packages it mentions.
+func (g *Generated) TypeString(t types.Type) string {
+	return types.TypeString(t, func(other *types.Package) string {
+		if other == g.pkg {
+			return ""
+		}
+		return g.Import(other.Path(), other.Name())
+	})
+}
+
+// Bytes returns the gofmt-ed file.
+func (g *Generated) Bytes() ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by %s, DO NOT EDIT.\n\npackage %s\n\n", g.tool, g.pkg.Name())
+	if len(g.imports) > 0 {
+		// Standard library first, then everything else, as goimports does.
+		var std, other []string
+		for path := range g.imports {
+			if strings.Contains(strings.Split(path, "/")[0], ".") {
+				other = append(other, path)
+			} else {
+				std = append(std, path)
+			}
+		}
+		sort.Strings(std)
+		sort.Strings(other)
+		out.WriteString("import (\n")
+		for i, group := range [][]string{std, other} {
+			if i > 0 && len(std) > 0 && len(other) > 0 {
+				out.WriteString("\n")
+			}
+			for _, path := range group {
+				if name := g.imports[path]; name != filepath.Base(path) {
+					fmt.Fprintf(&out, "\t%s %q\n", name, path)
+				} else {
+					fmt.Fprintf(&out, "\t%q\n", path)
+				}
+			}
+		}
+		out.WriteString(")\n\n")
+	}
+	out.Write(g.body.Bytes())
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return formatted, nil
+}
+
+// WriteFile writes the file to path.
+func (g *Generated) WriteFile(path string) error {
+	data, err := g.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil { // #nosec G703 -- CLI tool, path from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+	return nil
+}
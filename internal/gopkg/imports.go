@@ -0,0 +1,116 @@
+package gopkg
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// importBlock matches the parenthesized import block of a source file, and
+// its imports.
+var importBlock = regexp.MustCompile(`(import \(\n)([\s\S]*?)(\n\))`)
+
+// AddImports adds the import paths missing from the import block of src.
+// They go at the top of the block; gofmt sorts them into the standard
+// library group. src is returned unchanged if it has no import block.
+func AddImports(src []byte, paths ...string) []byte {
+	loc := importBlock.FindSubmatchIndex(src)
+	if loc == nil {
+		return src
+	}
+
+	imports := string(src[loc[4]:loc[5]])
+	var missing strings.Builder
+	for _, p := range paths {
+		if quoted := strconv.Quote(p); !strings.Contains(imports, quoted) {
+			missing.WriteString("\t" + quoted + "\n")
+		}
+	}
+	if missing.Len() == 0 {
+		return src
+	}
+
+	var out []byte
+	out = append(out, src[:loc[3]]...)
+	out = append(out, missing.String()...)
+	return append(out, src[loc[3]:]...)
+}
+
+// FixImports adds the import paths src uses and drops those it no longer
+// uses, after edits to its text. A path is used if the last element of the
+// path starts a selector anywhere in src, such as xml. for encoding/xml but
+// not in ogenxml. Unlike RemoveUnusedImports, it needs no type-checked
+// package.
+func FixImports(src []byte, paths ...string) []byte {
+	for _, p := range paths {
+		selector := regexp.MustCompile(`\b` + regexp.QuoteMeta(path.Base(p)) + `\.`)
+		if selector.Match(src) {
+			src = AddImports(src, p)
+		} else {
+			src = []byte(strings.Replace(string(src), "\t"+strconv.Quote(p)+"\n", "", 1))
+		}
+	}
+	return src
+}
+
+// RemoveUnusedImports drops imports that file no longer references, which
+// happens after declarations are deleted from it. Blank and dot imports are
+// kept. It reports whether any import was removed.
+func (p *Package) RemoveUnusedImports(file *ast.File) bool {
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok {
+			if pkgName, ok := p.Info.Uses[id].(*types.PkgName); ok {
+				used[pkgName.Imported().Path()] = true
+			}
+		}
+		return true
+	})
+
+	removed := false
+	var decls []ast.Decl
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			decls = append(decls, decl)
+			continue
+		}
+		var specs []ast.Spec
+		for _, spec := range gen.Specs {
+			imp := spec.(*ast.ImportSpec)
+			path, err := strconv.Unquote(imp.Path.Value)
+			keep := err != nil || used[path] ||
+				(imp.Name != nil && (imp.Name.Name == "_" || imp.Name.Name == "."))
+			if keep {
+				specs = append(specs, spec)
+			} else {
+				removed = true
+			}
+		}
+		if len(specs) > 0 {
+			gen.Specs = specs
+			decls = append(decls, gen)
+		}
+	}
+	file.Decls = decls
+
+	if removed {
+		var imports []*ast.ImportSpec
+		for _, imp := range file.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			if used[path] || (imp.Name != nil && (imp.Name.Name == "_" || imp.Name.Name == ".")) {
+				imports = append(imports, imp)
+			}
+		}
+		file.Imports = imports
+	}
+	return removed
+}
@@ -0,0 +1,74 @@
+package gopkg
+
+import "testing"
+
+func TestAddImports(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "adds missing",
+			input: "import (\n\t\"time\"\n)\n",
+			want:  "import (\n\t\"encoding/base64\"\n\t\"strings\"\n\t\"time\"\n)\n",
+		},
+		{
+			name:  "keeps existing",
+			input: "import (\n\t\"strings\"\n)\n",
+			want:  "import (\n\t\"encoding/base64\"\n\t\"strings\"\n)\n",
+		},
+		{
+			name:  "no import block",
+			input: "import \"time\"\n",
+			want:  "import \"time\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(AddImports([]byte(tt.input), "encoding/base64", "strings")); got != tt.want {
+				t.Errorf("AddImports() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFixImports(t *testing.T) {
+	const imports = "import (\n\t\"github.com/go-faster/jx\"\n\t\"github.com/ogen-go/ogen/ogenerrors\"\n)\n"
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "adds used",
+			input: imports + "var _ = xml.Unmarshal\nvar _ jx.Encoder\nvar _ = ogenerrors.ErrSecurityRequirementIsNotSatisfied\n",
+			want:  "import (\n\t\"encoding/xml\"\n\t\"github.com/go-faster/jx\"\n\t\"github.com/ogen-go/ogen/ogenerrors\"\n)\n",
+		},
+		{
+			name:  "drops unused",
+			input: imports + "var _ = ogenerrors.ErrSecurityRequirementIsNotSatisfied\n",
+			want:  "import (\n\t\"github.com/ogen-go/ogen/ogenerrors\"\n)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(FixImports([]byte(tt.input), "encoding/xml", "github.com/go-faster/errors", "github.com/go-faster/jx"))
+			if got[:len(tt.want)] != tt.want {
+				t.Errorf("FixImports() =\n%s\nwant imports\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	src := []byte("func f() {\n\treturn json(v)\n}\n")
+	got := ApplyEdits(src, []Edit{
+		{Start: 12, End: 26, Text: "return xml(v)"},
+		Insert(11, "\tstart()\n"),
+		Insert(11, "\tlog()\n"),
+	})
+	if want := "func f() {\n\tstart()\n\tlog()\n\treturn xml(v)\n}\n"; string(got) != want {
+		t.Errorf("ApplyEdits() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+// Package gopkg loads, edits, and writes an ogen-generated Go package.
+//
+// The package is type-checked so that edits follow declarations rather than
+// spelling: a generated field named Body can be renamed or removed without
+// touching resp.Body on an *http.Response.
+package gopkg
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Package is a parsed and type-checked Go package.
+type Package struct {
+	Dir   string
+	Fset  *token.FileSet
+	Files map[string]*ast.File
+	Types *types.Package
+	Info  *types.Info
+
+	changed map[string]bool
+	removed map[string]bool
+}
+
+// Load parses and type-checks the non-test Go files in dir, ignoring files
+// whose base name is listed in skip (typically the file a generator is about
+// to rewrite, which may not compile against the regenerated package).
+//
+// Imports are resolved from source relative to dir, so dir must be inside a
+// module whose dependencies are available.
+func Load(dir string, skip ...string) (*Package, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read dir: %w", err)
+	}
+
+	fset := token.NewFileSet()
+	files := make(map[string]*ast.File)
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") ||
+			slices.Contains(skip, name) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("parse: %w", err)
+		}
+		files[path] = file
+		names = append(names, path)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	// Check files in a stable order so errors are reproducible.
+	sort.Strings(names)
+	ordered := make([]*ast.File, 0, len(names))
+	for _, name := range names {
+		ordered = append(ordered, files[name])
+	}
+
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check(ordered[0].Name.Name, fset, ordered, info)
+	if err != nil {
+		return nil, fmt.Errorf("type-check: %w", err)
+	}
+
+	return &Package{
+		Dir:     dir,
+		Fset:    fset,
+		Files:   files,
+		Types:   pkg,
+		Info:    info,
+		changed: make(map[string]bool),
+		removed: make(map[string]bool),
+	}, nil
+}
+
+// MarkChanged records that the file at path was edited and must be written.
+func (p *Package) MarkChanged(path string) {
+	p.changed[path] = true
+}
+
+// RemoveFile records that the file at path must be deleted by Write.
+func (p *Package) RemoveFile(path string) {
+	p.removed[path] = true
+}
+
+// Write formats and writes every changed file, deletes every removed file,
+// and returns the paths it touched in sorted order.
+func (p *Package) Write() ([]string, error) {
+	var written []string
+	for path := range p.removed {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove file: %w", err)
+		}
+		written = append(written, path)
+	}
+	for path := range p.changed {
+		if p.removed[path] {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := format.Node(&buf, p.Fset, p.Files[path]); err != nil {
+			return nil, fmt.Errorf("format %s: %w", path, err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil { // #nosec G703 -- CLI tool, path from trusted args
+			return nil, fmt.Errorf("write file: %w", err)
+		}
+		written = append(written, path)
+	}
+	sort.Strings(written)
+	return written, nil
+}
@@ -0,0 +1,88 @@
+package gopkg
+
+import (
+	"go/types"
+	"strings"
+)
+
+// Wrapper is the kind of ogen optional/nullable wrapper a type is.
+type Wrapper int
+
+const (
+	// NotWrapped is any type that is not an ogen wrapper.
+	NotWrapped Wrapper = iota
+	// Opt is OptX{Value, Set}: the field may be absent.
+	Opt
+	// OptNil is OptNilX{Value, Set, Null}: the field may be absent or null.
+	OptNil
+	// Nil is NilX{Value, Null}: the field is required but may be null.
+	Nil
+)
+
+// Unwrap reports which ogen wrapper t is and the type of its Value field.
+// For types that are not wrappers it returns NotWrapped and t.
+func Unwrap(t types.Type) (Wrapper, types.Type) {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return NotWrapped, t
+	}
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return NotWrapped, t
+	}
+
+	fields := make(map[string]types.Type)
+	for i := range st.NumFields() {
+		fields[st.Field(i).Name()] = st.Field(i).Type()
+	}
+	value, hasValue := fields["Value"]
+	_, hasSet := fields["Set"]
+	_, hasNull := fields["Null"]
+	if !hasValue || len(fields) != 1+btoi(hasSet)+btoi(hasNull) {
+		return NotWrapped, t
+	}
+
+	name := named.Obj().Name()
+	switch {
+	case strings.HasPrefix(name, "OptNil") && hasSet && hasNull:
+		return OptNil, value
+	case strings.HasPrefix(name, "Opt") && hasSet && !hasNull:
+		return Opt, value
+	case strings.HasPrefix(name, "Nil") && hasNull && !hasSet:
+		return Nil, value
+	}
+	return NotWrapped, t
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Operation is a generated client operation.
+type Operation struct {
+	Name   string           // Go method name, e.g. CreateUser
+	Method *types.Func      // (*Client).CreateUser
+	Sig    *types.Signature // its signature
+}
+
+// Operations returns the methods of the generated Invoker interface in
+// declaration order, or nil if the package has no client.
+func (p *Package) Operations() []Operation {
+	obj, ok := p.Types.Scope().Lookup("Invoker").(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	iface, ok := obj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+	var ops []Operation
+	for i := range iface.NumExplicitMethods() {
+		m := iface.ExplicitMethod(i)
+		ops = append(ops, Operation{Name: m.Name(), Method: m, Sig: m.Type().(*types.Signature)})
+	}
+	return ops
+}
@@ -0,0 +1,208 @@
+package gopkg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Renameable reports whether obj is declared by this package at a level that
+// is visible to other files or packages: package-level declarations, struct
+// fields, and methods. Locals and parameters are never renamed.
+func (p *Package) Renameable(obj types.Object) bool {
+	if obj == nil || obj.Pkg() != p.Types {
+		return false
+	}
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		return false
+	case *types.Var:
+		if obj.IsField() {
+			return true
+		}
+	case *types.Func:
+		if sig, ok := obj.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return true
+		}
+	}
+	return obj.Parent() == p.Types.Scope()
+}
+
+// Rename applies mapName to every renameable object in the package and
+// returns the number of objects renamed. mapName returns the new name, or the
+// object's current name to leave it alone.
+//
+// Renaming fails without modifying anything if a new name is not a valid
+// identifier, changes visibility, or would collide with another declaration
+// in the same scope (package scope, or the fields and methods of one type).
+func (p *Package) Rename(mapName func(obj types.Object) string) (int, error) {
+	renames := make(map[types.Object]string)
+	for _, objs := range []map[*ast.Ident]types.Object{p.Info.Defs, p.Info.Uses} {
+		for _, obj := range objs {
+			if _, seen := renames[obj]; seen || !p.Renameable(obj) {
+				continue
+			}
+			newName := mapName(obj)
+			if newName == obj.Name() {
+				continue
+			}
+			if !token.IsIdentifier(newName) {
+				return 0, fmt.Errorf("rename %s: %q is not a valid identifier", obj.Name(), newName)
+			}
+			if ast.IsExported(obj.Name()) != ast.IsExported(newName) {
+				return 0, fmt.Errorf("rename %s: %q changes visibility", obj.Name(), newName)
+			}
+			renames[obj] = newName
+		}
+	}
+	if err := p.checkCollisions(renames); err != nil {
+		return 0, err
+	}
+
+	paths := make(map[*token.File]string, len(p.Files))
+	for path, file := range p.Files {
+		paths[p.Fset.File(file.Pos())] = path
+	}
+	for _, objs := range []map[*ast.Ident]types.Object{p.Info.Defs, p.Info.Uses} {
+		for ident, obj := range objs {
+			if newName, ok := renames[obj]; ok {
+				ident.Name = newName
+				p.changed[paths[p.Fset.File(ident.Pos())]] = true
+			}
+		}
+	}
+	p.renameInComments(renames)
+
+	return len(renames), nil
+}
+
+// checkCollisions reports a rename whose new name is already taken in the
+// scope it lives in, after accounting for names that are renamed away.
+func (p *Package) checkCollisions(renames map[types.Object]string) error {
+	finalName := func(obj types.Object) string {
+		if newName, ok := renames[obj]; ok {
+			return newName
+		}
+		return obj.Name()
+	}
+
+	// Package scope.
+	taken := make(map[string]types.Object)
+	scope := p.Types.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if prev, ok := taken[finalName(obj)]; ok {
+			return fmt.Errorf("rename %s: %s is already declared by %s", obj.Name(), finalName(obj), prev.Name())
+		}
+		taken[finalName(obj)] = obj
+	}
+
+	// Fields and methods of each named type.
+	for _, name := range scope.Names() {
+		named, ok := scope.Lookup(name).Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		members := make(map[string]types.Object)
+		add := func(obj types.Object) error {
+			if prev, ok := members[finalName(obj)]; ok {
+				return fmt.Errorf("rename %s: %s.%s is already declared by %s", obj.Name(), name, finalName(obj), prev.Name())
+			}
+			members[finalName(obj)] = obj
+			return nil
+		}
+		if st, ok := named.Underlying().(*types.Struct); ok {
+			for i := range st.NumFields() {
+				if err := add(st.Field(i)); err != nil {
+					return err
+				}
+			}
+		}
+		if iface, ok := named.Underlying().(*types.Interface); ok {
+			for i := range iface.NumExplicitMethods() {
+				if err := add(iface.ExplicitMethod(i)); err != nil {
+					return err
+				}
+			}
+		}
+		for i := range named.NumMethods() {
+			if err := add(named.Method(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renameInComments keeps comments in step with the code. Package-level names
+// are unique, so their whole-word occurrences are replaced in every comment;
+// fields and methods are only renamed in their own doc comment, whose first
+// word is the name it documents.
+func (p *Package) renameInComments(renames map[types.Object]string) {
+	global := make(map[string]string)
+	for obj, newName := range renames {
+		if obj.Parent() == p.Types.Scope() {
+			global[obj.Name()] = newName
+		}
+	}
+
+	if len(global) > 0 {
+		olds := make([]string, 0, len(global))
+		for oldName := range global {
+			olds = append(olds, regexp.QuoteMeta(oldName))
+		}
+		// Longest first so a name is not shadowed by one of its prefixes.
+		sort.Slice(olds, func(i, j int) bool { return len(olds[i]) > len(olds[j]) })
+		pattern := regexp.MustCompile(`\b(` + strings.Join(olds, "|") + `)\b`)
+
+		for path, file := range p.Files {
+			for _, group := range file.Comments {
+				for _, comment := range group.List {
+					replaced := pattern.ReplaceAllStringFunc(comment.Text, func(word string) string {
+						return global[word]
+					})
+					if replaced != comment.Text {
+						comment.Text = replaced
+						p.changed[path] = true
+					}
+				}
+			}
+		}
+	}
+
+	for path, file := range p.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			var doc *ast.CommentGroup
+			var names []*ast.Ident
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				doc, names = n.Doc, []*ast.Ident{n.Name}
+			case *ast.Field:
+				doc, names = n.Doc, n.Names
+			}
+			if doc == nil || len(names) == 0 {
+				return true
+			}
+			// Identifiers were already renamed; recover the old name from
+			// the object so the doc comment can be matched against it.
+			obj := p.Info.Defs[names[0]]
+			if obj == nil || obj.Parent() == p.Types.Scope() {
+				return true
+			}
+			if _, ok := renames[obj]; !ok {
+				return true
+			}
+			first := doc.List[0]
+			prefix := "// " + obj.Name() + " "
+			if strings.HasPrefix(first.Text, prefix) {
+				first.Text = "// " + names[0].Name + " " + strings.TrimPrefix(first.Text, prefix)
+				p.changed[path] = true
+			}
+			return true
+		})
+	}
+}
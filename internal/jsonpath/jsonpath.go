@@ -0,0 +1,739 @@
+// Package jsonpath evaluates JSONPath queries (RFC 9535) on documents read
+// by specdoc.
+//
+// It supports names, wildcards, indices, slices, unions, descendants and
+// filters with existence tests, comparisons and logical operators:
+//
+//	$.paths['/pets'].get
+//	$.paths.*[?@.tags[0] == 'internal']
+//	$..[?@.x-internal == true]
+//
+// Filter functions such as length() and match() are not supported. Member
+// names written without brackets may contain - and $, as in x-internal and
+// $ref, which RFC 9535 reserves to the bracket form.
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+// Node is a value selected by a query.
+type Node struct {
+	Value any
+	// Location holds the member names (string) and array indices (int)
+	// leading to the value from the root.
+	Location []any
+}
+
+// Pointer returns the JSON pointer of the node, such as /paths/~1pets/get.
+func (n Node) Pointer() string {
+	ptr := ""
+	for _, key := range n.Location {
+		switch key := key.(type) {
+		case string:
+			ptr = specdoc.Pointer(ptr, key)
+		case int:
+			ptr += "/" + strconv.Itoa(key)
+		}
+	}
+	return ptr
+}
+
+// Query is a parsed JSONPath query.
+type Query struct {
+	// relative tells whether the query starts at @, the current node of a
+	// filter, rather than at $.
+	relative bool
+	segments []segment
+}
+
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+// selector appends the children of a node it selects to out.
+type selector interface {
+	apply(n Node, root any, out []Node) []Node
+}
+
+// Parse parses a JSONPath query.
+func Parse(s string) (*Query, error) {
+	p := &parser{s: s}
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("jsonpath %q: query must start with $", s)
+	}
+	q, err := p.query()
+	if err == nil && p.i < len(s) {
+		err = p.errorf("unexpected %q", s[p.i:])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jsonpath %q: %w", s, err)
+	}
+	return q, nil
+}
+
+// Select returns the nodes of root the query selects, in document order.
+func (q *Query) Select(root any) []Node {
+	return q.selectFrom(Node{Value: root}, root)
+}
+
+func (q *Query) selectFrom(start Node, root any) []Node {
+	nodes := []Node{start}
+	for _, seg := range q.segments {
+		var next []Node
+		for _, n := range nodes {
+			inputs := []Node{n}
+			if seg.descendant {
+				inputs = descendants(n, nil)
+			}
+			for _, in := range inputs {
+				for _, sel := range seg.selectors {
+					next = sel.apply(in, root, next)
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// descendants appends n and the values within it to out, parents first.
+func descendants(n Node, out []Node) []Node {
+	out = append(out, n)
+	for _, c := range children(n) {
+		out = descendants(c, out)
+	}
+	return out
+}
+
+// children returns the members or elements of n.
+func children(n Node) []Node {
+	var out []Node
+	switch v := n.Value.(type) {
+	case *specdoc.Object:
+		for _, key := range v.Keys() {
+			out = append(out, Node{Value: v.Get(key), Location: child(n.Location, key)})
+		}
+	case []any:
+		for i, elem := range v {
+			out = append(out, Node{Value: elem, Location: child(n.Location, i)})
+		}
+	}
+	return out
+}
+
+// child returns the location of a member or element of the node at loc.
+func child(loc []any, key any) []any {
+	return append(loc[:len(loc):len(loc)], key)
+}
+
+type nameSelector string
+
+func (s nameSelector) apply(n Node, _ any, out []Node) []Node {
+	if o, ok := n.Value.(*specdoc.Object); ok && o.Has(string(s)) {
+		out = append(out, Node{Value: o.Get(string(s)), Location: child(n.Location, string(s))})
+	}
+	return out
+}
+
+type wildcardSelector struct{}
+
+func (wildcardSelector) apply(n Node, _ any, out []Node) []Node {
+	return append(out, children(n)...)
+}
+
+type indexSelector int
+
+func (s indexSelector) apply(n Node, _ any, out []Node) []Node {
+	list, ok := n.Value.([]any)
+	if !ok {
+		return out
+	}
+	i := int(s)
+	if i < 0 {
+		i += len(list)
+	}
+	if i < 0 || i >= len(list) {
+		return out
+	}
+	return append(out, Node{Value: list[i], Location: child(n.Location, i)})
+}
+
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+func (s sliceSelector) apply(n Node, _ any, out []Node) []Node {
+	list, ok := n.Value.([]any)
+	if !ok || s.step == 0 {
+		return out
+	}
+	length := len(list)
+	bound := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += length
+		}
+		return i
+	}
+	if s.step > 0 {
+		lower := max(min(bound(s.start, 0), length), 0)
+		upper := max(min(bound(s.end, length), length), 0)
+		for i := lower; i < upper; i += s.step {
+			out = append(out, Node{Value: list[i], Location: child(n.Location, i)})
+		}
+		return out
+	}
+	upper := max(min(bound(s.start, length-1), length-1), -1)
+	lower := max(min(bound(s.end, -length-1), length-1), -1)
+	for i := upper; i > lower; i += s.step {
+		out = append(out, Node{Value: list[i], Location: child(n.Location, i)})
+	}
+	return out
+}
+
+type filterSelector struct {
+	expr expr
+}
+
+func (s filterSelector) apply(n Node, root any, out []Node) []Node {
+	for _, c := range children(n) {
+		if s.expr.eval(c, root) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// expr is a logical expression of a filter.
+type expr interface {
+	eval(current Node, root any) bool
+}
+
+type orExpr []expr
+
+func (e orExpr) eval(current Node, root any) bool {
+	for _, x := range e {
+		if x.eval(current, root) {
+			return true
+		}
+	}
+	return false
+}
+
+type andExpr []expr
+
+func (e andExpr) eval(current Node, root any) bool {
+	for _, x := range e {
+		if !x.eval(current, root) {
+			return false
+		}
+	}
+	return true
+}
+
+type notExpr struct {
+	expr expr
+}
+
+func (e notExpr) eval(current Node, root any) bool {
+	return !e.expr.eval(current, root)
+}
+
+// testExpr is true if its query selects a node.
+type testExpr struct {
+	query *Query
+}
+
+func (e testExpr) eval(current Node, root any) bool {
+	return len(e.query.run(current, root)) > 0
+}
+
+type comparisonExpr struct {
+	op          string
+	left, right operand
+}
+
+func (e comparisonExpr) eval(current Node, root any) bool {
+	a, aok := e.left.value(current, root)
+	b, bok := e.right.value(current, root)
+	switch e.op {
+	case "==":
+		return equal(a, aok, b, bok)
+	case "!=":
+		return !equal(a, aok, b, bok)
+	case "<":
+		return less(a, aok, b, bok)
+	case ">":
+		return less(b, bok, a, aok)
+	case "<=":
+		return less(a, aok, b, bok) || equal(a, aok, b, bok)
+	default: // ">="
+		return less(b, bok, a, aok) || equal(a, aok, b, bok)
+	}
+}
+
+// operand is a side of a comparison. value returns false for Nothing: a
+// query that selects no node, or more than one.
+type operand interface {
+	value(current Node, root any) (any, bool)
+}
+
+type literal struct {
+	v any
+}
+
+func (l literal) value(Node, any) (any, bool) {
+	return l.v, true
+}
+
+func (q *Query) value(current Node, root any) (any, bool) {
+	nodes := q.run(current, root)
+	if len(nodes) != 1 {
+		return nil, false
+	}
+	return nodes[0].Value, true
+}
+
+// run evaluates a query of a filter.
+func (q *Query) run(current Node, root any) []Node {
+	if q.relative {
+		return q.selectFrom(current, root)
+	}
+	return q.Select(root)
+}
+
+func equal(a any, aok bool, b any, bok bool) bool {
+	if !aok || !bok {
+		return aok == bok
+	}
+	switch a := a.(type) {
+	case json.Number:
+		bn, ok := b.(json.Number)
+		return ok && numberCompare(a, bn) == 0
+	case *specdoc.Object:
+		bo, ok := b.(*specdoc.Object)
+		if !ok || a.Len() != bo.Len() {
+			return false
+		}
+		for _, key := range a.Keys() {
+			if !bo.Has(key) || !equal(a.Get(key), true, bo.Get(key), true) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bl, ok := b.([]any)
+		if !ok || len(a) != len(bl) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], true, bl[i], true) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func less(a any, aok bool, b any, bok bool) bool {
+	if !aok || !bok {
+		return false
+	}
+	switch a := a.(type) {
+	case json.Number:
+		bn, ok := b.(json.Number)
+		return ok && numberCompare(a, bn) < 0
+	case string:
+		bs, ok := b.(string)
+		return ok && a < bs
+	}
+	return false
+}
+
+func numberCompare(a, b json.Number) int {
+	x, errX := a.Float64()
+	y, errY := b.Float64()
+	switch {
+	case errX != nil || errY != nil:
+		return strings.Compare(a.String(), b.String())
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+type parser struct {
+	s string
+	i int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at %d: %s", p.i, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+func (p *parser) skipSpace() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\n\r", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// query parses a query starting with $ or @, and its segments.
+func (p *parser) query() (*Query, error) {
+	q := &Query{relative: p.peek() == '@'}
+	p.i++
+	for {
+		var seg segment
+		switch {
+		case strings.HasPrefix(p.s[p.i:], ".."):
+			p.i += 2
+			seg.descendant = true
+			if p.peek() == '[' {
+				sels, err := p.bracket()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = sels
+			} else {
+				sel, err := p.dotSelector()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = []selector{sel}
+			}
+		case p.peek() == '.':
+			p.i++
+			sel, err := p.dotSelector()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = []selector{sel}
+		case p.peek() == '[':
+			sels, err := p.bracket()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = sels
+		default:
+			return q, nil
+		}
+		q.segments = append(q.segments, seg)
+	}
+}
+
+// dotSelector parses the wildcard or member name after a dot.
+func (p *parser) dotSelector() (selector, error) {
+	if p.peek() == '*' {
+		p.i++
+		return wildcardSelector{}, nil
+	}
+	start := p.i
+	for p.i < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.i:])
+		if !isNameRune(r, p.i == start) {
+			break
+		}
+		p.i += size
+	}
+	if p.i == start {
+		return nil, p.errorf("member name expected")
+	}
+	return nameSelector(p.s[start:p.i]), nil
+}
+
+func isNameRune(r rune, first bool) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == '$', r >= 0x80:
+		return true
+	case r >= '0' && r <= '9', r == '-':
+		return !first
+	}
+	return false
+}
+
+// bracket parses the selectors between brackets.
+func (p *parser) bracket() ([]selector, error) {
+	p.i++ // [
+	var sels []selector
+	for {
+		p.skipSpace()
+		sel, err := p.selector()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.i++
+		case ']':
+			p.i++
+			return sels, nil
+		default:
+			return nil, p.errorf("] expected")
+		}
+	}
+}
+
+func (p *parser) selector() (selector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		s, err := p.stringLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return nameSelector(s), nil
+	case c == '*':
+		p.i++
+		return wildcardSelector{}, nil
+	case c == '?':
+		p.i++
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return filterSelector{expr: e}, nil
+	case c == '-' || c == ':' || c >= '0' && c <= '9':
+		return p.indexOrSlice()
+	}
+	return nil, p.errorf("selector expected")
+}
+
+func (p *parser) indexOrSlice() (selector, error) {
+	var parts [3]*int
+	n := 0
+	for {
+		p.skipSpace()
+		if c := p.peek(); c == '-' || c >= '0' && c <= '9' {
+			i, err := p.integer()
+			if err != nil {
+				return nil, err
+			}
+			parts[n] = &i
+		}
+		p.skipSpace()
+		if p.peek() != ':' || n == 2 {
+			break
+		}
+		p.i++
+		n++
+	}
+	if n == 0 {
+		if parts[0] == nil {
+			return nil, p.errorf("index expected")
+		}
+		return indexSelector(*parts[0]), nil
+	}
+	step := 1
+	if parts[2] != nil {
+		step = *parts[2]
+	}
+	return sliceSelector{start: parts[0], end: parts[1], step: step}, nil
+}
+
+func (p *parser) integer() (int, error) {
+	start := p.i
+	if p.peek() == '-' {
+		p.i++
+	}
+	for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+		p.i++
+	}
+	i, err := strconv.Atoi(p.s[start:p.i])
+	if err != nil {
+		return 0, p.errorf("invalid integer %q", p.s[start:p.i])
+	}
+	return i, nil
+}
+
+// stringLiteral parses a string in single or double quotes.
+func (p *parser) stringLiteral() (string, error) {
+	quote := p.s[p.i]
+	p.i++
+	var b strings.Builder
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		switch {
+		case c == quote:
+			p.i++
+			return b.String(), nil
+		case c == '\\' && p.i+1 < len(p.s):
+			p.i++
+			switch e := p.s[p.i]; e {
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if p.i+5 > len(p.s) {
+					return "", p.errorf("invalid escape")
+				}
+				r, err := strconv.ParseUint(p.s[p.i+1:p.i+5], 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid escape")
+				}
+				b.WriteRune(rune(r))
+				p.i += 4
+			default:
+				b.WriteByte(e)
+			}
+			p.i++
+		default:
+			b.WriteByte(c)
+			p.i++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) or() (expr, error) {
+	var list orExpr
+	for {
+		e, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		p.skipSpace()
+		if !strings.HasPrefix(p.s[p.i:], "||") {
+			break
+		}
+		p.i += 2
+	}
+	if len(list) == 1 {
+		return list[0], nil
+	}
+	return list, nil
+}
+
+func (p *parser) and() (expr, error) {
+	var list andExpr
+	for {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		p.skipSpace()
+		if !strings.HasPrefix(p.s[p.i:], "&&") {
+			break
+		}
+		p.i += 2
+	}
+	if len(list) == 1 {
+		return list[0], nil
+	}
+	return list, nil
+}
+
+func (p *parser) unary() (expr, error) {
+	p.skipSpace()
+	switch p.peek() {
+	case '!':
+		p.i++
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr: e}, nil
+	case '(':
+		p.i++
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.peek() != ')' {
+			return nil, p.errorf(") expected")
+		}
+		p.i++
+		return e, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	op := ""
+	for _, o := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(p.s[p.i:], o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		q, ok := left.(*Query)
+		if !ok {
+			return nil, p.errorf("comparison expected")
+		}
+		return testExpr{query: q}, nil
+	}
+	p.i += len(op)
+	p.skipSpace()
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return comparisonExpr{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) operand() (operand, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		return p.query()
+	case c == '\'' || c == '"':
+		s, err := p.stringLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return literal{v: s}, nil
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.i
+		for p.i < len(p.s) && strings.IndexByte("+-.eE0123456789", p.s[p.i]) >= 0 {
+			p.i++
+		}
+		n := p.s[start:p.i]
+		if _, err := strconv.ParseFloat(n, 64); err != nil {
+			return nil, p.errorf("invalid number %q", n)
+		}
+		return literal{v: json.Number(n)}, nil
+	}
+	for word, v := range map[string]any{"true": true, "false": false, "null": nil} {
+		if strings.HasPrefix(p.s[p.i:], word) {
+			p.i += len(word)
+			return literal{v: v}, nil
+		}
+	}
+	if r, _ := utf8.DecodeRuneInString(p.s[p.i:]); isNameRune(r, true) {
+		return nil, p.errorf("filter functions are not supported")
+	}
+	return nil, p.errorf("operand expected")
+}
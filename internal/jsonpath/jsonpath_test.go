@@ -0,0 +1,129 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/plexusone/ogen-tools/internal/specdoc"
+)
+
+const testDoc = `{
+  "paths": {
+    "/pets": {
+      "get": {"operationId": "listPets", "tags": ["pets"], "x-internal": false},
+      "post": {"operationId": "createPet", "tags": ["pets", "admin"], "x-internal": true}
+    },
+    "/users": {
+      "get": {"operationId": "listUsers", "tags": ["users"], "parameters": [{"name": "limit", "schema": {"maximum": 100}}]}
+    }
+  },
+  "list": [0, 1, 2, 3, 4]
+}`
+
+func TestSelect(t *testing.T) {
+	doc, err := specdoc.Parse([]byte(testDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string // locations
+	}{
+		{`$`, []string{``}},
+		{`$.paths['/pets'].get`, []string{`/paths/~1pets/get`}},
+		{`$["paths"]["/users"]`, []string{`/paths/~1users`}},
+		{`$.paths['\u002fpets']`, []string{`/paths/~1pets`}},
+		{`$.paths.*.*.operationId`, []string{
+			`/paths/~1pets/get/operationId`,
+			`/paths/~1pets/post/operationId`,
+			`/paths/~1users/get/operationId`,
+		}},
+		{`$.paths.*[?@.x-internal == true]`, []string{`/paths/~1pets/post`}},
+		{`$.paths.*[?@.x-internal]`, []string{`/paths/~1pets/get`, `/paths/~1pets/post`}},
+		{`$.paths.*[?!@.x-internal]`, []string{`/paths/~1users/get`}},
+		{`$.paths.*[?@.tags[0] == 'pets' && @.tags[1] == "admin"]`, []string{`/paths/~1pets/post`}},
+		{`$.paths.*[?(@.operationId == 'listUsers' || @.operationId == 'createPet')].operationId`, []string{
+			`/paths/~1pets/post/operationId`,
+			`/paths/~1users/get/operationId`,
+		}},
+		{`$..parameters[?@.schema.maximum >= 100].name`, []string{`/paths/~1users/get/parameters/0/name`}},
+		{`$..parameters[?@.schema.maximum < 100]`, nil},
+		{`$..[?@.operationId == $.paths['/users'].get.operationId]`, []string{`/paths/~1users/get`}},
+		{`$..x-internal`, []string{`/paths/~1pets/get/x-internal`, `/paths/~1pets/post/x-internal`}},
+		{`$.list[1, -1]`, []string{`/list/1`, `/list/4`}},
+		{`$.list[1:3]`, []string{`/list/1`, `/list/2`}},
+		{`$.list[::-2]`, []string{`/list/4`, `/list/2`, `/list/0`}},
+		{`$.list[?@ > 2]`, []string{`/list/3`, `/list/4`}},
+		{`$.list[9]`, nil},
+		{`$.missing.*`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, n := range q.Select(doc) {
+				got = append(got, n.Pointer())
+				if v, ok := specdoc.Lookup(doc, n.Pointer()); !ok || !reflect.DeepEqual(v, n.Value) {
+					t.Errorf("value at %s = %v, want %v", n.Pointer(), n.Value, v)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		`paths`:             `query must start with $`,
+		`$.`:                `at 2: member name expected`,
+		`$.paths[`:          `at 8: selector expected`,
+		`$.paths['/pets'`:   `at 15: ] expected`,
+		`$.paths['/pets]`:   `at 15: unterminated string`,
+		`$[?length(@) > 1]`: `at 3: filter functions are not supported`,
+		`$[?@.a == ]`:       `at 10: operand expected`,
+		`$[?'a']`:           `at 6: comparison expected`,
+		`$.paths extra`:     `at 7: unexpected " extra"`,
+		`$[?(@.a == 1]`:     `at 12: ) expected`,
+		`$.list[1-]`:        `at 8: ] expected`,
+		`$.list[-]`:         `at 8: invalid integer "-"`,
+		`$[?@.a == 1.2.3]`:  `at 15: invalid number "1.2.3"`,
+		`$.paths['é\n'] x`:  `at 15: unexpected " x"`,
+		`$.paths['\u00g9']`: `at 10: invalid escape`,
+	}
+	for query, want := range tests {
+		_, err := Parse(query)
+		want = fmt.Sprintf("jsonpath %q: %s", query, want)
+		if err == nil || err.Error() != want {
+			t.Errorf("Parse(%q) error = %v, want %s", query, err, want)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	doc, err := specdoc.Parse([]byte(`{"a": {"x": [1, "s", null]}, "b": {"x": [1.0, "s", null]}, "c": {"x": [1]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(doc.Get("a"), true, doc.Get("b"), true) {
+		t.Error("a != b")
+	}
+	if equal(doc.Get("a"), true, doc.Get("c"), true) {
+		t.Error("a == c")
+	}
+	// Nothing equals only Nothing.
+	if !equal(nil, false, nil, false) || equal(nil, false, nil, true) {
+		t.Error("wrong comparison of Nothing")
+	}
+	if less(json.Number("1"), true, "2", true) {
+		t.Error("number < string")
+	}
+}
@@ -0,0 +1,43 @@
+package specdoc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ListFlag is a flag.Value of comma-separated values that may be repeated:
+// -tag a,b -tag c gives [a b c]. Empty values are dropped.
+//
+//	var tags []string
+//	fs.Var((*specdoc.ListFlag)(&tags), "tag", "keep operations with one of these tags")
+type ListFlag []string
+
+// String implements flag.Value.
+func (l *ListFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value.
+func (l *ListFlag) Set(s string) error {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// Glob returns the regexp of a pattern, in which each * matches any
+// characters and is captured, such as /pets/* or schemas/*Event.
+func Glob(pattern string) (*regexp.Regexp, error) {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	re, err := regexp.Compile("^" + strings.Join(parts, "(.*)") + "$")
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
@@ -0,0 +1,47 @@
+package specdoc
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestListFlag(t *testing.T) {
+	var tags []string
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var((*ListFlag)(&tags), "tag", "")
+	if err := fs.Parse([]string{"-tag", "pets, stores", "-tag", "users,,", "-tag", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"pets", "stores", "users"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+	if got := (*ListFlag)(&tags).String(); got != "pets,stores,users" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       []string
+	}{
+		{"/pets/*", "/pets/{id}", []string{"/pets/{id}", "{id}"}},
+		{"/pets/*", "/pets", nil},
+		{"schemas/*Event", "schemas/PetEvent", []string{"schemas/PetEvent", "Pet"}},
+		{"schemas/*Event", "schemas/PetEvents", nil},
+		{"*.v1.*", "api.v1.Pet", []string{"api.v1.Pet", "api", "Pet"}},
+		{"*.v1.*", "apixv1.Pet", nil},
+		{"(a)+", "(a)+", []string{"(a)+"}},
+		{"*", "", []string{"", ""}},
+	}
+	for _, tt := range tests {
+		re, err := Glob(tt.pattern)
+		if err != nil {
+			t.Fatalf("Glob(%q): %v", tt.pattern, err)
+		}
+		if got := re.FindStringSubmatch(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Glob(%q) on %q = %q, want %q", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
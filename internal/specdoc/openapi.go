@@ -0,0 +1,53 @@
+package specdoc
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ItemMaps are the keys of the document root that hold path items: the
+// operations of paths, and of webhooks in OpenAPI 3.1.
+var ItemMaps = []string{"paths", "webhooks"}
+
+// IsMethod reports whether a path item key is an operation, such as get,
+// rather than parameters, servers or an extension.
+func IsMethod(key string) bool {
+	switch key {
+	case "get", "put", "post", "delete", "options", "head", "patch", "trace":
+		return true
+	}
+	return false
+}
+
+// IsComponentSchema reports whether ptr is the pointer of a schema of
+// components/schemas.
+func IsComponentSchema(ptr string) bool {
+	rest, ok := strings.CutPrefix(ptr, "/components/schemas/")
+	return ok && !strings.Contains(rest, "/")
+}
+
+// Pascal joins the words of s, split at characters other than letters and
+// digits, with their first letters in upper case: list_pets gives ListPets.
+func Pascal(s string) string {
+	var b strings.Builder
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		r := []rune(w)
+		b.WriteString(string(unicode.ToUpper(r[0])) + string(r[1:]))
+	}
+	return b.String()
+}
+
+// Normalize returns the form of a name that ogen's Go name for it depends
+// on: pet_list and PetList both become the type PetList, and getPet and
+// get_pet the method GetPet.
+func Normalize(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
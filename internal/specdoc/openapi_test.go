@@ -0,0 +1,51 @@
+package specdoc
+
+import "testing"
+
+func TestIsComponentSchema(t *testing.T) {
+	for ptr, want := range map[string]bool{
+		"/components/schemas/Pet":                 true,
+		"/components/schemas/a~1b":                true,
+		"/components/schemas/Pet/properties/name": false,
+		"/components/schemas":                     false,
+		"/components/responses/Pet":               false,
+		"/paths/~1pets/get":                       false,
+	} {
+		if got := IsComponentSchema(ptr); got != want {
+			t.Errorf("IsComponentSchema(%q) = %v, want %v", ptr, got, want)
+		}
+	}
+}
+
+func TestPascal(t *testing.T) {
+	for s, want := range map[string]string{
+		"list_pets":      "ListPets",
+		"listPets":       "ListPets",
+		"pet-owner.v2":   "PetOwnerV2",
+		"GET /pets/{id}": "GETPetsId",
+		"état civil":     "ÉtatCivil",
+		"__":             "",
+	} {
+		if got := Pascal(s); got != want {
+			t.Errorf("Pascal(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for _, names := range [][]string{
+		{"pet_list", "PetList", "petList", "pet-list"},
+		{"getPet", "GetPet", "get_pet"},
+		{"Pet2", "pet_2"},
+	} {
+		want := Normalize(names[0])
+		for _, name := range names[1:] {
+			if got := Normalize(name); got != want {
+				t.Errorf("Normalize(%q) = %q, want %q as for %q", name, got, want, names[0])
+			}
+		}
+	}
+	if got := Normalize("Pet_List"); got != "petlist" {
+		t.Errorf("Normalize(Pet_List) = %q, want petlist", got)
+	}
+}
@@ -0,0 +1,98 @@
+package specdoc
+
+import "strings"
+
+// Refs returns the JSON pointers of the local $refs in v, and of the schemas
+// of discriminator mappings, which may name a schema without a $ref.
+func Refs(v any) []string {
+	var ptrs []string
+	var scan func(v any)
+	scan = func(v any) {
+		switch v := v.(type) {
+		case *Object:
+			for _, key := range v.Keys() {
+				if ref, ok := v.Get(key).(string); ok && key == "$ref" && strings.HasPrefix(ref, "#/") {
+					ptrs = append(ptrs, ref[1:])
+				}
+				if discriminator, ok := v.Get(key).(*Object); ok && key == "discriminator" {
+					mapping, _ := discriminator.Get("mapping").(*Object)
+					for _, value := range mapping.Keys() {
+						ref, _ := mapping.Get(value).(string)
+						if strings.HasPrefix(ref, "#/") {
+							ptrs = append(ptrs, ref[1:])
+						} else if ref != "" {
+							ptrs = append(ptrs, Pointer("/components/schemas", ref))
+						}
+					}
+				}
+				scan(v.Get(key))
+			}
+		case []any:
+			for _, elem := range v {
+				scan(elem)
+			}
+		}
+	}
+	scan(v)
+	return ptrs
+}
+
+// Reachable returns the pointers of the components of doc that ptrs point
+// into, and of the components those reference in turn, directly or through
+// other components. A pointer into a component, such as
+// /components/schemas/Pet/properties/owner, counts as the whole component,
+// /components/schemas/Pet. Pointers outside components are kept as they are.
+func Reachable(doc *Object, ptrs []string) map[string]bool {
+	used := make(map[string]bool)
+	pending := ptrs
+	for len(pending) > 0 {
+		ptr := component(pending[0])
+		pending = pending[1:]
+		if used[ptr] {
+			continue
+		}
+		used[ptr] = true
+		if v, ok := Lookup(doc, ptr); ok {
+			pending = append(pending, Refs(v)...)
+		}
+	}
+	return used
+}
+
+// component returns the pointer of the component ptr is in, or ptr itself.
+func component(ptr string) string {
+	parts := strings.SplitN(ptr, "/", 5)
+	if len(parts) >= 4 && parts[1] == "components" {
+		return strings.Join(parts[:4], "/")
+	}
+	return ptr
+}
+
+// SecuritySchemes returns the pointers of the security schemes that the
+// requirements of doc name, at the root and of its operations, such as
+// /components/securitySchemes/apiKey.
+func SecuritySchemes(doc *Object) []string {
+	var ptrs []string
+	add := func(v any) {
+		list, _ := v.([]any)
+		for _, req := range list {
+			o, _ := req.(*Object)
+			for _, name := range o.Keys() {
+				ptrs = append(ptrs, Pointer("/components/securitySchemes", name))
+			}
+		}
+	}
+	add(doc.Get("security"))
+	for _, key := range ItemMaps {
+		items, _ := doc.Get(key).(*Object)
+		for _, name := range items.Keys() {
+			item, _ := items.Get(name).(*Object)
+			for _, method := range item.Keys() {
+				if op, ok := item.Get(method).(*Object); ok && IsMethod(method) {
+					add(op.Get("security"))
+				}
+			}
+		}
+	}
+	return ptrs
+}
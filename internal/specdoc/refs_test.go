@@ -0,0 +1,109 @@
+package specdoc
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+const refsSpec = `{
+  "security": [{"apiKey": []}],
+  "paths": {
+    "/pets": {
+      "get": {
+        "security": [{"oauth": ["read"]}, {}],
+        "responses": {"200": {"$ref": "#/components/responses/Pets"}}
+      },
+      "parameters": [{"$ref": "#/components/parameters/Limit"}]
+    }
+  },
+  "webhooks": {
+    "newPet": {"post": {"security": [{"hook": []}], "requestBody": {"$ref": "#/components/requestBodies/Pet"}}}
+  },
+  "components": {
+    "responses": {
+      "Pets": {"content": {"application/json": {"schema": {"items": {"$ref": "#/components/schemas/Pet/properties/owner"}}}}}
+    },
+    "parameters": {"Limit": {"schema": {"type": "integer"}}},
+    "schemas": {
+      "Pet": {
+        "properties": {"owner": {"$ref": "#/components/schemas/Owner"}},
+        "discriminator": {"mapping": {"cat": "Cat", "dog": "#/components/schemas/Dog", "none": ""}}
+      },
+      "Owner": {"properties": {"pet": {"$ref": "#/components/schemas/Pet"}}},
+      "Cat": {"type": "object"},
+      "Dog": {"type": "object"},
+      "Unused": {"$ref": "#/components/schemas/Owner"},
+      "Remote": {"$ref": "other.json#/components/schemas/Pet"}
+    }
+  }
+}`
+
+func TestRefs(t *testing.T) {
+	spec, err := Parse([]byte(refsSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas, _ := Lookup(spec, "/components/schemas")
+	want := []string{
+		"/components/schemas/Owner",
+		"/components/schemas/Cat",
+		"/components/schemas/Dog",
+		"/components/schemas/Pet",
+		"/components/schemas/Owner",
+	}
+	if got := Refs(schemas); !reflect.DeepEqual(got, want) {
+		t.Errorf("Refs(schemas) = %q, want %q", got, want)
+	}
+	if got := Refs("#/components/schemas/Pet"); got != nil {
+		t.Errorf("Refs(string) = %q, want none", got)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	spec, err := Parse([]byte(refsSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths, _ := Lookup(spec, "/paths")
+	got := Reachable(spec, append(Refs(paths), "/components/schemas/Missing", "/paths/~1pets"))
+	var used []string
+	for ptr := range got {
+		used = append(used, ptr)
+	}
+	slices.Sort(used)
+	want := []string{
+		"/components/parameters/Limit",
+		"/components/responses/Pets",
+		"/components/schemas/Cat",
+		"/components/schemas/Dog",
+		"/components/schemas/Missing",
+		"/components/schemas/Owner",
+		"/components/schemas/Pet",
+		"/paths/~1pets",
+	}
+	if !reflect.DeepEqual(used, want) {
+		t.Errorf("Reachable() = %q, want %q", used, want)
+	}
+	if got := Reachable(spec, nil); len(got) != 0 {
+		t.Errorf("Reachable(nil) = %v, want none", got)
+	}
+}
+
+func TestSecuritySchemes(t *testing.T) {
+	spec, err := Parse([]byte(refsSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/components/securitySchemes/apiKey",
+		"/components/securitySchemes/oauth",
+		"/components/securitySchemes/hook",
+	}
+	if got := SecuritySchemes(spec); !reflect.DeepEqual(got, want) {
+		t.Errorf("SecuritySchemes() = %q, want %q", got, want)
+	}
+	if got := SecuritySchemes(NewObject()); got != nil {
+		t.Errorf("SecuritySchemes(empty) = %q, want none", got)
+	}
+}
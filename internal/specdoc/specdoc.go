@@ -0,0 +1,505 @@
+// Package specdoc reads OpenAPI documents in JSON or YAML, and writes them
+// in JSON.
+//
+// Tools that rewrite a spec must keep the order of its object keys: ogen
+// generates struct fields, and encodes them, in the order of the properties.
+// Decoding into map[string]any would sort them. Documents are decoded into
+// *Object for objects, []any for arrays, json.Number for numbers, and
+// string, bool or nil for the rest.
+package specdoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Object is a JSON object that keeps its keys in document order.
+type Object struct {
+	keys   []string
+	values map[string]any
+}
+
+// NewObject returns an empty object.
+func NewObject() *Object {
+	return &Object{values: make(map[string]any)}
+}
+
+// Keys returns the keys of o in order. Keys, Len, Get and Has treat a nil
+// *Object as empty, so that missing objects of a spec need no checks.
+func (o *Object) Keys() []string {
+	if o == nil {
+		return nil
+	}
+	return o.keys
+}
+
+// Len returns the number of keys of o.
+func (o *Object) Len() int {
+	return len(o.Keys())
+}
+
+// Get returns the value of key, or nil if o has no key.
+func (o *Object) Get(key string) any {
+	if o == nil {
+		return nil
+	}
+	return o.values[key]
+}
+
+// Has reports whether o has key, which may be null.
+func (o *Object) Has(key string) bool {
+	if o == nil {
+		return false
+	}
+	_, ok := o.values[key]
+	return ok
+}
+
+// Set sets the value of key. A new key is added last.
+func (o *Object) Set(key string, v any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+// Delete removes key from o.
+func (o *Object) Delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Replace replaces the key old of o by key, with the value v, at the same
+// position. It does nothing if o has no key old.
+func (o *Object) Replace(old, key string, v any) {
+	if _, ok := o.values[old]; !ok {
+		return
+	}
+	if key != old {
+		o.Delete(key)
+	}
+	for i, k := range o.keys {
+		if k == old {
+			o.keys[i] = key
+		}
+	}
+	delete(o.values, old)
+	o.values[key] = v
+}
+
+// MarshalJSON implements json.Marshaler, keeping the order of the keys.
+func (o *Object) MarshalJSON() ([]byte, error) {
+	return Encode(o)
+}
+
+// ReadFile reads the JSON or YAML document in path. Its root must be an
+// object.
+func ReadFile(path string) (*Object, error) {
+	data, err := os.ReadFile(path) // #nosec G703 -- CLI tool, filename from trusted args
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	doc, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// ReadFileMap reads the document in path like ReadFile, decoded by
+// encoding/json into map[string]any, for tools that only look up values.
+func ReadFileMap(path string) (map[string]any, error) {
+	doc, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := Encode(doc)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// WriteFile writes v to path as indented JSON.
+func WriteFile(path string, v any) error {
+	data, err := Encode(v)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil { // #nosec G703 -- CLI tool, filename from trusted args
+		return fmt.Errorf("write file: %w", err)
+	}
+	return nil
+}
+
+// Parse parses a JSON or YAML document. Its root must be an object. A
+// document starting with "{" is JSON, so that numbers keep their text.
+func Parse(data []byte) (*Object, error) {
+	var v any
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		v, err = parseJSON(data)
+	} else {
+		v, err = parseYAML(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := v.(*Object)
+	if !ok {
+		return nil, fmt.Errorf("document is not an object")
+	}
+	return doc, nil
+}
+
+func parseJSON(data []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	v, err := decodeValue(d)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the document")
+	}
+	return v, nil
+}
+
+func decodeValue(d *json.Decoder) (any, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		o := NewObject()
+		for d.More() {
+			key, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeValue(d)
+			if err != nil {
+				return nil, err
+			}
+			o.Set(key.(string), v)
+		}
+		_, err := d.Token()
+		return o, err
+	case json.Delim('['):
+		arr := []any{}
+		for d.More() {
+			v, err := decodeValue(d)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err := d.Token()
+		return arr, err
+	}
+	return tok, nil
+}
+
+// Encode returns v as JSON indented by two spaces, with a trailing newline.
+// Unlike encoding/json, it leaves <, > and & unescaped.
+func Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, v, "\n"); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// Compact returns v as JSON on one line, for messages.
+func Compact(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func encodeValue(buf *bytes.Buffer, v any, newline string) error {
+	inner := newline + "  "
+	switch v := v.(type) {
+	case *Object:
+		if v.Len() == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteByte('{')
+		for i, key := range v.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(inner)
+			if err := encodeScalar(buf, key); err != nil {
+				return err
+			}
+			buf.WriteString(": ")
+			if err := encodeValue(buf, v.values[key], inner); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(newline)
+		buf.WriteByte('}')
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(inner)
+			if err := encodeValue(buf, elem, inner); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(newline)
+		buf.WriteByte(']')
+	default:
+		return encodeScalar(buf, v)
+	}
+	return nil
+}
+
+func encodeScalar(buf *bytes.Buffer, v any) error {
+	var out bytes.Buffer
+	e := json.NewEncoder(&out)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return err
+	}
+	buf.WriteString(strings.TrimSuffix(out.String(), "\n"))
+	return nil
+}
+
+// dataKeys hold values rather than spec objects: Walk doesn't enter them.
+var dataKeys = map[string]bool{
+	"example":  true,
+	"examples": true,
+	"default":  true,
+	"enum":     true,
+	"const":    true,
+}
+
+// nameKeys hold maps from names to spec objects, such as the properties of
+// a schema or the paths of a document. Their keys are not keywords: a
+// property named "default" is entered like any other.
+var nameKeys = map[string]bool{
+	"properties":        true,
+	"patternProperties": true,
+	"dependentSchemas":  true,
+	"$defs":             true,
+	"definitions":       true,
+	"paths":             true,
+	"webhooks":          true,
+	"callbacks":         true,
+	"schemas":           true,
+	"responses":         true,
+	"parameters":        true,
+	"requestBodies":     true,
+	"headers":           true,
+	"securitySchemes":   true,
+	"links":             true,
+	"pathItems":         true,
+	"content":           true,
+	"encoding":          true,
+	"variables":         true,
+}
+
+// SkipChildren is returned by a Walk function to not enter the values of
+// the object it was called for.
+var SkipChildren = errors.New("skip children")
+
+// Walk calls fn for every object of the document v that holds OpenAPI or
+// JSON Schema keywords, parents first, with its JSON pointer from the root
+// ("" for the root itself). fn may change the object; Walk then enters its
+// new values. Examples, defaults, enums, consts and x- extensions are data,
+// and are not entered.
+func Walk(v any, fn func(o *Object, ptr string) error) error {
+	return walk(v, "", false, fn)
+}
+
+func walk(v any, ptr string, names bool, fn func(o *Object, ptr string) error) error {
+	switch v := v.(type) {
+	case *Object:
+		if !names {
+			if err := fn(v, ptr); err == SkipChildren {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+		for _, key := range v.Keys() {
+			if !names && (dataKeys[key] || strings.HasPrefix(key, "x-")) {
+				continue
+			}
+			if err := walk(v.Get(key), Pointer(ptr, key), !names && nameKeys[key], fn); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for i, elem := range v {
+			if err := walk(elem, Pointer(ptr, strconv.Itoa(i)), false, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Lookup returns the value at the JSON pointer ptr of v, such as
+// "/components/schemas/Pet". The empty pointer is v itself.
+func Lookup(v any, ptr string) (any, bool) {
+	if ptr == "" {
+		return v, true
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, false
+	}
+	for _, part := range strings.Split(ptr[1:], "/") {
+		part = Unescape(part)
+		switch c := v.(type) {
+		case *Object:
+			if !c.Has(part) {
+				return nil, false
+			}
+			v = c.Get(part)
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			v = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// LookupObject returns the object at the JSON pointer ptr of v, and false if
+// there is none or the value is not an object.
+func LookupObject(v any, ptr string) (*Object, bool) {
+	v, _ = Lookup(v, ptr)
+	o, ok := v.(*Object)
+	return o, ok
+}
+
+// Clone returns a deep copy of v.
+func Clone(v any) any {
+	switch v := v.(type) {
+	case *Object:
+		c := NewObject()
+		for _, key := range v.keys {
+			c.Set(key, Clone(v.values[key]))
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, elem := range v {
+			c[i] = Clone(elem)
+		}
+		return c
+	}
+	return v
+}
+
+// schemaKeys hold a schema.
+var schemaKeys = map[string]bool{
+	"schema":                true,
+	"items":                 true,
+	"additionalItems":       true,
+	"additionalProperties":  true,
+	"unevaluatedItems":      true,
+	"unevaluatedProperties": true,
+	"propertyNames":         true,
+	"contains":              true,
+	"not":                   true,
+	"if":                    true,
+	"then":                  true,
+	"else":                  true,
+}
+
+// schemaListKeys hold lists or maps of schemas.
+var schemaListKeys = map[string]bool{
+	"allOf":             true,
+	"anyOf":             true,
+	"oneOf":             true,
+	"prefixItems":       true,
+	"properties":        true,
+	"patternProperties": true,
+	"dependentSchemas":  true,
+	"$defs":             true,
+	"definitions":       true,
+	"schemas":           true,
+}
+
+// IsSchemaPointer reports whether the object at ptr is a schema, from the
+// keys leading to it. root tells whether the object at the empty pointer is
+// a schema.
+func IsSchemaPointer(ptr string, root bool) bool {
+	if ptr == "" {
+		return root
+	}
+	parts := strings.Split(ptr[1:], "/")
+	last := parts[len(parts)-1]
+	if schemaKeys[last] {
+		return true
+	}
+	return len(parts) >= 2 && schemaListKeys[parts[len(parts)-2]]
+}
+
+// Pointer appends key to the JSON pointer ptr, escaping ~ and /.
+func Pointer(ptr, key string) string {
+	return ptr + "/" + Escape(key)
+}
+
+// Escape returns key as a JSON pointer segment, with ~ and / escaped.
+func Escape(key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	return strings.ReplaceAll(key, "/", "~1")
+}
+
+// Unescape returns the key a JSON pointer segment escapes.
+func Unescape(segment string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+}
+
+// SplitPointer returns the unescaped keys of the JSON pointer ptr.
+func SplitPointer(ptr string) []string {
+	if ptr == "" {
+		return nil
+	}
+	parts := strings.Split(ptr[1:], "/")
+	for i, part := range parts {
+		parts[i] = Unescape(part)
+	}
+	return parts
+}
+
+// Parent returns the JSON pointer of the container of the value at ptr.
+func Parent(ptr string) string {
+	return ptr[:strings.LastIndex(ptr, "/")]
+}
@@ -0,0 +1,352 @@
+package specdoc
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEncode(t *testing.T) {
+	// Keys stay in document order, numbers keep their text, and HTML
+	// characters stay unescaped.
+	const doc = `{
+  "zeta": 1.50,
+  "alpha": {
+    "b": [
+      true,
+      null,
+      "<a & b>"
+    ],
+    "a": {}
+  },
+  "empty": []
+}
+`
+	v, err := Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v.Keys(), []string{"zeta", "alpha", "empty"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %q, want %q", got, want)
+	}
+	out, err := Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != doc {
+		t.Errorf("Encode() =\n%s\nwant\n%s", out, doc)
+	}
+}
+
+func TestReadWriteFile(t *testing.T) {
+	// The tools read a spec, edit it and write it back: keys stay in the
+	// order of the input, not sorted, and keys added by an edit go last.
+	const doc = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/pets": {
+      "post": {},
+      "get": {}
+    }
+  },
+  "info": {
+    "version": "1",
+    "title": "Pets"
+  }
+}
+`
+	path := filepath.Join(t.TempDir(), "openapi.json")
+	if err := os.WriteFile(path, []byte(doc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.Get("info").(*Object).Set("description", "Pet store")
+	spec.Get("paths").(*Object).Replace("/pets", "/animals", spec.Get("paths").(*Object).Get("/pets"))
+	if err := WriteFile(path, spec); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(strings.Replace(doc, `"/pets"`, `"/animals"`, 1),
+		`"title": "Pets"`, `"title": "Pets",`+"\n"+`    "description": "Pet store"`, 1)
+	if string(got) != want {
+		t.Errorf("WriteFile() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParse_YAML(t *testing.T) {
+	// Keys stay in document order, whatever the type of their YAML scalar,
+	// and aliases and merge keys are expanded.
+	const doc = `openapi: 3.0.3
+paths:
+  /pets:
+    get:
+      responses:
+        200:
+          description: ok
+        default: &error
+          description: error
+          x-retry: true
+    post:
+      responses:
+        default: *error
+components:
+  schemas:
+    Base: &base
+      type: object
+      maxProperties: 0x10
+    Pet:
+      <<: *base
+      description: Pet
+      minimum: 1.50
+      example: 2024-01-02
+      nullable: null
+`
+	v, err := Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"openapi":"3.0.3","paths":{"/pets":{` +
+		`"get":{"responses":{"200":{"description":"ok"},"default":{"description":"error","x-retry":true}}},` +
+		`"post":{"responses":{"default":{"description":"error","x-retry":true}}}}},` +
+		`"components":{"schemas":{"Base":{"type":"object","maxProperties":16},` +
+		`"Pet":{"type":"object","maxProperties":16,"description":"Pet","minimum":1.5,"example":"2024-01-02","nullable":null}}}}`
+	if string(out) != want {
+		t.Errorf("Parse() =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestReadFileMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openapi.yaml")
+	if err := os.WriteFile(path, []byte("servers:\n  - url: https://{region}.example.com\n    variables:\n      region: {default: eu}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := ReadFileMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"servers": []any{map[string]any{
+		"url":       "https://{region}.example.com",
+		"variables": map[string]any{"region": map[string]any{"default": "eu"}},
+	}}}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("ReadFileMap() = %v, want %v", spec, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, doc := range []string{`{"a": }`, `{} {}`, ``, `[]`, `- a`, `a: .inf`, `a: [`, `? [a]: b`} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", doc)
+		}
+	}
+}
+
+func TestObject(t *testing.T) {
+	o := NewObject()
+	o.Set("a", 1)
+	o.Set("b", 2)
+	o.Set("c", 3)
+	o.Set("a", 4)
+	o.Replace("b", "d", 5)
+	o.Replace("c", "a", 6)
+	o.Delete("missing")
+	if got, want := o.Keys(), []string{"d", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %q, want %q", got, want)
+	}
+	if v := o.Get("a"); v != 6 {
+		t.Errorf("Get(a) = %v, want 6", v)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	v, err := Parse([]byte(`{
+  "components": {
+    "schemas": {
+      "Pet": {
+        "properties": {
+          "default": {"type": "string"},
+          "tags": {"items": {"type": "string"}}
+        },
+        "default": {"type": "not a schema"},
+        "x-go": {"type": "not a schema"}
+      }
+    },
+    "examples": {"pet": {"value": {"type": "not a schema"}}}
+  },
+  "paths": {"/pets": {"get": {"parameters": [{"name": "q"}]}}}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	err = Walk(v, func(o *Object, ptr string) error {
+		got = append(got, ptr)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"",
+		"/components",
+		"/components/schemas/Pet",
+		"/components/schemas/Pet/properties/default",
+		"/components/schemas/Pet/properties/tags",
+		"/components/schemas/Pet/properties/tags/items",
+		"/paths/~1pets",
+		"/paths/~1pets/get",
+		"/paths/~1pets/get/parameters/0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk() visited\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestWalkSkipChildren(t *testing.T) {
+	v, err := Parse([]byte(`{"paths": {"/pets": {"get": {}}}, "info": {}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	err = Walk(v, func(o *Object, ptr string) error {
+		got = append(got, ptr)
+		if ptr == "/paths/~1pets" {
+			return SkipChildren
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"", "/paths/~1pets", "/info"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk() visited %q, want %q", got, want)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	v, err := Parse([]byte(`{"paths": {"/pets": {"get": {"tags": ["a", "b"]}}}, "a~b": null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ptr  string
+		want any
+		ok   bool
+	}{
+		{"/paths/~1pets/get/tags/1", "b", true},
+		{"/a~0b", nil, true},
+		{"/paths/~1pets/get/tags/2", nil, false},
+		{"/paths/~1pets/get/tags/x", nil, false},
+		{"/paths/pets", nil, false},
+		{"/a~0b/c", nil, false},
+		{"paths", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := Lookup(v, tt.ptr)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Lookup(%q) = %v, %v, want %v, %v", tt.ptr, got, ok, tt.want, tt.ok)
+		}
+	}
+	if got, ok := Lookup(v, ""); got != v || !ok {
+		t.Errorf("Lookup(\"\") = %v, %v, want the document", got, ok)
+	}
+	if o, ok := LookupObject(v, "/paths/~1pets/get"); !ok || !o.Has("tags") {
+		t.Errorf("LookupObject(get) = %v, %v, want the operation", o, ok)
+	}
+	for _, ptr := range []string{"/paths/~1pets/get/tags", "/a~0b", "/missing"} {
+		if o, ok := LookupObject(v, ptr); o != nil || ok {
+			t.Errorf("LookupObject(%q) = %v, %v, want none", ptr, o, ok)
+		}
+	}
+}
+
+func TestPointers(t *testing.T) {
+	ptr := Pointer(Pointer("/paths", "/pets/{id}"), "a~b")
+	if want := "/paths/~1pets~1{id}/a~0b"; ptr != want {
+		t.Errorf("Pointer() = %q, want %q", ptr, want)
+	}
+	if got, want := SplitPointer(ptr), []string{"paths", "/pets/{id}", "a~b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitPointer(%q) = %q, want %q", ptr, got, want)
+	}
+	if got := SplitPointer(""); got != nil {
+		t.Errorf("SplitPointer(\"\") = %q, want none", got)
+	}
+	if got, want := Parent(ptr), "/paths/~1pets~1{id}"; got != want {
+		t.Errorf("Parent(%q) = %q, want %q", ptr, got, want)
+	}
+	if got, want := Parent("/paths"), ""; got != want {
+		t.Errorf("Parent(/paths) = %q, want %q", got, want)
+	}
+	for _, key := range []string{"~1", "a/b~0c", ""} {
+		if got := Unescape(Escape(key)); got != key {
+			t.Errorf("Unescape(Escape(%q)) = %q", key, got)
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	v, err := Parse([]byte(`{"b": [1, "<x>"], "a": {"c": null}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Compact(v), `{"b":[1,"\u003cx\u003e"],"a":{"c":null}}`; got != want {
+		t.Errorf("Compact() = %s, want %s", got, want)
+	}
+	if got := Compact("a"); got != `"a"` {
+		t.Errorf("Compact(\"a\") = %s", got)
+	}
+}
+
+func TestClone(t *testing.T) {
+	v, err := Parse([]byte(`{"a": {"b": [{"c": 1}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Clone(v).(*Object)
+	c.Get("a").(*Object).Get("b").([]any)[0].(*Object).Set("c", 2)
+
+	for doc, want := range map[*Object]string{v: `{"a":{"b":[{"c":1}]}}`, c: `{"a":{"b":[{"c":2}]}}`} {
+		got, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestIsSchemaPointer(t *testing.T) {
+	for ptr, want := range map[string]bool{
+		"/components/schemas/Pet":                                          true,
+		"/components/schemas/Pet/properties/tags/items":                    true,
+		"/components/schemas/Pet/properties/items":                         true,
+		"/components/schemas/Pet/allOf/0":                                  true,
+		"/paths/~1pets/get/parameters/0/schema":                            true,
+		"/paths/~1pets/get/parameters/0":                                   false,
+		"/paths/~1pets/get/responses/200/content/application~1json":        false,
+		"/paths/~1pets/get/responses/200/content/application~1json/schema": true,
+		"/components/examples/Pet":                                         false,
+	} {
+		if got := IsSchemaPointer(ptr, false); got != want {
+			t.Errorf("IsSchemaPointer(%q) = %v, want %v", ptr, got, want)
+		}
+	}
+	if !IsSchemaPointer("", true) || IsSchemaPointer("", false) {
+		t.Error("IsSchemaPointer(\"\") does not follow root")
+	}
+}
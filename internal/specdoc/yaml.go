@@ -0,0 +1,125 @@
+package specdoc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/go-faster/yaml"
+)
+
+// parseYAML parses a YAML document into the values decodeValue returns for
+// JSON. Mapping keys are taken as strings, so that `200:` in responses is
+// the key "200", and aliases and merge keys are expanded.
+func parseYAML(data []byte) (any, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 {
+		return nil, fmt.Errorf("empty document")
+	}
+	return fromYAML(doc.Content[0])
+}
+
+func fromYAML(n *yaml.Node) (any, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return fromYAML(n.Alias)
+	case yaml.SequenceNode:
+		arr := make([]any, 0, len(n.Content))
+		for _, item := range n.Content {
+			v, err := fromYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, nil
+	case yaml.MappingNode:
+		o := NewObject()
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			for key.Kind == yaml.AliasNode {
+				key = key.Alias
+			}
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: mapping key is not a scalar", key.Line)
+			}
+			v, err := fromYAML(value)
+			if err != nil {
+				return nil, err
+			}
+			if key.ShortTag() == "!!merge" {
+				if err := merge(o, v, key.Line); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			o.Set(key.Value, v)
+		}
+		return o, nil
+	case yaml.ScalarNode:
+		return scalarFromYAML(n)
+	default:
+		return nil, fmt.Errorf("line %d: unexpected YAML node", n.Line)
+	}
+}
+
+// merge adds the keys of the mappings merged with `<<` that o does not have.
+func merge(o *Object, v any, line int) error {
+	switch v := v.(type) {
+	case *Object:
+		for _, key := range v.Keys() {
+			if !o.Has(key) {
+				o.Set(key, v.Get(key))
+			}
+		}
+		return nil
+	case []any:
+		for _, item := range v {
+			if err := merge(o, item, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("line %d: merged value is not a mapping", line)
+	}
+}
+
+func scalarFromYAML(n *yaml.Node) (any, error) {
+	switch n.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	case "!!int", "!!float":
+		var v any
+		if err := n.Decode(&v); err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case int:
+			return json.Number(strconv.Itoa(v)), nil
+		case int64:
+			return json.Number(strconv.FormatInt(v, 10)), nil
+		case uint64:
+			return json.Number(strconv.FormatUint(v, 10)), nil
+		case float64:
+			if math.IsInf(v, 0) || math.IsNaN(v) {
+				return nil, fmt.Errorf("line %d: %s has no JSON form", n.Line, n.Value)
+			}
+			return json.Number(strconv.FormatFloat(v, 'g', -1, 64)), nil
+		}
+		return nil, fmt.Errorf("line %d: unexpected number %s", n.Line, n.Value)
+	default:
+		// Strings, and timestamps and binary data as written.
+		return n.Value, nil
+	}
+}
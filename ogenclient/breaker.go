@@ -0,0 +1,250 @@
+package ogenclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+// BreakerState is the state of a circuit of a Breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets requests through, counting consecutive failures.
+	BreakerClosed BreakerState = iota
+	// BreakerHalfOpen lets one request through, to probe whether the
+	// failures are over.
+	BreakerHalfOpen
+	// BreakerOpen rejects requests with a *CircuitOpenError.
+	BreakerOpen
+)
+
+// String returns "closed", "half-open" or "open".
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// BreakerResult is the result of a request through a Breaker, as reported
+// to Breaker.OnResult.
+type BreakerResult int
+
+const (
+	// BreakerSuccess is a request that got a response other than a failure.
+	BreakerSuccess BreakerResult = iota
+	// BreakerFailure is a request that failed, as Breaker.IsFailure
+	// classifies it.
+	BreakerFailure
+	// BreakerRejected is a request rejected with a *CircuitOpenError.
+	BreakerRejected
+)
+
+// BreakerKey identifies a circuit of a Breaker: requests to a host, for an
+// ogen operation, as ogenerror.WithOperation names it, or "" for requests
+// with none.
+type BreakerKey struct {
+	Host      string
+	Operation string
+}
+
+// CircuitOpenError is returned by a Breaker, without sending the request,
+// while the circuit of the request is open.
+type CircuitOpenError struct {
+	Key BreakerKey
+	// Until is when the circuit lets a probe request through.
+	Until time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	target := e.Key.Host
+	if e.Key.Operation != "" {
+		target = e.Key.Operation + " on " + target
+	}
+	return fmt.Sprintf("ogenclient: circuit open for %s until %s", target, e.Until.Format(time.RFC3339))
+}
+
+// Breaker is an http.RoundTripper with a circuit breaker per host and
+// operation. After Threshold consecutive failures, the circuit opens, and
+// its requests fail fast with a *CircuitOpenError. After Cooldown, it lets
+// one request through: if it succeeds, the circuit closes, and otherwise it
+// opens again.
+//
+// Set it as the Base of a Transport for each attempt to count; the
+// Transport does not retry a *CircuitOpenError. Requests canceled by their
+// context count neither as successes nor as failures.
+type Breaker struct {
+	// Base sends the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Threshold is the number of consecutive failures that opens a
+	// circuit, or 5 if 0.
+	Threshold int
+	// Cooldown is how long a circuit stays open, or 30s if 0.
+	Cooldown time.Duration
+	// IsFailure classifies the result of a request, with err nil if it got
+	// resp. If nil, 5xx responses, timeouts and connection errors, as
+	// ogenerror classifies them, are failures.
+	IsFailure func(resp *http.Response, err error) bool
+
+	// OnStateChange, if set, is called when a circuit changes state.
+	OnStateChange func(key BreakerKey, from, to BreakerState)
+	// OnResult, if set, is called with the result of each request, for
+	// metrics.
+	OnResult func(key BreakerKey, result BreakerResult)
+
+	mu       sync.Mutex
+	circuits map[BreakerKey]*circuit
+}
+
+// circuit is the state of the requests of a BreakerKey.
+type circuit struct {
+	state    BreakerState
+	failures int
+	until    time.Time
+	// probing is set while the request of a half-open circuit is in flight.
+	probing bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (b *Breaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := BreakerKey{Host: req.URL.Host, Operation: ogenerror.Operation(req.Context())}
+	probe, err := b.allow(key)
+	if err != nil {
+		if b.OnResult != nil {
+			b.OnResult(key, BreakerRejected)
+		}
+		return nil, err
+	}
+
+	base := b.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+
+	switch {
+	case err != nil && errors.Is(err, context.Canceled):
+		b.done(key, probe, nil)
+	default:
+		failed := b.isFailure(resp, err)
+		b.done(key, probe, &failed)
+		if b.OnResult != nil {
+			result := BreakerSuccess
+			if failed {
+				result = BreakerFailure
+			}
+			b.OnResult(key, result)
+		}
+	}
+	return resp, err
+}
+
+// State returns the state of the circuit of key.
+func (b *Breaker) State(key BreakerKey) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[key]; ok {
+		return c.state
+	}
+	return BreakerClosed
+}
+
+// allow returns a *CircuitOpenError if the circuit of key rejects a
+// request, and otherwise whether the request is the probe of a half-open
+// circuit.
+func (b *Breaker) allow(key BreakerKey) (probe bool, err error) {
+	b.mu.Lock()
+	c, ok := b.circuits[key]
+	if !ok {
+		if b.circuits == nil {
+			b.circuits = make(map[BreakerKey]*circuit)
+		}
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+
+	var from BreakerState
+	var changed bool
+	if c.state == BreakerOpen && !time.Now().Before(c.until) {
+		from, changed = c.state, true
+		c.state = BreakerHalfOpen
+	}
+	switch {
+	case c.state == BreakerOpen, c.state == BreakerHalfOpen && c.probing:
+		err = &CircuitOpenError{Key: key, Until: c.until}
+	case c.state == BreakerHalfOpen:
+		c.probing = true
+		probe = true
+	}
+	b.mu.Unlock()
+
+	if changed && b.OnStateChange != nil {
+		b.OnStateChange(key, from, BreakerHalfOpen)
+	}
+	return probe, err
+}
+
+// done records the result of a request of the circuit of key, or nil for a
+// canceled one. probe is whether allow let the request through as the probe
+// of a half-open circuit.
+func (b *Breaker) done(key BreakerKey, probe bool, failed *bool) {
+	threshold := b.Threshold
+	if threshold <= 0 {
+		threshold = 5
+	}
+	cooldown := b.Cooldown
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+
+	b.mu.Lock()
+	c := b.circuits[key]
+	from := c.state
+	if probe {
+		c.probing = false
+	}
+	switch {
+	case failed == nil, c.state == BreakerOpen, c.state == BreakerHalfOpen && !probe:
+		// Canceled, or sent before the circuit opened: only the probe
+		// decides the state of a half-open circuit.
+	case !*failed:
+		c.failures = 0
+		c.state = BreakerClosed
+	case c.state == BreakerHalfOpen || c.failures+1 >= threshold:
+		c.failures = 0
+		c.state = BreakerOpen
+		c.until = time.Now().Add(cooldown)
+	default:
+		c.failures++
+	}
+	to := c.state
+	b.mu.Unlock()
+
+	if from != to && b.OnStateChange != nil {
+		b.OnStateChange(key, from, to)
+	}
+}
+
+// isFailure classifies the result of a request with IsFailure, or as a
+// failure if it is a 5xx response, a timeout or a connection error.
+func (b *Breaker) isFailure(resp *http.Response, err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(resp, err)
+	}
+	if err != nil {
+		return ogenerror.IsTimeout(err) || ogenerror.IsConnectionError(err)
+	}
+	return ogenerror.Is5xx(statusError(resp))
+}
@@ -0,0 +1,186 @@
+package ogenclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+func TestBreaker(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+
+	var changes []string
+	results := map[BreakerResult]int{}
+	breaker := &Breaker{
+		Threshold: 3,
+		Cooldown:  50 * time.Millisecond,
+		OnStateChange: func(key BreakerKey, from, to BreakerState) {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", key.Operation, from, to))
+		},
+		OnResult: func(key BreakerKey, result BreakerResult) { results[result]++ },
+	}
+	client := &http.Client{Transport: breaker}
+	get := func(op string) (int, error) {
+		req, _ := http.NewRequestWithContext(ogenerror.WithOperation(context.Background(), op), "GET", srv.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	for range 3 {
+		if code, err := get("listPets"); code != 503 {
+			t.Fatalf("get = %d, %v, want 503", code, err)
+		}
+	}
+	// The circuit is open for listPets, and only for listPets.
+	_, err := get("listPets")
+	var open *CircuitOpenError
+	if !errors.As(err, &open) || open.Key.Operation != "listPets" {
+		t.Fatalf("err = %v, want CircuitOpenError", err)
+	}
+	if code, _ := get("getPet"); code != 503 {
+		t.Errorf("getPet = %d, want 503", code)
+	}
+
+	// After the cooldown, a failed probe opens it again, and a successful
+	// one closes it.
+	time.Sleep(60 * time.Millisecond)
+	if code, _ := get("listPets"); code != 503 {
+		t.Errorf("probe = %d, want 503", code)
+	}
+	if _, err := get("listPets"); !errors.As(err, &open) {
+		t.Errorf("err = %v, want CircuitOpenError", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	status.Store(http.StatusOK)
+	if code, _ := get("listPets"); code != 200 {
+		t.Errorf("probe = %d, want 200", code)
+	}
+	key := BreakerKey{Host: srv.Listener.Addr().String(), Operation: "listPets"}
+	if state := breaker.State(key); state != BreakerClosed {
+		t.Errorf("State = %s, want closed", state)
+	}
+
+	want := []string{
+		"listPets: closed -> open",
+		"listPets: open -> half-open",
+		"listPets: half-open -> open",
+		"listPets: open -> half-open",
+		"listPets: half-open -> closed",
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("state changes = %q, want %q", changes, want)
+	}
+	if want := map[BreakerResult]int{BreakerSuccess: 1, BreakerFailure: 5, BreakerRejected: 2}; !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %v, want %v", results, want)
+	}
+}
+
+func TestBreaker_NotFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	breaker := &Breaker{Threshold: 1}
+	client := &http.Client{Transport: breaker}
+	for range 3 {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	if state := breaker.State(BreakerKey{Host: srv.Listener.Addr().String()}); state != BreakerClosed {
+		t.Errorf("State = %s after 4xx responses, want closed", state)
+	}
+}
+
+func TestBreaker_StaleRequest(t *testing.T) {
+	// Requests to /slow/... block until released, then get their status.
+	type slow struct {
+		entered chan struct{}
+		release chan int
+	}
+	paths := map[string]*slow{
+		"/slow/old":   {make(chan struct{}), make(chan int)},
+		"/slow/probe": {make(chan struct{}), make(chan int)},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := paths[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		close(s.entered)
+		w.WriteHeader(<-s.release)
+	}))
+	defer srv.Close()
+
+	breaker := &Breaker{Threshold: 1, Cooldown: 20 * time.Millisecond}
+	client := &http.Client{Transport: breaker}
+	get := func(path string) (int, error) {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			return 0, err
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	start := func(path string) <-chan int {
+		codes := make(chan int, 1)
+		go func() {
+			code, _ := get(path)
+			codes <- code
+		}()
+		<-paths[path].entered
+		return codes
+	}
+	key := BreakerKey{Host: srv.Listener.Addr().String()}
+
+	// A request sent while the circuit is closed is still in flight when a
+	// failure opens it, and when the probe goes out.
+	old := start("/slow/old")
+	if code, _ := get("/fail"); code != 503 {
+		t.Fatalf("get = %d, want 503", code)
+	}
+	time.Sleep(30 * time.Millisecond)
+	probe := start("/slow/probe")
+
+	// Its success does not close the circuit: only the probe decides.
+	paths["/slow/old"].release <- http.StatusOK
+	if code := <-old; code != 200 {
+		t.Fatalf("old request = %d, want 200", code)
+	}
+	if state := breaker.State(key); state != BreakerHalfOpen {
+		t.Errorf("State = %s after the old request, want half-open", state)
+	}
+	var open *CircuitOpenError
+	if _, err := get("/fail"); !errors.As(err, &open) {
+		t.Errorf("err = %v while probing, want CircuitOpenError", err)
+	}
+
+	paths["/slow/probe"].release <- http.StatusServiceUnavailable
+	if code := <-probe; code != 503 {
+		t.Fatalf("probe = %d, want 503", code)
+	}
+	if state := breaker.State(key); state != BreakerOpen {
+		t.Errorf("State = %s after the failed probe, want open", state)
+	}
+}
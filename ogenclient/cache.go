@@ -0,0 +1,384 @@
+package ogenclient
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStore stores the responses of a Cache, by the URL of their request.
+// Its methods may be called concurrently.
+type CacheStore interface {
+	// Get returns the response stored under key, if any.
+	Get(key string) ([]byte, bool)
+	// Set stores a response under key.
+	Set(key string, response []byte)
+	// Delete removes the response stored under key, if any.
+	Delete(key string)
+}
+
+// Cache is an http.RoundTripper that caches the responses of GET requests,
+// for clients of read-heavy APIs. It is a private cache, for the
+// credentials of a single client.
+//
+// A response is cached if it is a 200 with an ETag, a Last-Modified date
+// or a Cache-Control max-age, and without Cache-Control no-store. It is
+// served from the cache while fresh, per its max-age or Expires; then the
+// request is sent with If-None-Match and If-Modified-Since, and the cached
+// response is served again if the server answers 304 Not Modified.
+//
+// Responses are cached whole, in memory while being stored. A successful
+// request of another method than GET or HEAD removes the cached response
+// of its URL.
+type Cache struct {
+	// Base sends the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Store stores the responses. If nil, they are stored in a MemoryCache.
+	Store CacheStore
+
+	memory MemoryCache
+}
+
+// Headers Cache adds to the responses it stores, and removes from those it
+// serves: when it stored the response, and the values of the request
+// headers the response varies by.
+const (
+	storedHeader = "X-Ogenclient-Stored"
+	varyPrefix   = "X-Ogenclient-Vary-"
+)
+
+// RoundTrip implements http.RoundTripper.
+func (c *Cache) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := c.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	store := c.store()
+	key := req.URL.String()
+
+	if req.Method != http.MethodGet {
+		resp, err := base.RoundTrip(req)
+		if err == nil && req.Method != http.MethodHead && resp.StatusCode < 400 {
+			store.Delete(key)
+		}
+		return resp, err
+	}
+	// Leave requests the caller made conditional or partial to the caller.
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" ||
+		req.Header.Get("Range") != "" || cacheControl(req.Header)["no-store"] {
+		return base.RoundTrip(req)
+	}
+
+	cached := c.lookup(store, key, req)
+	if cached != nil && !cacheControl(req.Header)["no-cache"] && fresh(cached.Header, time.Now()) {
+		delete(cached.Header, storedHeader)
+		return cached, nil
+	}
+
+	outReq := req
+	if cached != nil {
+		etag, modified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+		if etag != "" || modified != "" {
+			outReq = req.Clone(req.Context())
+			if etag != "" {
+				outReq.Header.Set("If-None-Match", etag)
+			}
+			if modified != "" {
+				outReq.Header.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+
+	resp, err := base.RoundTrip(outReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil && outReq != req {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		// The 304 updates the headers of the cached response.
+		for name, values := range resp.Header {
+			if name != "Content-Length" {
+				cached.Header[name] = values
+			}
+		}
+		return c.save(store, key, req, cached)
+	}
+	if !cacheable(resp) {
+		if cached != nil {
+			store.Delete(key)
+		}
+		return resp, nil
+	}
+	return c.save(store, key, req, resp)
+}
+
+// store returns Store, or the MemoryCache of c.
+func (c *Cache) store() CacheStore {
+	if c.Store != nil {
+		return c.Store
+	}
+	return &c.memory
+}
+
+// lookup returns the response cached for req, or nil. Its header keeps
+// when it was stored.
+func (c *Cache) lookup(store CacheStore, key string, req *http.Request) *http.Response {
+	data, ok := store.Get(key)
+	if !ok {
+		return nil
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		store.Delete(key)
+		return nil
+	}
+	// A response varying by request headers is cached for the values of
+	// the request that got it.
+	for name, values := range resp.Header {
+		if vary, ok := strings.CutPrefix(name, varyPrefix); ok {
+			if req.Header.Get(vary) != values[0] {
+				return nil
+			}
+			delete(resp.Header, name)
+		}
+	}
+	return resp
+}
+
+// save reads the body of resp, stores resp under key, and returns it with
+// its body put back.
+func (c *Cache) save(store CacheStore, key string, req *http.Request, resp *http.Response) (*http.Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+
+	stored := *resp
+	stored.Header = resp.Header.Clone()
+	delete(resp.Header, storedHeader)
+	stored.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	stored.Header.Set(storedHeader, time.Now().UTC().Format(http.TimeFormat))
+	for _, vary := range resp.Header.Values("Vary") {
+		for name := range strings.SplitSeq(vary, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				stored.Header.Set(varyPrefix+name, req.Header.Get(name))
+			}
+		}
+	}
+	stored.Body = io.NopCloser(bytes.NewReader(body))
+	data, err := httputil.DumpResponse(&stored, true)
+	if err == nil {
+		store.Set(key, data)
+	}
+	return resp, nil
+}
+
+// cacheable reports whether resp may be cached.
+func cacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK || cacheControl(resp.Header)["no-store"] ||
+		strings.Contains(strings.Join(resp.Header.Values("Vary"), ","), "*") {
+		return false
+	}
+	_, maxAge := maxAge(resp.Header)
+	return resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "" || maxAge
+}
+
+// fresh reports whether a cached response with header may be served
+// without asking the server at now.
+func fresh(header http.Header, now time.Time) bool {
+	if cacheControl(header)["no-cache"] {
+		return false
+	}
+	if age, ok := maxAge(header); ok {
+		stored, err := http.ParseTime(header.Get(storedHeader))
+		return err == nil && now.Sub(stored) < age
+	}
+	expires, err := http.ParseTime(header.Get("Expires"))
+	return err == nil && now.Before(expires)
+}
+
+// maxAge returns the max-age of the Cache-Control header.
+func maxAge(header http.Header) (time.Duration, bool) {
+	for directive := range strings.SplitSeq(strings.Join(header.Values("Cache-Control"), ","), ",") {
+		if value, ok := strings.CutPrefix(strings.ToLower(strings.TrimSpace(directive)), "max-age="); ok {
+			seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+			return time.Duration(seconds) * time.Second, err == nil
+		}
+	}
+	return 0, false
+}
+
+// cacheControl returns the directives of the Cache-Control header, without
+// their values.
+func cacheControl(header http.Header) map[string]bool {
+	directives := make(map[string]bool)
+	for directive := range strings.SplitSeq(strings.Join(header.Values("Cache-Control"), ","), ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		directives[strings.ToLower(name)] = true
+	}
+	return directives
+}
+
+// MemoryCache is a CacheStore in memory. Its zero value is ready to use.
+//
+// It holds at most MaxEntries responses, evicting the least recently used.
+// Setting a response also removes the expired responses Cache stored that
+// it can't revalidate: those without an ETag or a Last-Modified date.
+type MemoryCache struct {
+	// MaxEntries is the number of responses kept, or 1000 if 0.
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// recent holds the *memoryEntry values, most recently used first.
+	recent list.List
+}
+
+// memoryEntry is a response in a MemoryCache.
+type memoryEntry struct {
+	key      string
+	response []byte
+	// expires is when the response can no longer be served from the
+	// cache or revalidated, or zero if never.
+	expires time.Time
+}
+
+// Get implements CacheStore.
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	m.recent.MoveToFront(elem)
+	return elem.Value.(*memoryEntry).response, true
+}
+
+// Set implements CacheStore.
+func (m *MemoryCache) Set(key string, response []byte) {
+	entry := &memoryEntry{key: key, response: response, expires: expiry(response)}
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries == nil {
+		m.entries = make(map[string]*list.Element)
+	}
+	if elem, ok := m.entries[key]; ok {
+		m.remove(elem)
+	}
+	for elem := m.recent.Front(); elem != nil; {
+		next := elem.Next()
+		if expires := elem.Value.(*memoryEntry).expires; !expires.IsZero() && !now.Before(expires) {
+			m.remove(elem)
+		}
+		elem = next
+	}
+
+	m.entries[key] = m.recent.PushFront(entry)
+	maxEntries := m.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	for m.recent.Len() > maxEntries {
+		m.remove(m.recent.Back())
+	}
+}
+
+// Delete implements CacheStore.
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elem, ok := m.entries[key]; ok {
+		m.remove(elem)
+	}
+}
+
+// remove removes the entry of elem. m.mu must be held.
+func (m *MemoryCache) remove(elem *list.Element) {
+	m.recent.Remove(elem)
+	delete(m.entries, elem.Value.(*memoryEntry).key)
+}
+
+// expiry returns when a response stored by Cache stops being fresh, or zero
+// if it can be revalidated, or is not one Cache stored.
+func expiry(response []byte) time.Time {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(response)), nil)
+	if err != nil {
+		return time.Time{}
+	}
+	header := resp.Header
+	if header.Get("ETag") != "" || header.Get("Last-Modified") != "" {
+		return time.Time{}
+	}
+	if age, ok := maxAge(header); ok {
+		if stored, err := http.ParseTime(header.Get(storedHeader)); err == nil {
+			return stored.Add(age)
+		}
+	}
+	expires, _ := http.ParseTime(header.Get("Expires"))
+	return expires
+}
+
+// DiskCache is a CacheStore in the files of a directory, one per response,
+// named by the SHA-256 of its key. The directory is created as needed.
+// Errors writing files are ignored, leaving the response uncached.
+type DiskCache struct {
+	Dir string
+}
+
+// path returns the file of key.
+func (d DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.Dir, hex.EncodeToString(sum[:]))
+}
+
+// Get implements CacheStore.
+func (d DiskCache) Get(key string) ([]byte, bool) {
+	response, err := os.ReadFile(d.path(key))
+	return response, err == nil
+}
+
+// Set implements CacheStore. The file is replaced atomically, for readers
+// not to see part of it.
+func (d DiskCache) Set(key string, response []byte) {
+	if err := os.MkdirAll(d.Dir, 0700); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(d.Dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(response)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), d.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+}
+
+// Delete implements CacheStore.
+func (d DiskCache) Delete(key string) {
+	_ = os.Remove(d.path(key))
+}
@@ -0,0 +1,175 @@
+package ogenclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	var requests, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Authorization")
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("ETag", `"v1"`)
+		}
+		_, _ = io.WriteString(w, r.URL.Path+" "+r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	for _, store := range []CacheStore{nil, DiskCache{Dir: t.TempDir()}} {
+		client := &http.Client{Transport: &Cache{Store: store}}
+		get := func(method, path, auth string) string {
+			t.Helper()
+			req, _ := http.NewRequest(method, srv.URL+path, nil)
+			if auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("%s %s: status %d", method, path, resp.StatusCode)
+			}
+			for name := range resp.Header {
+				if strings.HasPrefix(name, "X-Ogenclient-") {
+					t.Errorf("%s %s: header %s", method, path, name)
+				}
+			}
+			return string(body)
+		}
+		expect := func(path string, wantRequests, wantNotModified int32) {
+			t.Helper()
+			if requests.Load() != wantRequests || notModified.Load() != wantNotModified {
+				t.Errorf("%s: %d requests, %d not modified, want %d, %d", path, requests.Load(), notModified.Load(), wantRequests, wantNotModified)
+			}
+			requests.Store(0)
+			notModified.Store(0)
+		}
+
+		// Fresh responses are served from the cache.
+		for range 3 {
+			if got := get("GET", "/fresh", ""); got != "/fresh " {
+				t.Errorf("body = %q", got)
+			}
+		}
+		expect("/fresh", 1, 0)
+
+		// Others are revalidated with If-None-Match.
+		for range 3 {
+			if got := get("GET", "/etag", ""); got != "/etag " {
+				t.Errorf("body = %q", got)
+			}
+		}
+		expect("/etag", 3, 2)
+
+		// A response varying by Authorization is not served to another.
+		get("GET", "/vary", "a")
+		get("GET", "/vary", "a")
+		if got := get("GET", "/vary", "b"); got != "/vary b" {
+			t.Errorf("body = %q, want that of b", got)
+		}
+		expect("/vary", 2, 0)
+
+		get("GET", "/nostore", "")
+		get("GET", "/nostore", "")
+		expect("/nostore", 2, 0)
+
+		// A POST removes the cached response of its URL.
+		get("POST", "/fresh", "")
+		get("GET", "/fresh", "")
+		expect("/fresh after POST", 2, 0)
+	}
+}
+
+func TestFresh(t *testing.T) {
+	header := http.Header{
+		"Cache-Control": {"public, Max-Age=60"},
+		storedHeader:    {"Mon, 02 Jan 2006 15:04:05 GMT"},
+	}
+	stored, _ := http.ParseTime(header.Get(storedHeader))
+	if !fresh(header, stored.Add(59e9)) || fresh(header, stored.Add(61e9)) {
+		t.Error("max-age=60 not fresh for 60s")
+	}
+	header.Set("Cache-Control", "no-cache, max-age=60")
+	if fresh(header, stored) {
+		t.Error("no-cache is fresh")
+	}
+	header = http.Header{"Expires": {"Mon, 02 Jan 2006 15:04:05 GMT"}}
+	if !fresh(header, stored.Add(-1e9)) || fresh(header, stored) {
+		t.Error("Expires is not the end of freshness")
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	m := &MemoryCache{MaxEntries: 2}
+	m.Set("a", []byte("A"))
+	m.Set("b", []byte("B"))
+	m.Get("a")
+	m.Set("c", []byte("C"))
+	if _, ok := m.Get("b"); ok {
+		t.Error("least recently used b not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := m.Get(key); !ok {
+			t.Errorf("%s evicted", key)
+		}
+	}
+	m.Set("c", []byte("C2"))
+	if got, _ := m.Get("c"); string(got) != "C2" || m.recent.Len() != 2 {
+		t.Errorf("Get(c) = %s with %d entries after replacing it", got, m.recent.Len())
+	}
+	m.Delete("a")
+	if _, ok := m.Get("a"); ok || m.recent.Len() != 1 {
+		t.Error("a not deleted")
+	}
+
+	var unbounded MemoryCache
+	for i := range 1001 {
+		unbounded.Set(strconv.Itoa(i), nil)
+	}
+	if _, ok := unbounded.Get("0"); ok || unbounded.recent.Len() != 1000 {
+		t.Errorf("zero MemoryCache holds %d entries, want 1000", unbounded.recent.Len())
+	}
+}
+
+func TestMemoryCache_Expired(t *testing.T) {
+	stored := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	response := func(header string) []byte {
+		return []byte("HTTP/1.1 200 OK\r\n" + storedHeader + ": " + stored + "\r\n" + header + "\r\nContent-Length: 0\r\n\r\n")
+	}
+	m := &MemoryCache{}
+	m.Set("/expired", response("Cache-Control: max-age=60"))
+	m.Set("/expires", response("Expires: "+stored))
+	m.Set("/etag", response("Cache-Control: max-age=60\r\nETag: \"1\""))
+	m.Set("/fresh", response("Cache-Control: max-age=86400"))
+	m.Set("/other", []byte("not a response"))
+
+	for key, want := range map[string]bool{"/expired": false, "/expires": false, "/etag": true, "/fresh": true, "/other": true} {
+		if _, ok := m.Get(key); ok != want {
+			t.Errorf("Get(%s) = %v, want %v", key, ok, want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package ogenclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+// Hedger is an http.RoundTripper that sends requests to fallback base
+// URLs, such as the servers of other regions, when the first fails, and
+// hedges slow requests, sending another attempt if the first has not
+// responded after HedgeAfter. The first attempt to succeed wins, and the
+// others are canceled.
+//
+//	hedger := &ogenclient.Hedger{
+//	    BaseURLs:   []string{"https://us.api.example.com/v1", "https://eu.api.example.com/v1"},
+//	    HedgeAfter: 300 * time.Millisecond,
+//	}
+//	client, err := api.NewClient("https://us.api.example.com/v1", api.WithClient(&http.Client{Transport: hedger}))
+//
+// Failures are errors, and the responses ogenerror.DefaultRetryPolicy
+// retries, such as 503s. Only requests that are safe to repeat, by the
+// rules of Transport, are hedged, or sent to a fallback after a failure;
+// other requests fall back only when the connection to a server could not
+// be made, as they were not sent. The body of a request must be replayable through Request.GetBody
+// for it to be sent more than once.
+type Hedger struct {
+	// Base sends the attempts. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// BaseURLs are the server URLs to send requests to, in order of
+	// preference. The first is that of the generated client: requests to
+	// URLs under it are sent to the others by replacing it with theirs.
+	// Requests to other URLs are sent unchanged, and only hedged. If
+	// empty, requests are hedged to their own URL.
+	BaseURLs []string
+	// HedgeAfter is how long to wait for an attempt before sending the
+	// next, to the next base URL, or 0 not to hedge.
+	HedgeAfter time.Duration
+	// MaxAttempts is the number of attempts of a request, hedged or after
+	// failures, cycling through the base URLs. If 0, it is one per base
+	// URL, or 2 with a single one.
+	MaxAttempts int
+}
+
+// attemptResult is the result of an attempt of a Hedger.
+type attemptResult struct {
+	n    int
+	resp *http.Response
+	err  error
+}
+
+// RoundTrip implements http.RoundTripper.
+func (h *Hedger) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := h.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	targets, err := h.targets(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	maxAttempts := h.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = max(len(targets), 2)
+	}
+	if !replayable(req) || len(targets) == 1 && (h.HedgeAfter <= 0 || !h.hedges(req)) {
+		// Nothing to fall back to or hedge, or the request can't be sent
+		// again.
+		maxAttempts = 1
+	}
+
+	ctx := req.Context()
+	results := make(chan attemptResult, maxAttempts)
+	cancels := make([]context.CancelFunc, 0, maxAttempts)
+	start := func() error {
+		n := len(cancels)
+		attemptReq, cancel, err := h.attempt(req, targets[n%len(targets)], n)
+		if err != nil {
+			return err
+		}
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := base.RoundTrip(attemptReq)
+			results <- attemptResult{n: n, resp: resp, err: err}
+		}()
+		return nil
+	}
+	if err := start(); err != nil {
+		return nil, err
+	}
+
+	var hedge <-chan time.Time
+	var timer *time.Timer
+	if h.HedgeAfter > 0 && h.hedges(req) && maxAttempts > 1 {
+		timer = time.NewTimer(h.HedgeAfter)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	// last is the result of the last failed attempt, returned if they all
+	// fail.
+	var last *attemptResult
+	for inFlight := 1; inFlight > 0; {
+		select {
+		case r := <-results:
+			inFlight--
+			if !attemptFailed(r) {
+				for n, cancel := range cancels {
+					if n != r.n {
+						cancel()
+					}
+				}
+				go discard(results, inFlight)
+				if last != nil && last.resp != nil {
+					_ = last.resp.Body.Close()
+				}
+				r.resp.Body = &cancelBody{ReadCloser: r.resp.Body, cancel: cancels[r.n]}
+				return r.resp, nil
+			}
+
+			// Keep the response of the last failure over an error.
+			if r.resp != nil || last == nil || last.resp == nil {
+				if last != nil && last.resp != nil {
+					_ = last.resp.Body.Close()
+				}
+				if last != nil {
+					cancels[last.n]()
+				}
+				last = &r
+			} else {
+				cancels[r.n]()
+			}
+
+			// A failure falls back to the next base URL at once; with a
+			// single one, retries are left to Transport, with backoff.
+			if len(cancels) < maxAttempts && len(targets) > 1 && ctx.Err() == nil && (h.hedges(req) || notSent(r.err)) {
+				if err := start(); err == nil {
+					inFlight++
+					if timer != nil {
+						timer.Reset(h.HedgeAfter)
+					}
+				}
+			}
+		case <-hedge:
+			if len(cancels) < maxAttempts && start() == nil {
+				inFlight++
+				timer.Reset(h.HedgeAfter)
+			}
+		}
+	}
+
+	if last.resp != nil {
+		last.resp.Body = &cancelBody{ReadCloser: last.resp.Body, cancel: cancels[last.n]}
+		return last.resp, nil
+	}
+	return nil, last.err
+}
+
+// hedges reports whether req may be sent again while an attempt is in
+// flight, or after it failed.
+func (h *Hedger) hedges(req *http.Request) bool {
+	return retryable(req, Policy{})
+}
+
+// targets returns the URLs to send a request to u to, one per base URL, or
+// u alone if it is not under the first.
+func (h *Hedger) targets(u *url.URL) ([]*url.URL, error) {
+	if len(h.BaseURLs) == 0 {
+		return []*url.URL{u}, nil
+	}
+	bases := make([]*url.URL, len(h.BaseURLs))
+	for i, raw := range h.BaseURLs {
+		base, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("ogenclient: base URL %q: %w", raw, err)
+		}
+		bases[i] = base
+	}
+
+	primary := bases[0]
+	prefix := strings.TrimSuffix(primary.Path, "/")
+	rest, ok := strings.CutPrefix(u.Path, prefix)
+	if !strings.EqualFold(u.Scheme, primary.Scheme) || !strings.EqualFold(u.Host, primary.Host) ||
+		!ok || rest != "" && !strings.HasPrefix(rest, "/") {
+		return []*url.URL{u}, nil
+	}
+
+	targets := make([]*url.URL, len(bases))
+	for i, base := range bases {
+		target := *u
+		target.Scheme = base.Scheme
+		target.Host = base.Host
+		target.Path = strings.TrimSuffix(base.Path, "/") + rest
+		target.RawPath = ""
+		targets[i] = &target
+	}
+	return targets, nil
+}
+
+// attempt returns the n-th attempt of req, to target, with a context of its
+// own to cancel it.
+func (h *Hedger) attempt(req *http.Request, target *url.URL, n int) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	attemptReq := req.Clone(ctx)
+	attemptReq.URL = target
+	if target.Host != req.URL.Host {
+		attemptReq.Host = ""
+	}
+	if n > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		attemptReq.Body = body
+	}
+	return attemptReq, cancel, nil
+}
+
+// attemptFailed reports whether the result of an attempt calls for another:
+// an error, or a response ogenerror.DefaultRetryPolicy retries, such as a
+// 503.
+func attemptFailed(r attemptResult) bool {
+	if r.err != nil {
+		return true
+	}
+	return ogenerror.DefaultRetryPolicy.IsRetryable(statusError(r.resp))
+}
+
+// notSent reports whether err is a failure to connect, before the request
+// was sent.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// discard closes the responses of the n attempts still in flight once they
+// arrive.
+func discard(results <-chan attemptResult, n int) {
+	for range n {
+		if r := <-results; r.resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(r.resp.Body, 64<<10))
+			_ = r.resp.Body.Close()
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package ogenclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// region is a server answering with status, after delay, and counting the
+// requests it got and those canceled while waiting.
+type region struct {
+	*httptest.Server
+	requests, canceled atomic.Int32
+}
+
+func newRegion(t *testing.T, status int, delay time.Duration) *region {
+	t.Helper()
+	r := &region{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.requests.Add(1)
+		// The server notices a canceled request once the body is read.
+		body, _ := io.ReadAll(req.Body)
+		select {
+		case <-req.Context().Done():
+			r.canceled.Add(1)
+			return
+		case <-time.After(delay):
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, req.URL.Path+" "+string(body))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func do(t *testing.T, rt http.RoundTripper, method, url string) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader("body"))
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestHedger_Fallback(t *testing.T) {
+	primary := newRegion(t, http.StatusServiceUnavailable, 0)
+	secondary := newRegion(t, http.StatusOK, 0)
+	hedger := &Hedger{BaseURLs: []string{primary.URL + "/v1", secondary.URL + "/eu/v1/"}}
+
+	code, body := do(t, hedger, "PUT", primary.URL+"/v1/pets/1")
+	if code != 200 || body != "/eu/v1/pets/1 body" {
+		t.Errorf("PUT = %d %q, want 200 from the secondary", code, body)
+	}
+
+	// A POST that reached the primary is not sent again.
+	if code, _ := do(t, hedger, "POST", primary.URL+"/v1/pets"); code != 503 {
+		t.Errorf("POST = %d, want 503", code)
+	}
+	if n := secondary.requests.Load(); n != 1 {
+		t.Errorf("secondary got %d requests, want 1", n)
+	}
+
+	// A POST that could not connect is.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	hedger.BaseURLs[0] = down.URL + "/v1"
+	if code, body := do(t, hedger, "POST", down.URL+"/v1/pets"); code != 200 || body != "/eu/v1/pets body" {
+		t.Errorf("POST = %d %q, want 200 from the secondary", code, body)
+	}
+
+	// Other URLs are sent unchanged.
+	if code, body := do(t, hedger, "GET", secondary.URL+"/health"); code != 200 || body != "/health body" {
+		t.Errorf("GET = %d %q", code, body)
+	}
+}
+
+func TestHedger_Hedge(t *testing.T) {
+	slow := newRegion(t, http.StatusOK, time.Second)
+	fast := newRegion(t, http.StatusOK, 0)
+	hedger := &Hedger{BaseURLs: []string{slow.URL, fast.URL}, HedgeAfter: 20 * time.Millisecond}
+
+	start := time.Now()
+	code, _ := do(t, hedger, "GET", slow.URL+"/pets")
+	if elapsed := time.Since(start); code != 200 || elapsed > 500*time.Millisecond {
+		t.Errorf("GET = %d after %v, want the hedged attempt", code, elapsed)
+	}
+	// The losing attempt is canceled.
+	deadline := time.Now().Add(time.Second)
+	for slow.canceled.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if slow.canceled.Load() != 1 || fast.requests.Load() != 1 {
+		t.Errorf("slow canceled %d, fast got %d requests, want 1, 1", slow.canceled.Load(), fast.requests.Load())
+	}
+
+	// Non-idempotent requests are not hedged.
+	if code, _ := do(t, hedger, "POST", slow.URL+"/pets"); code != 200 || fast.requests.Load() != 1 {
+		t.Errorf("POST = %d, fast got %d requests, want 200 from slow", code, fast.requests.Load())
+	}
+}
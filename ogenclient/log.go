@@ -0,0 +1,180 @@
+package ogenclient
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+// maxLoggedBody is the most of a body RequestLogger reads to log it. Larger
+// bodies are not logged, as their JSON could not be masked.
+const maxLoggedBody = 64 << 10
+
+// DefaultRedactedHeaders lists the headers whose values RequestLogger masks
+// if RedactHeaders is nil.
+var DefaultRedactedHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key",
+}
+
+// RequestLogger is an http.RoundTripper that logs each request as an slog
+// record, with its method, URL, operation, status and duration:
+//
+//	logger := &ogenclient.RequestLogger{Logger: slog.Default(), Headers: true, Bodies: true}
+//	client, err := api.NewClient(serverURL, api.WithClient(&http.Client{Transport: logger}))
+//
+// Credentials are masked: the values of RedactHeaders, and the query
+// parameters and JSON members named in the Fields of Sanitizer.
+type RequestLogger struct {
+	// Base sends the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Logger writes the records. If nil, slog.Default() is used.
+	Logger *slog.Logger
+	// Level is the level of the records of requests that got a response.
+	// Those that failed with an error are logged at slog.LevelError.
+	Level slog.Level
+
+	// Headers logs the request and response headers.
+	Headers bool
+	// RedactHeaders lists the headers whose values are masked. If nil,
+	// DefaultRedactedHeaders are.
+	RedactHeaders []string
+
+	// Bodies logs the request body, if it can be read again through
+	// Request.GetBody, and the response body. The response body is read
+	// before RoundTrip returns, and put back; bodies over 64 KiB are not
+	// logged.
+	Bodies bool
+	// Sanitizer masks and cuts the logged bodies, and the query of the
+	// URL. If nil, ogenerror.DefaultSanitizer is used.
+	Sanitizer *ogenerror.Sanitizer
+}
+
+// RoundTrip implements http.RoundTripper.
+func (l *RequestLogger) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := l.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	logger := l.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	sanitizer := &ogenerror.DefaultSanitizer
+	if l.Sanitizer != nil {
+		sanitizer = l.Sanitizer
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", redactURL(req.URL, sanitizer.Fields)),
+	}
+	if op := ogenerror.Operation(req.Context()); op != "" {
+		attrs = append(attrs, slog.String("operation", op))
+	}
+	if l.Headers {
+		attrs = append(attrs, l.header("request_header", req.Header))
+	}
+	if l.Bodies && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			if data, ok := readLogged(body); ok && len(data) > 0 {
+				attrs = append(attrs, slog.String("request_body", string(sanitizer.Sanitize(data))))
+			}
+			_ = body.Close()
+		}
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		logger.LogAttrs(req.Context(), slog.LevelError, "HTTP request", attrs...)
+		return nil, err
+	}
+
+	attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	if l.Headers {
+		attrs = append(attrs, l.header("response_header", resp.Header))
+	}
+	if l.Bodies {
+		if data, ok := peekBody(resp); ok && len(data) > 0 {
+			attrs = append(attrs, slog.String("response_body", string(sanitizer.Sanitize(data))))
+		}
+	}
+	logger.LogAttrs(req.Context(), l.Level, "HTTP request", attrs...)
+	return resp, nil
+}
+
+// header returns header as a group named key, with the values of
+// RedactHeaders masked.
+func (l *RequestLogger) header(key string, header http.Header) slog.Attr {
+	redact := l.RedactHeaders
+	if redact == nil {
+		redact = DefaultRedactedHeaders
+	}
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	attrs := make([]any, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if slices.ContainsFunc(redact, func(r string) bool { return strings.EqualFold(r, name) }) {
+			value = "***"
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.Group(key, attrs...)
+}
+
+// redactURL returns u without its credentials, and with the values of the
+// query parameters named in fields masked, regardless of case.
+func redactURL(u *url.URL, fields []string) string {
+	redacted := *u
+	redacted.User = nil
+	if redacted.RawQuery != "" {
+		query := redacted.Query()
+		for name, values := range query {
+			if slices.ContainsFunc(fields, func(f string) bool { return strings.EqualFold(f, name) }) {
+				for i := range values {
+					values[i] = "***"
+				}
+			}
+		}
+		redacted.RawQuery = strings.ReplaceAll(query.Encode(), "%2A%2A%2A", "***")
+	}
+	return redacted.String()
+}
+
+// readLogged reads body, up to maxLoggedBody. Returns false if it is larger
+// or fails.
+func readLogged(body io.Reader) ([]byte, bool) {
+	data, err := io.ReadAll(io.LimitReader(body, maxLoggedBody+1))
+	return data, err == nil && len(data) <= maxLoggedBody
+}
+
+// peekBody reads the body of resp to log it, and puts it back. Returns false
+// if it is larger than maxLoggedBody.
+func peekBody(resp *http.Response) ([]byte, bool) {
+	data, ok := readLogged(resp.Body)
+	if !ok {
+		// Put back what was read of it, and the rest, along with any error.
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+		return nil, false
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return data, true
+}
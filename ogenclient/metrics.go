@@ -0,0 +1,173 @@
+package ogenclient
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+// Error classes of the requests that failed with an error rather than a
+// response, as Metrics labels them. Error responses are labeled with their
+// ogenerror.StatusCategory instead.
+const (
+	ErrorClassTimeout    = "timeout"
+	ErrorClassCanceled   = "canceled"
+	ErrorClassConnection = "connection"
+	ErrorClassOther      = "other"
+)
+
+// MetricsOptions configures the metrics of NewMetrics.
+type MetricsOptions struct {
+	// Namespace prefixes the metric names, such as "petstore" for
+	// petstore_client_requests_total. If empty, they start with "ogen".
+	Namespace string
+	// Buckets are the upper bounds of the latency histogram, in seconds.
+	// If nil, prometheus.DefBuckets are used.
+	Buckets []float64
+	// ConstLabels are added to every metric, such as the name of the API.
+	ConstLabels prometheus.Labels
+	// Categories classifies error responses. If nil,
+	// ogenerror.DefaultCategoryPolicy does.
+	Categories *ogenerror.CategoryPolicy
+}
+
+// Metrics is an http.RoundTripper that records Prometheus metrics of the
+// requests it sends, labeled by the name of their ogen operation, as
+// ogenerror.WithOperation sets it in the request context:
+//
+//   - <namespace>_client_requests_total, by operation, method and code,
+//     the status code, or "" for requests that failed with an error;
+//   - <namespace>_client_errors_total, by operation and class, the
+//     ogenerror.StatusCategory of error responses, or an ErrorClass for
+//     errors;
+//   - <namespace>_client_request_duration_seconds, by operation, the time
+//     until the response headers arrived.
+//
+// Metrics is a prometheus.Collector: register it with Register, or a
+// registry of your own.
+type Metrics struct {
+	// Base sends the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	categories *ogenerror.CategoryPolicy
+	requests   *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+}
+
+// NewMetrics returns Metrics of the requests sent through base.
+//
+// Usage:
+//
+//	metrics := ogenclient.NewMetrics(http.DefaultTransport, ogenclient.MetricsOptions{Namespace: "petstore"})
+//	if err := metrics.Register(nil); err != nil {
+//	    return err
+//	}
+//	client, err := api.NewClient(serverURL, api.WithClient(&http.Client{Transport: metrics}))
+func NewMetrics(base http.RoundTripper, opts MetricsOptions) *Metrics {
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = "ogen"
+	}
+	categories := opts.Categories
+	if categories == nil {
+		categories = &ogenerror.DefaultCategoryPolicy
+	}
+	return &Metrics{
+		Base:       base,
+		categories: categories,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "client",
+			Name:        "requests_total",
+			Help:        "Requests sent, by operation, method and status code.",
+			ConstLabels: opts.ConstLabels,
+		}, []string{"operation", "method", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "client",
+			Name:        "errors_total",
+			Help:        "Requests that failed or got an error response, by operation and class.",
+			ConstLabels: opts.ConstLabels,
+		}, []string{"operation", "class"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   "client",
+			Name:        "request_duration_seconds",
+			Help:        "Time until the response headers arrived, by operation.",
+			Buckets:     opts.Buckets,
+			ConstLabels: opts.ConstLabels,
+		}, []string{"operation"}),
+	}
+}
+
+// Register registers m with reg, or with prometheus.DefaultRegisterer if
+// reg is nil.
+func (m *Metrics) Register(reg prometheus.Registerer) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	return reg.Register(m)
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.errors.Describe(ch)
+	m.duration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.errors.Collect(ch)
+	m.duration.Collect(ch)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (m *Metrics) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := m.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	op := ogenerror.Operation(req.Context())
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	m.duration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+
+	code := ""
+	class := ""
+	switch {
+	case err != nil:
+		class = errorClass(err)
+	case resp.StatusCode >= 400:
+		code = strconv.Itoa(resp.StatusCode)
+		class = string(m.categories.Category(statusError(resp)))
+	default:
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	m.requests.WithLabelValues(op, req.Method, code).Inc()
+	if class != "" {
+		m.errors.WithLabelValues(op, class).Inc()
+	}
+	return resp, err
+}
+
+// errorClass returns the ErrorClass of a request that failed with err.
+func errorClass(err error) string {
+	switch {
+	case ogenerror.IsCanceled(err):
+		return ErrorClassCanceled
+	case ogenerror.IsTimeout(err):
+		return ErrorClassTimeout
+	case ogenerror.IsConnectionError(err):
+		return ErrorClassConnection
+	default:
+		return ErrorClassOther
+	}
+}
@@ -0,0 +1,63 @@
+package ogenclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+func TestMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/busy":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	metrics := NewMetrics(nil, MetricsOptions{Namespace: "petstore"})
+	reg := prometheus.NewRegistry()
+	if err := metrics.Register(reg); err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: metrics}
+	for _, call := range []struct{ op, url string }{
+		{"getPet", srv.URL + "/"},
+		{"getPet", srv.URL + "/missing"},
+		{"listPets", srv.URL + "/busy"},
+		{"listPets", "http://127.0.0.1:1/"},
+	} {
+		req, _ := http.NewRequestWithContext(ogenerror.WithOperation(t.Context(), call.op), "GET", call.url, nil)
+		if resp, err := client.Do(req); err == nil {
+			_ = resp.Body.Close()
+		}
+	}
+
+	want := `
+# HELP petstore_client_errors_total Requests that failed or got an error response, by operation and class.
+# TYPE petstore_client_errors_total counter
+petstore_client_errors_total{class="connection",operation="listPets"} 1
+petstore_client_errors_total{class="not_found",operation="getPet"} 1
+petstore_client_errors_total{class="transient",operation="listPets"} 1
+# HELP petstore_client_requests_total Requests sent, by operation, method and status code.
+# TYPE petstore_client_requests_total counter
+petstore_client_requests_total{code="",method="GET",operation="listPets"} 1
+petstore_client_requests_total{code="200",method="GET",operation="getPet"} 1
+petstore_client_requests_total{code="404",method="GET",operation="getPet"} 1
+petstore_client_requests_total{code="503",method="GET",operation="listPets"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "petstore_client_errors_total", "petstore_client_requests_total"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(metrics, "petstore_client_request_duration_seconds"); n != 2 {
+		t.Errorf("%d duration histograms, want 2", n)
+	}
+}
@@ -0,0 +1,250 @@
+package ogenclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ogen-go/ogen/validate"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+const (
+	// maxTokenResponse is the most of a token endpoint response
+	// TokenSource reads.
+	maxTokenResponse = 1 << 20
+	// tokenTimeout bounds the requests to the token endpoint.
+	tokenTimeout = 30 * time.Second
+)
+
+// TokenSource gets OAuth 2.0 access tokens from a token endpoint, with the
+// client credentials grant, or the refresh token grant if RefreshToken is
+// set, and caches them until they are about to expire. It is safe for
+// concurrent use: requests share one token, and one request to the token
+// endpoint at a time.
+//
+// Use it in the methods of the SecuritySource of a generated client for
+// the OAuth 2.0 schemes of the spec:
+//
+//	func (s *source) OAuth2(ctx context.Context, op api.OperationName) (api.OAuth2, error) {
+//	    token, err := s.tokens.Token(ctx)
+//	    return api.OAuth2{Token: token}, err
+//	}
+type TokenSource struct {
+	// TokenURL is the URL of the token endpoint.
+	TokenURL string
+	// ClientID and ClientSecret authenticate the client, with HTTP Basic
+	// authentication.
+	ClientID     string
+	ClientSecret string
+	// Scopes are the scopes to request, if any.
+	Scopes []string
+	// Params are other parameters of token requests, such as "audience".
+	Params url.Values
+	// RefreshToken, if set, gets tokens with the refresh token grant. A
+	// new refresh token from the endpoint replaces it: do not read or set
+	// it once Token is called.
+	RefreshToken string
+
+	// RefreshBefore is how long before its expiry a token is refreshed, or
+	// 1 minute if 0, at most half its lifetime. A random jitter of up to
+	// half of it more spreads the refreshes of several clients. The token
+	// is refreshed in the background, while requests go on using it.
+	RefreshBefore time.Duration
+	// HTTPClient sends the token requests. If nil, http.DefaultClient
+	// does.
+	HTTPClient *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expiry  time.Time // zero if the token does not expire
+	refresh time.Time // when to refresh the token, zero if never
+	// fetching is closed once the request in flight to the token endpoint
+	// is done, or nil if there is none.
+	fetching chan struct{}
+	err      error
+}
+
+// Token returns a valid access token, from the cache or the token endpoint.
+// If the endpoint fails, the error wraps a *validate.UnexpectedStatusCodeError
+// that ogenerror parses, such as with ogenerror.OAuth2.
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	for {
+		s.mu.Lock()
+		now := time.Now()
+		valid := s.token != "" && (s.expiry.IsZero() || now.Before(s.expiry))
+		if valid && (s.refresh.IsZero() || now.Before(s.refresh)) {
+			token := s.token
+			s.mu.Unlock()
+			return token, nil
+		}
+
+		fetching := s.fetching
+		if fetching == nil {
+			fetching = make(chan struct{})
+			s.fetching = fetching
+			// The token is for all the requests waiting for it: it must
+			// not be canceled with the one that asked first.
+			go s.fetch(context.WithoutCancel(ctx), fetching)
+		}
+		if valid {
+			token := s.token
+			s.mu.Unlock()
+			return token, nil
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-fetching:
+		}
+		s.mu.Lock()
+		err := s.err
+		ok := s.token != "" && (s.expiry.IsZero() || time.Now().Before(s.expiry))
+		s.mu.Unlock()
+		if !ok && err != nil {
+			return "", err
+		}
+	}
+}
+
+// Invalidate drops the cached token, for the next call to Token to get a
+// new one, such as after the API rejected it with 401.
+func (s *TokenSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+}
+
+// fetch gets a token from the endpoint, stores it, and closes done.
+func (s *TokenSource) fetch(ctx context.Context, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(ctx, tokenTimeout)
+	token, err := s.request(ctx)
+	cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetching = nil
+	s.err = err
+	close(done)
+	now := time.Now()
+	if err != nil {
+		// Retry a failed refresh in the background when a quarter of the
+		// lifetime left has passed, rather than on every call.
+		if s.token != "" && !s.expiry.IsZero() && now.Before(s.expiry) {
+			s.refresh = now.Add(s.expiry.Sub(now) / 4)
+		}
+		return
+	}
+
+	s.token = token.AccessToken
+	s.expiry = time.Time{}
+	s.refresh = time.Time{}
+	if lifetime := token.lifetime(); lifetime > 0 {
+		before := s.RefreshBefore
+		if before <= 0 {
+			before = time.Minute
+		}
+		before = min(before+rand.N(before/2+1), lifetime/2)
+		s.expiry = now.Add(lifetime)
+		s.refresh = s.expiry.Add(-before)
+	}
+	if token.RefreshToken != "" && s.RefreshToken != "" {
+		s.RefreshToken = token.RefreshToken
+	}
+}
+
+// tokenResponse is the successful response of a token endpoint.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	// ExpiresIn is a number of seconds, which some endpoints send as a
+	// string.
+	ExpiresIn    json.Number `json:"expires_in"`
+	RefreshToken string      `json:"refresh_token"`
+}
+
+// lifetime returns how long the token is valid for, or 0 if the response
+// does not say.
+func (t *tokenResponse) lifetime() time.Duration {
+	seconds, err := strconv.ParseFloat(string(t.ExpiresIn), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// request sends a token request.
+func (s *TokenSource) request(ctx context.Context) (*tokenResponse, error) {
+	form := url.Values{}
+	for name, values := range s.Params {
+		form[name] = values
+	}
+	s.mu.Lock()
+	refreshToken := s.RefreshToken
+	s.mu.Unlock()
+	if refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(s.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("ogenclient: token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.ClientID != "" {
+		// RFC 6749 2.3.1 has the credentials form-encoded before they are
+		// Basic encoded.
+		req.SetBasicAuth(url.QueryEscape(s.ClientID), url.QueryEscape(s.ClientSecret))
+	}
+
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ogenclient: token request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponse))
+	if err != nil {
+		return nil, fmt.Errorf("ogenclient: token request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		statusErr := &validate.UnexpectedStatusCodeError{StatusCode: resp.StatusCode, Payload: resp}
+		if oauthErr, ok := ogenerror.ParseOAuth2(body, resp.Header.Get("Content-Type")); ok {
+			return nil, fmt.Errorf("ogenclient: token request: %s: %w", oauthErr.Code, statusErr)
+		}
+		return nil, fmt.Errorf("ogenclient: token request: %w", statusErr)
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, fmt.Errorf("ogenclient: token response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return nil, errors.New("ogenclient: token response: no access_token")
+	}
+	return &tr, nil
+}
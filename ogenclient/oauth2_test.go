@@ -0,0 +1,123 @@
+package ogenclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+func TestTokenSource(t *testing.T) {
+	var requests atomic.Int32
+	var forms []url.Values
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if id, secret, _ := r.BasicAuth(); id != "app%3A1" || secret != "s3cret" {
+			t.Errorf("credentials %q, %q", id, secret)
+		}
+		_ = r.ParseForm()
+		mu.Lock()
+		forms = append(forms, r.PostForm)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"access_token":"t`+string(rune('0'+n))+`","token_type":"Bearer","expires_in":"1","refresh_token":"r`+string(rune('0'+n))+`"}`)
+	}))
+	defer srv.Close()
+
+	tokens := &TokenSource{
+		TokenURL:      srv.URL,
+		ClientID:      "app:1",
+		ClientSecret:  "s3cret",
+		Scopes:        []string{"pets:read", "pets:write"},
+		Params:        url.Values{"audience": {"pets"}},
+		RefreshBefore: 300 * time.Millisecond,
+	}
+
+	// Concurrent calls share one token request.
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			if token, err := tokens.Token(t.Context()); err != nil || token != "t1" {
+				t.Errorf("Token = %q, %v, want t1", token, err)
+			}
+		})
+	}
+	wg.Wait()
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("%d token requests, want 1", n)
+	}
+	if form := forms[0]; form.Get("grant_type") != "client_credentials" || form.Get("scope") != "pets:read pets:write" || form.Get("audience") != "pets" {
+		t.Errorf("form = %v", form)
+	}
+
+	// Within RefreshBefore of its expiry, at most half its lifetime, the
+	// token is refreshed in the background while still served.
+	time.Sleep(750 * time.Millisecond)
+	if token, _ := tokens.Token(t.Context()); token != "t1" {
+		t.Errorf("Token = %q during refresh, want t1", token)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if token, _ := tokens.Token(t.Context()); token != "t2" {
+		t.Errorf("Token = %q after refresh, want t2", token)
+	}
+
+	// Invalidate drops the token.
+	tokens.Invalidate()
+	if token, _ := tokens.Token(t.Context()); token != "t3" {
+		t.Errorf("Token = %q after Invalidate, want t3", token)
+	}
+}
+
+func TestTokenSource_RefreshToken(t *testing.T) {
+	var forms []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		forms = append(forms, r.PostForm)
+		_, _ = io.WriteString(w, `{"access_token":"t","refresh_token":"r2"}`)
+	}))
+	defer srv.Close()
+
+	tokens := &TokenSource{TokenURL: srv.URL, RefreshToken: "r1"}
+	if token, err := tokens.Token(t.Context()); err != nil || token != "t" {
+		t.Fatalf("Token = %q, %v", token, err)
+	}
+	tokens.Invalidate()
+	if _, err := tokens.Token(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if forms[0].Get("grant_type") != "refresh_token" || forms[0].Get("refresh_token") != "r1" || forms[1].Get("refresh_token") != "r2" {
+		t.Errorf("forms = %v, want the refresh token rotated", forms)
+	}
+}
+
+func TestTokenSource_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":"invalid_client","error_description":"unknown client"}`)
+	}))
+	defer srv.Close()
+
+	tokens := &TokenSource{TokenURL: srv.URL, ClientID: "app"}
+	_, err := tokens.Token(t.Context())
+	oauthErr, ok := ogenerror.OAuth2(err)
+	if !ok || oauthErr.Code != ogenerror.OAuth2InvalidClient || ogenerror.StatusCode(err) != 400 {
+		t.Errorf("err = %v, want invalid_client", err)
+	}
+
+	// A canceled caller does not wait for the token.
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := tokens.Token(ctx); err != context.Canceled {
+		t.Errorf("err = %v, want Canceled", err)
+	}
+}
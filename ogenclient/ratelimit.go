@@ -0,0 +1,125 @@
+package ogenclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+// Limit is a token-bucket rate limit: Rate requests a second on average, in
+// bursts of up to Burst requests. A Rate of 0 or less does not limit.
+type Limit struct {
+	Rate float64
+	// Burst is the size of the bucket, at least 1.
+	Burst int
+}
+
+// RateLimiter is an http.RoundTripper that delays requests to keep within
+// the rate limits of their operation and of their host. A request with both
+// waits for both:
+//
+//	limiter := &ogenclient.RateLimiter{
+//	    Operations: map[string]ogenclient.Limit{api.SearchOperation: {Rate: 5, Burst: 1}},
+//	    Hosts:      map[string]ogenclient.Limit{"api.example.com": {Rate: 50, Burst: 10}},
+//	}
+//
+// Set it as the Base of a Transport for retries to be limited as well. The
+// maps must not be modified after the first request.
+type RateLimiter struct {
+	// Base sends the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Operations limits requests by the name of their ogen operation, as
+	// ogenerror.WithOperation sets it in the request context.
+	Operations map[string]Limit
+	// Hosts limits requests by the host of their URL, with its port if it
+	// has one.
+	Hosts map[string]Limit
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// RoundTrip implements http.RoundTripper. If the request context is done
+// before the request is allowed, its error is returned.
+func (l *RateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	var reserved []*bucket
+	var delay time.Duration
+	l.mu.Lock()
+	now := time.Now()
+	if op := ogenerror.Operation(ctx); op != "" {
+		if limit, ok := l.Operations[op]; ok && limit.Rate > 0 {
+			b := l.bucket("operation "+op, limit, now)
+			delay = max(delay, b.reserve(now))
+			reserved = append(reserved, b)
+		}
+	}
+	if limit, ok := l.Hosts[req.URL.Host]; ok && limit.Rate > 0 {
+		b := l.bucket("host "+req.URL.Host, limit, now)
+		delay = max(delay, b.reserve(now))
+		reserved = append(reserved, b)
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			// Give the tokens back for the requests that are still waiting.
+			l.mu.Lock()
+			for _, b := range reserved {
+				b.tokens++
+			}
+			l.mu.Unlock()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	base := l.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+// bucket returns the bucket of key, created full with limit at now. It must
+// be called with l.mu held.
+func (l *RateLimiter) bucket(key string, limit Limit, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		if l.buckets == nil {
+			l.buckets = make(map[string]*bucket)
+		}
+		b = &bucket{rate: limit.Rate, burst: float64(max(limit.Burst, 1)), last: now}
+		b.tokens = b.burst
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// bucket is a token bucket. Its tokens go negative as requests reserve the
+// tokens it has yet to refill.
+type bucket struct {
+	rate, burst float64
+	tokens      float64
+	last        time.Time
+}
+
+// reserve takes a token at now, and returns how long to wait until it is
+// refilled.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	if now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
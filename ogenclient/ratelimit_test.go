@@ -0,0 +1,86 @@
+package ogenclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+func TestRateLimiter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	limiter := &RateLimiter{Operations: map[string]Limit{"search": {Rate: 20, Burst: 2}}}
+	client := &http.Client{Transport: limiter}
+	get := func(ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+
+	// A burst of 2, then 20 a second: 6 requests take 200ms.
+	search := ogenerror.WithOperation(context.Background(), "search")
+	start := time.Now()
+	for range 6 {
+		if err := get(search); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond || elapsed > time.Second {
+		t.Errorf("6 searches took %v, want 200ms", elapsed)
+	}
+
+	// Other operations are not limited.
+	start = time.Now()
+	for range 10 {
+		if err := get(ogenerror.WithOperation(context.Background(), "getPet")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("10 unlimited requests took %v", elapsed)
+	}
+
+	// A request whose context ends while waiting fails, and gives its token
+	// back.
+	ctx, cancel := context.WithTimeout(search, 10*time.Millisecond)
+	defer cancel()
+	if err := get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	}
+	if tokens := limiter.buckets["operation search"].tokens; tokens < -0.5 {
+		t.Errorf("tokens = %v, want the canceled token given back", tokens)
+	}
+}
+
+func TestRateLimiter_Hosts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	limiter := &RateLimiter{
+		Operations: map[string]Limit{"search": {Rate: 1000}},
+		Hosts:      map[string]Limit{srv.Listener.Addr().String(): {Rate: 20}},
+	}
+	client := &http.Client{Transport: limiter}
+	start := time.Now()
+	for range 3 {
+		req, _ := http.NewRequestWithContext(ogenerror.WithOperation(context.Background(), "search"), "GET", srv.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	// The limit of the host applies along with that of the operation.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests took %v, want 100ms", elapsed)
+	}
+}
@@ -0,0 +1,145 @@
+package ogenclient
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ogen-go/ogen/otelogen"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+// Attributes Tracing sets on spans, beyond those of the OpenTelemetry
+// semantic conventions.
+const (
+	// CategoryKey is the ogenerror.StatusCategory of error responses.
+	CategoryKey = attribute.Key("ogen.error.category")
+	// ResponseBodyKey is the sanitized start of the body of error
+	// responses, on their "error response" span event.
+	ResponseBodyKey = attribute.Key("ogen.error.body")
+)
+
+// tracerName is the instrumentation name of the spans Tracing starts.
+const tracerName = "github.com/plexusone/ogen-tools/ogenclient"
+
+// Tracing is an http.RoundTripper that adds to the span of each request what
+// the transport knows of its result: the status code, the
+// ogenerror.StatusCategory and error.type of errors, and, on an
+// "error response" event, the sanitized start of the body of error
+// responses. All attributes have few values, to be safe to aggregate on.
+//
+// By default, Tracing enriches the span of the request context, which is
+// the client span ogen starts for the operation, with its operationId and
+// route template. Set NewSpan for clients whose ogen tracing is off, to
+// start a span instead.
+type Tracing struct {
+	// Base sends the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// NewSpan starts a client span for each request, named after its
+	// operation, as ogenerror.WithOperation sets it, or its method.
+	NewSpan bool
+	// TracerProvider starts the spans of NewSpan. If nil, the global
+	// provider does.
+	TracerProvider trace.TracerProvider
+	// Routes maps operation names to their route templates, such as
+	// "/pets/{id}", set as the url.template of the spans of NewSpan. The
+	// URL of the request is not set, as it would have a value per
+	// resource.
+	Routes map[string]string
+
+	// Sanitizer masks and cuts the body of error responses. If nil,
+	// ogenerror.DefaultSanitizer does, cut to 256 bytes.
+	Sanitizer *ogenerror.Sanitizer
+	// Categories classifies error responses. If nil,
+	// ogenerror.DefaultCategoryPolicy does.
+	Categories *ogenerror.CategoryPolicy
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Tracing) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	span := trace.SpanFromContext(req.Context())
+	if t.NewSpan {
+		req, span = t.start(req)
+		defer span.End()
+	}
+	if !span.IsRecording() {
+		return base.RoundTrip(req)
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		// The class, not the message, which has the URL of the request
+		// with its query.
+		class := errorClass(err)
+		span.SetAttributes(semconv.ErrorTypeKey.String(class))
+		span.SetStatus(codes.Error, class)
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCodeKey.Int(resp.StatusCode))
+	if resp.StatusCode < 400 {
+		return resp, nil
+	}
+
+	categories := t.Categories
+	if categories == nil {
+		categories = &ogenerror.DefaultCategoryPolicy
+	}
+	span.SetAttributes(
+		semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)),
+		CategoryKey.String(string(categories.Category(statusError(resp)))),
+	)
+	span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+
+	if body, ok := peekBody(resp); ok && len(body) > 0 {
+		sanitizer := t.Sanitizer
+		if sanitizer == nil {
+			snippet := ogenerror.DefaultSanitizer
+			snippet.MaxBody = 256
+			sanitizer = &snippet
+		}
+		span.AddEvent("error response", trace.WithAttributes(ResponseBodyKey.String(string(sanitizer.Sanitize(body)))))
+	}
+	return resp, nil
+}
+
+// start starts the span of req, and returns a copy of req with the span in
+// its context.
+func (t *Tracing) start(req *http.Request) (*http.Request, trace.Span) {
+	provider := t.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	op := ogenerror.Operation(req.Context())
+	name := op
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.ServerAddressKey.String(req.URL.Hostname()),
+	}
+	if op != "" {
+		attrs = append(attrs, otelogen.OperationID(op))
+	} else {
+		name = req.Method
+	}
+	if route, ok := t.Routes[op]; ok {
+		attrs = append(attrs, semconv.URLTemplateKey.String(route))
+	}
+
+	ctx, span := provider.Tracer(tracerName).Start(req.Context(), name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return req.WithContext(ctx), span
+}
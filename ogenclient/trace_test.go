@@ -0,0 +1,111 @@
+package ogenclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+func TestTracing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pets/1" {
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, `{"error":"invalid token","token":"abc"}`)
+	}))
+	defer srv.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracing := &Tracing{
+		NewSpan:        true,
+		TracerProvider: provider,
+		Routes:         map[string]string{"getPet": "/pets/{id}"},
+	}
+	client := &http.Client{Transport: tracing}
+	for _, path := range []string{"/pets/1", "/pets/2"} {
+		req, _ := http.NewRequestWithContext(ogenerror.WithOperation(t.Context(), "getPet"), "GET", srv.URL+path, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The body is put back for the client to decode.
+		if body, _ := io.ReadAll(resp.Body); path == "/pets/2" && len(body) == 0 {
+			t.Error("error body not put back")
+		}
+		_ = resp.Body.Close()
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans, want 2", len(spans))
+	}
+	attrs := func(span sdktrace.ReadOnlySpan) map[attribute.Key]string {
+		m := make(map[attribute.Key]string)
+		for _, kv := range span.Attributes() {
+			m[kv.Key] = kv.Value.Emit()
+		}
+		return m
+	}
+
+	ok, failed := spans[0], spans[1]
+	if ok.Name() != "getPet" || ok.Status().Code != codes.Unset {
+		t.Errorf("span %s, status %v", ok.Name(), ok.Status())
+	}
+	want := map[attribute.Key]string{
+		"oas.operation":             "getPet",
+		"http.request.method":       "GET",
+		"server.address":            "127.0.0.1",
+		"url.template":              "/pets/{id}",
+		"http.response.status_code": "200",
+	}
+	if got := attrs(ok); !reflect.DeepEqual(got, want) {
+		t.Errorf("attributes = %v, want %v", got, want)
+	}
+
+	want["http.response.status_code"] = "401"
+	want["error.type"] = "401"
+	want["ogen.error.category"] = "auth"
+	if got := attrs(failed); !reflect.DeepEqual(got, want) {
+		t.Errorf("attributes = %v, want %v", got, want)
+	}
+	if failed.Status().Code != codes.Error {
+		t.Errorf("status = %v, want error", failed.Status())
+	}
+	events := failed.Events()
+	if len(events) != 1 || events[0].Name != "error response" ||
+		events[0].Attributes[0] != ResponseBodyKey.String(`{"error":"invalid token","token":"***"}`) {
+		t.Errorf("events = %v", events)
+	}
+}
+
+func TestTracing_ContextSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, span := provider.Tracer("test").Start(t.Context(), "getPet")
+
+	client := &http.Client{Transport: &Tracing{}}
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://127.0.0.1:1/?api_key=secret", nil)
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("want error")
+	}
+	span.End()
+
+	got := recorder.Ended()[0]
+	if want := []attribute.KeyValue{attribute.String("error.type", ErrorClassConnection)}; !reflect.DeepEqual(got.Attributes(), want) {
+		t.Errorf("attributes = %v, want %v", got.Attributes(), want)
+	}
+	if want := (sdktrace.Status{Code: codes.Error, Description: ErrorClassConnection}); got.Status() != want {
+		t.Errorf("status = %v, want %v", got.Status(), want)
+	}
+}
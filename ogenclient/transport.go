@@ -0,0 +1,247 @@
+// Package ogenclient provides http.RoundTrippers for ogen clients: Transport
+// retries requests, Hedger hedges them and falls back to other servers,
+// RateLimiter keeps them within rate limits, Breaker fails them fast while
+// their host fails, Cache caches their responses, RequestLogger logs them,
+// Metrics measures them, and Tracing adds their results to their spans.
+// TokenSource provides the OAuth 2.0 tokens of their SecuritySource.
+//
+// Use it as the transport of the http.Client given to the generated client:
+//
+//	client, err := api.NewClient(serverURL, api.WithClient(&http.Client{
+//	    Transport: &ogenclient.Transport{Policy: ogenclient.Policy{MaxAttempts: 5}},
+//	}))
+//
+// Failed attempts are classified as ogenerror.IsRetryable does, and only
+// requests that are safe to repeat, and whose body can be replayed, are
+// retried.
+package ogenclient
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/ogen-go/ogen/validate"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+// Policy configures the retries of a Transport. The zero value makes up to 3
+// attempts with backoff between 100ms and 10s.
+type Policy struct {
+	// MaxAttempts is the number of attempts, including the first one.
+	MaxAttempts int
+	// MinBackoff is the base delay before the first retry. It doubles with
+	// every retry, and a random jitter is applied.
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. If a Retry-After header
+	// asks for a longer delay, the response is returned instead.
+	MaxBackoff time.Duration
+	// AttemptTimeout bounds each attempt, including reading the response
+	// body, or 0 for no bound but the deadline of the request context. An
+	// attempt that times out is retried.
+	AttemptTimeout time.Duration
+	// Statuses lists the status codes of responses to retry. If nil, those
+	// of ogenerror.DefaultRetryPolicy are retried.
+	Statuses []int
+	// RetryNonIdempotent retries POST and PATCH requests, which are only
+	// retried otherwise if they have an Idempotency-Key header.
+	RetryNonIdempotent bool
+}
+
+// withDefaults fills in the zero fields of p.
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 10 * time.Second
+	}
+	if p.Statuses == nil {
+		p.Statuses = ogenerror.DefaultRetryPolicy.Statuses
+	}
+	return p
+}
+
+type policyKey struct{}
+
+// WithPolicy returns a copy of ctx whose requests a Transport retries with
+// policy, in place of the policy of their operation:
+//
+//	ctx = ogenclient.WithPolicy(ctx, ogenclient.Policy{MaxAttempts: 1})
+//	pet, err := client.GetPet(ctx, params)
+func WithPolicy(ctx context.Context, policy Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, policy)
+}
+
+// Transport is an http.RoundTripper that retries failed requests:
+//
+//   - responses with a status code of Policy.Statuses, or a Retry-After
+//     header, and network errors, as ogenerror.IsRetryable classifies them;
+//   - of the GET, HEAD, OPTIONS, TRACE, PUT and DELETE methods, and of POST
+//     and PATCH if they have an Idempotency-Key header or the policy
+//     allows it;
+//   - whose body is empty or can be replayed through Request.GetBody, as
+//     ogen sets it for bodies it encodes in memory.
+//
+// The delay between attempts doubles from Policy.MinBackoff, with jitter; a
+// Retry-After header asks for a longer one. Canceling the request context
+// stops the retries, returning the last response or error.
+type Transport struct {
+	// Base makes each attempt. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Policy configures the retries of requests whose operation is not in
+	// Operations.
+	Policy Policy
+	// Operations configures the retries of requests by the name of their
+	// ogen operation, as ogenerror.WithOperation sets it in the request
+	// context.
+	Operations map[string]Policy
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	policy := t.policy(req).withDefaults()
+	if !retryable(req, policy) {
+		return t.attempt(req, policy)
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		resp, err := t.attempt(attemptReq, policy)
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return resp, err
+		}
+		delay, ok := retryDelay(resp, err, attempt, policy)
+		if !ok {
+			return resp, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			_ = resp.Body.Close()
+		}
+	}
+}
+
+// policy returns the policy of req: that of its context, or of its
+// operation, or the default.
+func (t *Transport) policy(req *http.Request) Policy {
+	ctx := req.Context()
+	if policy, ok := ctx.Value(policyKey{}).(Policy); ok {
+		return policy
+	}
+	if policy, ok := t.Operations[ogenerror.Operation(ctx)]; ok {
+		return policy
+	}
+	return t.Policy
+}
+
+// attempt sends req once, within Policy.AttemptTimeout.
+func (t *Transport) attempt(req *http.Request, policy Policy) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if policy.AttemptTimeout <= 0 {
+		return base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), policy.AttemptTimeout)
+	resp, err := base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout covers reading the body too: release it once the body is
+	// closed.
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody cancels the context of its attempt when closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryable reports whether req is safe to send again.
+func retryable(req *http.Request, policy Policy) bool {
+	if !replayable(req) {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return policy.RetryNonIdempotent || req.Header.Get("Idempotency-Key") != ""
+	}
+}
+
+// replayable reports whether the body of req can be sent again.
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// retryDelay reports whether the result of an attempt is worth retrying,
+// and how long to wait before the next attempt.
+func retryDelay(resp *http.Response, err error, attempt int, policy Policy) (time.Duration, bool) {
+	classify := ogenerror.RetryPolicy{Statuses: policy.Statuses, RetryAfter: true, Network: true}
+	var wait time.Duration
+	if err == nil {
+		if resp.StatusCode < 400 {
+			return 0, false
+		}
+		err = statusError(resp)
+		wait, _ = ogenerror.RetryAfter(err)
+	}
+	if !classify.IsRetryable(err) || wait > policy.MaxBackoff {
+		return 0, false
+	}
+
+	// Exponential backoff with jitter in [backoff/2, backoff].
+	backoff := policy.MinBackoff << (attempt - 1)
+	if backoff <= 0 || backoff > policy.MaxBackoff {
+		backoff = policy.MaxBackoff
+	}
+	backoff = backoff/2 + rand.N(backoff/2+1)
+	return max(wait, backoff), true
+}
+
+// statusError returns the status and headers of resp as the ogen client
+// would report them, for ogenerror to classify, without its body, which the
+// caller reads.
+func statusError(resp *http.Response) error {
+	return &validate.UnexpectedStatusCodeError{
+		StatusCode: resp.StatusCode,
+		Payload:    &http.Response{StatusCode: resp.StatusCode, Header: resp.Header},
+	}
+}
@@ -0,0 +1,194 @@
+package ogenclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+// recorder records the request bodies a server gets.
+type recorder struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bodies
+}
+
+// server responds with the statuses in turn, with a Retry-After header for
+// 429, then 200.
+func server(t *testing.T, statuses ...int) (*httptest.Server, *recorder) {
+	t.Helper()
+	rec := &recorder{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		rec.bodies = append(rec.bodies, string(body))
+		n := len(rec.bodies)
+		rec.mu.Unlock()
+		if n <= len(statuses) {
+			if statuses[n-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv, rec
+}
+
+var fast = Policy{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		header   http.Header
+		policy   Policy
+		statuses []int
+		want     int
+		attempts int
+	}{
+		{name: "retried", method: "GET", policy: fast, statuses: []int{503, 502}, want: 200, attempts: 3},
+		{name: "max attempts", method: "GET", policy: fast, statuses: []int{503, 503, 503}, want: 503, attempts: 3},
+		{name: "not retryable", method: "GET", policy: fast, statuses: []int{500}, want: 500, attempts: 1},
+		{name: "statuses", method: "GET", policy: Policy{MaxBackoff: time.Millisecond, Statuses: []int{500}}, statuses: []int{500}, want: 200, attempts: 2},
+		{name: "post", method: "POST", policy: fast, statuses: []int{503}, want: 503, attempts: 1},
+		{name: "idempotency key", method: "POST", header: http.Header{"Idempotency-Key": {"k"}}, policy: fast, statuses: []int{503}, want: 200, attempts: 2},
+		{name: "retry non-idempotent", method: "PATCH", policy: Policy{MaxBackoff: time.Millisecond, RetryNonIdempotent: true}, statuses: []int{503}, want: 200, attempts: 2},
+		{name: "retry-after over max backoff", method: "GET", policy: fast, statuses: []int{429}, want: 429, attempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, rec := server(t, tt.statuses...)
+			client := &http.Client{Transport: &Transport{Policy: tt.policy}}
+			req, _ := http.NewRequest(tt.method, srv.URL, strings.NewReader("body"))
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if len(rec.get()) != tt.attempts {
+				t.Errorf("attempts = %d, want %d", len(rec.get()), tt.attempts)
+			}
+			for _, body := range rec.get() {
+				if body != "body" {
+					t.Errorf("body = %q, want replayed", body)
+				}
+			}
+		})
+	}
+}
+
+func TestTransport_StreamedBody(t *testing.T) {
+	srv, rec := server(t, 503)
+	client := &http.Client{Transport: &Transport{Policy: fast}}
+	body, w := io.Pipe()
+	go func() {
+		_, _ = io.WriteString(w, "part")
+		_ = w.Close()
+	}()
+	req, _ := http.NewRequest("PUT", srv.URL, body)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 503 || len(rec.get()) != 1 {
+		t.Errorf("status = %d after %d attempts, want 503 after 1", resp.StatusCode, len(rec.get()))
+	}
+}
+
+func TestTransport_Policies(t *testing.T) {
+	srv, rec := server(t, 503, 503)
+	transport := &Transport{
+		Policy:     fast,
+		Operations: map[string]Policy{"createPet": {MaxBackoff: time.Millisecond, RetryNonIdempotent: true}},
+	}
+	client := &http.Client{Transport: transport}
+
+	ctx := ogenerror.WithOperation(context.Background(), "createPet")
+	req, _ := http.NewRequestWithContext(ctx, "POST", srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 200 || len(rec.get()) != 3 {
+		t.Errorf("createPet: status = %d after %d attempts, want 200 after 3", resp.StatusCode, len(rec.get()))
+	}
+
+	// WithPolicy takes precedence over the policy of the operation.
+	srv2, rec2 := server(t, 503)
+	req, _ = http.NewRequestWithContext(WithPolicy(ctx, Policy{MaxAttempts: 1}), "GET", srv2.URL, nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 503 || len(rec2.get()) != 1 {
+		t.Errorf("WithPolicy: status = %d after %d attempts, want 503 after 1", resp.StatusCode, len(rec2.get()))
+	}
+}
+
+func TestTransport_AttemptTimeout(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	policy := fast
+	policy.AttemptTimeout = 50 * time.Millisecond
+	client := &http.Client{Transport: &Transport{Policy: policy}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil || string(body) != "ok" || calls.Load() != 2 {
+		t.Errorf("body = %q, %v after %d attempts, want ok after 2", body, err, calls.Load())
+	}
+}
+
+func TestTransport_Canceled(t *testing.T) {
+	srv, rec := server(t, 503, 503)
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := Policy{MinBackoff: time.Second, MaxBackoff: time.Second}
+	client := &http.Client{Transport: &Transport{Policy: policy}}
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != 503 || len(rec.get()) != 1 {
+		t.Errorf("status = %d after %d attempts, want 503 after 1", resp.StatusCode, len(rec.get()))
+	}
+}
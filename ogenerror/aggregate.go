@@ -0,0 +1,121 @@
+package ogenerror
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Item is the error of one call of a fan-out or batch.
+type Item struct {
+	// Key identifies the call, such as the ID of the resource it was for.
+	// Join keys the errors by their position, from "0".
+	Key string
+	Err error
+}
+
+// Aggregate collects the errors of concurrent or batch calls, keeping which
+// call failed with what. The zero value is empty and ready to use, and Add
+// is safe to call from several goroutines.
+//
+// Usage:
+//
+//	var errs ogenerror.Aggregate
+//	var wg sync.WaitGroup
+//	for _, id := range ids {
+//	    wg.Go(func() {
+//	        _, err := client.GetPet(ctx, api.GetPetParams{ID: id})
+//	        errs.Add(id, err)
+//	    })
+//	}
+//	wg.Wait()
+//	if errs.AnyRetryable() { ... }
+//	return errs.Err()
+type Aggregate struct {
+	mu    sync.Mutex
+	items []Item
+}
+
+// Join returns an Aggregate of the non-nil errors, keyed by their position,
+// or nil if all are nil.
+func Join(errs ...error) error {
+	var a Aggregate
+	for i, err := range errs {
+		a.Add(strconv.Itoa(i), err)
+	}
+	return a.Err()
+}
+
+// Add records the error of the call key. A nil error is ignored.
+func (a *Aggregate) Add(key string, err error) {
+	if err == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.items = append(a.items, Item{Key: key, Err: err})
+}
+
+// Err returns a, or nil if it has no errors.
+func (a *Aggregate) Err() error {
+	if a.Len() == 0 {
+		return nil
+	}
+	return a
+}
+
+// Len returns the number of errors.
+func (a *Aggregate) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.items)
+}
+
+// Items returns the errors in the order they were added.
+func (a *Aggregate) Items() []Item {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Item(nil), a.items...)
+}
+
+// Error lists the errors, one per line, each after the key of its call.
+func (a *Aggregate) Error() string {
+	items := a.Items()
+	lines := make([]string, len(items))
+	for i, item := range items {
+		lines[i] = item.Key + ": " + item.Err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the errors, for errors.Is and errors.As to match any of
+// them.
+func (a *Aggregate) Unwrap() []error {
+	items := a.Items()
+	errs := make([]error, len(items))
+	for i, item := range items {
+		errs[i] = item.Err
+	}
+	return errs
+}
+
+// WorstStatus returns the highest status code of the errors, which puts
+// server errors before client errors, or 0 if none has one.
+func (a *Aggregate) WorstStatus() int {
+	worst := 0
+	for _, item := range a.Items() {
+		worst = max(worst, StatusCode(item.Err))
+	}
+	return worst
+}
+
+// AnyRetryable reports whether any of the errors is retryable under
+// DefaultRetryPolicy.
+func (a *Aggregate) AnyRetryable() bool {
+	for _, item := range a.Items() {
+		if IsRetryable(item.Err) {
+			return true
+		}
+	}
+	return false
+}
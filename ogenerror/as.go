@@ -0,0 +1,44 @@
+package ogenerror
+
+// As finds the first error in the chain of err that is a *T or a T, such as
+// the *ErrorStatusCode ogen generates for default responses, and returns it.
+// An error with a GetResponse method returning T, as the generated
+// *StatusCode wrappers have, gives the response it wraps. Unlike
+// errors.As, T need not implement error.
+//
+// Usage:
+//
+//	if apiErr, ok := ogenerror.As[api.ErrorStatusCode](err); ok {
+//	    fmt.Println(apiErr.StatusCode, apiErr.Response.Message)
+//	}
+//	if body, ok := ogenerror.As[api.Error](err); ok {
+//	    fmt.Println(body.Message)
+//	}
+func As[T any](err error) (*T, bool) {
+	for err != nil {
+		switch e := any(err).(type) {
+		case *T:
+			return e, true
+		case T:
+			return &e, true
+		case interface{ GetResponse() T }:
+			response := e.GetResponse()
+			return &response, true
+		}
+
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				if found, ok := As[T](err); ok {
+					return found, true
+				}
+			}
+			return nil, false
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
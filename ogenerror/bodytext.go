@@ -0,0 +1,97 @@
+package ogenerror
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"html"
+	"mime"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// binaryPreview is the number of bytes of a binary body BodyText dumps.
+const binaryPreview = 64
+
+var (
+	htmlTitle    = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	htmlNonText  = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>|<!--.*?-->`)
+	htmlTag      = regexp.MustCompile(`(?s)<[^>]*>`)
+	spacesInLine = regexp.MustCompile(`[ \t\r\f\v]+`)
+)
+
+// BodyText returns the response body of an ogen error in a form readable in
+// logs and alerts, by its Content-Type:
+//
+//   - JSON is indented;
+//   - HTML, such as the error page of a proxy, is reduced to its title and
+//     text;
+//   - other text is returned as it is;
+//   - binary data is dumped in hex, up to its first 64 bytes.
+//
+// A body without a Content-Type is taken for JSON or text if it is one, and
+// binary otherwise. Returns "" if the error is not an ogen
+// UnexpectedStatusCodeError or its response has no body. The body is not
+// redacted: see Sanitize.
+func BodyText(err error) string {
+	status := parse(err)
+	if status == nil || len(status.Body) == 0 {
+		return ""
+	}
+	return bodyText(status.Body, status.Header.Get("Content-Type"))
+}
+
+// bodyText describes body of the given content type.
+func bodyText(body []byte, contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return htmlText(body)
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") ||
+		mediaType == "" && json.Valid(body):
+		var indented bytes.Buffer
+		if json.Indent(&indented, body, "", "  ") == nil {
+			return indented.String()
+		}
+		return string(body)
+	case strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "xml") ||
+		mediaType == "application/x-www-form-urlencoded" ||
+		mediaType == "" && utf8.Valid(body) && !bytes.ContainsRune(body, 0):
+		return string(body)
+	default:
+		preview := body[:min(len(body), binaryPreview)]
+		text := hex.Dump(preview)
+		if len(preview) < len(body) {
+			text += "... (" + strconv.Itoa(len(body)) + " bytes)\n"
+		}
+		return text
+	}
+}
+
+// htmlText returns the title of an HTML page and its text, without tags,
+// scripts and styles, one paragraph per line.
+func htmlText(body []byte) string {
+	page := string(body)
+	title := ""
+	if m := htmlTitle.FindStringSubmatch(page); m != nil {
+		title = strings.TrimSpace(html.UnescapeString(htmlTag.ReplaceAllString(m[1], "")))
+	}
+
+	text := htmlNonText.ReplaceAllString(page, "")
+	text = htmlTag.ReplaceAllString(text, "\n")
+	text = html.UnescapeString(text)
+	text = spacesInLine.ReplaceAllString(text, " ")
+	var lines []string
+	for line := range strings.Lines(text) {
+		if line = strings.TrimSpace(line); line != "" && line != title {
+			lines = append(lines, line)
+		}
+	}
+
+	if title != "" {
+		lines = append([]string{title, ""}, lines...)
+	}
+	return strings.Join(lines, "\n")
+}
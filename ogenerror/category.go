@@ -0,0 +1,91 @@
+package ogenerror
+
+import "maps"
+
+// StatusCategory groups status codes that call for the same handling. It is
+// a string so that policies can declare categories of their own, such as
+// "conflict-retryable".
+type StatusCategory string
+
+// Categories of DefaultCategoryPolicy.
+const (
+	// CategoryNone is the category of errors without a status code.
+	CategoryNone      StatusCategory = ""
+	CategoryClient    StatusCategory = "client"
+	CategoryServer    StatusCategory = "server"
+	CategoryAuth      StatusCategory = "auth"
+	CategoryNotFound  StatusCategory = "not_found"
+	CategoryConflict  StatusCategory = "conflict"
+	CategoryThrottled StatusCategory = "throttled"
+	CategoryTransient StatusCategory = "transient"
+	CategoryPermanent StatusCategory = "permanent"
+)
+
+// CategoryPolicy maps status codes to categories. Status codes it does not
+// list are CategoryClient if 4xx, CategoryServer if 5xx, and CategoryNone
+// otherwise.
+type CategoryPolicy struct {
+	Statuses map[int]StatusCategory
+}
+
+// DefaultCategoryPolicy puts 401 and 403 in CategoryAuth, 404 and 410 in
+// CategoryNotFound, 409 in CategoryConflict, 429 in CategoryThrottled, and
+// 408, 502, 503 and 504 in CategoryTransient.
+var DefaultCategoryPolicy = CategoryPolicy{
+	Statuses: map[int]StatusCategory{
+		401: CategoryAuth,
+		403: CategoryAuth,
+		404: CategoryNotFound,
+		410: CategoryNotFound,
+		409: CategoryConflict,
+		429: CategoryThrottled,
+		408: CategoryTransient,
+		502: CategoryTransient,
+		503: CategoryTransient,
+		504: CategoryTransient,
+	},
+}
+
+// Category returns the category of the status code of err under
+// DefaultCategoryPolicy.
+func Category(err error) StatusCategory {
+	return DefaultCategoryPolicy.Category(err)
+}
+
+// With returns a copy of p with the categories of statuses added, replacing
+// those p has for the same status codes. p is not modified.
+//
+// Usage:
+//
+//	policy := ogenerror.DefaultCategoryPolicy.With(map[int]ogenerror.StatusCategory{
+//	    425: ogenerror.CategoryThrottled,
+//	    409: "conflict-retryable",
+//	    501: ogenerror.CategoryPermanent,
+//	})
+//	switch policy.Category(err) { ... }
+func (p CategoryPolicy) With(statuses map[int]StatusCategory) CategoryPolicy {
+	merged := maps.Clone(p.Statuses)
+	if merged == nil {
+		merged = make(map[int]StatusCategory, len(statuses))
+	}
+	maps.Copy(merged, statuses)
+	return CategoryPolicy{Statuses: merged}
+}
+
+// Category returns the category of the status code of err under p, from an
+// ogen UnexpectedStatusCodeError or a generated error with a GetStatusCode
+// method. Returns CategoryNone for errors without a status code.
+func (p CategoryPolicy) Category(err error) StatusCategory {
+	code := StatusCode(err)
+	if category, ok := p.Statuses[code]; ok {
+		return category
+	}
+	switch {
+	case code >= 400 && code < 500:
+		return CategoryClient
+	case code >= 500 && code < 600:
+		return CategoryServer
+	default:
+		return CategoryNone
+	}
+}
@@ -0,0 +1,37 @@
+package ogenerror
+
+// Cause returns the error at the root of the chain of err, such as the
+// *validate.UnexpectedStatusCodeError of an unexpected status or the
+// syscall.Errno of a refused connection. It follows the Unwrap methods of
+// the chain, including that of an UnexpectedStatus from Parse, and the first
+// error of errors joined with errors.Join. Returns err if it wraps nothing,
+// and nil for a nil error.
+//
+// Usage:
+//
+//	log.Printf("get pet: %v (cause: %T)", err, ogenerror.Cause(err))
+func Cause(err error) error {
+	for {
+		next := unwrapOnce(err)
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+}
+
+// unwrapOnce returns the error err wraps, or the first non-nil error of a
+// joined error, or nil.
+func unwrapOnce(err error) error {
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return e.Unwrap()
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -3,21 +3,97 @@
 package ogenerror
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ogen-go/ogen/validate"
 )
 
-// UnexpectedStatus contains the status code and response body from an
-// ogen UnexpectedStatusCodeError.
+// UnexpectedStatus contains the status code, headers and response body from
+// an ogen UnexpectedStatusCodeError, and the request that got the response.
 type UnexpectedStatus struct {
 	StatusCode int
-	Body       []byte
+	// Header holds the response headers. It is empty, not nil, for errors
+	// without a response.
+	Header http.Header
+	Body   []byte
+
+	// Method and URL are those of the request, if the error has it.
+	Method string
+	URL    *url.URL
+	// Operation is the name of the ogen operation, if the context of the
+	// request has one from WithOperation.
+	Operation string
+
+	// Decoded is the application error a decoder registered with Register
+	// made of the response, or nil if none did.
+	Decoded error
+
+	// cause is the error Parse extracted the status from.
+	cause error
+}
+
+// String describes the failed request for logs, such as
+// "listPets: GET https://api.example.com/pets: unexpected status 500". The
+// query of the URL is left out, as it may hold credentials.
+func (s *UnexpectedStatus) String() string {
+	request := strings.TrimSpace(s.Method + " " + s.redactedURL())
+
+	var parts []string
+	for _, part := range []string{s.Operation, request, "unexpected status " + strconv.Itoa(s.StatusCode)} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ": ")
+}
+
+// redactedURL returns the URL of the request without its query, fragment
+// and credentials, or "" if the error has none.
+func (s *UnexpectedStatus) redactedURL() string {
+	if s.URL == nil {
+		return ""
+	}
+	u := *s.URL
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+type operationKey struct{}
+
+// WithOperation returns a copy of ctx that names the ogen operation it is
+// passed to, for Parse to report in UnexpectedStatus.Operation. Set it in an
+// Invoker wrapper, where the operation is known, or at the call:
+//
+//	ctx = ogenerror.WithOperation(ctx, api.ListPetsOperation)
+//	pets, err := client.ListPets(ctx, params)
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
 }
 
-// Parse extracts status code and response body from an ogen error.
-// Returns nil if the error is not an ogen UnexpectedStatusCodeError.
+// Operation returns the name of the ogen operation ctx was given by
+// WithOperation, or "" if it has none.
+func Operation(ctx context.Context) string {
+	operation, _ := ctx.Value(operationKey{}).(string)
+	return operation
+}
+
+// Parse extracts status code, headers and response body from an ogen error.
+// Returns nil if the error is not an ogen UnexpectedStatusCodeError, or an
+// UnexpectedStatus returned as an error, such as one UnmarshalJSON
+// rehydrated. The errors ogen generates for default responses, such as *ErrorStatusCode,
+// hold the decoded response instead: get them with errors.As. StatusCode,
+// IsStatus, Is4xx and Is5xx handle both.
 //
 // Usage:
 //
@@ -27,42 +103,87 @@ type UnexpectedStatus struct {
 //	        fmt.Printf("Status: %d, Body: %s\n", status.StatusCode, status.Body)
 //	    }
 //	}
+//
+// Decoders registered with Register are tried on the response, and the
+// application error they make of it is set as Decoded.
 func Parse(err error) *UnexpectedStatus {
+	status := parse(err)
+	if status != nil {
+		status.Decoded = decode(status)
+	}
+	return status
+}
+
+// parse is Parse without the registered decoders, for the helpers that need
+// only the response.
+func parse(err error) *UnexpectedStatus {
 	if err == nil {
 		return nil
 	}
 
 	var ogenErr *validate.UnexpectedStatusCodeError
 	if !errors.As(err, &ogenErr) {
+		// An UnexpectedStatus returned as an error, or rehydrated by
+		// UnmarshalJSON, stands for the ogen error it came from.
+		var status *UnexpectedStatus
+		if errors.As(err, &status) && status != nil {
+			result := *status
+			return &result
+		}
 		return nil
 	}
 
 	result := &UnexpectedStatus{
 		StatusCode: ogenErr.StatusCode,
+		Header:     http.Header{},
+		cause:      err,
+	}
+	if ogenErr.Payload != nil && ogenErr.Payload.Header != nil {
+		result.Header = ogenErr.Payload.Header
+	}
+	if ogenErr.Payload != nil && ogenErr.Payload.Request != nil {
+		req := ogenErr.Payload.Request
+		result.Method = req.Method
+		result.URL = req.URL
+		result.Operation = Operation(req.Context())
 	}
 
-	// Try to read the response body
+	// Try to read the response body, and put it back so that the error
+	// can be parsed again.
 	if ogenErr.Payload != nil && ogenErr.Payload.Body != nil {
 		body, readErr := io.ReadAll(ogenErr.Payload.Body)
 		if readErr == nil {
 			result.Body = body
+			ogenErr.Payload.Body = io.NopCloser(bytes.NewReader(body))
 		}
 	}
 
 	return result
 }
 
-// StatusCode extracts just the status code from an ogen error.
-// Returns 0 if the error is not an ogen UnexpectedStatusCodeError.
+// statusCoder is implemented by the errors ogen generates for responses the
+// spec declares with a default status code, such as *ErrorStatusCode, which
+// carry the decoded response rather than its body.
+type statusCoder interface {
+	GetStatusCode() int
+}
+
+// StatusCode extracts just the status code from an ogen error: an
+// UnexpectedStatusCodeError, or a generated error with a GetStatusCode
+// method, such as *ErrorStatusCode. Returns 0 for other errors.
 func StatusCode(err error) int {
-	if status := Parse(err); status != nil {
+	if status := parse(err); status != nil {
 		return status.StatusCode
 	}
+	var coded statusCoder
+	if errors.As(err, &coded) {
+		return coded.GetStatusCode()
+	}
 	return 0
 }
 
-// IsStatus returns true if the error is an ogen UnexpectedStatusCodeError
-// with the given status code.
+// IsStatus returns true if the error is an ogen error with the given status
+// code.
 func IsStatus(err error, code int) bool {
 	return StatusCode(err) == code
 }
@@ -78,3 +199,40 @@ func Is5xx(err error) bool {
 	code := StatusCode(err)
 	return code >= 500 && code < 600
 }
+
+// RetryAfter returns the delay the Retry-After header of an ogen error's
+// response asks for, in either its delta-seconds or its HTTP-date form. A
+// date in the past gives 0. Returns false if the error is not an ogen
+// UnexpectedStatusCodeError, or the response has no valid Retry-After.
+//
+// Usage:
+//
+//	if delay, ok := ogenerror.RetryAfter(err); ok {
+//	    time.Sleep(delay)
+//	}
+func RetryAfter(err error) (time.Duration, bool) {
+	status := parse(err)
+	if status == nil {
+		return 0, false
+	}
+	return parseRetryAfter(status.Header.Get("Retry-After"), time.Now())
+}
+
+// parseRetryAfter parses a Retry-After header value relative to now.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 || seconds > math.MaxInt64/int64(time.Second) {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
@@ -0,0 +1,135 @@
+package ogenerror
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ogen-go/ogen/ogenerrors"
+	"github.com/ogen-go/ogen/validate"
+)
+
+// FieldError is a failure of one field of a request or response that does
+// not match the spec.
+type FieldError struct {
+	// In is "body", or the location of a parameter: "path", "query",
+	// "header" or "cookie".
+	In string
+	// Path locates the field in the body, such as "owner.email" or
+	// "tags[1]", or starts with the name of the parameter. It is "" for
+	// failures of the body as a whole, such as a missing body or malformed
+	// JSON outside any field.
+	Path string
+	// Message describes the failure, such as "field required" or
+	// "len 0 less than minimum 1".
+	Message string
+}
+
+// FieldErrors extracts the failures of an ogen validation or decoding error:
+// a request a generated server rejected, or a response a generated client
+// could not decode or validate. Returns nil for other errors, such as
+// transport failures and unexpected status codes.
+//
+// Usage:
+//
+//	func errorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+//	    if fields := ogenerror.FieldErrors(err); fields != nil {
+//	        w.WriteHeader(http.StatusBadRequest)
+//	        _ = json.NewEncoder(w).Encode(map[string]any{"errors": fields})
+//	        return
+//	    }
+//	    ogenerrors.DefaultErrorHandler(ctx, w, r, err)
+//	}
+func FieldErrors(err error) []FieldError {
+	if err == nil || parse(err) != nil {
+		return nil
+	}
+
+	var paramErr *ogenerrors.DecodeParamError
+	if errors.As(err, &paramErr) {
+		return fieldErrors(paramErr.Err, string(paramErr.In), paramErr.Name)
+	}
+	var contentTypeErr *validate.InvalidContentTypeError
+	if errors.As(err, &contentTypeErr) {
+		return []FieldError{{In: "body", Message: contentTypeErr.Error()}}
+	}
+	if errors.Is(err, validate.ErrBodyRequired) {
+		return []FieldError{{In: "body", Message: validate.ErrBodyRequired.Error()}}
+	}
+	var bodyErr *ogenerrors.DecodeBodyError
+	if errors.As(err, &bodyErr) {
+		return fieldErrors(bodyErr.Err, "body", "")
+	}
+	var validateErr *validate.Error
+	if errors.As(err, &validateErr) {
+		return fieldErrors(validateErr, "body", "")
+	}
+	return nil
+}
+
+// IsSchemaError reports whether err is an ogen validation or decoding error,
+// which FieldErrors extracts: on the client, a sign that the API sent a
+// response its spec does not describe rather than that the request failed
+// to reach it.
+func IsSchemaError(err error) bool {
+	return len(FieldErrors(err)) > 0
+}
+
+// fieldErrors flattens the validation errors in the chain of err, whose
+// field is at path. The chain adds to the path through the fields the
+// generated decoders name, and branches at validate.Error.
+func fieldErrors(err error, in, path string) []FieldError {
+	failure := err
+	for e := err; e != nil; e = unwrapOnce(e) {
+		if v, ok := e.(*validate.Error); ok {
+			var out []FieldError
+			for _, f := range v.Fields {
+				out = append(out, fieldErrors(f.Error, in, joinPath(path, f.Name))...)
+			}
+			return out
+		}
+		if name, ok := decodedField(e); ok {
+			path = joinPath(path, name)
+			failure = unwrapOnce(e)
+		}
+	}
+
+	message := ""
+	if failure != nil {
+		message = generatedWrappers.ReplaceAllString(failure.Error(), "")
+	}
+	return []FieldError{{In: in, Path: path, Message: message}}
+}
+
+// generatedWrappers matches the prefixes generated decoders and validators
+// add to the messages of errors, such as "decode Pet: " or "string: ".
+var generatedWrappers = regexp.MustCompile(`^(?:(?:decode [^:"]+|callback|validate|string|int|float|decimal|array|pointer): )+`)
+
+// decodedField returns the name of the field an error of a generated JSON
+// decoder wraps, from its message, such as `decode field "name": ...`.
+func decodedField(err error) (string, bool) {
+	own := err.Error()
+	if next := unwrapOnce(err); next != nil {
+		own = strings.TrimSuffix(own, ": "+next.Error())
+	}
+	quoted, ok := strings.CutPrefix(own, "decode field ")
+	if !ok {
+		return "", false
+	}
+	name, unquoteErr := strconv.Unquote(quoted)
+	return name, unquoteErr == nil
+}
+
+// joinPath appends the name of a field, or an index such as "[1]", to
+// path.
+func joinPath(path, name string) string {
+	switch {
+	case path == "":
+		return name
+	case strings.HasPrefix(name, "["):
+		return path + name
+	default:
+		return path + "." + name
+	}
+}
@@ -0,0 +1,98 @@
+package ogenerror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/ogen-go/ogen/ogenerrors"
+	"github.com/ogen-go/ogen/openapi"
+	"github.com/ogen-go/ogen/validate"
+)
+
+// field wraps err as a generated JSON decoder does for the field name.
+func field(name string, err error) error {
+	return fmt.Errorf("decode field %s: %w", strconv.Quote(name), err)
+}
+
+func TestFieldErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want []FieldError
+	}{
+		{
+			name: "nested fields",
+			err: &ogenerrors.DecodeBodyError{ContentType: "application/json", Err: fmt.Errorf("decode Pet: %w",
+				field("owner", fmt.Errorf("decode Owner: %w", field("email", errors.New("string: unexpected number")))))},
+			want: []FieldError{{In: "body", Path: "owner.email", Message: "unexpected number"}},
+		},
+		{
+			name: "validation",
+			err: fmt.Errorf("validate: %w", &validate.Error{Fields: []validate.FieldError{
+				{Name: "name", Error: &validate.MinLengthError{Len: 0, MinLength: 1}},
+				{Name: "tags", Error: &validate.Error{Fields: []validate.FieldError{
+					{Name: "[1]", Error: &validate.MaxLengthError{Len: 40, MaxLength: 32}},
+				}}},
+			}}),
+			want: []FieldError{
+				{In: "body", Path: "name", Message: "len 0 less than minimum 1"},
+				{In: "body", Path: "tags[1]", Message: "len 40 greater than maximum 32"},
+			},
+		},
+		{
+			name: "body as a whole",
+			err:  &ogenerrors.DecodeBodyError{ContentType: "application/json", Err: errors.New("decode Pet: unexpected EOF")},
+			want: []FieldError{{In: "body", Message: "unexpected EOF"}},
+		},
+		{
+			name: "parameter",
+			err:  &ogenerrors.DecodeParamError{Name: "filter", In: openapi.LocationQuery, Err: field("limit", errors.New("int: invalid syntax"))},
+			want: []FieldError{{In: "query", Path: "filter.limit", Message: "invalid syntax"}},
+		},
+		{
+			name: "body required",
+			err:  fmt.Errorf("decode request: %w", validate.ErrBodyRequired),
+			want: []FieldError{{In: "body", Message: "body required"}},
+		},
+		{
+			name: "content type",
+			err:  &validate.InvalidContentTypeError{ContentType: "text/plain"},
+			want: []FieldError{{In: "body", Message: `unexpected Content-Type: text/plain`}},
+		},
+		{name: "unexpected status", err: statusError(t, context.Background(), 400, nil, "", "https://api.example.com/")},
+		{name: "other error", err: errors.New("connection refused")},
+		{name: "nil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FieldErrors(tt.err)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FieldErrors() = %+v, want %+v", got, tt.want)
+			}
+			if IsSchemaError(tt.err) != (tt.want != nil) {
+				t.Errorf("IsSchemaError() = %v", IsSchemaError(tt.err))
+			}
+		})
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	tests := []struct {
+		path, name, want string
+	}{
+		{"", "name", "name"},
+		{"", "[0]", "[0]"},
+		{"owner", "email", "owner.email"},
+		{"tags", "[1]", "tags[1]"},
+		{"tags[1]", "label", "tags[1].label"},
+	}
+	for _, tt := range tests {
+		if got := joinPath(tt.path, tt.name); got != tt.want {
+			t.Errorf("joinPath(%q, %q) = %q, want %q", tt.path, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package ogenerror
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// JSONAPIError is an error object of a JSON:API error document, such as
+// {"errors": [{"status": "422", "title": "Invalid Attribute", ...}]}.
+type JSONAPIError struct {
+	// ID identifies this occurrence of the problem.
+	ID string
+	// Status is the HTTP status code of the problem, as a string.
+	Status string
+	// Code is an application-specific error code.
+	Code string
+	// Title is a short, human-readable summary of the problem.
+	Title string
+	// Detail is a human-readable explanation of this occurrence of the
+	// problem.
+	Detail string
+	// Source locates the cause of the problem in the request.
+	Source JSONAPISource
+	// Meta holds the non-standard meta-information of the error, as raw
+	// JSON.
+	Meta map[string]json.RawMessage
+}
+
+// JSONAPISource locates the cause of a JSON:API error in the request.
+type JSONAPISource struct {
+	// Pointer is a JSON Pointer to the value in the request document that
+	// caused the error, such as "/data/attributes/title".
+	Pointer string
+	// Parameter names the query parameter that caused the error.
+	Parameter string
+	// Header names the request header that caused the error.
+	Header string
+}
+
+// StatusCode returns Status as an int, or 0 if it is not a number.
+func (e *JSONAPIError) StatusCode() int {
+	code, err := strconv.Atoi(e.Status)
+	if err != nil {
+		return 0
+	}
+	return code
+}
+
+// JSONAPIErrors extracts the error objects of an ogen error whose response
+// is a JSON:API error document. The content type is not checked, as many
+// APIs send these documents as application/json. Returns false if the error
+// is not an ogen UnexpectedStatusCodeError, or its body is not an error
+// document.
+//
+// Usage:
+//
+//	if errs, ok := ogenerror.JSONAPIErrors(err); ok {
+//	    for _, e := range errs {
+//	        fmt.Printf("%s: %s (%s)\n", e.Code, e.Detail, e.Source.Pointer)
+//	    }
+//	}
+func JSONAPIErrors(err error) ([]JSONAPIError, bool) {
+	status := parse(err)
+	if status == nil {
+		return nil, false
+	}
+	return ParseJSONAPIErrors(status.Body)
+}
+
+// ParseJSONAPIErrors decodes a JSON:API error document: an object whose
+// "errors" member is a non-empty array of error objects. Members of the
+// wrong type are ignored. Returns false if the body is not an error
+// document.
+func ParseJSONAPIErrors(body []byte) ([]JSONAPIError, bool) {
+	var doc struct {
+		Errors []map[string]json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil || len(doc.Errors) == 0 {
+		return nil, false
+	}
+
+	errs := make([]JSONAPIError, 0, len(doc.Errors))
+	for _, members := range doc.Errors {
+		if members == nil {
+			return nil, false
+		}
+		var e JSONAPIError
+		for name, value := range members {
+			var field any
+			switch name {
+			case "id":
+				field = &e.ID
+			case "status":
+				field = &e.Status
+			case "code":
+				field = &e.Code
+			case "title":
+				field = &e.Title
+			case "detail":
+				field = &e.Detail
+			case "source":
+				var source map[string]json.RawMessage
+				_ = json.Unmarshal(value, &source)
+				_ = json.Unmarshal(source["pointer"], &e.Source.Pointer)
+				_ = json.Unmarshal(source["parameter"], &e.Source.Parameter)
+				_ = json.Unmarshal(source["header"], &e.Source.Header)
+				continue
+			case "meta":
+				_ = json.Unmarshal(value, &e.Meta)
+				continue
+			default:
+				continue
+			}
+			_ = json.Unmarshal(value, field)
+		}
+		errs = append(errs, e)
+	}
+	return errs, true
+}
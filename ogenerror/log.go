@@ -0,0 +1,38 @@
+package ogenerror
+
+import "log/slog"
+
+// LogValue implements slog.LogValuer, so that structured logs get the
+// status code, the operation, the request, the request ID of RequestID and
+// the body of the response as separate attributes:
+//
+//	slog.Error("get pet failed", "upstream", ogenerror.Parse(err))
+//	// upstream.status_code=404 upstream.operation=getPet upstream.method=GET
+//	// upstream.url=https://api.example.com/pets/1 upstream.body="{\"message\":\"not found\"}"
+//
+// The URL is left without its query and credentials, and the body is
+// redacted by DefaultSanitizer. Attributes the error has no value for are
+// left out.
+func (s *UnexpectedStatus) LogValue() slog.Value {
+	if s == nil {
+		return slog.AnyValue(nil)
+	}
+
+	attrs := []slog.Attr{slog.Int("status_code", s.StatusCode)}
+	if s.Operation != "" {
+		attrs = append(attrs, slog.String("operation", s.Operation))
+	}
+	if s.Method != "" {
+		attrs = append(attrs, slog.String("method", s.Method))
+	}
+	if u := s.redactedURL(); u != "" {
+		attrs = append(attrs, slog.String("url", u))
+	}
+	if id := requestID(s.Header); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if len(s.Body) > 0 {
+		attrs = append(attrs, slog.String("body", string(DefaultSanitizer.Sanitize(s.Body))))
+	}
+	return slog.GroupValue(attrs...)
+}
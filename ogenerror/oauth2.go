@@ -0,0 +1,81 @@
+package ogenerror
+
+import (
+	"encoding/json"
+	"mime"
+	"net/url"
+)
+
+// Error codes of OAuth 2.0 token endpoints, from RFC 6749 section 5.2.
+const (
+	OAuth2InvalidRequest       = "invalid_request"
+	OAuth2InvalidClient        = "invalid_client"
+	OAuth2InvalidGrant         = "invalid_grant"
+	OAuth2UnauthorizedClient   = "unauthorized_client"
+	OAuth2UnsupportedGrantType = "unsupported_grant_type"
+	OAuth2InvalidScope         = "invalid_scope"
+)
+
+// OAuth2Error is the error response of an OAuth 2.0 or OpenID Connect token
+// endpoint, such as {"error": "invalid_grant"}.
+type OAuth2Error struct {
+	// Code is the "error" member, such as OAuth2InvalidGrant.
+	Code string
+	// Description is the "error_description" member, a human-readable
+	// explanation.
+	Description string
+	// URI is the "error_uri" member, a page about the error.
+	URI string
+}
+
+// OAuth2 extracts the OAuth 2.0 error of an ogen error whose response is the
+// error response of a token endpoint, in JSON or form-encoded. Returns false
+// if the error is not an ogen UnexpectedStatusCodeError, or its body has no
+// "error" member.
+//
+// Usage:
+//
+//	if oauthErr, ok := ogenerror.OAuth2(err); ok && oauthErr.Code == ogenerror.OAuth2InvalidGrant {
+//	    // The refresh token expired or was revoked: sign in again
+//	}
+func OAuth2(err error) (*OAuth2Error, bool) {
+	status := parse(err)
+	if status == nil {
+		return nil, false
+	}
+	return ParseOAuth2(status.Body, status.Header.Get("Content-Type"))
+}
+
+// ParseOAuth2 decodes the error response of a token endpoint. A body of
+// content type application/x-www-form-urlencoded is decoded as a form, and
+// any other body as JSON, or as a form if it is not JSON. Returns false if
+// the body has no "error" member.
+func ParseOAuth2(body []byte, contentType string) (*OAuth2Error, bool) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "application/x-www-form-urlencoded" {
+		var members struct {
+			Error            any `json:"error"`
+			ErrorDescription any `json:"error_description"`
+			ErrorURI         any `json:"error_uri"`
+		}
+		if json.Unmarshal(body, &members) == nil {
+			code, _ := members.Error.(string)
+			if code == "" {
+				return nil, false
+			}
+			description, _ := members.ErrorDescription.(string)
+			uri, _ := members.ErrorURI.(string)
+			return &OAuth2Error{Code: code, Description: description, URI: uri}, true
+		}
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil || form.Get("error") == "" {
+		return nil, false
+	}
+	return &OAuth2Error{
+		Code:        form.Get("error"),
+		Description: form.Get("error_description"),
+		URI:         form.Get("error_uri"),
+	}, true
+}
@@ -0,0 +1,115 @@
+// Package ogengrpc maps the errors of ogen clients to gRPC codes and
+// statuses, for gRPC handlers that call REST APIs.
+//
+// It is a package of its own so that ogenerror does not depend on grpc and
+// protobuf.
+package ogengrpc
+
+import (
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+// grpcCodes maps HTTP status codes to the gRPC codes of the same meaning,
+// following google.rpc.Code.
+var grpcCodes = map[int]codes.Code{
+	400: codes.InvalidArgument,
+	401: codes.Unauthenticated,
+	403: codes.PermissionDenied,
+	404: codes.NotFound,
+	408: codes.DeadlineExceeded,
+	409: codes.Aborted,
+	410: codes.NotFound,
+	412: codes.FailedPrecondition,
+	416: codes.OutOfRange,
+	422: codes.InvalidArgument,
+	429: codes.ResourceExhausted,
+	499: codes.Canceled,
+	500: codes.Internal,
+	501: codes.Unimplemented,
+	502: codes.Unavailable,
+	503: codes.Unavailable,
+	504: codes.DeadlineExceeded,
+}
+
+// Code returns the gRPC code matching an error of an ogen client, for
+// gRPC handlers that call REST APIs:
+//
+//   - the status code of an ogen error, as google.rpc.Code maps them, such
+//     as NotFound for 404, Internal for other 5xx and Unknown for other 4xx;
+//   - Canceled for ogenerror.IsCanceled, and DeadlineExceeded for
+//     ogenerror.IsTimeout;
+//   - Unavailable for ogenerror.IsConnectionError;
+//   - the code of an error that has a gRPC status already.
+//
+// Returns OK for a nil error and Unknown for other errors.
+func Code(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+
+	if code := ogenerror.StatusCode(err); code != 0 {
+		if c, ok := grpcCodes[code]; ok {
+			return c
+		}
+		if code >= 500 && code < 600 {
+			return codes.Internal
+		}
+		return codes.Unknown
+	}
+
+	switch {
+	case ogenerror.IsCanceled(err):
+		return codes.Canceled
+	case ogenerror.IsTimeout(err):
+		return codes.DeadlineExceeded
+	case ogenerror.IsConnectionError(err):
+		return codes.Unavailable
+	}
+	return codes.Unknown
+}
+
+// Status returns the gRPC status of an error of an ogen client, with the
+// code of Code. For an UnexpectedStatusCodeError, the message is that of
+// ogenerror.UnexpectedStatus.String, and the response body, if any, is attached as a
+// google.api.HttpBody detail:
+//
+//	pet, err := client.GetPet(ctx, params)
+//	if err != nil {
+//	    return nil, ogengrpc.Status(err).Err()
+//	}
+//
+// Returns nil for a nil error, which is the OK status.
+func Status(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+
+	code := Code(err)
+	unexpected := ogenerror.Parse(err)
+	if unexpected == nil {
+		return status.New(code, err.Error())
+	}
+
+	s := status.New(code, unexpected.String())
+	if len(unexpected.Body) == 0 {
+		return s
+	}
+	withBody, detailErr := s.WithDetails(&httpbody.HttpBody{
+		ContentType: unexpected.Header.Get("Content-Type"),
+		Data:        unexpected.Body,
+	})
+	if detailErr != nil {
+		return s
+	}
+	return withBody
+}
@@ -0,0 +1,63 @@
+// Package ogenzap logs ogenerror.UnexpectedStatus with zap, with the same
+// attributes as its slog.LogValuer: the status code, the operation, the
+// request and the redacted body of the response.
+//
+// It is a package of its own so that ogenerror does not depend on zap.
+package ogenzap
+
+import (
+	"log/slog"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/plexusone/ogen-tools/ogenerror"
+)
+
+// Status returns a field that logs s as an object under key:
+//
+//	logger.Error("get pet failed", ogenzap.Status("upstream", ogenerror.Parse(err)))
+//
+// A nil s is logged as null.
+func Status(key string, s *ogenerror.UnexpectedStatus) zap.Field {
+	if s == nil {
+		return zap.Reflect(key, nil)
+	}
+	return zap.Object(key, Object(s))
+}
+
+// Error returns a field that logs the response of err under key if it is an
+// ogen UnexpectedStatusCodeError, and err itself as zap.NamedError does
+// otherwise.
+func Error(key string, err error) zap.Field {
+	if s := ogenerror.Parse(err); s != nil {
+		return zap.Object(key, Object(s))
+	}
+	return zap.NamedError(key, err)
+}
+
+// Object adapts s to zapcore.ObjectMarshaler.
+func Object(s *ogenerror.UnexpectedStatus) zapcore.ObjectMarshaler {
+	return object{s}
+}
+
+type object struct {
+	s *ogenerror.UnexpectedStatus
+}
+
+// MarshalLogObject adds the attributes of the LogValue of the status to enc.
+func (o object) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, attr := range o.s.LogValue().Group() {
+		switch attr.Value.Kind() {
+		case slog.KindInt64:
+			enc.AddInt64(attr.Key, attr.Value.Int64())
+		case slog.KindString:
+			enc.AddString(attr.Key, attr.Value.String())
+		default:
+			if err := enc.AddReflected(attr.Key, attr.Value.Any()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
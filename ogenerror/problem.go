@@ -0,0 +1,112 @@
+package ogenerror
+
+import (
+	"encoding/json"
+	"mime"
+)
+
+// ProblemDetails is an RFC 7807 problem details object, the error body of
+// application/problem+json responses.
+type ProblemDetails struct {
+	// Type is a URI reference identifying the problem type. It is
+	// "about:blank" if the body has none.
+	Type string
+	// Title is a short, human-readable summary of the problem type.
+	Title string
+	// Status is the HTTP status code of the problem. It is the status code
+	// of the response if the body has none.
+	Status int
+	// Detail is a human-readable explanation of this occurrence of the
+	// problem.
+	Detail string
+	// Instance is a URI reference identifying this occurrence of the
+	// problem.
+	Instance string
+	// Extensions holds the other members of the body, such as "errors" or
+	// "traceId", as raw JSON.
+	Extensions map[string]json.RawMessage
+}
+
+// Problem extracts the problem details from an ogen error whose response is
+// application/problem+json. Returns false if the error is not an ogen
+// UnexpectedStatusCodeError, the response is of another content type, or its
+// body is not a JSON object.
+//
+// Usage:
+//
+//	resp, err := client.SomeMethod(ctx, req)
+//	if err != nil {
+//	    if problem, ok := ogenerror.Problem(err); ok {
+//	        fmt.Printf("%s: %s\n", problem.Title, problem.Detail)
+//	    }
+//	}
+func Problem(err error) (*ProblemDetails, bool) {
+	status := parse(err)
+	if status == nil || !isProblem(status.Header.Get("Content-Type")) {
+		return nil, false
+	}
+
+	problem, ok := ParseProblem(status.Body)
+	if !ok {
+		return nil, false
+	}
+	if problem.Status == 0 {
+		problem.Status = status.StatusCode
+	}
+	return problem, true
+}
+
+// ParseProblem decodes an application/problem+json body. Members of the
+// wrong type are ignored, as RFC 7807 requires. Returns false if the body is
+// not a JSON object.
+func ParseProblem(body []byte) (*ProblemDetails, bool) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil || members == nil {
+		return nil, false
+	}
+
+	problem := &ProblemDetails{Type: "about:blank"}
+	for name, value := range members {
+		var field any
+		switch name {
+		case "type":
+			field = &problem.Type
+		case "title":
+			field = &problem.Title
+		case "status":
+			field = &problem.Status
+		case "detail":
+			field = &problem.Detail
+		case "instance":
+			field = &problem.Instance
+		default:
+			if problem.Extensions == nil {
+				problem.Extensions = make(map[string]json.RawMessage)
+			}
+			problem.Extensions[name] = value
+			continue
+		}
+		_ = json.Unmarshal(value, field)
+	}
+	return problem, true
+}
+
+// Extension decodes the extension member name of the problem into v.
+// Returns false if the problem has no such member or it does not decode
+// into v.
+//
+// Usage:
+//
+//	var errs []struct{ Field, Message string }
+//	if problem.Extension("errors", &errs) { ... }
+func (p *ProblemDetails) Extension(name string, v any) bool {
+	value, ok := p.Extensions[name]
+	return ok && json.Unmarshal(value, v) == nil
+}
+
+// isProblem reports whether a Content-Type header value is
+// application/problem+json, with or without parameters.
+func isProblem(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/problem+json"
+}
@@ -0,0 +1,121 @@
+package ogenerror
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// epochSeconds is the smallest reset value taken for a Unix time rather
+// than a number of seconds, in September 2001.
+const epochSeconds = 1_000_000_000
+
+// RateLimitInfo is the rate limit a response reports in its RateLimit-* or
+// X-RateLimit-* headers.
+type RateLimitInfo struct {
+	// Limit is the number of requests allowed in the window, or -1 if the
+	// response does not say.
+	Limit int
+	// Remaining is the number of requests left in the window, or -1 if the
+	// response does not say.
+	Remaining int
+	// ResetAt is when the window resets, or the zero time if the response
+	// does not say.
+	ResetAt time.Time
+}
+
+// RateLimit extracts the rate limit the response of an ogen error reports.
+// Returns false if the error is not an ogen UnexpectedStatusCodeError, or the
+// response has none of the headers.
+//
+// Usage:
+//
+//	if limit, ok := ogenerror.RateLimit(err); ok && limit.Remaining == 0 {
+//	    time.Sleep(time.Until(limit.ResetAt))
+//	}
+func RateLimit(err error) (*RateLimitInfo, bool) {
+	status := parse(err)
+	if status == nil {
+		return nil, false
+	}
+	return ParseRateLimit(status.Header)
+}
+
+// ParseRateLimit extracts the rate limit headers, such as those of a
+// successful response. The RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers are read first, then their X-RateLimit-
+// counterparts. Reset is taken for a Unix time if it is one, as GitHub
+// sends, for an HTTP-date, and otherwise for a number of seconds. Returns
+// false if the header has none of them.
+func ParseRateLimit(header http.Header) (*RateLimitInfo, bool) {
+	return parseRateLimit(header, time.Now())
+}
+
+// parseRateLimit parses the rate limit headers relative to now.
+func parseRateLimit(header http.Header, now time.Time) (*RateLimitInfo, bool) {
+	info := &RateLimitInfo{Limit: -1, Remaining: -1}
+	found := false
+	if limit, ok := rateLimitInt(header, "Limit"); ok {
+		info.Limit, found = limit, true
+	}
+	if remaining, ok := rateLimitInt(header, "Remaining"); ok {
+		info.Remaining, found = remaining, true
+	}
+	if resetAt, ok := rateLimitReset(header, now); ok {
+		info.ResetAt, found = resetAt, true
+	}
+	if !found {
+		return nil, false
+	}
+	return info, true
+}
+
+// rateLimitValue returns the value of the RateLimit- or X-RateLimit- header
+// with the suffix.
+func rateLimitValue(header http.Header, suffix string) string {
+	value := header.Get("RateLimit-" + suffix)
+	if value == "" {
+		value = header.Get("X-RateLimit-" + suffix)
+	}
+	return strings.TrimSpace(value)
+}
+
+// leadingNumber returns value up to any list or parameter that follows its
+// number, such as the policy in "100, 100;w=60".
+func leadingNumber(value string) string {
+	if i := strings.IndexAny(value, ",;"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// rateLimitInt parses a non-negative count header.
+func rateLimitInt(header http.Header, suffix string) (int, bool) {
+	n, err := strconv.Atoi(leadingNumber(rateLimitValue(header, suffix)))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// rateLimitReset parses the reset header into a time.
+func rateLimitReset(header http.Header, now time.Time) (time.Time, bool) {
+	value := rateLimitValue(header, "Reset")
+	if value == "" {
+		return time.Time{}, false
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date, true
+	}
+
+	seconds, err := strconv.ParseInt(leadingNumber(value), 10, 64)
+	switch {
+	case err != nil || seconds < 0:
+		return time.Time{}, false
+	case seconds >= epochSeconds:
+		return time.Unix(seconds, 0), true
+	default:
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+}
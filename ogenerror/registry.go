@@ -0,0 +1,118 @@
+package ogenerror
+
+import (
+	"cmp"
+	"mime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Decoder makes an application error of the response of an ogen error, such
+// as the error type of a vendor's API. It returns nil if the response is not
+// one it decodes, so that other decoders are tried. An error that wraps s,
+// or has an Unwrap method returning it, keeps errors.Is and errors.As
+// matching the ogen error it came from.
+type Decoder func(status *UnexpectedStatus) error
+
+// decoderEntry is a Decoder and the responses it is registered for.
+type decoderEntry struct {
+	statusCode  int
+	contentType string
+	decode      Decoder
+}
+
+// specificity orders entries with both a status code and a content type
+// first, then those with a status code, then those with a content type.
+func (e decoderEntry) specificity() int {
+	n := 0
+	if e.statusCode != 0 {
+		n += 2
+	}
+	if e.contentType != "" {
+		n++
+	}
+	return n
+}
+
+var (
+	decodersMu sync.RWMutex
+	decoders   []decoderEntry
+)
+
+// Register adds a decoder for responses with the status code and the
+// content type, ignoring its parameters such as charset. A status code of 0
+// or an empty content type matches any. Parse tries the decoders from the
+// most specific, by status code then by content type, in the order they
+// were registered, and keeps the first error one returns. Register is
+// usually called from init functions.
+//
+// Usage:
+//
+//	ogenerror.Register(0, "application/vnd.acme.error+json", func(s *ogenerror.UnexpectedStatus) error {
+//	    var acmeErr acme.Error
+//	    if json.Unmarshal(s.Body, &acmeErr) != nil {
+//	        return nil
+//	    }
+//	    return &acmeErr
+//	})
+func Register(statusCode int, contentType string, decode Decoder) {
+	if contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			contentType = mediaType
+		}
+	}
+	entry := decoderEntry{statusCode: statusCode, contentType: strings.ToLower(contentType), decode: decode}
+
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders = append(decoders, entry)
+	slices.SortStableFunc(decoders, func(a, b decoderEntry) int {
+		return cmp.Compare(b.specificity(), a.specificity())
+	})
+}
+
+// decode returns the error of the first registered decoder that decodes
+// the response, or nil.
+func decode(status *UnexpectedStatus) error {
+	mediaType, _, _ := mime.ParseMediaType(status.Header.Get("Content-Type"))
+
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	for _, entry := range decoders {
+		if entry.statusCode != 0 && entry.statusCode != status.StatusCode ||
+			entry.contentType != "" && entry.contentType != mediaType {
+			continue
+		}
+		if err := entry.decode(status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Classify returns the richest error value available for err: the
+// application error of a registered decoder, then the UnexpectedStatus of
+// an ogen UnexpectedStatusCodeError, and err itself for other errors, such
+// as the *ErrorStatusCode errors ogen generates. Returns nil for a nil
+// error.
+//
+// Usage:
+//
+//	switch e := ogenerror.Classify(err).(type) {
+//	case *acme.Error:
+//	    // Decoded by a registered decoder
+//	case *ogenerror.UnexpectedStatus:
+//	    // No decoder matched
+//	}
+func Classify(err error) error {
+	status := Parse(err)
+	switch {
+	case status == nil:
+		return err
+	case status.Decoded != nil:
+		return status.Decoded
+	default:
+		return status
+	}
+}
@@ -0,0 +1,130 @@
+package ogenerror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// withoutDecoders clears the registered decoders for the test, and restores
+// them after it.
+func withoutDecoders(t *testing.T) {
+	t.Helper()
+	decodersMu.Lock()
+	saved := decoders
+	decoders = nil
+	decodersMu.Unlock()
+	t.Cleanup(func() {
+		decodersMu.Lock()
+		decoders = saved
+		decodersMu.Unlock()
+	})
+}
+
+// decoded is an application error made by a test decoder.
+type decoded struct {
+	by     string
+	status *UnexpectedStatus
+}
+
+func (e *decoded) Error() string { return "decoded by " + e.by }
+func (e *decoded) Unwrap() error { return e.status }
+
+// decodeAs returns a decoder that makes a decoded error named by.
+func decodeAs(by string) Decoder {
+	return func(s *UnexpectedStatus) error {
+		return &decoded{by: by, status: s}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	withoutDecoders(t)
+	// Registered from the least specific, so that the order of
+	// registration alone would pick the wrong one.
+	Register(0, "", decodeAs("any"))
+	Register(0, "", decodeAs("any, second"))
+	Register(0, "application/json", decodeAs("json"))
+	Register(404, "", decodeAs("404"))
+	Register(404, "Application/JSON; charset=utf-8", decodeAs("404 json"))
+	Register(409, "", func(*UnexpectedStatus) error { return nil })
+
+	tests := []struct {
+		code        int
+		contentType string
+		want        string
+	}{
+		{404, "application/json", "404 json"},
+		{404, "application/json; charset=utf-8", "404 json"},
+		{404, "text/plain", "404"},
+		{404, "", "404"},
+		{500, "application/json;charset=UTF-8", "json"},
+		{500, "application/problem+json", "any"},
+		// The decoder for 409 declines, and the next one decodes.
+		{409, "text/plain", "any"},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.code)+" "+tt.contentType, func(t *testing.T) {
+			header := http.Header{}
+			if tt.contentType != "" {
+				header.Set("Content-Type", tt.contentType)
+			}
+			status := Parse(statusError(t, context.Background(), tt.code, header, "{}", "https://api.example.com/"))
+			var got *decoded
+			if !errors.As(status.Decoded, &got) || got.by != tt.want {
+				t.Errorf("Decoded = %v, want decoded by %s", status.Decoded, tt.want)
+			}
+			if got != nil && got.status.StatusCode != tt.code {
+				t.Errorf("decoder got status %d", got.status.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRegister_None(t *testing.T) {
+	withoutDecoders(t)
+	Register(404, "", decodeAs("404"))
+
+	if status := Parse(statusError(t, context.Background(), 500, nil, "", "https://api.example.com/")); status.Decoded != nil {
+		t.Errorf("Decoded = %v, want nil", status.Decoded)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	withoutDecoders(t)
+	Register(404, "", decodeAs("404"))
+
+	notFound := statusError(t, context.Background(), 404, nil, "", "https://api.example.com/")
+	var app *decoded
+	if err := Classify(notFound); !errors.As(err, &app) || !errors.Is(err, ErrNotFound) {
+		t.Errorf("Classify(404) = %v, want decoded error matching ErrNotFound", err)
+	}
+
+	serverErr := statusError(t, context.Background(), 500, nil, "", "https://api.example.com/")
+	if status, ok := Classify(serverErr).(*UnexpectedStatus); !ok || status.StatusCode != 500 {
+		t.Errorf("Classify(500) = %v, want *UnexpectedStatus", Classify(serverErr))
+	}
+
+	other := &coded{code: 404}
+	if err := Classify(other); err != other {
+		t.Errorf("Classify(other) = %v, want %v", err, other)
+	}
+	if err := Classify(nil); err != nil {
+		t.Errorf("Classify(nil) = %v", err)
+	}
+}
+
+func TestUnmarshalJSON_Decoded(t *testing.T) {
+	withoutDecoders(t)
+	Register(0, "application/json", decodeAs("json"))
+
+	var status UnexpectedStatus
+	if err := json.Unmarshal([]byte(`{"status_code":503,"header":{"Content-Type":["application/json"]}}`), &status); err != nil {
+		t.Fatal(err)
+	}
+	var got *decoded
+	if !errors.As(status.Decoded, &got) || got.by != "json" {
+		t.Errorf("Decoded = %v, want decoded by json", status.Decoded)
+	}
+}
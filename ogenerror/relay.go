@@ -0,0 +1,88 @@
+package ogenerror
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/ogen-go/ogen/validate"
+)
+
+// MaxRelayBody is the size of the largest response body WriteTo relays.
+// Larger bodies are left out rather than cut short, which would break their
+// content type.
+var MaxRelayBody int64 = 1 << 20
+
+// relayedHeaders are the response headers WriteTo relays: those that
+// describe the body, and Retry-After.
+var relayedHeaders = []string{"Content-Type", "Content-Encoding", "Content-Language", "Retry-After"}
+
+// WriteTo relays the response of an ogen UnexpectedStatusCodeError to w: its
+// status code, the headers that describe its body, Retry-After, and its body
+// if it is at most MaxRelayBody bytes. Returns false, writing nothing, if
+// the error is not an UnexpectedStatusCodeError.
+//
+// Usage:
+//
+//	pet, err := client.GetPet(ctx, params)
+//	if err != nil {
+//	    if !ogenerror.WriteTo(w, err) {
+//	        http.Error(w, "upstream error", http.StatusBadGateway)
+//	    }
+//	    return
+//	}
+func WriteTo(w http.ResponseWriter, err error) bool {
+	var ogenErr *validate.UnexpectedStatusCodeError
+	if err == nil || !errors.As(err, &ogenErr) {
+		return false
+	}
+
+	var body []byte
+	if ogenErr.Payload != nil && ogenErr.Payload.Body != nil {
+		body = readCapped(ogenErr.Payload, MaxRelayBody)
+	}
+
+	header := w.Header()
+	if ogenErr.Payload != nil {
+		for _, name := range relayedHeaders {
+			if values := ogenErr.Payload.Header.Values(name); len(values) > 0 {
+				header[name] = values
+			}
+		}
+	}
+	if body == nil {
+		header.Del("Content-Type")
+		header.Del("Content-Encoding")
+		header.Del("Content-Language")
+		header.Set("Content-Length", "0")
+	} else {
+		// Don't let the server sniff a content type the upstream did not
+		// send.
+		if header.Get("Content-Type") == "" {
+			header["Content-Type"] = nil
+		}
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	w.WriteHeader(ogenErr.StatusCode)
+	_, _ = w.Write(body)
+	return true
+}
+
+// readCapped reads the body of resp if it is at most limit bytes, and
+// returns nil otherwise. The body is put back, whole, so that the error can
+// be parsed again.
+func readCapped(resp *http.Response, limit int64) []byte {
+	read, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	rest := resp.Body
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(read), rest), rest}
+	if err != nil || int64(len(read)) > limit {
+		return nil
+	}
+	return read
+}
@@ -0,0 +1,85 @@
+package ogenerror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	header := http.Header{
+		"Content-Type":  {"application/json"},
+		"Retry-After":   {"5"},
+		"Set-Cookie":    {"session=1"},
+		"X-Internal-Id": {"42"},
+	}
+	err := statusError(t, context.Background(), 503, header, `{"error":"busy"}`, "https://api.example.com/")
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		if !WriteTo(rec, err) {
+			t.Fatal("WriteTo() = false")
+		}
+		want := http.Header{
+			"Content-Type":   {"application/json"},
+			"Retry-After":    {"5"},
+			"Content-Length": {"16"},
+		}
+		if rec.Code != 503 || !reflect.DeepEqual(rec.Header(), want) || rec.Body.String() != `{"error":"busy"}` {
+			t.Errorf("WriteTo() wrote %d %v %s", rec.Code, rec.Header(), rec.Body)
+		}
+	}
+
+	// The body is still there for Parse.
+	if status := Parse(err); string(status.Body) != `{"error":"busy"}` {
+		t.Errorf("Parse() body after WriteTo = %q", status.Body)
+	}
+}
+
+func TestWriteTo_TooLarge(t *testing.T) {
+	saved := MaxRelayBody
+	MaxRelayBody = 8
+	t.Cleanup(func() { MaxRelayBody = saved })
+
+	body := strings.Repeat("x", 20)
+	err := statusError(t, context.Background(), 500, http.Header{"Content-Type": {"text/plain"}}, body, "https://api.example.com/")
+
+	rec := httptest.NewRecorder()
+	if !WriteTo(rec, err) {
+		t.Fatal("WriteTo() = false")
+	}
+	if rec.Code != 500 || rec.Body.Len() != 0 || rec.Header().Get("Content-Length") != "0" || rec.Header().Get("Content-Type") != "" {
+		t.Errorf("WriteTo() wrote %d %v %q", rec.Code, rec.Header(), rec.Body)
+	}
+
+	// The body is put back whole, with the bytes read past the limit.
+	if status := Parse(err); string(status.Body) != body {
+		t.Errorf("Parse() body after WriteTo = %q, want %q", status.Body, body)
+	}
+}
+
+func TestWriteTo_NoContentType(t *testing.T) {
+	err := statusError(t, context.Background(), 502, nil, "<html>bad gateway</html>", "https://api.example.com/")
+
+	rec := httptest.NewRecorder()
+	WriteTo(rec, err)
+	if got := rec.Result().Header.Get("Content-Type"); got != "" {
+		t.Errorf("Content-Type = %q, want none sniffed", got)
+	}
+}
+
+func TestWriteTo_OtherErrors(t *testing.T) {
+	for _, err := range []error{nil, errors.New("connection refused"), &coded{code: 500}} {
+		rec := httptest.NewRecorder()
+		if WriteTo(rec, err) {
+			t.Errorf("WriteTo(%v) = true", err)
+		}
+		if rec.Body.Len() != 0 || len(rec.Header()) != 0 {
+			t.Errorf("WriteTo(%v) wrote %v %q", err, rec.Header(), rec.Body)
+		}
+	}
+}
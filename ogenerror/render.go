@@ -0,0 +1,97 @@
+package ogenerror
+
+import (
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// DefaultTemplate renders an error as one line, such as
+// "getPet: Pet not found (404): No pet has ID 7 [request ID abc123]".
+const DefaultTemplate = `{{if .Operation}}{{.Operation}}: {{end}}{{.Title}}` +
+	`{{if .StatusCode}} ({{.StatusCode}}){{end}}{{if .Detail}}: {{.Detail}}{{end}}` +
+	`{{if .RequestID}} [request ID {{.RequestID}}]{{end}}`
+
+// RenderData is what the template of Render gets.
+type RenderData struct {
+	// Err is the error rendered, and Message its Error.
+	Err     error
+	Message string
+
+	// StatusCode is the status code of the response, or 0 if the error has
+	// none, and Status its text, such as "Not Found".
+	StatusCode int
+	Status     string
+
+	// Title is the title of the problem details of the response, or Status,
+	// or Message, whichever comes first. Detail is the detail of the problem
+	// details.
+	Title  string
+	Detail string
+	// Problem is the problem details of the response, or nil if it has
+	// none: use it within {{with .Problem}}.
+	Problem *ProblemDetails
+
+	// Operation, Method and URL describe the request, as in
+	// UnexpectedStatus. URL is left without its query and credentials.
+	Operation string
+	Method    string
+	URL       string
+	// RequestID is the request ID of RequestID.
+	RequestID string
+	// Body is the response body, redacted by DefaultSanitizer.
+	Body string
+}
+
+// Render renders err with the text/template tmpl, such as DefaultTemplate,
+// which gets a RenderData. Returns "" for a nil error.
+//
+// Usage:
+//
+//	msg, _ := ogenerror.Render(err, "{{.Title}}{{with .Problem}}: {{.Detail}}{{end}}")
+//	fmt.Fprintln(os.Stderr, "error:", msg)
+func Render(err error, tmpl string) (string, error) {
+	if err == nil {
+		return "", nil
+	}
+	t, parseErr := template.New("error").Parse(tmpl)
+	if parseErr != nil {
+		return "", parseErr
+	}
+
+	var out strings.Builder
+	if execErr := t.Execute(&out, renderData(err)); execErr != nil {
+		return "", execErr
+	}
+	return out.String(), nil
+}
+
+// renderData collects what Render gets of err.
+func renderData(err error) *RenderData {
+	data := &RenderData{
+		Err:        err,
+		Message:    err.Error(),
+		StatusCode: StatusCode(err),
+	}
+	data.Status = http.StatusText(data.StatusCode)
+	data.Title = data.Message
+	if data.Status != "" {
+		data.Title = data.Status
+	}
+
+	if status := parse(err); status != nil {
+		data.Operation = status.Operation
+		data.Method = status.Method
+		data.URL = status.redactedURL()
+		data.RequestID = requestID(status.Header)
+		data.Body = string(DefaultSanitizer.Sanitize(status.Body))
+	}
+	if problem, ok := Problem(err); ok {
+		data.Problem = problem
+		data.Detail = problem.Detail
+		if problem.Title != "" {
+			data.Title = problem.Title
+		}
+	}
+	return data
+}
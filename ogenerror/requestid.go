@@ -0,0 +1,56 @@
+package ogenerror
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RequestIDHeaders lists the response headers RequestID checks, in order.
+// Add the header of an API that uses another:
+//
+//	ogenerror.RequestIDHeaders = append(ogenerror.RequestIDHeaders, "X-Acme-Trace")
+var RequestIDHeaders = []string{
+	"X-Request-Id",
+	"X-Amzn-RequestId",
+	"X-Amz-Request-Id",
+	"X-Correlation-Id",
+	"CF-Ray",
+	"traceparent",
+}
+
+// RequestID returns the request or trace ID the response of an ogen error
+// carries in the first of RequestIDHeaders it has, to quote in support
+// tickets to the API's vendor. For a W3C traceparent header it is the trace
+// ID. Returns "" if the error is not an ogen UnexpectedStatusCodeError, or
+// the response has none of the headers.
+//
+// Usage:
+//
+//	if id := ogenerror.RequestID(err); id != "" {
+//	    log.Printf("get pet failed (request ID %s): %v", id, err)
+//	}
+func RequestID(err error) string {
+	status := parse(err)
+	if status == nil {
+		return ""
+	}
+	return requestID(status.Header)
+}
+
+// requestID returns the ID in the first of RequestIDHeaders header has.
+func requestID(header http.Header) string {
+	for _, name := range RequestIDHeaders {
+		value := strings.TrimSpace(header.Get(name))
+		if value == "" {
+			continue
+		}
+		if strings.EqualFold(name, "traceparent") {
+			// version-traceid-parentid-flags
+			if parts := strings.Split(value, "-"); len(parts) >= 4 {
+				return parts[1]
+			}
+		}
+		return value
+	}
+	return ""
+}
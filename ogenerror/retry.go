@@ -0,0 +1,86 @@
+package ogenerror
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+)
+
+// RetryPolicy decides which errors are worth retrying. Copy
+// DefaultRetryPolicy and change it to customize:
+//
+//	policy := ogenerror.DefaultRetryPolicy
+//	policy.Statuses = append(slices.Clone(policy.Statuses), http.StatusInternalServerError)
+//	if policy.IsRetryable(err) { ... }
+type RetryPolicy struct {
+	// Statuses lists the status codes of retryable responses.
+	Statuses []int
+	// RetryAfter makes responses with a Retry-After header retryable,
+	// whatever their status code.
+	RetryAfter bool
+	// Network makes network errors retryable: the timeouts of IsTimeout,
+	// and the connection errors of IsConnectionError but for DNS lookups
+	// of hosts that do not exist.
+	Network bool
+}
+
+// DefaultRetryPolicy retries 408, 429, 502, 503 and 504 responses,
+// responses with a Retry-After header, and network errors.
+var DefaultRetryPolicy = RetryPolicy{
+	Statuses:   []int{408, 429, 502, 503, 504},
+	RetryAfter: true,
+	Network:    true,
+}
+
+// IsRetryable reports whether err is worth retrying under
+// DefaultRetryPolicy.
+//
+// Usage:
+//
+//	for attempt := 1; ; attempt++ {
+//	    resp, err := client.GetPet(ctx, params)
+//	    if err == nil || attempt == 3 || !ogenerror.IsRetryable(err) || ctx.Err() != nil {
+//	        return resp, err
+//	    }
+//	    time.Sleep(backoff(attempt))
+//	}
+func IsRetryable(err error) bool {
+	return DefaultRetryPolicy.IsRetryable(err)
+}
+
+// IsRetryable reports whether err is worth retrying under p. Responses are
+// classified by their status code, from an ogen UnexpectedStatusCodeError
+// or an error with a GetStatusCode method, such as the convenient errors
+// ogen generates. A canceled context is never retryable. A timeout is, as
+// the timeout of an http.Client can't be told apart from the deadline of
+// the caller's context; check ctx.Err() before retrying.
+//
+// Whether the operation is safe to repeat is up to the caller: a POST that
+// timed out may have been processed.
+func (p RetryPolicy) IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if status := parse(err); status != nil {
+		return slices.Contains(p.Statuses, status.StatusCode) ||
+			p.RetryAfter && status.Header.Get("Retry-After") != ""
+	}
+	var coded statusCoder
+	if errors.As(err, &coded) {
+		return slices.Contains(p.Statuses, coded.GetStatusCode())
+	}
+
+	return p.Network && isNetworkError(err)
+}
+
+// isNetworkError reports whether err is a timeout or a connection error
+// other than a DNS lookup that will fail again.
+func isNetworkError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	return IsTimeout(err) || IsConnectionError(err)
+}
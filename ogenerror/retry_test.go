@@ -0,0 +1,70 @@
+package ogenerror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestIsRetryable(t *testing.T) {
+	status := func(code int, header http.Header) error {
+		return statusError(t, context.Background(), code, header, "", "https://api.example.com/")
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"408", status(408, nil), true},
+		{"429", status(429, nil), true},
+		{"502", status(502, nil), true},
+		{"503", fmt.Errorf("list pets: %w", status(503, nil)), true},
+		{"504", status(504, nil), true},
+		{"500", status(500, nil), false},
+		{"404", status(404, nil), false},
+		{"500 with Retry-After", status(500, http.Header{"Retry-After": {"1"}}), true},
+		{"generated 503", &coded{code: 503}, true},
+		{"generated 400", &coded{code: 400}, false},
+		{"canceled", fmt.Errorf("get: %w", context.Canceled), false},
+		{"deadline", context.DeadlineExceeded, true},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, true},
+		{"no such host", &net.DNSError{Err: "no such host", Name: "api.example.invalid", IsNotFound: true}, false},
+		{"temporary DNS failure", &net.DNSError{Err: "server misbehaving", Name: "api.example.com", IsTemporary: true}, true},
+		{"other error", errors.New("invalid character"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{Statuses: []int{500}}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"listed status", statusError(t, context.Background(), 500, nil, "", "https://api.example.com/"), true},
+		{"default status", statusError(t, context.Background(), 503, nil, "", "https://api.example.com/"), false},
+		{"Retry-After", statusError(t, context.Background(), 503, http.Header{"Retry-After": {"1"}}, "", "https://api.example.com/"), false},
+		{"generated error", &coded{code: 500}, true},
+		{"network error", context.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
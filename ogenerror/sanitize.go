@@ -0,0 +1,154 @@
+package ogenerror
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-faster/jx"
+)
+
+// redacted replaces the values Sanitize masks.
+const redacted = "***"
+
+// bearerToken matches bearer tokens in text, such as an echoed
+// Authorization header.
+var bearerToken = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+
+// Sanitizer redacts response bodies before they are logged. Copy
+// DefaultSanitizer and change it to customize:
+//
+//	sanitizer := ogenerror.DefaultSanitizer
+//	sanitizer.Fields = append(slices.Clone(sanitizer.Fields), "ssn")
+//	log.Print(sanitizer.Format(err))
+type Sanitizer struct {
+	// MaxBody is the number of bytes of the body to keep, or 0 to keep it
+	// all.
+	MaxBody int
+	// Fields lists the names of JSON object members whose values are
+	// masked, at any depth. Names match regardless of case.
+	Fields []string
+}
+
+// DefaultSanitizer keeps the first 1024 bytes of a body, and masks the
+// members that commonly hold credentials or personal data.
+var DefaultSanitizer = Sanitizer{
+	MaxBody: 1024,
+	Fields: []string{
+		"access_token", "refresh_token", "id_token", "token",
+		"authorization", "password", "secret", "client_secret",
+		"api_key", "apikey", "email",
+	},
+}
+
+// Format describes err for logs under DefaultSanitizer.
+//
+// Usage:
+//
+//	if err != nil {
+//	    log.Printf("get pet: %s", ogenerror.Format(err))
+//	}
+func Format(err error) string {
+	return DefaultSanitizer.Format(err)
+}
+
+// Sanitize redacts body under DefaultSanitizer.
+func Sanitize(body []byte) []byte {
+	return DefaultSanitizer.Sanitize(body)
+}
+
+// Format describes err for logs. For an ogen UnexpectedStatusCodeError it is
+// the description of UnexpectedStatus.String followed by the sanitized
+// body, such as
+// `getPet: GET https://api.example.com/pets/1: unexpected status 401: {"token":"***"}`.
+// Other errors are described by their Error method, unchanged.
+func (s Sanitizer) Format(err error) string {
+	if err == nil {
+		return ""
+	}
+	status := parse(err)
+	if status == nil {
+		return err.Error()
+	}
+	if len(status.Body) == 0 {
+		return status.String()
+	}
+	return status.String() + ": " + string(s.Sanitize(status.Body))
+}
+
+// Sanitize returns a copy of body with the values of Fields masked if it is
+// JSON, bearer tokens masked, and cut to MaxBody bytes. A cut body ends with
+// the number of bytes it had, such as "... (4096 bytes)".
+func (s Sanitizer) Sanitize(body []byte) []byte {
+	out := body
+	if masked, ok := s.maskJSON(body); ok {
+		out = masked
+	}
+	out = bearerToken.ReplaceAll(out, []byte("Bearer "+redacted))
+
+	if s.MaxBody <= 0 || len(out) <= s.MaxBody {
+		return bytes.Clone(out)
+	}
+	cut := s.MaxBody
+	for cut > 0 && !utf8.RuneStart(out[cut]) {
+		cut--
+	}
+	return append(bytes.Clone(out[:cut]), "... ("+strconv.Itoa(len(out))+" bytes)"...)
+}
+
+// maskJSON returns body with the values of Fields masked. Returns false if
+// body is not JSON.
+func (s Sanitizer) maskJSON(body []byte) ([]byte, bool) {
+	if len(s.Fields) == 0 || !jx.Valid(body) {
+		return nil, false
+	}
+	var e jx.Encoder
+	if err := s.copyJSON(jx.DecodeBytes(body), &e); err != nil {
+		return nil, false
+	}
+	return e.Bytes(), true
+}
+
+// copyJSON copies the value d is at to e, masking the values of Fields.
+func (s Sanitizer) copyJSON(d *jx.Decoder, e *jx.Encoder) error {
+	switch d.Next() {
+	case jx.Object:
+		e.ObjStart()
+		err := d.ObjBytes(func(d *jx.Decoder, key []byte) error {
+			e.FieldStart(string(key))
+			if s.masks(string(key)) {
+				e.Str(redacted)
+				return d.Skip()
+			}
+			return s.copyJSON(d, e)
+		})
+		e.ObjEnd()
+		return err
+	case jx.Array:
+		e.ArrStart()
+		err := d.Arr(func(d *jx.Decoder) error {
+			return s.copyJSON(d, e)
+		})
+		e.ArrEnd()
+		return err
+	default:
+		raw, err := d.Raw()
+		if err != nil {
+			return err
+		}
+		e.Raw(raw)
+		return nil
+	}
+}
+
+// masks reports whether the value of the member name is masked.
+func (s Sanitizer) masks(name string) bool {
+	for _, field := range s.Fields {
+		if strings.EqualFold(field, name) {
+			return true
+		}
+	}
+	return false
+}
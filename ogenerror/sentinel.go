@@ -0,0 +1,53 @@
+package ogenerror
+
+import "errors"
+
+// Sentinel errors for common status codes. An UnexpectedStatus from Parse
+// matches the one of its status code with errors.Is:
+//
+//	if errors.Is(ogenerror.Parse(err), ogenerror.ErrNotFound) { ... }
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// sentinels maps status codes to their sentinel errors.
+var sentinels = map[int]error{
+	400: ErrBadRequest,
+	401: ErrUnauthorized,
+	403: ErrForbidden,
+	404: ErrNotFound,
+	409: ErrConflict,
+	429: ErrRateLimited,
+}
+
+// Error returns the description of String, so that an UnexpectedStatus can
+// be returned and wrapped as an error.
+func (s *UnexpectedStatus) Error() string {
+	return s.String()
+}
+
+// Unwrap returns the error Parse extracted the status from, so that
+// errors.Is and errors.As match the ogen error and the errors wrapping it,
+// or nil for a status UnmarshalJSON rehydrated.
+func (s *UnexpectedStatus) Unwrap() error {
+	if s == nil {
+		return nil
+	}
+	return s.cause
+}
+
+// Is reports whether target is the sentinel error of the status code, such
+// as ErrNotFound for 404. It is false for a nil UnexpectedStatus, so the
+// result of Parse can be passed to errors.Is without a nil check.
+func (s *UnexpectedStatus) Is(target error) bool {
+	if s == nil {
+		return false
+	}
+	sentinel, ok := sentinels[s.StatusCode]
+	return ok && target == sentinel
+}
@@ -0,0 +1,65 @@
+package ogenerror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ogen-go/ogen/validate"
+)
+
+func TestIs(t *testing.T) {
+	tests := []struct {
+		code int
+		want error
+	}{
+		{400, ErrBadRequest},
+		{401, ErrUnauthorized},
+		{403, ErrForbidden},
+		{404, ErrNotFound},
+		{409, ErrConflict},
+		{429, ErrRateLimited},
+		{500, nil},
+	}
+	sentinelErrs := []error{ErrBadRequest, ErrUnauthorized, ErrForbidden, ErrNotFound, ErrConflict, ErrRateLimited}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.code), func(t *testing.T) {
+			status := Parse(statusError(t, context.Background(), tt.code, nil, "", "https://api.example.com/"))
+			for _, sentinel := range sentinelErrs {
+				if got := errors.Is(status, sentinel); got != (sentinel == tt.want) {
+					t.Errorf("errors.Is(%v) = %v", sentinel, got)
+				}
+			}
+		})
+	}
+
+	var status *UnexpectedStatus
+	if errors.Is(status, ErrNotFound) {
+		t.Error("nil status is ErrNotFound")
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	cause := fmt.Errorf("get pet: %w", statusError(t, context.Background(), 404, nil, "", "https://api.example.com/pets/1"))
+	status := Parse(cause)
+
+	if status.Unwrap() != cause {
+		t.Errorf("Unwrap() = %v, want %v", status.Unwrap(), cause)
+	}
+	var ogenErr *validate.UnexpectedStatusCodeError
+	if !errors.As(status, &ogenErr) || ogenErr.StatusCode != 404 {
+		t.Errorf("errors.As(UnexpectedStatusCodeError) = %v", ogenErr)
+	}
+	if wrapped := fmt.Errorf("handler: %w", status); !errors.Is(wrapped, ErrNotFound) {
+		t.Error("wrapped status is not ErrNotFound")
+	}
+	if status.Error() != status.String() {
+		t.Errorf("Error() = %q, want %q", status.Error(), status.String())
+	}
+
+	var none *UnexpectedStatus
+	if none.Unwrap() != nil || (&UnexpectedStatus{StatusCode: 404}).Unwrap() != nil {
+		t.Error("Unwrap() of nil or rehydrated status is not nil")
+	}
+}
@@ -0,0 +1,65 @@
+package ogenerror
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// IsTimeout reports whether err is a timeout: the deadline of the request
+// context, the Timeout of the http.Client, or a dial, read or write timeout
+// of the connection. Through an ogen client these arrive wrapped in a
+// *url.Error, which IsTimeout looks through.
+func IsTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsCanceled reports whether err comes from canceling the request context.
+func IsCanceled(err error) bool {
+	return err != nil && errors.Is(err, context.Canceled)
+}
+
+// IsConnectionError reports whether the request failed to connect, or lost
+// its connection before the response was read: a refused, reset or closed
+// connection, a failed DNS lookup, or a response cut short. Timeouts and
+// cancellations are not connection errors, nor are responses the client
+// read but could not decode.
+//
+// Usage:
+//
+//	switch {
+//	case ogenerror.IsCanceled(err):
+//	    return ctx.Err()
+//	case ogenerror.IsTimeout(err):
+//	    return fmt.Errorf("pets API did not answer in time: %w", err)
+//	case ogenerror.IsConnectionError(err):
+//	    return fmt.Errorf("pets API is unreachable: %w", err)
+//	}
+func IsConnectionError(err error) bool {
+	if err == nil || IsTimeout(err) || IsCanceled(err) || IsSchemaError(err) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	return errors.As(err, &dnsErr) || errors.As(err, &opErr)
+}
@@ -0,0 +1,73 @@
+package ogenerror
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// wireStatus is the JSON form of UnexpectedStatus. Its members are kept
+// stable, so that errors persisted by one version can be read by another.
+type wireStatus struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	// Body is base64, as any []byte in JSON, so that binary bodies survive.
+	Body      []byte `json:"body,omitempty"`
+	Method    string `json:"method,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Operation string `json:"operation,omitempty"`
+}
+
+// MarshalJSON encodes the status for a queue or a store, such as
+//
+//	{"status_code":503,"header":{"Retry-After":["30"]},"body":"eyJlcnJvciI6ImJ1c3kifQ==",
+//	 "method":"POST","url":"https://api.example.com/pets","operation":"createPet"}
+//
+// with the body in base64. Unlike String and LogValue, the URL is encoded
+// whole, with its query and credentials, and the body is not redacted.
+// Decoded is left out: UnmarshalJSON decodes it again.
+func (s *UnexpectedStatus) MarshalJSON() ([]byte, error) {
+	w := wireStatus{
+		StatusCode: s.StatusCode,
+		Header:     s.Header,
+		Body:       s.Body,
+		Method:     s.Method,
+		Operation:  s.Operation,
+	}
+	if s.URL != nil {
+		w.URL = s.URL.String()
+	}
+	return json.Marshal(w)
+}
+
+// UnmarshalJSON decodes a status encoded by MarshalJSON, and sets Decoded
+// as Parse does, with the decoders registered in this process. The helpers
+// of the package, such as IsRetryable and Problem, accept the result as
+// they accept the ogen error it came from.
+func (s *UnexpectedStatus) UnmarshalJSON(data []byte) error {
+	var w wireStatus
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+
+	*s = UnexpectedStatus{
+		StatusCode: w.StatusCode,
+		Header:     w.Header,
+		Body:       w.Body,
+		Method:     w.Method,
+		Operation:  w.Operation,
+	}
+	if s.Header == nil {
+		s.Header = http.Header{}
+	}
+	if w.URL != "" {
+		u, err := url.Parse(w.URL)
+		if err != nil {
+			return fmt.Errorf("url: %w", err)
+		}
+		s.URL = u
+	}
+	s.Decoded = decode(s)
+	return nil
+}
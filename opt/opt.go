@@ -0,0 +1,78 @@
+// Package opt provides generic helpers for the Opt, Nil and OptNil wrappers
+// of ogen-generated packages.
+//
+// Every wrapper has a Get method, and a SetTo method on its pointer, so the
+// helpers work with any of them without knowing the generated types:
+//
+//	name := opt.OrElse(user.Nickname, func() string { return user.Email })
+//	user.Age = opt.FromPtr[api.OptInt](req.Age)
+package opt
+
+// Getter is implemented by every ogen wrapper holding a T.
+type Getter[T any] interface {
+	// Get returns the value and whether it is set and not null.
+	Get() (T, bool)
+}
+
+// Setter is implemented by a pointer to an ogen wrapper R holding a T.
+type Setter[R, T any] interface {
+	*R
+	SetTo(T)
+}
+
+// Map returns a wrapper of type R holding f of the value of o:
+//
+//	id := opt.Map[api.OptString](user.ID, strconv.Itoa)
+//
+// If o is null and R is nullable, the result is null. Otherwise, if o holds
+// no value, the result is unset and f is not called.
+func Map[R any, P Setter[R, U], T, U any](o Getter[T], f func(T) U) R {
+	var r R
+	v, ok := o.Get()
+	switch {
+	case ok:
+		P(&r).SetTo(f(v))
+	case IsNull(o):
+		if n, ok := any(P(&r)).(interface{ SetToNull() }); ok {
+			n.SetToNull()
+		}
+	}
+	return r
+}
+
+// OrElse returns the value of o, or the result of f if o holds no value.
+// Unlike the generated Or method, the default is only computed when needed.
+func OrElse[T any](o Getter[T], f func() T) T {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return f()
+}
+
+// Ptr returns a pointer to a copy of the value of o, or nil if o holds no
+// value.
+func Ptr[T any](o Getter[T]) *T {
+	v, ok := o.Get()
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// FromPtr returns a wrapper of type R holding *p, or an unset R if p is nil:
+//
+//	user.Age = opt.FromPtr[api.OptInt](req.Age)
+func FromPtr[R any, P Setter[R, T], T any](p *T) R {
+	var r R
+	if p != nil {
+		P(&r).SetTo(*p)
+	}
+	return r
+}
+
+// IsNull reports whether o is explicitly null. It is false for wrappers
+// that cannot be null, such as Opt types.
+func IsNull[T any](o Getter[T]) bool {
+	n, ok := o.(interface{ IsNull() bool })
+	return ok && n.IsNull()
+}
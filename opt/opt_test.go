@@ -0,0 +1,191 @@
+package opt
+
+import (
+	"strconv"
+	"testing"
+)
+
+// OptInt, OptString and OptNilInt have the methods ogen generates for its
+// wrappers.
+
+type OptInt struct {
+	Value int
+	Set   bool
+}
+
+func (o *OptInt) SetTo(v int) {
+	o.Set = true
+	o.Value = v
+}
+
+func (o OptInt) Get() (v int, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+type OptString struct {
+	Value string
+	Set   bool
+}
+
+func (o *OptString) SetTo(v string) {
+	o.Set = true
+	o.Value = v
+}
+
+func (o OptString) Get() (v string, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+type OptNilString struct {
+	Value string
+	Set   bool
+	Null  bool
+}
+
+func (o *OptNilString) SetTo(v string) {
+	o.Set = true
+	o.Null = false
+	o.Value = v
+}
+
+func (o OptNilString) IsNull() bool { return o.Null }
+
+func (o *OptNilString) SetToNull() {
+	o.Set = true
+	o.Null = true
+	var v string
+	o.Value = v
+}
+
+func (o OptNilString) Get() (v string, ok bool) {
+	if o.Null {
+		return v, false
+	}
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+type OptNilInt struct {
+	Value int
+	Set   bool
+	Null  bool
+}
+
+func (o *OptNilInt) SetTo(v int) {
+	o.Set = true
+	o.Null = false
+	o.Value = v
+}
+
+func (o OptNilInt) IsNull() bool { return o.Null }
+
+func (o *OptNilInt) SetToNull() {
+	o.Set = true
+	o.Null = true
+	var v int
+	o.Value = v
+}
+
+func (o OptNilInt) Get() (v int, ok bool) {
+	if o.Null {
+		return v, false
+	}
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+func TestMap(t *testing.T) {
+	if got, want := Map[OptString](OptInt{Value: 7, Set: true}, strconv.Itoa), (OptString{Value: "7", Set: true}); got != want {
+		t.Errorf("Map(set) = %+v, want %+v", got, want)
+	}
+
+	called := false
+	itoa := func(v int) string {
+		called = true
+		return strconv.Itoa(v)
+	}
+	if got := Map[OptString](OptInt{}, itoa); got != (OptString{}) || called {
+		t.Errorf("Map(unset) = %+v, called = %v, want unset without calling f", got, called)
+	}
+
+	null := OptNilInt{Set: true, Null: true}
+	if got, want := Map[OptNilString](null, itoa), (OptNilString{Set: true, Null: true}); got != want {
+		t.Errorf("Map(null) to nullable = %+v, want %+v", got, want)
+	}
+	if got := Map[OptString](null, itoa); got != (OptString{}) {
+		t.Errorf("Map(null) to Opt = %+v, want unset", got)
+	}
+}
+
+func TestOrElse(t *testing.T) {
+	fallback := func() string { return "fallback" }
+	tests := []struct {
+		name string
+		o    Getter[string]
+		want string
+	}{
+		{"set", OptString{Value: "ada", Set: true}, "ada"},
+		{"set to zero", OptString{Set: true}, ""},
+		{"unset", OptString{}, "fallback"},
+		{"null", OptNilString{Set: true, Null: true}, "fallback"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OrElse(tt.o, fallback); got != tt.want {
+				t.Errorf("OrElse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPtr(t *testing.T) {
+	if p := Ptr(OptInt{Value: 7, Set: true}); p == nil || *p != 7 {
+		t.Errorf("Ptr(set) = %v, want pointer to 7", p)
+	}
+	if p := Ptr(OptInt{}); p != nil {
+		t.Errorf("Ptr(unset) = %v, want nil", *p)
+	}
+	if p := Ptr(OptNilInt{Set: true, Null: true}); p != nil {
+		t.Errorf("Ptr(null) = %v, want nil", *p)
+	}
+}
+
+func TestFromPtr(t *testing.T) {
+	v := 0
+	if got, want := FromPtr[OptInt](&v), (OptInt{Set: true}); got != want {
+		t.Errorf("FromPtr(&0) = %+v, want %+v", got, want)
+	}
+	if got := FromPtr[OptNilInt]((*int)(nil)); got != (OptNilInt{}) {
+		t.Errorf("FromPtr(nil) = %+v, want unset", got)
+	}
+}
+
+func TestIsNull(t *testing.T) {
+	tests := []struct {
+		name string
+		o    Getter[int]
+		want bool
+	}{
+		{"null", OptNilInt{Set: true, Null: true}, true},
+		{"set", OptNilInt{Value: 1, Set: true}, false},
+		{"unset", OptNilInt{}, false},
+		{"not nullable", OptInt{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsNull(tt.o); got != tt.want {
+				t.Errorf("IsNull() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}